@@ -6,72 +6,263 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	proto "github.com/syedalijabir/protos/cache-service"
 
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
+// defaultTTL applies to Sets without a TTL so no key lives forever. Both
+// backends expire keys on access and with a background sweep, so memory is
+// reclaimed even for keys that are never read again.
+const defaultTTL = time.Hour
+
+// defaultMaxValueBytes bounds a single value. Total memory is bounded by
+// Redis' maxmemory or CACHE_MAX_MEMORY_BYTES, which evict the least recently
+// used keys when reached.
+const defaultMaxValueBytes = 64 << 10
+
+// defaultMaxBatchSize bounds the keys of one MGet or MSet. It leaves room for
+// url-service's batch lookups and the cache writes of its batch shortens.
+const defaultMaxBatchSize = 5000
+
 type cacheServer struct {
 	proto.UnimplementedCacheServiceServer
-	rdb *redis.Client
+	store         store
+	defaultTTL    time.Duration
+	maxValueBytes int
+	maxBatchSize  int
+	stats         *cacheStats
 }
 
-// Get retrieves a value from Redis
+// Get retrieves a value from the cache
 func (s *cacheServer) Get(ctx context.Context, req *proto.GetRequest) (*proto.GetResponse, error) {
-	log.Printf("Cache GET request for key: %s", req.Key)
+	key, err := storeKey(req.Namespace, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Cache GET request for key: %s", key)
 
-	val, err := s.rdb.Get(ctx, req.Key).Result()
-	if err == redis.Nil {
-		log.Printf("Cache miss for key: %s", req.Key)
-		return &proto.GetResponse{Found: false}, nil
-	} else if err != nil {
-		log.Printf("Redis error: %v", err)
+	val, found, err := s.store.Get(ctx, key)
+	if err != nil {
+		log.Printf("Store error: %v", err)
 		return nil, err
 	}
+	if !found {
+		log.Printf("Cache miss for key: %s", key)
+		s.stats.miss(1)
+		return &proto.GetResponse{Found: false}, nil
+	}
 
-	log.Printf("Cache hit for key: %s", req.Key)
+	log.Printf("Cache hit for key: %s", key)
+	s.stats.hit(1)
 	return &proto.GetResponse{Value: val, Found: true}, nil
 }
 
-// Set stores a value in Redis with optional TTL
+// Set stores a value in the cache with optional TTL
 func (s *cacheServer) Set(ctx context.Context, req *proto.SetRequest) (*proto.SetResponse, error) {
-	log.Printf("Cache SET request for key: %s", req.Key)
+	key, err := storeKey(req.Namespace, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Cache SET request for key: %s", key)
 
-	var expiration time.Duration
-	if req.TtlSeconds > 0 {
-		expiration = time.Duration(req.TtlSeconds) * time.Second
-	} else {
-		expiration = 5
+	expiration, err := s.expiration(req)
+	if err != nil {
+		return nil, err
 	}
 
-	err := s.rdb.Set(ctx, req.Key, req.Value, expiration).Err()
+	err = s.store.Set(ctx, key, req.Value, expiration)
 	if err != nil {
-		log.Printf("Redis error: %v", err)
+		log.Printf("Store error: %v", err)
 		return nil, err
 	}
 
-	log.Printf("Cache set successful for key: %s", req.Key)
+	log.Printf("Cache set successful for key: %s", key)
+	s.stats.set(1)
 	return &proto.SetResponse{Success: true}, nil
 }
 
-// Delete removes a key from Redis
+// SetIfAbsent stores a value only if its key doesn't exist yet, so callers
+// racing to claim a key can tell which of them won.
+func (s *cacheServer) SetIfAbsent(ctx context.Context, req *proto.SetRequest) (*proto.SetIfAbsentResponse, error) {
+	key, err := storeKey(req.Namespace, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Cache SETNX request for key: %s", key)
+
+	expiration, err := s.expiration(req)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.store.SetNX(ctx, key, req.Value, expiration)
+	if err != nil {
+		log.Printf("Store error: %v", err)
+		return nil, err
+	}
+
+	if stored {
+		s.stats.set(1)
+	}
+	return &proto.SetIfAbsentResponse{Stored: stored}, nil
+}
+
+// Delete removes a key from the cache. Deleting a missing key succeeds, so
+// retries are safe.
 func (s *cacheServer) Delete(ctx context.Context, req *proto.DeleteRequest) (*proto.DeleteResponse, error) {
-	log.Printf("Cache DELETE request for key: %s", req.Key)
+	key, err := storeKey(req.Namespace, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Cache DELETE request for key: %s", key)
 
-	err := s.rdb.Del(ctx, req.Key).Err()
+	existed, err := s.store.Delete(ctx, key)
 	if err != nil {
-		log.Printf("Redis error: %v", err)
+		log.Printf("Store error: %v", err)
 		return nil, err
 	}
 
-	log.Printf("Cache delete successful for key: %s", req.Key)
-	return &proto.DeleteResponse{Success: true}, nil
+	s.stats.delete()
+	log.Printf("Cache delete successful for key: %s (existed: %t)", key, existed)
+	return &proto.DeleteResponse{Success: true, Existed: existed}, nil
+}
+
+// Exists reports whether a key is present in the cache without fetching it
+func (s *cacheServer) Exists(ctx context.Context, req *proto.ExistsRequest) (*proto.ExistsResponse, error) {
+	key, err := storeKey(req.Namespace, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Cache EXISTS request for key: %s", key)
+
+	exists, err := s.store.Exists(ctx, key)
+	if err != nil {
+		log.Printf("Store error: %v", err)
+		return nil, err
+	}
+
+	return &proto.ExistsResponse{Exists: exists}, nil
+}
+
+// MGet retrieves several values from the cache in one round trip
+func (s *cacheServer) MGet(ctx context.Context, req *proto.MGetRequest) (*proto.MGetResponse, error) {
+	log.Printf("Cache MGET request for %d keys in namespace %q", len(req.Keys), req.Namespace)
+
+	if len(req.Keys) > s.maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "batch of %d keys exceeds the maximum of %d", len(req.Keys), s.maxBatchSize)
+	}
+
+	resp := &proto.MGetResponse{Values: make([]*proto.GetResponse, len(req.Keys))}
+	if len(req.Keys) == 0 {
+		return resp, nil
+	}
+
+	keys, err := storeKeys(req.Namespace, req.Keys)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := s.store.MGet(ctx, keys)
+	if err != nil {
+		log.Printf("Store error: %v", err)
+		return nil, err
+	}
+
+	hits := 0
+	for i, val := range vals {
+		resp.Values[i] = &proto.GetResponse{Value: val.value, Found: val.found}
+		if val.found {
+			hits++
+		}
+	}
+
+	log.Printf("Cache MGET found %d of %d keys", hits, len(req.Keys))
+	s.stats.hit(hits)
+	s.stats.miss(len(req.Keys) - hits)
+	return resp, nil
+}
+
+// MSet stores several values, each with its own TTL, in one round trip. The
+// writes are applied atomically so readers never see half of a batch.
+func (s *cacheServer) MSet(ctx context.Context, req *proto.MSetRequest) (*proto.MSetResponse, error) {
+	log.Printf("Cache MSET request for %d keys", len(req.Entries))
+
+	if len(req.Entries) > s.maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "batch of %d keys exceeds the maximum of %d", len(req.Entries), s.maxBatchSize)
+	}
+	entries := make([]storeEntry, len(req.Entries))
+	for i, entry := range req.Entries {
+		key, err := storeKey(entry.Namespace, entry.Key)
+		if err == nil {
+			entries[i].ttl, err = s.expiration(entry)
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "entry %d (%s): %s", i, entry.Key, status.Convert(err).Message())
+		}
+		entries[i].key, entries[i].value = key, entry.Value
+	}
+	if len(req.Entries) == 0 {
+		return &proto.MSetResponse{Success: true}, nil
+	}
+
+	if err := s.store.MSet(ctx, entries); err != nil {
+		log.Printf("Store error: %v", err)
+		return nil, err
+	}
+
+	log.Printf("Cache MSET successful for %d keys", len(req.Entries))
+	s.stats.set(len(req.Entries))
+	return &proto.MSetResponse{Success: true}, nil
+}
+
+// FlushNamespace removes every key in a namespace, leaving other namespaces
+// untouched
+func (s *cacheServer) FlushNamespace(ctx context.Context, req *proto.FlushNamespaceRequest) (*proto.FlushNamespaceResponse, error) {
+	log.Printf("Cache FLUSH request for namespace: %q", req.Namespace)
+
+	if req.Namespace == "" {
+		return nil, status.Error(codes.InvalidArgument, "namespace is required")
+	}
+	prefix, err := storeKey(req.Namespace, "")
+	if err != nil {
+		return nil, err
+	}
+
+	deleted, err := s.store.DeletePrefix(ctx, prefix)
+	if err != nil {
+		log.Printf("Store error: %v", err)
+		return nil, err
+	}
+
+	log.Printf("Cache flush removed %d keys from namespace %s", deleted, req.Namespace)
+	return &proto.FlushNamespaceResponse{Deleted: deleted}, nil
+}
+
+// expiration validates a Set and returns the TTL to store it with.
+func (s *cacheServer) expiration(req *proto.SetRequest) (time.Duration, error) {
+	if req.TtlSeconds < 0 {
+		return 0, status.Error(codes.InvalidArgument, "ttl_seconds must not be negative")
+	}
+	if len(req.Value) > s.maxValueBytes {
+		return 0, status.Errorf(codes.InvalidArgument, "value of %d bytes exceeds the maximum of %d", len(req.Value), s.maxValueBytes)
+	}
+	if req.TtlSeconds == 0 {
+		return s.defaultTTL, nil
+	}
+	return time.Duration(req.TtlSeconds) * time.Second, nil
 }
 
 // HealthCheck HTTP endpoint for simple health monitoring
@@ -84,28 +275,60 @@ func (s *cacheServer) HealthCheck(c *gin.Context) {
 }
 
 func main() {
-	// Redis connection
-	redisHost := os.Getenv("REDIS_HOST")
-	if redisHost == "" {
-		redisHost = "localhost"
+	st, description, err := newStore(context.Background())
+	if err != nil {
+		log.Fatalf("failed to open cache store: %v", err)
 	}
-	redisPort := os.Getenv("REDIS_PORT")
-	if redisPort == "" {
-		redisPort = "6379"
+	defer func() {
+		// Writes the final snapshot of the memory backend
+		if err := st.Close(); err != nil {
+			log.Printf("Warning: failed to close cache store: %v", err)
+		}
+	}()
+
+	ttl := defaultTTL
+	if value := os.Getenv("CACHE_DEFAULT_TTL"); value != "" {
+		ttl, err = time.ParseDuration(value)
+		if err != nil || ttl < time.Second {
+			log.Fatalf("invalid CACHE_DEFAULT_TTL %q, want a duration of at least 1s", value)
+		}
 	}
 
-	rdb := redis.NewClient(&redis.Options{
-		Addr: redisHost + ":" + redisPort,
-	})
+	maxValueBytes := defaultMaxValueBytes
+	if value := os.Getenv("CACHE_MAX_VALUE_BYTES"); value != "" {
+		maxValueBytes, err = strconv.Atoi(value)
+		if err != nil || maxValueBytes <= 0 {
+			log.Fatalf("invalid CACHE_MAX_VALUE_BYTES %q, want a positive number", value)
+		}
+	}
 
-	// Test Redis connection
-	_, err := rdb.Ping(context.Background()).Result()
-	if err != nil {
-		log.Fatalf("failed to connect to Redis: %v", err)
+	maxBatchSize := defaultMaxBatchSize
+	if value := os.Getenv("CACHE_MAX_BATCH_SIZE"); value != "" {
+		maxBatchSize, err = strconv.Atoi(value)
+		if err != nil || maxBatchSize <= 0 {
+			log.Fatalf("invalid CACHE_MAX_BATCH_SIZE %q, want a positive number", value)
+		}
+	}
+
+	// Reflection lets grpcurl call the service without compiled stubs. It is
+	// on by default only with INSECURE_DEV_MODE, and ENABLE_REFLECTION, read
+	// second, overrides that
+	reflectionEnabled := false
+	for _, key := range []string{"INSECURE_DEV_MODE", "ENABLE_REFLECTION"} {
+		if value := os.Getenv(key); value != "" {
+			reflectionEnabled, err = strconv.ParseBool(value)
+			if err != nil {
+				log.Fatalf("invalid %s %q, want true or false", key, value)
+			}
+		}
 	}
 
 	cacheServer := &cacheServer{
-		rdb: rdb,
+		store:         st,
+		defaultTTL:    ttl,
+		maxValueBytes: maxValueBytes,
+		maxBatchSize:  maxBatchSize,
+		stats:         newCacheStats(st),
 	}
 
 	// Start gRPC server
@@ -114,24 +337,57 @@ func main() {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	server := grpc.NewServer()
+	shutdownTracing, err := setupTracing(context.Background(), "cache-service")
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	server := grpc.NewServer(
+		grpc.StatsHandler(serverTracing()),
+		// Allow url-service's keepalive pings on idle connections
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             10 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	)
 	proto.RegisterCacheServiceServer(server, cacheServer)
+	if reflectionEnabled {
+		reflection.Register(server)
+	}
 
 	// Register gRPC health check
 	healthServer := health.NewServer()
 	grpc_health_v1.RegisterHealthServer(server, healthServer)
 
-	// Start HTTP health endpoint
-	// go func() {
-	// 	r := gin.Default()
-	// 	r.GET("/health", cacheServer.HealthCheck)
-	// 	if err := r.Run(":8080"); err != nil {
-	// 		log.Fatalf("failed to start HTTP health server: %v", err)
-	// 	}
-	// }()
+	// Start HTTP health and metrics endpoint
+	httpPort := os.Getenv("HTTP_PORT")
+	if httpPort == "" {
+		httpPort = "8080"
+	}
+	go func() {
+		r := gin.New()
+		r.Use(gin.Recovery())
+		r.GET("/health", cacheServer.HealthCheck)
+		r.GET("/metrics", gin.WrapH(promhttp.HandlerFor(cacheServer.stats.registry, promhttp.HandlerOpts{})))
+		if err := r.Run(":" + httpPort); err != nil {
+			log.Fatalf("failed to start HTTP health server: %v", err)
+		}
+	}()
+
+	// Stop gracefully on SIGINT/SIGTERM so the store is closed cleanly
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-sigCh
+		log.Printf("Received %s, shutting down", sig)
+		healthServer.Shutdown()
+		server.GracefulStop()
+	}()
 
-	log.Printf("Cache Service starting on :50052 with Redis at %s:%s", redisHost, redisPort)
+	log.Printf("Cache Service starting on :50052 with %s (gRPC reflection enabled: %t)", description, reflectionEnabled)
 	if err := server.Serve(lis); err != nil {
 		log.Fatalf("failed to serve gRPC: %v", err)
 	}
+	log.Printf("Cache Service stopped")
 }
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	proto "github.com/syedalijabir/protos/cache-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestCacheServer returns a cache server on a memory store.
+func newTestCacheServer(t *testing.T) *cacheServer {
+	t.Helper()
+	st := newTestMemoryStore(t, memoryConfig{})
+	return &cacheServer{
+		store:         st,
+		defaultTTL:    defaultTTL,
+		maxValueBytes: defaultMaxValueBytes,
+		maxBatchSize:  defaultMaxBatchSize,
+		stats:         newCacheStats(st),
+	}
+}
+
+// expiresIn returns how long key of the url namespace has left in s's
+// memory store.
+func expiresIn(t *testing.T, s *cacheServer, key string) time.Duration {
+	t.Helper()
+	st := s.store.(*memoryStore)
+	storeKey, _ := storeKey("url", key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	entry, ok := st.entries[storeKey]
+	if !ok {
+		t.Fatalf("%s not stored", key)
+	}
+	return time.Until(entry.expiresAt)
+}
+
+func TestDeleteAndExists(t *testing.T) {
+	s := newTestCacheServer(t)
+	ctx := context.Background()
+	if _, err := s.Set(ctx, &proto.SetRequest{Namespace: "url", Key: "abc123", Value: "https://example.com", TtlSeconds: 3600}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	exists := func(step string, want bool) {
+		t.Helper()
+		resp, err := s.Exists(ctx, &proto.ExistsRequest{Namespace: "url", Key: "abc123"})
+		if err != nil || resp.Exists != want {
+			t.Errorf("%s: Exists = %v, %v, want %v", step, resp, err, want)
+		}
+	}
+	exists("after Set", true)
+
+	// Deleting is idempotent and reports whether the key was there
+	for i, want := range []bool{true, false} {
+		resp, err := s.Delete(ctx, &proto.DeleteRequest{Namespace: "url", Key: "abc123"})
+		if err != nil || !resp.Success || resp.Existed != want {
+			t.Errorf("Delete %d = %v, %v, want existed %v", i+1, resp, err, want)
+		}
+	}
+	exists("after Delete", false)
+	if resp, err := s.Get(ctx, &proto.GetRequest{Namespace: "url", Key: "abc123"}); err != nil || resp.Found {
+		t.Errorf("Get after Delete = %v, %v, want a miss", resp, err)
+	}
+
+	// Other keys of the same code are kept
+	s.Set(ctx, &proto.SetRequest{Namespace: "count", Key: "abc123", Value: "1"})
+	s.Delete(ctx, &proto.DeleteRequest{Namespace: "url", Key: "abc123"})
+	if resp, err := s.Exists(ctx, &proto.ExistsRequest{Namespace: "count", Key: "abc123"}); err != nil || !resp.Exists {
+		t.Errorf("Exists of another key = %v, %v, want true", resp, err)
+	}
+}
+
+func TestConcurrentDeletes(t *testing.T) {
+	s := newTestCacheServer(t)
+	ctx := context.Background()
+	if _, err := s.Set(ctx, &proto.SetRequest{Namespace: "url", Key: "abc123", Value: "https://example.com"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Exactly one of the racing deletes finds the key
+	var existed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := s.Delete(ctx, &proto.DeleteRequest{Namespace: "url", Key: "abc123"})
+			if err != nil {
+				t.Errorf("Delete: %v", err)
+				return
+			}
+			if resp.Existed {
+				existed.Add(1)
+			}
+			s.Exists(ctx, &proto.ExistsRequest{Namespace: "url", Key: "abc123"})
+		}()
+	}
+	wg.Wait()
+	if n := existed.Load(); n != 1 {
+		t.Errorf("%d deletes found the key, want 1", n)
+	}
+}
+
+func TestSetDefaultTTL(t *testing.T) {
+	s := newTestCacheServer(t)
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		key  string
+		ttl  int32
+		want time.Duration
+	}{
+		{"forever", 0, defaultTTL},
+		{"short", 60, time.Minute},
+	} {
+		if _, err := s.Set(ctx, &proto.SetRequest{Namespace: "url", Key: tt.key, Value: "https://example.com", TtlSeconds: tt.ttl}); err != nil {
+			t.Fatalf("Set %s: %v", tt.key, err)
+		}
+		if got := expiresIn(t, s, tt.key); got > tt.want || got < tt.want-time.Second {
+			t.Errorf("%s expires in %v, want %v", tt.key, got, tt.want)
+		}
+	}
+
+	if _, err := s.Set(ctx, &proto.SetRequest{Namespace: "url", Key: "negative", Value: "https://example.com", TtlSeconds: -1}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Set with a negative TTL: got %v, want InvalidArgument", err)
+	}
+}
+
+func TestSetMaxValueBytes(t *testing.T) {
+	s := newTestCacheServer(t)
+	s.maxValueBytes = 16
+	ctx := context.Background()
+
+	if _, err := s.Set(ctx, &proto.SetRequest{Namespace: "url", Key: "fits", Value: strings.Repeat("a", 16)}); err != nil {
+		t.Errorf("Set at the limit: %v", err)
+	}
+	if _, err := s.Set(ctx, &proto.SetRequest{Namespace: "url", Key: "big", Value: strings.Repeat("a", 17)}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Set over the limit: got %v, want InvalidArgument", err)
+	}
+	if resp, err := s.Exists(ctx, &proto.ExistsRequest{Namespace: "url", Key: "big"}); err != nil || resp.Exists {
+		t.Errorf("Exists of the rejected value = %v, %v, want false", resp, err)
+	}
+}
+
+func TestMSetAndMGet(t *testing.T) {
+	s := newTestCacheServer(t)
+	ctx := context.Background()
+	_, err := s.MSet(ctx, &proto.MSetRequest{Entries: []*proto.SetRequest{
+		{Namespace: "url", Key: "a", Value: "https://example.com/a", TtlSeconds: 60},
+		{Namespace: "url", Key: "b", Value: "https://example.com/b", TtlSeconds: 3600},
+		{Namespace: "url", Key: "c", Value: "https://example.com/c"},
+	}})
+	if err != nil {
+		t.Fatalf("MSet: %v", err)
+	}
+
+	// Each entry keeps its own TTL, or the default without one
+	for key, ttl := range map[string]time.Duration{"a": time.Minute, "b": time.Hour, "c": defaultTTL} {
+		if got := expiresIn(t, s, key); got > ttl || got < ttl-time.Second {
+			t.Errorf("%s expires in %v, want %v", key, got, ttl)
+		}
+	}
+
+	// Partial hits keep their positions
+	resp, err := s.MGet(ctx, &proto.MGetRequest{Namespace: "url", Keys: []string{"b", "missing", "a", "b"}})
+	if err != nil {
+		t.Fatalf("MGet: %v", err)
+	}
+	want := []string{"https://example.com/b", "", "https://example.com/a", "https://example.com/b"}
+	for i, v := range resp.Values {
+		if v.Found != (want[i] != "") || v.Value != want[i] {
+			t.Errorf("value %d = %v, want %q", i, v, want[i])
+		}
+	}
+}
+
+func TestBatchLimits(t *testing.T) {
+	s := newTestCacheServer(t)
+	s.maxBatchSize = 2
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"MGet at the limit", func() error {
+			_, err := s.MGet(ctx, &proto.MGetRequest{Keys: []string{"a", "b"}})
+			return err
+		}, codes.OK},
+		{"oversized MGet", func() error {
+			_, err := s.MGet(ctx, &proto.MGetRequest{Keys: []string{"a", "b", "c"}})
+			return err
+		}, codes.InvalidArgument},
+		{"oversized MSet", func() error {
+			_, err := s.MSet(ctx, &proto.MSetRequest{Entries: []*proto.SetRequest{{Key: "a"}, {Key: "b"}, {Key: "c"}}})
+			return err
+		}, codes.InvalidArgument},
+		{"MSet with a negative TTL", func() error {
+			_, err := s.MSet(ctx, &proto.MSetRequest{Entries: []*proto.SetRequest{{Key: "a", Value: "1"}, {Key: "b", Value: "2", TtlSeconds: -1}}})
+			return err
+		}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		if err := tt.call(); status.Code(err) != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// A rejected batch writes nothing
+	if resp, err := s.Exists(ctx, &proto.ExistsRequest{Key: "a"}); err != nil || resp.Exists {
+		t.Errorf("Exists after rejected batches = %v, %v, want false", resp, err)
+	}
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultMemoryMaxBytes matches the maxmemory given to Redis in compose.
+const defaultMemoryMaxBytes = 256 << 20
+
+// defaultSweepInterval is how often expired entries that are never read
+// again are reclaimed, and defaultSweepBatch how many entries the sweep
+// looks at before letting requests in again.
+const (
+	defaultSweepInterval = 10 * time.Second
+	defaultSweepBatch    = 1000
+)
+
+// memoryEntryOverhead approximates the bookkeeping cost of one entry on top
+// of its key and value.
+const memoryEntryOverhead = 64
+
+// memoryConfig sizes the memory backend.
+type memoryConfig struct {
+	maxBytes      int64
+	maxEntries    int // 0 bounds the store by maxBytes alone
+	sweepInterval time.Duration
+	sweepBatch    int
+}
+
+// describe sums up the limits for the startup log.
+func (c memoryConfig) describe() string {
+	if c.maxEntries == 0 {
+		return fmt.Sprintf("max %d bytes", c.maxBytes)
+	}
+	return fmt.Sprintf("max %d bytes or %d entries", c.maxBytes, c.maxEntries)
+}
+
+// memoryStore keeps entries in a map in this process. Expired entries are
+// dropped when read and by a periodic sweep, and once maxBytes or maxEntries
+// is reached the least recently used entries are evicted, mirroring Redis'
+// allkeys-lru.
+type memoryStore struct {
+	cfg memoryConfig
+
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	lru     lruList
+	bytes   int64
+	evicted int64
+	expired int64 // Found expired when read
+	swept   int64 // Removed by the sweep
+
+	snapshot snapshotConfig // Zero unless snapshots are enabled
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+type memoryEntry struct {
+	key, value string
+	expiresAt  time.Time
+	prev, next *memoryEntry // Neighbours in lru
+}
+
+func (e *memoryEntry) size() int64 {
+	return int64(len(e.key) + len(e.value) + memoryEntryOverhead)
+}
+
+func newMemoryStore(cfg memoryConfig) *memoryStore {
+	m := &memoryStore{
+		cfg:     cfg,
+		entries: make(map[string]*memoryEntry),
+		stop:    make(chan struct{}),
+	}
+	m.lru.init()
+	m.wg.Add(1)
+	go m.sweep()
+	return m
+}
+
+func (m *memoryStore) Get(ctx context.Context, key string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.lookup(key, time.Now())
+	if !ok {
+		return "", false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (m *memoryStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(key, value, time.Now().Add(ttl))
+	return nil
+}
+
+func (m *memoryStore) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := m.lookup(key, now); ok {
+		return false, nil
+	}
+	m.set(key, value, now.Add(ttl))
+	return true, nil
+}
+
+func (m *memoryStore) Delete(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.lookup(key, time.Now())
+	if ok {
+		m.remove(m.entries[key])
+	}
+	return ok, nil
+}
+
+func (m *memoryStore) Exists(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.lookup(key, time.Now())
+	return ok, nil
+}
+
+func (m *memoryStore) MGet(ctx context.Context, keys []string) ([]storeValue, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	values := make([]storeValue, len(keys))
+	for i, key := range keys {
+		if entry, ok := m.lookup(key, now); ok {
+			values[i] = storeValue{value: entry.value, found: true}
+		}
+	}
+	return values, nil
+}
+
+func (m *memoryStore) MSet(ctx context.Context, entries []storeEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, entry := range entries {
+		m.set(entry.key, entry.value, now.Add(entry.ttl))
+	}
+	return nil
+}
+
+func (m *memoryStore) Info(ctx context.Context) (storeInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return storeInfo{
+		evicted:     m.evicted,
+		expired:     m.expired,
+		swept:       m.swept,
+		entries:     int64(len(m.entries)),
+		memoryBytes: m.bytes,
+	}, nil
+}
+
+func (m *memoryStore) NamespaceEntries(ctx context.Context) (map[string]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	counts := make(map[string]int64)
+	for key, entry := range m.entries {
+		if !now.Before(entry.expiresAt) {
+			continue
+		}
+		if namespace, _, ok := strings.Cut(key, namespaceSeparator); ok {
+			counts[namespace]++
+		}
+	}
+	return counts, nil
+}
+
+func (m *memoryStore) DeletePrefix(ctx context.Context, prefix string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var deleted int64
+	for key, entry := range m.entries {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		m.remove(entry)
+		if now.Before(entry.expiresAt) {
+			deleted++
+		} else {
+			m.expired++
+		}
+	}
+	return deleted, nil
+}
+
+// Close stops the expiry sweep and, when snapshots are enabled, writes a
+// final one.
+func (m *memoryStore) Close() error {
+	var err error
+	m.once.Do(func() {
+		close(m.stop)
+		m.wg.Wait()
+		if m.snapshot.path != "" {
+			err = m.writeSnapshot()
+		}
+	})
+	return err
+}
+
+// lookup returns the live entry for key, dropping it if it has expired.
+// Callers hold mu.
+func (m *memoryStore) lookup(key string, now time.Time) (*memoryEntry, bool) {
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(entry.expiresAt) {
+		m.remove(entry)
+		m.expired++
+		return nil, false
+	}
+	m.lru.moveToFront(entry)
+	return entry, true
+}
+
+// set stores an entry and evicts the least recently used ones until the
+// store fits in maxBytes and maxEntries again. Callers hold mu.
+func (m *memoryStore) set(key, value string, expiresAt time.Time) {
+	if entry, ok := m.entries[key]; ok {
+		// Overwrite in place, sparing the map and the allocator
+		m.bytes += int64(len(value) - len(entry.value))
+		entry.value, entry.expiresAt = value, expiresAt
+		m.lru.moveToFront(entry)
+	} else {
+		entry := &memoryEntry{key: key, value: value, expiresAt: expiresAt}
+		m.entries[key] = entry
+		m.lru.pushFront(entry)
+		m.bytes += entry.size()
+	}
+
+	for m.lru.len > 1 && (m.bytes > m.cfg.maxBytes || m.cfg.maxEntries > 0 && m.lru.len > m.cfg.maxEntries) {
+		m.remove(m.lru.back())
+		m.evicted++
+	}
+}
+
+// remove drops entry from the store. Callers hold mu.
+func (m *memoryStore) remove(entry *memoryEntry) {
+	m.lru.remove(entry)
+	delete(m.entries, entry.key)
+	m.bytes -= entry.size()
+}
+
+func (m *memoryStore) sweep() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.cfg.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+		m.sweepExpired()
+	}
+}
+
+// sweepExpired reclaims expired entries a batch at a time, releasing mu
+// between batches so requests aren't held up for a whole scan. Like Redis'
+// active expiry it samples the map rather than walking all of it: it moves
+// on to another batch only while at least a quarter of the last one had
+// expired, and leaves the rest to later sweeps and to reads.
+func (m *memoryStore) sweepExpired() {
+	for {
+		select {
+		case <-m.stop:
+			return
+		default:
+		}
+		scanned, swept := m.sweepBatch(time.Now())
+		if scanned < m.cfg.sweepBatch || swept*4 < scanned {
+			return
+		}
+	}
+}
+
+// sweepBatch looks at up to sweepBatch entries, starting wherever map
+// iteration does, and removes those that have expired.
+func (m *memoryStore) sweepBatch(now time.Time) (scanned, swept int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, entry := range m.entries {
+		if scanned == m.cfg.sweepBatch {
+			break
+		}
+		scanned++
+		if !now.Before(entry.expiresAt) {
+			m.remove(entry)
+			m.swept++
+			swept++
+		}
+	}
+	return scanned, swept
+}
+
+// lruList orders entries from most to least recently used. It is linked
+// through the entries themselves, unlike container/list, so a lookup reads
+// one allocation instead of a list element and the entry it points to.
+// root is a sentinel: root.next is the front and root.prev the back.
+type lruList struct {
+	root memoryEntry
+	len  int
+}
+
+func (l *lruList) init() {
+	l.root.next, l.root.prev = &l.root, &l.root
+}
+
+// front returns the most recently used entry, or nil if there is none.
+func (l *lruList) front() *memoryEntry {
+	return l.after(&l.root)
+}
+
+// back returns the least recently used entry, or nil if there is none.
+func (l *lruList) back() *memoryEntry {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.prev
+}
+
+// after returns the entry used less recently than e, or nil if there is none.
+func (l *lruList) after(e *memoryEntry) *memoryEntry {
+	if e.next == &l.root {
+		return nil
+	}
+	return e.next
+}
+
+func (l *lruList) pushFront(e *memoryEntry) {
+	e.prev, e.next = &l.root, l.root.next
+	e.next.prev, l.root.next = e, e
+	l.len++
+}
+
+func (l *lruList) remove(e *memoryEntry) {
+	e.prev.next, e.next.prev = e.next, e.prev
+	e.prev, e.next = nil, nil
+	l.len--
+}
+
+func (l *lruList) moveToFront(e *memoryEntry) {
+	if l.root.next == e {
+		return
+	}
+	l.remove(e)
+	l.pushFront(e)
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestMemoryStore returns a memory store of cfg, with defaults for the
+// settings cfg leaves out.
+func newTestMemoryStore(t *testing.T, cfg memoryConfig) *memoryStore {
+	t.Helper()
+	if cfg.maxBytes == 0 {
+		cfg.maxBytes = defaultMemoryMaxBytes
+	}
+	if cfg.sweepInterval == 0 {
+		cfg.sweepInterval = time.Hour
+	}
+	if cfg.sweepBatch == 0 {
+		cfg.sweepBatch = defaultSweepBatch
+	}
+	m := newMemoryStore(cfg)
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func TestMemoryStoreExpiresOnGet(t *testing.T) {
+	m := newTestMemoryStore(t, memoryConfig{})
+	ctx := context.Background()
+
+	if err := m.Set(ctx, "short", "lived", 20*time.Millisecond); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, found, _ := m.Get(ctx, "short"); !found {
+		t.Fatal("Get before the TTL: not found")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, found, _ := m.Get(ctx, "short"); found {
+		t.Fatal("Get after the TTL: found")
+	}
+
+	info, _ := m.Info(ctx)
+	if info.expired != 1 || info.swept != 0 || info.entries != 0 || info.memoryBytes != 0 {
+		t.Errorf("Info = %+v, want 1 expired and nothing left", info)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	m := newTestMemoryStore(t, memoryConfig{sweepInterval: 10 * time.Millisecond, sweepBatch: 7})
+	ctx := context.Background()
+
+	for i := 0; i < 100; i++ {
+		m.Set(ctx, fmt.Sprintf("short%d", i), "lived", 20*time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		m.Set(ctx, fmt.Sprintf("long%d", i), "lived", time.Hour)
+	}
+
+	// Nothing reads the short-lived keys again, so only the sweep removes them
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, _ := m.Info(ctx)
+		if info.entries == 10 {
+			if info.swept != 100 || info.expired != 0 {
+				t.Errorf("Info = %+v, want 100 swept and none expired on read", info)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Info = %+v after 5s, want 10 entries left", info)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		if _, found, _ := m.Get(ctx, fmt.Sprintf("long%d", i)); !found {
+			t.Errorf("long%d was swept", i)
+		}
+	}
+}
+
+func TestMemoryStoreSweepBatch(t *testing.T) {
+	m := newTestMemoryStore(t, memoryConfig{sweepBatch: 10})
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		m.Set(ctx, fmt.Sprintf("key%d", i), "value", time.Millisecond)
+	}
+	later := time.Now().Add(time.Second)
+
+	// One batch looks at no more than sweepBatch entries
+	if scanned, swept := m.sweepBatch(later); scanned != 10 || swept != 10 {
+		t.Errorf("sweepBatch scanned %d and swept %d, want 10 and 10", scanned, swept)
+	}
+	if info, _ := m.Info(ctx); info.entries != 90 {
+		t.Errorf("%d entries left after a batch, want 90", info.entries)
+	}
+
+	// A sweep goes on while batches find expired entries
+	time.Sleep(2 * time.Millisecond)
+	m.sweepExpired()
+	if info, _ := m.Info(ctx); info.entries != 0 || info.swept != 100 {
+		t.Errorf("Info = %+v after a sweep, want all 100 swept", info)
+	}
+}
+
+func TestMemoryStoreEvictsOverMaxEntries(t *testing.T) {
+	m := newTestMemoryStore(t, memoryConfig{maxEntries: 3})
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b", "c"} {
+		m.Set(ctx, key, "value", time.Hour)
+	}
+	// Reading a makes b the least recently used
+	m.Get(ctx, "a")
+	m.Set(ctx, "d", "value", time.Hour)
+	m.MSet(ctx, []storeEntry{{key: "e", value: "value", ttl: time.Hour}, {key: "a", value: "new", ttl: time.Hour}})
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": false, "d": true, "e": true} {
+		if _, found, _ := m.Get(ctx, key); found != want {
+			t.Errorf("%s found %v, want %v", key, found, want)
+		}
+	}
+	info, _ := m.Info(ctx)
+	if info.entries != 3 || info.evicted != 2 {
+		t.Errorf("Info = %+v, want 3 entries and 2 evicted", info)
+	}
+}
+
+func TestMemoryStoreEvictsOverMaxBytes(t *testing.T) {
+	value := string(make([]byte, 100))
+	entrySize := (&memoryEntry{key: "k0", value: value}).size()
+	m := newTestMemoryStore(t, memoryConfig{maxBytes: 4 * entrySize})
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		m.Set(ctx, "k"+strconv.Itoa(i), value, time.Hour)
+	}
+	info, _ := m.Info(ctx)
+	if info.entries != 4 || info.evicted != 6 || info.memoryBytes > 4*entrySize {
+		t.Errorf("Info = %+v, want 4 entries of %d bytes and 6 evicted", info, entrySize)
+	}
+	if _, found, _ := m.Get(ctx, "k9"); !found {
+		t.Error("the newest entry was evicted")
+	}
+}
+
+func TestMemoryStoreSnapshotKeepsMaxEntries(t *testing.T) {
+	cfg := snapshotConfig{path: filepath.Join(t.TempDir(), "cache.snapshot"), interval: time.Hour, maxBytes: defaultMemoryMaxBytes}
+	ctx := context.Background()
+
+	before := newTestMemoryStore(t, memoryConfig{})
+	before.enableSnapshots(cfg)
+	for i := 0; i < 5; i++ {
+		before.Set(ctx, "k"+strconv.Itoa(i), "value", time.Hour)
+	}
+	before.Get(ctx, "k0")
+	if err := before.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// A smaller store keeps the most recently used entries
+	after := newTestMemoryStore(t, memoryConfig{maxEntries: 3})
+	after.enableSnapshots(cfg)
+	for key, want := range map[string]bool{"k0": true, "k4": true, "k3": true, "k2": false, "k1": false} {
+		if _, found, _ := after.Get(ctx, key); found != want {
+			t.Errorf("%s found %v, want %v", key, found, want)
+		}
+	}
+}
+
+func TestMemoryConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name, key, value string
+	}{
+		{"zero interval", "CACHE_SWEEP_INTERVAL", "0s"},
+		{"interval without unit", "CACHE_SWEEP_INTERVAL", "10"},
+		{"zero batch", "CACHE_SWEEP_BATCH", "0"},
+		{"batch not a number", "CACHE_SWEEP_BATCH", "many"},
+		{"negative memory", "CACHE_MAX_MEMORY_BYTES", "-1"},
+		{"negative entries", "CACHE_MAX_ENTRIES", "-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			if _, err := memoryConfigFromEnv(); err == nil {
+				t.Errorf("%s=%s accepted", tt.key, tt.value)
+			}
+		})
+	}
+
+	t.Setenv("CACHE_SWEEP_INTERVAL", "250ms")
+	t.Setenv("CACHE_SWEEP_BATCH", "50")
+	t.Setenv("CACHE_MAX_ENTRIES", "1000")
+	cfg, err := memoryConfigFromEnv()
+	if err != nil {
+		t.Fatalf("memoryConfigFromEnv: %v", err)
+	}
+	if cfg.sweepInterval != 250*time.Millisecond || cfg.sweepBatch != 50 || cfg.maxEntries != 1000 || cfg.maxBytes != defaultMemoryMaxBytes {
+		t.Errorf("memoryConfigFromEnv = %+v", cfg)
+	}
+}
+
+// unboundedMap is the store without limits or LRU bookkeeping, the baseline
+// for the benchmarks below.
+type unboundedMap struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+func (u *unboundedMap) Get(ctx context.Context, key string) (string, bool, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	entry, ok := u.entries[key]
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		return "", false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (u *unboundedMap) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.entries[key] = &memoryEntry{key: key, value: value, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+type getSetter interface {
+	Get(ctx context.Context, key string) (string, bool, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+}
+
+// benchmarkGetSet runs a read-heavy mix, nine Gets to a Set, over working
+// sets of growing size. Every key is stored and fits in the bounded store, so
+// the difference to the unbounded map is the LRU bookkeeping. Once the
+// entries no longer fit in CPU caches, moving one to the front of the list
+// touches its neighbours too, which shows in the larger sizes.
+func benchmarkGetSet(b *testing.B, newStore func(keys int) (getSetter, func())) {
+	for _, keys := range []int{1000, 10000, 100000} {
+		b.Run(strconv.Itoa(keys), func(b *testing.B) {
+			st, closeStore := newStore(keys)
+			defer closeStore()
+
+			names := make([]string, keys)
+			ctx := context.Background()
+			for i := range names {
+				names[i] = "urls:" + strconv.Itoa(i)
+				st.Set(ctx, names[i], "https://example.com/some/long/path", time.Hour)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				rng := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+				for pb.Next() {
+					key := names[rng.IntN(keys)]
+					if rng.IntN(10) == 0 {
+						st.Set(ctx, key, "https://example.com/some/long/path", time.Hour)
+					} else {
+						st.Get(ctx, key)
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkMemoryStoreGetSet(b *testing.B) {
+	benchmarkGetSet(b, func(keys int) (getSetter, func()) {
+		m := newMemoryStore(memoryConfig{maxBytes: defaultMemoryMaxBytes, maxEntries: keys, sweepInterval: time.Hour, sweepBatch: defaultSweepBatch})
+		return m, func() { m.Close() }
+	})
+}
+
+func BenchmarkUnboundedMapGetSet(b *testing.B) {
+	benchmarkGetSet(b, func(keys int) (getSetter, func()) {
+		return &unboundedMap{entries: make(map[string]*memoryEntry)}, func() {}
+	})
+}
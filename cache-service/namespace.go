@@ -0,0 +1,45 @@
+package main
+
+import (
+	"regexp"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultNamespace applies to requests without a namespace, which predate
+// namespaces and all stored URL mappings.
+const defaultNamespace = "url"
+
+// namespaceSeparator joins a namespace and a key into the stored key, so
+// "url" and "abc123" are stored as "url:abc123".
+const namespaceSeparator = ":"
+
+// Namespaces are short identifiers so they can't contain the separator or
+// the glob characters used to scan a namespace in Redis.
+var namespacePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// storeKey returns the stored key for key in namespace.
+func storeKey(namespace, key string) (string, error) {
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	if !namespacePattern.MatchString(namespace) {
+		return "", status.Errorf(codes.InvalidArgument, "invalid namespace %q, want 1-32 of a-z, 0-9, _ and -", namespace)
+	}
+	return namespace + namespaceSeparator + key, nil
+}
+
+// storeKeys returns the stored keys for keys in namespace.
+func storeKeys(namespace string, keys []string) ([]string, error) {
+	prefix, err := storeKey(namespace, "")
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make([]string, len(keys))
+	for i, key := range keys {
+		stored[i] = prefix + key
+	}
+	return stored, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	proto "github.com/syedalijabir/protos/cache-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNamespaceIsolation(t *testing.T) {
+	s := newTestCacheServer(t)
+	ctx := context.Background()
+	for _, e := range []*proto.SetRequest{
+		{Key: "abc123", Value: "https://example.com"}, // The default namespace
+		{Namespace: "notfound", Key: "abc123", Value: "1"},
+		{Namespace: "count", Key: "abc123", Value: "7"},
+		{Namespace: "count", Key: "xyz789", Value: "2"},
+	} {
+		if _, err := s.Set(ctx, e); err != nil {
+			t.Fatalf("Set %s/%s: %v", e.Namespace, e.Key, err)
+		}
+	}
+
+	// The same key holds a value per namespace, and "url" is the default
+	for namespace, want := range map[string]string{"": "https://example.com", "url": "https://example.com", "notfound": "1", "count": "7", "other": ""} {
+		resp, err := s.Get(ctx, &proto.GetRequest{Namespace: namespace, Key: "abc123"})
+		if err != nil || resp.Value != want || resp.Found != (want != "") {
+			t.Errorf("Get in %q = %v, %v, want %q", namespace, resp, err, want)
+		}
+	}
+	s.Delete(ctx, &proto.DeleteRequest{Namespace: "notfound", Key: "abc123"})
+	if resp, _ := s.Get(ctx, &proto.GetRequest{Key: "abc123"}); !resp.Found {
+		t.Error("deleting the sentinel removed the URL mapping")
+	}
+
+	stats, err := s.GetCacheStats(ctx, &proto.GetCacheStatsRequest{})
+	if err != nil {
+		t.Fatalf("GetCacheStats: %v", err)
+	}
+	if ns := stats.NamespaceEntries; len(ns) != 2 || ns["url"] != 1 || ns["count"] != 2 {
+		t.Errorf("namespace entries %v, want url 1 and count 2", ns)
+	}
+
+	// Flushing a namespace leaves the others alone
+	flushed, err := s.FlushNamespace(ctx, &proto.FlushNamespaceRequest{Namespace: "count"})
+	if err != nil || flushed.Deleted != 2 {
+		t.Errorf("FlushNamespace = %v, %v, want 2 deleted", flushed, err)
+	}
+	if resp, _ := s.Get(ctx, &proto.GetRequest{Namespace: "url", Key: "abc123"}); !resp.Found {
+		t.Error("flushing count removed the URL mapping")
+	}
+	if resp, _ := s.Get(ctx, &proto.GetRequest{Namespace: "count", Key: "xyz789"}); resp.Found {
+		t.Error("count entry survived the flush")
+	}
+}
+
+func TestInvalidNamespace(t *testing.T) {
+	s := newTestCacheServer(t)
+	ctx := context.Background()
+	for _, namespace := range []string{"url:extra", "URL", "a*", "this-namespace-is-longer-than-32-chars"} {
+		if _, err := s.Set(ctx, &proto.SetRequest{Namespace: namespace, Key: "k", Value: "v"}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Set in %q: got %v, want InvalidArgument", namespace, err)
+		}
+		if _, err := s.FlushNamespace(ctx, &proto.FlushNamespaceRequest{Namespace: namespace}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("FlushNamespace(%q): got %v, want InvalidArgument", namespace, err)
+		}
+	}
+	if _, err := s.FlushNamespace(ctx, &proto.FlushNamespaceRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("FlushNamespace without a namespace: got %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisStore keeps entries in Redis. TTLs are Redis expirations, so keys are
+// reclaimed on access and by Redis' background sweep, and maxmemory evicts
+// the least recently used keys.
+type redisStore struct {
+	rdb *redis.Client
+}
+
+func newRedisStore(ctx context.Context, addr, password string, db int) (*redisStore, error) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		rdb.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	return &redisStore{rdb: rdb}, nil
+}
+
+func (r *redisStore) Get(ctx context.Context, key string) (string, bool, error) {
+	val, err := r.rdb.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	return val, true, nil
+}
+
+func (r *redisStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return r.rdb.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *redisStore) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return r.rdb.SetNX(ctx, key, value, ttl).Result()
+}
+
+func (r *redisStore) Delete(ctx context.Context, key string) (bool, error) {
+	deleted, err := r.rdb.Del(ctx, key).Result()
+	return deleted > 0, err
+}
+
+func (r *redisStore) Exists(ctx context.Context, key string) (bool, error) {
+	n, err := r.rdb.Exists(ctx, key).Result()
+	return n > 0, err
+}
+
+func (r *redisStore) MGet(ctx context.Context, keys []string) ([]storeValue, error) {
+	vals, err := r.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]storeValue, len(vals))
+	for i, val := range vals {
+		// Missing keys come back as nil
+		str, ok := val.(string)
+		values[i] = storeValue{value: str, found: ok}
+	}
+	return values, nil
+}
+
+// MSet writes the entries in a MULTI/EXEC transaction.
+func (r *redisStore) MSet(ctx context.Context, entries []storeEntry) error {
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, entry := range entries {
+			pipe.Set(ctx, entry.key, entry.value, entry.ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// Info reads Redis' own eviction, expiry, size and memory figures.
+func (r *redisStore) Info(ctx context.Context) (storeInfo, error) {
+	raw, err := r.rdb.Info(ctx, "stats", "memory").Result()
+	if err != nil {
+		return storeInfo{}, fmt.Errorf("redis INFO: %w", err)
+	}
+	entries, err := r.rdb.DBSize(ctx).Result()
+	if err != nil {
+		return storeInfo{}, fmt.Errorf("redis DBSIZE: %w", err)
+	}
+
+	fields := make(map[string]int64)
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			fields[key] = n
+		}
+	}
+
+	return storeInfo{
+		evicted:     fields["evicted_keys"],
+		expired:     fields["expired_keys"],
+		entries:     entries,
+		memoryBytes: fields["used_memory"],
+	}, nil
+}
+
+// redisScanCount is the SCAN batch size when walking the keyspace.
+const redisScanCount = 1000
+
+// NamespaceEntries walks the keyspace with SCAN, so it doesn't block Redis
+// but costs a round trip per thousand keys.
+func (r *redisStore) NamespaceEntries(ctx context.Context) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	iter := r.rdb.Scan(ctx, 0, "*", redisScanCount).Iterator()
+	for iter.Next(ctx) {
+		if namespace, _, ok := strings.Cut(iter.Val(), namespaceSeparator); ok {
+			counts[namespace]++
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("redis SCAN: %w", err)
+	}
+	return counts, nil
+}
+
+// DeletePrefix unlinks matching keys a SCAN batch at a time. Keys written
+// during the walk may survive it.
+func (r *redisStore) DeletePrefix(ctx context.Context, prefix string) (int64, error) {
+	var deleted int64
+	var cursor uint64
+	for {
+		keys, next, err := r.rdb.Scan(ctx, cursor, prefix+"*", redisScanCount).Result()
+		if err != nil {
+			return deleted, fmt.Errorf("redis SCAN: %w", err)
+		}
+		if len(keys) > 0 {
+			n, err := r.rdb.Unlink(ctx, keys...).Result()
+			deleted += n
+			if err != nil {
+				return deleted, fmt.Errorf("redis UNLINK: %w", err)
+			}
+		}
+		if next == 0 {
+			return deleted, nil
+		}
+		cursor = next
+	}
+}
+
+func (r *redisStore) Close() error {
+	return r.rdb.Close()
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultSnapshotInterval = 5 * time.Minute
+
+// snapshotVersion is bumped whenever snapshotEntry changes incompatibly.
+const snapshotVersion = 1
+
+// snapshotConfig controls periodic snapshots of the in-memory store, so a
+// restarted replica starts warm instead of sending every lookup to storage.
+// Redis persists itself and ignores it.
+type snapshotConfig struct {
+	path     string
+	interval time.Duration
+	maxBytes int64 // Entries beyond this, least recently used first, are left out
+}
+
+// A snapshot file is a gob stream of a snapshotHeader followed by its
+// entries, most recently used first.
+type snapshotHeader struct {
+	Version int
+	Entries int
+	Written time.Time
+}
+
+type snapshotEntry struct {
+	Key       string
+	Value     string
+	ExpiresAt time.Time
+}
+
+// enableSnapshots loads the last snapshot, if any, and starts writing new
+// ones every cfg.interval and when the store is closed. A missing or
+// unreadable snapshot only means starting cold.
+func (m *memoryStore) enableSnapshots(cfg snapshotConfig) {
+	m.snapshot = cfg
+
+	start := time.Now()
+	loaded, expired, err := m.loadSnapshot()
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		log.Printf("No cache snapshot at %s, starting cold", cfg.path)
+	case err != nil:
+		log.Printf("Warning: failed to load cache snapshot, starting cold: %v", err)
+	default:
+		log.Printf("Loaded %d entries from cache snapshot %s in %s (%d already expired)", loaded, cfg.path, time.Since(start), expired)
+	}
+
+	m.wg.Add(1)
+	go m.snapshotLoop()
+}
+
+func (m *memoryStore) snapshotLoop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.snapshot.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			if err := m.writeSnapshot(); err != nil {
+				log.Printf("Warning: failed to write cache snapshot: %v", err)
+			}
+		}
+	}
+}
+
+// writeSnapshot writes the store to a temporary file and renames it over the
+// previous snapshot, so a crash mid-write leaves the old one intact. The lock
+// is only held to copy entries; strings are immutable, so the copy shares
+// their bytes and encoding runs without blocking Get or Set.
+func (m *memoryStore) writeSnapshot() error {
+	start := time.Now()
+	entries := m.snapshotEntries()
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.snapshot.path), filepath.Base(m.snapshot.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op after the rename
+
+	w := bufio.NewWriter(tmp)
+	enc := gob.NewEncoder(w)
+	err = enc.Encode(snapshotHeader{Version: snapshotVersion, Entries: len(entries), Written: start})
+	for i := 0; err == nil && i < len(entries); i++ {
+		err = enc.Encode(&entries[i])
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), m.snapshot.path); err != nil {
+		return err
+	}
+
+	log.Printf("Wrote cache snapshot of %d entries to %s in %s", len(entries), m.snapshot.path, time.Since(start))
+	return nil
+}
+
+// snapshotEntries copies the live entries, most recently used first, up to
+// the snapshot size limit.
+func (m *memoryStore) snapshotEntries() []snapshotEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]snapshotEntry, 0, m.lru.len)
+	var size int64
+	for entry := m.lru.front(); entry != nil; entry = m.lru.after(entry) {
+		if !now.Before(entry.expiresAt) {
+			continue
+		}
+		if size += entry.size(); size > m.snapshot.maxBytes {
+			break
+		}
+		entries = append(entries, snapshotEntry{Key: entry.key, Value: entry.value, ExpiresAt: entry.expiresAt})
+	}
+	return entries
+}
+
+// loadSnapshot fills the store from the snapshot file, skipping entries that
+// expired while the service was down and any that don't fit in maxBytes or
+// maxEntries.
+func (m *memoryStore) loadSnapshot() (loaded, expired int, err error) {
+	f, err := os.Open(m.snapshot.path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	dec := gob.NewDecoder(bufio.NewReader(f))
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return 0, 0, fmt.Errorf("read %s: %w", m.snapshot.path, err)
+	}
+	if header.Version != snapshotVersion {
+		return 0, 0, fmt.Errorf("%s has version %d, want %d", m.snapshot.path, header.Version, snapshotVersion)
+	}
+
+	now := time.Now()
+	var entries []snapshotEntry
+	var size int64
+	for i := 0; i < header.Entries; i++ {
+		var entry snapshotEntry
+		if err := dec.Decode(&entry); err != nil {
+			return 0, 0, fmt.Errorf("read %s: entry %d: %w", m.snapshot.path, i, err)
+		}
+		if !now.Before(entry.ExpiresAt) {
+			expired++
+			continue
+		}
+		e := memoryEntry{key: entry.Key, value: entry.Value}
+		if size += e.size(); size > m.cfg.maxBytes || m.cfg.maxEntries > 0 && len(entries) == m.cfg.maxEntries {
+			break
+		}
+		entries = append(entries, entry)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Oldest first, so the most recently used entry ends up at the front
+	for i := len(entries) - 1; i >= 0; i-- {
+		m.set(entries[i].Key, entries[i].Value, entries[i].ExpiresAt)
+	}
+	return len(entries), expired, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	proto "github.com/syedalijabir/protos/cache-service"
+)
+
+// newTestSnapshotConfig returns a snapshot config in a fresh directory that
+// only writes when asked to.
+func newTestSnapshotConfig(t *testing.T) snapshotConfig {
+	t.Helper()
+	return snapshotConfig{path: filepath.Join(t.TempDir(), "cache.snapshot"), interval: time.Hour, maxBytes: defaultMemoryMaxBytes}
+}
+
+func TestSnapshotWarmRestart(t *testing.T) {
+	cfg := newTestSnapshotConfig(t)
+	ctx := context.Background()
+
+	before := newTestCacheServer(t)
+	before.store.(*memoryStore).enableSnapshots(cfg)
+	for _, key := range []string{"a", "b"} {
+		if _, err := before.Set(ctx, &proto.SetRequest{Namespace: "url", Key: key, Value: "https://example.com/" + key, TtlSeconds: 3600}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	before.store.Set(ctx, "url:short", "https://example.com/short", 100*time.Millisecond)
+	if err := before.store.(*memoryStore).writeSnapshot(); err != nil {
+		t.Fatalf("writeSnapshot: %v", err)
+	}
+
+	// Only the snapshot itself is left, the temporary file was renamed
+	files, _ := os.ReadDir(filepath.Dir(cfg.path))
+	if len(files) != 1 || files[0].Name() != filepath.Base(cfg.path) {
+		t.Errorf("snapshot directory holds %v, want only the snapshot", files)
+	}
+
+	// A new instance starts from the file, without what expired meanwhile
+	time.Sleep(150 * time.Millisecond)
+	after := newTestCacheServer(t)
+	after.store.(*memoryStore).enableSnapshots(cfg)
+	resp, err := after.MGet(ctx, &proto.MGetRequest{Namespace: "url", Keys: []string{"a", "b", "short"}})
+	if err != nil {
+		t.Fatalf("MGet: %v", err)
+	}
+	for i, want := range []string{"https://example.com/a", "https://example.com/b", ""} {
+		if v := resp.Values[i]; v.Value != want || v.Found != (want != "") {
+			t.Errorf("value %d after restart = %v, want %q", i, v, want)
+		}
+	}
+
+	// Survivors keep their expiry rather than getting a fresh TTL
+	st := after.store.(*memoryStore)
+	st.mu.Lock()
+	left := time.Until(st.entries["url:a"].expiresAt)
+	st.mu.Unlock()
+	if left > time.Hour-100*time.Millisecond {
+		t.Errorf("a expires in %v after restart, want its original expiry", left)
+	}
+}
+
+func TestSnapshotMaxBytes(t *testing.T) {
+	cfg := newTestSnapshotConfig(t)
+	ctx := context.Background()
+	before := newTestMemoryStore(t, memoryConfig{})
+	for i := 0; i < 10; i++ {
+		before.Set(ctx, "k"+strconv.Itoa(i), "value", time.Hour)
+	}
+	entry := memoryEntry{key: "k0", value: "value"}
+	cfg.maxBytes = 3 * entry.size()
+	before.snapshot = cfg
+	if err := before.writeSnapshot(); err != nil {
+		t.Fatalf("writeSnapshot: %v", err)
+	}
+
+	// The most recently used entries make the cut
+	after := newTestMemoryStore(t, memoryConfig{})
+	after.enableSnapshots(cfg)
+	for key, want := range map[string]bool{"k9": true, "k8": true, "k7": true, "k6": false, "k0": false} {
+		if _, found, _ := after.Get(ctx, key); found != want {
+			t.Errorf("%s found %v, want %v", key, found, want)
+		}
+	}
+}
+
+func TestSnapshotUnreadableStartsCold(t *testing.T) {
+	for name, data := range map[string]string{
+		"garbage":   "not a gob stream",
+		"truncated": "",
+	} {
+		cfg := newTestSnapshotConfig(t)
+		if err := os.WriteFile(cfg.path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		st := newTestMemoryStore(t, memoryConfig{})
+		st.enableSnapshots(cfg)
+		if info, _ := st.Info(context.Background()); info.entries != 0 {
+			t.Errorf("%s: %d entries loaded, want 0", name, info.entries)
+		}
+	}
+}
+
+func TestSnapshotConcurrentWrites(t *testing.T) {
+	cfg := newTestSnapshotConfig(t)
+	ctx := context.Background()
+	st := newTestMemoryStore(t, memoryConfig{})
+	st.snapshot = cfg
+
+	// Gets and Sets keep going while snapshots are written
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := "k" + strconv.Itoa(w*1000+i)
+				st.Set(ctx, key, "value", time.Hour)
+				st.Get(ctx, key)
+			}
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		if err := st.writeSnapshot(); err != nil {
+			t.Errorf("writeSnapshot: %v", err)
+		}
+	}
+	wg.Wait()
+}
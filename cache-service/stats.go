@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	proto "github.com/syedalijabir/protos/cache-service"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Metrics exported on /metrics. Counters here never reset; ResetCacheStats only
+// affects the GetCacheStats view.
+//
+//	cache_service_hits_total              keys found by Get and MGet
+//	cache_service_misses_total            keys not found by Get and MGet
+//	cache_service_sets_total              keys written by Set and MSet
+//	cache_service_deletes_total           Delete calls
+//	cache_service_evicted_keys_total      keys evicted by the store under memory pressure, or over CACHE_MAX_ENTRIES
+//	cache_service_expired_keys_total      keys found expired when read, and with Redis by its sweep too
+//	cache_service_swept_keys_total        keys removed by the memory store's sweep
+//	cache_service_entries                 keys currently stored
+//	cache_service_memory_bytes            memory used by the store
+type cacheStats struct {
+	hits, misses, sets, deletes atomic.Int64
+
+	// Values of the above and of the store's own counters at the last reset
+	mu   sync.Mutex
+	base statsSnapshot
+
+	registry                                        *prometheus.Registry
+	hitsTotal, missesTotal, setsTotal, deletesTotal prometheus.Counter
+}
+
+// statsSnapshot is a point-in-time view of every counter.
+type statsSnapshot struct {
+	hits, misses, sets, deletes int64
+	storeInfo
+}
+
+func newCacheStats(st store) *cacheStats {
+	s := &cacheStats{
+		registry: prometheus.NewRegistry(),
+		hitsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cache_service_hits_total",
+			Help: "Keys found by Get and MGet.",
+		}),
+		missesTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cache_service_misses_total",
+			Help: "Keys not found by Get and MGet.",
+		}),
+		setsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cache_service_sets_total",
+			Help: "Keys written by Set and MSet.",
+		}),
+		deletesTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cache_service_deletes_total",
+			Help: "Delete calls.",
+		}),
+	}
+	s.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		s.hitsTotal,
+		s.missesTotal,
+		s.setsTotal,
+		s.deletesTotal,
+		&storeCollector{st: st},
+	)
+	return s
+}
+
+func (s *cacheStats) hit(n int) {
+	s.hits.Add(int64(n))
+	s.hitsTotal.Add(float64(n))
+}
+
+func (s *cacheStats) miss(n int) {
+	s.misses.Add(int64(n))
+	s.missesTotal.Add(float64(n))
+}
+
+func (s *cacheStats) set(n int) {
+	s.sets.Add(int64(n))
+	s.setsTotal.Add(float64(n))
+}
+
+func (s *cacheStats) delete() {
+	s.deletes.Add(1)
+	s.deletesTotal.Inc()
+}
+
+// Snapshot returns the counters since the last reset, along with the
+// current entry count and memory use.
+func (s *cacheStats) Snapshot(ctx context.Context, st store) (statsSnapshot, error) {
+	current, err := s.current(ctx, st)
+	if err != nil {
+		return statsSnapshot{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return statsSnapshot{
+		hits:    current.hits - s.base.hits,
+		misses:  current.misses - s.base.misses,
+		sets:    current.sets - s.base.sets,
+		deletes: current.deletes - s.base.deletes,
+		storeInfo: storeInfo{
+			evicted:     current.evicted - s.base.evicted,
+			expired:     current.expired - s.base.expired,
+			swept:       current.swept - s.base.swept,
+			entries:     current.entries,
+			memoryBytes: current.memoryBytes,
+		},
+	}, nil
+}
+
+// Reset starts the counters reported by Snapshot from zero.
+func (s *cacheStats) Reset(ctx context.Context, st store) error {
+	current, err := s.current(ctx, st)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.base = current
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *cacheStats) current(ctx context.Context, st store) (statsSnapshot, error) {
+	info, err := st.Info(ctx)
+	if err != nil {
+		return statsSnapshot{}, err
+	}
+	return statsSnapshot{
+		hits:      s.hits.Load(),
+		misses:    s.misses.Load(),
+		sets:      s.sets.Load(),
+		deletes:   s.deletes.Load(),
+		storeInfo: info,
+	}, nil
+}
+
+// storeCollector exports the store's own figures, read once per scrape.
+type storeCollector struct {
+	st store
+}
+
+var (
+	evictedDesc = prometheus.NewDesc("cache_service_evicted_keys_total", "Keys evicted by the store under memory pressure, or over its entry limit.", nil, nil)
+	expiredDesc = prometheus.NewDesc("cache_service_expired_keys_total", "Keys found expired when read, and with Redis by its background sweep too.", nil, nil)
+	sweptDesc   = prometheus.NewDesc("cache_service_swept_keys_total", "Keys removed by the memory store's background sweep.", nil, nil)
+	entriesDesc = prometheus.NewDesc("cache_service_entries", "Keys currently stored.", nil, nil)
+	memoryDesc  = prometheus.NewDesc("cache_service_memory_bytes", "Memory used by the store.", nil, nil)
+)
+
+func (c *storeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- evictedDesc
+	ch <- expiredDesc
+	ch <- sweptDesc
+	ch <- entriesDesc
+	ch <- memoryDesc
+}
+
+func (c *storeCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	info, err := c.st.Info(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to collect store metrics: %v", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(evictedDesc, prometheus.CounterValue, float64(info.evicted))
+	ch <- prometheus.MustNewConstMetric(expiredDesc, prometheus.CounterValue, float64(info.expired))
+	ch <- prometheus.MustNewConstMetric(sweptDesc, prometheus.CounterValue, float64(info.swept))
+	ch <- prometheus.MustNewConstMetric(entriesDesc, prometheus.GaugeValue, float64(info.entries))
+	ch <- prometheus.MustNewConstMetric(memoryDesc, prometheus.GaugeValue, float64(info.memoryBytes))
+}
+
+// GetCacheStats reports the counters since start or the last ResetCacheStats
+func (s *cacheServer) GetCacheStats(ctx context.Context, req *proto.GetCacheStatsRequest) (*proto.GetCacheStatsResponse, error) {
+	snap, err := s.stats.Snapshot(ctx, s.store)
+	if err != nil {
+		log.Printf("Store error: %v", err)
+		return nil, err
+	}
+
+	namespaces, err := s.store.NamespaceEntries(ctx)
+	if err != nil {
+		log.Printf("Store error: %v", err)
+		return nil, err
+	}
+
+	var hitRatio float64
+	if reads := snap.hits + snap.misses; reads > 0 {
+		hitRatio = float64(snap.hits) / float64(reads)
+	}
+	return &proto.GetCacheStatsResponse{
+		Hits:             snap.hits,
+		Misses:           snap.misses,
+		Sets:             snap.sets,
+		Deletes:          snap.deletes,
+		Evictions:        snap.evicted,
+		Expired:          snap.expired,
+		Swept:            snap.swept,
+		Entries:          snap.entries,
+		MemoryBytes:      snap.memoryBytes,
+		HitRatio:         hitRatio,
+		NamespaceEntries: namespaces,
+	}, nil
+}
+
+// ResetCacheStats starts the GetCacheStats counters from zero. Prometheus
+// counters are unaffected.
+func (s *cacheServer) ResetCacheStats(ctx context.Context, req *proto.ResetCacheStatsRequest) (*proto.ResetCacheStatsResponse, error) {
+	if err := s.stats.Reset(ctx, s.store); err != nil {
+		log.Printf("Store error: %v", err)
+		return nil, err
+	}
+
+	log.Printf("Cache stats reset")
+	return &proto.ResetCacheStatsResponse{Success: true}, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	proto "github.com/syedalijabir/protos/cache-service"
+)
+
+// counterValue returns the value of the counter name in s's registry.
+func counterValue(t *testing.T, s *cacheStats, name string) float64 {
+	t.Helper()
+	families, err := s.registry.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == name {
+			return family.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	t.Fatalf("metric %s not registered", name)
+	return 0
+}
+
+func TestCacheStatsScriptedSequence(t *testing.T) {
+	s := newTestCacheServer(t)
+	ctx := context.Background()
+	get := func(key string) {
+		s.Get(ctx, &proto.GetRequest{Namespace: "url", Key: key})
+	}
+	set := func(key string) {
+		s.Set(ctx, &proto.SetRequest{Namespace: "url", Key: key, Value: "https://example.com/" + key})
+	}
+
+	set("a")
+	set("b")
+	get("a") // Hit
+	get("x") // Miss
+	get("b") // Hit
+	s.MGet(ctx, &proto.MGetRequest{Namespace: "url", Keys: []string{"a", "c", "z"}})
+	s.MSet(ctx, &proto.MSetRequest{Entries: []*proto.SetRequest{{Namespace: "url", Key: "c", Value: "https://example.com/c"}}})
+	s.Delete(ctx, &proto.DeleteRequest{Namespace: "url", Key: "a"})
+	// A rejected write isn't counted
+	s.Set(ctx, &proto.SetRequest{Namespace: "url", Key: "bad", Value: "https://example.com", TtlSeconds: -1})
+
+	want := map[string]int64{"hits": 3, "misses": 3, "sets": 3, "deletes": 1}
+	got := map[string]int64{
+		"hits":    s.stats.hits.Load(),
+		"misses":  s.stats.misses.Load(),
+		"sets":    s.stats.sets.Load(),
+		"deletes": s.stats.deletes.Load(),
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s = %d, want %d", name, got[name], value)
+		}
+		if metric := counterValue(t, s.stats, "cache_service_"+name+"_total"); metric != float64(value) {
+			t.Errorf("cache_service_%s_total = %v, want %d", name, metric, value)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Cache backends, selected by CACHE_BACKEND:
+//   - redis:  shared by every cache-service replica and survives restarts.
+//   - memory: a map inside this process, for local development without Redis.
+const (
+	backendRedis  = "redis"
+	backendMemory = "memory"
+)
+
+// store is where cache entries live. Keys arrive already namespaced, see
+// storeKey. Implementations must be safe for concurrent use; a zero TTL is
+// never passed, every key expires.
+type store interface {
+	Get(ctx context.Context, key string) (value string, found bool, err error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// SetNX sets the key only if it doesn't exist, atomically, and reports
+	// whether it did.
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	// Delete reports whether the key was present.
+	Delete(ctx context.Context, key string) (bool, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	// MGet returns one result per key, in order.
+	MGet(ctx context.Context, keys []string) ([]storeValue, error)
+	// MSet applies all entries or none, so readers never see half a batch.
+	MSet(ctx context.Context, entries []storeEntry) error
+	Info(ctx context.Context) (storeInfo, error)
+	// NamespaceEntries counts the live keys of every namespace.
+	NamespaceEntries(ctx context.Context) (map[string]int64, error)
+	// DeletePrefix removes every key starting with prefix and returns how
+	// many it removed.
+	DeletePrefix(ctx context.Context, prefix string) (int64, error)
+	Close() error
+}
+
+type storeValue struct {
+	value string
+	found bool
+}
+
+type storeEntry struct {
+	key, value string
+	ttl        time.Duration
+}
+
+// storeInfo is the backend's own view of its size and housekeeping.
+type storeInfo struct {
+	evicted, expired     int64
+	swept                int64 // Counted in expired by backends that don't tell them apart
+	entries, memoryBytes int64
+}
+
+// newStore opens the backend named by CACHE_BACKEND and describes it for
+// the startup log.
+func newStore(ctx context.Context) (store, string, error) {
+	backend := os.Getenv("CACHE_BACKEND")
+	if backend == "" {
+		backend = backendRedis
+	}
+
+	switch backend {
+	case backendRedis:
+		addr := os.Getenv("REDIS_ADDR")
+		if addr == "" {
+			// REDIS_HOST and REDIS_PORT predate REDIS_ADDR
+			host := os.Getenv("REDIS_HOST")
+			if host == "" {
+				host = "localhost"
+			}
+			port := os.Getenv("REDIS_PORT")
+			if port == "" {
+				port = "6379"
+			}
+			addr = host + ":" + port
+		}
+		db := 0
+		if value := os.Getenv("REDIS_DB"); value != "" {
+			var err error
+			db, err = strconv.Atoi(value)
+			if err != nil || db < 0 {
+				return nil, "", fmt.Errorf("invalid REDIS_DB %q, want a non-negative number", value)
+			}
+		}
+
+		if os.Getenv("CACHE_SNAPSHOT_PATH") != "" {
+			log.Printf("Warning: CACHE_SNAPSHOT_PATH only applies to the memory backend, use Redis persistence instead")
+		}
+		if os.Getenv("CACHE_SWEEP_INTERVAL") != "" || os.Getenv("CACHE_SWEEP_BATCH") != "" {
+			log.Printf("Warning: CACHE_SWEEP_INTERVAL and CACHE_SWEEP_BATCH only apply to the memory backend, Redis expires keys on its own")
+		}
+
+		st, err := newRedisStore(ctx, addr, os.Getenv("REDIS_PASSWORD"), db)
+		if err != nil {
+			return nil, "", err
+		}
+		return st, fmt.Sprintf("Redis at %s (db %d)", addr, db), nil
+
+	case backendMemory:
+		cfg, err := memoryConfigFromEnv()
+		if err != nil {
+			return nil, "", err
+		}
+		snapshot, err := snapshotConfigFromEnv(cfg.maxBytes)
+		if err != nil {
+			return nil, "", err
+		}
+
+		st := newMemoryStore(cfg)
+		if snapshot.path == "" {
+			log.Printf("Warning: in-memory cache backend is local to this replica and lost on restart")
+			return st, "in-memory store (" + cfg.describe() + ")", nil
+		}
+		st.enableSnapshots(snapshot)
+		return st, fmt.Sprintf("in-memory store (%s, snapshot to %s every %s)", cfg.describe(), snapshot.path, snapshot.interval), nil
+	}
+
+	return nil, "", fmt.Errorf("invalid CACHE_BACKEND %q, want %s or %s", backend, backendRedis, backendMemory)
+}
+
+// memoryConfigFromEnv reads the memory backend's size and sweep settings.
+// Without CACHE_MAX_ENTRIES only the bytes are bounded.
+func memoryConfigFromEnv() (memoryConfig, error) {
+	cfg := memoryConfig{
+		maxBytes:      defaultMemoryMaxBytes,
+		sweepInterval: defaultSweepInterval,
+		sweepBatch:    defaultSweepBatch,
+	}
+	if value := os.Getenv("CACHE_MAX_MEMORY_BYTES"); value != "" {
+		var err error
+		cfg.maxBytes, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || cfg.maxBytes <= 0 {
+			return cfg, fmt.Errorf("invalid CACHE_MAX_MEMORY_BYTES %q, want a positive number", value)
+		}
+	}
+	if value := os.Getenv("CACHE_MAX_ENTRIES"); value != "" {
+		var err error
+		cfg.maxEntries, err = strconv.Atoi(value)
+		if err != nil || cfg.maxEntries < 0 {
+			return cfg, fmt.Errorf("invalid CACHE_MAX_ENTRIES %q, want 0 (no limit) or a positive number", value)
+		}
+	}
+	if value := os.Getenv("CACHE_SWEEP_INTERVAL"); value != "" {
+		var err error
+		cfg.sweepInterval, err = time.ParseDuration(value)
+		if err != nil || cfg.sweepInterval <= 0 {
+			return cfg, fmt.Errorf("invalid CACHE_SWEEP_INTERVAL %q, want a positive duration", value)
+		}
+	}
+	if value := os.Getenv("CACHE_SWEEP_BATCH"); value != "" {
+		var err error
+		cfg.sweepBatch, err = strconv.Atoi(value)
+		if err != nil || cfg.sweepBatch <= 0 {
+			return cfg, fmt.Errorf("invalid CACHE_SWEEP_BATCH %q, want a positive number", value)
+		}
+	}
+	return cfg, nil
+}
+
+// snapshotConfigFromEnv reads the memory backend's snapshot settings. The
+// snapshot size defaults to the store's own limit.
+func snapshotConfigFromEnv(maxBytes int64) (snapshotConfig, error) {
+	cfg := snapshotConfig{
+		path:     os.Getenv("CACHE_SNAPSHOT_PATH"),
+		interval: defaultSnapshotInterval,
+		maxBytes: maxBytes,
+	}
+	if value := os.Getenv("CACHE_SNAPSHOT_INTERVAL"); value != "" {
+		var err error
+		cfg.interval, err = time.ParseDuration(value)
+		if err != nil || cfg.interval < time.Second {
+			return cfg, fmt.Errorf("invalid CACHE_SNAPSHOT_INTERVAL %q, want a duration of at least 1s", value)
+		}
+	}
+	if value := os.Getenv("CACHE_SNAPSHOT_MAX_BYTES"); value != "" {
+		var err error
+		cfg.maxBytes, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || cfg.maxBytes <= 0 {
+			return cfg, fmt.Errorf("invalid CACHE_SNAPSHOT_MAX_BYTES %q, want a positive number", value)
+		}
+	}
+	return cfg, nil
+}
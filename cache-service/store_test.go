@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// forEachStore runs test against every backend, Redis being an in-process
+// miniredis. Time only passes for miniredis' keys when it is told to, so
+// tests wait with elapse.
+func forEachStore(t *testing.T, test func(t *testing.T, st store, elapse func(time.Duration))) {
+	t.Run(backendMemory, func(t *testing.T) {
+		test(t, newTestMemoryStore(t, memoryConfig{}), time.Sleep)
+	})
+	t.Run(backendRedis, func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		t.Setenv("CACHE_BACKEND", backendRedis)
+		t.Setenv("REDIS_ADDR", mr.Addr())
+		st, _, err := newStore(context.Background())
+		if err != nil {
+			t.Fatalf("newStore: %v", err)
+		}
+		t.Cleanup(func() { st.Close() })
+		test(t, st, mr.FastForward)
+	})
+}
+
+func TestStoreTTLExpiry(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st store, elapse func(time.Duration)) {
+		ctx := context.Background()
+		if err := st.Set(ctx, "short", "1", 100*time.Millisecond); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if err := st.MSet(ctx, []storeEntry{
+			{key: "batch-short", value: "2", ttl: 100 * time.Millisecond},
+			{key: "batch-long", value: "3", ttl: time.Hour},
+		}); err != nil {
+			t.Fatalf("MSet: %v", err)
+		}
+		if _, found, _ := st.Get(ctx, "short"); !found {
+			t.Fatal("short missing before its TTL")
+		}
+
+		elapse(150 * time.Millisecond)
+		for key, want := range map[string]bool{"short": false, "batch-short": false, "batch-long": true} {
+			if _, found, err := st.Get(ctx, key); err != nil || found != want {
+				t.Errorf("Get(%s) after 150ms: found %v, %v, want %v", key, found, err, want)
+			}
+		}
+	})
+}
+
+func TestStoreBatchOps(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st store, elapse func(time.Duration)) {
+		ctx := context.Background()
+		if err := st.MSet(ctx, []storeEntry{
+			{key: "a", value: "1", ttl: time.Hour},
+			{key: "b", value: "2", ttl: time.Hour},
+		}); err != nil {
+			t.Fatalf("MSet: %v", err)
+		}
+
+		vals, err := st.MGet(ctx, []string{"b", "missing", "a"})
+		if err != nil {
+			t.Fatalf("MGet: %v", err)
+		}
+		want := []storeValue{{"2", true}, {"", false}, {"1", true}}
+		for i := range want {
+			if i >= len(vals) || vals[i] != want[i] {
+				t.Errorf("MGet = %v, want %v", vals, want)
+				break
+			}
+		}
+
+		if existed, err := st.Delete(ctx, "a"); err != nil || !existed {
+			t.Errorf("Delete = %v, %v, want true", existed, err)
+		}
+		if existed, err := st.Delete(ctx, "a"); err != nil || existed {
+			t.Errorf("Delete again = %v, %v, want false", existed, err)
+		}
+		if exists, err := st.Exists(ctx, "a"); err != nil || exists {
+			t.Errorf("Exists after Delete = %v, %v, want false", exists, err)
+		}
+
+		st.Set(ctx, "other:b", "3", time.Hour)
+		if n, err := st.DeletePrefix(ctx, "b"); err != nil || n != 1 {
+			t.Errorf("DeletePrefix = %d, %v, want 1", n, err)
+		}
+		if exists, err := st.Exists(ctx, "other:b"); err != nil || !exists {
+			t.Errorf("Exists of a key outside the prefix = %v, %v, want true", exists, err)
+		}
+	})
+}
+
+func TestNewStoreInvalid(t *testing.T) {
+	for _, env := range []map[string]string{
+		{"CACHE_BACKEND": "memcached"},
+		{"CACHE_BACKEND": backendRedis, "REDIS_DB": "-1"},
+	} {
+		for key, value := range env {
+			t.Setenv(key, value)
+		}
+		if st, _, err := newStore(context.Background()); err == nil {
+			st.Close()
+			t.Errorf("newStore with %v succeeded", env)
+		}
+	}
+}
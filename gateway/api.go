@@ -0,0 +1,546 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// JSON API under /api/v1. Unlike the legacy routes, every error uses the
+// same envelope:
+//
+//	{"error": {"code": "NOT_FOUND", "message": "URL not found"}}
+
+type CreateURLRequest struct {
+	OriginalURL    string         `json:"original_url"`
+	CustomAlias    string         `json:"custom_alias,omitempty"`
+	TTLSeconds     int64          `json:"ttl_seconds,omitempty"`
+	MaxClicks      int64          `json:"max_clicks,omitempty"`
+	FallbackURL    string         `json:"fallback_url,omitempty"`
+	NotBefore      string         `json:"not_before,omitempty"`
+	NotAfter       string         `json:"not_after,omitempty"`
+	ComingSoonURL  string         `json:"coming_soon_url,omitempty"`
+	Variants       []Variant      `json:"variants,omitempty"`
+	StickyVariants bool           `json:"sticky_variants,omitempty"`
+	Rules          []RedirectRule `json:"rules,omitempty"`
+	QueryTemplate  string         `json:"query_template,omitempty"`
+	Tags           []string       `json:"tags,omitempty"`
+	CodeStrategy   string         `json:"code_strategy,omitempty"`
+}
+
+type CreateURLResponse struct {
+	ShortCode     string         `json:"short_code"`
+	ShortURL      string         `json:"short_url,omitempty"`
+	OriginalURL   string         `json:"original_url"`
+	CreatedAt     string         `json:"created_at,omitempty"`
+	ExpiresAt     string         `json:"expires_at,omitempty"`
+	Variants      []Variant      `json:"variants,omitempty"`
+	Rules         []RedirectRule `json:"rules,omitempty"`
+	QueryTemplate string         `json:"query_template,omitempty"`
+	Tags          []string       `json:"tags,omitempty"`
+}
+
+type URLStatsResponse struct {
+	ShortCode      string           `json:"short_code"`
+	ClickCount     int64            `json:"click_count"`
+	UniqueClicks   int64            `json:"unique_clicks"`
+	BotClicks      int64            `json:"bot_clicks"`
+	CreatedAt      string           `json:"created_at"`
+	ExpiresAt      string           `json:"expires_at,omitempty"`
+	Expired        bool             `json:"expired,omitempty"`
+	LastAccessedAt string           `json:"last_accessed_at,omitempty"`
+	TopReferrers   []BreakdownEntry `json:"top_referrers,omitempty"`
+	Countries      []BreakdownEntry `json:"countries,omitempty"`
+	Browsers       []BreakdownEntry `json:"browsers,omitempty"`
+	Devices        []BreakdownEntry `json:"devices,omitempty"`
+	Variants       []BreakdownEntry `json:"variants,omitempty"`
+	Rules          []BreakdownEntry `json:"rules,omitempty"`
+}
+
+type URLStatusRequest struct {
+	Active *bool `json:"active"`
+}
+
+type URLStatusResponse struct {
+	ShortCode string `json:"short_code"`
+	Active    bool   `json:"active"`
+}
+
+type URLHistoryEntry struct {
+	Action    string `json:"action"`
+	Actor     string `json:"actor,omitempty"`
+	APIKeyID  string `json:"api_key_id,omitempty"`
+	OldURL    string `json:"old_url,omitempty"`
+	NewURL    string `json:"new_url,omitempty"`
+	ChangedAt string `json:"changed_at"`
+}
+
+type URLHistoryResponse struct {
+	Entries       []URLHistoryEntry `json:"entries"`
+	NextPageToken string            `json:"next_page_token,omitempty"`
+}
+
+type ReportRequest struct {
+	ShortCode       string `json:"short_code"`
+	Reason          string `json:"reason"`
+	ReporterContact string `json:"reporter_contact"`
+}
+
+type ReportResponse struct {
+	ReportID  int64 `json:"report_id,omitempty"`
+	Duplicate bool  `json:"duplicate,omitempty"`
+}
+
+type AbuseReport struct {
+	ID              int64  `json:"id"`
+	ShortCode       string `json:"short_code"`
+	Reason          string `json:"reason"`
+	ReporterContact string `json:"reporter_contact,omitempty"`
+	Status          string `json:"status"`
+	CreatedAt       string `json:"created_at"`
+}
+
+type ListReportsResponse struct {
+	Reports       []AbuseReport `json:"reports"`
+	NextPageToken string        `json:"next_page_token,omitempty"`
+}
+
+type PurgeLayerResult struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type PurgeResponse struct {
+	ShortCode string           `json:"short_code"`
+	Storage   PurgeLayerResult `json:"storage"`
+	Cache     PurgeLayerResult `json:"cache"`
+	Memory    PurgeLayerResult `json:"memory"`
+	Complete  bool             `json:"complete"`
+}
+
+type DeleteUserDataResponse struct {
+	UserID         string `json:"user_id"`
+	DeletedURLs    int64  `json:"deleted_urls"`
+	ScrubbedClicks int64  `json:"scrubbed_clicks"`
+	CachePurged    int64  `json:"cache_purged"`
+	CacheFailed    int64  `json:"cache_failed"`
+}
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type apiErrorResponse struct {
+	Error apiError `json:"error"`
+}
+
+// registerAPI adds the /api/v1 routes to router.
+func (g *GatewayServer) registerAPI(router *gin.Engine) {
+	api := router.Group("/api/v1")
+	api.POST("/urls", g.createURL)
+	api.GET("/urls/:code/stats", g.urlStats)
+	api.DELETE("/urls/:code", g.deleteURL)
+	api.PUT("/urls/:code/status", g.setURLStatus)
+	api.GET("/urls/:code/history", g.urlHistory)
+	api.POST("/urls/:code/purge", g.purgeURL)
+	api.DELETE("/users/:user/data", g.deleteUserData)
+	api.GET("/users/:user/export", g.exportUserData)
+	api.POST("/reports", g.reportURL)
+	api.GET("/reports", g.listReports)
+}
+
+// shortURL returns the link url-service built for resp, falling back to the
+// gateway's own base URL for services without one. It returns "" when
+// neither is configured, and for other tenants than the default one, whose
+// links aren't on the gateway's base URL.
+func (g *GatewayServer) shortURL(resp *url_service.ShortenResponse) string {
+	if resp.ShortUrl != "" || g.baseURL == "" || resp.TenantId != "" {
+		return resp.ShortUrl
+	}
+	link, err := url.JoinPath(g.baseURL, resp.ShortCode)
+	if err != nil {
+		return ""
+	}
+	return link
+}
+
+func (g *GatewayServer) createURL(c *gin.Context) {
+	var req CreateURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apiAbort(c, http.StatusBadRequest, "INVALID_ARGUMENT", "request body must be a JSON object: "+err.Error())
+		return
+	}
+	if req.OriginalURL == "" && len(req.Variants) == 0 {
+		apiAbort(c, http.StatusBadRequest, "INVALID_ARGUMENT", "original_url or variants is required")
+		return
+	}
+	if req.TTLSeconds < 0 {
+		apiAbort(c, http.StatusBadRequest, "INVALID_ARGUMENT", "ttl_seconds must not be negative")
+		return
+	}
+	if req.MaxClicks < 0 {
+		apiAbort(c, http.StatusBadRequest, "INVALID_ARGUMENT", "max_clicks must not be negative")
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	var trailer metadata.MD
+	resp, err := g.urlClient.ShortenURL(ctx, &url_service.ShortenRequest{
+		OriginalUrl:    req.OriginalURL,
+		CustomAlias:    req.CustomAlias,
+		TtlSeconds:     req.TTLSeconds,
+		MaxClicks:      req.MaxClicks,
+		FallbackUrl:    req.FallbackURL,
+		NotBefore:      req.NotBefore,
+		NotAfter:       req.NotAfter,
+		ComingSoonUrl:  req.ComingSoonURL,
+		Variants:       variantsToProto(req.Variants),
+		StickyVariants: req.StickyVariants,
+		Rules:          rulesToProto(req.Rules),
+		QueryTemplate:  req.QueryTemplate,
+		Tags:           req.Tags,
+		TenantId:       c.GetString(tenantContextKey),
+		CodeStrategy:   req.CodeStrategy,
+	}, grpc.Trailer(&trailer))
+	setResponseHeaders(c, trailer)
+	if err != nil {
+		apiAbortGRPC(c, err)
+		return
+	}
+
+	c.Header("Location", "/api/v1/urls/"+url.PathEscape(resp.ShortCode)+"/stats")
+	c.JSON(http.StatusCreated, CreateURLResponse{
+		ShortCode:     resp.ShortCode,
+		ShortURL:      g.shortURL(resp),
+		OriginalURL:   resp.OriginalUrl,
+		CreatedAt:     resp.CreatedAt,
+		ExpiresAt:     resp.ExpiresAt,
+		Variants:      variantsFromProto(resp.Variants),
+		Rules:         rulesFromProto(resp.Rules),
+		QueryTemplate: resp.QueryTemplate,
+		Tags:          resp.Tags,
+	})
+}
+
+func (g *GatewayServer) urlStats(c *gin.Context) {
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	var trailer metadata.MD
+	resp, err := g.urlClient.GetURLStats(ctx, &url_service.StatsRequest{
+		ShortCode:         c.Param("code"),
+		IncludeBreakdowns: c.Query("breakdowns") == "true",
+	}, grpc.Trailer(&trailer))
+	setResponseHeaders(c, trailer)
+	if err != nil {
+		apiAbortGRPC(c, err)
+		return
+	}
+	if resp.Error != "" {
+		apiAbort(c, http.StatusNotFound, "NOT_FOUND", resp.Error)
+		return
+	}
+
+	c.JSON(http.StatusOK, URLStatsResponse{
+		ShortCode:      resp.ShortCode,
+		ClickCount:     resp.ClickCount,
+		UniqueClicks:   resp.UniqueClicks,
+		BotClicks:      resp.BotClicks,
+		CreatedAt:      resp.CreatedAt,
+		ExpiresAt:      resp.ExpiresAt,
+		Expired:        resp.Expired,
+		LastAccessedAt: resp.LastAccessedAt,
+		TopReferrers:   breakdownEntries(resp.TopReferrers),
+		Countries:      breakdownEntries(resp.Countries),
+		Browsers:       breakdownEntries(resp.Browsers),
+		Devices:        breakdownEntries(resp.Devices),
+		Variants:       breakdownEntries(resp.Variants),
+		Rules:          breakdownEntries(resp.Rules),
+	})
+}
+
+func (g *GatewayServer) deleteURL(c *gin.Context) {
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	var trailer metadata.MD
+	_, err := g.urlClient.DeleteURL(ctx, &url_service.DeleteURLRequest{
+		ShortCode: c.Param("code"),
+	}, grpc.Trailer(&trailer))
+	setResponseHeaders(c, trailer)
+	if err != nil {
+		apiAbortGRPC(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (g *GatewayServer) setURLStatus(c *gin.Context) {
+	var req URLStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apiAbort(c, http.StatusBadRequest, "INVALID_ARGUMENT", "request body must be a JSON object: "+err.Error())
+		return
+	}
+	if req.Active == nil {
+		apiAbort(c, http.StatusBadRequest, "INVALID_ARGUMENT", "active is required")
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	var trailer metadata.MD
+	resp, err := g.urlClient.SetURLStatus(ctx, &url_service.SetURLStatusRequest{
+		ShortCode: c.Param("code"),
+		Active:    *req.Active,
+	}, grpc.Trailer(&trailer))
+	setResponseHeaders(c, trailer)
+	if err != nil {
+		apiAbortGRPC(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, URLStatusResponse{
+		ShortCode: resp.ShortCode,
+		Active:    resp.Active,
+	})
+}
+
+func (g *GatewayServer) urlHistory(c *gin.Context) {
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "0"))
+	if err != nil {
+		apiAbort(c, http.StatusBadRequest, "INVALID_ARGUMENT", "page_size must be a number")
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	var trailer metadata.MD
+	resp, err := g.urlClient.GetURLHistory(ctx, &url_service.GetURLHistoryRequest{
+		ShortCode: c.Param("code"),
+		PageSize:  int32(pageSize),
+		PageToken: c.Query("page_token"),
+	}, grpc.Trailer(&trailer))
+	setResponseHeaders(c, trailer)
+	if err != nil {
+		apiAbortGRPC(c, err)
+		return
+	}
+
+	entries := make([]URLHistoryEntry, len(resp.Entries))
+	for i, e := range resp.Entries {
+		entries[i] = URLHistoryEntry{
+			Action:    e.Action,
+			Actor:     e.Actor,
+			APIKeyID:  e.ApiKeyId,
+			OldURL:    e.OldUrl,
+			NewURL:    e.NewUrl,
+			ChangedAt: e.ChangedAt,
+		}
+	}
+	c.JSON(http.StatusOK, URLHistoryResponse{Entries: entries, NextPageToken: resp.NextPageToken})
+}
+
+func (g *GatewayServer) purgeURL(c *gin.Context) {
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	var trailer metadata.MD
+	resp, err := g.urlClient.PurgeURL(ctx, &url_service.PurgeURLRequest{
+		ShortCode: c.Param("code"),
+	}, grpc.Trailer(&trailer))
+	setResponseHeaders(c, trailer)
+	if err != nil {
+		apiAbortGRPC(c, err)
+		return
+	}
+
+	// A partial purge is worth retrying, like any unavailable dependency
+	httpStatus := http.StatusOK
+	if !resp.Complete {
+		httpStatus = http.StatusServiceUnavailable
+	}
+	c.JSON(httpStatus, PurgeResponse{
+		ShortCode: resp.ShortCode,
+		Storage:   purgeLayer(resp.Storage),
+		Cache:     purgeLayer(resp.Cache),
+		Memory:    purgeLayer(resp.Memory),
+		Complete:  resp.Complete,
+	})
+}
+
+func (g *GatewayServer) deleteUserData(c *gin.Context) {
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	var trailer metadata.MD
+	resp, err := g.urlClient.DeleteUserData(ctx, &url_service.DeleteUserDataRequest{
+		UserId: c.Param("user"),
+	}, grpc.Trailer(&trailer))
+	setResponseHeaders(c, trailer)
+	if err != nil {
+		apiAbortGRPC(c, err)
+		return
+	}
+
+	// Links still cached are worth retrying for, like a partial purge
+	httpStatus := http.StatusOK
+	if resp.CacheFailed > 0 {
+		httpStatus = http.StatusServiceUnavailable
+	}
+	c.JSON(httpStatus, DeleteUserDataResponse{
+		UserID:         resp.UserId,
+		DeletedURLs:    resp.DeletedUrls,
+		ScrubbedClicks: resp.ScrubbedClicks,
+		CachePurged:    resp.CachePurged,
+		CacheFailed:    resp.CacheFailed,
+	})
+}
+
+// exportUserData streams a user's data as newline delimited JSON, one line
+// per chunk from url-service, the last one being the summary.
+func (g *GatewayServer) exportUserData(c *gin.Context) {
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	stream, err := g.urlClient.ExportUserData(ctx, &url_service.ExportUserDataRequest{
+		UserId: c.Param("user"),
+	})
+	if err != nil {
+		apiAbortGRPC(c, err)
+		return
+	}
+	// Errors are only known once the first chunk is read, and can still be
+	// answered with a status then
+	chunk, err := stream.Recv()
+	if err != nil {
+		apiAbortGRPC(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	for {
+		c.Writer.Write(chunk.Data)
+		c.Writer.Write([]byte("\n"))
+		c.Writer.Flush()
+		if chunk, err = stream.Recv(); err != nil {
+			// The summary line is missing if the export broke off
+			if !errors.Is(err, io.EOF) {
+				log.Printf("User data export of %s stopped: %v", c.Param("user"), err)
+			}
+			return
+		}
+	}
+}
+
+func purgeLayer(r *url_service.PurgeLayerResult) PurgeLayerResult {
+	return PurgeLayerResult{OK: r.GetOk(), Error: r.GetError()}
+}
+
+func (g *GatewayServer) reportURL(c *gin.Context) {
+	var req ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apiAbort(c, http.StatusBadRequest, "INVALID_ARGUMENT", "request body must be a JSON object: "+err.Error())
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	var trailer metadata.MD
+	resp, err := g.urlClient.ReportURL(ctx, &url_service.ReportURLRequest{
+		ShortCode:       req.ShortCode,
+		Reason:          req.Reason,
+		ReporterContact: req.ReporterContact,
+	}, grpc.Trailer(&trailer))
+	setResponseHeaders(c, trailer)
+	if err != nil {
+		apiAbortGRPC(c, err)
+		return
+	}
+
+	// A repeat report from the same reporter isn't stored again
+	httpStatus := http.StatusCreated
+	if resp.Duplicate {
+		httpStatus = http.StatusOK
+	}
+	c.JSON(httpStatus, ReportResponse{ReportID: resp.ReportId, Duplicate: resp.Duplicate})
+}
+
+func (g *GatewayServer) listReports(c *gin.Context) {
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "0"))
+	if err != nil {
+		apiAbort(c, http.StatusBadRequest, "INVALID_ARGUMENT", "page_size must be a number")
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	var trailer metadata.MD
+	resp, err := g.urlClient.ListReports(ctx, &url_service.ListReportsRequest{
+		Status:    c.Query("status"),
+		ShortCode: c.Query("short_code"),
+		PageSize:  int32(pageSize),
+		PageToken: c.Query("page_token"),
+	}, grpc.Trailer(&trailer))
+	setResponseHeaders(c, trailer)
+	if err != nil {
+		apiAbortGRPC(c, err)
+		return
+	}
+
+	reports := make([]AbuseReport, len(resp.Reports))
+	for i, r := range resp.Reports {
+		reports[i] = AbuseReport{
+			ID:              r.Id,
+			ShortCode:       r.ShortCode,
+			Reason:          r.Reason,
+			ReporterContact: r.ReporterContact,
+			Status:          r.Status,
+			CreatedAt:       r.CreatedAt,
+		}
+	}
+	c.JSON(http.StatusOK, ListReportsResponse{Reports: reports, NextPageToken: resp.NextPageToken})
+}
+
+// apiAbort writes an error envelope.
+func apiAbort(c *gin.Context, httpStatus int, code, message string) {
+	c.AbortWithStatusJSON(httpStatus, apiErrorResponse{Error: apiError{Code: code, Message: message}})
+}
+
+// apiAbortGRPC writes the error envelope for a failed url-service call,
+// naming the gRPC code in the style of Google's JSON APIs (NOT_FOUND, ...).
+func apiAbortGRPC(c *gin.Context, err error) {
+	code := strings.ToUpper(toSnakeCase(status.Code(err).String()))
+	apiAbort(c, httpStatusFromGRPC(err), code, grpcErrorMessage(err))
+}
+
+// toSnakeCase turns a CamelCase name into snake_case.
+func toSnakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToLower(b.String())
+}
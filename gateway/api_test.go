@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (f *fakeURLService) ShortenURL(ctx context.Context, req *url_service.ShortenRequest, opts ...grpc.CallOption) (*url_service.ShortenResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	code := req.CustomAlias
+	if code == "" {
+		code = "abc123"
+	}
+	originalURL := req.OriginalUrl
+	if len(req.Variants) > 0 {
+		originalURL = req.Variants[0].Url
+	}
+	return &url_service.ShortenResponse{ShortCode: code, OriginalUrl: originalURL, CreatedAt: "2024-01-02T03:04:05Z", Variants: req.Variants, Rules: req.Rules}, nil
+}
+
+func (f *fakeURLService) DeleteURL(ctx context.Context, req *url_service.DeleteURLRequest, opts ...grpc.CallOption) (*url_service.DeleteURLResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &url_service.DeleteURLResponse{Success: true}, nil
+}
+
+func (f *fakeURLService) ReportURL(ctx context.Context, req *url_service.ReportURLRequest, opts ...grpc.CallOption) (*url_service.ReportURLResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range f.reports {
+		if r.ShortCode == req.ShortCode {
+			return &url_service.ReportURLResponse{Duplicate: true}, nil
+		}
+	}
+	f.reports = append(f.reports, req)
+	return &url_service.ReportURLResponse{ReportId: int64(len(f.reports))}, nil
+}
+
+func (f *fakeURLService) PurgeURL(ctx context.Context, req *url_service.PurgeURLRequest, opts ...grpc.CallOption) (*url_service.PurgeURLResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	// "stuck" stays cached, as if the cache delete failed
+	cache := &url_service.PurgeLayerResult{Ok: true}
+	if req.ShortCode == "stuck" {
+		cache = &url_service.PurgeLayerResult{Error: "cache unreachable"}
+	}
+	return &url_service.PurgeURLResponse{
+		ShortCode: req.ShortCode,
+		Storage:   &url_service.PurgeLayerResult{Ok: true},
+		Cache:     cache,
+		Memory:    &url_service.PurgeLayerResult{Ok: true},
+		Complete:  cache.Ok,
+	}, nil
+}
+
+func TestAPIStatuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		err      error
+		want     int
+		wantCode string
+	}{
+		{"create", http.MethodPost, "/api/v1/urls", `{"original_url": "https://example.com", "ttl_seconds": 60}`, nil, http.StatusCreated, ""},
+		{"create with malformed body", http.MethodPost, "/api/v1/urls", `{"original_url":`, nil, http.StatusBadRequest, "INVALID_ARGUMENT"},
+		{"create without URL", http.MethodPost, "/api/v1/urls", `{"custom_alias": "mine"}`, nil, http.StatusBadRequest, "INVALID_ARGUMENT"},
+		{"create split link", http.MethodPost, "/api/v1/urls", `{"variants": [{"url": "https://example.com/a", "weight": 1}, {"url": "https://example.com/b", "weight": 1}]}`, nil, http.StatusCreated, ""},
+		{"create with negative TTL", http.MethodPost, "/api/v1/urls", `{"original_url": "https://example.com", "ttl_seconds": -1}`, nil, http.StatusBadRequest, "INVALID_ARGUMENT"},
+		{"create rejected by the service", http.MethodPost, "/api/v1/urls", `{"original_url": "ftp://example.com"}`, status.Error(codes.InvalidArgument, "unsupported scheme"), http.StatusBadRequest, "INVALID_ARGUMENT"},
+		{"create with taken alias", http.MethodPost, "/api/v1/urls", `{"original_url": "https://example.com", "custom_alias": "taken"}`, status.Error(codes.AlreadyExists, "alias taken"), http.StatusConflict, "ALREADY_EXISTS"},
+		{"create rate limited", http.MethodPost, "/api/v1/urls", `{"original_url": "https://example.com"}`, status.Error(codes.ResourceExhausted, "slow down"), http.StatusTooManyRequests, "RESOURCE_EXHAUSTED"},
+		{"stats", http.MethodGet, "/api/v1/urls/abc123/stats", "", nil, http.StatusOK, ""},
+		{"stats of unknown code", http.MethodGet, "/api/v1/urls/ghost/stats", "", status.Error(codes.NotFound, "URL not found"), http.StatusNotFound, "NOT_FOUND"},
+		{"delete", http.MethodDelete, "/api/v1/urls/abc123", "", nil, http.StatusNoContent, ""},
+		{"delete unknown code", http.MethodDelete, "/api/v1/urls/ghost", "", status.Error(codes.NotFound, "URL not found"), http.StatusNotFound, "NOT_FOUND"},
+		{"report", http.MethodPost, "/api/v1/reports", `{"short_code": "abc123", "reason": "spam"}`, nil, http.StatusCreated, ""},
+		{"report with malformed body", http.MethodPost, "/api/v1/reports", `["abc123"]`, nil, http.StatusBadRequest, "INVALID_ARGUMENT"},
+		{"report of unknown code", http.MethodPost, "/api/v1/reports", `{"short_code": "ghost", "reason": "spam"}`, status.Error(codes.NotFound, "URL not found"), http.StatusNotFound, "NOT_FOUND"},
+		{"report without reason", http.MethodPost, "/api/v1/reports", `{"short_code": "abc123"}`, status.Error(codes.InvalidArgument, "reason is required"), http.StatusBadRequest, "INVALID_ARGUMENT"},
+	}
+	for _, tt := range tests {
+		router := newTestRouter(t, newTestGateway(&fakeURLService{err: tt.err}))
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+			continue
+		}
+		if tt.wantCode == "" {
+			continue
+		}
+		var envelope apiErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil || envelope.Error.Code != tt.wantCode || envelope.Error.Message == "" {
+			t.Errorf("%s: error body %s, want code %s with a message", tt.name, w.Body, tt.wantCode)
+		}
+	}
+}
+
+func TestAPICreateShortURL(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+	}{
+		{"", ""},
+		{"https://sho.rt", "https://sho.rt/abc123"},
+		{"https://sho.rt/r/", "https://sho.rt/r/abc123"},
+	}
+	for _, tt := range tests {
+		g := newTestGateway(&fakeURLService{})
+		g.baseURL = tt.baseURL
+		router := newTestRouter(t, g)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/urls", strings.NewReader(`{"original_url": "https://example.com"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp CreateURLResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("base URL %q: %v: %s", tt.baseURL, err, w.Body)
+		}
+		if resp.ShortCode != "abc123" || resp.ShortURL != tt.want {
+			t.Errorf("base URL %q: got %+v, want short URL %q", tt.baseURL, resp, tt.want)
+		}
+		if loc := w.Header().Get("Location"); loc != "/api/v1/urls/abc123/stats" {
+			t.Errorf("base URL %q: Location %q", tt.baseURL, loc)
+		}
+	}
+}
+
+func TestAPICreateSplitLink(t *testing.T) {
+	router := newTestRouter(t, newTestGateway(&fakeURLService{}))
+	body := `{"variants": [{"name": "control", "url": "https://example.com/a", "weight": 70}, {"url": "https://example.com/b", "weight": 30}], "sticky_variants": true}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/urls", strings.NewReader(body)))
+
+	var resp CreateURLResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("got %d, %v: %s", w.Code, err, w.Body)
+	}
+	want := []Variant{{Name: "control", URL: "https://example.com/a", Weight: 70}, {URL: "https://example.com/b", Weight: 30}}
+	if resp.OriginalURL != "https://example.com/a" || !slices.Equal(resp.Variants, want) {
+		t.Errorf("got %+v, want the variants back and the first as original_url", resp)
+	}
+}
+
+func TestAPIRedirectRules(t *testing.T) {
+	router := newTestRouter(t, newTestGateway(&fakeURLService{}))
+	body := `{"original_url": "https://example.com/app", "rules": [{"device": "ios", "url": "https://apps.apple.com/app"}, {"countries": ["DE"], "url": "https://example.de"}]}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/urls", strings.NewReader(body)))
+
+	var created CreateURLResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("create: got %d, %v: %s", w.Code, err, w.Body)
+	}
+	if len(created.Rules) != 2 || created.Rules[0].Device != "ios" || created.Rules[1].Countries[0] != "DE" || created.Rules[1].URL != "https://example.de" {
+		t.Errorf("created rules %+v, want both rules back in order", created.Rules)
+	}
+
+	// Stats break clicks out by the rule that matched
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/urls/app/stats?breakdowns=true", nil))
+	var stats URLStatsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("stats: got %d, %v: %s", w.Code, err, w.Body)
+	}
+	want := []BreakdownEntry{{Value: "default", Clicks: 4}, {Value: "1", Clicks: 3}}
+	if !slices.Equal(stats.Rules, want) {
+		t.Errorf("rules breakdown %+v, want %+v", stats.Rules, want)
+	}
+}
+
+func TestAPIReportURL(t *testing.T) {
+	urlService := &fakeURLService{}
+	router := newTestRouter(t, newTestGateway(urlService))
+	body := `{"short_code": "abc123", "reason": "phishing", "reporter_contact": "me@example.com"}`
+
+	// A repeat report is answered 200 instead of 201, without an ID
+	for i, want := range []ReportResponse{{ReportID: 1}, {Duplicate: true}} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/reports", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		wantStatus := http.StatusCreated
+		if want.Duplicate {
+			wantStatus = http.StatusOK
+		}
+		var got ReportResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || w.Code != wantStatus || got != want {
+			t.Errorf("report %d: got %d %s, want %d %+v", i, w.Code, w.Body, wantStatus, want)
+		}
+	}
+	if r := urlService.reports[0]; r.ShortCode != "abc123" || r.Reason != "phishing" || r.ReporterContact != "me@example.com" {
+		t.Errorf("reported %v", r)
+	}
+}
+
+func TestAPIPurgeURL(t *testing.T) {
+	tests := []struct {
+		code string
+		want int
+		body PurgeResponse
+	}{
+		{"gone", http.StatusOK, PurgeResponse{ShortCode: "gone", Storage: PurgeLayerResult{OK: true}, Cache: PurgeLayerResult{OK: true}, Memory: PurgeLayerResult{OK: true}, Complete: true}},
+		{"stuck", http.StatusServiceUnavailable, PurgeResponse{ShortCode: "stuck", Storage: PurgeLayerResult{OK: true}, Cache: PurgeLayerResult{Error: "cache unreachable"}, Memory: PurgeLayerResult{OK: true}}},
+	}
+	for _, tt := range tests {
+		router := newTestRouter(t, newTestGateway(&fakeURLService{}))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/urls/"+tt.code+"/purge", nil))
+
+		var got PurgeResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || w.Code != tt.want || got != tt.body {
+			t.Errorf("%s: got %d %s, want %d %+v", tt.code, w.Code, w.Body, tt.want, tt.body)
+		}
+	}
+
+	// Refused purges keep their status
+	router := newTestRouter(t, newTestGateway(&fakeURLService{err: status.Error(codes.PermissionDenied, "admin access required")}))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/urls/gone/purge", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("purge by a non-admin: got %d, want 403", w.Code)
+	}
+}
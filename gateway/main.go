@@ -2,39 +2,136 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	url_service "github.com/syedalijabir/protos/url-service"
 
 	"github.com/gin-gonic/gin"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 // HTTP Request/Response structures
 type ShortenRequest struct {
-	URL         string `json:"url" binding:"required"`
-	CustomAlias string `json:"custom_alias,omitempty"`
+	URL                string         `json:"url"` // Required unless variants are set
+	CustomAlias        string         `json:"custom_alias,omitempty"`
+	TTLSeconds         int64          `json:"ttl_seconds,omitempty"`
+	WaitForPersistence bool           `json:"wait_for_persistence,omitempty"`
+	MaxClicks          int64          `json:"max_clicks,omitempty"`
+	FallbackURL        string         `json:"fallback_url,omitempty"`
+	NotBefore          string         `json:"not_before,omitempty"`
+	NotAfter           string         `json:"not_after,omitempty"`
+	ComingSoonURL      string         `json:"coming_soon_url,omitempty"`
+	Variants           []Variant      `json:"variants,omitempty"`
+	StickyVariants     bool           `json:"sticky_variants,omitempty"`
+	Rules              []RedirectRule `json:"rules,omitempty"`
+	QueryTemplate      string         `json:"query_template,omitempty"`
+	Tags               []string       `json:"tags,omitempty"`
+	CodeStrategy       string         `json:"code_strategy,omitempty"`
 }
 
 type ShortenResponse struct {
-	ShortCode   string `json:"short_code"`
-	OriginalURL string `json:"original_url"`
-	Error       string `json:"error,omitempty"`
+	ShortCode     string         `json:"short_code"`
+	ShortURL      string         `json:"short_url,omitempty"`
+	OriginalURL   string         `json:"original_url"`
+	CreatedAt     string         `json:"created_at,omitempty"`
+	ExpiresAt     string         `json:"expires_at,omitempty"`
+	Variants      []Variant      `json:"variants,omitempty"`
+	Rules         []RedirectRule `json:"rules,omitempty"`
+	QueryTemplate string         `json:"query_template,omitempty"`
+	Tags          []string       `json:"tags,omitempty"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// Variant is one weighted destination of a split link.
+type Variant struct {
+	Name   string `json:"name,omitempty"`
+	URL    string `json:"url"`
+	Weight int32  `json:"weight"`
+}
+
+// RedirectRule sends the visitors whose device and country it matches to
+// its own destination.
+type RedirectRule struct {
+	Device    string   `json:"device,omitempty"`
+	Countries []string `json:"countries,omitempty"`
+	URL       string   `json:"url"`
 }
 
 type StatsResponse struct {
-	ShortCode  string `json:"short_code"`
-	ClickCount int64  `json:"click_count"`
-	CreatedAt  string `json:"created_at"`
-	Error      string `json:"error,omitempty"`
+	ShortCode      string           `json:"short_code"`
+	ClickCount     int64            `json:"click_count"`
+	UniqueClicks   int64            `json:"unique_clicks"`
+	BotClicks      int64            `json:"bot_clicks"`
+	CreatedAt      string           `json:"created_at"`
+	ExpiresAt      string           `json:"expires_at,omitempty"`
+	Expired        bool             `json:"expired,omitempty"`
+	LastAccessedAt string           `json:"last_accessed_at,omitempty"`
+	TopReferrers   []BreakdownEntry `json:"top_referrers,omitempty"`
+	Countries      []BreakdownEntry `json:"countries,omitempty"`
+	Browsers       []BreakdownEntry `json:"browsers,omitempty"`
+	Devices        []BreakdownEntry `json:"devices,omitempty"`
+	Variants       []BreakdownEntry `json:"variants,omitempty"`
+	Rules          []BreakdownEntry `json:"rules,omitempty"`
+	Error          string           `json:"error,omitempty"`
 }
 
+type BreakdownEntry struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+// visitorCookie holds a random ID that keeps visitors of sticky split links
+// on the same variant.
+const (
+	visitorCookie       = "visitor_id"
+	visitorCookieMaxAge = 365 * 24 * time.Hour
+)
+
+// fallbackReasonHeader tells why a redirect doesn't go to the link's
+// destination: coming_soon, expired, exhausted, disabled or deleted.
+const fallbackReasonHeader = "X-Fallback-Reason"
+
 type GatewayServer struct {
 	urlClient url_service.URLServiceClient
+
+	// trustedProxies are the addresses, or CIDR ranges, of the proxies in
+	// front of the gateway whose X-Forwarded-For is believed. Other
+	// callers' IP is the address they connect from.
+	trustedProxies []string
+	// redirectStatus is 302 by default so every visit reaches the service
+	// and is counted. 301s are cached by browsers for redirectMaxAge.
+	redirectStatus int
+	redirectMaxAge time.Duration
+
+	// baseURL is the public address of the redirect routes, used to build
+	// full short URLs. Empty leaves them out.
+	baseURL string
+
+	// countryHeader names the header a CDN or load balancer puts the
+	// visitor's country in, such as CF-IPCountry. Empty records no country.
+	countryHeader string
+
+	// tenantHosts maps the hosts of tenants' domains to their tenant.
+	// Other hosts are the default tenant's.
+	tenantHosts map[string]string
+
+	// serviceKey is the gateway's own API key, for lookups it makes for
+	// itself rather than for the caller. Empty without URL_SERVICE_API_KEY.
+	serviceKey string
 }
 
 func getEnv(key, defaultValue string) string {
@@ -45,35 +142,205 @@ func getEnv(key, defaultValue string) string {
 }
 
 func NewGatewayServer() (*GatewayServer, error) {
+	trustedProxies, err := parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %v", err)
+	}
+	redirectStatus, err := strconv.Atoi(getEnv("REDIRECT_STATUS", "302"))
+	if err != nil || (redirectStatus != http.StatusMovedPermanently && redirectStatus != http.StatusFound) {
+		return nil, fmt.Errorf("invalid REDIRECT_STATUS %q, want 301 or 302", os.Getenv("REDIRECT_STATUS"))
+	}
+	redirectMaxAge, err := time.ParseDuration(getEnv("REDIRECT_CACHE_MAX_AGE", "1h"))
+	if err != nil || redirectMaxAge < 0 {
+		return nil, fmt.Errorf("invalid REDIRECT_CACHE_MAX_AGE %q", os.Getenv("REDIRECT_CACHE_MAX_AGE"))
+	}
+
+	tenantHosts, err := parseTenantHosts(os.Getenv("TENANT_HOSTS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TENANT_HOSTS: %v", err)
+	}
+
 	urlHost := getEnv("URL_SERVICE_HOST", "url-service")
-	urlConn, err := grpc.Dial(urlHost+":50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	urlConn, err := grpc.NewClient(urlHost+":50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
 		return nil, err
 	}
 
 	return &GatewayServer{
-		urlClient: url_service.NewURLServiceClient(urlConn),
+		urlClient:      url_service.NewURLServiceClient(urlConn),
+		trustedProxies: trustedProxies,
+		redirectStatus: redirectStatus,
+		redirectMaxAge: redirectMaxAge,
+		baseURL:        os.Getenv("BASE_URL"),
+		countryHeader:  os.Getenv("COUNTRY_HEADER"),
+		tenantHosts:    tenantHosts,
+		serviceKey:     os.Getenv("URL_SERVICE_API_KEY"),
 	}, nil
 }
 
+// parseTenantHosts reads the comma separated "host=tenant" entries of
+// TENANT_HOSTS.
+func parseTenantHosts(list string) (map[string]string, error) {
+	hosts := make(map[string]string)
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, tenant, ok := strings.Cut(entry, "=")
+		if !ok || host == "" || tenant == "" {
+			return nil, fmt.Errorf("entry %q, want host=tenant", entry)
+		}
+		hosts[strings.ToLower(host)] = tenant
+	}
+	return hosts, nil
+}
+
+// parseTrustedProxies reads the comma separated addresses and CIDR ranges
+// of TRUSTED_PROXIES.
+func parseTrustedProxies(list string) ([]string, error) {
+	var proxies []string
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, err := netip.ParsePrefix(entry); err != nil {
+			if _, err := netip.ParseAddr(entry); err != nil {
+				return nil, fmt.Errorf("entry %q, want an IP address or CIDR range", entry)
+			}
+		}
+		proxies = append(proxies, entry)
+	}
+	return proxies, nil
+}
+
+// tenantContextKey is where resolveTenant leaves the tenant of a request.
+const tenantContextKey = "tenant"
+
+// resolveTenant tells the tenant of each request from the host it came in
+// on, for requestContext to pass on.
+func (g *GatewayServer) resolveTenant(c *gin.Context) {
+	host := c.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if tenant, ok := g.tenantHosts[strings.ToLower(host)]; ok {
+		c.Set(tenantContextKey, tenant)
+	}
+	c.Next()
+}
+
+// requestContext bounds an RPC made on behalf of c and forwards the caller's
+// IP, its X-Request-ID and X-API-Key headers, if any, and the tenant of the
+// host it came in on. The IP is only taken from X-Forwarded-For when the
+// request came through one of TRUSTED_PROXIES, so callers can't pick their
+// own to dodge rate limits.
+func requestContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	ctx := context.Background()
+	if tenant := c.GetString(tenantContextKey); tenant != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-tenant-id", tenant)
+	}
+	if id := c.GetHeader("X-Request-ID"); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-request-id", id)
+	}
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", key)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-forwarded-for", c.ClientIP())
+	return context.WithTimeout(ctx, 5*time.Second)
+}
+
+// withAPIKey makes an RPC in ctx with key instead of any the caller sent.
+func withAPIKey(ctx context.Context, key string) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Set("x-api-key", key)
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// setResponseHeaders surfaces the request ID and any retry-after hint
+// returned by url-service.
+func setResponseHeaders(c *gin.Context, trailer metadata.MD) {
+	if values := trailer.Get("x-request-id"); len(values) > 0 {
+		c.Header("X-Request-ID", values[0])
+	}
+	if values := trailer.Get("retry-after"); len(values) > 0 {
+		c.Header("Retry-After", values[0])
+	}
+}
+
+// httpStatusFromGRPC maps a gRPC error returned by the URL service to the
+// closest HTTP status code.
+func httpStatusFromGRPC(err error) int {
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.FailedPrecondition:
+		return http.StatusConflict
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// grpcErrorMessage returns the human readable message of a gRPC error.
+func grpcErrorMessage(err error) string {
+	if st, ok := status.FromError(err); ok {
+		return st.Message()
+	}
+	return err.Error()
+}
+
 func (g *GatewayServer) ShortenURL(c *gin.Context) {
 	var req ShortenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, ShortenResponse{Error: err.Error()})
 		return
 	}
+	if req.URL == "" && len(req.Variants) == 0 {
+		c.JSON(http.StatusBadRequest, ShortenResponse{Error: "url is required"})
+		return
+	}
 
 	// Simple protocol conversion - no business logic
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := requestContext(c)
 	defer cancel()
 
+	var trailer metadata.MD
 	resp, err := g.urlClient.ShortenURL(ctx, &url_service.ShortenRequest{
-		OriginalUrl: req.URL,
-		CustomAlias: req.CustomAlias,
-	})
+		OriginalUrl:        req.URL,
+		CustomAlias:        req.CustomAlias,
+		TtlSeconds:         req.TTLSeconds,
+		WaitForPersistence: req.WaitForPersistence,
+		MaxClicks:          req.MaxClicks,
+		FallbackUrl:        req.FallbackURL,
+		NotBefore:          req.NotBefore,
+		NotAfter:           req.NotAfter,
+		ComingSoonUrl:      req.ComingSoonURL,
+		Variants:           variantsToProto(req.Variants),
+		StickyVariants:     req.StickyVariants,
+		Rules:              rulesToProto(req.Rules),
+		QueryTemplate:      req.QueryTemplate,
+		Tags:               req.Tags,
+		TenantId:           c.GetString(tenantContextKey),
+		CodeStrategy:       req.CodeStrategy,
+	}, grpc.Trailer(&trailer))
+	setResponseHeaders(c, trailer)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ShortenResponse{Error: err.Error()})
+		c.JSON(httpStatusFromGRPC(err), ShortenResponse{Error: grpcErrorMessage(err)})
 		return
 	}
 
@@ -83,37 +350,193 @@ func (g *GatewayServer) ShortenURL(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, ShortenResponse{
-		ShortCode:   resp.ShortCode,
-		OriginalURL: resp.OriginalUrl,
+		ShortCode:     resp.ShortCode,
+		ShortURL:      g.shortURL(resp),
+		OriginalURL:   resp.OriginalUrl,
+		CreatedAt:     resp.CreatedAt,
+		ExpiresAt:     resp.ExpiresAt,
+		Variants:      variantsFromProto(resp.Variants),
+		Rules:         rulesFromProto(resp.Rules),
+		QueryTemplate: resp.QueryTemplate,
+		Tags:          resp.Tags,
 	})
 }
 
+func variantsToProto(variants []Variant) []*url_service.Variant {
+	out := make([]*url_service.Variant, len(variants))
+	for i, v := range variants {
+		out[i] = &url_service.Variant{Name: v.Name, Url: v.URL, Weight: v.Weight}
+	}
+	return out
+}
+
+func variantsFromProto(variants []*url_service.Variant) []Variant {
+	if len(variants) == 0 {
+		return nil
+	}
+	out := make([]Variant, len(variants))
+	for i, v := range variants {
+		out[i] = Variant{Name: v.Name, URL: v.Url, Weight: v.Weight}
+	}
+	return out
+}
+
+func rulesToProto(rules []RedirectRule) []*url_service.RedirectRule {
+	out := make([]*url_service.RedirectRule, len(rules))
+	for i, r := range rules {
+		out[i] = &url_service.RedirectRule{Device: r.Device, Countries: r.Countries, Url: r.URL}
+	}
+	return out
+}
+
+func rulesFromProto(rules []*url_service.RedirectRule) []RedirectRule {
+	if len(rules) == 0 {
+		return nil
+	}
+	out := make([]RedirectRule, len(rules))
+	for i, r := range rules {
+		out[i] = RedirectRule{Device: r.Device, Countries: r.Countries, URL: r.Url}
+	}
+	return out
+}
+
 func (g *GatewayServer) RedirectURL(c *gin.Context) {
 	shortCode := c.Param("code")
 	if shortCode == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Short code is required"})
 		return
 	}
+	// Gin can't route a suffix within a segment, so /:code+ lands here
+	if strings.HasSuffix(shortCode, previewSuffix) {
+		g.PreviewURL(c)
+		return
+	}
+
+	count, ok := countClick(c)
+	if !ok {
+		return
+	}
 
 	// Simple protocol conversion - URL service handles cache/storage logic
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := requestContext(c)
 	defer cancel()
 
-	urlResp, err := g.urlClient.GetOriginalURL(ctx, &url_service.GetOriginalRequest{
+	var trailer metadata.MD
+	visitorID, newVisitor := visitorID(c)
+	req := &url_service.GetOriginalRequest{
 		ShortCode: shortCode,
-	})
+		SkipStats: !count,
+		Prefetch:  isPrefetch(c),
+		Referrer:  c.Request.Referer(),
+		UserAgent: c.Request.UserAgent(),
+		VisitorId: visitorID,
+		TenantId:  c.GetString(tenantContextKey),
+	}
+	if g.countryHeader != "" {
+		req.Country = c.GetHeader(g.countryHeader)
+	}
+	urlResp, err := g.urlClient.GetOriginalURL(ctx, req, grpc.Trailer(&trailer))
+	setResponseHeaders(c, trailer)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(httpStatusFromGRPC(err), gin.H{"error": grpcErrorMessage(err)})
 		return
 	}
 
+	if urlResp.Expired {
+		c.JSON(http.StatusGone, gin.H{"error": "URL has expired"})
+		return
+	}
+	if urlResp.Exhausted {
+		c.JSON(http.StatusGone, gin.H{"error": "URL has reached its click limit"})
+		return
+	}
+	if urlResp.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "URL has been disabled"})
+		return
+	}
 	if !urlResp.Found {
 		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
 		return
 	}
 
-	c.Redirect(http.StatusFound, urlResp.OriginalUrl)
+	if urlResp.Resolution != "" && urlResp.Resolution != "destination" {
+		// A stand-in for the destination, which may come back, so the
+		// redirect mustn't be cached
+		c.Header(fallbackReasonHeader, urlResp.Resolution)
+		c.Header("Cache-Control", "private, no-cache")
+		c.Redirect(http.StatusFound, urlResp.OriginalUrl)
+		return
+	}
+
+	// Only split links need to recognize the visitor next time
+	if urlResp.Variant != "" && newVisitor {
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(visitorCookie, visitorID, int(visitorCookieMaxAge.Seconds()), "/", "", c.Request.TLS != nil, true)
+	}
+	if urlResp.Variant != "" || urlResp.Rule != "" {
+		// The destination depends on the visitor, so a cached redirect
+		// would send others to the wrong one
+		c.Header("Cache-Control", "private, no-cache")
+		c.Redirect(http.StatusFound, urlResp.OriginalUrl)
+		return
+	}
+
+	if g.redirectStatus == http.StatusMovedPermanently {
+		// Keep the max age short: a cached 301 outlives updates and deletes
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(g.redirectMaxAge.Seconds())))
+	} else {
+		c.Header("Cache-Control", "private, no-cache")
+	}
+	c.Redirect(g.redirectStatus, urlResp.OriginalUrl)
+}
+
+// visitorID returns the visitor's ID from their cookie, or a new random one
+// and true if they have none yet.
+func visitorID(c *gin.Context) (string, bool) {
+	if id, err := c.Cookie(visitorCookie); err == nil && id != "" && len(id) <= 64 {
+		return id, false
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b), true
+}
+
+// countClick reads the count_click query flag of a redirect, which lets
+// monitoring and link checkers resolve a code without counting a click.
+// Turning it off takes a valid API key, which only url-service can tell,
+// so visitors can't hide their clicks. It writes the error response and
+// returns false if the flag can't be honored.
+func countClick(c *gin.Context) (bool, bool) {
+	value, set := c.GetQuery("count_click")
+	if !set {
+		return true, true
+	}
+	count, err := strconv.ParseBool(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "count_click must be true or false"})
+		return false, false
+	}
+	if !count && c.GetHeader("X-API-Key") == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "count_click=false requires an API key"})
+		return false, false
+	}
+	return count, true
+}
+
+// isPrefetch reports whether c is a HEAD request or a browser prefetch or
+// preview rather than a visit, which url-service counts as a bot click.
+func isPrefetch(c *gin.Context) bool {
+	if c.Request.Method == http.MethodHead {
+		return true
+	}
+	for _, header := range []string{"Sec-Purpose", "Purpose", "X-Purpose", "X-Moz"} {
+		value := strings.ToLower(c.GetHeader(header))
+		if strings.Contains(value, "prefetch") || strings.Contains(value, "preview") {
+			return true
+		}
+	}
+	return false
 }
 
 func (g *GatewayServer) GetStats(c *gin.Context) {
@@ -123,15 +546,18 @@ func (g *GatewayServer) GetStats(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := requestContext(c)
 	defer cancel()
 
+	var trailer metadata.MD
 	resp, err := g.urlClient.GetURLStats(ctx, &url_service.StatsRequest{
-		ShortCode: shortCode,
-	})
+		ShortCode:         shortCode,
+		IncludeBreakdowns: c.Query("breakdowns") == "true",
+	}, grpc.Trailer(&trailer))
+	setResponseHeaders(c, trailer)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, StatsResponse{Error: err.Error()})
+		c.JSON(httpStatusFromGRPC(err), StatsResponse{Error: grpcErrorMessage(err)})
 		return
 	}
 
@@ -141,12 +567,34 @@ func (g *GatewayServer) GetStats(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, StatsResponse{
-		ShortCode:  resp.ShortCode,
-		ClickCount: resp.ClickCount,
-		CreatedAt:  resp.CreatedAt,
+		ShortCode:      resp.ShortCode,
+		ClickCount:     resp.ClickCount,
+		UniqueClicks:   resp.UniqueClicks,
+		BotClicks:      resp.BotClicks,
+		CreatedAt:      resp.CreatedAt,
+		ExpiresAt:      resp.ExpiresAt,
+		Expired:        resp.Expired,
+		LastAccessedAt: resp.LastAccessedAt,
+		TopReferrers:   breakdownEntries(resp.TopReferrers),
+		Countries:      breakdownEntries(resp.Countries),
+		Browsers:       breakdownEntries(resp.Browsers),
+		Devices:        breakdownEntries(resp.Devices),
+		Variants:       breakdownEntries(resp.Variants),
+		Rules:          breakdownEntries(resp.Rules),
 	})
 }
 
+func breakdownEntries(entries []*url_service.BreakdownEntry) []BreakdownEntry {
+	if len(entries) == 0 {
+		return nil
+	}
+	out := make([]BreakdownEntry, len(entries))
+	for i, e := range entries {
+		out[i] = BreakdownEntry{Value: e.Value, Clicks: e.Clicks}
+	}
+	return out
+}
+
 func (g *GatewayServer) HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":    "healthy",
@@ -155,27 +603,41 @@ func (g *GatewayServer) HealthCheck(c *gin.Context) {
 	})
 }
 
-func main() {
-	gateway, err := NewGatewayServer()
-	if err != nil {
-		log.Fatalf("Failed to create gateway server: %v", err)
-	}
-
+// newRouter routes the gateway's HTTP API to g.
+func newRouter(g *GatewayServer) (*gin.Engine, error) {
 	router := gin.Default()
+	if err := router.SetTrustedProxies(g.trustedProxies); err != nil {
+		return nil, fmt.Errorf("failed to set trusted proxies: %v", err)
+	}
+	router.Use(g.resolveTenant)
 
 	// API routes - HTTP to gRPC conversion
-	router.POST("/shorten", gateway.ShortenURL)
-	router.GET("/stats/:code", gateway.GetStats)
-	router.GET("/:code", gateway.RedirectURL)
+	router.POST("/shorten", g.ShortenURL)
+	router.GET("/stats/:code", g.GetStats)
+	router.GET("/:code", g.RedirectURL)
+	router.HEAD("/:code", g.RedirectURL)
+	g.registerAPI(router)
 
-	router.GET("/health", gateway.HealthCheck)
+	router.GET("/health", g.HealthCheck)
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "URL Shortener Gateway",
 			"version": "1.0.0",
 		})
 	})
+	return router, nil
+}
 
+func main() {
+	gateway, err := NewGatewayServer()
+	if err != nil {
+		log.Fatalf("Failed to create gateway server: %v", err)
+	}
+
+	router, err := newRouter(gateway)
+	if err != nil {
+		log.Fatalf("Failed to create gateway router: %v", err)
+	}
 	if err := router.Run(":8080"); err != nil {
 		log.Fatalf("Failed to start gateway server: %v", err)
 	}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeURLService answers the gateway's lookups like url-service with the
+// single API key validKey, and records what it was asked.
+type fakeURLService struct {
+	url_service.URLServiceClient
+
+	// links answers lookups when set, with NotFound for other codes
+	links map[string]*url_service.GetOriginalResponse
+	// err fails the other calls when set
+	err error
+
+	mu       sync.Mutex
+	lookups  []*url_service.GetOriginalRequest
+	keys     []string
+	forwards []string
+	// reports holds each ReportURL request, repeats of a code duplicates
+	reports []*url_service.ReportURLRequest
+}
+
+const validKey = "s3cret"
+
+func (f *fakeURLService) GetOriginalURL(ctx context.Context, req *url_service.GetOriginalRequest, opts ...grpc.CallOption) (*url_service.GetOriginalResponse, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	f.mu.Lock()
+	f.lookups = append(f.lookups, req)
+	f.keys = append(f.keys, first(md.Get("x-api-key")))
+	f.forwards = append(f.forwards, first(md.Get("x-forwarded-for")))
+	f.mu.Unlock()
+
+	if key := first(md.Get("x-api-key")); key != "" && key != validKey {
+		return nil, status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	if req.SkipStats && first(md.Get("x-api-key")) == "" {
+		return nil, status.Error(codes.Unauthenticated, "skip_stats requires an API key")
+	}
+	if f.links != nil {
+		resp, ok := f.links[req.ShortCode]
+		if !ok {
+			return nil, status.Error(codes.NotFound, "URL not found")
+		}
+		return resp, nil
+	}
+	return &url_service.GetOriginalResponse{OriginalUrl: "https://example.com", Found: true}, nil
+}
+
+func (f *fakeURLService) GetURLStats(ctx context.Context, req *url_service.StatsRequest, opts ...grpc.CallOption) (*url_service.StatsResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	resp := &url_service.StatsResponse{ShortCode: req.ShortCode, ClickCount: 7, CreatedAt: "2024-01-02T03:04:05Z", Title: "Example <Domain>"}
+	if req.IncludeBreakdowns {
+		resp.Rules = []*url_service.BreakdownEntry{{Value: "default", Clicks: 4}, {Value: "1", Clicks: 3}}
+	}
+	return resp, nil
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// newTestRouter returns the gateway's router for g.
+func newTestRouter(t *testing.T, g *GatewayServer) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router, err := newRouter(g)
+	if err != nil {
+		t.Fatalf("newRouter: %v", err)
+	}
+	return router
+}
+
+func newTestGateway(urlService *fakeURLService) *GatewayServer {
+	return &GatewayServer{urlClient: urlService, redirectStatus: http.StatusFound}
+}
+
+func TestRedirectCountClick(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    string
+		key       string
+		want      int
+		skipStats bool
+	}{
+		{"counted", "/abc", "", http.StatusFound, false},
+		{"counted explicitly", "/abc?count_click=true", "", http.StatusFound, false},
+		{"uncounted without key", "/abc?count_click=false", "", http.StatusUnauthorized, false},
+		{"uncounted with made-up key", "/abc?count_click=false", "guess", http.StatusUnauthorized, true},
+		{"uncounted with key", "/abc?count_click=false", validKey, http.StatusFound, true},
+		{"invalid flag", "/abc?count_click=maybe", validKey, http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		urlService := &fakeURLService{}
+		router := newTestRouter(t, newTestGateway(urlService))
+
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.key != "" {
+			req.Header.Set("X-API-Key", tt.key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, w.Code, tt.want)
+		}
+		for _, lookup := range urlService.lookups {
+			if lookup.SkipStats != tt.skipStats {
+				t.Errorf("%s: looked up with skip_stats %v, want %v", tt.name, lookup.SkipStats, tt.skipStats)
+			}
+		}
+	}
+}
+
+func TestRedirectStatuses(t *testing.T) {
+	links := map[string]*url_service.GetOriginalResponse{
+		"plain":     {OriginalUrl: "https://example.com", Found: true, Resolution: "destination"},
+		"split":     {OriginalUrl: "https://example.com/b", Found: true, Variant: "b"},
+		"app":       {OriginalUrl: "https://apps.apple.com/app", Found: true, Rule: "1"},
+		"expired":   {Expired: true},
+		"exhausted": {Exhausted: true},
+		"disabled":  {Disabled: true},
+		"fallback":  {OriginalUrl: "https://example.com/soon", Found: true, Resolution: "coming_soon"},
+		"ended":     {OriginalUrl: "https://example.com/over", Found: true, Resolution: "expired"},
+		"removed":   {OriginalUrl: "https://example.com/over", Found: true, Resolution: "deleted"},
+	}
+	tests := []struct {
+		name         string
+		method       string
+		code         string
+		permanent    bool
+		want         int
+		cacheControl string
+	}{
+		{"302 by default", http.MethodGet, "plain", false, http.StatusFound, "private, no-cache"},
+		{"301 when configured", http.MethodGet, "plain", true, http.StatusMovedPermanently, "public, max-age=3600"},
+		{"HEAD", http.MethodHead, "plain", false, http.StatusFound, "private, no-cache"},
+		{"split link never 301", http.MethodGet, "split", true, http.StatusFound, "private, no-cache"},
+		{"link with rules never 301", http.MethodGet, "app", true, http.StatusFound, "private, no-cache"},
+		{"fallback never 301", http.MethodGet, "fallback", true, http.StatusFound, "private, no-cache"},
+		{"expired link's fallback", http.MethodGet, "ended", true, http.StatusFound, "private, no-cache"},
+		{"deleted link's fallback", http.MethodGet, "removed", false, http.StatusFound, "private, no-cache"},
+		{"unknown", http.MethodGet, "ghost", false, http.StatusNotFound, ""},
+		{"expired", http.MethodGet, "expired", false, http.StatusGone, ""},
+		{"click limit reached", http.MethodGet, "exhausted", false, http.StatusGone, ""},
+		{"disabled", http.MethodGet, "disabled", false, http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		urlService := &fakeURLService{links: links}
+		g := newTestGateway(urlService)
+		if tt.permanent {
+			g.redirectStatus, g.redirectMaxAge = http.StatusMovedPermanently, time.Hour
+		}
+		router := newTestRouter(t, g)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, "/"+tt.code, nil))
+
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if got := w.Header().Get("Cache-Control"); got != tt.cacheControl {
+			t.Errorf("%s: Cache-Control %q, want %q", tt.name, got, tt.cacheControl)
+		}
+		if want := links[tt.code].GetOriginalUrl(); w.Code < 400 && w.Header().Get("Location") != want {
+			t.Errorf("%s: Location %q, want %q", tt.name, w.Header().Get("Location"), want)
+		}
+		// Stand-ins for the destination say why
+		wantReason := links[tt.code].GetResolution()
+		if wantReason == "destination" {
+			wantReason = ""
+		}
+		if got := w.Header().Get(fallbackReasonHeader); got != wantReason {
+			t.Errorf("%s: %s %q, want %q", tt.name, fallbackReasonHeader, got, wantReason)
+		}
+		// One lookup per request, which only counts a click for a visit
+		if len(urlService.lookups) != 1 {
+			t.Fatalf("%s: %d lookups, want 1", tt.name, len(urlService.lookups))
+		}
+		if prefetch := urlService.lookups[0].Prefetch; prefetch != (tt.method == http.MethodHead) {
+			t.Errorf("%s: looked up with prefetch %v", tt.name, prefetch)
+		}
+	}
+}
+
+func TestRedirectVisitorCookie(t *testing.T) {
+	urlService := &fakeURLService{links: map[string]*url_service.GetOriginalResponse{
+		"plain": {OriginalUrl: "https://example.com", Found: true},
+		"split": {OriginalUrl: "https://example.com/b", Found: true, Variant: "b"},
+	}}
+	router := newTestRouter(t, newTestGateway(urlService))
+	redirect := func(code string, cookie *http.Cookie) *http.Cookie {
+		req := httptest.NewRequest(http.MethodGet, "/"+code, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		for _, c := range w.Result().Cookies() {
+			if c.Name == visitorCookie {
+				return c
+			}
+		}
+		return nil
+	}
+
+	// Only split links need to recognize the visitor
+	if cookie := redirect("plain", nil); cookie != nil {
+		t.Errorf("plain link set %v", cookie)
+	}
+	cookie := redirect("split", nil)
+	if cookie == nil || len(cookie.Value) != 32 || !cookie.HttpOnly || cookie.MaxAge != int(visitorCookieMaxAge.Seconds()) {
+		t.Fatalf("split link set %v, want a year-long HttpOnly visitor ID", cookie)
+	}
+	// A returning visitor keeps their ID, and isn't sent a new cookie
+	if again := redirect("split", cookie); again != nil {
+		t.Errorf("returning visitor got %v", again)
+	}
+
+	urlService.mu.Lock()
+	defer urlService.mu.Unlock()
+	lookups := urlService.lookups
+	if len(lookups) != 3 || lookups[1].VisitorId != cookie.Value || lookups[2].VisitorId != cookie.Value {
+		t.Errorf("looked up with visitor IDs %q, %q, want %q for both split lookups", lookups[1].GetVisitorId(), lookups[2].GetVisitorId(), cookie.Value)
+	}
+}
+
+func TestPreviewLookup(t *testing.T) {
+	tests := []struct {
+		name       string
+		serviceKey string
+		callerKey  string
+		wantKey    string
+		skipStats  bool
+	}{
+		{"without service key", "", "", "", false},
+		{"caller's key", "", validKey, validKey, false},
+		{"service key", validKey, "", validKey, true},
+		{"service key over caller's", validKey, "guess", validKey, true},
+	}
+	for _, tt := range tests {
+		urlService := &fakeURLService{}
+		g := newTestGateway(urlService)
+		g.serviceKey = tt.serviceKey
+		router := newTestRouter(t, g)
+
+		req := httptest.NewRequest(http.MethodGet, "/abc+", nil)
+		if tt.callerKey != "" {
+			req.Header.Set("X-API-Key", tt.callerKey)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got %d: %s", tt.name, w.Code, w.Body)
+		}
+		lookup := urlService.lookups[0]
+		if lookup.SkipStats != tt.skipStats || lookup.Prefetch == tt.skipStats {
+			t.Errorf("%s: looked up with skip_stats %v and prefetch %v", tt.name, lookup.SkipStats, lookup.Prefetch)
+		}
+		if key := urlService.keys[0]; key != tt.wantKey {
+			t.Errorf("%s: looked up with key %q, want %q", tt.name, key, tt.wantKey)
+		}
+	}
+}
+
+func TestForwardedFor(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxies    string
+		remoteAddr string
+		header     string
+		want       string
+	}{
+		{"no header", "", "203.0.113.7:4321", "", "203.0.113.7"},
+		{"spoofed without trusted proxies", "", "203.0.113.7:4321", "198.51.100.1", "203.0.113.7"},
+		{"spoofed by untrusted caller", "10.0.0.0/8", "203.0.113.7:4321", "198.51.100.1", "203.0.113.7"},
+		{"from trusted proxy", "10.0.0.0/8", "10.1.2.3:4321", "198.51.100.1", "198.51.100.1"},
+		{"chain through trusted proxy", "10.1.2.3", "10.1.2.3:4321", "192.0.2.9, 198.51.100.1", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		proxies, err := parseTrustedProxies(tt.proxies)
+		if err != nil {
+			t.Fatalf("%s: parseTrustedProxies: %v", tt.name, err)
+		}
+		urlService := &fakeURLService{}
+		g := newTestGateway(urlService)
+		g.trustedProxies = proxies
+		router := newTestRouter(t, g)
+
+		req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+		req.RemoteAddr = tt.remoteAddr
+		if tt.header != "" {
+			req.Header.Set("X-Forwarded-For", tt.header)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		if got := urlService.forwards[0]; got != tt.want {
+			t.Errorf("%s: forwarded %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := parseTrustedProxies(" 10.0.0.0/8, 192.0.2.1 ,,fd00::/8")
+	if err != nil {
+		t.Fatalf("parseTrustedProxies: %v", err)
+	}
+	if len(proxies) != 3 {
+		t.Errorf("got %q, want 3 entries", proxies)
+	}
+	if _, err := parseTrustedProxies("proxy.internal"); err == nil {
+		t.Error("parseTrustedProxies accepted a host name")
+	}
+}
+
+func TestRedirectForwardsClickMetadata(t *testing.T) {
+	tests := []struct {
+		name          string
+		countryHeader string
+		wantCountry   string
+	}{
+		{"country header", "CF-IPCountry", "NZ"},
+		{"no country header", "", ""},
+	}
+	for _, tt := range tests {
+		urlService := &fakeURLService{}
+		g := newTestGateway(urlService)
+		g.countryHeader = tt.countryHeader
+		router := newTestRouter(t, g)
+
+		req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+		req.Header.Set("Referer", "https://news.example/item")
+		req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64)")
+		req.Header.Set("CF-IPCountry", "NZ")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusFound {
+			t.Fatalf("%s: got %d, want 302", tt.name, w.Code)
+		}
+		lookup := urlService.lookups[0]
+		if lookup.Referrer != "https://news.example/item" || lookup.UserAgent != "Mozilla/5.0 (X11; Linux x86_64)" || lookup.Country != tt.wantCountry {
+			t.Errorf("%s: looked up with referrer %q, user agent %q and country %q", tt.name, lookup.Referrer, lookup.UserAgent, lookup.Country)
+		}
+	}
+}
+
+func TestRedirectPrefetchHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		header string
+		value  string
+		want   bool
+	}{
+		{"visit", http.MethodGet, "", "", false},
+		{"HEAD", http.MethodHead, "", "", true},
+		{"Chrome prefetch", http.MethodGet, "Sec-Purpose", "prefetch;prerender", true},
+		{"Safari prefetch", http.MethodGet, "Purpose", "prefetch", true},
+		{"link preview", http.MethodGet, "X-Purpose", "preview", true},
+		{"Firefox prefetch", http.MethodGet, "X-Moz", "Prefetch", true},
+		{"unrelated purpose", http.MethodGet, "Purpose", "navigate", false},
+	}
+	for _, tt := range tests {
+		urlService := &fakeURLService{}
+		router := newTestRouter(t, newTestGateway(urlService))
+
+		req := httptest.NewRequest(tt.method, "/abc", nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		// Prefetches still resolve, url-service counts them as bots
+		if len(urlService.lookups) != 1 {
+			t.Fatalf("%s: %d lookups, want 1", tt.name, len(urlService.lookups))
+		}
+		if got := urlService.lookups[0].Prefetch; got != tt.want {
+			t.Errorf("%s: looked up with prefetch %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
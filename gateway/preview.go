@@ -0,0 +1,128 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// previewSuffix turns a redirect into a preview of where it leads, as in
+// GET /XQwJLm+. Short codes and aliases can't contain it.
+const previewSuffix = "+"
+
+type PreviewResponse struct {
+	ShortCode   string `json:"short_code"`
+	OriginalURL string `json:"original_url"`
+	CreatedAt   string `json:"created_at"`
+	ClickCount  int64  `json:"click_count"`
+	ExpiresAt   string `json:"expires_at,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+}
+
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Preview of {{.ShortCode}}</title></head>
+<body>
+<h1>{{.ShortCode}} leads to</h1>
+<p><a href="{{.OriginalURL}}" rel="noopener noreferrer">{{.OriginalURL}}</a></p>
+{{if .Title}}<h2>{{.Title}}</h2>{{end}}
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+{{if .ImageURL}}<p><img src="{{.ImageURL}}" alt="" referrerpolicy="no-referrer" style="max-width: 100%"></p>{{end}}
+<ul>
+<li>Created: {{.CreatedAt}}</li>
+<li>Clicks: {{.ClickCount}}</li>
+{{if .ExpiresAt}}<li>Expires: {{.ExpiresAt}}</li>{{end}}
+</ul>
+</body>
+</html>
+`))
+
+// PreviewURL shows where a short code leads instead of redirecting, as JSON
+// or, for browsers asking for text/html, as a page, with the destination's
+// title, description and image once url-service has fetched them. A
+// preview never counts as a click: with URL_SERVICE_API_KEY the gateway
+// looks the code up with skip_stats under its own key, and without one as
+// a link preview, which url-service counts as a bot click.
+func (g *GatewayServer) PreviewURL(c *gin.Context) {
+	shortCode := strings.TrimSuffix(c.Param("code"), previewSuffix)
+	if shortCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Short code is required"})
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	req := &url_service.GetOriginalRequest{
+		ShortCode: shortCode,
+		Prefetch:  true,
+		TenantId:  c.GetString(tenantContextKey),
+	}
+	lookupCtx := ctx
+	if g.serviceKey != "" {
+		lookupCtx = withAPIKey(ctx, g.serviceKey)
+		req.SkipStats, req.Prefetch = true, false
+	}
+	var trailer metadata.MD
+	urlResp, err := g.urlClient.GetOriginalURL(lookupCtx, req, grpc.Trailer(&trailer))
+	setResponseHeaders(c, trailer)
+	if err != nil {
+		c.JSON(httpStatusFromGRPC(err), gin.H{"error": grpcErrorMessage(err)})
+		return
+	}
+	if urlResp.Expired {
+		c.JSON(http.StatusGone, gin.H{"error": "URL has expired"})
+		return
+	}
+	if urlResp.Exhausted {
+		c.JSON(http.StatusGone, gin.H{"error": "URL has reached its click limit"})
+		return
+	}
+	if urlResp.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "URL has been disabled"})
+		return
+	}
+	if !urlResp.Found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return
+	}
+
+	stats, err := g.urlClient.GetURLStats(ctx, &url_service.StatsRequest{ShortCode: shortCode, IncludeMetadata: true})
+	if err != nil {
+		c.JSON(httpStatusFromGRPC(err), gin.H{"error": grpcErrorMessage(err)})
+		return
+	}
+	if stats.Error != "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": stats.Error})
+		return
+	}
+
+	preview := PreviewResponse{
+		ShortCode:   shortCode,
+		OriginalURL: urlResp.OriginalUrl,
+		CreatedAt:   stats.CreatedAt,
+		ClickCount:  stats.ClickCount,
+		ExpiresAt:   stats.ExpiresAt,
+		Title:       stats.Title,
+		Description: stats.Description,
+		ImageURL:    stats.ImageUrl,
+	}
+	c.Header("Cache-Control", "private, no-cache")
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		if err := previewPage.Execute(c.Writer, preview); err != nil {
+			c.Error(err)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, preview)
+}
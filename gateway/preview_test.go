@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+)
+
+func TestPreviewFormats(t *testing.T) {
+	urlService := &fakeURLService{links: map[string]*url_service.GetOriginalResponse{
+		"abc":     {OriginalUrl: "https://example.com/page?a=1&b=2", Found: true},
+		"old":     {Expired: true},
+		"used":    {Exhausted: true},
+		"blocked": {Disabled: true},
+	}}
+	router := newTestRouter(t, newTestGateway(urlService))
+
+	tests := []struct {
+		name     string
+		target   string
+		accept   string
+		want     int
+		wantType string
+	}{
+		{"json by default", "/abc+", "", http.StatusOK, "application/json"},
+		{"json asked for", "/abc+", "application/json", http.StatusOK, "application/json"},
+		{"html for browsers", "/abc+", "text/html,application/xhtml+xml,*/*;q=0.8", http.StatusOK, "text/html"},
+		{"missing", "/nope+", "", http.StatusNotFound, "application/json"},
+		{"expired", "/old+", "", http.StatusGone, "application/json"},
+		{"exhausted", "/used+", "", http.StatusGone, "application/json"},
+		{"disabled", "/blocked+", "", http.StatusForbidden, "application/json"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.want || !strings.HasPrefix(w.Header().Get("Content-Type"), tt.wantType) {
+			t.Errorf("%s: got %d %s, want %d %s", tt.name, w.Code, w.Header().Get("Content-Type"), tt.want, tt.wantType)
+		}
+		if w.Header().Get("Location") != "" {
+			t.Errorf("%s: redirected to %s", tt.name, w.Header().Get("Location"))
+		}
+	}
+
+	// The suffix is stripped before the lookup
+	for _, lookup := range urlService.lookups {
+		if strings.HasSuffix(lookup.ShortCode, previewSuffix) {
+			t.Errorf("looked up %q with the preview suffix", lookup.ShortCode)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/abc+", nil))
+	var preview PreviewResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &preview); err != nil {
+		t.Fatalf("decoding the preview: %v", err)
+	}
+	want := PreviewResponse{ShortCode: "abc", OriginalURL: "https://example.com/page?a=1&b=2", CreatedAt: "2024-01-02T03:04:05Z", ClickCount: 7, Title: "Example <Domain>"}
+	if preview != want {
+		t.Errorf("preview = %+v, want %+v", preview, want)
+	}
+
+	// The page escapes what the destination's metadata says
+	req := httptest.NewRequest(http.MethodGet, "/abc+", nil)
+	req.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	page := w.Body.String()
+	for _, s := range []string{`href="https://example.com/page?a=1&amp;b=2"`, "Example &lt;Domain&gt;", "Clicks: 7"} {
+		if !strings.Contains(page, s) {
+			t.Errorf("page has no %s:\n%s", s, page)
+		}
+	}
+}
@@ -0,0 +1,1192 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        v6.33.0
+// source: cache-service/cache.proto
+
+package cache_service
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Namespace     string                 `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRequest) Reset() {
+	*x = GetRequest{}
+	mi := &file_cache_service_cache_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRequest) ProtoMessage() {}
+
+func (x *GetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRequest.ProtoReflect.Descriptor instead.
+func (*GetRequest) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *GetRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+type GetResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         string                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Found         bool                   `protobuf:"varint,2,opt,name=found,proto3" json:"found,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetResponse) Reset() {
+	*x = GetResponse{}
+	mi := &file_cache_service_cache_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetResponse) ProtoMessage() {}
+
+func (x *GetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetResponse.ProtoReflect.Descriptor instead.
+func (*GetResponse) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetResponse) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *GetResponse) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+func (x *GetResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type SetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value         string                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	TtlSeconds    int32                  `protobuf:"varint,3,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"` // Time to live in seconds
+	Namespace     string                 `protobuf:"bytes,4,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetRequest) Reset() {
+	*x = SetRequest{}
+	mi := &file_cache_service_cache_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetRequest) ProtoMessage() {}
+
+func (x *SetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetRequest.ProtoReflect.Descriptor instead.
+func (*SetRequest) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SetRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *SetRequest) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *SetRequest) GetTtlSeconds() int32 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+func (x *SetRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+type SetResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetResponse) Reset() {
+	*x = SetResponse{}
+	mi := &file_cache_service_cache_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetResponse) ProtoMessage() {}
+
+func (x *SetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetResponse.ProtoReflect.Descriptor instead.
+func (*SetResponse) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SetResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *SetResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type SetIfAbsentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Stored        bool                   `protobuf:"varint,1,opt,name=stored,proto3" json:"stored,omitempty"` // False if the key already existed, which is left unchanged
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetIfAbsentResponse) Reset() {
+	*x = SetIfAbsentResponse{}
+	mi := &file_cache_service_cache_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetIfAbsentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetIfAbsentResponse) ProtoMessage() {}
+
+func (x *SetIfAbsentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetIfAbsentResponse.ProtoReflect.Descriptor instead.
+func (*SetIfAbsentResponse) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SetIfAbsentResponse) GetStored() bool {
+	if x != nil {
+		return x.Stored
+	}
+	return false
+}
+
+type DeleteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Namespace     string                 `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRequest) Reset() {
+	*x = DeleteRequest{}
+	mi := &file_cache_service_cache_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRequest) ProtoMessage() {}
+
+func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRequest.ProtoReflect.Descriptor instead.
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeleteRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *DeleteRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+type DeleteResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Existed       bool                   `protobuf:"varint,3,opt,name=existed,proto3" json:"existed,omitempty"` // Whether the key was present, deleting a missing key still succeeds
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteResponse) Reset() {
+	*x = DeleteResponse{}
+	mi := &file_cache_service_cache_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteResponse) ProtoMessage() {}
+
+func (x *DeleteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteResponse.ProtoReflect.Descriptor instead.
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DeleteResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *DeleteResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *DeleteResponse) GetExisted() bool {
+	if x != nil {
+		return x.Existed
+	}
+	return false
+}
+
+type MSetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entries       []*SetRequest          `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"` // Each with its own TTL
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MSetRequest) Reset() {
+	*x = MSetRequest{}
+	mi := &file_cache_service_cache_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MSetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MSetRequest) ProtoMessage() {}
+
+func (x *MSetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MSetRequest.ProtoReflect.Descriptor instead.
+func (*MSetRequest) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *MSetRequest) GetEntries() []*SetRequest {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type MSetResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MSetResponse) Reset() {
+	*x = MSetResponse{}
+	mi := &file_cache_service_cache_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MSetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MSetResponse) ProtoMessage() {}
+
+func (x *MSetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MSetResponse.ProtoReflect.Descriptor instead.
+func (*MSetResponse) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *MSetResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+type ExistsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Namespace     string                 `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExistsRequest) Reset() {
+	*x = ExistsRequest{}
+	mi := &file_cache_service_cache_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExistsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExistsRequest) ProtoMessage() {}
+
+func (x *ExistsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExistsRequest.ProtoReflect.Descriptor instead.
+func (*ExistsRequest) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ExistsRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *ExistsRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+type ExistsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Exists        bool                   `protobuf:"varint,1,opt,name=exists,proto3" json:"exists,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExistsResponse) Reset() {
+	*x = ExistsResponse{}
+	mi := &file_cache_service_cache_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExistsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExistsResponse) ProtoMessage() {}
+
+func (x *ExistsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExistsResponse.ProtoReflect.Descriptor instead.
+func (*ExistsResponse) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ExistsResponse) GetExists() bool {
+	if x != nil {
+		return x.Exists
+	}
+	return false
+}
+
+type MGetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Keys          []string               `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+	Namespace     string                 `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"` // Shared by every key
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MGetRequest) Reset() {
+	*x = MGetRequest{}
+	mi := &file_cache_service_cache_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MGetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MGetRequest) ProtoMessage() {}
+
+func (x *MGetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MGetRequest.ProtoReflect.Descriptor instead.
+func (*MGetRequest) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *MGetRequest) GetKeys() []string {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+func (x *MGetRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+type MGetResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []*GetResponse         `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"` // One per key, in request order
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MGetResponse) Reset() {
+	*x = MGetResponse{}
+	mi := &file_cache_service_cache_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MGetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MGetResponse) ProtoMessage() {}
+
+func (x *MGetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MGetResponse.ProtoReflect.Descriptor instead.
+func (*MGetResponse) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *MGetResponse) GetValues() []*GetResponse {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type GetCacheStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCacheStatsRequest) Reset() {
+	*x = GetCacheStatsRequest{}
+	mi := &file_cache_service_cache_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCacheStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCacheStatsRequest) ProtoMessage() {}
+
+func (x *GetCacheStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCacheStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetCacheStatsRequest) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{13}
+}
+
+// Counters run from service start or the last ResetCacheStats. Reading them
+// doesn't reset them.
+type GetCacheStatsResponse struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Hits             int64                  `protobuf:"varint,1,opt,name=hits,proto3" json:"hits,omitempty"`
+	Misses           int64                  `protobuf:"varint,2,opt,name=misses,proto3" json:"misses,omitempty"`
+	Sets             int64                  `protobuf:"varint,3,opt,name=sets,proto3" json:"sets,omitempty"`
+	Deletes          int64                  `protobuf:"varint,4,opt,name=deletes,proto3" json:"deletes,omitempty"`
+	Evictions        int64                  `protobuf:"varint,5,opt,name=evictions,proto3" json:"evictions,omitempty"`                                                                                                                  // Keys evicted under memory pressure, or over the memory backend's entry limit
+	Expired          int64                  `protobuf:"varint,6,opt,name=expired,proto3" json:"expired,omitempty"`                                                                                                                      // Keys found expired when read; Redis also counts those its sweep removed
+	Entries          int64                  `protobuf:"varint,7,opt,name=entries,proto3" json:"entries,omitempty"`                                                                                                                      // Keys currently stored
+	MemoryBytes      int64                  `protobuf:"varint,8,opt,name=memory_bytes,json=memoryBytes,proto3" json:"memory_bytes,omitempty"`                                                                                           // Memory used by Redis
+	HitRatio         float64                `protobuf:"fixed64,9,opt,name=hit_ratio,json=hitRatio,proto3" json:"hit_ratio,omitempty"`                                                                                                   // hits / (hits + misses), 0 before any read
+	NamespaceEntries map[string]int64       `protobuf:"bytes,10,rep,name=namespace_entries,json=namespaceEntries,proto3" json:"namespace_entries,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"` // Keys currently stored, by namespace
+	Swept            int64                  `protobuf:"varint,11,opt,name=swept,proto3" json:"swept,omitempty"`                                                                                                                         // Keys removed by the memory backend's sweep
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *GetCacheStatsResponse) Reset() {
+	*x = GetCacheStatsResponse{}
+	mi := &file_cache_service_cache_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCacheStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCacheStatsResponse) ProtoMessage() {}
+
+func (x *GetCacheStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCacheStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetCacheStatsResponse) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *GetCacheStatsResponse) GetHits() int64 {
+	if x != nil {
+		return x.Hits
+	}
+	return 0
+}
+
+func (x *GetCacheStatsResponse) GetMisses() int64 {
+	if x != nil {
+		return x.Misses
+	}
+	return 0
+}
+
+func (x *GetCacheStatsResponse) GetSets() int64 {
+	if x != nil {
+		return x.Sets
+	}
+	return 0
+}
+
+func (x *GetCacheStatsResponse) GetDeletes() int64 {
+	if x != nil {
+		return x.Deletes
+	}
+	return 0
+}
+
+func (x *GetCacheStatsResponse) GetEvictions() int64 {
+	if x != nil {
+		return x.Evictions
+	}
+	return 0
+}
+
+func (x *GetCacheStatsResponse) GetExpired() int64 {
+	if x != nil {
+		return x.Expired
+	}
+	return 0
+}
+
+func (x *GetCacheStatsResponse) GetEntries() int64 {
+	if x != nil {
+		return x.Entries
+	}
+	return 0
+}
+
+func (x *GetCacheStatsResponse) GetMemoryBytes() int64 {
+	if x != nil {
+		return x.MemoryBytes
+	}
+	return 0
+}
+
+func (x *GetCacheStatsResponse) GetHitRatio() float64 {
+	if x != nil {
+		return x.HitRatio
+	}
+	return 0
+}
+
+func (x *GetCacheStatsResponse) GetNamespaceEntries() map[string]int64 {
+	if x != nil {
+		return x.NamespaceEntries
+	}
+	return nil
+}
+
+func (x *GetCacheStatsResponse) GetSwept() int64 {
+	if x != nil {
+		return x.Swept
+	}
+	return 0
+}
+
+type ResetCacheStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResetCacheStatsRequest) Reset() {
+	*x = ResetCacheStatsRequest{}
+	mi := &file_cache_service_cache_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResetCacheStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResetCacheStatsRequest) ProtoMessage() {}
+
+func (x *ResetCacheStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResetCacheStatsRequest.ProtoReflect.Descriptor instead.
+func (*ResetCacheStatsRequest) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{15}
+}
+
+type ResetCacheStatsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResetCacheStatsResponse) Reset() {
+	*x = ResetCacheStatsResponse{}
+	mi := &file_cache_service_cache_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResetCacheStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResetCacheStatsResponse) ProtoMessage() {}
+
+func (x *ResetCacheStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResetCacheStatsResponse.ProtoReflect.Descriptor instead.
+func (*ResetCacheStatsResponse) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ResetCacheStatsResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+type FlushNamespaceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"` // Required, flushing the default namespace must be explicit
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FlushNamespaceRequest) Reset() {
+	*x = FlushNamespaceRequest{}
+	mi := &file_cache_service_cache_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FlushNamespaceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FlushNamespaceRequest) ProtoMessage() {}
+
+func (x *FlushNamespaceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FlushNamespaceRequest.ProtoReflect.Descriptor instead.
+func (*FlushNamespaceRequest) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *FlushNamespaceRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+type FlushNamespaceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Deleted       int64                  `protobuf:"varint,1,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FlushNamespaceResponse) Reset() {
+	*x = FlushNamespaceResponse{}
+	mi := &file_cache_service_cache_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FlushNamespaceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FlushNamespaceResponse) ProtoMessage() {}
+
+func (x *FlushNamespaceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_service_cache_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FlushNamespaceResponse.ProtoReflect.Descriptor instead.
+func (*FlushNamespaceResponse) Descriptor() ([]byte, []int) {
+	return file_cache_service_cache_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *FlushNamespaceResponse) GetDeleted() int64 {
+	if x != nil {
+		return x.Deleted
+	}
+	return 0
+}
+
+var File_cache_service_cache_proto protoreflect.FileDescriptor
+
+const file_cache_service_cache_proto_rawDesc = "" +
+	"\n" +
+	"\x19cache-service/cache.proto\x12\x05cache\"<\n" +
+	"\n" +
+	"GetRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x1c\n" +
+	"\tnamespace\x18\x02 \x01(\tR\tnamespace\"O\n" +
+	"\vGetResponse\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\tR\x05value\x12\x14\n" +
+	"\x05found\x18\x02 \x01(\bR\x05found\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"s\n" +
+	"\n" +
+	"SetRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\x12\x1f\n" +
+	"\vttl_seconds\x18\x03 \x01(\x05R\n" +
+	"ttlSeconds\x12\x1c\n" +
+	"\tnamespace\x18\x04 \x01(\tR\tnamespace\"=\n" +
+	"\vSetResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"-\n" +
+	"\x13SetIfAbsentResponse\x12\x16\n" +
+	"\x06stored\x18\x01 \x01(\bR\x06stored\"?\n" +
+	"\rDeleteRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x1c\n" +
+	"\tnamespace\x18\x02 \x01(\tR\tnamespace\"Z\n" +
+	"\x0eDeleteResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x12\x18\n" +
+	"\aexisted\x18\x03 \x01(\bR\aexisted\":\n" +
+	"\vMSetRequest\x12+\n" +
+	"\aentries\x18\x01 \x03(\v2\x11.cache.SetRequestR\aentries\"(\n" +
+	"\fMSetResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\"?\n" +
+	"\rExistsRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x1c\n" +
+	"\tnamespace\x18\x02 \x01(\tR\tnamespace\"(\n" +
+	"\x0eExistsResponse\x12\x16\n" +
+	"\x06exists\x18\x01 \x01(\bR\x06exists\"?\n" +
+	"\vMGetRequest\x12\x12\n" +
+	"\x04keys\x18\x01 \x03(\tR\x04keys\x12\x1c\n" +
+	"\tnamespace\x18\x02 \x01(\tR\tnamespace\":\n" +
+	"\fMGetResponse\x12*\n" +
+	"\x06values\x18\x01 \x03(\v2\x12.cache.GetResponseR\x06values\"\x16\n" +
+	"\x14GetCacheStatsRequest\"\xbf\x03\n" +
+	"\x15GetCacheStatsResponse\x12\x12\n" +
+	"\x04hits\x18\x01 \x01(\x03R\x04hits\x12\x16\n" +
+	"\x06misses\x18\x02 \x01(\x03R\x06misses\x12\x12\n" +
+	"\x04sets\x18\x03 \x01(\x03R\x04sets\x12\x18\n" +
+	"\adeletes\x18\x04 \x01(\x03R\adeletes\x12\x1c\n" +
+	"\tevictions\x18\x05 \x01(\x03R\tevictions\x12\x18\n" +
+	"\aexpired\x18\x06 \x01(\x03R\aexpired\x12\x18\n" +
+	"\aentries\x18\a \x01(\x03R\aentries\x12!\n" +
+	"\fmemory_bytes\x18\b \x01(\x03R\vmemoryBytes\x12\x1b\n" +
+	"\thit_ratio\x18\t \x01(\x01R\bhitRatio\x12_\n" +
+	"\x11namespace_entries\x18\n" +
+	" \x03(\v22.cache.GetCacheStatsResponse.NamespaceEntriesEntryR\x10namespaceEntries\x12\x14\n" +
+	"\x05swept\x18\v \x01(\x03R\x05swept\x1aC\n" +
+	"\x15NamespaceEntriesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\x18\n" +
+	"\x16ResetCacheStatsRequest\"3\n" +
+	"\x17ResetCacheStatsResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\"5\n" +
+	"\x15FlushNamespaceRequest\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\"2\n" +
+	"\x16FlushNamespaceResponse\x12\x18\n" +
+	"\adeleted\x18\x01 \x01(\x03R\adeleted2\xe5\x04\n" +
+	"\fCacheService\x12,\n" +
+	"\x03Get\x12\x11.cache.GetRequest\x1a\x12.cache.GetResponse\x12,\n" +
+	"\x03Set\x12\x11.cache.SetRequest\x1a\x12.cache.SetResponse\x12<\n" +
+	"\vSetIfAbsent\x12\x11.cache.SetRequest\x1a\x1a.cache.SetIfAbsentResponse\x125\n" +
+	"\x06Delete\x12\x14.cache.DeleteRequest\x1a\x15.cache.DeleteResponse\x12/\n" +
+	"\x04MGet\x12\x12.cache.MGetRequest\x1a\x13.cache.MGetResponse\x125\n" +
+	"\x06Exists\x12\x14.cache.ExistsRequest\x1a\x15.cache.ExistsResponse\x12/\n" +
+	"\x04MSet\x12\x12.cache.MSetRequest\x1a\x13.cache.MSetResponse\x12J\n" +
+	"\rGetCacheStats\x12\x1b.cache.GetCacheStatsRequest\x1a\x1c.cache.GetCacheStatsResponse\x12P\n" +
+	"\x0fResetCacheStats\x12\x1d.cache.ResetCacheStatsRequest\x1a\x1e.cache.ResetCacheStatsResponse\x12M\n" +
+	"\x0eFlushNamespace\x12\x1c.cache.FlushNamespaceRequest\x1a\x1d.cache.FlushNamespaceResponseB\x11Z\x0f./cache-serviceb\x06proto3"
+
+var (
+	file_cache_service_cache_proto_rawDescOnce sync.Once
+	file_cache_service_cache_proto_rawDescData []byte
+)
+
+func file_cache_service_cache_proto_rawDescGZIP() []byte {
+	file_cache_service_cache_proto_rawDescOnce.Do(func() {
+		file_cache_service_cache_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_cache_service_cache_proto_rawDesc), len(file_cache_service_cache_proto_rawDesc)))
+	})
+	return file_cache_service_cache_proto_rawDescData
+}
+
+var file_cache_service_cache_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
+var file_cache_service_cache_proto_goTypes = []any{
+	(*GetRequest)(nil),              // 0: cache.GetRequest
+	(*GetResponse)(nil),             // 1: cache.GetResponse
+	(*SetRequest)(nil),              // 2: cache.SetRequest
+	(*SetResponse)(nil),             // 3: cache.SetResponse
+	(*SetIfAbsentResponse)(nil),     // 4: cache.SetIfAbsentResponse
+	(*DeleteRequest)(nil),           // 5: cache.DeleteRequest
+	(*DeleteResponse)(nil),          // 6: cache.DeleteResponse
+	(*MSetRequest)(nil),             // 7: cache.MSetRequest
+	(*MSetResponse)(nil),            // 8: cache.MSetResponse
+	(*ExistsRequest)(nil),           // 9: cache.ExistsRequest
+	(*ExistsResponse)(nil),          // 10: cache.ExistsResponse
+	(*MGetRequest)(nil),             // 11: cache.MGetRequest
+	(*MGetResponse)(nil),            // 12: cache.MGetResponse
+	(*GetCacheStatsRequest)(nil),    // 13: cache.GetCacheStatsRequest
+	(*GetCacheStatsResponse)(nil),   // 14: cache.GetCacheStatsResponse
+	(*ResetCacheStatsRequest)(nil),  // 15: cache.ResetCacheStatsRequest
+	(*ResetCacheStatsResponse)(nil), // 16: cache.ResetCacheStatsResponse
+	(*FlushNamespaceRequest)(nil),   // 17: cache.FlushNamespaceRequest
+	(*FlushNamespaceResponse)(nil),  // 18: cache.FlushNamespaceResponse
+	nil,                             // 19: cache.GetCacheStatsResponse.NamespaceEntriesEntry
+}
+var file_cache_service_cache_proto_depIdxs = []int32{
+	2,  // 0: cache.MSetRequest.entries:type_name -> cache.SetRequest
+	1,  // 1: cache.MGetResponse.values:type_name -> cache.GetResponse
+	19, // 2: cache.GetCacheStatsResponse.namespace_entries:type_name -> cache.GetCacheStatsResponse.NamespaceEntriesEntry
+	0,  // 3: cache.CacheService.Get:input_type -> cache.GetRequest
+	2,  // 4: cache.CacheService.Set:input_type -> cache.SetRequest
+	2,  // 5: cache.CacheService.SetIfAbsent:input_type -> cache.SetRequest
+	5,  // 6: cache.CacheService.Delete:input_type -> cache.DeleteRequest
+	11, // 7: cache.CacheService.MGet:input_type -> cache.MGetRequest
+	9,  // 8: cache.CacheService.Exists:input_type -> cache.ExistsRequest
+	7,  // 9: cache.CacheService.MSet:input_type -> cache.MSetRequest
+	13, // 10: cache.CacheService.GetCacheStats:input_type -> cache.GetCacheStatsRequest
+	15, // 11: cache.CacheService.ResetCacheStats:input_type -> cache.ResetCacheStatsRequest
+	17, // 12: cache.CacheService.FlushNamespace:input_type -> cache.FlushNamespaceRequest
+	1,  // 13: cache.CacheService.Get:output_type -> cache.GetResponse
+	3,  // 14: cache.CacheService.Set:output_type -> cache.SetResponse
+	4,  // 15: cache.CacheService.SetIfAbsent:output_type -> cache.SetIfAbsentResponse
+	6,  // 16: cache.CacheService.Delete:output_type -> cache.DeleteResponse
+	12, // 17: cache.CacheService.MGet:output_type -> cache.MGetResponse
+	10, // 18: cache.CacheService.Exists:output_type -> cache.ExistsResponse
+	8,  // 19: cache.CacheService.MSet:output_type -> cache.MSetResponse
+	14, // 20: cache.CacheService.GetCacheStats:output_type -> cache.GetCacheStatsResponse
+	16, // 21: cache.CacheService.ResetCacheStats:output_type -> cache.ResetCacheStatsResponse
+	18, // 22: cache.CacheService.FlushNamespace:output_type -> cache.FlushNamespaceResponse
+	13, // [13:23] is the sub-list for method output_type
+	3,  // [3:13] is the sub-list for method input_type
+	3,  // [3:3] is the sub-list for extension type_name
+	3,  // [3:3] is the sub-list for extension extendee
+	0,  // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_cache_service_cache_proto_init() }
+func file_cache_service_cache_proto_init() {
+	if File_cache_service_cache_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_cache_service_cache_proto_rawDesc), len(file_cache_service_cache_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   20,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_cache_service_cache_proto_goTypes,
+		DependencyIndexes: file_cache_service_cache_proto_depIdxs,
+		MessageInfos:      file_cache_service_cache_proto_msgTypes,
+	}.Build()
+	File_cache_service_cache_proto = out.File
+	file_cache_service_cache_proto_goTypes = nil
+	file_cache_service_cache_proto_depIdxs = nil
+}
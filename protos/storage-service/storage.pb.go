@@ -0,0 +1,5869 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        v6.33.0
+// source: storage-service/storage.proto
+
+package storage_service
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SaveURLRequest struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode           string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	OriginalUrl         string                 `protobuf:"bytes,2,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	ExpectedOriginalUrl string                 `protobuf:"bytes,3,opt,name=expected_original_url,json=expectedOriginalUrl,proto3" json:"expected_original_url,omitempty"` // Optional, only overwrite if the stored URL matches
+	ExpiresAt           string                 `protobuf:"bytes,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`                                 // Optional RFC3339 expiry, empty keeps the current expiry
+	ApiKeyId            string                 `protobuf:"bytes,5,opt,name=api_key_id,json=apiKeyId,proto3" json:"api_key_id,omitempty"`                                  // Optional ID of the API key that created the URL, only set on insert
+	UserId              string                 `protobuf:"bytes,6,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`                                          // Optional owner of the URL, only set on insert
+	Resurrect           bool                   `protobuf:"varint,7,opt,name=resurrect,proto3" json:"resurrect,omitempty"`                                                 // Replace a deleted URL with the same code instead of failing with AlreadyExists
+	MaxClicks           int64                  `protobuf:"varint,8,opt,name=max_clicks,json=maxClicks,proto3" json:"max_clicks,omitempty"`                                // Optional number of clicks after which the URL stops resolving, only set on insert
+	FallbackUrl         string                 `protobuf:"bytes,9,opt,name=fallback_url,json=fallbackUrl,proto3" json:"fallback_url,omitempty"`                           // Optional destination once the URL has expired, reached max_clicks, been disabled or been deleted
+	NotBefore           string                 `protobuf:"bytes,10,opt,name=not_before,json=notBefore,proto3" json:"not_before,omitempty"`                                // Optional RFC3339 time before which the URL doesn't resolve, only set on insert
+	ComingSoonUrl       string                 `protobuf:"bytes,11,opt,name=coming_soon_url,json=comingSoonUrl,proto3" json:"coming_soon_url,omitempty"`                  // Optional destination before not_before
+	Variants            []*Variant             `protobuf:"bytes,12,rep,name=variants,proto3" json:"variants,omitempty"`                                                   // Optional weighted destinations of a split link, replacing any it had; original_url is the first's
+	StickyVariants      bool                   `protobuf:"varint,13,opt,name=sticky_variants,json=stickyVariants,proto3" json:"sticky_variants,omitempty"`                // Keep each visitor on one variant
+	Rules               []*RedirectRule        `protobuf:"bytes,14,rep,name=rules,proto3" json:"rules,omitempty"`                                                         // Optional conditional destinations, replacing any it had
+	QueryTemplate       string                 `protobuf:"bytes,15,opt,name=query_template,json=queryTemplate,proto3" json:"query_template,omitempty"`                    // Optional query parameters added on redirect, replacing any it had
+	Tags                []string               `protobuf:"bytes,16,rep,name=tags,proto3" json:"tags,omitempty"`                                                           // Normalized tags, see set_tags
+	SetTags             bool                   `protobuf:"varint,17,opt,name=set_tags,json=setTags,proto3" json:"set_tags,omitempty"`                                     // Replace the URL's tags with tags; without it they are only written for a new or recreated URL
+	TenantId            string                 `protobuf:"bytes,18,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`                                   // Tenant the URL belongs to, only set on insert; short_code already carries it
+	CreateOnly          bool                   `protobuf:"varint,19,opt,name=create_only,json=createOnly,proto3" json:"create_only,omitempty"`                            // Fail with AlreadyExists instead of overwriting a live URL with the same code
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *SaveURLRequest) Reset() {
+	*x = SaveURLRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SaveURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SaveURLRequest) ProtoMessage() {}
+
+func (x *SaveURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SaveURLRequest.ProtoReflect.Descriptor instead.
+func (*SaveURLRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SaveURLRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *SaveURLRequest) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *SaveURLRequest) GetExpectedOriginalUrl() string {
+	if x != nil {
+		return x.ExpectedOriginalUrl
+	}
+	return ""
+}
+
+func (x *SaveURLRequest) GetExpiresAt() string {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return ""
+}
+
+func (x *SaveURLRequest) GetApiKeyId() string {
+	if x != nil {
+		return x.ApiKeyId
+	}
+	return ""
+}
+
+func (x *SaveURLRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *SaveURLRequest) GetResurrect() bool {
+	if x != nil {
+		return x.Resurrect
+	}
+	return false
+}
+
+func (x *SaveURLRequest) GetMaxClicks() int64 {
+	if x != nil {
+		return x.MaxClicks
+	}
+	return 0
+}
+
+func (x *SaveURLRequest) GetFallbackUrl() string {
+	if x != nil {
+		return x.FallbackUrl
+	}
+	return ""
+}
+
+func (x *SaveURLRequest) GetNotBefore() string {
+	if x != nil {
+		return x.NotBefore
+	}
+	return ""
+}
+
+func (x *SaveURLRequest) GetComingSoonUrl() string {
+	if x != nil {
+		return x.ComingSoonUrl
+	}
+	return ""
+}
+
+func (x *SaveURLRequest) GetVariants() []*Variant {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+func (x *SaveURLRequest) GetStickyVariants() bool {
+	if x != nil {
+		return x.StickyVariants
+	}
+	return false
+}
+
+func (x *SaveURLRequest) GetRules() []*RedirectRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *SaveURLRequest) GetQueryTemplate() string {
+	if x != nil {
+		return x.QueryTemplate
+	}
+	return ""
+}
+
+func (x *SaveURLRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *SaveURLRequest) GetSetTags() bool {
+	if x != nil {
+		return x.SetTags
+	}
+	return false
+}
+
+func (x *SaveURLRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *SaveURLRequest) GetCreateOnly() bool {
+	if x != nil {
+		return x.CreateOnly
+	}
+	return false
+}
+
+// RedirectRule is one conditional destination of a link, tried in order.
+type RedirectRule struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Device        string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`       // Empty matches any
+	Countries     []string               `protobuf:"bytes,2,rep,name=countries,proto3" json:"countries,omitempty"` // Empty matches any
+	Url           string                 `protobuf:"bytes,3,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RedirectRule) Reset() {
+	*x = RedirectRule{}
+	mi := &file_storage_service_storage_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RedirectRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RedirectRule) ProtoMessage() {}
+
+func (x *RedirectRule) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RedirectRule.ProtoReflect.Descriptor instead.
+func (*RedirectRule) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RedirectRule) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+func (x *RedirectRule) GetCountries() []string {
+	if x != nil {
+		return x.Countries
+	}
+	return nil
+}
+
+func (x *RedirectRule) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+// Variant is one weighted destination of a split link.
+type Variant struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Weight        int32                  `protobuf:"varint,3,opt,name=weight,proto3" json:"weight,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Variant) Reset() {
+	*x = Variant{}
+	mi := &file_storage_service_storage_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Variant) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Variant) ProtoMessage() {}
+
+func (x *Variant) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Variant.ProtoReflect.Descriptor instead.
+func (*Variant) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Variant) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Variant) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Variant) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+type SaveURLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SaveURLResponse) Reset() {
+	*x = SaveURLResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SaveURLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SaveURLResponse) ProtoMessage() {}
+
+func (x *SaveURLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SaveURLResponse.ProtoReflect.Descriptor instead.
+func (*SaveURLResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SaveURLResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *SaveURLResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type GetURLRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode      string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	IncludeExpired bool                   `protobuf:"varint,2,opt,name=include_expired,json=includeExpired,proto3" json:"include_expired,omitempty"` // Return expired URLs instead of treating them as not found
+	ForcePrimary   bool                   `protobuf:"varint,3,opt,name=force_primary,json=forcePrimary,proto3" json:"force_primary,omitempty"`       // Read from the primary, not a replica that may lag behind a recent write
+	IncludeDeleted bool                   `protobuf:"varint,4,opt,name=include_deleted,json=includeDeleted,proto3" json:"include_deleted,omitempty"` // Return deleted URLs until they are purged instead of treating them as not found
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetURLRequest) Reset() {
+	*x = GetURLRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetURLRequest) ProtoMessage() {}
+
+func (x *GetURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetURLRequest.ProtoReflect.Descriptor instead.
+func (*GetURLRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetURLRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *GetURLRequest) GetIncludeExpired() bool {
+	if x != nil {
+		return x.IncludeExpired
+	}
+	return false
+}
+
+func (x *GetURLRequest) GetForcePrimary() bool {
+	if x != nil {
+		return x.ForcePrimary
+	}
+	return false
+}
+
+func (x *GetURLRequest) GetIncludeDeleted() bool {
+	if x != nil {
+		return x.IncludeDeleted
+	}
+	return false
+}
+
+type GetURLResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	OriginalUrl    string                 `protobuf:"bytes,1,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	Found          bool                   `protobuf:"varint,2,opt,name=found,proto3" json:"found,omitempty"`
+	Error          string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	ExpiresAt      string                 `protobuf:"bytes,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	ClickCount     int64                  `protobuf:"varint,5,opt,name=click_count,json=clickCount,proto3" json:"click_count,omitempty"`
+	CreatedAt      string                 `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UserId         string                 `protobuf:"bytes,7,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	MaxClicks      int64                  `protobuf:"varint,8,opt,name=max_clicks,json=maxClicks,proto3" json:"max_clicks,omitempty"` // 0 if unlimited
+	NotBefore      string                 `protobuf:"bytes,9,opt,name=not_before,json=notBefore,proto3" json:"not_before,omitempty"`
+	ComingSoonUrl  string                 `protobuf:"bytes,10,opt,name=coming_soon_url,json=comingSoonUrl,proto3" json:"coming_soon_url,omitempty"`
+	FallbackUrl    string                 `protobuf:"bytes,11,opt,name=fallback_url,json=fallbackUrl,proto3" json:"fallback_url,omitempty"`
+	Disabled       bool                   `protobuf:"varint,12,opt,name=disabled,proto3" json:"disabled,omitempty"` // Turned off with SetURLStatus
+	Variants       []*Variant             `protobuf:"bytes,13,rep,name=variants,proto3" json:"variants,omitempty"`  // Set for split links, in order
+	StickyVariants bool                   `protobuf:"varint,14,opt,name=sticky_variants,json=stickyVariants,proto3" json:"sticky_variants,omitempty"`
+	Rules          []*RedirectRule        `protobuf:"bytes,15,rep,name=rules,proto3" json:"rules,omitempty"` // In order
+	QueryTemplate  string                 `protobuf:"bytes,16,opt,name=query_template,json=queryTemplate,proto3" json:"query_template,omitempty"`
+	Deleted        bool                   `protobuf:"varint,17,opt,name=deleted,proto3" json:"deleted,omitempty"` // Set with include_deleted
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetURLResponse) Reset() {
+	*x = GetURLResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetURLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetURLResponse) ProtoMessage() {}
+
+func (x *GetURLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetURLResponse.ProtoReflect.Descriptor instead.
+func (*GetURLResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetURLResponse) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *GetURLResponse) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+func (x *GetURLResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *GetURLResponse) GetExpiresAt() string {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return ""
+}
+
+func (x *GetURLResponse) GetClickCount() int64 {
+	if x != nil {
+		return x.ClickCount
+	}
+	return 0
+}
+
+func (x *GetURLResponse) GetCreatedAt() string {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return ""
+}
+
+func (x *GetURLResponse) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *GetURLResponse) GetMaxClicks() int64 {
+	if x != nil {
+		return x.MaxClicks
+	}
+	return 0
+}
+
+func (x *GetURLResponse) GetNotBefore() string {
+	if x != nil {
+		return x.NotBefore
+	}
+	return ""
+}
+
+func (x *GetURLResponse) GetComingSoonUrl() string {
+	if x != nil {
+		return x.ComingSoonUrl
+	}
+	return ""
+}
+
+func (x *GetURLResponse) GetFallbackUrl() string {
+	if x != nil {
+		return x.FallbackUrl
+	}
+	return ""
+}
+
+func (x *GetURLResponse) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+func (x *GetURLResponse) GetVariants() []*Variant {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+func (x *GetURLResponse) GetStickyVariants() bool {
+	if x != nil {
+		return x.StickyVariants
+	}
+	return false
+}
+
+func (x *GetURLResponse) GetRules() []*RedirectRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *GetURLResponse) GetQueryTemplate() string {
+	if x != nil {
+		return x.QueryTemplate
+	}
+	return ""
+}
+
+func (x *GetURLResponse) GetDeleted() bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return false
+}
+
+type IncrementClickRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IncrementClickRequest) Reset() {
+	*x = IncrementClickRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IncrementClickRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IncrementClickRequest) ProtoMessage() {}
+
+func (x *IncrementClickRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IncrementClickRequest.ProtoReflect.Descriptor instead.
+func (*IncrementClickRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *IncrementClickRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+type IncrementClickResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IncrementClickResponse) Reset() {
+	*x = IncrementClickResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IncrementClickResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IncrementClickResponse) ProtoMessage() {}
+
+func (x *IncrementClickResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IncrementClickResponse.ProtoReflect.Descriptor instead.
+func (*IncrementClickResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *IncrementClickResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *IncrementClickResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type GetStatsRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode      string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	IncludeDeleted bool                   `protobuf:"varint,2,opt,name=include_deleted,json=includeDeleted,proto3" json:"include_deleted,omitempty"` // Return deleted URLs instead of treating them as not found
+	ForcePrimary   bool                   `protobuf:"varint,3,opt,name=force_primary,json=forcePrimary,proto3" json:"force_primary,omitempty"`       // Read from the primary, not a replica that may lag behind a recent write
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetStatsRequest) Reset() {
+	*x = GetStatsRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsRequest) ProtoMessage() {}
+
+func (x *GetStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetStatsRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetStatsRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *GetStatsRequest) GetIncludeDeleted() bool {
+	if x != nil {
+		return x.IncludeDeleted
+	}
+	return false
+}
+
+func (x *GetStatsRequest) GetForcePrimary() bool {
+	if x != nil {
+		return x.ForcePrimary
+	}
+	return false
+}
+
+type GetStatsResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode      string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	ClickCount     int64                  `protobuf:"varint,2,opt,name=click_count,json=clickCount,proto3" json:"click_count,omitempty"`
+	CreatedAt      string                 `protobuf:"bytes,3,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Error          string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	ExpiresAt      string                 `protobuf:"bytes,5,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	DeletedAt      string                 `protobuf:"bytes,6,opt,name=deleted_at,json=deletedAt,proto3" json:"deleted_at,omitempty"` // Empty unless the URL was deleted
+	UniqueClicks   int64                  `protobuf:"varint,7,opt,name=unique_clicks,json=uniqueClicks,proto3" json:"unique_clicks,omitempty"`
+	BotClicks      int64                  `protobuf:"varint,8,opt,name=bot_clicks,json=botClicks,proto3" json:"bot_clicks,omitempty"`                 // Not included in click_count
+	LastAccessedAt string                 `protobuf:"bytes,9,opt,name=last_accessed_at,json=lastAccessedAt,proto3" json:"last_accessed_at,omitempty"` // Latest click by a person, empty if there has been none
+	Title          string                 `protobuf:"bytes,10,opt,name=title,proto3" json:"title,omitempty"`                                          // From the destination page, empty until fetched
+	Description    string                 `protobuf:"bytes,11,opt,name=description,proto3" json:"description,omitempty"`
+	ImageUrl       string                 `protobuf:"bytes,12,opt,name=image_url,json=imageUrl,proto3" json:"image_url,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetStatsResponse) Reset() {
+	*x = GetStatsResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsResponse) ProtoMessage() {}
+
+func (x *GetStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetStatsResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetStatsResponse) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *GetStatsResponse) GetClickCount() int64 {
+	if x != nil {
+		return x.ClickCount
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetCreatedAt() string {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return ""
+}
+
+func (x *GetStatsResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *GetStatsResponse) GetExpiresAt() string {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return ""
+}
+
+func (x *GetStatsResponse) GetDeletedAt() string {
+	if x != nil {
+		return x.DeletedAt
+	}
+	return ""
+}
+
+func (x *GetStatsResponse) GetUniqueClicks() int64 {
+	if x != nil {
+		return x.UniqueClicks
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetBotClicks() int64 {
+	if x != nil {
+		return x.BotClicks
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetLastAccessedAt() string {
+	if x != nil {
+		return x.LastAccessedAt
+	}
+	return ""
+}
+
+func (x *GetStatsResponse) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *GetStatsResponse) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *GetStatsResponse) GetImageUrl() string {
+	if x != nil {
+		return x.ImageUrl
+	}
+	return ""
+}
+
+type DeleteURLRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteURLRequest) Reset() {
+	*x = DeleteURLRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteURLRequest) ProtoMessage() {}
+
+func (x *DeleteURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteURLRequest.ProtoReflect.Descriptor instead.
+func (*DeleteURLRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeleteURLRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+type DeleteURLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteURLResponse) Reset() {
+	*x = DeleteURLResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteURLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteURLResponse) ProtoMessage() {}
+
+func (x *DeleteURLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteURLResponse.ProtoReflect.Descriptor instead.
+func (*DeleteURLResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *DeleteURLResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *DeleteURLResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type FindByOriginalURLRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OriginalUrl   string                 `protobuf:"bytes,1,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"` // The full URL, or a host such as evil.example in host mode
+	Limit         int32                  `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	Match         string                 `protobuf:"bytes,3,opt,name=match,proto3" json:"match,omitempty"` // "exact" (default) or "host", which also matches subdomains
+	PageToken     string                 `protobuf:"bytes,4,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	UnlimitedOnly bool                   `protobuf:"varint,5,opt,name=unlimited_only,json=unlimitedOnly,proto3" json:"unlimited_only,omitempty"` // Leave out URLs with max_clicks
+	ActiveOnly    bool                   `protobuf:"varint,6,opt,name=active_only,json=activeOnly,proto3" json:"active_only,omitempty"`          // Leave out disabled URLs
+	PlainOnly     bool                   `protobuf:"varint,7,opt,name=plain_only,json=plainOnly,proto3" json:"plain_only,omitempty"`             // Leave out split links and URLs with rules, a query template or a fallback URL
+	TenantId      string                 `protobuf:"bytes,8,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`                 // Only URLs of this tenant, empty for the default one
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FindByOriginalURLRequest) Reset() {
+	*x = FindByOriginalURLRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FindByOriginalURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FindByOriginalURLRequest) ProtoMessage() {}
+
+func (x *FindByOriginalURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FindByOriginalURLRequest.ProtoReflect.Descriptor instead.
+func (*FindByOriginalURLRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *FindByOriginalURLRequest) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *FindByOriginalURLRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *FindByOriginalURLRequest) GetMatch() string {
+	if x != nil {
+		return x.Match
+	}
+	return ""
+}
+
+func (x *FindByOriginalURLRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *FindByOriginalURLRequest) GetUnlimitedOnly() bool {
+	if x != nil {
+		return x.UnlimitedOnly
+	}
+	return false
+}
+
+func (x *FindByOriginalURLRequest) GetActiveOnly() bool {
+	if x != nil {
+		return x.ActiveOnly
+	}
+	return false
+}
+
+func (x *FindByOriginalURLRequest) GetPlainOnly() bool {
+	if x != nil {
+		return x.PlainOnly
+	}
+	return false
+}
+
+func (x *FindByOriginalURLRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type FindByOriginalURLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCodes    []string               `protobuf:"bytes,1,rep,name=short_codes,json=shortCodes,proto3" json:"short_codes,omitempty"` // Oldest first
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	NextPageToken string                 `protobuf:"bytes,3,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"` // Empty on the last page
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FindByOriginalURLResponse) Reset() {
+	*x = FindByOriginalURLResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FindByOriginalURLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FindByOriginalURLResponse) ProtoMessage() {}
+
+func (x *FindByOriginalURLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FindByOriginalURLResponse.ProtoReflect.Descriptor instead.
+func (*FindByOriginalURLResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *FindByOriginalURLResponse) GetShortCodes() []string {
+	if x != nil {
+		return x.ShortCodes
+	}
+	return nil
+}
+
+func (x *FindByOriginalURLResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *FindByOriginalURLResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type GetCleanupStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCleanupStatsRequest) Reset() {
+	*x = GetCleanupStatsRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCleanupStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCleanupStatsRequest) ProtoMessage() {}
+
+func (x *GetCleanupStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCleanupStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetCleanupStatsRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{14}
+}
+
+type GetCleanupStatsResponse struct {
+	state                    protoimpl.MessageState `protogen:"open.v1"`
+	TotalRowsCleaned         int64                  `protobuf:"varint,1,opt,name=total_rows_cleaned,json=totalRowsCleaned,proto3" json:"total_rows_cleaned,omitempty"`
+	Runs                     int64                  `protobuf:"varint,2,opt,name=runs,proto3" json:"runs,omitempty"`
+	LastRunAt                string                 `protobuf:"bytes,3,opt,name=last_run_at,json=lastRunAt,proto3" json:"last_run_at,omitempty"`
+	LastRunRowsCleaned       int64                  `protobuf:"varint,4,opt,name=last_run_rows_cleaned,json=lastRunRowsCleaned,proto3" json:"last_run_rows_cleaned,omitempty"`
+	LastError                string                 `protobuf:"bytes,5,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	TotalRowsPurged          int64                  `protobuf:"varint,6,opt,name=total_rows_purged,json=totalRowsPurged,proto3" json:"total_rows_purged,omitempty"` // Deleted URLs removed after the retention period
+	LastRunRowsPurged        int64                  `protobuf:"varint,7,opt,name=last_run_rows_purged,json=lastRunRowsPurged,proto3" json:"last_run_rows_purged,omitempty"`
+	TotalClickEventsPurged   int64                  `protobuf:"varint,8,opt,name=total_click_events_purged,json=totalClickEventsPurged,proto3" json:"total_click_events_purged,omitempty"` // Click events removed after the retention period
+	LastRunClickEventsPurged int64                  `protobuf:"varint,9,opt,name=last_run_click_events_purged,json=lastRunClickEventsPurged,proto3" json:"last_run_click_events_purged,omitempty"`
+	unknownFields            protoimpl.UnknownFields
+	sizeCache                protoimpl.SizeCache
+}
+
+func (x *GetCleanupStatsResponse) Reset() {
+	*x = GetCleanupStatsResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCleanupStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCleanupStatsResponse) ProtoMessage() {}
+
+func (x *GetCleanupStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCleanupStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetCleanupStatsResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *GetCleanupStatsResponse) GetTotalRowsCleaned() int64 {
+	if x != nil {
+		return x.TotalRowsCleaned
+	}
+	return 0
+}
+
+func (x *GetCleanupStatsResponse) GetRuns() int64 {
+	if x != nil {
+		return x.Runs
+	}
+	return 0
+}
+
+func (x *GetCleanupStatsResponse) GetLastRunAt() string {
+	if x != nil {
+		return x.LastRunAt
+	}
+	return ""
+}
+
+func (x *GetCleanupStatsResponse) GetLastRunRowsCleaned() int64 {
+	if x != nil {
+		return x.LastRunRowsCleaned
+	}
+	return 0
+}
+
+func (x *GetCleanupStatsResponse) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *GetCleanupStatsResponse) GetTotalRowsPurged() int64 {
+	if x != nil {
+		return x.TotalRowsPurged
+	}
+	return 0
+}
+
+func (x *GetCleanupStatsResponse) GetLastRunRowsPurged() int64 {
+	if x != nil {
+		return x.LastRunRowsPurged
+	}
+	return 0
+}
+
+func (x *GetCleanupStatsResponse) GetTotalClickEventsPurged() int64 {
+	if x != nil {
+		return x.TotalClickEventsPurged
+	}
+	return 0
+}
+
+func (x *GetCleanupStatsResponse) GetLastRunClickEventsPurged() int64 {
+	if x != nil {
+		return x.LastRunClickEventsPurged
+	}
+	return 0
+}
+
+type ClickDelta struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode      string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	Delta          int64                  `protobuf:"varint,2,opt,name=delta,proto3" json:"delta,omitempty"`
+	UniqueDelta    int64                  `protobuf:"varint,3,opt,name=unique_delta,json=uniqueDelta,proto3" json:"unique_delta,omitempty"`           // Clicks among delta from visitors new within url-service's dedup window
+	BotDelta       int64                  `protobuf:"varint,4,opt,name=bot_delta,json=botDelta,proto3" json:"bot_delta,omitempty"`                    // Bot and prefetch clicks, counted apart from delta
+	LastAccessedAt string                 `protobuf:"bytes,5,opt,name=last_accessed_at,json=lastAccessedAt,proto3" json:"last_accessed_at,omitempty"` // Optional RFC3339 time of the latest click by a person among these, kept if later than the stored one
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ClickDelta) Reset() {
+	*x = ClickDelta{}
+	mi := &file_storage_service_storage_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClickDelta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClickDelta) ProtoMessage() {}
+
+func (x *ClickDelta) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClickDelta.ProtoReflect.Descriptor instead.
+func (*ClickDelta) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ClickDelta) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *ClickDelta) GetDelta() int64 {
+	if x != nil {
+		return x.Delta
+	}
+	return 0
+}
+
+func (x *ClickDelta) GetUniqueDelta() int64 {
+	if x != nil {
+		return x.UniqueDelta
+	}
+	return 0
+}
+
+func (x *ClickDelta) GetBotDelta() int64 {
+	if x != nil {
+		return x.BotDelta
+	}
+	return 0
+}
+
+func (x *ClickDelta) GetLastAccessedAt() string {
+	if x != nil {
+		return x.LastAccessedAt
+	}
+	return ""
+}
+
+type BatchIncrementClicksRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Deltas        []*ClickDelta          `protobuf:"bytes,1,rep,name=deltas,proto3" json:"deltas,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchIncrementClicksRequest) Reset() {
+	*x = BatchIncrementClicksRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchIncrementClicksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchIncrementClicksRequest) ProtoMessage() {}
+
+func (x *BatchIncrementClicksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchIncrementClicksRequest.ProtoReflect.Descriptor instead.
+func (*BatchIncrementClicksRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *BatchIncrementClicksRequest) GetDeltas() []*ClickDelta {
+	if x != nil {
+		return x.Deltas
+	}
+	return nil
+}
+
+type BatchIncrementClicksResponse struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Updated            int64                  `protobuf:"varint,1,opt,name=updated,proto3" json:"updated,omitempty"`
+	MissingShortCodes  []string               `protobuf:"bytes,2,rep,name=missing_short_codes,json=missingShortCodes,proto3" json:"missing_short_codes,omitempty"` // Codes that don't exist in storage
+	Error              string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	FailedShortCodes   []string               `protobuf:"bytes,4,rep,name=failed_short_codes,json=failedShortCodes,proto3" json:"failed_short_codes,omitempty"`       // Not applied because their chunk failed, safe to retry
+	BufferedShortCodes []string               `protobuf:"bytes,5,rep,name=buffered_short_codes,json=bufferedShortCodes,proto3" json:"buffered_short_codes,omitempty"` // Not saved yet; their clicks are added once they are
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *BatchIncrementClicksResponse) Reset() {
+	*x = BatchIncrementClicksResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchIncrementClicksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchIncrementClicksResponse) ProtoMessage() {}
+
+func (x *BatchIncrementClicksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchIncrementClicksResponse.ProtoReflect.Descriptor instead.
+func (*BatchIncrementClicksResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *BatchIncrementClicksResponse) GetUpdated() int64 {
+	if x != nil {
+		return x.Updated
+	}
+	return 0
+}
+
+func (x *BatchIncrementClicksResponse) GetMissingShortCodes() []string {
+	if x != nil {
+		return x.MissingShortCodes
+	}
+	return nil
+}
+
+func (x *BatchIncrementClicksResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *BatchIncrementClicksResponse) GetFailedShortCodes() []string {
+	if x != nil {
+		return x.FailedShortCodes
+	}
+	return nil
+}
+
+func (x *BatchIncrementClicksResponse) GetBufferedShortCodes() []string {
+	if x != nil {
+		return x.BufferedShortCodes
+	}
+	return nil
+}
+
+type ListURLsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	PageSize      int32                  `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken     string                 `protobuf:"bytes,3,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	Tags          []string               `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty"`                         // Only URLs with every one of these normalized tags
+	TenantId      string                 `protobuf:"bytes,5,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"` // Only URLs of this tenant, empty for the default one
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListURLsRequest) Reset() {
+	*x = ListURLsRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListURLsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListURLsRequest) ProtoMessage() {}
+
+func (x *ListURLsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListURLsRequest.ProtoReflect.Descriptor instead.
+func (*ListURLsRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ListURLsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ListURLsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListURLsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListURLsRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *ListURLsRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type URLSummary struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode      string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	OriginalUrl    string                 `protobuf:"bytes,2,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	ClickCount     int64                  `protobuf:"varint,3,opt,name=click_count,json=clickCount,proto3" json:"click_count,omitempty"`
+	CreatedAt      string                 `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	ExpiresAt      string                 `protobuf:"bytes,5,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	MaxClicks      int64                  `protobuf:"varint,6,opt,name=max_clicks,json=maxClicks,proto3" json:"max_clicks,omitempty"` // 0 if unlimited
+	NotBefore      string                 `protobuf:"bytes,7,opt,name=not_before,json=notBefore,proto3" json:"not_before,omitempty"`
+	Disabled       bool                   `protobuf:"varint,8,opt,name=disabled,proto3" json:"disabled,omitempty"`
+	Split          bool                   `protobuf:"varint,9,opt,name=split,proto3" json:"split,omitempty"`              // Has variants, returned by GetURL
+	Conditional    bool                   `protobuf:"varint,10,opt,name=conditional,proto3" json:"conditional,omitempty"` // Has redirect rules, returned by GetURL
+	QueryTemplate  string                 `protobuf:"bytes,11,opt,name=query_template,json=queryTemplate,proto3" json:"query_template,omitempty"`
+	Tags           []string               `protobuf:"bytes,12,rep,name=tags,proto3" json:"tags,omitempty"`                                             // Sorted, set by ListURLs
+	LastAccessedAt string                 `protobuf:"bytes,13,opt,name=last_accessed_at,json=lastAccessedAt,proto3" json:"last_accessed_at,omitempty"` // Latest click by a person, empty if there has been none; set by ListURLs and ListStaleURLs
+	Title          string                 `protobuf:"bytes,14,opt,name=title,proto3" json:"title,omitempty"`                                           // From the destination page, set by ListURLs
+	Description    string                 `protobuf:"bytes,15,opt,name=description,proto3" json:"description,omitempty"`
+	ImageUrl       string                 `protobuf:"bytes,16,opt,name=image_url,json=imageUrl,proto3" json:"image_url,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *URLSummary) Reset() {
+	*x = URLSummary{}
+	mi := &file_storage_service_storage_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *URLSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*URLSummary) ProtoMessage() {}
+
+func (x *URLSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use URLSummary.ProtoReflect.Descriptor instead.
+func (*URLSummary) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *URLSummary) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *URLSummary) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *URLSummary) GetClickCount() int64 {
+	if x != nil {
+		return x.ClickCount
+	}
+	return 0
+}
+
+func (x *URLSummary) GetCreatedAt() string {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return ""
+}
+
+func (x *URLSummary) GetExpiresAt() string {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return ""
+}
+
+func (x *URLSummary) GetMaxClicks() int64 {
+	if x != nil {
+		return x.MaxClicks
+	}
+	return 0
+}
+
+func (x *URLSummary) GetNotBefore() string {
+	if x != nil {
+		return x.NotBefore
+	}
+	return ""
+}
+
+func (x *URLSummary) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+func (x *URLSummary) GetSplit() bool {
+	if x != nil {
+		return x.Split
+	}
+	return false
+}
+
+func (x *URLSummary) GetConditional() bool {
+	if x != nil {
+		return x.Conditional
+	}
+	return false
+}
+
+func (x *URLSummary) GetQueryTemplate() string {
+	if x != nil {
+		return x.QueryTemplate
+	}
+	return ""
+}
+
+func (x *URLSummary) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *URLSummary) GetLastAccessedAt() string {
+	if x != nil {
+		return x.LastAccessedAt
+	}
+	return ""
+}
+
+func (x *URLSummary) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *URLSummary) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *URLSummary) GetImageUrl() string {
+	if x != nil {
+		return x.ImageUrl
+	}
+	return ""
+}
+
+type ListURLsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Urls          []*URLSummary          `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"`                                          // Newest first
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"` // Empty on the last page
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListURLsResponse) Reset() {
+	*x = ListURLsResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListURLsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListURLsResponse) ProtoMessage() {}
+
+func (x *ListURLsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListURLsResponse.ProtoReflect.Descriptor instead.
+func (*ListURLsResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *ListURLsResponse) GetUrls() []*URLSummary {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+func (x *ListURLsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type ListStaleURLsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OlderThan     string                 `protobuf:"bytes,1,opt,name=older_than,json=olderThan,proto3" json:"older_than,omitempty"` // RFC3339 cutoff, links not clicked since, or created before it if never clicked, are stale
+	Limit         int32                  `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`                         // Defaults to and is capped at 100
+	TenantId      string                 `protobuf:"bytes,3,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`    // Only URLs of this tenant, empty for the default one
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListStaleURLsRequest) Reset() {
+	*x = ListStaleURLsRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListStaleURLsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListStaleURLsRequest) ProtoMessage() {}
+
+func (x *ListStaleURLsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListStaleURLsRequest.ProtoReflect.Descriptor instead.
+func (*ListStaleURLsRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *ListStaleURLsRequest) GetOlderThan() string {
+	if x != nil {
+		return x.OlderThan
+	}
+	return ""
+}
+
+func (x *ListStaleURLsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListStaleURLsRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type ListStaleURLsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Urls          []*URLSummary          `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"` // Longest unclicked first
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListStaleURLsResponse) Reset() {
+	*x = ListStaleURLsResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListStaleURLsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListStaleURLsResponse) ProtoMessage() {}
+
+func (x *ListStaleURLsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListStaleURLsResponse.ProtoReflect.Descriptor instead.
+func (*ListStaleURLsResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ListStaleURLsResponse) GetUrls() []*URLSummary {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+type ListTagsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	TenantId      string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTagsRequest) Reset() {
+	*x = ListTagsRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTagsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTagsRequest) ProtoMessage() {}
+
+func (x *ListTagsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTagsRequest.ProtoReflect.Descriptor instead.
+func (*ListTagsRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *ListTagsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ListTagsRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type TagCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tag           string                 `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"`
+	Urls          int64                  `protobuf:"varint,2,opt,name=urls,proto3" json:"urls,omitempty"` // URLs of the user with the tag, not counting deleted ones
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TagCount) Reset() {
+	*x = TagCount{}
+	mi := &file_storage_service_storage_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TagCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TagCount) ProtoMessage() {}
+
+func (x *TagCount) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TagCount.ProtoReflect.Descriptor instead.
+func (*TagCount) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *TagCount) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *TagCount) GetUrls() int64 {
+	if x != nil {
+		return x.Urls
+	}
+	return 0
+}
+
+type ListTagsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tags          []*TagCount            `protobuf:"bytes,1,rep,name=tags,proto3" json:"tags,omitempty"` // Most used first, then by name
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTagsResponse) Reset() {
+	*x = ListTagsResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTagsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTagsResponse) ProtoMessage() {}
+
+func (x *ListTagsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTagsResponse.ProtoReflect.Descriptor instead.
+func (*ListTagsResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *ListTagsResponse) GetTags() []*TagCount {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type CountURLsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	TenantId      string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CountURLsRequest) Reset() {
+	*x = CountURLsRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CountURLsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountURLsRequest) ProtoMessage() {}
+
+func (x *CountURLsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountURLsRequest.ProtoReflect.Descriptor instead.
+func (*CountURLsRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *CountURLsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *CountURLsRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type CountURLsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Active        int64                  `protobuf:"varint,1,opt,name=active,proto3" json:"active,omitempty"` // URLs that have not expired
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CountURLsResponse) Reset() {
+	*x = CountURLsResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CountURLsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountURLsResponse) ProtoMessage() {}
+
+func (x *CountURLsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountURLsResponse.ProtoReflect.Descriptor instead.
+func (*CountURLsResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *CountURLsResponse) GetActive() int64 {
+	if x != nil {
+		return x.Active
+	}
+	return 0
+}
+
+type SaveURLsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Urls          []*SaveURLRequest      `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"` // expected_original_url is ignored, existing codes are never overwritten
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SaveURLsRequest) Reset() {
+	*x = SaveURLsRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SaveURLsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SaveURLsRequest) ProtoMessage() {}
+
+func (x *SaveURLsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SaveURLsRequest.ProtoReflect.Descriptor instead.
+func (*SaveURLsRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *SaveURLsRequest) GetUrls() []*SaveURLRequest {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+type SaveURLsResponse struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	InsertedShortCodes []string               `protobuf:"bytes,1,rep,name=inserted_short_codes,json=insertedShortCodes,proto3" json:"inserted_short_codes,omitempty"` // Codes in neither list already existed
+	FailedShortCodes   []string               `protobuf:"bytes,2,rep,name=failed_short_codes,json=failedShortCodes,proto3" json:"failed_short_codes,omitempty"`       // Not saved because their chunk failed, safe to retry
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *SaveURLsResponse) Reset() {
+	*x = SaveURLsResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SaveURLsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SaveURLsResponse) ProtoMessage() {}
+
+func (x *SaveURLsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SaveURLsResponse.ProtoReflect.Descriptor instead.
+func (*SaveURLsResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *SaveURLsResponse) GetInsertedShortCodes() []string {
+	if x != nil {
+		return x.InsertedShortCodes
+	}
+	return nil
+}
+
+func (x *SaveURLsResponse) GetFailedShortCodes() []string {
+	if x != nil {
+		return x.FailedShortCodes
+	}
+	return nil
+}
+
+type GetURLsRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ShortCodes     []string               `protobuf:"bytes,1,rep,name=short_codes,json=shortCodes,proto3" json:"short_codes,omitempty"`
+	IncludeExpired bool                   `protobuf:"varint,2,opt,name=include_expired,json=includeExpired,proto3" json:"include_expired,omitempty"` // Return expired URLs instead of treating them as not found
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetURLsRequest) Reset() {
+	*x = GetURLsRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetURLsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetURLsRequest) ProtoMessage() {}
+
+func (x *GetURLsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetURLsRequest.ProtoReflect.Descriptor instead.
+func (*GetURLsRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *GetURLsRequest) GetShortCodes() []string {
+	if x != nil {
+		return x.ShortCodes
+	}
+	return nil
+}
+
+func (x *GetURLsRequest) GetIncludeExpired() bool {
+	if x != nil {
+		return x.IncludeExpired
+	}
+	return false
+}
+
+type GetURLsResponse struct {
+	state         protoimpl.MessageState     `protogen:"open.v1"`
+	Urls          map[string]*GetURLResponse `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // Keyed by short code, codes not found are absent
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetURLsResponse) Reset() {
+	*x = GetURLsResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetURLsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetURLsResponse) ProtoMessage() {}
+
+func (x *GetURLsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetURLsResponse.ProtoReflect.Descriptor instead.
+func (*GetURLsResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *GetURLsResponse) GetUrls() map[string]*GetURLResponse {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+type GetTopURLsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Limit         int32                  `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	OrderBy       string                 `protobuf:"bytes,2,opt,name=order_by,json=orderBy,proto3" json:"order_by,omitempty"`    // "clicks" (default) or "recent" for the most recently updated
+	Since         string                 `protobuf:"bytes,3,opt,name=since,proto3" json:"since,omitempty"`                       // Optional RFC3339, ranks by click events since then instead of all-time clicks, only with order_by clicks
+	TenantId      string                 `protobuf:"bytes,4,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"` // Only URLs of this tenant, empty for the default one
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTopURLsRequest) Reset() {
+	*x = GetTopURLsRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTopURLsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTopURLsRequest) ProtoMessage() {}
+
+func (x *GetTopURLsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTopURLsRequest.ProtoReflect.Descriptor instead.
+func (*GetTopURLsRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *GetTopURLsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *GetTopURLsRequest) GetOrderBy() string {
+	if x != nil {
+		return x.OrderBy
+	}
+	return ""
+}
+
+func (x *GetTopURLsRequest) GetSince() string {
+	if x != nil {
+		return x.Since
+	}
+	return ""
+}
+
+func (x *GetTopURLsRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type GetTopURLsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Urls          []*URLSummary          `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"` // Unexpired URLs only, ties broken by short code. click_count counts clicks since since, when given
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTopURLsResponse) Reset() {
+	*x = GetTopURLsResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTopURLsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTopURLsResponse) ProtoMessage() {}
+
+func (x *GetTopURLsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTopURLsResponse.ProtoReflect.Descriptor instead.
+func (*GetTopURLsResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *GetTopURLsResponse) GetUrls() []*URLSummary {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+type GetGlobalStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TenantId      string                 `protobuf:"bytes,1,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"` // Only URLs of this tenant, empty for the default one
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetGlobalStatsRequest) Reset() {
+	*x = GetGlobalStatsRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetGlobalStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetGlobalStatsRequest) ProtoMessage() {}
+
+func (x *GetGlobalStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetGlobalStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetGlobalStatsRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *GetGlobalStatsRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type GetGlobalStatsResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	TotalUrls      int64                  `protobuf:"varint,1,opt,name=total_urls,json=totalUrls,proto3" json:"total_urls,omitempty"`       // Including deleted URLs that haven't been purged yet
+	TotalClicks    int64                  `protobuf:"varint,2,opt,name=total_clicks,json=totalClicks,proto3" json:"total_clicks,omitempty"` // Over total_urls
+	CreatedLastDay int64                  `protobuf:"varint,3,opt,name=created_last_day,json=createdLastDay,proto3" json:"created_last_day,omitempty"`
+	ActiveUrls     int64                  `protobuf:"varint,4,opt,name=active_urls,json=activeUrls,proto3" json:"active_urls,omitempty"` // Neither expired nor deleted
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetGlobalStatsResponse) Reset() {
+	*x = GetGlobalStatsResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetGlobalStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetGlobalStatsResponse) ProtoMessage() {}
+
+func (x *GetGlobalStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetGlobalStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetGlobalStatsResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *GetGlobalStatsResponse) GetTotalUrls() int64 {
+	if x != nil {
+		return x.TotalUrls
+	}
+	return 0
+}
+
+func (x *GetGlobalStatsResponse) GetTotalClicks() int64 {
+	if x != nil {
+		return x.TotalClicks
+	}
+	return 0
+}
+
+func (x *GetGlobalStatsResponse) GetCreatedLastDay() int64 {
+	if x != nil {
+		return x.CreatedLastDay
+	}
+	return 0
+}
+
+func (x *GetGlobalStatsResponse) GetActiveUrls() int64 {
+	if x != nil {
+		return x.ActiveUrls
+	}
+	return 0
+}
+
+type ClickEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	ClickedAt     string                 `protobuf:"bytes,2,opt,name=clicked_at,json=clickedAt,proto3" json:"clicked_at,omitempty"` // RFC3339, defaults to now
+	Referrer      string                 `protobuf:"bytes,3,opt,name=referrer,proto3" json:"referrer,omitempty"`                    // Optional
+	UserAgent     string                 `protobuf:"bytes,4,opt,name=user_agent,json=userAgent,proto3" json:"user_agent,omitempty"` // Optional
+	Country       string                 `protobuf:"bytes,5,opt,name=country,proto3" json:"country,omitempty"`                      // Optional ISO 3166-1 alpha-2 code
+	Bot           bool                   `protobuf:"varint,6,opt,name=bot,proto3" json:"bot,omitempty"`                             // Made by a bot or prefetch rather than a person
+	Variant       string                 `protobuf:"bytes,7,opt,name=variant,proto3" json:"variant,omitempty"`                      // Optional name of the variant of a split link the click was sent to
+	Rule          string                 `protobuf:"bytes,8,opt,name=rule,proto3" json:"rule,omitempty"`                            // Optional rule of the link the click matched, its number from 1 or "default"
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClickEvent) Reset() {
+	*x = ClickEvent{}
+	mi := &file_storage_service_storage_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClickEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClickEvent) ProtoMessage() {}
+
+func (x *ClickEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClickEvent.ProtoReflect.Descriptor instead.
+func (*ClickEvent) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *ClickEvent) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *ClickEvent) GetClickedAt() string {
+	if x != nil {
+		return x.ClickedAt
+	}
+	return ""
+}
+
+func (x *ClickEvent) GetReferrer() string {
+	if x != nil {
+		return x.Referrer
+	}
+	return ""
+}
+
+func (x *ClickEvent) GetUserAgent() string {
+	if x != nil {
+		return x.UserAgent
+	}
+	return ""
+}
+
+func (x *ClickEvent) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *ClickEvent) GetBot() bool {
+	if x != nil {
+		return x.Bot
+	}
+	return false
+}
+
+func (x *ClickEvent) GetVariant() string {
+	if x != nil {
+		return x.Variant
+	}
+	return ""
+}
+
+func (x *ClickEvent) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+// RecordClickRequest carries a batch of clicks. It only records the events;
+// click_count is maintained by IncrementClick and BatchIncrementClicks.
+type RecordClickRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Events        []*ClickEvent          `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecordClickRequest) Reset() {
+	*x = RecordClickRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordClickRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordClickRequest) ProtoMessage() {}
+
+func (x *RecordClickRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordClickRequest.ProtoReflect.Descriptor instead.
+func (*RecordClickRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *RecordClickRequest) GetEvents() []*ClickEvent {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+type RecordClickResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Recorded      int64                  `protobuf:"varint,1,opt,name=recorded,proto3" json:"recorded,omitempty"` // Events for unknown codes are dropped
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecordClickResponse) Reset() {
+	*x = RecordClickResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordClickResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordClickResponse) ProtoMessage() {}
+
+func (x *RecordClickResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordClickResponse.ProtoReflect.Descriptor instead.
+func (*RecordClickResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *RecordClickResponse) GetRecorded() int64 {
+	if x != nil {
+		return x.Recorded
+	}
+	return 0
+}
+
+type GetClickTimeSeriesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	Start         string                 `protobuf:"bytes,2,opt,name=start,proto3" json:"start,omitempty"`                                 // RFC3339, inclusive
+	End           string                 `protobuf:"bytes,3,opt,name=end,proto3" json:"end,omitempty"`                                     // RFC3339, exclusive
+	Bucket        string                 `protobuf:"bytes,4,opt,name=bucket,proto3" json:"bucket,omitempty"`                               // "hour" or "day" (default)
+	TimeZone      string                 `protobuf:"bytes,5,opt,name=time_zone,json=timeZone,proto3" json:"time_zone,omitempty"`           // IANA name that buckets are aligned to, defaults to UTC
+	IncludeBots   bool                   `protobuf:"varint,6,opt,name=include_bots,json=includeBots,proto3" json:"include_bots,omitempty"` // Count bot clicks too, which are left out by default
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetClickTimeSeriesRequest) Reset() {
+	*x = GetClickTimeSeriesRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetClickTimeSeriesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetClickTimeSeriesRequest) ProtoMessage() {}
+
+func (x *GetClickTimeSeriesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetClickTimeSeriesRequest.ProtoReflect.Descriptor instead.
+func (*GetClickTimeSeriesRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *GetClickTimeSeriesRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *GetClickTimeSeriesRequest) GetStart() string {
+	if x != nil {
+		return x.Start
+	}
+	return ""
+}
+
+func (x *GetClickTimeSeriesRequest) GetEnd() string {
+	if x != nil {
+		return x.End
+	}
+	return ""
+}
+
+func (x *GetClickTimeSeriesRequest) GetBucket() string {
+	if x != nil {
+		return x.Bucket
+	}
+	return ""
+}
+
+func (x *GetClickTimeSeriesRequest) GetTimeZone() string {
+	if x != nil {
+		return x.TimeZone
+	}
+	return ""
+}
+
+func (x *GetClickTimeSeriesRequest) GetIncludeBots() bool {
+	if x != nil {
+		return x.IncludeBots
+	}
+	return false
+}
+
+type ClickBucket struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         string                 `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"` // RFC3339 instant the bucket starts at
+	Clicks        int64                  `protobuf:"varint,2,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClickBucket) Reset() {
+	*x = ClickBucket{}
+	mi := &file_storage_service_storage_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClickBucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClickBucket) ProtoMessage() {}
+
+func (x *ClickBucket) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClickBucket.ProtoReflect.Descriptor instead.
+func (*ClickBucket) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *ClickBucket) GetStart() string {
+	if x != nil {
+		return x.Start
+	}
+	return ""
+}
+
+func (x *ClickBucket) GetClicks() int64 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+type GetClickTimeSeriesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Buckets       []*ClickBucket         `protobuf:"bytes,1,rep,name=buckets,proto3" json:"buckets,omitempty"` // Every bucket in the range, oldest first, including empty ones
+	TotalClicks   int64                  `protobuf:"varint,2,opt,name=total_clicks,json=totalClicks,proto3" json:"total_clicks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetClickTimeSeriesResponse) Reset() {
+	*x = GetClickTimeSeriesResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetClickTimeSeriesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetClickTimeSeriesResponse) ProtoMessage() {}
+
+func (x *GetClickTimeSeriesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetClickTimeSeriesResponse.ProtoReflect.Descriptor instead.
+func (*GetClickTimeSeriesResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *GetClickTimeSeriesResponse) GetBuckets() []*ClickBucket {
+	if x != nil {
+		return x.Buckets
+	}
+	return nil
+}
+
+func (x *GetClickTimeSeriesResponse) GetTotalClicks() int64 {
+	if x != nil {
+		return x.TotalClicks
+	}
+	return 0
+}
+
+type GetClickBreakdownRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	Limit         int32                  `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"` // Entries per breakdown, defaults to 10, at most 100
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetClickBreakdownRequest) Reset() {
+	*x = GetClickBreakdownRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetClickBreakdownRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetClickBreakdownRequest) ProtoMessage() {}
+
+func (x *GetClickBreakdownRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetClickBreakdownRequest.ProtoReflect.Descriptor instead.
+func (*GetClickBreakdownRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *GetClickBreakdownRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *GetClickBreakdownRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type BreakdownEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         string                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Clicks        int64                  `protobuf:"varint,2,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BreakdownEntry) Reset() {
+	*x = BreakdownEntry{}
+	mi := &file_storage_service_storage_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BreakdownEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BreakdownEntry) ProtoMessage() {}
+
+func (x *BreakdownEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BreakdownEntry.ProtoReflect.Descriptor instead.
+func (*BreakdownEntry) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *BreakdownEntry) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *BreakdownEntry) GetClicks() int64 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+// GetClickBreakdownResponse groups a URL's recorded click events, most
+// clicks first. Events without the value, e.g. direct visits without a
+// referrer, are left out.
+type GetClickBreakdownResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Referrers     []*BreakdownEntry      `protobuf:"bytes,1,rep,name=referrers,proto3" json:"referrers,omitempty"` // By host
+	Countries     []*BreakdownEntry      `protobuf:"bytes,2,rep,name=countries,proto3" json:"countries,omitempty"`
+	Browsers      []*BreakdownEntry      `protobuf:"bytes,3,rep,name=browsers,proto3" json:"browsers,omitempty"`
+	Devices       []*BreakdownEntry      `protobuf:"bytes,4,rep,name=devices,proto3" json:"devices,omitempty"`
+	Variants      []*BreakdownEntry      `protobuf:"bytes,5,rep,name=variants,proto3" json:"variants,omitempty"` // By variant name, for split links
+	Rules         []*BreakdownEntry      `protobuf:"bytes,6,rep,name=rules,proto3" json:"rules,omitempty"`       // By rule matched, for links with rules
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetClickBreakdownResponse) Reset() {
+	*x = GetClickBreakdownResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetClickBreakdownResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetClickBreakdownResponse) ProtoMessage() {}
+
+func (x *GetClickBreakdownResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetClickBreakdownResponse.ProtoReflect.Descriptor instead.
+func (*GetClickBreakdownResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *GetClickBreakdownResponse) GetReferrers() []*BreakdownEntry {
+	if x != nil {
+		return x.Referrers
+	}
+	return nil
+}
+
+func (x *GetClickBreakdownResponse) GetCountries() []*BreakdownEntry {
+	if x != nil {
+		return x.Countries
+	}
+	return nil
+}
+
+func (x *GetClickBreakdownResponse) GetBrowsers() []*BreakdownEntry {
+	if x != nil {
+		return x.Browsers
+	}
+	return nil
+}
+
+func (x *GetClickBreakdownResponse) GetDevices() []*BreakdownEntry {
+	if x != nil {
+		return x.Devices
+	}
+	return nil
+}
+
+func (x *GetClickBreakdownResponse) GetVariants() []*BreakdownEntry {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+func (x *GetClickBreakdownResponse) GetRules() []*BreakdownEntry {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+type ExportURLsRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	BatchSize      int32                  `protobuf:"varint,1,opt,name=batch_size,json=batchSize,proto3" json:"batch_size,omitempty"`                 // URLs per message, defaults to 500, at most 5000
+	CreatedAfter   string                 `protobuf:"bytes,2,opt,name=created_after,json=createdAfter,proto3" json:"created_after,omitempty"`         // Optional RFC3339, exports only URLs created after it
+	AfterShortCode string                 `protobuf:"bytes,3,opt,name=after_short_code,json=afterShortCode,proto3" json:"after_short_code,omitempty"` // Optional, resumes an interrupted export after this code
+	UserId         string                 `protobuf:"bytes,4,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`                           // Optional, exports only this user's URLs in tenant_id
+	TenantId       string                 `protobuf:"bytes,5,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`                     // With user_id, empty for the default tenant
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ExportURLsRequest) Reset() {
+	*x = ExportURLsRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportURLsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportURLsRequest) ProtoMessage() {}
+
+func (x *ExportURLsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[46]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportURLsRequest.ProtoReflect.Descriptor instead.
+func (*ExportURLsRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *ExportURLsRequest) GetBatchSize() int32 {
+	if x != nil {
+		return x.BatchSize
+	}
+	return 0
+}
+
+func (x *ExportURLsRequest) GetCreatedAfter() string {
+	if x != nil {
+		return x.CreatedAfter
+	}
+	return ""
+}
+
+func (x *ExportURLsRequest) GetAfterShortCode() string {
+	if x != nil {
+		return x.AfterShortCode
+	}
+	return ""
+}
+
+func (x *ExportURLsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ExportURLsRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type ExportedURL struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	OriginalUrl   string                 `protobuf:"bytes,2,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	CreatedAt     string                 `protobuf:"bytes,3,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"` // RFC3339 with fractional seconds
+	ClickCount    int64                  `protobuf:"varint,4,opt,name=click_count,json=clickCount,proto3" json:"click_count,omitempty"`
+	ExpiresAt     string                 `protobuf:"bytes,5,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	UserId        string                 `protobuf:"bytes,6,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	DeletedAt     string                 `protobuf:"bytes,7,opt,name=deleted_at,json=deletedAt,proto3" json:"deleted_at,omitempty"` // Empty unless the URL was deleted
+	TenantId      string                 `protobuf:"bytes,8,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`    // Empty for the default tenant
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportedURL) Reset() {
+	*x = ExportedURL{}
+	mi := &file_storage_service_storage_proto_msgTypes[47]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportedURL) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportedURL) ProtoMessage() {}
+
+func (x *ExportedURL) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[47]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportedURL.ProtoReflect.Descriptor instead.
+func (*ExportedURL) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{47}
+}
+
+func (x *ExportedURL) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *ExportedURL) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *ExportedURL) GetCreatedAt() string {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return ""
+}
+
+func (x *ExportedURL) GetClickCount() int64 {
+	if x != nil {
+		return x.ClickCount
+	}
+	return 0
+}
+
+func (x *ExportedURL) GetExpiresAt() string {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return ""
+}
+
+func (x *ExportedURL) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ExportedURL) GetDeletedAt() string {
+	if x != nil {
+		return x.DeletedAt
+	}
+	return ""
+}
+
+func (x *ExportedURL) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type ExportURLsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Urls          []*ExportedURL         `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"` // In short code order
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportURLsResponse) Reset() {
+	*x = ExportURLsResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[48]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportURLsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportURLsResponse) ProtoMessage() {}
+
+func (x *ExportURLsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[48]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportURLsResponse.ProtoReflect.Descriptor instead.
+func (*ExportURLsResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *ExportURLsResponse) GetUrls() []*ExportedURL {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+type ImportURLsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Urls          []*ExportedURL         `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"`                               // created_at defaults to now, the other optional fields to empty
+	DryRun        bool                   `protobuf:"varint,2,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`            // Validate and check for conflicts without writing, read from the first message
+	OnConflict    string                 `protobuf:"bytes,3,opt,name=on_conflict,json=onConflict,proto3" json:"on_conflict,omitempty"` // "skip" (default), "overwrite" or "fail" for codes that already exist, read from the first message
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportURLsRequest) Reset() {
+	*x = ImportURLsRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[49]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportURLsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportURLsRequest) ProtoMessage() {}
+
+func (x *ImportURLsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[49]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportURLsRequest.ProtoReflect.Descriptor instead.
+func (*ImportURLsRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{49}
+}
+
+func (x *ImportURLsRequest) GetUrls() []*ExportedURL {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+func (x *ImportURLsRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+func (x *ImportURLsRequest) GetOnConflict() string {
+	if x != nil {
+		return x.OnConflict
+	}
+	return ""
+}
+
+type ImportRejection struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int64                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"` // Position of the URL in the whole stream
+	ShortCode     string                 `protobuf:"bytes,2,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportRejection) Reset() {
+	*x = ImportRejection{}
+	mi := &file_storage_service_storage_proto_msgTypes[50]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportRejection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportRejection) ProtoMessage() {}
+
+func (x *ImportRejection) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[50]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportRejection.ProtoReflect.Descriptor instead.
+func (*ImportRejection) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{50}
+}
+
+func (x *ImportRejection) GetIndex() int64 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *ImportRejection) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *ImportRejection) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type ImportURLsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Inserted      int64                  `protobuf:"varint,1,opt,name=inserted,proto3" json:"inserted,omitempty"`
+	Updated       int64                  `protobuf:"varint,2,opt,name=updated,proto3" json:"updated,omitempty"`             // Existing codes overwritten
+	Skipped       int64                  `protobuf:"varint,3,opt,name=skipped,proto3" json:"skipped,omitempty"`             // Existing codes left alone
+	Rejected      int64                  `protobuf:"varint,4,opt,name=rejected,proto3" json:"rejected,omitempty"`           // URLs that failed validation
+	Rejections    []*ImportRejection     `protobuf:"bytes,5,rep,name=rejections,proto3" json:"rejections,omitempty"`        // The first 1000 rejections
+	DryRun        bool                   `protobuf:"varint,6,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"` // Counts are what the import would have done
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportURLsResponse) Reset() {
+	*x = ImportURLsResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[51]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportURLsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportURLsResponse) ProtoMessage() {}
+
+func (x *ImportURLsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[51]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportURLsResponse.ProtoReflect.Descriptor instead.
+func (*ImportURLsResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *ImportURLsResponse) GetInserted() int64 {
+	if x != nil {
+		return x.Inserted
+	}
+	return 0
+}
+
+func (x *ImportURLsResponse) GetUpdated() int64 {
+	if x != nil {
+		return x.Updated
+	}
+	return 0
+}
+
+func (x *ImportURLsResponse) GetSkipped() int64 {
+	if x != nil {
+		return x.Skipped
+	}
+	return 0
+}
+
+func (x *ImportURLsResponse) GetRejected() int64 {
+	if x != nil {
+		return x.Rejected
+	}
+	return 0
+}
+
+func (x *ImportURLsResponse) GetRejections() []*ImportRejection {
+	if x != nil {
+		return x.Rejections
+	}
+	return nil
+}
+
+func (x *ImportURLsResponse) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type AllocateIDRangeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Count         int64                  `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"` // IDs to reserve, at most 1000000
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AllocateIDRangeRequest) Reset() {
+	*x = AllocateIDRangeRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[52]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AllocateIDRangeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AllocateIDRangeRequest) ProtoMessage() {}
+
+func (x *AllocateIDRangeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[52]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AllocateIDRangeRequest.ProtoReflect.Descriptor instead.
+func (*AllocateIDRangeRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{52}
+}
+
+func (x *AllocateIDRangeRequest) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type AllocateIDRangeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         int64                  `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"` // First reserved ID
+	End           int64                  `protobuf:"varint,2,opt,name=end,proto3" json:"end,omitempty"`     // One past the last reserved ID
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AllocateIDRangeResponse) Reset() {
+	*x = AllocateIDRangeResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[53]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AllocateIDRangeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AllocateIDRangeResponse) ProtoMessage() {}
+
+func (x *AllocateIDRangeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[53]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AllocateIDRangeResponse.ProtoReflect.Descriptor instead.
+func (*AllocateIDRangeResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{53}
+}
+
+func (x *AllocateIDRangeResponse) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *AllocateIDRangeResponse) GetEnd() int64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+type PopKeysRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Count         int32                  `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"` // Keys to take from the pool, at most 1000
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PopKeysRequest) Reset() {
+	*x = PopKeysRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[54]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PopKeysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PopKeysRequest) ProtoMessage() {}
+
+func (x *PopKeysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[54]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PopKeysRequest.ProtoReflect.Descriptor instead.
+func (*PopKeysRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{54}
+}
+
+func (x *PopKeysRequest) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type PopKeysResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCodes    []string               `protobuf:"bytes,1,rep,name=short_codes,json=shortCodes,proto3" json:"short_codes,omitempty"` // Fewer than asked for, or none, when the pool runs low
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PopKeysResponse) Reset() {
+	*x = PopKeysResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[55]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PopKeysResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PopKeysResponse) ProtoMessage() {}
+
+func (x *PopKeysResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[55]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PopKeysResponse.ProtoReflect.Descriptor instead.
+func (*PopKeysResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{55}
+}
+
+func (x *PopKeysResponse) GetShortCodes() []string {
+	if x != nil {
+		return x.ShortCodes
+	}
+	return nil
+}
+
+type ClaimClickRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	Peek          bool                   `protobuf:"varint,2,opt,name=peek,proto3" json:"peek,omitempty"` // Only report whether a click is left, without using it
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClaimClickRequest) Reset() {
+	*x = ClaimClickRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[56]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClaimClickRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClaimClickRequest) ProtoMessage() {}
+
+func (x *ClaimClickRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[56]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClaimClickRequest.ProtoReflect.Descriptor instead.
+func (*ClaimClickRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{56}
+}
+
+func (x *ClaimClickRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *ClaimClickRequest) GetPeek() bool {
+	if x != nil {
+		return x.Peek
+	}
+	return false
+}
+
+type ClaimClickResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Claimed       bool                   `protobuf:"varint,1,opt,name=claimed,proto3" json:"claimed,omitempty"`                           // The click was counted, or with peek, one is left
+	Remaining     int64                  `protobuf:"varint,2,opt,name=remaining,proto3" json:"remaining,omitempty"`                       // Clicks left after this one, always 0 for URLs without max_clicks
+	FallbackUrl   string                 `protobuf:"bytes,3,opt,name=fallback_url,json=fallbackUrl,proto3" json:"fallback_url,omitempty"` // Set when not claimed and the URL has one
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClaimClickResponse) Reset() {
+	*x = ClaimClickResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[57]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClaimClickResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClaimClickResponse) ProtoMessage() {}
+
+func (x *ClaimClickResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[57]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClaimClickResponse.ProtoReflect.Descriptor instead.
+func (*ClaimClickResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{57}
+}
+
+func (x *ClaimClickResponse) GetClaimed() bool {
+	if x != nil {
+		return x.Claimed
+	}
+	return false
+}
+
+func (x *ClaimClickResponse) GetRemaining() int64 {
+	if x != nil {
+		return x.Remaining
+	}
+	return 0
+}
+
+func (x *ClaimClickResponse) GetFallbackUrl() string {
+	if x != nil {
+		return x.FallbackUrl
+	}
+	return ""
+}
+
+type SetURLStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	Active        bool                   `protobuf:"varint,2,opt,name=active,proto3" json:"active,omitempty"` // false disables the URL without deleting it, true enables it again
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetURLStatusRequest) Reset() {
+	*x = SetURLStatusRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[58]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetURLStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetURLStatusRequest) ProtoMessage() {}
+
+func (x *SetURLStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[58]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetURLStatusRequest.ProtoReflect.Descriptor instead.
+func (*SetURLStatusRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{58}
+}
+
+func (x *SetURLStatusRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *SetURLStatusRequest) GetActive() bool {
+	if x != nil {
+		return x.Active
+	}
+	return false
+}
+
+type SetURLStatusResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Active        bool                   `protobuf:"varint,1,opt,name=active,proto3" json:"active,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetURLStatusResponse) Reset() {
+	*x = SetURLStatusResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[59]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetURLStatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetURLStatusResponse) ProtoMessage() {}
+
+func (x *SetURLStatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[59]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetURLStatusResponse.ProtoReflect.Descriptor instead.
+func (*SetURLStatusResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{59}
+}
+
+func (x *SetURLStatusResponse) GetActive() bool {
+	if x != nil {
+		return x.Active
+	}
+	return false
+}
+
+type GetURLHistoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	PageSize      int32                  `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken     string                 `protobuf:"bytes,3,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetURLHistoryRequest) Reset() {
+	*x = GetURLHistoryRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[60]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetURLHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetURLHistoryRequest) ProtoMessage() {}
+
+func (x *GetURLHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[60]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetURLHistoryRequest.ProtoReflect.Descriptor instead.
+func (*GetURLHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{60}
+}
+
+func (x *GetURLHistoryRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *GetURLHistoryRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *GetURLHistoryRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type URLHistoryEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Action        string                 `protobuf:"bytes,1,opt,name=action,proto3" json:"action,omitempty"` // update, recreate, disable, enable or delete
+	Actor         string                 `protobuf:"bytes,2,opt,name=actor,proto3" json:"actor,omitempty"`   // User whose API key made the change, empty without authentication
+	ApiKeyId      string                 `protobuf:"bytes,3,opt,name=api_key_id,json=apiKeyId,proto3" json:"api_key_id,omitempty"`
+	OldUrl        string                 `protobuf:"bytes,4,opt,name=old_url,json=oldUrl,proto3" json:"old_url,omitempty"`
+	NewUrl        string                 `protobuf:"bytes,5,opt,name=new_url,json=newUrl,proto3" json:"new_url,omitempty"` // Empty for deletes
+	ChangedAt     string                 `protobuf:"bytes,6,opt,name=changed_at,json=changedAt,proto3" json:"changed_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *URLHistoryEntry) Reset() {
+	*x = URLHistoryEntry{}
+	mi := &file_storage_service_storage_proto_msgTypes[61]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *URLHistoryEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*URLHistoryEntry) ProtoMessage() {}
+
+func (x *URLHistoryEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[61]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use URLHistoryEntry.ProtoReflect.Descriptor instead.
+func (*URLHistoryEntry) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{61}
+}
+
+func (x *URLHistoryEntry) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *URLHistoryEntry) GetActor() string {
+	if x != nil {
+		return x.Actor
+	}
+	return ""
+}
+
+func (x *URLHistoryEntry) GetApiKeyId() string {
+	if x != nil {
+		return x.ApiKeyId
+	}
+	return ""
+}
+
+func (x *URLHistoryEntry) GetOldUrl() string {
+	if x != nil {
+		return x.OldUrl
+	}
+	return ""
+}
+
+func (x *URLHistoryEntry) GetNewUrl() string {
+	if x != nil {
+		return x.NewUrl
+	}
+	return ""
+}
+
+func (x *URLHistoryEntry) GetChangedAt() string {
+	if x != nil {
+		return x.ChangedAt
+	}
+	return ""
+}
+
+type GetURLHistoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entries       []*URLHistoryEntry     `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`                                    // Newest first
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"` // Empty on the last page
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetURLHistoryResponse) Reset() {
+	*x = GetURLHistoryResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[62]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetURLHistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetURLHistoryResponse) ProtoMessage() {}
+
+func (x *GetURLHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[62]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetURLHistoryResponse.ProtoReflect.Descriptor instead.
+func (*GetURLHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{62}
+}
+
+func (x *GetURLHistoryResponse) GetEntries() []*URLHistoryEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+func (x *GetURLHistoryResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+// A destination url-service refuses to shorten, or with DOMAIN_POLICY=allowlist
+// one of the only destinations it accepts.
+type BlockedDomain struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pattern       string                 `protobuf:"bytes,1,opt,name=pattern,proto3" json:"pattern,omitempty"` // A host, also matching its subdomains, or a regular expression
+	Regex         bool                   `protobuf:"varint,2,opt,name=regex,proto3" json:"regex,omitempty"`    // pattern is a Go regular expression matched against the whole URL
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	CreatedAt     string                 `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BlockedDomain) Reset() {
+	*x = BlockedDomain{}
+	mi := &file_storage_service_storage_proto_msgTypes[63]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BlockedDomain) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockedDomain) ProtoMessage() {}
+
+func (x *BlockedDomain) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[63]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockedDomain.ProtoReflect.Descriptor instead.
+func (*BlockedDomain) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{63}
+}
+
+func (x *BlockedDomain) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+func (x *BlockedDomain) GetRegex() bool {
+	if x != nil {
+		return x.Regex
+	}
+	return false
+}
+
+func (x *BlockedDomain) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *BlockedDomain) GetCreatedAt() string {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return ""
+}
+
+type AddBlockedDomainRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pattern       string                 `protobuf:"bytes,1,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	Regex         bool                   `protobuf:"varint,2,opt,name=regex,proto3" json:"regex,omitempty"`
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"` // Optional
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddBlockedDomainRequest) Reset() {
+	*x = AddBlockedDomainRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[64]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddBlockedDomainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddBlockedDomainRequest) ProtoMessage() {}
+
+func (x *AddBlockedDomainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[64]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddBlockedDomainRequest.ProtoReflect.Descriptor instead.
+func (*AddBlockedDomainRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{64}
+}
+
+func (x *AddBlockedDomainRequest) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+func (x *AddBlockedDomainRequest) GetRegex() bool {
+	if x != nil {
+		return x.Regex
+	}
+	return false
+}
+
+func (x *AddBlockedDomainRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type AddBlockedDomainResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        *BlockedDomain         `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"` // As stored, with a host lowercased
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddBlockedDomainResponse) Reset() {
+	*x = AddBlockedDomainResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[65]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddBlockedDomainResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddBlockedDomainResponse) ProtoMessage() {}
+
+func (x *AddBlockedDomainResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[65]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddBlockedDomainResponse.ProtoReflect.Descriptor instead.
+func (*AddBlockedDomainResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{65}
+}
+
+func (x *AddBlockedDomainResponse) GetDomain() *BlockedDomain {
+	if x != nil {
+		return x.Domain
+	}
+	return nil
+}
+
+type RemoveBlockedDomainRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pattern       string                 `protobuf:"bytes,1,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	Regex         bool                   `protobuf:"varint,2,opt,name=regex,proto3" json:"regex,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveBlockedDomainRequest) Reset() {
+	*x = RemoveBlockedDomainRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[66]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveBlockedDomainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveBlockedDomainRequest) ProtoMessage() {}
+
+func (x *RemoveBlockedDomainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[66]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveBlockedDomainRequest.ProtoReflect.Descriptor instead.
+func (*RemoveBlockedDomainRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{66}
+}
+
+func (x *RemoveBlockedDomainRequest) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+func (x *RemoveBlockedDomainRequest) GetRegex() bool {
+	if x != nil {
+		return x.Regex
+	}
+	return false
+}
+
+type RemoveBlockedDomainResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveBlockedDomainResponse) Reset() {
+	*x = RemoveBlockedDomainResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[67]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveBlockedDomainResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveBlockedDomainResponse) ProtoMessage() {}
+
+func (x *RemoveBlockedDomainResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[67]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveBlockedDomainResponse.ProtoReflect.Descriptor instead.
+func (*RemoveBlockedDomainResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{67}
+}
+
+type ListBlockedDomainsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListBlockedDomainsRequest) Reset() {
+	*x = ListBlockedDomainsRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[68]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListBlockedDomainsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListBlockedDomainsRequest) ProtoMessage() {}
+
+func (x *ListBlockedDomainsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[68]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListBlockedDomainsRequest.ProtoReflect.Descriptor instead.
+func (*ListBlockedDomainsRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{68}
+}
+
+type ListBlockedDomainsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domains       []*BlockedDomain       `protobuf:"bytes,1,rep,name=domains,proto3" json:"domains,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListBlockedDomainsResponse) Reset() {
+	*x = ListBlockedDomainsResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[69]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListBlockedDomainsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListBlockedDomainsResponse) ProtoMessage() {}
+
+func (x *ListBlockedDomainsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[69]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListBlockedDomainsResponse.ProtoReflect.Descriptor instead.
+func (*ListBlockedDomainsResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{69}
+}
+
+func (x *ListBlockedDomainsResponse) GetDomains() []*BlockedDomain {
+	if x != nil {
+		return x.Domains
+	}
+	return nil
+}
+
+type ReportURLRequest struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode        string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	Reason           string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	ReporterContact  string                 `protobuf:"bytes,3,opt,name=reporter_contact,json=reporterContact,proto3" json:"reporter_contact,omitempty"`     // Optional
+	Reporter         string                 `protobuf:"bytes,4,opt,name=reporter,proto3" json:"reporter,omitempty"`                                          // Identifies the reporter; one report per reporter, code and UTC day is kept
+	DisableThreshold int32                  `protobuf:"varint,5,opt,name=disable_threshold,json=disableThreshold,proto3" json:"disable_threshold,omitempty"` // Optional, disable the URL once this many distinct reporters have open reports
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *ReportURLRequest) Reset() {
+	*x = ReportURLRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[70]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportURLRequest) ProtoMessage() {}
+
+func (x *ReportURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[70]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportURLRequest.ProtoReflect.Descriptor instead.
+func (*ReportURLRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{70}
+}
+
+func (x *ReportURLRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *ReportURLRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *ReportURLRequest) GetReporterContact() string {
+	if x != nil {
+		return x.ReporterContact
+	}
+	return ""
+}
+
+func (x *ReportURLRequest) GetReporter() string {
+	if x != nil {
+		return x.Reporter
+	}
+	return ""
+}
+
+func (x *ReportURLRequest) GetDisableThreshold() int32 {
+	if x != nil {
+		return x.DisableThreshold
+	}
+	return 0
+}
+
+type ReportURLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ReportId      int64                  `protobuf:"varint,1,opt,name=report_id,json=reportId,proto3" json:"report_id,omitempty"` // 0 for duplicates
+	Duplicate     bool                   `protobuf:"varint,2,opt,name=duplicate,proto3" json:"duplicate,omitempty"`
+	Reporters     int32                  `protobuf:"varint,3,opt,name=reporters,proto3" json:"reporters,omitempty"` // Distinct reporters with open reports on the URL
+	Disabled      bool                   `protobuf:"varint,4,opt,name=disabled,proto3" json:"disabled,omitempty"`   // This report took the URL past disable_threshold
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReportURLResponse) Reset() {
+	*x = ReportURLResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[71]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportURLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportURLResponse) ProtoMessage() {}
+
+func (x *ReportURLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[71]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportURLResponse.ProtoReflect.Descriptor instead.
+func (*ReportURLResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{71}
+}
+
+func (x *ReportURLResponse) GetReportId() int64 {
+	if x != nil {
+		return x.ReportId
+	}
+	return 0
+}
+
+func (x *ReportURLResponse) GetDuplicate() bool {
+	if x != nil {
+		return x.Duplicate
+	}
+	return false
+}
+
+func (x *ReportURLResponse) GetReporters() int32 {
+	if x != nil {
+		return x.Reporters
+	}
+	return 0
+}
+
+func (x *ReportURLResponse) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+type AbuseReport struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	ShortCode       string                 `protobuf:"bytes,2,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	Reason          string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	ReporterContact string                 `protobuf:"bytes,4,opt,name=reporter_contact,json=reporterContact,proto3" json:"reporter_contact,omitempty"`
+	Status          string                 `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"` // open, or actioned once the URL was disabled over its reports
+	CreatedAt       string                 `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *AbuseReport) Reset() {
+	*x = AbuseReport{}
+	mi := &file_storage_service_storage_proto_msgTypes[72]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AbuseReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AbuseReport) ProtoMessage() {}
+
+func (x *AbuseReport) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[72]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AbuseReport.ProtoReflect.Descriptor instead.
+func (*AbuseReport) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{72}
+}
+
+func (x *AbuseReport) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *AbuseReport) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *AbuseReport) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *AbuseReport) GetReporterContact() string {
+	if x != nil {
+		return x.ReporterContact
+	}
+	return ""
+}
+
+func (x *AbuseReport) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *AbuseReport) GetCreatedAt() string {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return ""
+}
+
+type ListReportsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`                        // Optional filter
+	ShortCode     string                 `protobuf:"bytes,2,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"` // Optional filter
+	PageSize      int32                  `protobuf:"varint,3,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken     string                 `protobuf:"bytes,4,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	TenantId      string                 `protobuf:"bytes,5,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"` // Only reports on URLs of this tenant, empty for the default one
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListReportsRequest) Reset() {
+	*x = ListReportsRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[73]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListReportsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListReportsRequest) ProtoMessage() {}
+
+func (x *ListReportsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[73]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListReportsRequest.ProtoReflect.Descriptor instead.
+func (*ListReportsRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{73}
+}
+
+func (x *ListReportsRequest) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ListReportsRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *ListReportsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListReportsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListReportsRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type ListReportsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reports       []*AbuseReport         `protobuf:"bytes,1,rep,name=reports,proto3" json:"reports,omitempty"`                                    // Newest first
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"` // Empty on the last page
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListReportsResponse) Reset() {
+	*x = ListReportsResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[74]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListReportsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListReportsResponse) ProtoMessage() {}
+
+func (x *ListReportsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[74]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListReportsResponse.ProtoReflect.Descriptor instead.
+func (*ListReportsResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{74}
+}
+
+func (x *ListReportsResponse) GetReports() []*AbuseReport {
+	if x != nil {
+		return x.Reports
+	}
+	return nil
+}
+
+func (x *ListReportsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type PurgeURLRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PurgeURLRequest) Reset() {
+	*x = PurgeURLRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[75]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PurgeURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PurgeURLRequest) ProtoMessage() {}
+
+func (x *PurgeURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[75]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PurgeURLRequest.ProtoReflect.Descriptor instead.
+func (*PurgeURLRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{75}
+}
+
+func (x *PurgeURLRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+type PurgeURLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Purged        bool                   `protobuf:"varint,1,opt,name=purged,proto3" json:"purged,omitempty"` // false when there was no row, deleted or not, to remove
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PurgeURLResponse) Reset() {
+	*x = PurgeURLResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[76]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PurgeURLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PurgeURLResponse) ProtoMessage() {}
+
+func (x *PurgeURLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[76]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PurgeURLResponse.ProtoReflect.Descriptor instead.
+func (*PurgeURLResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{76}
+}
+
+func (x *PurgeURLResponse) GetPurged() bool {
+	if x != nil {
+		return x.Purged
+	}
+	return false
+}
+
+type DeleteUserDataRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	TenantId      string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"` // Empty for the default tenant
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteUserDataRequest) Reset() {
+	*x = DeleteUserDataRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[77]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteUserDataRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteUserDataRequest) ProtoMessage() {}
+
+func (x *DeleteUserDataRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[77]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteUserDataRequest.ProtoReflect.Descriptor instead.
+func (*DeleteUserDataRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{77}
+}
+
+func (x *DeleteUserDataRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *DeleteUserDataRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type DeleteUserDataResponse struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	DeletedUrls       int64                  `protobuf:"varint,1,opt,name=deleted_urls,json=deletedUrls,proto3" json:"deleted_urls,omitempty"`                    // URLs soft deleted by this call, 0 when it is repeated
+	ScrubbedClicks    int64                  `protobuf:"varint,2,opt,name=scrubbed_clicks,json=scrubbedClicks,proto3" json:"scrubbed_clicks,omitempty"`           // Click events whose visitor details this call cleared
+	ShortCodes        []string               `protobuf:"bytes,3,rep,name=short_codes,json=shortCodes,proto3" json:"short_codes,omitempty"`                        // Every URL of the user, deleted now or before, for callers to drop cached copies of
+	DeletedShortCodes []string               `protobuf:"bytes,4,rep,name=deleted_short_codes,json=deletedShortCodes,proto3" json:"deleted_short_codes,omitempty"` // The URLs this call deleted
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *DeleteUserDataResponse) Reset() {
+	*x = DeleteUserDataResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[78]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteUserDataResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteUserDataResponse) ProtoMessage() {}
+
+func (x *DeleteUserDataResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[78]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteUserDataResponse.ProtoReflect.Descriptor instead.
+func (*DeleteUserDataResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{78}
+}
+
+func (x *DeleteUserDataResponse) GetDeletedUrls() int64 {
+	if x != nil {
+		return x.DeletedUrls
+	}
+	return 0
+}
+
+func (x *DeleteUserDataResponse) GetScrubbedClicks() int64 {
+	if x != nil {
+		return x.ScrubbedClicks
+	}
+	return 0
+}
+
+func (x *DeleteUserDataResponse) GetShortCodes() []string {
+	if x != nil {
+		return x.ShortCodes
+	}
+	return nil
+}
+
+func (x *DeleteUserDataResponse) GetDeletedShortCodes() []string {
+	if x != nil {
+		return x.DeletedShortCodes
+	}
+	return nil
+}
+
+type UpdateMetadataRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	ImageUrl      string                 `protobuf:"bytes,4,opt,name=image_url,json=imageUrl,proto3" json:"image_url,omitempty"`
+	Error         string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"` // Why fetching failed; the metadata already stored is kept
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateMetadataRequest) Reset() {
+	*x = UpdateMetadataRequest{}
+	mi := &file_storage_service_storage_proto_msgTypes[79]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateMetadataRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateMetadataRequest) ProtoMessage() {}
+
+func (x *UpdateMetadataRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[79]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateMetadataRequest.ProtoReflect.Descriptor instead.
+func (*UpdateMetadataRequest) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{79}
+}
+
+func (x *UpdateMetadataRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *UpdateMetadataRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *UpdateMetadataRequest) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *UpdateMetadataRequest) GetImageUrl() string {
+	if x != nil {
+		return x.ImageUrl
+	}
+	return ""
+}
+
+func (x *UpdateMetadataRequest) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type UpdateMetadataResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateMetadataResponse) Reset() {
+	*x = UpdateMetadataResponse{}
+	mi := &file_storage_service_storage_proto_msgTypes[80]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateMetadataResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateMetadataResponse) ProtoMessage() {}
+
+func (x *UpdateMetadataResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_service_storage_proto_msgTypes[80]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateMetadataResponse.ProtoReflect.Descriptor instead.
+func (*UpdateMetadataResponse) Descriptor() ([]byte, []int) {
+	return file_storage_service_storage_proto_rawDescGZIP(), []int{80}
+}
+
+var File_storage_service_storage_proto protoreflect.FileDescriptor
+
+const file_storage_service_storage_proto_rawDesc = "" +
+	"\n" +
+	"\x1dstorage-service/storage.proto\x12\astorage\"\x9b\x05\n" +
+	"\x0eSaveURLRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12!\n" +
+	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\x122\n" +
+	"\x15expected_original_url\x18\x03 \x01(\tR\x13expectedOriginalUrl\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x04 \x01(\tR\texpiresAt\x12\x1c\n" +
+	"\n" +
+	"api_key_id\x18\x05 \x01(\tR\bapiKeyId\x12\x17\n" +
+	"\auser_id\x18\x06 \x01(\tR\x06userId\x12\x1c\n" +
+	"\tresurrect\x18\a \x01(\bR\tresurrect\x12\x1d\n" +
+	"\n" +
+	"max_clicks\x18\b \x01(\x03R\tmaxClicks\x12!\n" +
+	"\ffallback_url\x18\t \x01(\tR\vfallbackUrl\x12\x1d\n" +
+	"\n" +
+	"not_before\x18\n" +
+	" \x01(\tR\tnotBefore\x12&\n" +
+	"\x0fcoming_soon_url\x18\v \x01(\tR\rcomingSoonUrl\x12,\n" +
+	"\bvariants\x18\f \x03(\v2\x10.storage.VariantR\bvariants\x12'\n" +
+	"\x0fsticky_variants\x18\r \x01(\bR\x0estickyVariants\x12+\n" +
+	"\x05rules\x18\x0e \x03(\v2\x15.storage.RedirectRuleR\x05rules\x12%\n" +
+	"\x0equery_template\x18\x0f \x01(\tR\rqueryTemplate\x12\x12\n" +
+	"\x04tags\x18\x10 \x03(\tR\x04tags\x12\x19\n" +
+	"\bset_tags\x18\x11 \x01(\bR\asetTags\x12\x1b\n" +
+	"\ttenant_id\x18\x12 \x01(\tR\btenantId\x12\x1f\n" +
+	"\vcreate_only\x18\x13 \x01(\bR\n" +
+	"createOnly\"V\n" +
+	"\fRedirectRule\x12\x16\n" +
+	"\x06device\x18\x01 \x01(\tR\x06device\x12\x1c\n" +
+	"\tcountries\x18\x02 \x03(\tR\tcountries\x12\x10\n" +
+	"\x03url\x18\x03 \x01(\tR\x03url\"G\n" +
+	"\aVariant\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x16\n" +
+	"\x06weight\x18\x03 \x01(\x05R\x06weight\"A\n" +
+	"\x0fSaveURLResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"\xa5\x01\n" +
+	"\rGetURLRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12'\n" +
+	"\x0finclude_expired\x18\x02 \x01(\bR\x0eincludeExpired\x12#\n" +
+	"\rforce_primary\x18\x03 \x01(\bR\fforcePrimary\x12'\n" +
+	"\x0finclude_deleted\x18\x04 \x01(\bR\x0eincludeDeleted\"\xc1\x04\n" +
+	"\x0eGetURLResponse\x12!\n" +
+	"\foriginal_url\x18\x01 \x01(\tR\voriginalUrl\x12\x14\n" +
+	"\x05found\x18\x02 \x01(\bR\x05found\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x04 \x01(\tR\texpiresAt\x12\x1f\n" +
+	"\vclick_count\x18\x05 \x01(\x03R\n" +
+	"clickCount\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x06 \x01(\tR\tcreatedAt\x12\x17\n" +
+	"\auser_id\x18\a \x01(\tR\x06userId\x12\x1d\n" +
+	"\n" +
+	"max_clicks\x18\b \x01(\x03R\tmaxClicks\x12\x1d\n" +
+	"\n" +
+	"not_before\x18\t \x01(\tR\tnotBefore\x12&\n" +
+	"\x0fcoming_soon_url\x18\n" +
+	" \x01(\tR\rcomingSoonUrl\x12!\n" +
+	"\ffallback_url\x18\v \x01(\tR\vfallbackUrl\x12\x1a\n" +
+	"\bdisabled\x18\f \x01(\bR\bdisabled\x12,\n" +
+	"\bvariants\x18\r \x03(\v2\x10.storage.VariantR\bvariants\x12'\n" +
+	"\x0fsticky_variants\x18\x0e \x01(\bR\x0estickyVariants\x12+\n" +
+	"\x05rules\x18\x0f \x03(\v2\x15.storage.RedirectRuleR\x05rules\x12%\n" +
+	"\x0equery_template\x18\x10 \x01(\tR\rqueryTemplate\x12\x18\n" +
+	"\adeleted\x18\x11 \x01(\bR\adeleted\"6\n" +
+	"\x15IncrementClickRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\"H\n" +
+	"\x16IncrementClickResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"~\n" +
+	"\x0fGetStatsRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12'\n" +
+	"\x0finclude_deleted\x18\x02 \x01(\bR\x0eincludeDeleted\x12#\n" +
+	"\rforce_primary\x18\x03 \x01(\bR\fforcePrimary\"\x88\x03\n" +
+	"\x10GetStatsResponse\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x1f\n" +
+	"\vclick_count\x18\x02 \x01(\x03R\n" +
+	"clickCount\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x03 \x01(\tR\tcreatedAt\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x05 \x01(\tR\texpiresAt\x12\x1d\n" +
+	"\n" +
+	"deleted_at\x18\x06 \x01(\tR\tdeletedAt\x12#\n" +
+	"\runique_clicks\x18\a \x01(\x03R\funiqueClicks\x12\x1d\n" +
+	"\n" +
+	"bot_clicks\x18\b \x01(\x03R\tbotClicks\x12(\n" +
+	"\x10last_accessed_at\x18\t \x01(\tR\x0elastAccessedAt\x12\x14\n" +
+	"\x05title\x18\n" +
+	" \x01(\tR\x05title\x12 \n" +
+	"\vdescription\x18\v \x01(\tR\vdescription\x12\x1b\n" +
+	"\timage_url\x18\f \x01(\tR\bimageUrl\"1\n" +
+	"\x10DeleteURLRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\"C\n" +
+	"\x11DeleteURLResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"\x8c\x02\n" +
+	"\x18FindByOriginalURLRequest\x12!\n" +
+	"\foriginal_url\x18\x01 \x01(\tR\voriginalUrl\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\x12\x14\n" +
+	"\x05match\x18\x03 \x01(\tR\x05match\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x04 \x01(\tR\tpageToken\x12%\n" +
+	"\x0eunlimited_only\x18\x05 \x01(\bR\runlimitedOnly\x12\x1f\n" +
+	"\vactive_only\x18\x06 \x01(\bR\n" +
+	"activeOnly\x12\x1d\n" +
+	"\n" +
+	"plain_only\x18\a \x01(\bR\tplainOnly\x12\x1b\n" +
+	"\ttenant_id\x18\b \x01(\tR\btenantId\"z\n" +
+	"\x19FindByOriginalURLResponse\x12\x1f\n" +
+	"\vshort_codes\x18\x01 \x03(\tR\n" +
+	"shortCodes\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x12&\n" +
+	"\x0fnext_page_token\x18\x03 \x01(\tR\rnextPageToken\"\x18\n" +
+	"\x16GetCleanupStatsRequest\"\xa5\x03\n" +
+	"\x17GetCleanupStatsResponse\x12,\n" +
+	"\x12total_rows_cleaned\x18\x01 \x01(\x03R\x10totalRowsCleaned\x12\x12\n" +
+	"\x04runs\x18\x02 \x01(\x03R\x04runs\x12\x1e\n" +
+	"\vlast_run_at\x18\x03 \x01(\tR\tlastRunAt\x121\n" +
+	"\x15last_run_rows_cleaned\x18\x04 \x01(\x03R\x12lastRunRowsCleaned\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\x05 \x01(\tR\tlastError\x12*\n" +
+	"\x11total_rows_purged\x18\x06 \x01(\x03R\x0ftotalRowsPurged\x12/\n" +
+	"\x14last_run_rows_purged\x18\a \x01(\x03R\x11lastRunRowsPurged\x129\n" +
+	"\x19total_click_events_purged\x18\b \x01(\x03R\x16totalClickEventsPurged\x12>\n" +
+	"\x1clast_run_click_events_purged\x18\t \x01(\x03R\x18lastRunClickEventsPurged\"\xab\x01\n" +
+	"\n" +
+	"ClickDelta\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x14\n" +
+	"\x05delta\x18\x02 \x01(\x03R\x05delta\x12!\n" +
+	"\funique_delta\x18\x03 \x01(\x03R\vuniqueDelta\x12\x1b\n" +
+	"\tbot_delta\x18\x04 \x01(\x03R\bbotDelta\x12(\n" +
+	"\x10last_accessed_at\x18\x05 \x01(\tR\x0elastAccessedAt\"J\n" +
+	"\x1bBatchIncrementClicksRequest\x12+\n" +
+	"\x06deltas\x18\x01 \x03(\v2\x13.storage.ClickDeltaR\x06deltas\"\xde\x01\n" +
+	"\x1cBatchIncrementClicksResponse\x12\x18\n" +
+	"\aupdated\x18\x01 \x01(\x03R\aupdated\x12.\n" +
+	"\x13missing_short_codes\x18\x02 \x03(\tR\x11missingShortCodes\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\x12,\n" +
+	"\x12failed_short_codes\x18\x04 \x03(\tR\x10failedShortCodes\x120\n" +
+	"\x14buffered_short_codes\x18\x05 \x03(\tR\x12bufferedShortCodes\"\x97\x01\n" +
+	"\x0fListURLsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x03 \x01(\tR\tpageToken\x12\x12\n" +
+	"\x04tags\x18\x04 \x03(\tR\x04tags\x12\x1b\n" +
+	"\ttenant_id\x18\x05 \x01(\tR\btenantId\"\xf9\x03\n" +
+	"\n" +
+	"URLSummary\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12!\n" +
+	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\x12\x1f\n" +
+	"\vclick_count\x18\x03 \x01(\x03R\n" +
+	"clickCount\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\tR\tcreatedAt\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x05 \x01(\tR\texpiresAt\x12\x1d\n" +
+	"\n" +
+	"max_clicks\x18\x06 \x01(\x03R\tmaxClicks\x12\x1d\n" +
+	"\n" +
+	"not_before\x18\a \x01(\tR\tnotBefore\x12\x1a\n" +
+	"\bdisabled\x18\b \x01(\bR\bdisabled\x12\x14\n" +
+	"\x05split\x18\t \x01(\bR\x05split\x12 \n" +
+	"\vconditional\x18\n" +
+	" \x01(\bR\vconditional\x12%\n" +
+	"\x0equery_template\x18\v \x01(\tR\rqueryTemplate\x12\x12\n" +
+	"\x04tags\x18\f \x03(\tR\x04tags\x12(\n" +
+	"\x10last_accessed_at\x18\r \x01(\tR\x0elastAccessedAt\x12\x14\n" +
+	"\x05title\x18\x0e \x01(\tR\x05title\x12 \n" +
+	"\vdescription\x18\x0f \x01(\tR\vdescription\x12\x1b\n" +
+	"\timage_url\x18\x10 \x01(\tR\bimageUrl\"c\n" +
+	"\x10ListURLsResponse\x12'\n" +
+	"\x04urls\x18\x01 \x03(\v2\x13.storage.URLSummaryR\x04urls\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"h\n" +
+	"\x14ListStaleURLsRequest\x12\x1d\n" +
+	"\n" +
+	"older_than\x18\x01 \x01(\tR\tolderThan\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\x12\x1b\n" +
+	"\ttenant_id\x18\x03 \x01(\tR\btenantId\"@\n" +
+	"\x15ListStaleURLsResponse\x12'\n" +
+	"\x04urls\x18\x01 \x03(\v2\x13.storage.URLSummaryR\x04urls\"G\n" +
+	"\x0fListTagsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\"0\n" +
+	"\bTagCount\x12\x10\n" +
+	"\x03tag\x18\x01 \x01(\tR\x03tag\x12\x12\n" +
+	"\x04urls\x18\x02 \x01(\x03R\x04urls\"9\n" +
+	"\x10ListTagsResponse\x12%\n" +
+	"\x04tags\x18\x01 \x03(\v2\x11.storage.TagCountR\x04tags\"H\n" +
+	"\x10CountURLsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\"+\n" +
+	"\x11CountURLsResponse\x12\x16\n" +
+	"\x06active\x18\x01 \x01(\x03R\x06active\">\n" +
+	"\x0fSaveURLsRequest\x12+\n" +
+	"\x04urls\x18\x01 \x03(\v2\x17.storage.SaveURLRequestR\x04urls\"r\n" +
+	"\x10SaveURLsResponse\x120\n" +
+	"\x14inserted_short_codes\x18\x01 \x03(\tR\x12insertedShortCodes\x12,\n" +
+	"\x12failed_short_codes\x18\x02 \x03(\tR\x10failedShortCodes\"Z\n" +
+	"\x0eGetURLsRequest\x12\x1f\n" +
+	"\vshort_codes\x18\x01 \x03(\tR\n" +
+	"shortCodes\x12'\n" +
+	"\x0finclude_expired\x18\x02 \x01(\bR\x0eincludeExpired\"\x9b\x01\n" +
+	"\x0fGetURLsResponse\x126\n" +
+	"\x04urls\x18\x01 \x03(\v2\".storage.GetURLsResponse.UrlsEntryR\x04urls\x1aP\n" +
+	"\tUrlsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12-\n" +
+	"\x05value\x18\x02 \x01(\v2\x17.storage.GetURLResponseR\x05value:\x028\x01\"w\n" +
+	"\x11GetTopURLsRequest\x12\x14\n" +
+	"\x05limit\x18\x01 \x01(\x05R\x05limit\x12\x19\n" +
+	"\border_by\x18\x02 \x01(\tR\aorderBy\x12\x14\n" +
+	"\x05since\x18\x03 \x01(\tR\x05since\x12\x1b\n" +
+	"\ttenant_id\x18\x04 \x01(\tR\btenantId\"=\n" +
+	"\x12GetTopURLsResponse\x12'\n" +
+	"\x04urls\x18\x01 \x03(\v2\x13.storage.URLSummaryR\x04urls\"4\n" +
+	"\x15GetGlobalStatsRequest\x12\x1b\n" +
+	"\ttenant_id\x18\x01 \x01(\tR\btenantId\"\xa5\x01\n" +
+	"\x16GetGlobalStatsResponse\x12\x1d\n" +
+	"\n" +
+	"total_urls\x18\x01 \x01(\x03R\ttotalUrls\x12!\n" +
+	"\ftotal_clicks\x18\x02 \x01(\x03R\vtotalClicks\x12(\n" +
+	"\x10created_last_day\x18\x03 \x01(\x03R\x0ecreatedLastDay\x12\x1f\n" +
+	"\vactive_urls\x18\x04 \x01(\x03R\n" +
+	"activeUrls\"\xdf\x01\n" +
+	"\n" +
+	"ClickEvent\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x1d\n" +
+	"\n" +
+	"clicked_at\x18\x02 \x01(\tR\tclickedAt\x12\x1a\n" +
+	"\breferrer\x18\x03 \x01(\tR\breferrer\x12\x1d\n" +
+	"\n" +
+	"user_agent\x18\x04 \x01(\tR\tuserAgent\x12\x18\n" +
+	"\acountry\x18\x05 \x01(\tR\acountry\x12\x10\n" +
+	"\x03bot\x18\x06 \x01(\bR\x03bot\x12\x18\n" +
+	"\avariant\x18\a \x01(\tR\avariant\x12\x12\n" +
+	"\x04rule\x18\b \x01(\tR\x04rule\"A\n" +
+	"\x12RecordClickRequest\x12+\n" +
+	"\x06events\x18\x01 \x03(\v2\x13.storage.ClickEventR\x06events\"1\n" +
+	"\x13RecordClickResponse\x12\x1a\n" +
+	"\brecorded\x18\x01 \x01(\x03R\brecorded\"\xba\x01\n" +
+	"\x19GetClickTimeSeriesRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x14\n" +
+	"\x05start\x18\x02 \x01(\tR\x05start\x12\x10\n" +
+	"\x03end\x18\x03 \x01(\tR\x03end\x12\x16\n" +
+	"\x06bucket\x18\x04 \x01(\tR\x06bucket\x12\x1b\n" +
+	"\ttime_zone\x18\x05 \x01(\tR\btimeZone\x12!\n" +
+	"\finclude_bots\x18\x06 \x01(\bR\vincludeBots\";\n" +
+	"\vClickBucket\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\tR\x05start\x12\x16\n" +
+	"\x06clicks\x18\x02 \x01(\x03R\x06clicks\"o\n" +
+	"\x1aGetClickTimeSeriesResponse\x12.\n" +
+	"\abuckets\x18\x01 \x03(\v2\x14.storage.ClickBucketR\abuckets\x12!\n" +
+	"\ftotal_clicks\x18\x02 \x01(\x03R\vtotalClicks\"O\n" +
+	"\x18GetClickBreakdownRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\">\n" +
+	"\x0eBreakdownEntry\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\tR\x05value\x12\x16\n" +
+	"\x06clicks\x18\x02 \x01(\x03R\x06clicks\"\xd5\x02\n" +
+	"\x19GetClickBreakdownResponse\x125\n" +
+	"\treferrers\x18\x01 \x03(\v2\x17.storage.BreakdownEntryR\treferrers\x125\n" +
+	"\tcountries\x18\x02 \x03(\v2\x17.storage.BreakdownEntryR\tcountries\x123\n" +
+	"\bbrowsers\x18\x03 \x03(\v2\x17.storage.BreakdownEntryR\bbrowsers\x121\n" +
+	"\adevices\x18\x04 \x03(\v2\x17.storage.BreakdownEntryR\adevices\x123\n" +
+	"\bvariants\x18\x05 \x03(\v2\x17.storage.BreakdownEntryR\bvariants\x12-\n" +
+	"\x05rules\x18\x06 \x03(\v2\x17.storage.BreakdownEntryR\x05rules\"\xb7\x01\n" +
+	"\x11ExportURLsRequest\x12\x1d\n" +
+	"\n" +
+	"batch_size\x18\x01 \x01(\x05R\tbatchSize\x12#\n" +
+	"\rcreated_after\x18\x02 \x01(\tR\fcreatedAfter\x12(\n" +
+	"\x10after_short_code\x18\x03 \x01(\tR\x0eafterShortCode\x12\x17\n" +
+	"\auser_id\x18\x04 \x01(\tR\x06userId\x12\x1b\n" +
+	"\ttenant_id\x18\x05 \x01(\tR\btenantId\"\x83\x02\n" +
+	"\vExportedURL\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12!\n" +
+	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x03 \x01(\tR\tcreatedAt\x12\x1f\n" +
+	"\vclick_count\x18\x04 \x01(\x03R\n" +
+	"clickCount\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x05 \x01(\tR\texpiresAt\x12\x17\n" +
+	"\auser_id\x18\x06 \x01(\tR\x06userId\x12\x1d\n" +
+	"\n" +
+	"deleted_at\x18\a \x01(\tR\tdeletedAt\x12\x1b\n" +
+	"\ttenant_id\x18\b \x01(\tR\btenantId\">\n" +
+	"\x12ExportURLsResponse\x12(\n" +
+	"\x04urls\x18\x01 \x03(\v2\x14.storage.ExportedURLR\x04urls\"w\n" +
+	"\x11ImportURLsRequest\x12(\n" +
+	"\x04urls\x18\x01 \x03(\v2\x14.storage.ExportedURLR\x04urls\x12\x17\n" +
+	"\adry_run\x18\x02 \x01(\bR\x06dryRun\x12\x1f\n" +
+	"\von_conflict\x18\x03 \x01(\tR\n" +
+	"onConflict\"^\n" +
+	"\x0fImportRejection\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x03R\x05index\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x02 \x01(\tR\tshortCode\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\"\xd3\x01\n" +
+	"\x12ImportURLsResponse\x12\x1a\n" +
+	"\binserted\x18\x01 \x01(\x03R\binserted\x12\x18\n" +
+	"\aupdated\x18\x02 \x01(\x03R\aupdated\x12\x18\n" +
+	"\askipped\x18\x03 \x01(\x03R\askipped\x12\x1a\n" +
+	"\brejected\x18\x04 \x01(\x03R\brejected\x128\n" +
+	"\n" +
+	"rejections\x18\x05 \x03(\v2\x18.storage.ImportRejectionR\n" +
+	"rejections\x12\x17\n" +
+	"\adry_run\x18\x06 \x01(\bR\x06dryRun\".\n" +
+	"\x16AllocateIDRangeRequest\x12\x14\n" +
+	"\x05count\x18\x01 \x01(\x03R\x05count\"A\n" +
+	"\x17AllocateIDRangeResponse\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\x03R\x05start\x12\x10\n" +
+	"\x03end\x18\x02 \x01(\x03R\x03end\"&\n" +
+	"\x0ePopKeysRequest\x12\x14\n" +
+	"\x05count\x18\x01 \x01(\x05R\x05count\"2\n" +
+	"\x0fPopKeysResponse\x12\x1f\n" +
+	"\vshort_codes\x18\x01 \x03(\tR\n" +
+	"shortCodes\"F\n" +
+	"\x11ClaimClickRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x12\n" +
+	"\x04peek\x18\x02 \x01(\bR\x04peek\"o\n" +
+	"\x12ClaimClickResponse\x12\x18\n" +
+	"\aclaimed\x18\x01 \x01(\bR\aclaimed\x12\x1c\n" +
+	"\tremaining\x18\x02 \x01(\x03R\tremaining\x12!\n" +
+	"\ffallback_url\x18\x03 \x01(\tR\vfallbackUrl\"L\n" +
+	"\x13SetURLStatusRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x16\n" +
+	"\x06active\x18\x02 \x01(\bR\x06active\".\n" +
+	"\x14SetURLStatusResponse\x12\x16\n" +
+	"\x06active\x18\x01 \x01(\bR\x06active\"q\n" +
+	"\x14GetURLHistoryRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x1b\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x03 \x01(\tR\tpageToken\"\xae\x01\n" +
+	"\x0fURLHistoryEntry\x12\x16\n" +
+	"\x06action\x18\x01 \x01(\tR\x06action\x12\x14\n" +
+	"\x05actor\x18\x02 \x01(\tR\x05actor\x12\x1c\n" +
+	"\n" +
+	"api_key_id\x18\x03 \x01(\tR\bapiKeyId\x12\x17\n" +
+	"\aold_url\x18\x04 \x01(\tR\x06oldUrl\x12\x17\n" +
+	"\anew_url\x18\x05 \x01(\tR\x06newUrl\x12\x1d\n" +
+	"\n" +
+	"changed_at\x18\x06 \x01(\tR\tchangedAt\"s\n" +
+	"\x15GetURLHistoryResponse\x122\n" +
+	"\aentries\x18\x01 \x03(\v2\x18.storage.URLHistoryEntryR\aentries\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"v\n" +
+	"\rBlockedDomain\x12\x18\n" +
+	"\apattern\x18\x01 \x01(\tR\apattern\x12\x14\n" +
+	"\x05regex\x18\x02 \x01(\bR\x05regex\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\tR\tcreatedAt\"a\n" +
+	"\x17AddBlockedDomainRequest\x12\x18\n" +
+	"\apattern\x18\x01 \x01(\tR\apattern\x12\x14\n" +
+	"\x05regex\x18\x02 \x01(\bR\x05regex\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\"J\n" +
+	"\x18AddBlockedDomainResponse\x12.\n" +
+	"\x06domain\x18\x01 \x01(\v2\x16.storage.BlockedDomainR\x06domain\"L\n" +
+	"\x1aRemoveBlockedDomainRequest\x12\x18\n" +
+	"\apattern\x18\x01 \x01(\tR\apattern\x12\x14\n" +
+	"\x05regex\x18\x02 \x01(\bR\x05regex\"\x1d\n" +
+	"\x1bRemoveBlockedDomainResponse\"\x1b\n" +
+	"\x19ListBlockedDomainsRequest\"N\n" +
+	"\x1aListBlockedDomainsResponse\x120\n" +
+	"\adomains\x18\x01 \x03(\v2\x16.storage.BlockedDomainR\adomains\"\xbd\x01\n" +
+	"\x10ReportURLRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x12)\n" +
+	"\x10reporter_contact\x18\x03 \x01(\tR\x0freporterContact\x12\x1a\n" +
+	"\breporter\x18\x04 \x01(\tR\breporter\x12+\n" +
+	"\x11disable_threshold\x18\x05 \x01(\x05R\x10disableThreshold\"\x88\x01\n" +
+	"\x11ReportURLResponse\x12\x1b\n" +
+	"\treport_id\x18\x01 \x01(\x03R\breportId\x12\x1c\n" +
+	"\tduplicate\x18\x02 \x01(\bR\tduplicate\x12\x1c\n" +
+	"\treporters\x18\x03 \x01(\x05R\treporters\x12\x1a\n" +
+	"\bdisabled\x18\x04 \x01(\bR\bdisabled\"\xb6\x01\n" +
+	"\vAbuseReport\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x02 \x01(\tR\tshortCode\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\x12)\n" +
+	"\x10reporter_contact\x18\x04 \x01(\tR\x0freporterContact\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x06 \x01(\tR\tcreatedAt\"\xa4\x01\n" +
+	"\x12ListReportsRequest\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x02 \x01(\tR\tshortCode\x12\x1b\n" +
+	"\tpage_size\x18\x03 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x04 \x01(\tR\tpageToken\x12\x1b\n" +
+	"\ttenant_id\x18\x05 \x01(\tR\btenantId\"m\n" +
+	"\x13ListReportsResponse\x12.\n" +
+	"\areports\x18\x01 \x03(\v2\x14.storage.AbuseReportR\areports\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"0\n" +
+	"\x0fPurgeURLRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\"*\n" +
+	"\x10PurgeURLResponse\x12\x16\n" +
+	"\x06purged\x18\x01 \x01(\bR\x06purged\"M\n" +
+	"\x15DeleteUserDataRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\"\xb5\x01\n" +
+	"\x16DeleteUserDataResponse\x12!\n" +
+	"\fdeleted_urls\x18\x01 \x01(\x03R\vdeletedUrls\x12'\n" +
+	"\x0fscrubbed_clicks\x18\x02 \x01(\x03R\x0escrubbedClicks\x12\x1f\n" +
+	"\vshort_codes\x18\x03 \x03(\tR\n" +
+	"shortCodes\x12.\n" +
+	"\x13deleted_short_codes\x18\x04 \x03(\tR\x11deletedShortCodes\"\xa1\x01\n" +
+	"\x15UpdateMetadataRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x1b\n" +
+	"\timage_url\x18\x04 \x01(\tR\bimageUrl\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\"\x18\n" +
+	"\x16UpdateMetadataResponse2\xc5\x14\n" +
+	"\x0eStorageService\x12<\n" +
+	"\aSaveURL\x12\x17.storage.SaveURLRequest\x1a\x18.storage.SaveURLResponse\x129\n" +
+	"\x06GetURL\x12\x16.storage.GetURLRequest\x1a\x17.storage.GetURLResponse\x12Q\n" +
+	"\x0eIncrementClick\x12\x1e.storage.IncrementClickRequest\x1a\x1f.storage.IncrementClickResponse\x12?\n" +
+	"\bGetStats\x12\x18.storage.GetStatsRequest\x1a\x19.storage.GetStatsResponse\x12B\n" +
+	"\tDeleteURL\x12\x19.storage.DeleteURLRequest\x1a\x1a.storage.DeleteURLResponse\x12Z\n" +
+	"\x11FindByOriginalURL\x12!.storage.FindByOriginalURLRequest\x1a\".storage.FindByOriginalURLResponse\x12T\n" +
+	"\x0fGetCleanupStats\x12\x1f.storage.GetCleanupStatsRequest\x1a .storage.GetCleanupStatsResponse\x12c\n" +
+	"\x14BatchIncrementClicks\x12$.storage.BatchIncrementClicksRequest\x1a%.storage.BatchIncrementClicksResponse\x12?\n" +
+	"\bListURLs\x12\x18.storage.ListURLsRequest\x1a\x19.storage.ListURLsResponse\x12B\n" +
+	"\tCountURLs\x12\x19.storage.CountURLsRequest\x1a\x1a.storage.CountURLsResponse\x12?\n" +
+	"\bSaveURLs\x12\x18.storage.SaveURLsRequest\x1a\x19.storage.SaveURLsResponse\x12<\n" +
+	"\aGetURLs\x12\x17.storage.GetURLsRequest\x1a\x18.storage.GetURLsResponse\x12E\n" +
+	"\n" +
+	"GetTopURLs\x12\x1a.storage.GetTopURLsRequest\x1a\x1b.storage.GetTopURLsResponse\x12H\n" +
+	"\vRecordClick\x12\x1b.storage.RecordClickRequest\x1a\x1c.storage.RecordClickResponse\x12]\n" +
+	"\x12GetClickTimeSeries\x12\".storage.GetClickTimeSeriesRequest\x1a#.storage.GetClickTimeSeriesResponse\x12Z\n" +
+	"\x11GetClickBreakdown\x12!.storage.GetClickBreakdownRequest\x1a\".storage.GetClickBreakdownResponse\x12Q\n" +
+	"\x0eGetGlobalStats\x12\x1e.storage.GetGlobalStatsRequest\x1a\x1f.storage.GetGlobalStatsResponse\x12G\n" +
+	"\n" +
+	"ExportURLs\x12\x1a.storage.ExportURLsRequest\x1a\x1b.storage.ExportURLsResponse0\x01\x12G\n" +
+	"\n" +
+	"ImportURLs\x12\x1a.storage.ImportURLsRequest\x1a\x1b.storage.ImportURLsResponse(\x01\x12T\n" +
+	"\x0fAllocateIDRange\x12\x1f.storage.AllocateIDRangeRequest\x1a .storage.AllocateIDRangeResponse\x12<\n" +
+	"\aPopKeys\x12\x17.storage.PopKeysRequest\x1a\x18.storage.PopKeysResponse\x12E\n" +
+	"\n" +
+	"ClaimClick\x12\x1a.storage.ClaimClickRequest\x1a\x1b.storage.ClaimClickResponse\x12K\n" +
+	"\fSetURLStatus\x12\x1c.storage.SetURLStatusRequest\x1a\x1d.storage.SetURLStatusResponse\x12N\n" +
+	"\rGetURLHistory\x12\x1d.storage.GetURLHistoryRequest\x1a\x1e.storage.GetURLHistoryResponse\x12W\n" +
+	"\x10AddBlockedDomain\x12 .storage.AddBlockedDomainRequest\x1a!.storage.AddBlockedDomainResponse\x12`\n" +
+	"\x13RemoveBlockedDomain\x12#.storage.RemoveBlockedDomainRequest\x1a$.storage.RemoveBlockedDomainResponse\x12]\n" +
+	"\x12ListBlockedDomains\x12\".storage.ListBlockedDomainsRequest\x1a#.storage.ListBlockedDomainsResponse\x12B\n" +
+	"\tReportURL\x12\x19.storage.ReportURLRequest\x1a\x1a.storage.ReportURLResponse\x12H\n" +
+	"\vListReports\x12\x1b.storage.ListReportsRequest\x1a\x1c.storage.ListReportsResponse\x12?\n" +
+	"\bPurgeURL\x12\x18.storage.PurgeURLRequest\x1a\x19.storage.PurgeURLResponse\x12?\n" +
+	"\bListTags\x12\x18.storage.ListTagsRequest\x1a\x19.storage.ListTagsResponse\x12Q\n" +
+	"\x0eDeleteUserData\x12\x1e.storage.DeleteUserDataRequest\x1a\x1f.storage.DeleteUserDataResponse\x12N\n" +
+	"\rListStaleURLs\x12\x1d.storage.ListStaleURLsRequest\x1a\x1e.storage.ListStaleURLsResponse\x12Q\n" +
+	"\x0eUpdateMetadata\x12\x1e.storage.UpdateMetadataRequest\x1a\x1f.storage.UpdateMetadataResponseB\x13Z\x11./storage-serviceb\x06proto3"
+
+var (
+	file_storage_service_storage_proto_rawDescOnce sync.Once
+	file_storage_service_storage_proto_rawDescData []byte
+)
+
+func file_storage_service_storage_proto_rawDescGZIP() []byte {
+	file_storage_service_storage_proto_rawDescOnce.Do(func() {
+		file_storage_service_storage_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_storage_service_storage_proto_rawDesc), len(file_storage_service_storage_proto_rawDesc)))
+	})
+	return file_storage_service_storage_proto_rawDescData
+}
+
+var file_storage_service_storage_proto_msgTypes = make([]protoimpl.MessageInfo, 82)
+var file_storage_service_storage_proto_goTypes = []any{
+	(*SaveURLRequest)(nil),               // 0: storage.SaveURLRequest
+	(*RedirectRule)(nil),                 // 1: storage.RedirectRule
+	(*Variant)(nil),                      // 2: storage.Variant
+	(*SaveURLResponse)(nil),              // 3: storage.SaveURLResponse
+	(*GetURLRequest)(nil),                // 4: storage.GetURLRequest
+	(*GetURLResponse)(nil),               // 5: storage.GetURLResponse
+	(*IncrementClickRequest)(nil),        // 6: storage.IncrementClickRequest
+	(*IncrementClickResponse)(nil),       // 7: storage.IncrementClickResponse
+	(*GetStatsRequest)(nil),              // 8: storage.GetStatsRequest
+	(*GetStatsResponse)(nil),             // 9: storage.GetStatsResponse
+	(*DeleteURLRequest)(nil),             // 10: storage.DeleteURLRequest
+	(*DeleteURLResponse)(nil),            // 11: storage.DeleteURLResponse
+	(*FindByOriginalURLRequest)(nil),     // 12: storage.FindByOriginalURLRequest
+	(*FindByOriginalURLResponse)(nil),    // 13: storage.FindByOriginalURLResponse
+	(*GetCleanupStatsRequest)(nil),       // 14: storage.GetCleanupStatsRequest
+	(*GetCleanupStatsResponse)(nil),      // 15: storage.GetCleanupStatsResponse
+	(*ClickDelta)(nil),                   // 16: storage.ClickDelta
+	(*BatchIncrementClicksRequest)(nil),  // 17: storage.BatchIncrementClicksRequest
+	(*BatchIncrementClicksResponse)(nil), // 18: storage.BatchIncrementClicksResponse
+	(*ListURLsRequest)(nil),              // 19: storage.ListURLsRequest
+	(*URLSummary)(nil),                   // 20: storage.URLSummary
+	(*ListURLsResponse)(nil),             // 21: storage.ListURLsResponse
+	(*ListStaleURLsRequest)(nil),         // 22: storage.ListStaleURLsRequest
+	(*ListStaleURLsResponse)(nil),        // 23: storage.ListStaleURLsResponse
+	(*ListTagsRequest)(nil),              // 24: storage.ListTagsRequest
+	(*TagCount)(nil),                     // 25: storage.TagCount
+	(*ListTagsResponse)(nil),             // 26: storage.ListTagsResponse
+	(*CountURLsRequest)(nil),             // 27: storage.CountURLsRequest
+	(*CountURLsResponse)(nil),            // 28: storage.CountURLsResponse
+	(*SaveURLsRequest)(nil),              // 29: storage.SaveURLsRequest
+	(*SaveURLsResponse)(nil),             // 30: storage.SaveURLsResponse
+	(*GetURLsRequest)(nil),               // 31: storage.GetURLsRequest
+	(*GetURLsResponse)(nil),              // 32: storage.GetURLsResponse
+	(*GetTopURLsRequest)(nil),            // 33: storage.GetTopURLsRequest
+	(*GetTopURLsResponse)(nil),           // 34: storage.GetTopURLsResponse
+	(*GetGlobalStatsRequest)(nil),        // 35: storage.GetGlobalStatsRequest
+	(*GetGlobalStatsResponse)(nil),       // 36: storage.GetGlobalStatsResponse
+	(*ClickEvent)(nil),                   // 37: storage.ClickEvent
+	(*RecordClickRequest)(nil),           // 38: storage.RecordClickRequest
+	(*RecordClickResponse)(nil),          // 39: storage.RecordClickResponse
+	(*GetClickTimeSeriesRequest)(nil),    // 40: storage.GetClickTimeSeriesRequest
+	(*ClickBucket)(nil),                  // 41: storage.ClickBucket
+	(*GetClickTimeSeriesResponse)(nil),   // 42: storage.GetClickTimeSeriesResponse
+	(*GetClickBreakdownRequest)(nil),     // 43: storage.GetClickBreakdownRequest
+	(*BreakdownEntry)(nil),               // 44: storage.BreakdownEntry
+	(*GetClickBreakdownResponse)(nil),    // 45: storage.GetClickBreakdownResponse
+	(*ExportURLsRequest)(nil),            // 46: storage.ExportURLsRequest
+	(*ExportedURL)(nil),                  // 47: storage.ExportedURL
+	(*ExportURLsResponse)(nil),           // 48: storage.ExportURLsResponse
+	(*ImportURLsRequest)(nil),            // 49: storage.ImportURLsRequest
+	(*ImportRejection)(nil),              // 50: storage.ImportRejection
+	(*ImportURLsResponse)(nil),           // 51: storage.ImportURLsResponse
+	(*AllocateIDRangeRequest)(nil),       // 52: storage.AllocateIDRangeRequest
+	(*AllocateIDRangeResponse)(nil),      // 53: storage.AllocateIDRangeResponse
+	(*PopKeysRequest)(nil),               // 54: storage.PopKeysRequest
+	(*PopKeysResponse)(nil),              // 55: storage.PopKeysResponse
+	(*ClaimClickRequest)(nil),            // 56: storage.ClaimClickRequest
+	(*ClaimClickResponse)(nil),           // 57: storage.ClaimClickResponse
+	(*SetURLStatusRequest)(nil),          // 58: storage.SetURLStatusRequest
+	(*SetURLStatusResponse)(nil),         // 59: storage.SetURLStatusResponse
+	(*GetURLHistoryRequest)(nil),         // 60: storage.GetURLHistoryRequest
+	(*URLHistoryEntry)(nil),              // 61: storage.URLHistoryEntry
+	(*GetURLHistoryResponse)(nil),        // 62: storage.GetURLHistoryResponse
+	(*BlockedDomain)(nil),                // 63: storage.BlockedDomain
+	(*AddBlockedDomainRequest)(nil),      // 64: storage.AddBlockedDomainRequest
+	(*AddBlockedDomainResponse)(nil),     // 65: storage.AddBlockedDomainResponse
+	(*RemoveBlockedDomainRequest)(nil),   // 66: storage.RemoveBlockedDomainRequest
+	(*RemoveBlockedDomainResponse)(nil),  // 67: storage.RemoveBlockedDomainResponse
+	(*ListBlockedDomainsRequest)(nil),    // 68: storage.ListBlockedDomainsRequest
+	(*ListBlockedDomainsResponse)(nil),   // 69: storage.ListBlockedDomainsResponse
+	(*ReportURLRequest)(nil),             // 70: storage.ReportURLRequest
+	(*ReportURLResponse)(nil),            // 71: storage.ReportURLResponse
+	(*AbuseReport)(nil),                  // 72: storage.AbuseReport
+	(*ListReportsRequest)(nil),           // 73: storage.ListReportsRequest
+	(*ListReportsResponse)(nil),          // 74: storage.ListReportsResponse
+	(*PurgeURLRequest)(nil),              // 75: storage.PurgeURLRequest
+	(*PurgeURLResponse)(nil),             // 76: storage.PurgeURLResponse
+	(*DeleteUserDataRequest)(nil),        // 77: storage.DeleteUserDataRequest
+	(*DeleteUserDataResponse)(nil),       // 78: storage.DeleteUserDataResponse
+	(*UpdateMetadataRequest)(nil),        // 79: storage.UpdateMetadataRequest
+	(*UpdateMetadataResponse)(nil),       // 80: storage.UpdateMetadataResponse
+	nil,                                  // 81: storage.GetURLsResponse.UrlsEntry
+}
+var file_storage_service_storage_proto_depIdxs = []int32{
+	2,  // 0: storage.SaveURLRequest.variants:type_name -> storage.Variant
+	1,  // 1: storage.SaveURLRequest.rules:type_name -> storage.RedirectRule
+	2,  // 2: storage.GetURLResponse.variants:type_name -> storage.Variant
+	1,  // 3: storage.GetURLResponse.rules:type_name -> storage.RedirectRule
+	16, // 4: storage.BatchIncrementClicksRequest.deltas:type_name -> storage.ClickDelta
+	20, // 5: storage.ListURLsResponse.urls:type_name -> storage.URLSummary
+	20, // 6: storage.ListStaleURLsResponse.urls:type_name -> storage.URLSummary
+	25, // 7: storage.ListTagsResponse.tags:type_name -> storage.TagCount
+	0,  // 8: storage.SaveURLsRequest.urls:type_name -> storage.SaveURLRequest
+	81, // 9: storage.GetURLsResponse.urls:type_name -> storage.GetURLsResponse.UrlsEntry
+	20, // 10: storage.GetTopURLsResponse.urls:type_name -> storage.URLSummary
+	37, // 11: storage.RecordClickRequest.events:type_name -> storage.ClickEvent
+	41, // 12: storage.GetClickTimeSeriesResponse.buckets:type_name -> storage.ClickBucket
+	44, // 13: storage.GetClickBreakdownResponse.referrers:type_name -> storage.BreakdownEntry
+	44, // 14: storage.GetClickBreakdownResponse.countries:type_name -> storage.BreakdownEntry
+	44, // 15: storage.GetClickBreakdownResponse.browsers:type_name -> storage.BreakdownEntry
+	44, // 16: storage.GetClickBreakdownResponse.devices:type_name -> storage.BreakdownEntry
+	44, // 17: storage.GetClickBreakdownResponse.variants:type_name -> storage.BreakdownEntry
+	44, // 18: storage.GetClickBreakdownResponse.rules:type_name -> storage.BreakdownEntry
+	47, // 19: storage.ExportURLsResponse.urls:type_name -> storage.ExportedURL
+	47, // 20: storage.ImportURLsRequest.urls:type_name -> storage.ExportedURL
+	50, // 21: storage.ImportURLsResponse.rejections:type_name -> storage.ImportRejection
+	61, // 22: storage.GetURLHistoryResponse.entries:type_name -> storage.URLHistoryEntry
+	63, // 23: storage.AddBlockedDomainResponse.domain:type_name -> storage.BlockedDomain
+	63, // 24: storage.ListBlockedDomainsResponse.domains:type_name -> storage.BlockedDomain
+	72, // 25: storage.ListReportsResponse.reports:type_name -> storage.AbuseReport
+	5,  // 26: storage.GetURLsResponse.UrlsEntry.value:type_name -> storage.GetURLResponse
+	0,  // 27: storage.StorageService.SaveURL:input_type -> storage.SaveURLRequest
+	4,  // 28: storage.StorageService.GetURL:input_type -> storage.GetURLRequest
+	6,  // 29: storage.StorageService.IncrementClick:input_type -> storage.IncrementClickRequest
+	8,  // 30: storage.StorageService.GetStats:input_type -> storage.GetStatsRequest
+	10, // 31: storage.StorageService.DeleteURL:input_type -> storage.DeleteURLRequest
+	12, // 32: storage.StorageService.FindByOriginalURL:input_type -> storage.FindByOriginalURLRequest
+	14, // 33: storage.StorageService.GetCleanupStats:input_type -> storage.GetCleanupStatsRequest
+	17, // 34: storage.StorageService.BatchIncrementClicks:input_type -> storage.BatchIncrementClicksRequest
+	19, // 35: storage.StorageService.ListURLs:input_type -> storage.ListURLsRequest
+	27, // 36: storage.StorageService.CountURLs:input_type -> storage.CountURLsRequest
+	29, // 37: storage.StorageService.SaveURLs:input_type -> storage.SaveURLsRequest
+	31, // 38: storage.StorageService.GetURLs:input_type -> storage.GetURLsRequest
+	33, // 39: storage.StorageService.GetTopURLs:input_type -> storage.GetTopURLsRequest
+	38, // 40: storage.StorageService.RecordClick:input_type -> storage.RecordClickRequest
+	40, // 41: storage.StorageService.GetClickTimeSeries:input_type -> storage.GetClickTimeSeriesRequest
+	43, // 42: storage.StorageService.GetClickBreakdown:input_type -> storage.GetClickBreakdownRequest
+	35, // 43: storage.StorageService.GetGlobalStats:input_type -> storage.GetGlobalStatsRequest
+	46, // 44: storage.StorageService.ExportURLs:input_type -> storage.ExportURLsRequest
+	49, // 45: storage.StorageService.ImportURLs:input_type -> storage.ImportURLsRequest
+	52, // 46: storage.StorageService.AllocateIDRange:input_type -> storage.AllocateIDRangeRequest
+	54, // 47: storage.StorageService.PopKeys:input_type -> storage.PopKeysRequest
+	56, // 48: storage.StorageService.ClaimClick:input_type -> storage.ClaimClickRequest
+	58, // 49: storage.StorageService.SetURLStatus:input_type -> storage.SetURLStatusRequest
+	60, // 50: storage.StorageService.GetURLHistory:input_type -> storage.GetURLHistoryRequest
+	64, // 51: storage.StorageService.AddBlockedDomain:input_type -> storage.AddBlockedDomainRequest
+	66, // 52: storage.StorageService.RemoveBlockedDomain:input_type -> storage.RemoveBlockedDomainRequest
+	68, // 53: storage.StorageService.ListBlockedDomains:input_type -> storage.ListBlockedDomainsRequest
+	70, // 54: storage.StorageService.ReportURL:input_type -> storage.ReportURLRequest
+	73, // 55: storage.StorageService.ListReports:input_type -> storage.ListReportsRequest
+	75, // 56: storage.StorageService.PurgeURL:input_type -> storage.PurgeURLRequest
+	24, // 57: storage.StorageService.ListTags:input_type -> storage.ListTagsRequest
+	77, // 58: storage.StorageService.DeleteUserData:input_type -> storage.DeleteUserDataRequest
+	22, // 59: storage.StorageService.ListStaleURLs:input_type -> storage.ListStaleURLsRequest
+	79, // 60: storage.StorageService.UpdateMetadata:input_type -> storage.UpdateMetadataRequest
+	3,  // 61: storage.StorageService.SaveURL:output_type -> storage.SaveURLResponse
+	5,  // 62: storage.StorageService.GetURL:output_type -> storage.GetURLResponse
+	7,  // 63: storage.StorageService.IncrementClick:output_type -> storage.IncrementClickResponse
+	9,  // 64: storage.StorageService.GetStats:output_type -> storage.GetStatsResponse
+	11, // 65: storage.StorageService.DeleteURL:output_type -> storage.DeleteURLResponse
+	13, // 66: storage.StorageService.FindByOriginalURL:output_type -> storage.FindByOriginalURLResponse
+	15, // 67: storage.StorageService.GetCleanupStats:output_type -> storage.GetCleanupStatsResponse
+	18, // 68: storage.StorageService.BatchIncrementClicks:output_type -> storage.BatchIncrementClicksResponse
+	21, // 69: storage.StorageService.ListURLs:output_type -> storage.ListURLsResponse
+	28, // 70: storage.StorageService.CountURLs:output_type -> storage.CountURLsResponse
+	30, // 71: storage.StorageService.SaveURLs:output_type -> storage.SaveURLsResponse
+	32, // 72: storage.StorageService.GetURLs:output_type -> storage.GetURLsResponse
+	34, // 73: storage.StorageService.GetTopURLs:output_type -> storage.GetTopURLsResponse
+	39, // 74: storage.StorageService.RecordClick:output_type -> storage.RecordClickResponse
+	42, // 75: storage.StorageService.GetClickTimeSeries:output_type -> storage.GetClickTimeSeriesResponse
+	45, // 76: storage.StorageService.GetClickBreakdown:output_type -> storage.GetClickBreakdownResponse
+	36, // 77: storage.StorageService.GetGlobalStats:output_type -> storage.GetGlobalStatsResponse
+	48, // 78: storage.StorageService.ExportURLs:output_type -> storage.ExportURLsResponse
+	51, // 79: storage.StorageService.ImportURLs:output_type -> storage.ImportURLsResponse
+	53, // 80: storage.StorageService.AllocateIDRange:output_type -> storage.AllocateIDRangeResponse
+	55, // 81: storage.StorageService.PopKeys:output_type -> storage.PopKeysResponse
+	57, // 82: storage.StorageService.ClaimClick:output_type -> storage.ClaimClickResponse
+	59, // 83: storage.StorageService.SetURLStatus:output_type -> storage.SetURLStatusResponse
+	62, // 84: storage.StorageService.GetURLHistory:output_type -> storage.GetURLHistoryResponse
+	65, // 85: storage.StorageService.AddBlockedDomain:output_type -> storage.AddBlockedDomainResponse
+	67, // 86: storage.StorageService.RemoveBlockedDomain:output_type -> storage.RemoveBlockedDomainResponse
+	69, // 87: storage.StorageService.ListBlockedDomains:output_type -> storage.ListBlockedDomainsResponse
+	71, // 88: storage.StorageService.ReportURL:output_type -> storage.ReportURLResponse
+	74, // 89: storage.StorageService.ListReports:output_type -> storage.ListReportsResponse
+	76, // 90: storage.StorageService.PurgeURL:output_type -> storage.PurgeURLResponse
+	26, // 91: storage.StorageService.ListTags:output_type -> storage.ListTagsResponse
+	78, // 92: storage.StorageService.DeleteUserData:output_type -> storage.DeleteUserDataResponse
+	23, // 93: storage.StorageService.ListStaleURLs:output_type -> storage.ListStaleURLsResponse
+	80, // 94: storage.StorageService.UpdateMetadata:output_type -> storage.UpdateMetadataResponse
+	61, // [61:95] is the sub-list for method output_type
+	27, // [27:61] is the sub-list for method input_type
+	27, // [27:27] is the sub-list for extension type_name
+	27, // [27:27] is the sub-list for extension extendee
+	0,  // [0:27] is the sub-list for field type_name
+}
+
+func init() { file_storage_service_storage_proto_init() }
+func file_storage_service_storage_proto_init() {
+	if File_storage_service_storage_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_storage_service_storage_proto_rawDesc), len(file_storage_service_storage_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   82,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_storage_service_storage_proto_goTypes,
+		DependencyIndexes: file_storage_service_storage_proto_depIdxs,
+		MessageInfos:      file_storage_service_storage_proto_msgTypes,
+	}.Build()
+	File_storage_service_storage_proto = out.File
+	file_storage_service_storage_proto_goTypes = nil
+	file_storage_service_storage_proto_depIdxs = nil
+}
@@ -0,0 +1,1372 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v6.33.0
+// source: storage-service/storage.proto
+
+package storage_service
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	StorageService_SaveURL_FullMethodName              = "/storage.StorageService/SaveURL"
+	StorageService_GetURL_FullMethodName               = "/storage.StorageService/GetURL"
+	StorageService_IncrementClick_FullMethodName       = "/storage.StorageService/IncrementClick"
+	StorageService_GetStats_FullMethodName             = "/storage.StorageService/GetStats"
+	StorageService_DeleteURL_FullMethodName            = "/storage.StorageService/DeleteURL"
+	StorageService_FindByOriginalURL_FullMethodName    = "/storage.StorageService/FindByOriginalURL"
+	StorageService_GetCleanupStats_FullMethodName      = "/storage.StorageService/GetCleanupStats"
+	StorageService_BatchIncrementClicks_FullMethodName = "/storage.StorageService/BatchIncrementClicks"
+	StorageService_ListURLs_FullMethodName             = "/storage.StorageService/ListURLs"
+	StorageService_CountURLs_FullMethodName            = "/storage.StorageService/CountURLs"
+	StorageService_SaveURLs_FullMethodName             = "/storage.StorageService/SaveURLs"
+	StorageService_GetURLs_FullMethodName              = "/storage.StorageService/GetURLs"
+	StorageService_GetTopURLs_FullMethodName           = "/storage.StorageService/GetTopURLs"
+	StorageService_RecordClick_FullMethodName          = "/storage.StorageService/RecordClick"
+	StorageService_GetClickTimeSeries_FullMethodName   = "/storage.StorageService/GetClickTimeSeries"
+	StorageService_GetClickBreakdown_FullMethodName    = "/storage.StorageService/GetClickBreakdown"
+	StorageService_GetGlobalStats_FullMethodName       = "/storage.StorageService/GetGlobalStats"
+	StorageService_ExportURLs_FullMethodName           = "/storage.StorageService/ExportURLs"
+	StorageService_ImportURLs_FullMethodName           = "/storage.StorageService/ImportURLs"
+	StorageService_AllocateIDRange_FullMethodName      = "/storage.StorageService/AllocateIDRange"
+	StorageService_PopKeys_FullMethodName              = "/storage.StorageService/PopKeys"
+	StorageService_ClaimClick_FullMethodName           = "/storage.StorageService/ClaimClick"
+	StorageService_SetURLStatus_FullMethodName         = "/storage.StorageService/SetURLStatus"
+	StorageService_GetURLHistory_FullMethodName        = "/storage.StorageService/GetURLHistory"
+	StorageService_AddBlockedDomain_FullMethodName     = "/storage.StorageService/AddBlockedDomain"
+	StorageService_RemoveBlockedDomain_FullMethodName  = "/storage.StorageService/RemoveBlockedDomain"
+	StorageService_ListBlockedDomains_FullMethodName   = "/storage.StorageService/ListBlockedDomains"
+	StorageService_ReportURL_FullMethodName            = "/storage.StorageService/ReportURL"
+	StorageService_ListReports_FullMethodName          = "/storage.StorageService/ListReports"
+	StorageService_PurgeURL_FullMethodName             = "/storage.StorageService/PurgeURL"
+	StorageService_ListTags_FullMethodName             = "/storage.StorageService/ListTags"
+	StorageService_DeleteUserData_FullMethodName       = "/storage.StorageService/DeleteUserData"
+	StorageService_ListStaleURLs_FullMethodName        = "/storage.StorageService/ListStaleURLs"
+	StorageService_UpdateMetadata_FullMethodName       = "/storage.StorageService/UpdateMetadata"
+)
+
+// StorageServiceClient is the client API for StorageService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type StorageServiceClient interface {
+	SaveURL(ctx context.Context, in *SaveURLRequest, opts ...grpc.CallOption) (*SaveURLResponse, error)
+	GetURL(ctx context.Context, in *GetURLRequest, opts ...grpc.CallOption) (*GetURLResponse, error)
+	IncrementClick(ctx context.Context, in *IncrementClickRequest, opts ...grpc.CallOption) (*IncrementClickResponse, error)
+	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error)
+	DeleteURL(ctx context.Context, in *DeleteURLRequest, opts ...grpc.CallOption) (*DeleteURLResponse, error)
+	FindByOriginalURL(ctx context.Context, in *FindByOriginalURLRequest, opts ...grpc.CallOption) (*FindByOriginalURLResponse, error)
+	GetCleanupStats(ctx context.Context, in *GetCleanupStatsRequest, opts ...grpc.CallOption) (*GetCleanupStatsResponse, error)
+	BatchIncrementClicks(ctx context.Context, in *BatchIncrementClicksRequest, opts ...grpc.CallOption) (*BatchIncrementClicksResponse, error)
+	ListURLs(ctx context.Context, in *ListURLsRequest, opts ...grpc.CallOption) (*ListURLsResponse, error)
+	CountURLs(ctx context.Context, in *CountURLsRequest, opts ...grpc.CallOption) (*CountURLsResponse, error)
+	SaveURLs(ctx context.Context, in *SaveURLsRequest, opts ...grpc.CallOption) (*SaveURLsResponse, error)
+	GetURLs(ctx context.Context, in *GetURLsRequest, opts ...grpc.CallOption) (*GetURLsResponse, error)
+	GetTopURLs(ctx context.Context, in *GetTopURLsRequest, opts ...grpc.CallOption) (*GetTopURLsResponse, error)
+	RecordClick(ctx context.Context, in *RecordClickRequest, opts ...grpc.CallOption) (*RecordClickResponse, error)
+	GetClickTimeSeries(ctx context.Context, in *GetClickTimeSeriesRequest, opts ...grpc.CallOption) (*GetClickTimeSeriesResponse, error)
+	GetClickBreakdown(ctx context.Context, in *GetClickBreakdownRequest, opts ...grpc.CallOption) (*GetClickBreakdownResponse, error)
+	GetGlobalStats(ctx context.Context, in *GetGlobalStatsRequest, opts ...grpc.CallOption) (*GetGlobalStatsResponse, error)
+	ExportURLs(ctx context.Context, in *ExportURLsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExportURLsResponse], error)
+	ImportURLs(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImportURLsRequest, ImportURLsResponse], error)
+	AllocateIDRange(ctx context.Context, in *AllocateIDRangeRequest, opts ...grpc.CallOption) (*AllocateIDRangeResponse, error)
+	PopKeys(ctx context.Context, in *PopKeysRequest, opts ...grpc.CallOption) (*PopKeysResponse, error)
+	ClaimClick(ctx context.Context, in *ClaimClickRequest, opts ...grpc.CallOption) (*ClaimClickResponse, error)
+	SetURLStatus(ctx context.Context, in *SetURLStatusRequest, opts ...grpc.CallOption) (*SetURLStatusResponse, error)
+	GetURLHistory(ctx context.Context, in *GetURLHistoryRequest, opts ...grpc.CallOption) (*GetURLHistoryResponse, error)
+	AddBlockedDomain(ctx context.Context, in *AddBlockedDomainRequest, opts ...grpc.CallOption) (*AddBlockedDomainResponse, error)
+	RemoveBlockedDomain(ctx context.Context, in *RemoveBlockedDomainRequest, opts ...grpc.CallOption) (*RemoveBlockedDomainResponse, error)
+	ListBlockedDomains(ctx context.Context, in *ListBlockedDomainsRequest, opts ...grpc.CallOption) (*ListBlockedDomainsResponse, error)
+	ReportURL(ctx context.Context, in *ReportURLRequest, opts ...grpc.CallOption) (*ReportURLResponse, error)
+	ListReports(ctx context.Context, in *ListReportsRequest, opts ...grpc.CallOption) (*ListReportsResponse, error)
+	PurgeURL(ctx context.Context, in *PurgeURLRequest, opts ...grpc.CallOption) (*PurgeURLResponse, error)
+	ListTags(ctx context.Context, in *ListTagsRequest, opts ...grpc.CallOption) (*ListTagsResponse, error)
+	DeleteUserData(ctx context.Context, in *DeleteUserDataRequest, opts ...grpc.CallOption) (*DeleteUserDataResponse, error)
+	ListStaleURLs(ctx context.Context, in *ListStaleURLsRequest, opts ...grpc.CallOption) (*ListStaleURLsResponse, error)
+	UpdateMetadata(ctx context.Context, in *UpdateMetadataRequest, opts ...grpc.CallOption) (*UpdateMetadataResponse, error)
+}
+
+type storageServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewStorageServiceClient(cc grpc.ClientConnInterface) StorageServiceClient {
+	return &storageServiceClient{cc}
+}
+
+func (c *storageServiceClient) SaveURL(ctx context.Context, in *SaveURLRequest, opts ...grpc.CallOption) (*SaveURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SaveURLResponse)
+	err := c.cc.Invoke(ctx, StorageService_SaveURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) GetURL(ctx context.Context, in *GetURLRequest, opts ...grpc.CallOption) (*GetURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetURLResponse)
+	err := c.cc.Invoke(ctx, StorageService_GetURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) IncrementClick(ctx context.Context, in *IncrementClickRequest, opts ...grpc.CallOption) (*IncrementClickResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IncrementClickResponse)
+	err := c.cc.Invoke(ctx, StorageService_IncrementClick_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStatsResponse)
+	err := c.cc.Invoke(ctx, StorageService_GetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) DeleteURL(ctx context.Context, in *DeleteURLRequest, opts ...grpc.CallOption) (*DeleteURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteURLResponse)
+	err := c.cc.Invoke(ctx, StorageService_DeleteURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) FindByOriginalURL(ctx context.Context, in *FindByOriginalURLRequest, opts ...grpc.CallOption) (*FindByOriginalURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FindByOriginalURLResponse)
+	err := c.cc.Invoke(ctx, StorageService_FindByOriginalURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) GetCleanupStats(ctx context.Context, in *GetCleanupStatsRequest, opts ...grpc.CallOption) (*GetCleanupStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetCleanupStatsResponse)
+	err := c.cc.Invoke(ctx, StorageService_GetCleanupStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) BatchIncrementClicks(ctx context.Context, in *BatchIncrementClicksRequest, opts ...grpc.CallOption) (*BatchIncrementClicksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchIncrementClicksResponse)
+	err := c.cc.Invoke(ctx, StorageService_BatchIncrementClicks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) ListURLs(ctx context.Context, in *ListURLsRequest, opts ...grpc.CallOption) (*ListURLsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListURLsResponse)
+	err := c.cc.Invoke(ctx, StorageService_ListURLs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) CountURLs(ctx context.Context, in *CountURLsRequest, opts ...grpc.CallOption) (*CountURLsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CountURLsResponse)
+	err := c.cc.Invoke(ctx, StorageService_CountURLs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) SaveURLs(ctx context.Context, in *SaveURLsRequest, opts ...grpc.CallOption) (*SaveURLsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SaveURLsResponse)
+	err := c.cc.Invoke(ctx, StorageService_SaveURLs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) GetURLs(ctx context.Context, in *GetURLsRequest, opts ...grpc.CallOption) (*GetURLsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetURLsResponse)
+	err := c.cc.Invoke(ctx, StorageService_GetURLs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) GetTopURLs(ctx context.Context, in *GetTopURLsRequest, opts ...grpc.CallOption) (*GetTopURLsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetTopURLsResponse)
+	err := c.cc.Invoke(ctx, StorageService_GetTopURLs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) RecordClick(ctx context.Context, in *RecordClickRequest, opts ...grpc.CallOption) (*RecordClickResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RecordClickResponse)
+	err := c.cc.Invoke(ctx, StorageService_RecordClick_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) GetClickTimeSeries(ctx context.Context, in *GetClickTimeSeriesRequest, opts ...grpc.CallOption) (*GetClickTimeSeriesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetClickTimeSeriesResponse)
+	err := c.cc.Invoke(ctx, StorageService_GetClickTimeSeries_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) GetClickBreakdown(ctx context.Context, in *GetClickBreakdownRequest, opts ...grpc.CallOption) (*GetClickBreakdownResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetClickBreakdownResponse)
+	err := c.cc.Invoke(ctx, StorageService_GetClickBreakdown_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) GetGlobalStats(ctx context.Context, in *GetGlobalStatsRequest, opts ...grpc.CallOption) (*GetGlobalStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetGlobalStatsResponse)
+	err := c.cc.Invoke(ctx, StorageService_GetGlobalStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) ExportURLs(ctx context.Context, in *ExportURLsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExportURLsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &StorageService_ServiceDesc.Streams[0], StorageService_ExportURLs_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportURLsRequest, ExportURLsResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StorageService_ExportURLsClient = grpc.ServerStreamingClient[ExportURLsResponse]
+
+func (c *storageServiceClient) ImportURLs(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImportURLsRequest, ImportURLsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &StorageService_ServiceDesc.Streams[1], StorageService_ImportURLs_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ImportURLsRequest, ImportURLsResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StorageService_ImportURLsClient = grpc.ClientStreamingClient[ImportURLsRequest, ImportURLsResponse]
+
+func (c *storageServiceClient) AllocateIDRange(ctx context.Context, in *AllocateIDRangeRequest, opts ...grpc.CallOption) (*AllocateIDRangeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AllocateIDRangeResponse)
+	err := c.cc.Invoke(ctx, StorageService_AllocateIDRange_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) PopKeys(ctx context.Context, in *PopKeysRequest, opts ...grpc.CallOption) (*PopKeysResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PopKeysResponse)
+	err := c.cc.Invoke(ctx, StorageService_PopKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) ClaimClick(ctx context.Context, in *ClaimClickRequest, opts ...grpc.CallOption) (*ClaimClickResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClaimClickResponse)
+	err := c.cc.Invoke(ctx, StorageService_ClaimClick_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) SetURLStatus(ctx context.Context, in *SetURLStatusRequest, opts ...grpc.CallOption) (*SetURLStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetURLStatusResponse)
+	err := c.cc.Invoke(ctx, StorageService_SetURLStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) GetURLHistory(ctx context.Context, in *GetURLHistoryRequest, opts ...grpc.CallOption) (*GetURLHistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetURLHistoryResponse)
+	err := c.cc.Invoke(ctx, StorageService_GetURLHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) AddBlockedDomain(ctx context.Context, in *AddBlockedDomainRequest, opts ...grpc.CallOption) (*AddBlockedDomainResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AddBlockedDomainResponse)
+	err := c.cc.Invoke(ctx, StorageService_AddBlockedDomain_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) RemoveBlockedDomain(ctx context.Context, in *RemoveBlockedDomainRequest, opts ...grpc.CallOption) (*RemoveBlockedDomainResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RemoveBlockedDomainResponse)
+	err := c.cc.Invoke(ctx, StorageService_RemoveBlockedDomain_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) ListBlockedDomains(ctx context.Context, in *ListBlockedDomainsRequest, opts ...grpc.CallOption) (*ListBlockedDomainsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListBlockedDomainsResponse)
+	err := c.cc.Invoke(ctx, StorageService_ListBlockedDomains_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) ReportURL(ctx context.Context, in *ReportURLRequest, opts ...grpc.CallOption) (*ReportURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReportURLResponse)
+	err := c.cc.Invoke(ctx, StorageService_ReportURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) ListReports(ctx context.Context, in *ListReportsRequest, opts ...grpc.CallOption) (*ListReportsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListReportsResponse)
+	err := c.cc.Invoke(ctx, StorageService_ListReports_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) PurgeURL(ctx context.Context, in *PurgeURLRequest, opts ...grpc.CallOption) (*PurgeURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PurgeURLResponse)
+	err := c.cc.Invoke(ctx, StorageService_PurgeURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) ListTags(ctx context.Context, in *ListTagsRequest, opts ...grpc.CallOption) (*ListTagsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTagsResponse)
+	err := c.cc.Invoke(ctx, StorageService_ListTags_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) DeleteUserData(ctx context.Context, in *DeleteUserDataRequest, opts ...grpc.CallOption) (*DeleteUserDataResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteUserDataResponse)
+	err := c.cc.Invoke(ctx, StorageService_DeleteUserData_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) ListStaleURLs(ctx context.Context, in *ListStaleURLsRequest, opts ...grpc.CallOption) (*ListStaleURLsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListStaleURLsResponse)
+	err := c.cc.Invoke(ctx, StorageService_ListStaleURLs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) UpdateMetadata(ctx context.Context, in *UpdateMetadataRequest, opts ...grpc.CallOption) (*UpdateMetadataResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateMetadataResponse)
+	err := c.cc.Invoke(ctx, StorageService_UpdateMetadata_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StorageServiceServer is the server API for StorageService service.
+// All implementations must embed UnimplementedStorageServiceServer
+// for forward compatibility.
+type StorageServiceServer interface {
+	SaveURL(context.Context, *SaveURLRequest) (*SaveURLResponse, error)
+	GetURL(context.Context, *GetURLRequest) (*GetURLResponse, error)
+	IncrementClick(context.Context, *IncrementClickRequest) (*IncrementClickResponse, error)
+	GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error)
+	DeleteURL(context.Context, *DeleteURLRequest) (*DeleteURLResponse, error)
+	FindByOriginalURL(context.Context, *FindByOriginalURLRequest) (*FindByOriginalURLResponse, error)
+	GetCleanupStats(context.Context, *GetCleanupStatsRequest) (*GetCleanupStatsResponse, error)
+	BatchIncrementClicks(context.Context, *BatchIncrementClicksRequest) (*BatchIncrementClicksResponse, error)
+	ListURLs(context.Context, *ListURLsRequest) (*ListURLsResponse, error)
+	CountURLs(context.Context, *CountURLsRequest) (*CountURLsResponse, error)
+	SaveURLs(context.Context, *SaveURLsRequest) (*SaveURLsResponse, error)
+	GetURLs(context.Context, *GetURLsRequest) (*GetURLsResponse, error)
+	GetTopURLs(context.Context, *GetTopURLsRequest) (*GetTopURLsResponse, error)
+	RecordClick(context.Context, *RecordClickRequest) (*RecordClickResponse, error)
+	GetClickTimeSeries(context.Context, *GetClickTimeSeriesRequest) (*GetClickTimeSeriesResponse, error)
+	GetClickBreakdown(context.Context, *GetClickBreakdownRequest) (*GetClickBreakdownResponse, error)
+	GetGlobalStats(context.Context, *GetGlobalStatsRequest) (*GetGlobalStatsResponse, error)
+	ExportURLs(*ExportURLsRequest, grpc.ServerStreamingServer[ExportURLsResponse]) error
+	ImportURLs(grpc.ClientStreamingServer[ImportURLsRequest, ImportURLsResponse]) error
+	AllocateIDRange(context.Context, *AllocateIDRangeRequest) (*AllocateIDRangeResponse, error)
+	PopKeys(context.Context, *PopKeysRequest) (*PopKeysResponse, error)
+	ClaimClick(context.Context, *ClaimClickRequest) (*ClaimClickResponse, error)
+	SetURLStatus(context.Context, *SetURLStatusRequest) (*SetURLStatusResponse, error)
+	GetURLHistory(context.Context, *GetURLHistoryRequest) (*GetURLHistoryResponse, error)
+	AddBlockedDomain(context.Context, *AddBlockedDomainRequest) (*AddBlockedDomainResponse, error)
+	RemoveBlockedDomain(context.Context, *RemoveBlockedDomainRequest) (*RemoveBlockedDomainResponse, error)
+	ListBlockedDomains(context.Context, *ListBlockedDomainsRequest) (*ListBlockedDomainsResponse, error)
+	ReportURL(context.Context, *ReportURLRequest) (*ReportURLResponse, error)
+	ListReports(context.Context, *ListReportsRequest) (*ListReportsResponse, error)
+	PurgeURL(context.Context, *PurgeURLRequest) (*PurgeURLResponse, error)
+	ListTags(context.Context, *ListTagsRequest) (*ListTagsResponse, error)
+	DeleteUserData(context.Context, *DeleteUserDataRequest) (*DeleteUserDataResponse, error)
+	ListStaleURLs(context.Context, *ListStaleURLsRequest) (*ListStaleURLsResponse, error)
+	UpdateMetadata(context.Context, *UpdateMetadataRequest) (*UpdateMetadataResponse, error)
+	mustEmbedUnimplementedStorageServiceServer()
+}
+
+// UnimplementedStorageServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedStorageServiceServer struct{}
+
+func (UnimplementedStorageServiceServer) SaveURL(context.Context, *SaveURLRequest) (*SaveURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SaveURL not implemented")
+}
+func (UnimplementedStorageServiceServer) GetURL(context.Context, *GetURLRequest) (*GetURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetURL not implemented")
+}
+func (UnimplementedStorageServiceServer) IncrementClick(context.Context, *IncrementClickRequest) (*IncrementClickResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IncrementClick not implemented")
+}
+func (UnimplementedStorageServiceServer) GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedStorageServiceServer) DeleteURL(context.Context, *DeleteURLRequest) (*DeleteURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteURL not implemented")
+}
+func (UnimplementedStorageServiceServer) FindByOriginalURL(context.Context, *FindByOriginalURLRequest) (*FindByOriginalURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindByOriginalURL not implemented")
+}
+func (UnimplementedStorageServiceServer) GetCleanupStats(context.Context, *GetCleanupStatsRequest) (*GetCleanupStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCleanupStats not implemented")
+}
+func (UnimplementedStorageServiceServer) BatchIncrementClicks(context.Context, *BatchIncrementClicksRequest) (*BatchIncrementClicksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchIncrementClicks not implemented")
+}
+func (UnimplementedStorageServiceServer) ListURLs(context.Context, *ListURLsRequest) (*ListURLsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListURLs not implemented")
+}
+func (UnimplementedStorageServiceServer) CountURLs(context.Context, *CountURLsRequest) (*CountURLsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CountURLs not implemented")
+}
+func (UnimplementedStorageServiceServer) SaveURLs(context.Context, *SaveURLsRequest) (*SaveURLsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SaveURLs not implemented")
+}
+func (UnimplementedStorageServiceServer) GetURLs(context.Context, *GetURLsRequest) (*GetURLsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetURLs not implemented")
+}
+func (UnimplementedStorageServiceServer) GetTopURLs(context.Context, *GetTopURLsRequest) (*GetTopURLsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTopURLs not implemented")
+}
+func (UnimplementedStorageServiceServer) RecordClick(context.Context, *RecordClickRequest) (*RecordClickResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecordClick not implemented")
+}
+func (UnimplementedStorageServiceServer) GetClickTimeSeries(context.Context, *GetClickTimeSeriesRequest) (*GetClickTimeSeriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetClickTimeSeries not implemented")
+}
+func (UnimplementedStorageServiceServer) GetClickBreakdown(context.Context, *GetClickBreakdownRequest) (*GetClickBreakdownResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetClickBreakdown not implemented")
+}
+func (UnimplementedStorageServiceServer) GetGlobalStats(context.Context, *GetGlobalStatsRequest) (*GetGlobalStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGlobalStats not implemented")
+}
+func (UnimplementedStorageServiceServer) ExportURLs(*ExportURLsRequest, grpc.ServerStreamingServer[ExportURLsResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ExportURLs not implemented")
+}
+func (UnimplementedStorageServiceServer) ImportURLs(grpc.ClientStreamingServer[ImportURLsRequest, ImportURLsResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ImportURLs not implemented")
+}
+func (UnimplementedStorageServiceServer) AllocateIDRange(context.Context, *AllocateIDRangeRequest) (*AllocateIDRangeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AllocateIDRange not implemented")
+}
+func (UnimplementedStorageServiceServer) PopKeys(context.Context, *PopKeysRequest) (*PopKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PopKeys not implemented")
+}
+func (UnimplementedStorageServiceServer) ClaimClick(context.Context, *ClaimClickRequest) (*ClaimClickResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClaimClick not implemented")
+}
+func (UnimplementedStorageServiceServer) SetURLStatus(context.Context, *SetURLStatusRequest) (*SetURLStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetURLStatus not implemented")
+}
+func (UnimplementedStorageServiceServer) GetURLHistory(context.Context, *GetURLHistoryRequest) (*GetURLHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetURLHistory not implemented")
+}
+func (UnimplementedStorageServiceServer) AddBlockedDomain(context.Context, *AddBlockedDomainRequest) (*AddBlockedDomainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddBlockedDomain not implemented")
+}
+func (UnimplementedStorageServiceServer) RemoveBlockedDomain(context.Context, *RemoveBlockedDomainRequest) (*RemoveBlockedDomainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveBlockedDomain not implemented")
+}
+func (UnimplementedStorageServiceServer) ListBlockedDomains(context.Context, *ListBlockedDomainsRequest) (*ListBlockedDomainsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBlockedDomains not implemented")
+}
+func (UnimplementedStorageServiceServer) ReportURL(context.Context, *ReportURLRequest) (*ReportURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportURL not implemented")
+}
+func (UnimplementedStorageServiceServer) ListReports(context.Context, *ListReportsRequest) (*ListReportsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListReports not implemented")
+}
+func (UnimplementedStorageServiceServer) PurgeURL(context.Context, *PurgeURLRequest) (*PurgeURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PurgeURL not implemented")
+}
+func (UnimplementedStorageServiceServer) ListTags(context.Context, *ListTagsRequest) (*ListTagsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTags not implemented")
+}
+func (UnimplementedStorageServiceServer) DeleteUserData(context.Context, *DeleteUserDataRequest) (*DeleteUserDataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteUserData not implemented")
+}
+func (UnimplementedStorageServiceServer) ListStaleURLs(context.Context, *ListStaleURLsRequest) (*ListStaleURLsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListStaleURLs not implemented")
+}
+func (UnimplementedStorageServiceServer) UpdateMetadata(context.Context, *UpdateMetadataRequest) (*UpdateMetadataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateMetadata not implemented")
+}
+func (UnimplementedStorageServiceServer) mustEmbedUnimplementedStorageServiceServer() {}
+func (UnimplementedStorageServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeStorageServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to StorageServiceServer will
+// result in compilation errors.
+type UnsafeStorageServiceServer interface {
+	mustEmbedUnimplementedStorageServiceServer()
+}
+
+func RegisterStorageServiceServer(s grpc.ServiceRegistrar, srv StorageServiceServer) {
+	// If the following call pancis, it indicates UnimplementedStorageServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&StorageService_ServiceDesc, srv)
+}
+
+func _StorageService_SaveURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SaveURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).SaveURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_SaveURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).SaveURL(ctx, req.(*SaveURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_GetURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).GetURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_GetURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).GetURL(ctx, req.(*GetURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_IncrementClick_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IncrementClickRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).IncrementClick(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_IncrementClick_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).IncrementClick(ctx, req.(*IncrementClickRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).GetStats(ctx, req.(*GetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_DeleteURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).DeleteURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_DeleteURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).DeleteURL(ctx, req.(*DeleteURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_FindByOriginalURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindByOriginalURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).FindByOriginalURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_FindByOriginalURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).FindByOriginalURL(ctx, req.(*FindByOriginalURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_GetCleanupStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCleanupStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).GetCleanupStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_GetCleanupStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).GetCleanupStats(ctx, req.(*GetCleanupStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_BatchIncrementClicks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchIncrementClicksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).BatchIncrementClicks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_BatchIncrementClicks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).BatchIncrementClicks(ctx, req.(*BatchIncrementClicksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_ListURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListURLsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).ListURLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_ListURLs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).ListURLs(ctx, req.(*ListURLsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_CountURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CountURLsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).CountURLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_CountURLs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).CountURLs(ctx, req.(*CountURLsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_SaveURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SaveURLsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).SaveURLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_SaveURLs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).SaveURLs(ctx, req.(*SaveURLsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_GetURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetURLsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).GetURLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_GetURLs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).GetURLs(ctx, req.(*GetURLsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_GetTopURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTopURLsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).GetTopURLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_GetTopURLs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).GetTopURLs(ctx, req.(*GetTopURLsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_RecordClick_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecordClickRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).RecordClick(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_RecordClick_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).RecordClick(ctx, req.(*RecordClickRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_GetClickTimeSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetClickTimeSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).GetClickTimeSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_GetClickTimeSeries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).GetClickTimeSeries(ctx, req.(*GetClickTimeSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_GetClickBreakdown_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetClickBreakdownRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).GetClickBreakdown(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_GetClickBreakdown_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).GetClickBreakdown(ctx, req.(*GetClickBreakdownRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_GetGlobalStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGlobalStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).GetGlobalStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_GetGlobalStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).GetGlobalStats(ctx, req.(*GetGlobalStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_ExportURLs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportURLsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageServiceServer).ExportURLs(m, &grpc.GenericServerStream[ExportURLsRequest, ExportURLsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StorageService_ExportURLsServer = grpc.ServerStreamingServer[ExportURLsResponse]
+
+func _StorageService_ImportURLs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(StorageServiceServer).ImportURLs(&grpc.GenericServerStream[ImportURLsRequest, ImportURLsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StorageService_ImportURLsServer = grpc.ClientStreamingServer[ImportURLsRequest, ImportURLsResponse]
+
+func _StorageService_AllocateIDRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AllocateIDRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).AllocateIDRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_AllocateIDRange_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).AllocateIDRange(ctx, req.(*AllocateIDRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_PopKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PopKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).PopKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_PopKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).PopKeys(ctx, req.(*PopKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_ClaimClick_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClaimClickRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).ClaimClick(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_ClaimClick_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).ClaimClick(ctx, req.(*ClaimClickRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_SetURLStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetURLStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).SetURLStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_SetURLStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).SetURLStatus(ctx, req.(*SetURLStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_GetURLHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetURLHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).GetURLHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_GetURLHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).GetURLHistory(ctx, req.(*GetURLHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_AddBlockedDomain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddBlockedDomainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).AddBlockedDomain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_AddBlockedDomain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).AddBlockedDomain(ctx, req.(*AddBlockedDomainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_RemoveBlockedDomain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveBlockedDomainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).RemoveBlockedDomain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_RemoveBlockedDomain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).RemoveBlockedDomain(ctx, req.(*RemoveBlockedDomainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_ListBlockedDomains_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBlockedDomainsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).ListBlockedDomains(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_ListBlockedDomains_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).ListBlockedDomains(ctx, req.(*ListBlockedDomainsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_ReportURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).ReportURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_ReportURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).ReportURL(ctx, req.(*ReportURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_ListReports_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListReportsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).ListReports(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_ListReports_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).ListReports(ctx, req.(*ListReportsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_PurgeURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PurgeURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).PurgeURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_PurgeURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).PurgeURL(ctx, req.(*PurgeURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_ListTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTagsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).ListTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_ListTags_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).ListTags(ctx, req.(*ListTagsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_DeleteUserData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteUserDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).DeleteUserData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_DeleteUserData_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).DeleteUserData(ctx, req.(*DeleteUserDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_ListStaleURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListStaleURLsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).ListStaleURLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_ListStaleURLs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).ListStaleURLs(ctx, req.(*ListStaleURLsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_UpdateMetadata_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateMetadataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).UpdateMetadata(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_UpdateMetadata_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).UpdateMetadata(ctx, req.(*UpdateMetadataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// StorageService_ServiceDesc is the grpc.ServiceDesc for StorageService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var StorageService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "storage.StorageService",
+	HandlerType: (*StorageServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SaveURL",
+			Handler:    _StorageService_SaveURL_Handler,
+		},
+		{
+			MethodName: "GetURL",
+			Handler:    _StorageService_GetURL_Handler,
+		},
+		{
+			MethodName: "IncrementClick",
+			Handler:    _StorageService_IncrementClick_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _StorageService_GetStats_Handler,
+		},
+		{
+			MethodName: "DeleteURL",
+			Handler:    _StorageService_DeleteURL_Handler,
+		},
+		{
+			MethodName: "FindByOriginalURL",
+			Handler:    _StorageService_FindByOriginalURL_Handler,
+		},
+		{
+			MethodName: "GetCleanupStats",
+			Handler:    _StorageService_GetCleanupStats_Handler,
+		},
+		{
+			MethodName: "BatchIncrementClicks",
+			Handler:    _StorageService_BatchIncrementClicks_Handler,
+		},
+		{
+			MethodName: "ListURLs",
+			Handler:    _StorageService_ListURLs_Handler,
+		},
+		{
+			MethodName: "CountURLs",
+			Handler:    _StorageService_CountURLs_Handler,
+		},
+		{
+			MethodName: "SaveURLs",
+			Handler:    _StorageService_SaveURLs_Handler,
+		},
+		{
+			MethodName: "GetURLs",
+			Handler:    _StorageService_GetURLs_Handler,
+		},
+		{
+			MethodName: "GetTopURLs",
+			Handler:    _StorageService_GetTopURLs_Handler,
+		},
+		{
+			MethodName: "RecordClick",
+			Handler:    _StorageService_RecordClick_Handler,
+		},
+		{
+			MethodName: "GetClickTimeSeries",
+			Handler:    _StorageService_GetClickTimeSeries_Handler,
+		},
+		{
+			MethodName: "GetClickBreakdown",
+			Handler:    _StorageService_GetClickBreakdown_Handler,
+		},
+		{
+			MethodName: "GetGlobalStats",
+			Handler:    _StorageService_GetGlobalStats_Handler,
+		},
+		{
+			MethodName: "AllocateIDRange",
+			Handler:    _StorageService_AllocateIDRange_Handler,
+		},
+		{
+			MethodName: "PopKeys",
+			Handler:    _StorageService_PopKeys_Handler,
+		},
+		{
+			MethodName: "ClaimClick",
+			Handler:    _StorageService_ClaimClick_Handler,
+		},
+		{
+			MethodName: "SetURLStatus",
+			Handler:    _StorageService_SetURLStatus_Handler,
+		},
+		{
+			MethodName: "GetURLHistory",
+			Handler:    _StorageService_GetURLHistory_Handler,
+		},
+		{
+			MethodName: "AddBlockedDomain",
+			Handler:    _StorageService_AddBlockedDomain_Handler,
+		},
+		{
+			MethodName: "RemoveBlockedDomain",
+			Handler:    _StorageService_RemoveBlockedDomain_Handler,
+		},
+		{
+			MethodName: "ListBlockedDomains",
+			Handler:    _StorageService_ListBlockedDomains_Handler,
+		},
+		{
+			MethodName: "ReportURL",
+			Handler:    _StorageService_ReportURL_Handler,
+		},
+		{
+			MethodName: "ListReports",
+			Handler:    _StorageService_ListReports_Handler,
+		},
+		{
+			MethodName: "PurgeURL",
+			Handler:    _StorageService_PurgeURL_Handler,
+		},
+		{
+			MethodName: "ListTags",
+			Handler:    _StorageService_ListTags_Handler,
+		},
+		{
+			MethodName: "DeleteUserData",
+			Handler:    _StorageService_DeleteUserData_Handler,
+		},
+		{
+			MethodName: "ListStaleURLs",
+			Handler:    _StorageService_ListStaleURLs_Handler,
+		},
+		{
+			MethodName: "UpdateMetadata",
+			Handler:    _StorageService_UpdateMetadata_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExportURLs",
+			Handler:       _StorageService_ExportURLs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ImportURLs",
+			Handler:       _StorageService_ImportURLs_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "storage-service/storage.proto",
+}
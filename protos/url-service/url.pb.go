@@ -0,0 +1,3812 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        v6.33.0
+// source: url-service/url.proto
+
+package url_service
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ShortenRequest struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	OriginalUrl        string                 `protobuf:"bytes,1,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	CustomAlias        string                 `protobuf:"bytes,2,opt,name=custom_alias,json=customAlias,proto3" json:"custom_alias,omitempty"`
+	ReuseExisting      bool                   `protobuf:"varint,3,opt,name=reuse_existing,json=reuseExisting,proto3" json:"reuse_existing,omitempty"`                  // Return an existing short code for the same URL instead of creating a new one
+	TtlSeconds         int64                  `protobuf:"varint,4,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"`                           // Optional lifetime of the link, 0 means it never expires
+	WaitForPersistence bool                   `protobuf:"varint,5,opt,name=wait_for_persistence,json=waitForPersistence,proto3" json:"wait_for_persistence,omitempty"` // Return only after the URL is written to storage
+	MaxClicks          int64                  `protobuf:"varint,6,opt,name=max_clicks,json=maxClicks,proto3" json:"max_clicks,omitempty"`                              // Optional number of clicks after which the link stops working, 1 for single use
+	FallbackUrl        string                 `protobuf:"bytes,7,opt,name=fallback_url,json=fallbackUrl,proto3" json:"fallback_url,omitempty"`                         // Optional destination once the link has expired, reached max_clicks, been disabled or been deleted, instead of failing
+	NotBefore          string                 `protobuf:"bytes,8,opt,name=not_before,json=notBefore,proto3" json:"not_before,omitempty"`                               // Optional RFC3339 time the link starts working at
+	NotAfter           string                 `protobuf:"bytes,9,opt,name=not_after,json=notAfter,proto3" json:"not_after,omitempty"`                                  // Optional RFC3339 time the link stops working at, like ttl_seconds
+	ComingSoonUrl      string                 `protobuf:"bytes,10,opt,name=coming_soon_url,json=comingSoonUrl,proto3" json:"coming_soon_url,omitempty"`                // Optional destination before not_before, instead of not found
+	Variants           []*Variant             `protobuf:"bytes,11,rep,name=variants,proto3" json:"variants,omitempty"`                                                 // Optional weighted destinations splitting the traffic, instead of original_url
+	StickyVariants     bool                   `protobuf:"varint,12,opt,name=sticky_variants,json=stickyVariants,proto3" json:"sticky_variants,omitempty"`              // Keep each visitor on the variant they got first
+	Rules              []*RedirectRule        `protobuf:"bytes,13,rep,name=rules,proto3" json:"rules,omitempty"`                                                       // Optional conditional destinations, tried in order before original_url or variants
+	QueryTemplate      string                 `protobuf:"bytes,14,opt,name=query_template,json=queryTemplate,proto3" json:"query_template,omitempty"`                  // Optional query parameters added to the destination on redirect, e.g. utm_source=shortener&utm_campaign={code}; {code} and {variant} are filled in
+	Tags               []string               `protobuf:"bytes,15,rep,name=tags,proto3" json:"tags,omitempty"`                                                         // Optional labels to organize links by, trimmed and lowercased, up to 10
+	TenantId           string                 `protobuf:"bytes,16,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`                                 // Optional tenant whose codes the link is among, when the API key doesn't name one; empty for the default tenant
+	CodeStrategy       string                 `protobuf:"bytes,17,opt,name=code_strategy,json=codeStrategy,proto3" json:"code_strategy,omitempty"`                     // Optional random or hash, how the code is picked instead of the server's CODE_STRATEGY; ignored with custom_alias
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *ShortenRequest) Reset() {
+	*x = ShortenRequest{}
+	mi := &file_url_service_url_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShortenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShortenRequest) ProtoMessage() {}
+
+func (x *ShortenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShortenRequest.ProtoReflect.Descriptor instead.
+func (*ShortenRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ShortenRequest) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *ShortenRequest) GetCustomAlias() string {
+	if x != nil {
+		return x.CustomAlias
+	}
+	return ""
+}
+
+func (x *ShortenRequest) GetReuseExisting() bool {
+	if x != nil {
+		return x.ReuseExisting
+	}
+	return false
+}
+
+func (x *ShortenRequest) GetTtlSeconds() int64 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+func (x *ShortenRequest) GetWaitForPersistence() bool {
+	if x != nil {
+		return x.WaitForPersistence
+	}
+	return false
+}
+
+func (x *ShortenRequest) GetMaxClicks() int64 {
+	if x != nil {
+		return x.MaxClicks
+	}
+	return 0
+}
+
+func (x *ShortenRequest) GetFallbackUrl() string {
+	if x != nil {
+		return x.FallbackUrl
+	}
+	return ""
+}
+
+func (x *ShortenRequest) GetNotBefore() string {
+	if x != nil {
+		return x.NotBefore
+	}
+	return ""
+}
+
+func (x *ShortenRequest) GetNotAfter() string {
+	if x != nil {
+		return x.NotAfter
+	}
+	return ""
+}
+
+func (x *ShortenRequest) GetComingSoonUrl() string {
+	if x != nil {
+		return x.ComingSoonUrl
+	}
+	return ""
+}
+
+func (x *ShortenRequest) GetVariants() []*Variant {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+func (x *ShortenRequest) GetStickyVariants() bool {
+	if x != nil {
+		return x.StickyVariants
+	}
+	return false
+}
+
+func (x *ShortenRequest) GetRules() []*RedirectRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *ShortenRequest) GetQueryTemplate() string {
+	if x != nil {
+		return x.QueryTemplate
+	}
+	return ""
+}
+
+func (x *ShortenRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *ShortenRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *ShortenRequest) GetCodeStrategy() string {
+	if x != nil {
+		return x.CodeStrategy
+	}
+	return ""
+}
+
+// RedirectRule sends the visitors it matches to its own destination. A rule
+// matches when every matcher it sets does.
+type RedirectRule struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Device        string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`       // Optional: ios, android, mobile, tablet or desktop
+	Countries     []string               `protobuf:"bytes,2,rep,name=countries,proto3" json:"countries,omitempty"` // Optional ISO 3166-1 alpha-2 codes, matched against the visitor's country
+	Url           string                 `protobuf:"bytes,3,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RedirectRule) Reset() {
+	*x = RedirectRule{}
+	mi := &file_url_service_url_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RedirectRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RedirectRule) ProtoMessage() {}
+
+func (x *RedirectRule) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RedirectRule.ProtoReflect.Descriptor instead.
+func (*RedirectRule) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RedirectRule) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+func (x *RedirectRule) GetCountries() []string {
+	if x != nil {
+		return x.Countries
+	}
+	return nil
+}
+
+func (x *RedirectRule) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+// Variant is one destination of a split link. Each lookup picks one at
+// random in proportion to the weights.
+type Variant struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"` // Optional, defaults to a, b, c... in order
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Weight        int32                  `protobuf:"varint,3,opt,name=weight,proto3" json:"weight,omitempty"` // Relative share of the traffic, e.g. 80 and 20
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Variant) Reset() {
+	*x = Variant{}
+	mi := &file_url_service_url_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Variant) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Variant) ProtoMessage() {}
+
+func (x *Variant) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Variant.ProtoReflect.Descriptor instead.
+func (*Variant) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Variant) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Variant) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Variant) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+type ShortenResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	OriginalUrl   string                 `protobuf:"bytes,2,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	NormalizedUrl string                 `protobuf:"bytes,4,opt,name=normalized_url,json=normalizedUrl,proto3" json:"normalized_url,omitempty"` // The URL as stored after normalization
+	ShortUrl      string                 `protobuf:"bytes,5,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`                // Full short link, empty unless the service has a BASE_URL
+	CreatedAt     string                 `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`             // RFC3339, empty for reused codes
+	ExpiresAt     string                 `protobuf:"bytes,7,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`             // RFC3339, empty if the link never expires
+	Variants      []*Variant             `protobuf:"bytes,8,rep,name=variants,proto3" json:"variants,omitempty"`                                // Set for split links, as stored
+	Rules         []*RedirectRule        `protobuf:"bytes,9,rep,name=rules,proto3" json:"rules,omitempty"`                                      // As stored
+	QueryTemplate string                 `protobuf:"bytes,10,opt,name=query_template,json=queryTemplate,proto3" json:"query_template,omitempty"`
+	Tags          []string               `protobuf:"bytes,11,rep,name=tags,proto3" json:"tags,omitempty"`                         // Normalized and sorted
+	TenantId      string                 `protobuf:"bytes,12,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"` // Empty for the default tenant
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ShortenResponse) Reset() {
+	*x = ShortenResponse{}
+	mi := &file_url_service_url_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShortenResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShortenResponse) ProtoMessage() {}
+
+func (x *ShortenResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShortenResponse.ProtoReflect.Descriptor instead.
+func (*ShortenResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ShortenResponse) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *ShortenResponse) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *ShortenResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *ShortenResponse) GetNormalizedUrl() string {
+	if x != nil {
+		return x.NormalizedUrl
+	}
+	return ""
+}
+
+func (x *ShortenResponse) GetShortUrl() string {
+	if x != nil {
+		return x.ShortUrl
+	}
+	return ""
+}
+
+func (x *ShortenResponse) GetCreatedAt() string {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return ""
+}
+
+func (x *ShortenResponse) GetExpiresAt() string {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return ""
+}
+
+func (x *ShortenResponse) GetVariants() []*Variant {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+func (x *ShortenResponse) GetRules() []*RedirectRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *ShortenResponse) GetQueryTemplate() string {
+	if x != nil {
+		return x.QueryTemplate
+	}
+	return ""
+}
+
+func (x *ShortenResponse) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *ShortenResponse) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type GetOriginalRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	SkipStats     bool                   `protobuf:"varint,2,opt,name=skip_stats,json=skipStats,proto3" json:"skip_stats,omitempty"` // Resolve without counting a click, e.g. for monitoring, link checkers and previews; needs an API key, the gateway sets it for count_click=false
+	Referrer      string                 `protobuf:"bytes,3,opt,name=referrer,proto3" json:"referrer,omitempty"`                     // Optional, recorded with the click
+	UserAgent     string                 `protobuf:"bytes,4,opt,name=user_agent,json=userAgent,proto3" json:"user_agent,omitempty"`  // Optional, recorded with the click
+	Country       string                 `protobuf:"bytes,5,opt,name=country,proto3" json:"country,omitempty"`                       // Optional ISO 3166-1 alpha-2 code, recorded with the click
+	Prefetch      bool                   `protobuf:"varint,6,opt,name=prefetch,proto3" json:"prefetch,omitempty"`                    // A HEAD, prefetch or link preview request, counted as a bot click
+	VisitorId     string                 `protobuf:"bytes,7,opt,name=visitor_id,json=visitorId,proto3" json:"visitor_id,omitempty"`  // Optional stable ID of the visitor, e.g. from a cookie, for sticky variants; defaults to their IP and user agent
+	TenantId      string                 `protobuf:"bytes,8,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`     // Optional tenant the code belongs to, e.g. from the domain it was requested on; empty for the default tenant
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetOriginalRequest) Reset() {
+	*x = GetOriginalRequest{}
+	mi := &file_url_service_url_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetOriginalRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetOriginalRequest) ProtoMessage() {}
+
+func (x *GetOriginalRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetOriginalRequest.ProtoReflect.Descriptor instead.
+func (*GetOriginalRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetOriginalRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *GetOriginalRequest) GetSkipStats() bool {
+	if x != nil {
+		return x.SkipStats
+	}
+	return false
+}
+
+func (x *GetOriginalRequest) GetReferrer() string {
+	if x != nil {
+		return x.Referrer
+	}
+	return ""
+}
+
+func (x *GetOriginalRequest) GetUserAgent() string {
+	if x != nil {
+		return x.UserAgent
+	}
+	return ""
+}
+
+func (x *GetOriginalRequest) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *GetOriginalRequest) GetPrefetch() bool {
+	if x != nil {
+		return x.Prefetch
+	}
+	return false
+}
+
+func (x *GetOriginalRequest) GetVisitorId() string {
+	if x != nil {
+		return x.VisitorId
+	}
+	return ""
+}
+
+func (x *GetOriginalRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type GetOriginalResponse struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	OriginalUrl string                 `protobuf:"bytes,1,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	Found       bool                   `protobuf:"varint,2,opt,name=found,proto3" json:"found,omitempty"`
+	Error       string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	Expired     bool                   `protobuf:"varint,4,opt,name=expired,proto3" json:"expired,omitempty"`     // The code existed but its TTL has passed
+	Exhausted   bool                   `protobuf:"varint,5,opt,name=exhausted,proto3" json:"exhausted,omitempty"` // The code reached its max_clicks and has no fallback URL
+	Disabled    bool                   `protobuf:"varint,6,opt,name=disabled,proto3" json:"disabled,omitempty"`   // The code was turned off with SetURLStatus
+	Variant     string                 `protobuf:"bytes,7,opt,name=variant,proto3" json:"variant,omitempty"`      // Name of the variant picked, for split links
+	Rule        string                 `protobuf:"bytes,8,opt,name=rule,proto3" json:"rule,omitempty"`            // Number of the rule matched from 1, or "default", for links with rules
+	// How the code resolved: "destination", "coming_soon", or why it no longer
+	// does: "expired", "exhausted", "disabled" or "deleted". For those
+	// original_url is the fallback, if there is one.
+	Resolution    string `protobuf:"bytes,9,opt,name=resolution,proto3" json:"resolution,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetOriginalResponse) Reset() {
+	*x = GetOriginalResponse{}
+	mi := &file_url_service_url_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetOriginalResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetOriginalResponse) ProtoMessage() {}
+
+func (x *GetOriginalResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetOriginalResponse.ProtoReflect.Descriptor instead.
+func (*GetOriginalResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetOriginalResponse) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *GetOriginalResponse) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+func (x *GetOriginalResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *GetOriginalResponse) GetExpired() bool {
+	if x != nil {
+		return x.Expired
+	}
+	return false
+}
+
+func (x *GetOriginalResponse) GetExhausted() bool {
+	if x != nil {
+		return x.Exhausted
+	}
+	return false
+}
+
+func (x *GetOriginalResponse) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+func (x *GetOriginalResponse) GetVariant() string {
+	if x != nil {
+		return x.Variant
+	}
+	return ""
+}
+
+func (x *GetOriginalResponse) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *GetOriginalResponse) GetResolution() string {
+	if x != nil {
+		return x.Resolution
+	}
+	return ""
+}
+
+type StatsRequest struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode         string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	IncludeBreakdowns bool                   `protobuf:"varint,2,opt,name=include_breakdowns,json=includeBreakdowns,proto3" json:"include_breakdowns,omitempty"` // Also return the top referrers, countries, browsers and devices
+	IncludeMetadata   bool                   `protobuf:"varint,3,opt,name=include_metadata,json=includeMetadata,proto3" json:"include_metadata,omitempty"`       // Also return the destination page's title, description and image, see FETCH_METADATA
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *StatsRequest) Reset() {
+	*x = StatsRequest{}
+	mi := &file_url_service_url_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsRequest) ProtoMessage() {}
+
+func (x *StatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsRequest.ProtoReflect.Descriptor instead.
+func (*StatsRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *StatsRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *StatsRequest) GetIncludeBreakdowns() bool {
+	if x != nil {
+		return x.IncludeBreakdowns
+	}
+	return false
+}
+
+func (x *StatsRequest) GetIncludeMetadata() bool {
+	if x != nil {
+		return x.IncludeMetadata
+	}
+	return false
+}
+
+type BreakdownEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         string                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Clicks        int64                  `protobuf:"varint,2,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BreakdownEntry) Reset() {
+	*x = BreakdownEntry{}
+	mi := &file_url_service_url_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BreakdownEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BreakdownEntry) ProtoMessage() {}
+
+func (x *BreakdownEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BreakdownEntry.ProtoReflect.Descriptor instead.
+func (*BreakdownEntry) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *BreakdownEntry) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *BreakdownEntry) GetClicks() int64 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+type StatsResponse struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode  string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	ClickCount int64                  `protobuf:"varint,2,opt,name=click_count,json=clickCount,proto3" json:"click_count,omitempty"`
+	CreatedAt  string                 `protobuf:"bytes,3,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Error      string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	ExpiresAt  string                 `protobuf:"bytes,5,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	Expired    bool                   `protobuf:"varint,6,opt,name=expired,proto3" json:"expired,omitempty"`
+	// Set with include_breakdowns, over the click events storage still keeps,
+	// most clicks first
+	TopReferrers   []*BreakdownEntry `protobuf:"bytes,7,rep,name=top_referrers,json=topReferrers,proto3" json:"top_referrers,omitempty"` // By referring host
+	Countries      []*BreakdownEntry `protobuf:"bytes,8,rep,name=countries,proto3" json:"countries,omitempty"`
+	Browsers       []*BreakdownEntry `protobuf:"bytes,9,rep,name=browsers,proto3" json:"browsers,omitempty"`
+	Devices        []*BreakdownEntry `protobuf:"bytes,10,rep,name=devices,proto3" json:"devices,omitempty"`                                       // desktop, mobile, tablet, bot or other
+	UniqueClicks   int64             `protobuf:"varint,11,opt,name=unique_clicks,json=uniqueClicks,proto3" json:"unique_clicks,omitempty"`        // Clicks from distinct visitors, see UNIQUE_CLICK_WINDOW
+	BotClicks      int64             `protobuf:"varint,12,opt,name=bot_clicks,json=botClicks,proto3" json:"bot_clicks,omitempty"`                 // Bot and prefetch clicks, not included in click_count
+	Variants       []*BreakdownEntry `protobuf:"bytes,13,rep,name=variants,proto3" json:"variants,omitempty"`                                     // Set with include_breakdowns for split links, by variant name
+	Rules          []*BreakdownEntry `protobuf:"bytes,14,rep,name=rules,proto3" json:"rules,omitempty"`                                           // Set with include_breakdowns for links with rules, by rule matched
+	LastAccessedAt string            `protobuf:"bytes,15,opt,name=last_accessed_at,json=lastAccessedAt,proto3" json:"last_accessed_at,omitempty"` // Latest click by a person, empty if there has been none
+	// Set with include_metadata, from the destination page's <title> and Open
+	// Graph tags; empty until fetched or if the page has none
+	Title         string `protobuf:"bytes,16,opt,name=title,proto3" json:"title,omitempty"`
+	Description   string `protobuf:"bytes,17,opt,name=description,proto3" json:"description,omitempty"`
+	ImageUrl      string `protobuf:"bytes,18,opt,name=image_url,json=imageUrl,proto3" json:"image_url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatsResponse) Reset() {
+	*x = StatsResponse{}
+	mi := &file_url_service_url_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsResponse) ProtoMessage() {}
+
+func (x *StatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsResponse.ProtoReflect.Descriptor instead.
+func (*StatsResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *StatsResponse) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *StatsResponse) GetClickCount() int64 {
+	if x != nil {
+		return x.ClickCount
+	}
+	return 0
+}
+
+func (x *StatsResponse) GetCreatedAt() string {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return ""
+}
+
+func (x *StatsResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *StatsResponse) GetExpiresAt() string {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return ""
+}
+
+func (x *StatsResponse) GetExpired() bool {
+	if x != nil {
+		return x.Expired
+	}
+	return false
+}
+
+func (x *StatsResponse) GetTopReferrers() []*BreakdownEntry {
+	if x != nil {
+		return x.TopReferrers
+	}
+	return nil
+}
+
+func (x *StatsResponse) GetCountries() []*BreakdownEntry {
+	if x != nil {
+		return x.Countries
+	}
+	return nil
+}
+
+func (x *StatsResponse) GetBrowsers() []*BreakdownEntry {
+	if x != nil {
+		return x.Browsers
+	}
+	return nil
+}
+
+func (x *StatsResponse) GetDevices() []*BreakdownEntry {
+	if x != nil {
+		return x.Devices
+	}
+	return nil
+}
+
+func (x *StatsResponse) GetUniqueClicks() int64 {
+	if x != nil {
+		return x.UniqueClicks
+	}
+	return 0
+}
+
+func (x *StatsResponse) GetBotClicks() int64 {
+	if x != nil {
+		return x.BotClicks
+	}
+	return 0
+}
+
+func (x *StatsResponse) GetVariants() []*BreakdownEntry {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+func (x *StatsResponse) GetRules() []*BreakdownEntry {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *StatsResponse) GetLastAccessedAt() string {
+	if x != nil {
+		return x.LastAccessedAt
+	}
+	return ""
+}
+
+func (x *StatsResponse) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *StatsResponse) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *StatsResponse) GetImageUrl() string {
+	if x != nil {
+		return x.ImageUrl
+	}
+	return ""
+}
+
+type DeleteURLRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteURLRequest) Reset() {
+	*x = DeleteURLRequest{}
+	mi := &file_url_service_url_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteURLRequest) ProtoMessage() {}
+
+func (x *DeleteURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteURLRequest.ProtoReflect.Descriptor instead.
+func (*DeleteURLRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DeleteURLRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+type DeleteURLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteURLResponse) Reset() {
+	*x = DeleteURLResponse{}
+	mi := &file_url_service_url_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteURLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteURLResponse) ProtoMessage() {}
+
+func (x *DeleteURLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteURLResponse.ProtoReflect.Descriptor instead.
+func (*DeleteURLResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeleteURLResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *DeleteURLResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type UpdateURLRequest struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode           string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	OriginalUrl         string                 `protobuf:"bytes,2,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	ExpectedOriginalUrl string                 `protobuf:"bytes,3,opt,name=expected_original_url,json=expectedOriginalUrl,proto3" json:"expected_original_url,omitempty"` // Optional, rejects the update if the current destination differs
+	Variants            []*Variant             `protobuf:"bytes,4,rep,name=variants,proto3" json:"variants,omitempty"`                                                    // Replaces the destinations with a split, instead of original_url; setting original_url ends a split
+	StickyVariants      bool                   `protobuf:"varint,5,opt,name=sticky_variants,json=stickyVariants,proto3" json:"sticky_variants,omitempty"`
+	Rules               []*RedirectRule        `protobuf:"bytes,6,rep,name=rules,proto3" json:"rules,omitempty"`                                      // Replaces the rules; none removes them
+	QueryTemplate       string                 `protobuf:"bytes,7,opt,name=query_template,json=queryTemplate,proto3" json:"query_template,omitempty"` // Replaces the query template; empty removes it
+	Tags                []string               `protobuf:"bytes,8,rep,name=tags,proto3" json:"tags,omitempty"`                                        // Replaces the tags when set_tags is set
+	SetTags             bool                   `protobuf:"varint,9,opt,name=set_tags,json=setTags,proto3" json:"set_tags,omitempty"`                  // Replace the tags, none removing them; otherwise they are kept
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *UpdateURLRequest) Reset() {
+	*x = UpdateURLRequest{}
+	mi := &file_url_service_url_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateURLRequest) ProtoMessage() {}
+
+func (x *UpdateURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateURLRequest.ProtoReflect.Descriptor instead.
+func (*UpdateURLRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *UpdateURLRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *UpdateURLRequest) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *UpdateURLRequest) GetExpectedOriginalUrl() string {
+	if x != nil {
+		return x.ExpectedOriginalUrl
+	}
+	return ""
+}
+
+func (x *UpdateURLRequest) GetVariants() []*Variant {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+func (x *UpdateURLRequest) GetStickyVariants() bool {
+	if x != nil {
+		return x.StickyVariants
+	}
+	return false
+}
+
+func (x *UpdateURLRequest) GetRules() []*RedirectRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *UpdateURLRequest) GetQueryTemplate() string {
+	if x != nil {
+		return x.QueryTemplate
+	}
+	return ""
+}
+
+func (x *UpdateURLRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *UpdateURLRequest) GetSetTags() bool {
+	if x != nil {
+		return x.SetTags
+	}
+	return false
+}
+
+type UpdateURLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	OriginalUrl   string                 `protobuf:"bytes,2,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	Variants      []*Variant             `protobuf:"bytes,4,rep,name=variants,proto3" json:"variants,omitempty"` // Set for split links, as stored
+	Rules         []*RedirectRule        `protobuf:"bytes,5,rep,name=rules,proto3" json:"rules,omitempty"`       // As stored
+	QueryTemplate string                 `protobuf:"bytes,6,opt,name=query_template,json=queryTemplate,proto3" json:"query_template,omitempty"`
+	Tags          []string               `protobuf:"bytes,7,rep,name=tags,proto3" json:"tags,omitempty"` // Set with set_tags, normalized and sorted
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateURLResponse) Reset() {
+	*x = UpdateURLResponse{}
+	mi := &file_url_service_url_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateURLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateURLResponse) ProtoMessage() {}
+
+func (x *UpdateURLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateURLResponse.ProtoReflect.Descriptor instead.
+func (*UpdateURLResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *UpdateURLResponse) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *UpdateURLResponse) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *UpdateURLResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *UpdateURLResponse) GetVariants() []*Variant {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+func (x *UpdateURLResponse) GetRules() []*RedirectRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *UpdateURLResponse) GetQueryTemplate() string {
+	if x != nil {
+		return x.QueryTemplate
+	}
+	return ""
+}
+
+func (x *UpdateURLResponse) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type ListURLsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`          // Optional when authenticated, must match the API key's user
+	PageSize      int32                  `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`   // Defaults to 50, at most 100
+	PageToken     string                 `protobuf:"bytes,3,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"` // next_page_token of the previous page, listed with the same tags
+	Tags          []string               `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty"`                            // Only links with every one of these tags
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListURLsRequest) Reset() {
+	*x = ListURLsRequest{}
+	mi := &file_url_service_url_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListURLsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListURLsRequest) ProtoMessage() {}
+
+func (x *ListURLsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListURLsRequest.ProtoReflect.Descriptor instead.
+func (*ListURLsRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ListURLsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ListURLsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListURLsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListURLsRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type URLSummary struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode      string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	OriginalUrl    string                 `protobuf:"bytes,2,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	ClickCount     int64                  `protobuf:"varint,3,opt,name=click_count,json=clickCount,proto3" json:"click_count,omitempty"`
+	CreatedAt      string                 `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	ExpiresAt      string                 `protobuf:"bytes,5,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	Disabled       bool                   `protobuf:"varint,6,opt,name=disabled,proto3" json:"disabled,omitempty"`                                    // Turned off with SetURLStatus
+	Tags           []string               `protobuf:"bytes,7,rep,name=tags,proto3" json:"tags,omitempty"`                                             // Sorted
+	LastAccessedAt string                 `protobuf:"bytes,8,opt,name=last_accessed_at,json=lastAccessedAt,proto3" json:"last_accessed_at,omitempty"` // Latest click by a person, empty if there has been none
+	Title          string                 `protobuf:"bytes,9,opt,name=title,proto3" json:"title,omitempty"`                                           // From the destination page, see FETCH_METADATA
+	Description    string                 `protobuf:"bytes,10,opt,name=description,proto3" json:"description,omitempty"`
+	ImageUrl       string                 `protobuf:"bytes,11,opt,name=image_url,json=imageUrl,proto3" json:"image_url,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *URLSummary) Reset() {
+	*x = URLSummary{}
+	mi := &file_url_service_url_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *URLSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*URLSummary) ProtoMessage() {}
+
+func (x *URLSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use URLSummary.ProtoReflect.Descriptor instead.
+func (*URLSummary) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *URLSummary) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *URLSummary) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *URLSummary) GetClickCount() int64 {
+	if x != nil {
+		return x.ClickCount
+	}
+	return 0
+}
+
+func (x *URLSummary) GetCreatedAt() string {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return ""
+}
+
+func (x *URLSummary) GetExpiresAt() string {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return ""
+}
+
+func (x *URLSummary) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+func (x *URLSummary) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *URLSummary) GetLastAccessedAt() string {
+	if x != nil {
+		return x.LastAccessedAt
+	}
+	return ""
+}
+
+func (x *URLSummary) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *URLSummary) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *URLSummary) GetImageUrl() string {
+	if x != nil {
+		return x.ImageUrl
+	}
+	return ""
+}
+
+type ListURLsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Urls          []*URLSummary          `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"`                                          // Newest first
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"` // Empty on the last page
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListURLsResponse) Reset() {
+	*x = ListURLsResponse{}
+	mi := &file_url_service_url_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListURLsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListURLsResponse) ProtoMessage() {}
+
+func (x *ListURLsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListURLsResponse.ProtoReflect.Descriptor instead.
+func (*ListURLsResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ListURLsResponse) GetUrls() []*URLSummary {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+func (x *ListURLsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type ListTagsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"` // Optional when authenticated, must match the API key's user
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTagsRequest) Reset() {
+	*x = ListTagsRequest{}
+	mi := &file_url_service_url_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTagsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTagsRequest) ProtoMessage() {}
+
+func (x *ListTagsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTagsRequest.ProtoReflect.Descriptor instead.
+func (*ListTagsRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ListTagsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+type TagCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tag           string                 `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"`
+	Urls          int64                  `protobuf:"varint,2,opt,name=urls,proto3" json:"urls,omitempty"` // Links with the tag
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TagCount) Reset() {
+	*x = TagCount{}
+	mi := &file_url_service_url_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TagCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TagCount) ProtoMessage() {}
+
+func (x *TagCount) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TagCount.ProtoReflect.Descriptor instead.
+func (*TagCount) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *TagCount) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *TagCount) GetUrls() int64 {
+	if x != nil {
+		return x.Urls
+	}
+	return 0
+}
+
+type ListTagsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tags          []*TagCount            `protobuf:"bytes,1,rep,name=tags,proto3" json:"tags,omitempty"` // Most used first, then by name
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTagsResponse) Reset() {
+	*x = ListTagsResponse{}
+	mi := &file_url_service_url_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTagsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTagsResponse) ProtoMessage() {}
+
+func (x *ListTagsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTagsResponse.ProtoReflect.Descriptor instead.
+func (*ListTagsResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *ListTagsResponse) GetTags() []*TagCount {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type BatchShortenRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Items         []*ShortenRequest      `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"` // reuse_existing and wait_for_persistence are ignored, batches are always persisted before returning
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchShortenRequest) Reset() {
+	*x = BatchShortenRequest{}
+	mi := &file_url_service_url_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchShortenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchShortenRequest) ProtoMessage() {}
+
+func (x *BatchShortenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchShortenRequest.ProtoReflect.Descriptor instead.
+func (*BatchShortenRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *BatchShortenRequest) GetItems() []*ShortenRequest {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+type BatchShortenResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           *ShortenResponse       `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`    // Set if the item succeeded
+	Code          int32                  `protobuf:"varint,2,opt,name=code,proto3" json:"code,omitempty"` // gRPC status code of the item, 0 on success
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchShortenResult) Reset() {
+	*x = BatchShortenResult{}
+	mi := &file_url_service_url_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchShortenResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchShortenResult) ProtoMessage() {}
+
+func (x *BatchShortenResult) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchShortenResult.ProtoReflect.Descriptor instead.
+func (*BatchShortenResult) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *BatchShortenResult) GetUrl() *ShortenResponse {
+	if x != nil {
+		return x.Url
+	}
+	return nil
+}
+
+func (x *BatchShortenResult) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *BatchShortenResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type BatchShortenResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*BatchShortenResult  `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"` // One per item, in request order
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchShortenResponse) Reset() {
+	*x = BatchShortenResponse{}
+	mi := &file_url_service_url_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchShortenResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchShortenResponse) ProtoMessage() {}
+
+func (x *BatchShortenResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchShortenResponse.ProtoReflect.Descriptor instead.
+func (*BatchShortenResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *BatchShortenResponse) GetResults() []*BatchShortenResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type BatchGetOriginalRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCodes    []string               `protobuf:"bytes,1,rep,name=short_codes,json=shortCodes,proto3" json:"short_codes,omitempty"`
+	CountClicks   bool                   `protobuf:"varint,2,opt,name=count_clicks,json=countClicks,proto3" json:"count_clicks,omitempty"` // Count each resolved code as a click, off by default since batch lookups are rarely visits
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetOriginalRequest) Reset() {
+	*x = BatchGetOriginalRequest{}
+	mi := &file_url_service_url_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetOriginalRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetOriginalRequest) ProtoMessage() {}
+
+func (x *BatchGetOriginalRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetOriginalRequest.ProtoReflect.Descriptor instead.
+func (*BatchGetOriginalRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *BatchGetOriginalRequest) GetShortCodes() []string {
+	if x != nil {
+		return x.ShortCodes
+	}
+	return nil
+}
+
+func (x *BatchGetOriginalRequest) GetCountClicks() bool {
+	if x != nil {
+		return x.CountClicks
+	}
+	return false
+}
+
+type BatchGetOriginalResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*GetOriginalResponse `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"` // One per short code, in request order
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetOriginalResponse) Reset() {
+	*x = BatchGetOriginalResponse{}
+	mi := &file_url_service_url_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetOriginalResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetOriginalResponse) ProtoMessage() {}
+
+func (x *BatchGetOriginalResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetOriginalResponse.ProtoReflect.Descriptor instead.
+func (*BatchGetOriginalResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *BatchGetOriginalResponse) GetResults() []*GetOriginalResponse {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type GetTopURLsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Limit         int32                  `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"` // Defaults to 50, at most 1000
+	Since         string                 `protobuf:"bytes,2,opt,name=since,proto3" json:"since,omitempty"`  // Optional RFC3339, ranks by clicks since then instead of all-time clicks
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTopURLsRequest) Reset() {
+	*x = GetTopURLsRequest{}
+	mi := &file_url_service_url_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTopURLsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTopURLsRequest) ProtoMessage() {}
+
+func (x *GetTopURLsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTopURLsRequest.ProtoReflect.Descriptor instead.
+func (*GetTopURLsRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *GetTopURLsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *GetTopURLsRequest) GetSince() string {
+	if x != nil {
+		return x.Since
+	}
+	return ""
+}
+
+type GetTopURLsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Urls          []*URLSummary          `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"` // Most clicked first, ties broken by short code
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTopURLsResponse) Reset() {
+	*x = GetTopURLsResponse{}
+	mi := &file_url_service_url_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTopURLsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTopURLsResponse) ProtoMessage() {}
+
+func (x *GetTopURLsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTopURLsResponse.ProtoReflect.Descriptor instead.
+func (*GetTopURLsResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *GetTopURLsResponse) GetUrls() []*URLSummary {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+type GetGlobalStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetGlobalStatsRequest) Reset() {
+	*x = GetGlobalStatsRequest{}
+	mi := &file_url_service_url_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetGlobalStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetGlobalStatsRequest) ProtoMessage() {}
+
+func (x *GetGlobalStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetGlobalStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetGlobalStatsRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{26}
+}
+
+type GetGlobalStatsResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	TotalUrls      int64                  `protobuf:"varint,1,opt,name=total_urls,json=totalUrls,proto3" json:"total_urls,omitempty"` // Including deleted URLs that haven't been purged yet
+	TotalClicks    int64                  `protobuf:"varint,2,opt,name=total_clicks,json=totalClicks,proto3" json:"total_clicks,omitempty"`
+	CreatedLastDay int64                  `protobuf:"varint,3,opt,name=created_last_day,json=createdLastDay,proto3" json:"created_last_day,omitempty"`
+	ActiveUrls     int64                  `protobuf:"varint,4,opt,name=active_urls,json=activeUrls,proto3" json:"active_urls,omitempty"` // Neither expired nor deleted
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetGlobalStatsResponse) Reset() {
+	*x = GetGlobalStatsResponse{}
+	mi := &file_url_service_url_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetGlobalStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetGlobalStatsResponse) ProtoMessage() {}
+
+func (x *GetGlobalStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetGlobalStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetGlobalStatsResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *GetGlobalStatsResponse) GetTotalUrls() int64 {
+	if x != nil {
+		return x.TotalUrls
+	}
+	return 0
+}
+
+func (x *GetGlobalStatsResponse) GetTotalClicks() int64 {
+	if x != nil {
+		return x.TotalClicks
+	}
+	return 0
+}
+
+func (x *GetGlobalStatsResponse) GetCreatedLastDay() int64 {
+	if x != nil {
+		return x.CreatedLastDay
+	}
+	return 0
+}
+
+func (x *GetGlobalStatsResponse) GetActiveUrls() int64 {
+	if x != nil {
+		return x.ActiveUrls
+	}
+	return 0
+}
+
+type SetURLStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	Active        bool                   `protobuf:"varint,2,opt,name=active,proto3" json:"active,omitempty"` // false stops the link resolving at once, keeping its history; true restores it
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetURLStatusRequest) Reset() {
+	*x = SetURLStatusRequest{}
+	mi := &file_url_service_url_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetURLStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetURLStatusRequest) ProtoMessage() {}
+
+func (x *SetURLStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetURLStatusRequest.ProtoReflect.Descriptor instead.
+func (*SetURLStatusRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *SetURLStatusRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *SetURLStatusRequest) GetActive() bool {
+	if x != nil {
+		return x.Active
+	}
+	return false
+}
+
+type SetURLStatusResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	Active        bool                   `protobuf:"varint,2,opt,name=active,proto3" json:"active,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetURLStatusResponse) Reset() {
+	*x = SetURLStatusResponse{}
+	mi := &file_url_service_url_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetURLStatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetURLStatusResponse) ProtoMessage() {}
+
+func (x *SetURLStatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetURLStatusResponse.ProtoReflect.Descriptor instead.
+func (*SetURLStatusResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *SetURLStatusResponse) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *SetURLStatusResponse) GetActive() bool {
+	if x != nil {
+		return x.Active
+	}
+	return false
+}
+
+type GetURLHistoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	PageSize      int32                  `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken     string                 `protobuf:"bytes,3,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetURLHistoryRequest) Reset() {
+	*x = GetURLHistoryRequest{}
+	mi := &file_url_service_url_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetURLHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetURLHistoryRequest) ProtoMessage() {}
+
+func (x *GetURLHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetURLHistoryRequest.ProtoReflect.Descriptor instead.
+func (*GetURLHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *GetURLHistoryRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *GetURLHistoryRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *GetURLHistoryRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type URLHistoryEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Action        string                 `protobuf:"bytes,1,opt,name=action,proto3" json:"action,omitempty"` // update, recreate, disable, enable or delete
+	Actor         string                 `protobuf:"bytes,2,opt,name=actor,proto3" json:"actor,omitempty"`
+	ApiKeyId      string                 `protobuf:"bytes,3,opt,name=api_key_id,json=apiKeyId,proto3" json:"api_key_id,omitempty"`
+	OldUrl        string                 `protobuf:"bytes,4,opt,name=old_url,json=oldUrl,proto3" json:"old_url,omitempty"`
+	NewUrl        string                 `protobuf:"bytes,5,opt,name=new_url,json=newUrl,proto3" json:"new_url,omitempty"`
+	ChangedAt     string                 `protobuf:"bytes,6,opt,name=changed_at,json=changedAt,proto3" json:"changed_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *URLHistoryEntry) Reset() {
+	*x = URLHistoryEntry{}
+	mi := &file_url_service_url_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *URLHistoryEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*URLHistoryEntry) ProtoMessage() {}
+
+func (x *URLHistoryEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use URLHistoryEntry.ProtoReflect.Descriptor instead.
+func (*URLHistoryEntry) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *URLHistoryEntry) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *URLHistoryEntry) GetActor() string {
+	if x != nil {
+		return x.Actor
+	}
+	return ""
+}
+
+func (x *URLHistoryEntry) GetApiKeyId() string {
+	if x != nil {
+		return x.ApiKeyId
+	}
+	return ""
+}
+
+func (x *URLHistoryEntry) GetOldUrl() string {
+	if x != nil {
+		return x.OldUrl
+	}
+	return ""
+}
+
+func (x *URLHistoryEntry) GetNewUrl() string {
+	if x != nil {
+		return x.NewUrl
+	}
+	return ""
+}
+
+func (x *URLHistoryEntry) GetChangedAt() string {
+	if x != nil {
+		return x.ChangedAt
+	}
+	return ""
+}
+
+type GetURLHistoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entries       []*URLHistoryEntry     `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"` // Newest first
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetURLHistoryResponse) Reset() {
+	*x = GetURLHistoryResponse{}
+	mi := &file_url_service_url_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetURLHistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetURLHistoryResponse) ProtoMessage() {}
+
+func (x *GetURLHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetURLHistoryResponse.ProtoReflect.Descriptor instead.
+func (*GetURLHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *GetURLHistoryResponse) GetEntries() []*URLHistoryEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+func (x *GetURLHistoryResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type ReportURLRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode       string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	Reason          string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	ReporterContact string                 `protobuf:"bytes,3,opt,name=reporter_contact,json=reporterContact,proto3" json:"reporter_contact,omitempty"` // Optional email or similar to follow up with the reporter
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ReportURLRequest) Reset() {
+	*x = ReportURLRequest{}
+	mi := &file_url_service_url_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportURLRequest) ProtoMessage() {}
+
+func (x *ReportURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportURLRequest.ProtoReflect.Descriptor instead.
+func (*ReportURLRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *ReportURLRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *ReportURLRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *ReportURLRequest) GetReporterContact() string {
+	if x != nil {
+		return x.ReporterContact
+	}
+	return ""
+}
+
+type ReportURLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ReportId      int64                  `protobuf:"varint,1,opt,name=report_id,json=reportId,proto3" json:"report_id,omitempty"` // 0 for duplicates
+	Duplicate     bool                   `protobuf:"varint,2,opt,name=duplicate,proto3" json:"duplicate,omitempty"`               // The reporter already reported the code today
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReportURLResponse) Reset() {
+	*x = ReportURLResponse{}
+	mi := &file_url_service_url_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportURLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportURLResponse) ProtoMessage() {}
+
+func (x *ReportURLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportURLResponse.ProtoReflect.Descriptor instead.
+func (*ReportURLResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *ReportURLResponse) GetReportId() int64 {
+	if x != nil {
+		return x.ReportId
+	}
+	return 0
+}
+
+func (x *ReportURLResponse) GetDuplicate() bool {
+	if x != nil {
+		return x.Duplicate
+	}
+	return false
+}
+
+type AbuseReport struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	ShortCode       string                 `protobuf:"bytes,2,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	Reason          string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	ReporterContact string                 `protobuf:"bytes,4,opt,name=reporter_contact,json=reporterContact,proto3" json:"reporter_contact,omitempty"`
+	Status          string                 `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"` // open or actioned
+	CreatedAt       string                 `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *AbuseReport) Reset() {
+	*x = AbuseReport{}
+	mi := &file_url_service_url_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AbuseReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AbuseReport) ProtoMessage() {}
+
+func (x *AbuseReport) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AbuseReport.ProtoReflect.Descriptor instead.
+func (*AbuseReport) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *AbuseReport) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *AbuseReport) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *AbuseReport) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *AbuseReport) GetReporterContact() string {
+	if x != nil {
+		return x.ReporterContact
+	}
+	return ""
+}
+
+func (x *AbuseReport) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *AbuseReport) GetCreatedAt() string {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return ""
+}
+
+type ListReportsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`                        // Optional filter, open or actioned
+	ShortCode     string                 `protobuf:"bytes,2,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"` // Optional filter
+	PageSize      int32                  `protobuf:"varint,3,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken     string                 `protobuf:"bytes,4,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListReportsRequest) Reset() {
+	*x = ListReportsRequest{}
+	mi := &file_url_service_url_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListReportsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListReportsRequest) ProtoMessage() {}
+
+func (x *ListReportsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListReportsRequest.ProtoReflect.Descriptor instead.
+func (*ListReportsRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *ListReportsRequest) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ListReportsRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *ListReportsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListReportsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListReportsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reports       []*AbuseReport         `protobuf:"bytes,1,rep,name=reports,proto3" json:"reports,omitempty"` // Newest first
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListReportsResponse) Reset() {
+	*x = ListReportsResponse{}
+	mi := &file_url_service_url_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListReportsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListReportsResponse) ProtoMessage() {}
+
+func (x *ListReportsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListReportsResponse.ProtoReflect.Descriptor instead.
+func (*ListReportsResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *ListReportsResponse) GetReports() []*AbuseReport {
+	if x != nil {
+		return x.Reports
+	}
+	return nil
+}
+
+func (x *ListReportsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type PurgeURLRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PurgeURLRequest) Reset() {
+	*x = PurgeURLRequest{}
+	mi := &file_url_service_url_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PurgeURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PurgeURLRequest) ProtoMessage() {}
+
+func (x *PurgeURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PurgeURLRequest.ProtoReflect.Descriptor instead.
+func (*PurgeURLRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *PurgeURLRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+type PurgeLayerResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ok            bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`      // The code is verified gone from the layer
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"` // Why not, when ok is false
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PurgeLayerResult) Reset() {
+	*x = PurgeLayerResult{}
+	mi := &file_url_service_url_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PurgeLayerResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PurgeLayerResult) ProtoMessage() {}
+
+func (x *PurgeLayerResult) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PurgeLayerResult.ProtoReflect.Descriptor instead.
+func (*PurgeLayerResult) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *PurgeLayerResult) GetOk() bool {
+	if x != nil {
+		return x.Ok
+	}
+	return false
+}
+
+func (x *PurgeLayerResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type PurgeURLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	Storage       *PurgeLayerResult      `protobuf:"bytes,2,opt,name=storage,proto3" json:"storage,omitempty"`
+	Cache         *PurgeLayerResult      `protobuf:"bytes,3,opt,name=cache,proto3" json:"cache,omitempty"`
+	Memory        *PurgeLayerResult      `protobuf:"bytes,4,opt,name=memory,proto3" json:"memory,omitempty"`      // Only this replica's; others drop the code as it is evicted
+	Complete      bool                   `protobuf:"varint,5,opt,name=complete,proto3" json:"complete,omitempty"` // Every layer is ok; otherwise PurgeURL can be retried
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PurgeURLResponse) Reset() {
+	*x = PurgeURLResponse{}
+	mi := &file_url_service_url_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PurgeURLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PurgeURLResponse) ProtoMessage() {}
+
+func (x *PurgeURLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PurgeURLResponse.ProtoReflect.Descriptor instead.
+func (*PurgeURLResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *PurgeURLResponse) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *PurgeURLResponse) GetStorage() *PurgeLayerResult {
+	if x != nil {
+		return x.Storage
+	}
+	return nil
+}
+
+func (x *PurgeURLResponse) GetCache() *PurgeLayerResult {
+	if x != nil {
+		return x.Cache
+	}
+	return nil
+}
+
+func (x *PurgeURLResponse) GetMemory() *PurgeLayerResult {
+	if x != nil {
+		return x.Memory
+	}
+	return nil
+}
+
+func (x *PurgeURLResponse) GetComplete() bool {
+	if x != nil {
+		return x.Complete
+	}
+	return false
+}
+
+type StreamClicksRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"` // Optional, only clicks on this code
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamClicksRequest) Reset() {
+	*x = StreamClicksRequest{}
+	mi := &file_url_service_url_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamClicksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamClicksRequest) ProtoMessage() {}
+
+func (x *StreamClicksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamClicksRequest.ProtoReflect.Descriptor instead.
+func (*StreamClicksRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *StreamClicksRequest) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+type LiveClick struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	ClickedAt     string                 `protobuf:"bytes,2,opt,name=clicked_at,json=clickedAt,proto3" json:"clicked_at,omitempty"` // RFC3339 with milliseconds
+	Country       string                 `protobuf:"bytes,3,opt,name=country,proto3" json:"country,omitempty"`
+	Referrer      string                 `protobuf:"bytes,4,opt,name=referrer,proto3" json:"referrer,omitempty"`
+	Bot           bool                   `protobuf:"varint,5,opt,name=bot,proto3" json:"bot,omitempty"`                          // A bot or prefetch click, not in click_count
+	Dropped       int64                  `protobuf:"varint,6,opt,name=dropped,proto3" json:"dropped,omitempty"`                  // Clicks skipped before this one because the subscriber fell behind
+	Variant       string                 `protobuf:"bytes,7,opt,name=variant,proto3" json:"variant,omitempty"`                   // The variant of a split link the click was sent to
+	Rule          string                 `protobuf:"bytes,8,opt,name=rule,proto3" json:"rule,omitempty"`                         // The rule matched, for links with rules
+	TenantId      string                 `protobuf:"bytes,9,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"` // Empty for the default tenant
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LiveClick) Reset() {
+	*x = LiveClick{}
+	mi := &file_url_service_url_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LiveClick) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LiveClick) ProtoMessage() {}
+
+func (x *LiveClick) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LiveClick.ProtoReflect.Descriptor instead.
+func (*LiveClick) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *LiveClick) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *LiveClick) GetClickedAt() string {
+	if x != nil {
+		return x.ClickedAt
+	}
+	return ""
+}
+
+func (x *LiveClick) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *LiveClick) GetReferrer() string {
+	if x != nil {
+		return x.Referrer
+	}
+	return ""
+}
+
+func (x *LiveClick) GetBot() bool {
+	if x != nil {
+		return x.Bot
+	}
+	return false
+}
+
+func (x *LiveClick) GetDropped() int64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+func (x *LiveClick) GetVariant() string {
+	if x != nil {
+		return x.Variant
+	}
+	return ""
+}
+
+func (x *LiveClick) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *LiveClick) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type DeleteUserDataRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteUserDataRequest) Reset() {
+	*x = DeleteUserDataRequest{}
+	mi := &file_url_service_url_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteUserDataRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteUserDataRequest) ProtoMessage() {}
+
+func (x *DeleteUserDataRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteUserDataRequest.ProtoReflect.Descriptor instead.
+func (*DeleteUserDataRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *DeleteUserDataRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+type DeleteUserDataResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	UserId         string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	DeletedUrls    int64                  `protobuf:"varint,2,opt,name=deleted_urls,json=deletedUrls,proto3" json:"deleted_urls,omitempty"`          // Links deleted by this call, 0 when it is repeated
+	ScrubbedClicks int64                  `protobuf:"varint,3,opt,name=scrubbed_clicks,json=scrubbedClicks,proto3" json:"scrubbed_clicks,omitempty"` // Click events whose referrer, user agent, country and what was derived from them were cleared
+	CachePurged    int64                  `protobuf:"varint,4,opt,name=cache_purged,json=cachePurged,proto3" json:"cache_purged,omitempty"`          // Links whose cache entries are gone
+	CacheFailed    int64                  `protobuf:"varint,5,opt,name=cache_failed,json=cacheFailed,proto3" json:"cache_failed,omitempty"`          // Links whose cache entries couldn't be removed; DeleteUserData can be retried
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *DeleteUserDataResponse) Reset() {
+	*x = DeleteUserDataResponse{}
+	mi := &file_url_service_url_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteUserDataResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteUserDataResponse) ProtoMessage() {}
+
+func (x *DeleteUserDataResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteUserDataResponse.ProtoReflect.Descriptor instead.
+func (*DeleteUserDataResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *DeleteUserDataResponse) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *DeleteUserDataResponse) GetDeletedUrls() int64 {
+	if x != nil {
+		return x.DeletedUrls
+	}
+	return 0
+}
+
+func (x *DeleteUserDataResponse) GetScrubbedClicks() int64 {
+	if x != nil {
+		return x.ScrubbedClicks
+	}
+	return 0
+}
+
+func (x *DeleteUserDataResponse) GetCachePurged() int64 {
+	if x != nil {
+		return x.CachePurged
+	}
+	return 0
+}
+
+func (x *DeleteUserDataResponse) GetCacheFailed() int64 {
+	if x != nil {
+		return x.CacheFailed
+	}
+	return 0
+}
+
+type ExportUserDataRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportUserDataRequest) Reset() {
+	*x = ExportUserDataRequest{}
+	mi := &file_url_service_url_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportUserDataRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportUserDataRequest) ProtoMessage() {}
+
+func (x *ExportUserDataRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportUserDataRequest.ProtoReflect.Descriptor instead.
+func (*ExportUserDataRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *ExportUserDataRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+type ExportUserDataChunk struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// One JSON object: {"type": "urls", "urls": [...]} for each page of the
+	// user's links, deleted ones included, then a last {"type": "summary", ...}
+	// with their totals
+	Data          []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportUserDataChunk) Reset() {
+	*x = ExportUserDataChunk{}
+	mi := &file_url_service_url_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportUserDataChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportUserDataChunk) ProtoMessage() {}
+
+func (x *ExportUserDataChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[46]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportUserDataChunk.ProtoReflect.Descriptor instead.
+func (*ExportUserDataChunk) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *ExportUserDataChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type WriteHeapProfileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WriteHeapProfileRequest) Reset() {
+	*x = WriteHeapProfileRequest{}
+	mi := &file_url_service_url_proto_msgTypes[47]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WriteHeapProfileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WriteHeapProfileRequest) ProtoMessage() {}
+
+func (x *WriteHeapProfileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[47]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WriteHeapProfileRequest.ProtoReflect.Descriptor instead.
+func (*WriteHeapProfileRequest) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{47}
+}
+
+type WriteHeapProfileResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"` // File the profile was written to, on the replica that served the call
+	Bytes         int64                  `protobuf:"varint,2,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	WrittenAt     string                 `protobuf:"bytes,3,opt,name=written_at,json=writtenAt,proto3" json:"written_at,omitempty"` // RFC3339
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WriteHeapProfileResponse) Reset() {
+	*x = WriteHeapProfileResponse{}
+	mi := &file_url_service_url_proto_msgTypes[48]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WriteHeapProfileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WriteHeapProfileResponse) ProtoMessage() {}
+
+func (x *WriteHeapProfileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_url_service_url_proto_msgTypes[48]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WriteHeapProfileResponse.ProtoReflect.Descriptor instead.
+func (*WriteHeapProfileResponse) Descriptor() ([]byte, []int) {
+	return file_url_service_url_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *WriteHeapProfileResponse) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *WriteHeapProfileResponse) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *WriteHeapProfileResponse) GetWrittenAt() string {
+	if x != nil {
+		return x.WrittenAt
+	}
+	return ""
+}
+
+var File_url_service_url_proto protoreflect.FileDescriptor
+
+const file_url_service_url_proto_rawDesc = "" +
+	"\n" +
+	"\x15url-service/url.proto\x12\x03url\"\xef\x04\n" +
+	"\x0eShortenRequest\x12!\n" +
+	"\foriginal_url\x18\x01 \x01(\tR\voriginalUrl\x12!\n" +
+	"\fcustom_alias\x18\x02 \x01(\tR\vcustomAlias\x12%\n" +
+	"\x0ereuse_existing\x18\x03 \x01(\bR\rreuseExisting\x12\x1f\n" +
+	"\vttl_seconds\x18\x04 \x01(\x03R\n" +
+	"ttlSeconds\x120\n" +
+	"\x14wait_for_persistence\x18\x05 \x01(\bR\x12waitForPersistence\x12\x1d\n" +
+	"\n" +
+	"max_clicks\x18\x06 \x01(\x03R\tmaxClicks\x12!\n" +
+	"\ffallback_url\x18\a \x01(\tR\vfallbackUrl\x12\x1d\n" +
+	"\n" +
+	"not_before\x18\b \x01(\tR\tnotBefore\x12\x1b\n" +
+	"\tnot_after\x18\t \x01(\tR\bnotAfter\x12&\n" +
+	"\x0fcoming_soon_url\x18\n" +
+	" \x01(\tR\rcomingSoonUrl\x12(\n" +
+	"\bvariants\x18\v \x03(\v2\f.url.VariantR\bvariants\x12'\n" +
+	"\x0fsticky_variants\x18\f \x01(\bR\x0estickyVariants\x12'\n" +
+	"\x05rules\x18\r \x03(\v2\x11.url.RedirectRuleR\x05rules\x12%\n" +
+	"\x0equery_template\x18\x0e \x01(\tR\rqueryTemplate\x12\x12\n" +
+	"\x04tags\x18\x0f \x03(\tR\x04tags\x12\x1b\n" +
+	"\ttenant_id\x18\x10 \x01(\tR\btenantId\x12#\n" +
+	"\rcode_strategy\x18\x11 \x01(\tR\fcodeStrategy\"V\n" +
+	"\fRedirectRule\x12\x16\n" +
+	"\x06device\x18\x01 \x01(\tR\x06device\x12\x1c\n" +
+	"\tcountries\x18\x02 \x03(\tR\tcountries\x12\x10\n" +
+	"\x03url\x18\x03 \x01(\tR\x03url\"G\n" +
+	"\aVariant\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x16\n" +
+	"\x06weight\x18\x03 \x01(\x05R\x06weight\"\x96\x03\n" +
+	"\x0fShortenResponse\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12!\n" +
+	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\x12%\n" +
+	"\x0enormalized_url\x18\x04 \x01(\tR\rnormalizedUrl\x12\x1b\n" +
+	"\tshort_url\x18\x05 \x01(\tR\bshortUrl\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x06 \x01(\tR\tcreatedAt\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\a \x01(\tR\texpiresAt\x12(\n" +
+	"\bvariants\x18\b \x03(\v2\f.url.VariantR\bvariants\x12'\n" +
+	"\x05rules\x18\t \x03(\v2\x11.url.RedirectRuleR\x05rules\x12%\n" +
+	"\x0equery_template\x18\n" +
+	" \x01(\tR\rqueryTemplate\x12\x12\n" +
+	"\x04tags\x18\v \x03(\tR\x04tags\x12\x1b\n" +
+	"\ttenant_id\x18\f \x01(\tR\btenantId\"\xff\x01\n" +
+	"\x12GetOriginalRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x1d\n" +
+	"\n" +
+	"skip_stats\x18\x02 \x01(\bR\tskipStats\x12\x1a\n" +
+	"\breferrer\x18\x03 \x01(\tR\breferrer\x12\x1d\n" +
+	"\n" +
+	"user_agent\x18\x04 \x01(\tR\tuserAgent\x12\x18\n" +
+	"\acountry\x18\x05 \x01(\tR\acountry\x12\x1a\n" +
+	"\bprefetch\x18\x06 \x01(\bR\bprefetch\x12\x1d\n" +
+	"\n" +
+	"visitor_id\x18\a \x01(\tR\tvisitorId\x12\x1b\n" +
+	"\ttenant_id\x18\b \x01(\tR\btenantId\"\x86\x02\n" +
+	"\x13GetOriginalResponse\x12!\n" +
+	"\foriginal_url\x18\x01 \x01(\tR\voriginalUrl\x12\x14\n" +
+	"\x05found\x18\x02 \x01(\bR\x05found\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\x12\x18\n" +
+	"\aexpired\x18\x04 \x01(\bR\aexpired\x12\x1c\n" +
+	"\texhausted\x18\x05 \x01(\bR\texhausted\x12\x1a\n" +
+	"\bdisabled\x18\x06 \x01(\bR\bdisabled\x12\x18\n" +
+	"\avariant\x18\a \x01(\tR\avariant\x12\x12\n" +
+	"\x04rule\x18\b \x01(\tR\x04rule\x12\x1e\n" +
+	"\n" +
+	"resolution\x18\t \x01(\tR\n" +
+	"resolution\"\x87\x01\n" +
+	"\fStatsRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12-\n" +
+	"\x12include_breakdowns\x18\x02 \x01(\bR\x11includeBreakdowns\x12)\n" +
+	"\x10include_metadata\x18\x03 \x01(\bR\x0fincludeMetadata\">\n" +
+	"\x0eBreakdownEntry\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\tR\x05value\x12\x16\n" +
+	"\x06clicks\x18\x02 \x01(\x03R\x06clicks\"\xa9\x05\n" +
+	"\rStatsResponse\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x1f\n" +
+	"\vclick_count\x18\x02 \x01(\x03R\n" +
+	"clickCount\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x03 \x01(\tR\tcreatedAt\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x05 \x01(\tR\texpiresAt\x12\x18\n" +
+	"\aexpired\x18\x06 \x01(\bR\aexpired\x128\n" +
+	"\rtop_referrers\x18\a \x03(\v2\x13.url.BreakdownEntryR\ftopReferrers\x121\n" +
+	"\tcountries\x18\b \x03(\v2\x13.url.BreakdownEntryR\tcountries\x12/\n" +
+	"\bbrowsers\x18\t \x03(\v2\x13.url.BreakdownEntryR\bbrowsers\x12-\n" +
+	"\adevices\x18\n" +
+	" \x03(\v2\x13.url.BreakdownEntryR\adevices\x12#\n" +
+	"\runique_clicks\x18\v \x01(\x03R\funiqueClicks\x12\x1d\n" +
+	"\n" +
+	"bot_clicks\x18\f \x01(\x03R\tbotClicks\x12/\n" +
+	"\bvariants\x18\r \x03(\v2\x13.url.BreakdownEntryR\bvariants\x12)\n" +
+	"\x05rules\x18\x0e \x03(\v2\x13.url.BreakdownEntryR\x05rules\x12(\n" +
+	"\x10last_accessed_at\x18\x0f \x01(\tR\x0elastAccessedAt\x12\x14\n" +
+	"\x05title\x18\x10 \x01(\tR\x05title\x12 \n" +
+	"\vdescription\x18\x11 \x01(\tR\vdescription\x12\x1b\n" +
+	"\timage_url\x18\x12 \x01(\tR\bimageUrl\"1\n" +
+	"\x10DeleteURLRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\"C\n" +
+	"\x11DeleteURLResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"\xda\x02\n" +
+	"\x10UpdateURLRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12!\n" +
+	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\x122\n" +
+	"\x15expected_original_url\x18\x03 \x01(\tR\x13expectedOriginalUrl\x12(\n" +
+	"\bvariants\x18\x04 \x03(\v2\f.url.VariantR\bvariants\x12'\n" +
+	"\x0fsticky_variants\x18\x05 \x01(\bR\x0estickyVariants\x12'\n" +
+	"\x05rules\x18\x06 \x03(\v2\x11.url.RedirectRuleR\x05rules\x12%\n" +
+	"\x0equery_template\x18\a \x01(\tR\rqueryTemplate\x12\x12\n" +
+	"\x04tags\x18\b \x03(\tR\x04tags\x12\x19\n" +
+	"\bset_tags\x18\t \x01(\bR\asetTags\"\xf9\x01\n" +
+	"\x11UpdateURLResponse\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12!\n" +
+	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\x12(\n" +
+	"\bvariants\x18\x04 \x03(\v2\f.url.VariantR\bvariants\x12'\n" +
+	"\x05rules\x18\x05 \x03(\v2\x11.url.RedirectRuleR\x05rules\x12%\n" +
+	"\x0equery_template\x18\x06 \x01(\tR\rqueryTemplate\x12\x12\n" +
+	"\x04tags\x18\a \x03(\tR\x04tags\"z\n" +
+	"\x0fListURLsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x03 \x01(\tR\tpageToken\x12\x12\n" +
+	"\x04tags\x18\x04 \x03(\tR\x04tags\"\xdc\x02\n" +
+	"\n" +
+	"URLSummary\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12!\n" +
+	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\x12\x1f\n" +
+	"\vclick_count\x18\x03 \x01(\x03R\n" +
+	"clickCount\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\tR\tcreatedAt\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x05 \x01(\tR\texpiresAt\x12\x1a\n" +
+	"\bdisabled\x18\x06 \x01(\bR\bdisabled\x12\x12\n" +
+	"\x04tags\x18\a \x03(\tR\x04tags\x12(\n" +
+	"\x10last_accessed_at\x18\b \x01(\tR\x0elastAccessedAt\x12\x14\n" +
+	"\x05title\x18\t \x01(\tR\x05title\x12 \n" +
+	"\vdescription\x18\n" +
+	" \x01(\tR\vdescription\x12\x1b\n" +
+	"\timage_url\x18\v \x01(\tR\bimageUrl\"_\n" +
+	"\x10ListURLsResponse\x12#\n" +
+	"\x04urls\x18\x01 \x03(\v2\x0f.url.URLSummaryR\x04urls\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"*\n" +
+	"\x0fListTagsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\"0\n" +
+	"\bTagCount\x12\x10\n" +
+	"\x03tag\x18\x01 \x01(\tR\x03tag\x12\x12\n" +
+	"\x04urls\x18\x02 \x01(\x03R\x04urls\"5\n" +
+	"\x10ListTagsResponse\x12!\n" +
+	"\x04tags\x18\x01 \x03(\v2\r.url.TagCountR\x04tags\"@\n" +
+	"\x13BatchShortenRequest\x12)\n" +
+	"\x05items\x18\x01 \x03(\v2\x13.url.ShortenRequestR\x05items\"f\n" +
+	"\x12BatchShortenResult\x12&\n" +
+	"\x03url\x18\x01 \x01(\v2\x14.url.ShortenResponseR\x03url\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\x05R\x04code\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"I\n" +
+	"\x14BatchShortenResponse\x121\n" +
+	"\aresults\x18\x01 \x03(\v2\x17.url.BatchShortenResultR\aresults\"]\n" +
+	"\x17BatchGetOriginalRequest\x12\x1f\n" +
+	"\vshort_codes\x18\x01 \x03(\tR\n" +
+	"shortCodes\x12!\n" +
+	"\fcount_clicks\x18\x02 \x01(\bR\vcountClicks\"N\n" +
+	"\x18BatchGetOriginalResponse\x122\n" +
+	"\aresults\x18\x01 \x03(\v2\x18.url.GetOriginalResponseR\aresults\"?\n" +
+	"\x11GetTopURLsRequest\x12\x14\n" +
+	"\x05limit\x18\x01 \x01(\x05R\x05limit\x12\x14\n" +
+	"\x05since\x18\x02 \x01(\tR\x05since\"9\n" +
+	"\x12GetTopURLsResponse\x12#\n" +
+	"\x04urls\x18\x01 \x03(\v2\x0f.url.URLSummaryR\x04urls\"\x17\n" +
+	"\x15GetGlobalStatsRequest\"\xa5\x01\n" +
+	"\x16GetGlobalStatsResponse\x12\x1d\n" +
+	"\n" +
+	"total_urls\x18\x01 \x01(\x03R\ttotalUrls\x12!\n" +
+	"\ftotal_clicks\x18\x02 \x01(\x03R\vtotalClicks\x12(\n" +
+	"\x10created_last_day\x18\x03 \x01(\x03R\x0ecreatedLastDay\x12\x1f\n" +
+	"\vactive_urls\x18\x04 \x01(\x03R\n" +
+	"activeUrls\"L\n" +
+	"\x13SetURLStatusRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x16\n" +
+	"\x06active\x18\x02 \x01(\bR\x06active\"M\n" +
+	"\x14SetURLStatusResponse\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x16\n" +
+	"\x06active\x18\x02 \x01(\bR\x06active\"q\n" +
+	"\x14GetURLHistoryRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x1b\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x03 \x01(\tR\tpageToken\"\xae\x01\n" +
+	"\x0fURLHistoryEntry\x12\x16\n" +
+	"\x06action\x18\x01 \x01(\tR\x06action\x12\x14\n" +
+	"\x05actor\x18\x02 \x01(\tR\x05actor\x12\x1c\n" +
+	"\n" +
+	"api_key_id\x18\x03 \x01(\tR\bapiKeyId\x12\x17\n" +
+	"\aold_url\x18\x04 \x01(\tR\x06oldUrl\x12\x17\n" +
+	"\anew_url\x18\x05 \x01(\tR\x06newUrl\x12\x1d\n" +
+	"\n" +
+	"changed_at\x18\x06 \x01(\tR\tchangedAt\"o\n" +
+	"\x15GetURLHistoryResponse\x12.\n" +
+	"\aentries\x18\x01 \x03(\v2\x14.url.URLHistoryEntryR\aentries\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"t\n" +
+	"\x10ReportURLRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x12)\n" +
+	"\x10reporter_contact\x18\x03 \x01(\tR\x0freporterContact\"N\n" +
+	"\x11ReportURLResponse\x12\x1b\n" +
+	"\treport_id\x18\x01 \x01(\x03R\breportId\x12\x1c\n" +
+	"\tduplicate\x18\x02 \x01(\bR\tduplicate\"\xb6\x01\n" +
+	"\vAbuseReport\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x02 \x01(\tR\tshortCode\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\x12)\n" +
+	"\x10reporter_contact\x18\x04 \x01(\tR\x0freporterContact\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x06 \x01(\tR\tcreatedAt\"\x87\x01\n" +
+	"\x12ListReportsRequest\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x02 \x01(\tR\tshortCode\x12\x1b\n" +
+	"\tpage_size\x18\x03 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x04 \x01(\tR\tpageToken\"i\n" +
+	"\x13ListReportsResponse\x12*\n" +
+	"\areports\x18\x01 \x03(\v2\x10.url.AbuseReportR\areports\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"0\n" +
+	"\x0fPurgeURLRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\"8\n" +
+	"\x10PurgeLayerResult\x12\x0e\n" +
+	"\x02ok\x18\x01 \x01(\bR\x02ok\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"\xda\x01\n" +
+	"\x10PurgeURLResponse\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12/\n" +
+	"\astorage\x18\x02 \x01(\v2\x15.url.PurgeLayerResultR\astorage\x12+\n" +
+	"\x05cache\x18\x03 \x01(\v2\x15.url.PurgeLayerResultR\x05cache\x12-\n" +
+	"\x06memory\x18\x04 \x01(\v2\x15.url.PurgeLayerResultR\x06memory\x12\x1a\n" +
+	"\bcomplete\x18\x05 \x01(\bR\bcomplete\"4\n" +
+	"\x13StreamClicksRequest\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\"\xf6\x01\n" +
+	"\tLiveClick\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x1d\n" +
+	"\n" +
+	"clicked_at\x18\x02 \x01(\tR\tclickedAt\x12\x18\n" +
+	"\acountry\x18\x03 \x01(\tR\acountry\x12\x1a\n" +
+	"\breferrer\x18\x04 \x01(\tR\breferrer\x12\x10\n" +
+	"\x03bot\x18\x05 \x01(\bR\x03bot\x12\x18\n" +
+	"\adropped\x18\x06 \x01(\x03R\adropped\x12\x18\n" +
+	"\avariant\x18\a \x01(\tR\avariant\x12\x12\n" +
+	"\x04rule\x18\b \x01(\tR\x04rule\x12\x1b\n" +
+	"\ttenant_id\x18\t \x01(\tR\btenantId\"0\n" +
+	"\x15DeleteUserDataRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\"\xc3\x01\n" +
+	"\x16DeleteUserDataResponse\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12!\n" +
+	"\fdeleted_urls\x18\x02 \x01(\x03R\vdeletedUrls\x12'\n" +
+	"\x0fscrubbed_clicks\x18\x03 \x01(\x03R\x0escrubbedClicks\x12!\n" +
+	"\fcache_purged\x18\x04 \x01(\x03R\vcachePurged\x12!\n" +
+	"\fcache_failed\x18\x05 \x01(\x03R\vcacheFailed\"0\n" +
+	"\x15ExportUserDataRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\")\n" +
+	"\x13ExportUserDataChunk\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"\x19\n" +
+	"\x17WriteHeapProfileRequest\"c\n" +
+	"\x18WriteHeapProfileResponse\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x14\n" +
+	"\x05bytes\x18\x02 \x01(\x03R\x05bytes\x12\x1d\n" +
+	"\n" +
+	"written_at\x18\x03 \x01(\tR\twrittenAt2\xb0\n" +
+	"\n" +
+	"\n" +
+	"URLService\x127\n" +
+	"\n" +
+	"ShortenURL\x12\x13.url.ShortenRequest\x1a\x14.url.ShortenResponse\x12C\n" +
+	"\x0eGetOriginalURL\x12\x17.url.GetOriginalRequest\x1a\x18.url.GetOriginalResponse\x124\n" +
+	"\vGetURLStats\x12\x11.url.StatsRequest\x1a\x12.url.StatsResponse\x12:\n" +
+	"\tDeleteURL\x12\x15.url.DeleteURLRequest\x1a\x16.url.DeleteURLResponse\x12:\n" +
+	"\tUpdateURL\x12\x15.url.UpdateURLRequest\x1a\x16.url.UpdateURLResponse\x127\n" +
+	"\bListURLs\x12\x14.url.ListURLsRequest\x1a\x15.url.ListURLsResponse\x12C\n" +
+	"\fBatchShorten\x12\x18.url.BatchShortenRequest\x1a\x19.url.BatchShortenResponse\x12O\n" +
+	"\x10BatchGetOriginal\x12\x1c.url.BatchGetOriginalRequest\x1a\x1d.url.BatchGetOriginalResponse\x12=\n" +
+	"\n" +
+	"GetTopURLs\x12\x16.url.GetTopURLsRequest\x1a\x17.url.GetTopURLsResponse\x12I\n" +
+	"\x0eGetGlobalStats\x12\x1a.url.GetGlobalStatsRequest\x1a\x1b.url.GetGlobalStatsResponse\x12C\n" +
+	"\fSetURLStatus\x12\x18.url.SetURLStatusRequest\x1a\x19.url.SetURLStatusResponse\x12F\n" +
+	"\rGetURLHistory\x12\x19.url.GetURLHistoryRequest\x1a\x1a.url.GetURLHistoryResponse\x12:\n" +
+	"\tReportURL\x12\x15.url.ReportURLRequest\x1a\x16.url.ReportURLResponse\x12@\n" +
+	"\vListReports\x12\x17.url.ListReportsRequest\x1a\x18.url.ListReportsResponse\x127\n" +
+	"\bPurgeURL\x12\x14.url.PurgeURLRequest\x1a\x15.url.PurgeURLResponse\x12:\n" +
+	"\fStreamClicks\x12\x18.url.StreamClicksRequest\x1a\x0e.url.LiveClick0\x01\x127\n" +
+	"\bListTags\x12\x14.url.ListTagsRequest\x1a\x15.url.ListTagsResponse\x12I\n" +
+	"\x0eDeleteUserData\x12\x1a.url.DeleteUserDataRequest\x1a\x1b.url.DeleteUserDataResponse\x12H\n" +
+	"\x0eExportUserData\x12\x1a.url.ExportUserDataRequest\x1a\x18.url.ExportUserDataChunk0\x01\x12O\n" +
+	"\x10WriteHeapProfile\x12\x1c.url.WriteHeapProfileRequest\x1a\x1d.url.WriteHeapProfileResponseB\x0fZ\r./url-serviceb\x06proto3"
+
+var (
+	file_url_service_url_proto_rawDescOnce sync.Once
+	file_url_service_url_proto_rawDescData []byte
+)
+
+func file_url_service_url_proto_rawDescGZIP() []byte {
+	file_url_service_url_proto_rawDescOnce.Do(func() {
+		file_url_service_url_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_url_service_url_proto_rawDesc), len(file_url_service_url_proto_rawDesc)))
+	})
+	return file_url_service_url_proto_rawDescData
+}
+
+var file_url_service_url_proto_msgTypes = make([]protoimpl.MessageInfo, 49)
+var file_url_service_url_proto_goTypes = []any{
+	(*ShortenRequest)(nil),           // 0: url.ShortenRequest
+	(*RedirectRule)(nil),             // 1: url.RedirectRule
+	(*Variant)(nil),                  // 2: url.Variant
+	(*ShortenResponse)(nil),          // 3: url.ShortenResponse
+	(*GetOriginalRequest)(nil),       // 4: url.GetOriginalRequest
+	(*GetOriginalResponse)(nil),      // 5: url.GetOriginalResponse
+	(*StatsRequest)(nil),             // 6: url.StatsRequest
+	(*BreakdownEntry)(nil),           // 7: url.BreakdownEntry
+	(*StatsResponse)(nil),            // 8: url.StatsResponse
+	(*DeleteURLRequest)(nil),         // 9: url.DeleteURLRequest
+	(*DeleteURLResponse)(nil),        // 10: url.DeleteURLResponse
+	(*UpdateURLRequest)(nil),         // 11: url.UpdateURLRequest
+	(*UpdateURLResponse)(nil),        // 12: url.UpdateURLResponse
+	(*ListURLsRequest)(nil),          // 13: url.ListURLsRequest
+	(*URLSummary)(nil),               // 14: url.URLSummary
+	(*ListURLsResponse)(nil),         // 15: url.ListURLsResponse
+	(*ListTagsRequest)(nil),          // 16: url.ListTagsRequest
+	(*TagCount)(nil),                 // 17: url.TagCount
+	(*ListTagsResponse)(nil),         // 18: url.ListTagsResponse
+	(*BatchShortenRequest)(nil),      // 19: url.BatchShortenRequest
+	(*BatchShortenResult)(nil),       // 20: url.BatchShortenResult
+	(*BatchShortenResponse)(nil),     // 21: url.BatchShortenResponse
+	(*BatchGetOriginalRequest)(nil),  // 22: url.BatchGetOriginalRequest
+	(*BatchGetOriginalResponse)(nil), // 23: url.BatchGetOriginalResponse
+	(*GetTopURLsRequest)(nil),        // 24: url.GetTopURLsRequest
+	(*GetTopURLsResponse)(nil),       // 25: url.GetTopURLsResponse
+	(*GetGlobalStatsRequest)(nil),    // 26: url.GetGlobalStatsRequest
+	(*GetGlobalStatsResponse)(nil),   // 27: url.GetGlobalStatsResponse
+	(*SetURLStatusRequest)(nil),      // 28: url.SetURLStatusRequest
+	(*SetURLStatusResponse)(nil),     // 29: url.SetURLStatusResponse
+	(*GetURLHistoryRequest)(nil),     // 30: url.GetURLHistoryRequest
+	(*URLHistoryEntry)(nil),          // 31: url.URLHistoryEntry
+	(*GetURLHistoryResponse)(nil),    // 32: url.GetURLHistoryResponse
+	(*ReportURLRequest)(nil),         // 33: url.ReportURLRequest
+	(*ReportURLResponse)(nil),        // 34: url.ReportURLResponse
+	(*AbuseReport)(nil),              // 35: url.AbuseReport
+	(*ListReportsRequest)(nil),       // 36: url.ListReportsRequest
+	(*ListReportsResponse)(nil),      // 37: url.ListReportsResponse
+	(*PurgeURLRequest)(nil),          // 38: url.PurgeURLRequest
+	(*PurgeLayerResult)(nil),         // 39: url.PurgeLayerResult
+	(*PurgeURLResponse)(nil),         // 40: url.PurgeURLResponse
+	(*StreamClicksRequest)(nil),      // 41: url.StreamClicksRequest
+	(*LiveClick)(nil),                // 42: url.LiveClick
+	(*DeleteUserDataRequest)(nil),    // 43: url.DeleteUserDataRequest
+	(*DeleteUserDataResponse)(nil),   // 44: url.DeleteUserDataResponse
+	(*ExportUserDataRequest)(nil),    // 45: url.ExportUserDataRequest
+	(*ExportUserDataChunk)(nil),      // 46: url.ExportUserDataChunk
+	(*WriteHeapProfileRequest)(nil),  // 47: url.WriteHeapProfileRequest
+	(*WriteHeapProfileResponse)(nil), // 48: url.WriteHeapProfileResponse
+}
+var file_url_service_url_proto_depIdxs = []int32{
+	2,  // 0: url.ShortenRequest.variants:type_name -> url.Variant
+	1,  // 1: url.ShortenRequest.rules:type_name -> url.RedirectRule
+	2,  // 2: url.ShortenResponse.variants:type_name -> url.Variant
+	1,  // 3: url.ShortenResponse.rules:type_name -> url.RedirectRule
+	7,  // 4: url.StatsResponse.top_referrers:type_name -> url.BreakdownEntry
+	7,  // 5: url.StatsResponse.countries:type_name -> url.BreakdownEntry
+	7,  // 6: url.StatsResponse.browsers:type_name -> url.BreakdownEntry
+	7,  // 7: url.StatsResponse.devices:type_name -> url.BreakdownEntry
+	7,  // 8: url.StatsResponse.variants:type_name -> url.BreakdownEntry
+	7,  // 9: url.StatsResponse.rules:type_name -> url.BreakdownEntry
+	2,  // 10: url.UpdateURLRequest.variants:type_name -> url.Variant
+	1,  // 11: url.UpdateURLRequest.rules:type_name -> url.RedirectRule
+	2,  // 12: url.UpdateURLResponse.variants:type_name -> url.Variant
+	1,  // 13: url.UpdateURLResponse.rules:type_name -> url.RedirectRule
+	14, // 14: url.ListURLsResponse.urls:type_name -> url.URLSummary
+	17, // 15: url.ListTagsResponse.tags:type_name -> url.TagCount
+	0,  // 16: url.BatchShortenRequest.items:type_name -> url.ShortenRequest
+	3,  // 17: url.BatchShortenResult.url:type_name -> url.ShortenResponse
+	20, // 18: url.BatchShortenResponse.results:type_name -> url.BatchShortenResult
+	5,  // 19: url.BatchGetOriginalResponse.results:type_name -> url.GetOriginalResponse
+	14, // 20: url.GetTopURLsResponse.urls:type_name -> url.URLSummary
+	31, // 21: url.GetURLHistoryResponse.entries:type_name -> url.URLHistoryEntry
+	35, // 22: url.ListReportsResponse.reports:type_name -> url.AbuseReport
+	39, // 23: url.PurgeURLResponse.storage:type_name -> url.PurgeLayerResult
+	39, // 24: url.PurgeURLResponse.cache:type_name -> url.PurgeLayerResult
+	39, // 25: url.PurgeURLResponse.memory:type_name -> url.PurgeLayerResult
+	0,  // 26: url.URLService.ShortenURL:input_type -> url.ShortenRequest
+	4,  // 27: url.URLService.GetOriginalURL:input_type -> url.GetOriginalRequest
+	6,  // 28: url.URLService.GetURLStats:input_type -> url.StatsRequest
+	9,  // 29: url.URLService.DeleteURL:input_type -> url.DeleteURLRequest
+	11, // 30: url.URLService.UpdateURL:input_type -> url.UpdateURLRequest
+	13, // 31: url.URLService.ListURLs:input_type -> url.ListURLsRequest
+	19, // 32: url.URLService.BatchShorten:input_type -> url.BatchShortenRequest
+	22, // 33: url.URLService.BatchGetOriginal:input_type -> url.BatchGetOriginalRequest
+	24, // 34: url.URLService.GetTopURLs:input_type -> url.GetTopURLsRequest
+	26, // 35: url.URLService.GetGlobalStats:input_type -> url.GetGlobalStatsRequest
+	28, // 36: url.URLService.SetURLStatus:input_type -> url.SetURLStatusRequest
+	30, // 37: url.URLService.GetURLHistory:input_type -> url.GetURLHistoryRequest
+	33, // 38: url.URLService.ReportURL:input_type -> url.ReportURLRequest
+	36, // 39: url.URLService.ListReports:input_type -> url.ListReportsRequest
+	38, // 40: url.URLService.PurgeURL:input_type -> url.PurgeURLRequest
+	41, // 41: url.URLService.StreamClicks:input_type -> url.StreamClicksRequest
+	16, // 42: url.URLService.ListTags:input_type -> url.ListTagsRequest
+	43, // 43: url.URLService.DeleteUserData:input_type -> url.DeleteUserDataRequest
+	45, // 44: url.URLService.ExportUserData:input_type -> url.ExportUserDataRequest
+	47, // 45: url.URLService.WriteHeapProfile:input_type -> url.WriteHeapProfileRequest
+	3,  // 46: url.URLService.ShortenURL:output_type -> url.ShortenResponse
+	5,  // 47: url.URLService.GetOriginalURL:output_type -> url.GetOriginalResponse
+	8,  // 48: url.URLService.GetURLStats:output_type -> url.StatsResponse
+	10, // 49: url.URLService.DeleteURL:output_type -> url.DeleteURLResponse
+	12, // 50: url.URLService.UpdateURL:output_type -> url.UpdateURLResponse
+	15, // 51: url.URLService.ListURLs:output_type -> url.ListURLsResponse
+	21, // 52: url.URLService.BatchShorten:output_type -> url.BatchShortenResponse
+	23, // 53: url.URLService.BatchGetOriginal:output_type -> url.BatchGetOriginalResponse
+	25, // 54: url.URLService.GetTopURLs:output_type -> url.GetTopURLsResponse
+	27, // 55: url.URLService.GetGlobalStats:output_type -> url.GetGlobalStatsResponse
+	29, // 56: url.URLService.SetURLStatus:output_type -> url.SetURLStatusResponse
+	32, // 57: url.URLService.GetURLHistory:output_type -> url.GetURLHistoryResponse
+	34, // 58: url.URLService.ReportURL:output_type -> url.ReportURLResponse
+	37, // 59: url.URLService.ListReports:output_type -> url.ListReportsResponse
+	40, // 60: url.URLService.PurgeURL:output_type -> url.PurgeURLResponse
+	42, // 61: url.URLService.StreamClicks:output_type -> url.LiveClick
+	18, // 62: url.URLService.ListTags:output_type -> url.ListTagsResponse
+	44, // 63: url.URLService.DeleteUserData:output_type -> url.DeleteUserDataResponse
+	46, // 64: url.URLService.ExportUserData:output_type -> url.ExportUserDataChunk
+	48, // 65: url.URLService.WriteHeapProfile:output_type -> url.WriteHeapProfileResponse
+	46, // [46:66] is the sub-list for method output_type
+	26, // [26:46] is the sub-list for method input_type
+	26, // [26:26] is the sub-list for extension type_name
+	26, // [26:26] is the sub-list for extension extendee
+	0,  // [0:26] is the sub-list for field type_name
+}
+
+func init() { file_url_service_url_proto_init() }
+func file_url_service_url_proto_init() {
+	if File_url_service_url_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_url_service_url_proto_rawDesc), len(file_url_service_url_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   49,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_url_service_url_proto_goTypes,
+		DependencyIndexes: file_url_service_url_proto_depIdxs,
+		MessageInfos:      file_url_service_url_proto_msgTypes,
+	}.Build()
+	File_url_service_url_proto = out.File
+	file_url_service_url_proto_goTypes = nil
+	file_url_service_url_proto_depIdxs = nil
+}
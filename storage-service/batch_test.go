@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+)
+
+func TestBatchChunkBoundaries(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		s.batchChunkSize = 3
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "c4", OriginalUrl: "https://example.com/taken"})
+
+		// Seven codes split 3, 3 and 1, the middle chunk partly taken
+		var urls []*proto.SaveURLRequest
+		var want []string
+		for i := 1; i <= 7; i++ {
+			code := fmt.Sprintf("c%d", i)
+			urls = append(urls, &proto.SaveURLRequest{ShortCode: code, OriginalUrl: "https://example.com/" + code})
+			if code != "c4" {
+				want = append(want, code)
+			}
+		}
+		saved, err := s.SaveURLs(ctx, &proto.SaveURLsRequest{Urls: urls})
+		if err != nil {
+			t.Fatalf("SaveURLs: %v", err)
+		}
+		if got := slices.Sorted(slices.Values(saved.InsertedShortCodes)); !slices.Equal(got, want) || len(saved.FailedShortCodes) != 0 {
+			t.Errorf("SaveURLs = %v, want all but c4 inserted", saved)
+		}
+		if resp, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "c4"}); err != nil || resp.OriginalUrl != "https://example.com/taken" {
+			t.Errorf("GetURL of the taken code = %v, %v, want it untouched", resp, err)
+		}
+
+		// Duplicates are merged before chunking, so c1 lands in one chunk
+		var deltas []*proto.ClickDelta
+		for i := 7; i >= 1; i-- {
+			deltas = append(deltas, &proto.ClickDelta{ShortCode: fmt.Sprintf("c%d", i), Delta: int64(i)})
+		}
+		deltas = append(deltas, &proto.ClickDelta{ShortCode: "c1", Delta: 10})
+		clicked, err := s.BatchIncrementClicks(ctx, &proto.BatchIncrementClicksRequest{Deltas: deltas})
+		if err != nil {
+			t.Fatalf("BatchIncrementClicks: %v", err)
+		}
+		if clicked.Updated != 7 || len(clicked.FailedShortCodes) != 0 {
+			t.Errorf("BatchIncrementClicks = %v, want 7 updated", clicked)
+		}
+		for i := 1; i <= 7; i++ {
+			code := fmt.Sprintf("c%d", i)
+			want := int64(i)
+			if code == "c1" {
+				want += 10
+			}
+			if stats, err := s.GetStats(ctx, &proto.GetStatsRequest{ShortCode: code}); err != nil || stats.ClickCount != want {
+				t.Errorf("GetStats(%s) = %v, %v, want %d clicks", code, stats, err, want)
+			}
+		}
+	})
+}
+
+func TestBatchIncrementClicksConcurrent(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		s.batchChunkSize = 4
+		const codes, batches = 10, 8
+		for i := 0; i < codes; i++ {
+			saveURL(t, s, &proto.SaveURLRequest{ShortCode: fmt.Sprintf("hot%d", i), OriginalUrl: "https://example.com"})
+		}
+
+		// Every batch touches every code, in its own order
+		var wg sync.WaitGroup
+		for b := 0; b < batches; b++ {
+			wg.Add(1)
+			go func(b int) {
+				defer wg.Done()
+				var deltas []*proto.ClickDelta
+				for i := 0; i < codes; i++ {
+					code := (i + b) % codes
+					deltas = append(deltas, &proto.ClickDelta{ShortCode: fmt.Sprintf("hot%d", code), Delta: int64(code + 1), UniqueDelta: 1})
+				}
+				resp, err := s.BatchIncrementClicks(ctx, &proto.BatchIncrementClicksRequest{Deltas: deltas})
+				if err != nil || len(resp.FailedShortCodes) != 0 {
+					t.Errorf("batch %d: %v, %v", b, resp, err)
+				}
+			}(b)
+		}
+		wg.Wait()
+
+		for i := 0; i < codes; i++ {
+			stats, err := s.GetStats(ctx, &proto.GetStatsRequest{ShortCode: fmt.Sprintf("hot%d", i)})
+			if err != nil {
+				t.Fatalf("GetStats: %v", err)
+			}
+			if want := int64(batches * (i + 1)); stats.ClickCount != want || stats.UniqueClicks != batches {
+				t.Errorf("hot%d: %d clicks and %d unique, want %d and %d", i, stats.ClickCount, stats.UniqueClicks, want, batches)
+			}
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+)
+
+// cleanupConfig controls the janitor that removes expired URLs, deleted URLs
+// past their retention, and old click events, and settles held clicks.
+type cleanupConfig struct {
+	interval              time.Duration
+	batchSize             int
+	deletedRetention      time.Duration
+	clickRetention        time.Duration
+	pendingClickRetention time.Duration
+}
+
+// cleanupStats tracks what the expired URL janitor has done so far.
+type cleanupStats struct {
+	mu                  sync.Mutex
+	totalRowsCleaned    int64
+	totalRowsPurged     int64
+	runs                int64
+	lastRunAt           time.Time
+	lastRunRowsCleaned  int64
+	lastRunRowsPurged   int64
+	totalClicksPurged   int64
+	lastRunClicksPurged int64
+	lastError           string
+}
+
+// runCleanup deletes expired URLs, and purges deleted URLs and click events
+// past their retention, every interval until ctx is cancelled.
+func (s *storageServer) runCleanup(ctx context.Context, config cleanupConfig) {
+	log.Printf("Expired URL cleanup running every %s in batches of %d, purging deleted URLs after %s and click events after %s",
+		config.interval, config.batchSize, config.deletedRetention, config.clickRetention)
+
+	ticker := time.NewTicker(config.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Expired URL cleanup stopped")
+			return
+		case <-ticker.C:
+			s.cleanupURLs(ctx, config)
+		}
+	}
+}
+
+// cleanupURLs removes expired and long deleted rows and old click events,
+// and settles held clicks.
+func (s *storageServer) cleanupURLs(ctx context.Context, config cleanupConfig) {
+	cleaned, runErr := s.deleteInBatches(ctx, config.batchSize, `
+		DELETE FROM urls
+		WHERE (tenant_id, short_code) IN (
+			SELECT tenant_id, short_code
+			FROM urls
+			WHERE expires_at IS NOT NULL AND expires_at <= NOW()
+			ORDER BY expires_at
+			LIMIT $1
+		)
+	`)
+	if runErr != nil {
+		log.Printf("Failed to clean up expired URLs: %v", runErr)
+	}
+
+	purged, err := s.deleteInBatches(ctx, config.batchSize, `
+		DELETE FROM urls
+		WHERE (tenant_id, short_code) IN (
+			SELECT tenant_id, short_code
+			FROM urls
+			WHERE deleted_at IS NOT NULL AND deleted_at <= $2
+			ORDER BY deleted_at
+			LIMIT $1
+		)
+	`, time.Now().Add(-config.deletedRetention))
+	if err != nil {
+		log.Printf("Failed to purge deleted URLs: %v", err)
+		runErr = err
+	}
+
+	clicksPurged, err := s.deleteInBatches(ctx, config.batchSize, `
+		DELETE FROM url_clicks
+		WHERE id IN (
+			SELECT id
+			FROM url_clicks
+			WHERE clicked_at <= $2
+			ORDER BY clicked_at
+			LIMIT $1
+		)
+	`, time.Now().Add(-config.clickRetention))
+	if err != nil {
+		log.Printf("Failed to purge click events: %v", err)
+		runErr = err
+	}
+
+	if err := s.cleanupPendingClicks(ctx, config); err != nil {
+		log.Printf("Failed to settle held clicks: %v", err)
+		runErr = err
+	}
+
+	s.cleanup.mu.Lock()
+	s.cleanup.runs++
+	s.cleanup.totalRowsCleaned += cleaned
+	s.cleanup.totalRowsPurged += purged
+	s.cleanup.lastRunAt = time.Now()
+	s.cleanup.lastRunRowsCleaned = cleaned
+	s.cleanup.lastRunRowsPurged = purged
+	s.cleanup.totalClicksPurged += clicksPurged
+	s.cleanup.lastRunClicksPurged = clicksPurged
+	s.cleanup.lastError = ""
+	if runErr != nil {
+		s.cleanup.lastError = runErr.Error()
+	}
+	total := s.cleanup.totalRowsCleaned
+	s.cleanup.mu.Unlock()
+
+	log.Printf("Expired URL cleanup removed %d rows (%d total) and purged %d deleted rows and %d click events", cleaned, total, purged, clicksPurged)
+}
+
+// deleteInBatches runs query, whose $1 is the batch size, until it deletes
+// fewer than batchSize rows, so no single statement holds locks on a large
+// part of the table.
+func (s *storageServer) deleteInBatches(ctx context.Context, batchSize int, query string, args ...interface{}) (int64, error) {
+	var deleted int64
+	for ctx.Err() == nil {
+		result, err := s.db.ExecContext(ctx, query, append([]interface{}{batchSize}, args...)...)
+		if err != nil {
+			return deleted, err
+		}
+
+		rowsAffected, _ := result.RowsAffected()
+		deleted += rowsAffected
+		if rowsAffected < int64(batchSize) {
+			break
+		}
+	}
+	return deleted, nil
+}
+
+func (s *storageServer) GetCleanupStats(ctx context.Context, req *proto.GetCleanupStatsRequest) (*proto.GetCleanupStatsResponse, error) {
+	s.cleanup.mu.Lock()
+	defer s.cleanup.mu.Unlock()
+
+	var lastRunAt string
+	if !s.cleanup.lastRunAt.IsZero() {
+		lastRunAt = s.cleanup.lastRunAt.Format(time.RFC3339)
+	}
+
+	return &proto.GetCleanupStatsResponse{
+		TotalRowsCleaned:         s.cleanup.totalRowsCleaned,
+		Runs:                     s.cleanup.runs,
+		LastRunAt:                lastRunAt,
+		LastRunRowsCleaned:       s.cleanup.lastRunRowsCleaned,
+		LastError:                s.cleanup.lastError,
+		TotalRowsPurged:          s.cleanup.totalRowsPurged,
+		LastRunRowsPurged:        s.cleanup.lastRunRowsPurged,
+		TotalClickEventsPurged:   s.cleanup.totalClicksPurged,
+		LastRunClickEventsPurged: s.cleanup.lastRunClicksPurged,
+	}, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCleanupURLsInBatches(t *testing.T) {
+	// Statements show up as spans, which tells how many batches ran
+	spans := recordSpans()
+
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		past, future := rfc3339(time.Now().Add(-time.Hour)), rfc3339(time.Now().Add(time.Hour))
+		for i := 0; i < 25; i++ {
+			saveURL(t, s, &proto.SaveURLRequest{ShortCode: fmt.Sprintf("expired%d", i), OriginalUrl: "https://example.com", ExpiresAt: past})
+		}
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "later", OriginalUrl: "https://example.com", ExpiresAt: future})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "forever", OriginalUrl: "https://example.com"})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "deleted", OriginalUrl: "https://example.com"})
+		if _, err := s.DeleteURL(ctx, &proto.DeleteURLRequest{ShortCode: "deleted"}); err != nil {
+			t.Fatalf("DeleteURL: %v", err)
+		}
+
+		before := len(spans.Ended())
+		s.cleanupURLs(ctx, cleanupConfig{batchSize: 10, deletedRetention: 0, clickRetention: time.Hour})
+
+		// Batches of 10, 10 and 5 expired rows, then one of the deleted row
+		var deletes int
+		for _, span := range spans.Ended()[before:] {
+			if span.Name() == "DELETE urls" {
+				deletes++
+			}
+		}
+		if deletes != 4 {
+			t.Errorf("%d DELETE statements on urls, want 3 batches of expired rows and 1 of deleted ones", deletes)
+		}
+
+		var left []string
+		rows, err := s.db.Query(`SELECT short_code FROM urls ORDER BY short_code`)
+		if err != nil {
+			t.Fatalf("listing URLs: %v", err)
+		}
+		for rows.Next() {
+			var code string
+			rows.Scan(&code)
+			left = append(left, code)
+		}
+		rows.Close()
+		if fmt.Sprint(left) != "[forever later]" {
+			t.Errorf("URLs left = %v, want [forever later]", left)
+		}
+
+		stats, err := s.GetCleanupStats(ctx, &proto.GetCleanupStatsRequest{})
+		if err != nil || stats.Runs != 1 || stats.LastRunRowsCleaned != 25 || stats.TotalRowsPurged != 1 || stats.LastError != "" {
+			t.Errorf("GetCleanupStats = %v, %v, want one run cleaning 25 rows and purging 1", stats, err)
+		}
+	})
+}
+
+func TestRunCleanupStops(t *testing.T) {
+	s := newTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.runCleanup(ctx, cleanupConfig{interval: 10 * time.Millisecond, batchSize: 10, deletedRetention: time.Hour, clickRetention: time.Hour})
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats, _ := s.GetCleanupStats(ctx, &proto.GetCleanupStatsRequest{})
+		if stats.Runs > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("cleanup didn't run within 5s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cleanup still running 5s after its context was cancelled")
+	}
+}
+
+func TestPurgeDeletedAfterRetention(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		for _, code := range []string{"recent", "old", "live"} {
+			saveURL(t, s, &proto.SaveURLRequest{ShortCode: code, OriginalUrl: "https://example.com/" + code})
+		}
+		for _, code := range []string{"recent", "old"} {
+			if _, err := s.DeleteURL(ctx, &proto.DeleteURLRequest{ShortCode: code}); err != nil {
+				t.Fatalf("DeleteURL(%s): %v", code, err)
+			}
+		}
+		if _, err := s.db.ExecContext(ctx, `UPDATE urls SET deleted_at = $1 WHERE short_code = $2`, time.Now().Add(-2*time.Hour), "old"); err != nil {
+			t.Fatalf("backdating old: %v", err)
+		}
+
+		// Soft-deleted rows are hidden from reads but kept for the retention
+		if _, err := s.GetStats(ctx, &proto.GetStatsRequest{ShortCode: "recent"}); status.Code(err) != codes.NotFound {
+			t.Errorf("GetStats of a deleted URL: got %v, want NotFound", err)
+		}
+		s.cleanupURLs(ctx, cleanupConfig{batchSize: 10, deletedRetention: time.Hour, clickRetention: time.Hour})
+
+		tests := []struct {
+			code string
+			want codes.Code
+		}{
+			{"recent", codes.OK},
+			{"old", codes.NotFound},
+			{"live", codes.OK},
+		}
+		for _, tt := range tests {
+			_, err := s.GetStats(ctx, &proto.GetStatsRequest{ShortCode: tt.code, IncludeDeleted: true})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("GetStats(%s) with include_deleted after cleanup: got %v, want %v", tt.code, err, tt.want)
+			}
+		}
+
+		// A deleted code can be saved again with resurrect
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "recent", OriginalUrl: "https://example.com/again", Resurrect: true})
+		resp, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "recent"})
+		if err != nil || resp.OriginalUrl != "https://example.com/again" {
+			t.Errorf("GetURL after resurrecting = %v, %v", resp, err)
+		}
+	})
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxClickEvents bounds one RecordClick batch
+	maxClickEvents = 10000
+
+	// maxClickFieldLength truncates referrers and user agents, which are
+	// client supplied
+	maxClickFieldLength = 1024
+
+	// maxClickBuckets bounds GetClickTimeSeries to about a year of hours
+	maxClickBuckets = 24 * 366
+
+	defaultBreakdownLimit = 10
+	maxBreakdownLimit     = 100
+)
+
+// invalidParameterValue is the SQLSTATE Postgres reports for an unknown
+// time zone.
+const invalidParameterValue = "22023"
+
+// clickBucketSteps maps the supported buckets to their nominal length.
+// Days in a time zone with DST can be an hour shorter or longer.
+var clickBucketSteps = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+}
+
+// RecordClick stores a batch of click events with one statement. Events for
+// unknown or deleted codes are dropped.
+func (s *storageServer) RecordClick(ctx context.Context, req *proto.RecordClickRequest) (*proto.RecordClickResponse, error) {
+	logf(ctx, "Storage RecordClick request for %d events", len(req.Events))
+
+	if len(req.Events) == 0 {
+		return &proto.RecordClickResponse{}, nil
+	}
+	if len(req.Events) > maxClickEvents {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d events can be recorded at once", maxClickEvents)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	n := len(req.Events)
+	shortCodes := make([]string, 0, n)
+	clickedAt := make([]string, 0, n)
+	referrers := make([]string, 0, n)
+	userAgents := make([]string, 0, n)
+	countries := make([]string, 0, n)
+	hosts := make([]string, 0, n)
+	browsers := make([]string, 0, n)
+	devices := make([]string, 0, n)
+	bots := make([]bool, 0, n)
+	variants := make([]string, 0, n)
+	rules := make([]string, 0, n)
+	for i, e := range req.Events {
+		at := now
+		if e.ClickedAt != "" {
+			t, err := time.Parse(time.RFC3339Nano, e.ClickedAt)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid clicked_at of event %d: %v", i, err)
+			}
+			at = t.UTC().Format(time.RFC3339Nano)
+		}
+		// Anything but a two letter code is dropped rather than failing
+		// the batch
+		country := strings.ToUpper(e.Country)
+		if len(country) != 2 {
+			country = ""
+		}
+
+		shortCodes = append(shortCodes, e.ShortCode)
+		clickedAt = append(clickedAt, at)
+		referrers = append(referrers, e.Referrer)
+		userAgents = append(userAgents, e.UserAgent)
+		countries = append(countries, country)
+		browser, device := classifyUserAgent(e.UserAgent)
+		hosts = append(hosts, referrerHost(e.Referrer))
+		browsers = append(browsers, browser)
+		devices = append(devices, device)
+		bots = append(bots, e.Bot)
+		variants = append(variants, e.Variant)
+		rules = append(rules, e.Rule)
+	}
+
+	// On SQLite, CROSS JOIN keeps the arrays in order so each is matched by
+	// key as it is read, rather than the planner multiplying them out first
+	d := s.db.dialect
+	result, err := s.db.ExecContext(ctx, d.sql(`
+		INSERT INTO url_clicks (tenant_id, short_code, clicked_at, referrer, user_agent, country, referrer_host, browser, device, bot, variant, redirect_rule)
+		SELECT urls.tenant_id, urls.short_code, t.at, NULLIF(left(t.ref, $6), ''), NULLIF(left(t.ua, $6), ''), NULLIF(t.country, ''),
+			NULLIF(left(t.host, $6), ''), NULLIF(t.browser, ''), NULLIF(t.device, ''), t.bot, NULLIF(left(t.variant, 32), ''),
+			NULLIF(left(t.rule, 16), '')
+		FROM unnest($1::text[], $2::timestamptz[], $3::text[], $4::text[], $5::text[], $7::text[], $8::text[], $9::text[], $10::boolean[], $11::text[], $12::text[])
+			AS t(code, at, ref, ua, country, host, browser, device, bot, variant, rule)
+		JOIN urls ON `+keyIs("urls", "t.code")+` AND urls.deleted_at IS NULL
+	`, `
+		INSERT INTO url_clicks (tenant_id, short_code, clicked_at, referrer, user_agent, country, referrer_host, browser, device, bot, variant, redirect_rule)
+		SELECT urls.tenant_id, urls.short_code, at.value, NULLIF(substr(ref.value, 1, $6), ''), NULLIF(substr(ua.value, 1, $6), ''), NULLIF(country.value, ''),
+			NULLIF(substr(host.value, 1, $6), ''), NULLIF(browser.value, ''), NULLIF(device.value, ''), bot.value, NULLIF(substr(variant.value, 1, 32), ''),
+			NULLIF(substr(rule.value, 1, 16), '')
+		FROM json_each($1) AS code
+			CROSS JOIN json_each($2) AS at ON at.key = code.key
+			CROSS JOIN json_each($3) AS ref ON ref.key = code.key
+			CROSS JOIN json_each($4) AS ua ON ua.key = code.key
+			CROSS JOIN json_each($5) AS country ON country.key = code.key
+			CROSS JOIN json_each($7) AS host ON host.key = code.key
+			CROSS JOIN json_each($8) AS browser ON browser.key = code.key
+			CROSS JOIN json_each($9) AS device ON device.key = code.key
+			CROSS JOIN json_each($10) AS bot ON bot.key = code.key
+			CROSS JOIN json_each($11) AS variant ON variant.key = code.key
+			CROSS JOIN json_each($12) AS rule ON rule.key = code.key
+			JOIN urls ON `+keyIs("urls", "code.value")+` AND urls.deleted_at IS NULL
+	`), d.array(shortCodes), d.timestamps(clickedAt), d.array(referrers), d.array(userAgents), d.array(countries), maxClickFieldLength,
+		d.array(hosts), d.array(browsers), d.array(devices), d.array(bots), d.array(variants), d.array(rules))
+	if err != nil {
+		logf(ctx, "Failed to record clicks: %v", err)
+		return nil, dbError(err, "failed to record clicks")
+	}
+
+	recorded, _ := result.RowsAffected()
+	logf(ctx, "Recorded %d of %d click events", recorded, n)
+	return &proto.RecordClickResponse{Recorded: recorded}, nil
+}
+
+// GetClickTimeSeries counts a URL's clicks per hour or day over [start, end).
+// Buckets are aligned to the requested time zone, so a day bucket runs from
+// local midnight to local midnight even across DST changes. Bot clicks are
+// left out unless asked for.
+func (s *storageServer) GetClickTimeSeries(ctx context.Context, req *proto.GetClickTimeSeriesRequest) (*proto.GetClickTimeSeriesResponse, error) {
+	logf(ctx, "Storage GetClickTimeSeries request for %s from %s to %s by %q", req.ShortCode, req.Start, req.End, req.Bucket)
+
+	if req.ShortCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "short_code is required")
+	}
+	bucket := req.Bucket
+	if bucket == "" {
+		bucket = "day"
+	}
+	step, ok := clickBucketSteps[bucket]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid bucket %q, want hour or day", req.Bucket)
+	}
+	timeZone := req.TimeZone
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+
+	start, err := time.Parse(time.RFC3339, req.Start)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid start: %v", err)
+	}
+	end, err := time.Parse(time.RFC3339, req.End)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid end: %v", err)
+	}
+	if !end.After(start) {
+		return nil, status.Error(codes.InvalidArgument, "end must be after start")
+	}
+	if end.Sub(start)/step > maxClickBuckets {
+		return nil, status.Errorf(codes.InvalidArgument, "range spans more than %d buckets", maxClickBuckets)
+	}
+
+	var buckets []*proto.ClickBucket
+	if s.db.dialect.sqlite() {
+		buckets, err = s.sqliteClickTimeSeries(ctx, req.ShortCode, start, end, bucket, timeZone, req.IncludeBots)
+	} else {
+		buckets, err = s.postgresClickTimeSeries(ctx, req.ShortCode, start, end, bucket, timeZone, req.IncludeBots)
+	}
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		logf(ctx, "Database error: %v", err)
+		return nil, dbError(err, "failed to get click time series")
+	}
+
+	resp := &proto.GetClickTimeSeriesResponse{Buckets: buckets}
+	for _, b := range buckets {
+		resp.TotalClicks += b.Clicks
+	}
+	return resp, nil
+}
+
+// postgresClickTimeSeries buckets the clicks in SQL. The series is
+// generated in the time zone too, so every bucket is listed even when it
+// has no clicks.
+func (s *storageServer) postgresClickTimeSeries(ctx context.Context, shortCode string, start, end time.Time, bucket, timeZone string, includeBots bool) ([]*proto.ClickBucket, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		WITH counts AS (
+			SELECT date_trunc($4, clicked_at, $5) AS start, COUNT(*) AS clicks
+			FROM url_clicks
+			WHERE `+keyIs("url_clicks", "$1")+`
+				AND clicked_at >= $2
+				AND clicked_at < $3
+				AND ($6 OR NOT bot)
+			GROUP BY 1
+		)
+		SELECT b.start, COALESCE(counts.clicks, 0)
+		FROM generate_series(
+			date_trunc($4, $2::timestamptz, $5),
+			$3::timestamptz - interval '1 microsecond',
+			('1 ' || $4)::interval,
+			$5
+		) AS b(start)
+		LEFT JOIN counts ON counts.start = b.start
+		ORDER BY b.start
+	`, shortCode, start, end, bucket, timeZone, includeBots)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == invalidParameterValue {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid time_zone %q", timeZone)
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []*proto.ClickBucket
+	for rows.Next() {
+		var bucketStart time.Time
+		var clicks int64
+		if err := rows.Scan(&bucketStart, &clicks); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, &proto.ClickBucket{
+			Start:  bucketStart.UTC().Format(time.RFC3339),
+			Clicks: clicks,
+		})
+	}
+	return buckets, rows.Err()
+}
+
+// sqliteClickTimeSeries counts the clicks per minute in SQL, which has no
+// time zones, and sums the minutes into local buckets. Minutes line up with
+// the buckets of every zone, including those offset by 30 or 45 minutes.
+func (s *storageServer) sqliteClickTimeSeries(ctx context.Context, shortCode string, start, end time.Time, bucket, timeZone string, includeBots bool) ([]*proto.ClickBucket, error) {
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid time_zone %q", timeZone)
+	}
+
+	// Stored timestamps start with "2006-01-02 15:04" in UTC
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT substr(clicked_at, 1, 16), COUNT(*)
+		FROM url_clicks
+		WHERE `+keyIs("url_clicks", "$1")+`
+			AND clicked_at >= $2
+			AND clicked_at < $3
+			AND ($4 OR NOT bot)
+		GROUP BY 1
+	`, shortCode, start, end, includeBots)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	truncate := func(t time.Time) time.Time {
+		t = t.In(loc)
+		if bucket == "hour" {
+			return t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+		}
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+
+	counts := make(map[time.Time]int64)
+	for rows.Next() {
+		var minute string
+		var clicks int64
+		if err := rows.Scan(&minute, &clicks); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse("2006-01-02 15:04", minute)
+		if err != nil {
+			return nil, err
+		}
+		counts[truncate(t).UTC()] += clicks
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var buckets []*proto.ClickBucket
+	for b := truncate(start); b.Before(end); {
+		buckets = append(buckets, &proto.ClickBucket{
+			Start:  b.UTC().Format(time.RFC3339),
+			Clicks: counts[b.UTC()],
+		})
+		if bucket == "hour" {
+			b = b.Add(time.Hour)
+		} else {
+			b = time.Date(b.Year(), b.Month(), b.Day()+1, 0, 0, 0, 0, loc)
+		}
+	}
+	return buckets, nil
+}
+
+// GetClickBreakdown counts a URL's recorded clicks by referring host,
+// country, browser, device, variant and redirect rule, each with one GROUP
+// BY over its events.
+func (s *storageServer) GetClickBreakdown(ctx context.Context, req *proto.GetClickBreakdownRequest) (*proto.GetClickBreakdownResponse, error) {
+	logf(ctx, "Storage GetClickBreakdown request for %s", req.ShortCode)
+
+	if req.ShortCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "short_code is required")
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultBreakdownLimit
+	}
+	if limit < 0 || limit > maxBreakdownLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "limit must be between 1 and %d", maxBreakdownLimit)
+	}
+
+	db := s.reader(false)
+	resp := &proto.GetClickBreakdownResponse{}
+	for _, breakdown := range []struct {
+		column  string
+		entries *[]*proto.BreakdownEntry
+	}{
+		{"referrer_host", &resp.Referrers},
+		{"country", &resp.Countries},
+		{"browser", &resp.Browsers},
+		{"device", &resp.Devices},
+		{"variant", &resp.Variants},
+		{"redirect_rule", &resp.Rules},
+	} {
+		entries, err := clickBreakdown(ctx, db, breakdown.column, req.ShortCode, limit)
+		if err != nil {
+			logf(ctx, "Database error: %v", err)
+			return nil, dbError(err, "failed to get click breakdown")
+		}
+		*breakdown.entries = entries
+	}
+	return resp, nil
+}
+
+// clickBreakdown returns the limit most clicked values of column, which
+// must be a trusted column name.
+func clickBreakdown(ctx context.Context, db tracedDB, column, shortCode string, limit int32) ([]*proto.BreakdownEntry, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+column+`, COUNT(*) AS clicks
+		FROM url_clicks
+		WHERE `+keyIs("url_clicks", "$1")+` AND `+column+` IS NOT NULL
+		GROUP BY `+column+`
+		ORDER BY clicks DESC, `+column+`
+		LIMIT $2
+	`, shortCode, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*proto.BreakdownEntry
+	for rows.Next() {
+		entry := &proto.BreakdownEntry{}
+		if err := rows.Scan(&entry.Value, &entry.Clicks); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// ClaimClick counts a click on a URL if it has clicks left, in one
+// conditional UPDATE so concurrent clicks can never exceed max_clicks. The
+// count is stored at once rather than batched like other clicks.
+func (s *storageServer) ClaimClick(ctx context.Context, req *proto.ClaimClickRequest) (*proto.ClaimClickResponse, error) {
+	logf(ctx, "Storage ClaimClick request for: %s", req.ShortCode)
+
+	if req.ShortCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "short_code is required")
+	}
+
+	var remaining sql.NullInt64
+	var err error
+	if req.Peek {
+		err = s.db.QueryRowContext(ctx, `
+			SELECT max_clicks - click_count
+			FROM urls
+			WHERE `+keyIs("urls", "$1")+`
+				AND deleted_at IS NULL
+				AND (expires_at IS NULL OR expires_at > NOW())
+				AND (max_clicks IS NULL OR click_count < max_clicks)
+		`, req.ShortCode).Scan(&remaining)
+	} else {
+		err = s.db.QueryRowContext(ctx, `
+			UPDATE urls
+			SET click_count = click_count + 1,
+				updated_at = NOW()
+			WHERE `+keyIs("urls", "$1")+`
+				AND deleted_at IS NULL
+				AND (expires_at IS NULL OR expires_at > NOW())
+				AND (max_clicks IS NULL OR click_count < max_clicks)
+			RETURNING max_clicks - click_count
+		`, req.ShortCode).Scan(&remaining)
+	}
+	if err == nil {
+		return &proto.ClaimClickResponse{Claimed: true, Remaining: remaining.Int64}, nil
+	}
+	if err != sql.ErrNoRows {
+		logf(ctx, "Database error: %v", err)
+		return nil, dbError(err, "failed to claim click")
+	}
+
+	// Either the URL is used up or it doesn't exist
+	var fallbackURL sql.NullString
+	err = s.db.QueryRowContext(ctx, `
+		SELECT fallback_url
+		FROM urls
+		WHERE `+keyIs("urls", "$1")+`
+			AND deleted_at IS NULL
+			AND (expires_at IS NULL OR expires_at > NOW())
+	`, req.ShortCode).Scan(&fallbackURL)
+	if err == sql.ErrNoRows {
+		return nil, status.Errorf(codes.NotFound, "URL not found: %s", req.ShortCode)
+	} else if err != nil {
+		logf(ctx, "Database error: %v", err)
+		return nil, dbError(err, "failed to claim click")
+	}
+	logf(ctx, "URL %s has reached its click limit", req.ShortCode)
+	return &proto.ClaimClickResponse{FallbackUrl: fallbackURL.String}, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+)
+
+func TestClaimClickConcurrent(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "limited", OriginalUrl: "https://example.com", MaxClicks: 3, FallbackUrl: "https://example.com/over"})
+
+		var mu sync.Mutex
+		var remaining []int64
+		var fallbacks int
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := s.ClaimClick(ctx, &proto.ClaimClickRequest{ShortCode: "limited"})
+				if err != nil {
+					t.Errorf("ClaimClick: %v", err)
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				if resp.Claimed {
+					remaining = append(remaining, resp.Remaining)
+				} else if resp.FallbackUrl == "https://example.com/over" {
+					fallbacks++
+				}
+			}()
+		}
+		wg.Wait()
+
+		// Exactly the limit is claimed, each click counting down once
+		slices.Sort(remaining)
+		if !slices.Equal(remaining, []int64{0, 1, 2}) || fallbacks != 47 {
+			t.Errorf("claimed with %v remaining and sent %d to the fallback, want [0 1 2] and 47", remaining, fallbacks)
+		}
+		stats, err := s.GetStats(ctx, &proto.GetStatsRequest{ShortCode: "limited"})
+		if err != nil || stats.ClickCount != 3 {
+			t.Errorf("GetStats = %v, %v, want 3 clicks", stats, err)
+		}
+	})
+}
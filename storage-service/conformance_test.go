@@ -0,0 +1,1364 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The conformance suite runs every case against each database, holding
+// PostgreSQL and SQLite to the same behaviour. SQLite always runs. PostgreSQL
+// runs with STORAGE_TEST_POSTGRES=true, on the server the DB_* variables
+// point at, in a database of its own per case.
+
+// forEachBackend runs test on a fresh storage server of every backend.
+func forEachBackend(t *testing.T, test func(t *testing.T, s *storageServer)) {
+	t.Run(driverSQLite, func(t *testing.T) {
+		test(t, newTestServer(t))
+	})
+	t.Run(driverPostgres, func(t *testing.T) {
+		if os.Getenv("STORAGE_TEST_POSTGRES") != "true" {
+			t.Skip("set STORAGE_TEST_POSTGRES=true and DB_* to run against PostgreSQL")
+		}
+		test(t, newPostgresTestServer(t))
+	})
+}
+
+// newPostgresTestServer returns a storage server on a new, empty database on
+// the configured PostgreSQL server, dropped when the test ends.
+func newPostgresTestServer(t *testing.T) *storageServer {
+	t.Helper()
+	t.Setenv("DB_DRIVER", driverPostgres)
+	config := getConfig()
+	admin, err := sql.Open("postgres", postgresConnString(config, config.Host, config.Port))
+	if err != nil {
+		t.Fatalf("open PostgreSQL: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	name := fmt.Sprintf("storage_conformance_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatalf("create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP DATABASE IF EXISTS " + name); err != nil {
+			t.Errorf("drop database %s: %v", name, err)
+		}
+	})
+
+	t.Setenv("DB_NAME", name)
+	s, err := NewStorageServer()
+	if err != nil {
+		t.Fatalf("NewStorageServer: %v", err)
+	}
+	// Registered last, so it runs before the database is dropped
+	t.Cleanup(func() { s.db.Close() })
+	return s
+}
+
+// saveURL saves req, failing the test if it doesn't succeed.
+func saveURL(t *testing.T, s *storageServer, req *proto.SaveURLRequest) {
+	t.Helper()
+	if _, err := s.SaveURL(context.Background(), req); err != nil {
+		t.Fatalf("SaveURL %s: %v", req.ShortCode, err)
+	}
+}
+
+func rfc3339(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func TestConformanceSaveAndGetURL(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+		saveURL(t, s, &proto.SaveURLRequest{
+			ShortCode:     "abc123",
+			OriginalUrl:   "https://example.com/page",
+			ExpiresAt:     rfc3339(expiresAt),
+			UserId:        "alice",
+			MaxClicks:     5,
+			FallbackUrl:   "https://example.com/gone",
+			NotBefore:     rfc3339(expiresAt.Add(-30 * time.Minute)),
+			ComingSoonUrl: "https://example.com/soon",
+			CreateOnly:    true,
+			Resurrect:     true,
+			QueryTemplate: "utm_source=test",
+		})
+
+		resp, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "abc123"})
+		if err != nil {
+			t.Fatalf("GetURL: %v", err)
+		}
+		if !resp.Found || resp.OriginalUrl != "https://example.com/page" || resp.UserId != "alice" || resp.MaxClicks != 5 ||
+			resp.FallbackUrl != "https://example.com/gone" || resp.QueryTemplate != "utm_source=test" || resp.ComingSoonUrl != "https://example.com/soon" {
+			t.Errorf("GetURL = %v", resp)
+		}
+		if got, err := time.Parse(time.RFC3339, resp.ExpiresAt); err != nil || !got.Equal(expiresAt) {
+			t.Errorf("expires_at = %q, want %s", resp.ExpiresAt, rfc3339(expiresAt))
+		}
+		if got, err := time.Parse(time.RFC3339, resp.NotBefore); err != nil || !got.Equal(expiresAt.Add(-30*time.Minute)) {
+			t.Errorf("not_before = %q, want %s", resp.NotBefore, rfc3339(expiresAt.Add(-30*time.Minute)))
+		}
+		if _, err := time.Parse(time.RFC3339, resp.CreatedAt); err != nil {
+			t.Errorf("created_at = %q: %v", resp.CreatedAt, err)
+		}
+
+		if _, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "missing"}); status.Code(err) != codes.NotFound {
+			t.Errorf("GetURL of a missing code: got %v, want NotFound", err)
+		}
+	})
+}
+
+func TestConformanceExpiredURL(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "old", OriginalUrl: "https://example.com", ExpiresAt: rfc3339(time.Now().Add(-time.Minute))})
+
+		if _, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "old"}); status.Code(err) != codes.NotFound {
+			t.Errorf("GetURL of an expired URL: got %v, want NotFound", err)
+		}
+		resp, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "old", IncludeExpired: true})
+		if err != nil || resp.OriginalUrl != "https://example.com" {
+			t.Errorf("GetURL with include_expired = %v, %v", resp, err)
+		}
+		// Its stats stay readable
+		stats, err := s.GetStats(ctx, &proto.GetStatsRequest{ShortCode: "old"})
+		if err != nil || stats.ExpiresAt == "" {
+			t.Errorf("GetStats of an expired URL = %v, %v, want its expiry", stats, err)
+		}
+	})
+}
+
+func TestConformanceDeleteAndPurge(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "bye", OriginalUrl: "https://example.com", UserId: "alice", FallbackUrl: "https://example.com/moved"})
+
+		if _, err := s.DeleteURL(ctx, &proto.DeleteURLRequest{ShortCode: "bye"}); err != nil {
+			t.Fatalf("DeleteURL: %v", err)
+		}
+		if _, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "bye"}); status.Code(err) != codes.NotFound {
+			t.Errorf("GetURL of a deleted URL: got %v, want NotFound", err)
+		}
+		resp, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "bye", IncludeDeleted: true})
+		if err != nil || !resp.Deleted || resp.FallbackUrl != "https://example.com/moved" {
+			t.Errorf("GetURL with include_deleted = %v, %v, want it deleted with its fallback", resp, err)
+		}
+		stats, err := s.GetStats(ctx, &proto.GetStatsRequest{ShortCode: "bye", IncludeDeleted: true})
+		if err != nil || stats.DeletedAt == "" {
+			t.Errorf("GetStats with include_deleted = %v, %v", stats, err)
+		}
+		if _, err := s.DeleteURL(ctx, &proto.DeleteURLRequest{ShortCode: "bye"}); status.Code(err) != codes.NotFound {
+			t.Errorf("second DeleteURL: got %v, want NotFound", err)
+		}
+
+		purged, err := s.PurgeURL(ctx, &proto.PurgeURLRequest{ShortCode: "bye"})
+		if err != nil || !purged.Purged {
+			t.Errorf("PurgeURL = %v, %v", purged, err)
+		}
+		if purged, err := s.PurgeURL(ctx, &proto.PurgeURLRequest{ShortCode: "bye"}); err != nil || purged.Purged {
+			t.Errorf("second PurgeURL = %v, %v", purged, err)
+		}
+		if _, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "bye", IncludeDeleted: true}); status.Code(err) != codes.NotFound {
+			t.Errorf("GetURL of a purged URL: got %v, want NotFound", err)
+		}
+	})
+}
+
+func TestConformanceClicks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "clicky", OriginalUrl: "https://example.com"})
+
+		if _, err := s.IncrementClick(ctx, &proto.IncrementClickRequest{ShortCode: "clicky"}); err != nil {
+			t.Fatalf("IncrementClick: %v", err)
+		}
+		lastAccessed := time.Now().Add(-time.Minute).Truncate(time.Second)
+		resp, err := s.BatchIncrementClicks(ctx, &proto.BatchIncrementClicksRequest{Deltas: []*proto.ClickDelta{
+			{ShortCode: "clicky", Delta: 4, UniqueDelta: 2, BotDelta: 3, LastAccessedAt: rfc3339(lastAccessed)},
+			{ShortCode: "unsaved", Delta: 1},
+		}})
+		if err != nil {
+			t.Fatalf("BatchIncrementClicks: %v", err)
+		}
+		if resp.Updated != 1 || !slices.Equal(resp.BufferedShortCodes, []string{"unsaved"}) {
+			t.Errorf("BatchIncrementClicks = %v, want 1 updated and unsaved buffered", resp)
+		}
+
+		stats, err := s.GetStats(ctx, &proto.GetStatsRequest{ShortCode: "clicky"})
+		if err != nil {
+			t.Fatalf("GetStats: %v", err)
+		}
+		if stats.ClickCount != 5 || stats.UniqueClicks != 2 || stats.BotClicks != 3 {
+			t.Errorf("GetStats = %d clicks, %d unique and %d bots, want 5, 2 and 3", stats.ClickCount, stats.UniqueClicks, stats.BotClicks)
+		}
+		if got, err := time.Parse(time.RFC3339, stats.LastAccessedAt); err != nil || got.Before(lastAccessed) {
+			t.Errorf("last_accessed_at = %q, want at least %s", stats.LastAccessedAt, rfc3339(lastAccessed))
+		}
+
+		// Clicks that arrived before their link are added once it is saved
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "unsaved", OriginalUrl: "https://example.com/late"})
+		if stats, err := s.GetStats(ctx, &proto.GetStatsRequest{ShortCode: "unsaved"}); err != nil || stats.ClickCount != 1 {
+			t.Errorf("GetStats of a link saved after its click = %v, %v", stats, err)
+		}
+	})
+}
+
+func TestConformanceBatchSaveAndGet(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "taken", OriginalUrl: "https://example.com/first"})
+
+		resp, err := s.SaveURLs(ctx, &proto.SaveURLsRequest{Urls: []*proto.SaveURLRequest{
+			{ShortCode: "b1", OriginalUrl: "https://example.com/1", UserId: "alice"},
+			{ShortCode: "taken", OriginalUrl: "https://example.com/second"},
+			{ShortCode: "b2", OriginalUrl: "https://example.com/2", ExpiresAt: rfc3339(time.Now().Add(time.Hour))},
+		}})
+		if err != nil {
+			t.Fatalf("SaveURLs: %v", err)
+		}
+		inserted := slices.Sorted(slices.Values(resp.InsertedShortCodes))
+		if !slices.Equal(inserted, []string{"b1", "b2"}) || len(resp.FailedShortCodes) != 0 {
+			t.Errorf("SaveURLs = %v, want b1 and b2 inserted", resp)
+		}
+
+		urls, err := s.GetURLs(ctx, &proto.GetURLsRequest{ShortCodes: []string{"b1", "taken", "b2", "missing"}})
+		if err != nil {
+			t.Fatalf("GetURLs: %v", err)
+		}
+		if len(urls.Urls) != 3 || urls.Urls["b1"].UserId != "alice" || urls.Urls["taken"].OriginalUrl != "https://example.com/first" || urls.Urls["b2"].ExpiresAt == "" {
+			t.Errorf("GetURLs = %v", urls.Urls)
+		}
+	})
+}
+
+func TestConformanceFindByOriginalURL(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "f1", OriginalUrl: "https://evil.example/a"})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "f2", OriginalUrl: "https://cdn.evil.example/b", MaxClicks: 3})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "f3", OriginalUrl: "https://evil.example/a"})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "f4", OriginalUrl: "https://notevil.example/a"})
+
+		exact, err := s.FindByOriginalURL(ctx, &proto.FindByOriginalURLRequest{OriginalUrl: "https://evil.example/a", Limit: 10})
+		if err != nil || !slices.Equal(exact.ShortCodes, []string{"f1", "f3"}) {
+			t.Errorf("exact match = %v, %v, want f1 and f3", exact, err)
+		}
+		host, err := s.FindByOriginalURL(ctx, &proto.FindByOriginalURLRequest{OriginalUrl: "evil.example", Match: "host", Limit: 10})
+		if err != nil || !slices.Equal(slices.Sorted(slices.Values(host.ShortCodes)), []string{"f1", "f2", "f3"}) {
+			t.Errorf("host match = %v, %v, want f1, f2 and f3", host, err)
+		}
+		unlimited, err := s.FindByOriginalURL(ctx, &proto.FindByOriginalURLRequest{OriginalUrl: "evil.example", Match: "host", UnlimitedOnly: true, Limit: 10})
+		if err != nil || slices.Contains(unlimited.ShortCodes, "f2") {
+			t.Errorf("unlimited_only = %v, %v, want f2 left out", unlimited, err)
+		}
+
+		// Paging returns every code once
+		var paged []string
+		token := ""
+		for {
+			page, err := s.FindByOriginalURL(ctx, &proto.FindByOriginalURLRequest{OriginalUrl: "evil.example", Match: "host", Limit: 1, PageToken: token})
+			if err != nil {
+				t.Fatalf("FindByOriginalURL page: %v", err)
+			}
+			paged = append(paged, page.ShortCodes...)
+			if token = page.NextPageToken; token == "" {
+				break
+			}
+		}
+		if !slices.Equal(slices.Sorted(slices.Values(paged)), []string{"f1", "f2", "f3"}) {
+			t.Errorf("pages = %v, want f1, f2 and f3", paged)
+		}
+	})
+}
+
+func TestConformanceFindByLongOriginalURL(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		// Too long for a btree entry, so only its md5 is indexed
+		long := "https://example.com/?q=" + strings.Repeat("a", 10000)
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "long1", OriginalUrl: long})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "long2", OriginalUrl: long})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "longer", OriginalUrl: long + "a"})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "expired", OriginalUrl: long, ExpiresAt: rfc3339(time.Now().Add(-time.Minute))})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "deleted", OriginalUrl: long})
+		if _, err := s.DeleteURL(ctx, &proto.DeleteURLRequest{ShortCode: "deleted"}); err != nil {
+			t.Fatalf("DeleteURL: %v", err)
+		}
+
+		resp, err := s.FindByOriginalURL(ctx, &proto.FindByOriginalURLRequest{OriginalUrl: long, Match: "exact", Limit: 10})
+		if err != nil || !slices.Equal(slices.Sorted(slices.Values(resp.ShortCodes)), []string{"long1", "long2"}) {
+			t.Errorf("long exact match = %v, %v, want long1 and long2", resp, err)
+		}
+		if resp, err := s.FindByOriginalURL(ctx, &proto.FindByOriginalURLRequest{OriginalUrl: "https://example.com/other"}); err != nil || len(resp.ShortCodes) != 0 {
+			t.Errorf("unknown URL = %v, %v, want no codes", resp, err)
+		}
+
+		for _, req := range []*proto.FindByOriginalURLRequest{{}, {OriginalUrl: long, Match: "prefix"}} {
+			if _, err := s.FindByOriginalURL(ctx, req); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("FindByOriginalURL(%q, %q): got %v, want InvalidArgument", req.OriginalUrl, req.Match, err)
+			}
+		}
+	})
+}
+
+func TestFindByOriginalURLUsesIndex(t *testing.T) {
+	s := newTestServer(t)
+	rows, err := s.db.Query(`EXPLAIN QUERY PLAN SELECT short_code FROM urls WHERE md5(original_url) = md5('https://example.com') AND original_url = 'https://example.com'`)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			t.Fatalf("scan plan: %v", err)
+		}
+		plan = append(plan, detail)
+	}
+	if !strings.Contains(strings.Join(plan, "\n"), "idx_urls_original_url_md5") {
+		t.Errorf("plan %q doesn't use idx_urls_original_url_md5", plan)
+	}
+}
+
+func TestConformanceListAndCountURLs(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		for i := 1; i <= 5; i++ {
+			req := &proto.SaveURLRequest{ShortCode: fmt.Sprintf("l%d", i), OriginalUrl: fmt.Sprintf("https://example.com/%d", i), UserId: "alice"}
+			if i%2 == 1 {
+				req.Tags, req.SetTags = []string{"odd", "all"}, true
+			} else {
+				req.Tags, req.SetTags = []string{"all"}, true
+			}
+			saveURL(t, s, req)
+			time.Sleep(2 * time.Millisecond) // Distinct creation times
+		}
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "bob1", OriginalUrl: "https://example.com/bob", UserId: "bob"})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "l6", OriginalUrl: "https://example.com/6", UserId: "alice", ExpiresAt: rfc3339(time.Now().Add(-time.Minute))})
+
+		var listed []string
+		token := ""
+		for {
+			page, err := s.ListURLs(ctx, &proto.ListURLsRequest{UserId: "alice", PageSize: 2, PageToken: token})
+			if err != nil {
+				t.Fatalf("ListURLs: %v", err)
+			}
+			for _, u := range page.Urls {
+				listed = append(listed, u.ShortCode)
+			}
+			if token = page.NextPageToken; token == "" {
+				break
+			}
+		}
+		if !slices.Equal(listed, []string{"l6", "l5", "l4", "l3", "l2", "l1"}) {
+			t.Errorf("ListURLs = %v, want newest first", listed)
+		}
+
+		odd, err := s.ListURLs(ctx, &proto.ListURLsRequest{UserId: "alice", PageSize: 10, Tags: []string{"odd", "all"}})
+		if err != nil {
+			t.Fatalf("ListURLs by tags: %v", err)
+		}
+		var oddCodes []string
+		for _, u := range odd.Urls {
+			oddCodes = append(oddCodes, u.ShortCode)
+			if !slices.Equal(u.Tags, []string{"all", "odd"}) {
+				t.Errorf("%s has tags %v, want all and odd", u.ShortCode, u.Tags)
+			}
+		}
+		if !slices.Equal(oddCodes, []string{"l5", "l3", "l1"}) {
+			t.Errorf("ListURLs by tags = %v, want l5, l3 and l1", oddCodes)
+		}
+
+		tags, err := s.ListTags(ctx, &proto.ListTagsRequest{UserId: "alice"})
+		if err != nil || len(tags.Tags) != 2 || tags.Tags[0].Tag != "all" || tags.Tags[0].Urls != 5 || tags.Tags[1].Tag != "odd" || tags.Tags[1].Urls != 3 {
+			t.Errorf("ListTags = %v, %v, want all on 5 and odd on 3", tags, err)
+		}
+
+		count, err := s.CountURLs(ctx, &proto.CountURLsRequest{UserId: "alice"})
+		if err != nil || count.Active != 5 {
+			t.Errorf("CountURLs = %v, %v, want 5 unexpired", count, err)
+		}
+	})
+}
+
+func TestConformanceListURLsPageBoundaries(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		for i := 1; i <= 4; i++ {
+			saveURL(t, s, &proto.SaveURLRequest{ShortCode: fmt.Sprintf("p%d", i), OriginalUrl: "https://example.com", UserId: "alice"})
+			time.Sleep(2 * time.Millisecond) // Distinct creation times
+		}
+
+		// A last page that is exactly full has no token to an empty one
+		for _, pageSize := range []int32{2, 4} {
+			page, err := s.ListURLs(ctx, &proto.ListURLsRequest{UserId: "alice", PageSize: pageSize})
+			if err != nil {
+				t.Fatalf("ListURLs with page size %d: %v", pageSize, err)
+			}
+			pages := 1
+			for page.NextPageToken != "" {
+				if page, err = s.ListURLs(ctx, &proto.ListURLsRequest{UserId: "alice", PageSize: pageSize, PageToken: page.NextPageToken}); err != nil {
+					t.Fatalf("ListURLs with page size %d: %v", pageSize, err)
+				}
+				pages++
+				if len(page.Urls) == 0 {
+					t.Errorf("page size %d: empty page %d", pageSize, pages)
+				}
+			}
+			if want := 4 / int(pageSize); pages != want {
+				t.Errorf("page size %d: %d pages, want %d", pageSize, pages, want)
+			}
+		}
+
+		// A link created between pages doesn't shift the next one
+		first, err := s.ListURLs(ctx, &proto.ListURLsRequest{UserId: "alice", PageSize: 3})
+		if err != nil {
+			t.Fatalf("ListURLs: %v", err)
+		}
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "p5", OriginalUrl: "https://example.com", UserId: "alice"})
+		second, err := s.ListURLs(ctx, &proto.ListURLsRequest{UserId: "alice", PageSize: 3, PageToken: first.NextPageToken})
+		if err != nil || len(second.Urls) != 1 || second.Urls[0].ShortCode != "p1" || second.NextPageToken != "" {
+			t.Errorf("second page = %v, %v, want only p1", second, err)
+		}
+
+		for _, tt := range []struct {
+			name string
+			req  *proto.ListURLsRequest
+		}{
+			{"no user", &proto.ListURLsRequest{}},
+			{"garbled token", &proto.ListURLsRequest{UserId: "alice", PageToken: "not-a-token"}},
+		} {
+			if _, err := s.ListURLs(ctx, tt.req); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("%s: got %v, want InvalidArgument", tt.name, err)
+			}
+		}
+		if page, err := s.ListURLs(ctx, &proto.ListURLsRequest{UserId: "bob"}); err != nil || len(page.Urls) != 0 {
+			t.Errorf("ListURLs for a user without links = %v, %v, want none", page, err)
+		}
+	})
+}
+
+func TestConformanceTags(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		tagged := map[string][]string{
+			"t1": {"launch", "q3", "social"},
+			"t2": {"launch", "social"},
+			"t3": {"launch", "q3", "social"},
+			"t4": {"q3"},
+			"t5": {"launch", "q3", "social"},
+			"t6": {"launch", "q3", "social"},
+		}
+		for i := 1; i <= 6; i++ {
+			code := fmt.Sprintf("t%d", i)
+			saveURL(t, s, &proto.SaveURLRequest{ShortCode: code, OriginalUrl: "https://example.com/" + code, UserId: "alice", Tags: tagged[code], SetTags: true})
+			time.Sleep(2 * time.Millisecond) // Distinct creation times
+		}
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "bob1", OriginalUrl: "https://example.com/bob", UserId: "bob", Tags: []string{"launch", "q3", "social"}, SetTags: true})
+
+		// listed pages through alice's links with all the tags
+		listed := func(tags ...string) []string {
+			var codes []string
+			token := ""
+			for {
+				page, err := s.ListURLs(ctx, &proto.ListURLsRequest{UserId: "alice", PageSize: 2, PageToken: token, Tags: tags})
+				if err != nil {
+					t.Fatalf("ListURLs by %v: %v", tags, err)
+				}
+				if len(page.Urls) > 2 {
+					t.Errorf("ListURLs by %v: page of %d", tags, len(page.Urls))
+				}
+				for _, u := range page.Urls {
+					codes = append(codes, u.ShortCode)
+				}
+				if token = page.NextPageToken; token == "" {
+					return codes
+				}
+			}
+		}
+
+		tests := []struct {
+			name string
+			tags []string
+			want []string
+		}{
+			{"one tag", []string{"q3"}, []string{"t6", "t5", "t4", "t3", "t1"}},
+			{"all of two tags", []string{"launch", "q3"}, []string{"t6", "t5", "t3", "t1"}},
+			{"all of three tags", []string{"social", "q3", "launch"}, []string{"t6", "t5", "t3", "t1"}},
+			{"unused tag", []string{"launch", "nope"}, nil},
+		}
+		for _, tt := range tests {
+			if got := listed(tt.tags...); !slices.Equal(got, tt.want) {
+				t.Errorf("%s: ListURLs = %v, want %v", tt.name, got, tt.want)
+			}
+		}
+
+		// Saving without set_tags keeps them, with set_tags replaces them
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "t6", OriginalUrl: "https://example.com/moved", UserId: "alice"})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "t5", OriginalUrl: "https://example.com/t5", UserId: "alice", Tags: []string{"archive"}, SetTags: true})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "t4", OriginalUrl: "https://example.com/t4", UserId: "alice", SetTags: true})
+		if got := listed("q3"); !slices.Equal(got, []string{"t6", "t3", "t1"}) {
+			t.Errorf("after retagging ListURLs by q3 = %v, want t6, t3 and t1", got)
+		}
+
+		// Deleted links leave the counts
+		if _, err := s.DeleteURL(ctx, &proto.DeleteURLRequest{ShortCode: "t1"}); err != nil {
+			t.Fatalf("DeleteURL: %v", err)
+		}
+		resp, err := s.ListTags(ctx, &proto.ListTagsRequest{UserId: "alice"})
+		if err != nil {
+			t.Fatalf("ListTags: %v", err)
+		}
+		var counts []string
+		for _, tag := range resp.Tags {
+			counts = append(counts, fmt.Sprintf("%s:%d", tag.Tag, tag.Urls))
+		}
+		if want := []string{"launch:3", "social:3", "q3:2", "archive:1"}; !slices.Equal(counts, want) {
+			t.Errorf("ListTags = %v, want %v", counts, want)
+		}
+
+		for _, tt := range []struct {
+			name string
+			tags []string
+		}{
+			{"empty tag", []string{""}},
+			{"long tag", []string{strings.Repeat("x", maxTagLength+1)}},
+			{"duplicate tag", []string{"a", "a"}},
+			{"too many tags", strings.Split("a b c d e f g h i j k", " ")},
+		} {
+			if _, err := s.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "bad", OriginalUrl: "https://example.com", Tags: tt.tags, SetTags: true}); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("SaveURL with %s: got %v, want InvalidArgument", tt.name, err)
+			}
+			if _, err := s.ListURLs(ctx, &proto.ListURLsRequest{UserId: "alice", Tags: tt.tags}); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("ListURLs by %s: got %v, want InvalidArgument", tt.name, err)
+			}
+		}
+		if _, err := s.ListTags(ctx, &proto.ListTagsRequest{}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ListTags without a user: got %v, want InvalidArgument", err)
+		}
+	})
+}
+
+func TestListURLsByTagUsesIndex(t *testing.T) {
+	s := newTestServer(t)
+	rows, err := s.db.Query(`EXPLAIN QUERY PLAN SELECT short_code FROM url_tags WHERE `+s.db.dialect.anyOf("tag", "?")+` GROUP BY short_code HAVING COUNT(*) = 2`,
+		s.db.dialect.array([]string{"launch", "q3"}))
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			t.Fatalf("scan plan: %v", err)
+		}
+		plan = append(plan, detail)
+	}
+	if !strings.Contains(strings.Join(plan, "\n"), "idx_url_tags_tag") {
+		t.Errorf("plan %q doesn't use idx_url_tags_tag", plan)
+	}
+}
+
+func TestConformanceClaimClick(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "twice", OriginalUrl: "https://example.com", MaxClicks: 2, FallbackUrl: "https://example.com/over"})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "free", OriginalUrl: "https://example.com"})
+
+		for want := int64(1); want >= 0; want-- {
+			resp, err := s.ClaimClick(ctx, &proto.ClaimClickRequest{ShortCode: "twice"})
+			if err != nil || !resp.Claimed || resp.Remaining != want {
+				t.Errorf("ClaimClick = %v, %v, want claimed with %d remaining", resp, err, want)
+			}
+		}
+		resp, err := s.ClaimClick(ctx, &proto.ClaimClickRequest{ShortCode: "twice"})
+		if err != nil || resp.Claimed || resp.FallbackUrl != "https://example.com/over" {
+			t.Errorf("ClaimClick past the limit = %v, %v, want the fallback", resp, err)
+		}
+		if peek, err := s.ClaimClick(ctx, &proto.ClaimClickRequest{ShortCode: "twice", Peek: true}); err != nil || peek.Claimed {
+			t.Errorf("peek past the limit = %v, %v", peek, err)
+		}
+		if free, err := s.ClaimClick(ctx, &proto.ClaimClickRequest{ShortCode: "free"}); err != nil || !free.Claimed || free.Remaining != 0 {
+			t.Errorf("ClaimClick without a limit = %v, %v", free, err)
+		}
+	})
+}
+
+func TestConformanceStatusAndHistory(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "h1", OriginalUrl: "https://example.com/a", UserId: "alice"})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "h1", OriginalUrl: "https://example.com/b", ExpectedOriginalUrl: "https://example.com/a"})
+
+		if resp, err := s.SetURLStatus(ctx, &proto.SetURLStatusRequest{ShortCode: "h1", Active: false}); err != nil || resp.Active {
+			t.Fatalf("SetURLStatus = %v, %v", resp, err)
+		}
+		if resp, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "h1"}); err != nil || !resp.Disabled {
+			t.Errorf("GetURL of a disabled URL = %v, %v", resp, err)
+		}
+		if list, err := s.ListURLs(ctx, &proto.ListURLsRequest{UserId: "alice", PageSize: 10}); err != nil || len(list.Urls) != 1 || !list.Urls[0].Disabled {
+			t.Errorf("ListURLs of a disabled URL = %v, %v", list, err)
+		}
+		if _, err := s.SetURLStatus(ctx, &proto.SetURLStatusRequest{ShortCode: "h1", Active: true}); err != nil {
+			t.Fatalf("SetURLStatus: %v", err)
+		}
+		if resp, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "h1"}); err != nil || resp.Disabled {
+			t.Errorf("GetURL of a reactivated URL = %v, %v", resp, err)
+		}
+		if _, err := s.SetURLStatus(ctx, &proto.SetURLStatusRequest{ShortCode: "missing"}); status.Code(err) != codes.NotFound {
+			t.Errorf("SetURLStatus of a missing code: got %v, want NotFound", err)
+		}
+
+		history, err := s.GetURLHistory(ctx, &proto.GetURLHistoryRequest{ShortCode: "h1", PageSize: 10})
+		if err != nil {
+			t.Fatalf("GetURLHistory: %v", err)
+		}
+		var actions []string
+		for _, e := range history.Entries {
+			actions = append(actions, e.Action)
+		}
+		if !slices.Equal(actions, []string{"enable", "disable", "update"}) {
+			t.Errorf("history = %v, want enable, disable and update, newest first", actions)
+		}
+		if update := history.Entries[len(history.Entries)-1]; update.OldUrl != "https://example.com/a" || update.NewUrl != "https://example.com/b" {
+			t.Errorf("update entry = %v", update)
+		}
+	})
+}
+
+func TestConformanceAllocateIDRange(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		first, err := s.AllocateIDRange(ctx, &proto.AllocateIDRangeRequest{Count: 100})
+		if err != nil {
+			t.Fatalf("AllocateIDRange: %v", err)
+		}
+		second, err := s.AllocateIDRange(ctx, &proto.AllocateIDRangeRequest{Count: 50})
+		if err != nil {
+			t.Fatalf("AllocateIDRange: %v", err)
+		}
+		if first.End-first.Start != 100 || second.End-second.Start != 50 || second.Start < first.End {
+			t.Errorf("ranges [%d, %d) and [%d, %d) overlap or have the wrong size", first.Start, first.End, second.Start, second.End)
+		}
+		if _, err := s.AllocateIDRange(ctx, &proto.AllocateIDRangeRequest{Count: 0}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("AllocateIDRange of 0: got %v, want InvalidArgument", err)
+		}
+	})
+}
+
+func TestConformanceAllocateIDRangeConcurrent(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		var mu sync.Mutex
+		var ranges []*proto.AllocateIDRangeResponse
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := s.AllocateIDRange(context.Background(), &proto.AllocateIDRangeRequest{Count: 100})
+				if err != nil {
+					t.Errorf("AllocateIDRange: %v", err)
+					return
+				}
+				mu.Lock()
+				ranges = append(ranges, resp)
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		// Sorted, each block must end where the next starts or before
+		slices.SortFunc(ranges, func(a, b *proto.AllocateIDRangeResponse) int { return cmp.Compare(a.Start, b.Start) })
+		for i, r := range ranges {
+			if r.End-r.Start != 100 {
+				t.Errorf("range [%d, %d) has %d IDs, want 100", r.Start, r.End, r.End-r.Start)
+			}
+			if i > 0 && r.Start < ranges[i-1].End {
+				t.Errorf("ranges [%d, %d) and [%d, %d) overlap", ranges[i-1].Start, ranges[i-1].End, r.Start, r.End)
+			}
+		}
+	})
+}
+
+func TestConformanceClickEvents(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "ev", OriginalUrl: "https://example.com"})
+		day := time.Now().UTC().Truncate(24 * time.Hour).Add(-48 * time.Hour)
+
+		resp, err := s.RecordClick(ctx, &proto.RecordClickRequest{Events: []*proto.ClickEvent{
+			{ShortCode: "ev", ClickedAt: rfc3339(day.Add(time.Hour)), Referrer: "https://news.example/item", Country: "DE"},
+			{ShortCode: "ev", ClickedAt: rfc3339(day.Add(2 * time.Hour)), Referrer: "https://news.example/other", Country: "DE"},
+			{ShortCode: "ev", ClickedAt: rfc3339(day.Add(25 * time.Hour)), Country: "FR"},
+			{ShortCode: "ev", ClickedAt: rfc3339(day.Add(26 * time.Hour)), Bot: true},
+			{ShortCode: "unknown", ClickedAt: rfc3339(day)},
+		}})
+		if err != nil || resp.Recorded != 4 {
+			t.Fatalf("RecordClick = %v, %v, want 4 recorded", resp, err)
+		}
+
+		series, err := s.GetClickTimeSeries(ctx, &proto.GetClickTimeSeriesRequest{ShortCode: "ev", Start: rfc3339(day), End: rfc3339(day.Add(72 * time.Hour)), Bucket: "day"})
+		if err != nil {
+			t.Fatalf("GetClickTimeSeries: %v", err)
+		}
+		var clicks []int64
+		for _, b := range series.Buckets {
+			clicks = append(clicks, b.Clicks)
+		}
+		if !slices.Equal(clicks, []int64{2, 1, 0}) || series.TotalClicks != 3 {
+			t.Errorf("daily clicks = %v, total %d, want [2 1 0] and 3 without the bot", clicks, series.TotalClicks)
+		}
+		series, err = s.GetClickTimeSeries(ctx, &proto.GetClickTimeSeriesRequest{ShortCode: "ev", Start: rfc3339(day), End: rfc3339(day.Add(72 * time.Hour)), Bucket: "day", IncludeBots: true})
+		if err != nil {
+			t.Fatalf("GetClickTimeSeries with bots: %v", err)
+		}
+		clicks = clicks[:0]
+		for _, b := range series.Buckets {
+			clicks = append(clicks, b.Clicks)
+		}
+		if !slices.Equal(clicks, []int64{2, 2, 0}) || series.TotalClicks != 4 {
+			t.Errorf("daily clicks with bots = %v, total %d, want [2 2 0] and 4", clicks, series.TotalClicks)
+		}
+
+		breakdown, err := s.GetClickBreakdown(ctx, &proto.GetClickBreakdownRequest{ShortCode: "ev"})
+		if err != nil {
+			t.Fatalf("GetClickBreakdown: %v", err)
+		}
+		if len(breakdown.Referrers) != 1 || breakdown.Referrers[0].Value != "news.example" || breakdown.Referrers[0].Clicks != 2 {
+			t.Errorf("referrers = %v, want news.example twice", breakdown.Referrers)
+		}
+		if len(breakdown.Countries) != 2 || breakdown.Countries[0].Value != "DE" || breakdown.Countries[0].Clicks != 2 {
+			t.Errorf("countries = %v, want DE twice first", breakdown.Countries)
+		}
+	})
+}
+
+func TestConformanceVariants(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		variants := []*proto.Variant{
+			{Name: "control", Url: "https://example.com/a", Weight: 70},
+			{Name: "new", Url: "https://example.com/b", Weight: 30},
+		}
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "split", OriginalUrl: "https://example.com/a", Variants: variants, StickyVariants: true})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "plain", OriginalUrl: "https://example.com", StickyVariants: true})
+
+		// Variants come back in order, from both lookups
+		resp, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "split"})
+		if err != nil {
+			t.Fatalf("GetURL: %v", err)
+		}
+		batch, err := s.GetURLs(ctx, &proto.GetURLsRequest{ShortCodes: []string{"split", "plain"}})
+		if err != nil {
+			t.Fatalf("GetURLs: %v", err)
+		}
+		for name, got := range map[string]*proto.GetURLResponse{"GetURL": resp, "GetURLs": batch.Urls["split"]} {
+			if !got.StickyVariants || len(got.Variants) != 2 || got.Variants[0].Name != "control" || got.Variants[0].Weight != 70 || got.Variants[1].Url != "https://example.com/b" {
+				t.Errorf("%s: sticky %v, variants %v", name, got.StickyVariants, got.Variants)
+			}
+		}
+		// Sticky means nothing without variants
+		if plain := batch.Urls["plain"]; len(plain.Variants) != 0 || plain.StickyVariants {
+			t.Errorf("plain link has sticky %v, variants %v", plain.StickyVariants, plain.Variants)
+		}
+
+		// Saving the link again replaces its variants, and none makes it plain
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "split", OriginalUrl: "https://example.com/c"})
+		if resp, _ := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "split"}); len(resp.Variants) != 0 || resp.StickyVariants {
+			t.Errorf("after a plain save: sticky %v, variants %v", resp.StickyVariants, resp.Variants)
+		}
+
+		for _, bad := range [][]*proto.Variant{
+			{{Name: "a", Url: "https://example.com/a", Weight: 1}, {Name: "a", Url: "https://example.com/b", Weight: 1}},
+			{{Name: "a", Url: "https://example.com/a", Weight: 0}},
+			{{Url: "https://example.com/a", Weight: 1}},
+		} {
+			if _, err := s.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "bad", OriginalUrl: "https://example.com", Variants: bad}); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("SaveURL with variants %v: got %v, want InvalidArgument", bad, err)
+			}
+		}
+
+		// Clicks are broken out per variant
+		now := time.Now().UTC()
+		if _, err := s.RecordClick(ctx, &proto.RecordClickRequest{Events: []*proto.ClickEvent{
+			{ShortCode: "split", ClickedAt: rfc3339(now), Variant: "control"},
+			{ShortCode: "split", ClickedAt: rfc3339(now), Variant: "control"},
+			{ShortCode: "split", ClickedAt: rfc3339(now), Variant: "new"},
+		}}); err != nil {
+			t.Fatalf("RecordClick: %v", err)
+		}
+		breakdown, err := s.GetClickBreakdown(ctx, &proto.GetClickBreakdownRequest{ShortCode: "split"})
+		if err != nil {
+			t.Fatalf("GetClickBreakdown: %v", err)
+		}
+		if len(breakdown.Variants) != 2 || breakdown.Variants[0].Value != "control" || breakdown.Variants[0].Clicks != 2 || breakdown.Variants[1].Clicks != 1 {
+			t.Errorf("variants = %v, want control twice and new once", breakdown.Variants)
+		}
+	})
+}
+
+func TestConformanceRedirectRules(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		rules := []*proto.RedirectRule{
+			{Device: "ios", Url: "https://apps.apple.com/app"},
+			{Device: "mobile", Countries: []string{"DE", "AT"}, Url: "https://example.de/mobile"},
+			{Countries: []string{"DE"}, Url: "https://example.de"},
+		}
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "app", OriginalUrl: "https://example.com", Rules: rules})
+
+		resp, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "app"})
+		if err != nil {
+			t.Fatalf("GetURL: %v", err)
+		}
+		batch, err := s.GetURLs(ctx, &proto.GetURLsRequest{ShortCodes: []string{"app"}})
+		if err != nil {
+			t.Fatalf("GetURLs: %v", err)
+		}
+		for name, got := range map[string]*proto.GetURLResponse{"GetURL": resp, "GetURLs": batch.Urls["app"]} {
+			if len(got.Rules) != 3 {
+				t.Fatalf("%s: rules %v, want 3", name, got.Rules)
+			}
+			for i, r := range got.Rules {
+				if r.Device != rules[i].Device || r.Url != rules[i].Url || !slices.Equal(r.Countries, rules[i].Countries) {
+					t.Errorf("%s: rule %d = %v, want %v", name, i+1, r, rules[i])
+				}
+			}
+		}
+
+		// Saving the link again replaces its rules
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "app", OriginalUrl: "https://example.com", Rules: rules[2:]})
+		if resp, _ := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "app"}); len(resp.Rules) != 1 || resp.Rules[0].Url != "https://example.de" {
+			t.Errorf("after replacing: rules %v", resp.Rules)
+		}
+
+		tooMany := make([]*proto.RedirectRule, maxRedirectRules+1)
+		for i := range tooMany {
+			tooMany[i] = &proto.RedirectRule{Device: "ios", Url: "https://example.com"}
+		}
+		for _, bad := range [][]*proto.RedirectRule{
+			{{Device: "ios"}},
+			{{Countries: []string{"DE,AT"}, Url: "https://example.com"}},
+			tooMany,
+		} {
+			if _, err := s.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "bad", OriginalUrl: "https://example.com", Rules: bad}); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("SaveURL with %d rules: got %v, want InvalidArgument", len(bad), err)
+			}
+		}
+
+		// Clicks are broken out by the rule they matched
+		now := time.Now().UTC()
+		if _, err := s.RecordClick(ctx, &proto.RecordClickRequest{Events: []*proto.ClickEvent{
+			{ShortCode: "app", ClickedAt: rfc3339(now), Rule: "1"},
+			{ShortCode: "app", ClickedAt: rfc3339(now), Rule: "default"},
+			{ShortCode: "app", ClickedAt: rfc3339(now), Rule: "default"},
+		}}); err != nil {
+			t.Fatalf("RecordClick: %v", err)
+		}
+		breakdown, err := s.GetClickBreakdown(ctx, &proto.GetClickBreakdownRequest{ShortCode: "app"})
+		if err != nil {
+			t.Fatalf("GetClickBreakdown: %v", err)
+		}
+		if len(breakdown.Rules) != 2 || breakdown.Rules[0].Value != "default" || breakdown.Rules[0].Clicks != 2 || breakdown.Rules[1].Value != "1" {
+			t.Errorf("rules = %v, want default twice and 1 once", breakdown.Rules)
+		}
+	})
+}
+
+func TestConformanceQueryTemplate(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		const destination = "https://example.com/sale?ref=home"
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "utm", OriginalUrl: destination, QueryTemplate: "utm_campaign={code}"})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "ab", OriginalUrl: destination, Variants: []*proto.Variant{
+			{Name: "a", Url: destination, Weight: 1}, {Name: "b", Url: "https://example.com/other", Weight: 1},
+		}})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "app", OriginalUrl: destination, Rules: []*proto.RedirectRule{{Device: "ios", Url: "https://apps.apple.com/app"}}})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "plain", OriginalUrl: destination})
+
+		// The template is kept apart from the destination
+		resp, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "utm"})
+		if err != nil || resp.OriginalUrl != destination || resp.QueryTemplate != "utm_campaign={code}" {
+			t.Errorf("GetURL = %v, %v, want the clean destination and its template", resp, err)
+		}
+
+		// Only plain links are candidates for reuse
+		all, err := s.FindByOriginalURL(ctx, &proto.FindByOriginalURLRequest{OriginalUrl: destination, Limit: 10})
+		if err != nil || len(all.ShortCodes) != 4 {
+			t.Errorf("every link = %v, %v, want 4", all, err)
+		}
+		plain, err := s.FindByOriginalURL(ctx, &proto.FindByOriginalURLRequest{OriginalUrl: destination, PlainOnly: true, Limit: 10})
+		if err != nil || !slices.Equal(plain.ShortCodes, []string{"plain"}) {
+			t.Errorf("plain_only = %v, %v, want only plain", plain, err)
+		}
+
+		// Saving without a template removes it
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "utm", OriginalUrl: destination})
+		if resp, _ := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "utm"}); resp.QueryTemplate != "" {
+			t.Errorf("template %q left after a save without one", resp.QueryTemplate)
+		}
+	})
+}
+
+func TestConformanceClickTimeSeriesDST(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "dst", OriginalUrl: "https://example.com"})
+		var events []*proto.ClickEvent
+		for _, at := range []string{
+			"2025-03-09T04:30:00Z", // 23:30 EST on March 8
+			"2025-03-10T03:30:00Z", // 23:30 EDT on March 9, a 23 hour day
+			"2025-03-10T04:30:00Z", // 00:30 EDT on March 10
+			"2025-11-02T05:30:00Z", // 01:30 EDT
+			"2025-11-02T06:30:00Z", // 01:30 EST, the same wall clock hour again
+		} {
+			events = append(events, &proto.ClickEvent{ShortCode: "dst", ClickedAt: at})
+		}
+		if resp, err := s.RecordClick(ctx, &proto.RecordClickRequest{Events: events}); err != nil || resp.Recorded != 5 {
+			t.Fatalf("RecordClick = %v, %v, want 5 recorded", resp, err)
+		}
+
+		type bucket struct {
+			start  string
+			clicks int64
+		}
+		tests := []struct {
+			name       string
+			start, end string
+			bucket     string
+			timeZone   string
+			want       []bucket
+		}{
+			{"days around spring forward", "2025-03-08T05:00:00Z", "2025-03-11T04:00:00Z", "day", "America/New_York", []bucket{
+				{"2025-03-08T05:00:00Z", 1}, {"2025-03-09T05:00:00Z", 1}, {"2025-03-10T04:00:00Z", 1},
+			}},
+			{"same range in UTC", "2025-03-08T05:00:00Z", "2025-03-11T04:00:00Z", "day", "", []bucket{
+				{"2025-03-08T00:00:00Z", 0}, {"2025-03-09T00:00:00Z", 1}, {"2025-03-10T00:00:00Z", 2}, {"2025-03-11T00:00:00Z", 0},
+			}},
+			{"hours around fall back", "2025-11-02T04:00:00Z", "2025-11-02T08:00:00Z", "hour", "America/New_York", []bucket{
+				{"2025-11-02T04:00:00Z", 0}, {"2025-11-02T05:00:00Z", 1}, {"2025-11-02T06:00:00Z", 1}, {"2025-11-02T07:00:00Z", 0},
+			}},
+			{"half hour zone", "2025-11-01T18:30:00Z", "2025-11-02T18:30:00Z", "day", "Asia/Kolkata", []bucket{
+				{"2025-11-01T18:30:00Z", 2},
+			}},
+		}
+		for _, tt := range tests {
+			series, err := s.GetClickTimeSeries(ctx, &proto.GetClickTimeSeriesRequest{ShortCode: "dst", Start: tt.start, End: tt.end, Bucket: tt.bucket, TimeZone: tt.timeZone})
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			var got []bucket
+			for _, b := range series.Buckets {
+				got = append(got, bucket{b.Start, b.Clicks})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("%s: buckets %v, want %v", tt.name, got, tt.want)
+			}
+		}
+
+		if _, err := s.GetClickTimeSeries(ctx, &proto.GetClickTimeSeriesRequest{ShortCode: "dst", Start: "2025-03-08T00:00:00Z", End: "2025-03-09T00:00:00Z", TimeZone: "Mars/Olympus"}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("unknown time zone: got %v, want InvalidArgument", err)
+		}
+	})
+}
+
+func TestConformanceTopAndGlobalStats(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		for i, clicks := range []int64{3, 10, 1} {
+			code := fmt.Sprintf("t%d", i)
+			saveURL(t, s, &proto.SaveURLRequest{ShortCode: code, OriginalUrl: "https://example.com/" + code})
+			if _, err := s.BatchIncrementClicks(ctx, &proto.BatchIncrementClicksRequest{Deltas: []*proto.ClickDelta{{ShortCode: code, Delta: clicks}}}); err != nil {
+				t.Fatalf("BatchIncrementClicks: %v", err)
+			}
+		}
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "gone", OriginalUrl: "https://example.com/gone"})
+		if _, err := s.DeleteURL(ctx, &proto.DeleteURLRequest{ShortCode: "gone"}); err != nil {
+			t.Fatalf("DeleteURL: %v", err)
+		}
+
+		top, err := s.GetTopURLs(ctx, &proto.GetTopURLsRequest{Limit: 2})
+		if err != nil {
+			t.Fatalf("GetTopURLs: %v", err)
+		}
+		if len(top.Urls) != 2 || top.Urls[0].ShortCode != "t1" || top.Urls[0].ClickCount != 10 || top.Urls[1].ShortCode != "t0" {
+			t.Errorf("GetTopURLs = %v, want t1 then t0", top.Urls)
+		}
+
+		global, err := s.GetGlobalStats(ctx, &proto.GetGlobalStatsRequest{})
+		if err != nil {
+			t.Fatalf("GetGlobalStats: %v", err)
+		}
+		if global.TotalUrls != 4 || global.TotalClicks != 14 || global.ActiveUrls != 3 || global.CreatedLastDay != 4 {
+			t.Errorf("GetGlobalStats = %v, want 4 URLs, 14 clicks, 3 active and 4 created today", global)
+		}
+	})
+}
+
+func TestConformanceTopURLsTiesAndSince(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		now := time.Now().UTC()
+		for _, code := range []string{"tie-b", "tie-a", "old", "expired"} {
+			saveURL(t, s, &proto.SaveURLRequest{ShortCode: code, OriginalUrl: "https://example.com/" + code})
+		}
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "expired", OriginalUrl: "https://example.com/expired", ExpiresAt: rfc3339(now.Add(-time.Minute))})
+
+		// old has the most clicks overall, but all of them a week ago
+		var events []*proto.ClickEvent
+		click := func(code string, n int, at time.Time) {
+			for i := 0; i < n; i++ {
+				events = append(events, &proto.ClickEvent{ShortCode: code, ClickedAt: rfc3339(at)})
+			}
+		}
+		click("old", 5, now.Add(-7*24*time.Hour))
+		click("tie-a", 2, now.Add(-time.Hour))
+		click("tie-b", 2, now.Add(-time.Hour))
+		click("expired", 9, now.Add(-time.Hour))
+		if _, err := s.RecordClick(ctx, &proto.RecordClickRequest{Events: events}); err != nil {
+			t.Fatalf("RecordClick: %v", err)
+		}
+		var deltas []*proto.ClickDelta
+		for code, n := range map[string]int64{"old": 5, "tie-a": 2, "tie-b": 2, "expired": 9} {
+			deltas = append(deltas, &proto.ClickDelta{ShortCode: code, Delta: n})
+		}
+		if _, err := s.BatchIncrementClicks(ctx, &proto.BatchIncrementClicksRequest{Deltas: deltas}); err != nil {
+			t.Fatalf("BatchIncrementClicks: %v", err)
+		}
+
+		tests := []struct {
+			name string
+			req  *proto.GetTopURLsRequest
+			want []string
+		}{
+			{"all time", &proto.GetTopURLsRequest{Limit: 10}, []string{"old", "tie-a", "tie-b"}},
+			{"limited", &proto.GetTopURLsRequest{Limit: 2}, []string{"old", "tie-a"}},
+			{"since yesterday", &proto.GetTopURLsRequest{Limit: 10, Since: rfc3339(now.Add(-24 * time.Hour))}, []string{"tie-a", "tie-b"}},
+		}
+		for _, tt := range tests {
+			resp, err := s.GetTopURLs(ctx, tt.req)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			var got []string
+			for _, u := range resp.Urls {
+				got = append(got, u.ShortCode)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			}
+		}
+		if _, err := s.GetTopURLs(ctx, &proto.GetTopURLsRequest{Since: "yesterday"}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("invalid since: got %v, want InvalidArgument", err)
+		}
+	})
+}
+
+func TestConformanceBlockedDomains(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		added, err := s.AddBlockedDomain(ctx, &proto.AddBlockedDomainRequest{Pattern: "Evil.Example", Reason: "phishing"})
+		if err != nil || added.Domain.Pattern != "evil.example" {
+			t.Fatalf("AddBlockedDomain = %v, %v", added, err)
+		}
+		if _, err := s.AddBlockedDomain(ctx, &proto.AddBlockedDomainRequest{Pattern: `^https://[^/]*\.zip/`, Regex: true}); err != nil {
+			t.Fatalf("AddBlockedDomain regex: %v", err)
+		}
+		if _, err := s.AddBlockedDomain(ctx, &proto.AddBlockedDomainRequest{Pattern: "(", Regex: true}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("AddBlockedDomain of an invalid regex: got %v, want InvalidArgument", err)
+		}
+
+		list, err := s.ListBlockedDomains(ctx, &proto.ListBlockedDomainsRequest{})
+		if err != nil || len(list.Domains) != 2 {
+			t.Fatalf("ListBlockedDomains = %v, %v, want 2", list, err)
+		}
+		if _, err := s.RemoveBlockedDomain(ctx, &proto.RemoveBlockedDomainRequest{Pattern: "evil.example"}); err != nil {
+			t.Fatalf("RemoveBlockedDomain: %v", err)
+		}
+		if list, err := s.ListBlockedDomains(ctx, &proto.ListBlockedDomainsRequest{}); err != nil || len(list.Domains) != 1 || !list.Domains[0].Regex {
+			t.Errorf("ListBlockedDomains after removal = %v, %v", list, err)
+		}
+	})
+}
+
+func TestConformanceReports(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "bad", OriginalUrl: "https://example.com"})
+
+		first, err := s.ReportURL(ctx, &proto.ReportURLRequest{ShortCode: "bad", Reason: "spam", Reporter: "r1", DisableThreshold: 2})
+		if err != nil || first.ReportId == 0 || first.Duplicate || first.Reporters != 1 || first.Disabled {
+			t.Fatalf("first report = %v, %v", first, err)
+		}
+		again, err := s.ReportURL(ctx, &proto.ReportURLRequest{ShortCode: "bad", Reason: "spam", Reporter: "r1", DisableThreshold: 2})
+		if err != nil || !again.Duplicate || again.ReportId != 0 {
+			t.Errorf("repeated report = %v, %v, want a duplicate", again, err)
+		}
+		second, err := s.ReportURL(ctx, &proto.ReportURLRequest{ShortCode: "bad", Reason: "malware", Reporter: "r2", DisableThreshold: 2})
+		if err != nil || second.Reporters != 2 || !second.Disabled {
+			t.Errorf("second reporter = %v, %v, want the URL disabled", second, err)
+		}
+		if resp, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "bad"}); err != nil || !resp.Disabled {
+			t.Errorf("GetURL of a reported URL = %v, %v", resp, err)
+		}
+
+		reports, err := s.ListReports(ctx, &proto.ListReportsRequest{ShortCode: "bad", PageSize: 10})
+		if err != nil || len(reports.Reports) != 2 {
+			t.Fatalf("ListReports = %v, %v, want 2", reports, err)
+		}
+		for _, r := range reports.Reports {
+			if r.Status != "actioned" {
+				t.Errorf("report %d is %s, want actioned", r.Id, r.Status)
+			}
+		}
+		if _, err := s.ReportURL(ctx, &proto.ReportURLRequest{ShortCode: "missing", Reason: "spam", Reporter: "r1"}); status.Code(err) != codes.NotFound {
+			t.Errorf("report of a missing code: got %v, want NotFound", err)
+		}
+	})
+}
+
+func TestConformanceDeleteUserData(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "u1", OriginalUrl: "https://example.com/1", UserId: "alice"})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "u2", OriginalUrl: "https://example.com/2", UserId: "alice"})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "u3", OriginalUrl: "https://example.com/3", UserId: "bob"})
+		if _, err := s.RecordClick(ctx, &proto.RecordClickRequest{Events: []*proto.ClickEvent{{ShortCode: "u1", Referrer: "https://ref.example", UserAgent: "Mozilla/5.0", Country: "DE"}}}); err != nil {
+			t.Fatalf("RecordClick: %v", err)
+		}
+
+		resp, err := s.DeleteUserData(ctx, &proto.DeleteUserDataRequest{UserId: "alice"})
+		if err != nil {
+			t.Fatalf("DeleteUserData: %v", err)
+		}
+		if resp.DeletedUrls != 2 || resp.ScrubbedClicks != 1 || !slices.Equal(slices.Sorted(slices.Values(resp.ShortCodes)), []string{"u1", "u2"}) {
+			t.Errorf("DeleteUserData = %v", resp)
+		}
+		if again, err := s.DeleteUserData(ctx, &proto.DeleteUserDataRequest{UserId: "alice"}); err != nil || again.DeletedUrls != 0 || len(again.ShortCodes) != 2 {
+			t.Errorf("repeated DeleteUserData = %v, %v", again, err)
+		}
+		if _, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "u3"}); err != nil {
+			t.Errorf("another user's URL: %v", err)
+		}
+	})
+}
+
+func TestConformanceUserDataIsolation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "a1", OriginalUrl: "https://example.com/a1", UserId: "alice"})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "a2", OriginalUrl: "https://example.com/a2", UserId: "alice"})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "b1", OriginalUrl: "https://example.com/b1", UserId: "bob"})
+		// Same user name, another tenant
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "acme:a3", OriginalUrl: "https://example.com/a3", UserId: "alice", TenantId: "acme"})
+		var events []*proto.ClickEvent
+		for _, code := range []string{"a1", "a1", "a2", "b1", "acme:a3"} {
+			events = append(events, &proto.ClickEvent{ShortCode: code, Referrer: "https://news.example/", UserAgent: "Mozilla/5.0", Country: "DE"})
+		}
+		if _, err := s.RecordClick(ctx, &proto.RecordClickRequest{Events: events}); err != nil {
+			t.Fatalf("RecordClick: %v", err)
+		}
+
+		resp, err := s.DeleteUserData(ctx, &proto.DeleteUserDataRequest{UserId: "alice"})
+		if err != nil {
+			t.Fatalf("DeleteUserData: %v", err)
+		}
+		if resp.DeletedUrls != 2 || resp.ScrubbedClicks != 3 || !slices.Equal(resp.ShortCodes, []string{"a1", "a2"}) {
+			t.Errorf("DeleteUserData = %v, want a1 and a2 deleted and 3 clicks scrubbed", resp)
+		}
+
+		// Only alice's links in the default tenant are touched
+		for _, tt := range []struct {
+			code      string
+			deleted   bool
+			countries int
+		}{{"a1", true, 0}, {"a2", true, 0}, {"b1", false, 1}, {"acme:a3", false, 1}} {
+			_, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: tt.code})
+			if deleted := status.Code(err) == codes.NotFound; deleted != tt.deleted {
+				t.Errorf("%s: GetURL got %v, want deleted %v", tt.code, err, tt.deleted)
+			}
+			breakdown, err := s.GetClickBreakdown(ctx, &proto.GetClickBreakdownRequest{ShortCode: tt.code})
+			if err != nil {
+				t.Fatalf("%s: GetClickBreakdown: %v", tt.code, err)
+			}
+			if len(breakdown.Countries) != tt.countries {
+				t.Errorf("%s: countries %v, want %d", tt.code, breakdown.Countries, tt.countries)
+			}
+		}
+		// Scrubbed clicks still count
+		if series, err := s.GetClickTimeSeries(ctx, &proto.GetClickTimeSeriesRequest{ShortCode: "a1", Start: rfc3339(time.Now().Add(-time.Hour)), End: rfc3339(time.Now().Add(time.Hour)), Bucket: "hour"}); err != nil || series.TotalClicks != 2 {
+			t.Errorf("a1 time series = %v, %v, want its 2 clicks kept", series, err)
+		}
+
+		// Repeats are audited too
+		if _, err := s.DeleteUserData(ctx, &proto.DeleteUserDataRequest{UserId: "alice"}); err != nil {
+			t.Fatalf("repeated DeleteUserData: %v", err)
+		}
+		var audits []string
+		rows, err := s.db.QueryContext(ctx, `SELECT user_id, action, deleted_urls, scrubbed_clicks FROM user_data_audit ORDER BY id`)
+		if err != nil {
+			t.Fatalf("reading the audit: %v", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var user, action string
+			var deleted, scrubbed int64
+			if err := rows.Scan(&user, &action, &deleted, &scrubbed); err != nil {
+				t.Fatalf("scanning the audit: %v", err)
+			}
+			audits = append(audits, fmt.Sprintf("%s %s %d %d", user, action, deleted, scrubbed))
+		}
+		if want := []string{"alice delete 2 3", "alice delete 0 0"}; !slices.Equal(audits, want) {
+			t.Errorf("audit %q, want %q", audits, want)
+		}
+
+		// The export of a user has their deleted links and no one else's
+		client := dialBufconn(t, s)
+		exported, _ := exportAll(t, client, &proto.ExportURLsRequest{UserId: "alice"})
+		if !slices.Equal(exported, []string{"a1", "a2"}) {
+			t.Errorf("export of alice = %v, want a1 and a2", exported)
+		}
+		if exported, _ := exportAll(t, client, &proto.ExportURLsRequest{UserId: "alice", TenantId: "acme"}); !slices.Equal(exported, []string{"acme:a3"}) {
+			t.Errorf("export of alice in acme = %v, want acme:a3", exported)
+		}
+
+		if _, err := s.DeleteUserData(ctx, &proto.DeleteUserDataRequest{}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("DeleteUserData without a user: got %v, want InvalidArgument", err)
+		}
+	})
+}
+
+func TestConformanceTenantKeys(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		// The same code in the default tenant and two others
+		links := []struct{ key, tenant, url string }{
+			{"promo", "", "https://example.com/default"},
+			{"acme:promo", "acme", "https://acme.example/promo"},
+			{"beta:promo", "beta", "https://beta.example/promo"},
+		}
+		for _, l := range links {
+			saveURL(t, s, &proto.SaveURLRequest{ShortCode: l.key, OriginalUrl: l.url, UserId: "alice", TenantId: l.tenant, Tags: []string{"sale"}})
+		}
+
+		var rows int
+		if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM urls WHERE short_code = 'promo'`).Scan(&rows); err != nil || rows != 3 {
+			t.Errorf("rows with code promo = %d, %v, want 3", rows, err)
+		}
+		for _, l := range links {
+			if resp, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: l.key}); err != nil || resp.OriginalUrl != l.url {
+				t.Errorf("GetURL %s = %v, %v, want %s", l.key, resp, err, l.url)
+			}
+		}
+		batch, err := s.GetURLs(ctx, &proto.GetURLsRequest{ShortCodes: []string{"acme:promo", "beta:promo", "gamma:promo"}})
+		if err != nil {
+			t.Fatalf("GetURLs: %v", err)
+		}
+		if len(batch.Urls) != 2 || batch.Urls["acme:promo"].GetOriginalUrl() != links[1].url || batch.Urls["beta:promo"].GetOriginalUrl() != links[2].url {
+			t.Errorf("GetURLs = %v, want acme's and beta's promo", batch.Urls)
+		}
+
+		if _, err := s.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "acme:promo", OriginalUrl: "https://mallory.example", TenantId: "acme", CreateOnly: true}); status.Code(err) != codes.AlreadyExists {
+			t.Errorf("second create of acme:promo: got %v, want AlreadyExists", err)
+		}
+		saved, err := s.SaveURLs(ctx, &proto.SaveURLsRequest{Urls: []*proto.SaveURLRequest{
+			{ShortCode: "promo", OriginalUrl: "https://mallory.example"},
+			{ShortCode: "gamma:promo", OriginalUrl: "https://gamma.example/promo", TenantId: "gamma"},
+		}})
+		if err != nil || !slices.Equal(saved.InsertedShortCodes, []string{"gamma:promo"}) {
+			t.Errorf("SaveURLs = %v, %v, want only gamma:promo inserted", saved, err)
+		}
+
+		// Clicks land on their own tenant's link
+		for i := 0; i < 2; i++ {
+			if _, err := s.IncrementClick(ctx, &proto.IncrementClickRequest{ShortCode: "acme:promo"}); err != nil {
+				t.Fatalf("IncrementClick: %v", err)
+			}
+		}
+		if _, err := s.RecordClick(ctx, &proto.RecordClickRequest{Events: []*proto.ClickEvent{{ShortCode: "beta:promo", Country: "DE"}}}); err != nil {
+			t.Fatalf("RecordClick: %v", err)
+		}
+		for _, tt := range []struct {
+			key       string
+			clicks    int64
+			countries int
+		}{{"promo", 0, 0}, {"acme:promo", 2, 0}, {"beta:promo", 0, 1}} {
+			if stats, err := s.GetStats(ctx, &proto.GetStatsRequest{ShortCode: tt.key}); err != nil || stats.ClickCount != tt.clicks {
+				t.Errorf("GetStats %s = %v, %v, want %d clicks", tt.key, stats, err, tt.clicks)
+			}
+			if breakdown, err := s.GetClickBreakdown(ctx, &proto.GetClickBreakdownRequest{ShortCode: tt.key}); err != nil || len(breakdown.Countries) != tt.countries {
+				t.Errorf("GetClickBreakdown %s = %v, %v, want %d countries", tt.key, breakdown, err, tt.countries)
+			}
+		}
+
+		// Listings name links by key, and purging one leaves the others
+		if _, err := s.PurgeURL(ctx, &proto.PurgeURLRequest{ShortCode: "acme:promo"}); err != nil {
+			t.Fatalf("PurgeURL: %v", err)
+		}
+		if _, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "acme:promo"}); status.Code(err) != codes.NotFound {
+			t.Errorf("GetURL of purged acme:promo: got %v, want NotFound", err)
+		}
+		for _, tenant := range []string{"", "acme", "beta"} {
+			list, err := s.ListURLs(ctx, &proto.ListURLsRequest{UserId: "alice", TenantId: tenant, Tags: []string{"sale"}})
+			if err != nil {
+				t.Fatalf("ListURLs in %q: %v", tenant, err)
+			}
+			var got []string
+			for _, u := range list.Urls {
+				got = append(got, u.ShortCode+" "+strings.Join(u.Tags, ","))
+			}
+			var want []string
+			switch tenant {
+			case "":
+				want = []string{"promo sale"}
+			case "beta":
+				want = []string{"beta:promo sale"}
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("ListURLs in %q = %q, want %q", tenant, got, want)
+			}
+		}
+	})
+}
+
+func TestConformanceMetadataAndStaleURLs(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "m1", OriginalUrl: "https://example.com/1"})
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "m2", OriginalUrl: "https://example.com/2"})
+
+		if _, err := s.UpdateMetadata(ctx, &proto.UpdateMetadataRequest{ShortCode: "m1", Title: "Example", Description: "A page", ImageUrl: "https://example.com/og.png"}); err != nil {
+			t.Fatalf("UpdateMetadata: %v", err)
+		}
+		stats, err := s.GetStats(ctx, &proto.GetStatsRequest{ShortCode: "m1"})
+		if err != nil || stats.Title != "Example" || stats.Description != "A page" || stats.ImageUrl != "https://example.com/og.png" {
+			t.Errorf("GetStats = %v, %v", stats, err)
+		}
+
+		if _, err := s.BatchIncrementClicks(ctx, &proto.BatchIncrementClicksRequest{Deltas: []*proto.ClickDelta{{ShortCode: "m2", Delta: 1, LastAccessedAt: rfc3339(time.Now().Add(time.Minute))}}}); err != nil {
+			t.Fatalf("BatchIncrementClicks: %v", err)
+		}
+		stale, err := s.ListStaleURLs(ctx, &proto.ListStaleURLsRequest{OlderThan: rfc3339(time.Now().Add(time.Second))})
+		if err != nil || len(stale.Urls) != 1 || stale.Urls[0].ShortCode != "m1" {
+			t.Errorf("ListStaleURLs = %v, %v, want only m1", stale, err)
+		}
+	})
+}
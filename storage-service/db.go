@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"math/rand/v2"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Connection pool defaults. MaxOpenConns bounds what one replica can ask of
+// Postgres, so a pile-up of slow queries waits in the pool instead of
+// exhausting max_connections.
+const (
+	defaultDBMaxOpenConns    = 25
+	defaultDBMaxIdleConns    = 10
+	defaultDBConnMaxLifetime = 30 * time.Minute
+	defaultDBConnMaxIdleTime = 5 * time.Minute
+
+	// The statement timeout backs up the query timeout on the server side,
+	// in case a cancellation is lost.
+	defaultDBQueryTimeout     = 5 * time.Second
+	defaultDBStatementTimeout = 10 * time.Second
+)
+
+// defaultDBBatchChunkSize bounds the rows of one batch statement, keeping
+// its locks short and its parameters under Postgres' limit.
+const defaultDBBatchChunkSize = 500
+
+// queryCanceled is the SQLSTATE Postgres reports for a statement stopped by
+// statement_timeout or a cancel request.
+const queryCanceled = "57014"
+
+func configurePool(db *sql.DB, config Config) {
+	db.SetMaxOpenConns(config.DBMaxOpenConns)
+	db.SetMaxIdleConns(min(config.DBMaxIdleConns, config.DBMaxOpenConns))
+	db.SetConnMaxLifetime(config.DBConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.DBConnMaxIdleTime)
+}
+
+// withQueryTimeout bounds one statement. The timeout is also released when
+// ctx ends, since rows may still be read after the call returns.
+func (db tracedDB) withQueryTimeout(ctx context.Context) context.Context {
+	if db.queryTimeout <= 0 {
+		return ctx
+	}
+	queryCtx, cancel := context.WithTimeout(ctx, db.queryTimeout)
+	context.AfterFunc(ctx, cancel)
+	return queryCtx
+}
+
+// dbError turns a database error into a status, reporting timeouts as
+// DeadlineExceeded so callers can tell them from failures.
+func dbError(err error, msg string) error {
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &pqErr) && pqErr.Code == queryCanceled:
+		return status.Errorf(codes.DeadlineExceeded, "%s: %v", msg, err)
+	case errors.Is(err, context.Canceled):
+		return status.Errorf(codes.Canceled, "%s: %v", msg, err)
+	}
+	return status.Errorf(codes.Internal, "%s: %v", msg, err)
+}
+
+// logPoolSaturation warns when requests had to wait for a connection since
+// the previous stats, the sign that DB_MAX_OPEN_CONNS is too low or queries
+// are too slow.
+func logPoolSaturation(prev, stats sql.DBStats) {
+	waits := stats.WaitCount - prev.WaitCount
+	if waits == 0 {
+		return
+	}
+	log.Printf("Warning: connection pool saturated, %d requests waited %s for a connection (%d/%d in use)",
+		waits, stats.WaitDuration-prev.WaitDuration, stats.InUse, stats.MaxOpenConnections)
+}
+
+// Retry defaults for transient database errors. Every retry also has to fit
+// in the request deadline.
+const (
+	defaultDBRetryMaxAttempts = 3
+	defaultDBRetryBaseDelay   = 50 * time.Millisecond
+	dbRetryMaxDelay           = time.Second
+)
+
+// SQLSTATEs after which the statement is known to have been rolled back, so
+// running it again is safe whatever it does.
+var retryableSQLStates = map[pq.ErrorCode]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+	"08001": true, // sqlclient_unable_to_establish_sqlconnection
+	"08004": true, // sqlserver_rejected_establishment_of_sqlconnection
+}
+
+// isTransient reports whether a failed statement is worth running again.
+// A connection lost mid-statement may have committed it, so those are only
+// retried for reads.
+func isTransient(err error, operation string) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return retryableSQLStates[pqErr.Code] || (operation == "SELECT" && pqErr.Code.Class() == "08")
+	}
+	// Nothing reached the server
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	if operation != "SELECT" {
+		return false
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, driver.ErrBadConn)
+}
+
+// retry runs attempt until it succeeds, fails with an error that isn't
+// transient, or runs out of attempts or time. Backoff is exponential with
+// jitter so replicas recovering from the same failover don't retry in step.
+func (db tracedDB) retry(ctx context.Context, query string, attempt func(ctx context.Context) error) error {
+	operation, _ := sqlOperation(query)
+	for n := 1; ; n++ {
+		err := attempt(ctx)
+		if n >= db.retryMaxAttempts || !isTransient(err, operation) {
+			return err
+		}
+
+		delay := min(db.retryBaseDelay<<(n-1), dbRetryMaxDelay)
+		delay = delay/2 + rand.N(delay/2+1)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+		logf(ctx, "Transient database error on %s (attempt %d/%d), retrying in %s: %v",
+			operation, n, db.retryMaxAttempts, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// inTx runs fn in a transaction, committing if it returns nil. query names
+// the transaction's span and decides, like a single statement, which
+// failures are retried; each attempt starts a fresh transaction.
+func (db tracedDB) inTx(ctx context.Context, query string, fn func(ctx context.Context, tx dbTx) error) error {
+	ctx, span := db.startSpan(ctx, query)
+	defer span.End()
+
+	err := db.retry(ctx, query, func(ctx context.Context) error {
+		ctx = db.withQueryTimeout(ctx)
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := fn(ctx, dbTx{Tx: tx, dialect: db.dialect}); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	recordDBError(span, err)
+	return err
+}
+
+// dbTx is a transaction whose statements take parameters like tracedDB's.
+type dbTx struct {
+	*sql.Tx
+	dialect dialect
+}
+
+func (tx dbTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.ExecContext(ctx, query, tx.dialect.args(args)...)
+}
+
+func (tx dbTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.QueryContext(ctx, query, tx.dialect.args(args)...)
+}
+
+func (tx dbTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRowContext(ctx, query, tx.dialect.args(args)...)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// slowQuery counts far enough that SQLite spends seconds on it.
+const slowQuery = `WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 1000000000) SELECT count(*) FROM n`
+
+func TestQueryTimeoutAbortsSlowQuery(t *testing.T) {
+	t.Setenv("DB_QUERY_TIMEOUT", "50ms")
+	s := newTestServer(t)
+
+	start := time.Now()
+	var n int64
+	err := s.db.QueryRowContext(context.Background(), slowQuery).Scan(&n)
+	if err == nil {
+		t.Fatalf("slow query finished with %d, want it aborted", n)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("slow query aborted after %v, want about 50ms", elapsed)
+	}
+	if got := status.Code(dbError(err, "failed to count")); got != codes.DeadlineExceeded {
+		t.Errorf("dbError(%v) = %v, want DeadlineExceeded", err, got)
+	}
+
+	// The pool is usable again afterwards
+	if err := s.db.QueryRowContext(context.Background(), "SELECT 1").Scan(&n); err != nil {
+		t.Errorf("query after the timeout: %v", err)
+	}
+}
+
+func TestDBError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{"context deadline", context.DeadlineExceeded, codes.DeadlineExceeded},
+		{"wrapped deadline", fmt.Errorf("scan: %w", context.DeadlineExceeded), codes.DeadlineExceeded},
+		{"statement_timeout", &pq.Error{Code: queryCanceled}, codes.DeadlineExceeded},
+		{"canceled", context.Canceled, codes.Canceled},
+		{"unique violation", &pq.Error{Code: "23505"}, codes.Internal},
+		{"other", errors.New("disk full"), codes.Internal},
+	}
+	for _, tt := range tests {
+		err := dbError(tt.err, "failed")
+		if got := status.Code(err); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+		if !strings.HasPrefix(status.Convert(err).Message(), "failed: ") {
+			t.Errorf("%s: message %q lost its context", tt.name, status.Convert(err).Message())
+		}
+	}
+}
+
+func TestConfigurePool(t *testing.T) {
+	db, err := sql.Open(driverSQLite, filepath.Join(t.TempDir(), "pool.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	configurePool(db, Config{DBMaxOpenConns: 2, DBMaxIdleConns: 10, DBConnMaxLifetime: time.Minute, DBConnMaxIdleTime: time.Minute})
+	if got := db.Stats().MaxOpenConnections; got != 2 {
+		t.Errorf("MaxOpenConnections %d, want 2", got)
+	}
+
+	// A third caller waits for one of the two connections
+	ctx := context.Background()
+	var conns []*sql.Conn
+	for i := 0; i < 2; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatalf("conn %d: %v", i, err)
+		}
+		conns = append(conns, conn)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := db.Conn(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("conn past the limit: got %v, want DeadlineExceeded", err)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	if stats := db.Stats(); stats.WaitCount != 1 || stats.Idle > 2 {
+		t.Errorf("stats %+v, want one wait and at most 2 idle", stats)
+	}
+}
+
+func TestPostgresConnStringStatementTimeout(t *testing.T) {
+	connStr := postgresConnString(Config{DBStatementTimeout: 1500 * time.Millisecond}, "db", "5432")
+	if !strings.Contains(connStr, "statement_timeout=1500") {
+		t.Errorf("connection string %q has no statement_timeout=1500", connStr)
+	}
+}
+
+func TestLogPoolSaturation(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	prev := sql.DBStats{WaitCount: 4, WaitDuration: time.Second}
+	logPoolSaturation(prev, prev)
+	if buf.Len() != 0 {
+		t.Errorf("logged %q without new waits", buf.String())
+	}
+	logPoolSaturation(prev, sql.DBStats{MaxOpenConnections: 25, InUse: 25, WaitCount: 7, WaitDuration: 3 * time.Second})
+	if got := buf.String(); !strings.Contains(got, "3 requests waited 2s") || !strings.Contains(got, "(25/25 in use)") {
+		t.Errorf("logged %q, want 3 waits of 2s with 25/25 in use", got)
+	}
+}
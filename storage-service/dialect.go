@@ -0,0 +1,106 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Values of DB_DRIVER.
+const (
+	driverPostgres = "postgres"
+	driverSQLite   = "sqlite"
+)
+
+// dialect covers the differences between the database backends. Queries
+// are written for Postgres and most run unchanged on SQLite, which gets the
+// few Postgres functions they use registered in sqlite.go. The rest come in
+// both forms, picked with sql.
+type dialect struct {
+	driver string
+}
+
+func (d dialect) sqlite() bool {
+	return d.driver == driverSQLite
+}
+
+// sql returns the form of a statement for this backend.
+func (d dialect) sql(postgres, sqlite string) string {
+	if d.sqlite() {
+		return sqlite
+	}
+	return postgres
+}
+
+// system names the backend in trace attributes.
+func (d dialect) system() string {
+	return d.sql("postgresql", "sqlite")
+}
+
+// anyOf matches column against the values of an array parameter.
+func (d dialect) anyOf(column, param string) string {
+	return d.sql(column+" = ANY("+param+")", column+" IN (SELECT value FROM json_each("+param+"))")
+}
+
+// originalHost is the reversed, lowercased host of original_url. It must
+// stay identical to the expression of idx_urls_original_host.
+func (d dialect) originalHost() string {
+	return d.sql(
+		`reverse(lower(substring(original_url from '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]+)')))`,
+		`reverse(lower(url_host(original_url)))`,
+	)
+}
+
+// array passes a slice as one parameter: a Postgres array, or on SQLite a
+// JSON array to read with json_each.
+func (d dialect) array(values interface{}) interface{} {
+	if !d.sqlite() {
+		return pq.Array(values)
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		panic(err) // Only slices of strings and numbers are passed
+	}
+	return string(data)
+}
+
+// timestamps passes RFC3339 strings as an array parameter like array,
+// converting them to the stored format on SQLite. Empty strings and
+// strings that don't parse are passed as they are.
+func (d dialect) timestamps(values []string) interface{} {
+	if !d.sqlite() {
+		return pq.Array(values)
+	}
+	converted := make([]string, len(values))
+	for i, value := range values {
+		converted[i] = value
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			converted[i] = formatSQLiteTime(t)
+		}
+	}
+	return d.array(converted)
+}
+
+// args converts time parameters to the stored format on SQLite, where
+// timestamps are compared as strings.
+func (d dialect) args(args []interface{}) []interface{} {
+	if !d.sqlite() {
+		return args
+	}
+	converted := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			converted[i] = formatSQLiteTime(v)
+		case sql.NullTime:
+			if v.Valid {
+				converted[i] = formatSQLiteTime(v.Time)
+			}
+		default:
+			converted[i] = arg
+		}
+	}
+	return converted
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// normalizeBlockedPattern returns the stored form of a blocked host or
+// regex. Hosts are lowercased, and a leading "*." is dropped since hosts
+// match their subdomains anyway.
+func normalizeBlockedPattern(pattern string, regex bool) (string, error) {
+	pattern = strings.TrimSpace(pattern)
+	if regex {
+		if pattern == "" {
+			return "", status.Error(codes.InvalidArgument, "pattern is required")
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return "", status.Errorf(codes.InvalidArgument, "invalid regex: %v", err)
+		}
+		return pattern, nil
+	}
+
+	host := strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(pattern), "*."), ".")
+	if host == "" || strings.ContainsAny(host, "/:@?# ") {
+		return "", status.Errorf(codes.InvalidArgument, "pattern %q is not a host", pattern)
+	}
+	return host, nil
+}
+
+// AddBlockedDomain adds a host or regex to the blocked domains. Adding one
+// again only replaces its reason.
+func (s *storageServer) AddBlockedDomain(ctx context.Context, req *proto.AddBlockedDomainRequest) (*proto.AddBlockedDomainResponse, error) {
+	logf(ctx, "Storage AddBlockedDomain request for %q, regex %t", req.Pattern, req.Regex)
+
+	pattern, err := normalizeBlockedPattern(req.Pattern, req.Regex)
+	if err != nil {
+		return nil, err
+	}
+
+	var createdAt time.Time
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO blocked_domains (pattern, is_regex, reason, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4)
+		ON CONFLICT (pattern, is_regex) DO UPDATE SET reason = EXCLUDED.reason
+		RETURNING created_at
+	`, pattern, req.Regex, req.Reason, time.Now()).Scan(&createdAt)
+	if err != nil {
+		logf(ctx, "Failed to add blocked domain: %v", err)
+		return nil, dbError(err, "failed to add blocked domain")
+	}
+
+	return &proto.AddBlockedDomainResponse{Domain: &proto.BlockedDomain{
+		Pattern:   pattern,
+		Regex:     req.Regex,
+		Reason:    req.Reason,
+		CreatedAt: createdAt.Format(time.RFC3339),
+	}}, nil
+}
+
+// RemoveBlockedDomain removes a host or regex from the blocked domains.
+func (s *storageServer) RemoveBlockedDomain(ctx context.Context, req *proto.RemoveBlockedDomainRequest) (*proto.RemoveBlockedDomainResponse, error) {
+	logf(ctx, "Storage RemoveBlockedDomain request for %q, regex %t", req.Pattern, req.Regex)
+
+	pattern, err := normalizeBlockedPattern(req.Pattern, req.Regex)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM blocked_domains WHERE pattern = $1 AND is_regex = $2
+	`, pattern, req.Regex)
+	if err != nil {
+		logf(ctx, "Failed to remove blocked domain: %v", err)
+		return nil, dbError(err, "failed to remove blocked domain")
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return nil, status.Errorf(codes.NotFound, "blocked domain not found: %s", pattern)
+	}
+	return &proto.RemoveBlockedDomainResponse{}, nil
+}
+
+// ListBlockedDomains returns every blocked host and regex. The list is meant
+// to be small enough for url-service to keep in memory, so it isn't paged.
+func (s *storageServer) ListBlockedDomains(ctx context.Context, req *proto.ListBlockedDomainsRequest) (*proto.ListBlockedDomainsResponse, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT pattern, is_regex, COALESCE(reason, ''), created_at
+		FROM blocked_domains
+		ORDER BY is_regex, pattern
+	`)
+	if err != nil {
+		logf(ctx, "Database error: %v", err)
+		return nil, dbError(err, "failed to list blocked domains")
+	}
+	defer rows.Close()
+
+	resp := &proto.ListBlockedDomainsResponse{}
+	for rows.Next() {
+		var domain proto.BlockedDomain
+		var createdAt time.Time
+		if err := rows.Scan(&domain.Pattern, &domain.Regex, &domain.Reason, &createdAt); err != nil {
+			return nil, dbError(err, "failed to scan blocked domain")
+		}
+		domain.CreatedAt = createdAt.Format(time.RFC3339)
+		resp.Domains = append(resp.Domains, &domain)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "failed to list blocked domains")
+	}
+	return resp, nil
+}
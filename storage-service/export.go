@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultExportBatchSize = 500
+	maxExportBatchSize     = 5000
+)
+
+// ExportURLs streams every URL, or every URL of a user, deleted ones
+// included, in short code order.
+// Each message is read by its own keyset query, so no transaction or
+// snapshot is held open for the whole export; rows written meanwhile may or
+// may not be included. The stream stops as soon as the client goes away.
+func (s *storageServer) ExportURLs(req *proto.ExportURLsRequest, stream proto.StorageService_ExportURLsServer) error {
+	ctx := stream.Context()
+	logf(ctx, "Storage ExportURLs request in batches of %d created after %q", req.BatchSize, req.CreatedAfter)
+
+	batchSize := req.BatchSize
+	if batchSize < 0 {
+		return status.Error(codes.InvalidArgument, "batch_size must not be negative")
+	}
+	if batchSize == 0 {
+		batchSize = defaultExportBatchSize
+	}
+	batchSize = min(batchSize, maxExportBatchSize)
+
+	// The zero time is before every row, so it stands for no filter
+	var createdAfter time.Time
+	if req.CreatedAfter != "" {
+		t, err := time.Parse(time.RFC3339Nano, req.CreatedAfter)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid created_after: %v", err)
+		}
+		createdAfter = t
+	}
+
+	after := req.AfterShortCode
+	var exported int
+	for {
+		urls, err := s.exportPage(ctx, after, createdAfter, req.UserId, req.TenantId, batchSize)
+		if err != nil {
+			logf(ctx, "Export stopped after %d URLs: %v", exported, err)
+			return dbError(err, "failed to export URLs")
+		}
+		if len(urls) == 0 {
+			break
+		}
+		if err := stream.Send(&proto.ExportURLsResponse{Urls: urls}); err != nil {
+			logf(ctx, "Export stopped after %d URLs: %v", exported, err)
+			return err
+		}
+
+		exported += len(urls)
+		after = urls[len(urls)-1].ShortCode
+		if len(urls) < int(batchSize) {
+			break
+		}
+	}
+
+	logf(ctx, "Exported %d URLs", exported)
+	return nil
+}
+
+// exportPage reads up to limit URLs with codes after the given one, only
+// those of user in tenant unless user is empty.
+func (s *storageServer) exportPage(ctx context.Context, after string, createdAfter time.Time, user, tenant string, limit int32) ([]*proto.ExportedURL, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+keyOf("urls")+`, original_url, created_at, click_count, expires_at, user_id, deleted_at, tenant_id
+		FROM urls
+		WHERE (tenant_id, short_code) > (`+keyTenant("$1")+`, `+keyCode("$1")+`)
+			AND created_at > $2
+			AND ($4 = '' OR (user_id = $4 AND tenant_id = $5))
+		ORDER BY tenant_id, short_code
+		LIMIT $3
+	`, after, createdAfter, limit, user, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	urls := make([]*proto.ExportedURL, 0, limit)
+	for rows.Next() {
+		var u proto.ExportedURL
+		var createdAt time.Time
+		var expiresAt, deletedAt sql.NullTime
+		var userID sql.NullString
+		if err := rows.Scan(&u.ShortCode, &u.OriginalUrl, &createdAt, &u.ClickCount, &expiresAt, &userID, &deletedAt, &u.TenantId); err != nil {
+			return nil, err
+		}
+		u.CreatedAt = createdAt.Format(time.RFC3339Nano)
+		u.ExpiresAt = formatOptionalTime(expiresAt)
+		u.UserId = userID.String
+		u.DeletedAt = formatOptionalTime(deletedAt)
+		urls = append(urls, &u)
+	}
+	return urls, rows.Err()
+}
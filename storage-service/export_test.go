@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// exportAll reads a whole export, returning the codes in stream order and
+// the size of the largest message.
+func exportAll(t *testing.T, client proto.StorageServiceClient, req *proto.ExportURLsRequest) (codes []string, largest int) {
+	t.Helper()
+	stream, err := client.ExportURLs(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ExportURLs: %v", err)
+	}
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return codes, largest
+		}
+		if err != nil {
+			t.Fatalf("ExportURLs after %d URLs: %v", len(codes), err)
+		}
+		largest = max(largest, len(msg.Urls))
+		for _, u := range msg.Urls {
+			codes = append(codes, u.ShortCode)
+		}
+	}
+}
+
+func TestExportURLsComplete(t *testing.T) {
+	// The SQL functions behind the indexes are slow under -race
+	t.Setenv("DB_QUERY_TIMEOUT", "1m")
+	s := newTestServer(t)
+	client := dialBufconn(t, s)
+	ctx := context.Background()
+
+	const seeded = 20000
+	_, err := s.db.ExecContext(ctx, `
+		WITH RECURSIVE n(i) AS (SELECT 0 UNION ALL SELECT i + 1 FROM n WHERE i < $1 - 1)
+		INSERT INTO urls (short_code, original_url, created_at, updated_at)
+		SELECT printf('e%05d', i), 'https://example.com/' || i, $2, $2 FROM n
+	`, seeded, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("seeding: %v", err)
+	}
+
+	codes, largest := exportAll(t, client, &proto.ExportURLsRequest{BatchSize: 750})
+	if len(codes) != seeded {
+		t.Fatalf("exported %d URLs, want %d", len(codes), seeded)
+	}
+	for i, code := range codes {
+		if want := fmt.Sprintf("e%05d", i); code != want {
+			t.Fatalf("URL %d is %s, want %s in short code order", i, code, want)
+		}
+	}
+	if largest != 750 {
+		t.Errorf("largest message has %d URLs, want 750", largest)
+	}
+
+	// Incremental exports only see what was created since, and resumed ones
+	// what comes after the last code
+	since := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	saveURL(t, s, &proto.SaveURLRequest{ShortCode: "new1", OriginalUrl: "https://example.com/new"})
+	if got, _ := exportAll(t, client, &proto.ExportURLsRequest{CreatedAfter: since.UTC().Format(time.RFC3339Nano)}); fmt.Sprint(got) != "[new1]" {
+		t.Errorf("created_after export = %v, want [new1]", got)
+	}
+	if got, _ := exportAll(t, client, &proto.ExportURLsRequest{AfterShortCode: "e19998"}); fmt.Sprint(got) != "[e19999 new1]" {
+		t.Errorf("resumed export = %v, want [e19999 new1]", got)
+	}
+}
+
+func TestExportURLsCancel(t *testing.T) {
+	var buf syncBuffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	// More rows than fit in the stream's flow control window, so the
+	// server is still sending when the client goes away
+	s := newTestServer(t)
+	client := dialBufconn(t, s)
+	ctx := context.Background()
+	_, err := s.db.ExecContext(ctx, `
+		WITH RECURSIVE n(i) AS (SELECT 0 UNION ALL SELECT i + 1 FROM n WHERE i < 4999)
+		INSERT INTO urls (short_code, original_url, created_at, updated_at)
+		SELECT printf('c%04d', i), 'https://example.com/' || i, $1, $1 FROM n
+	`, time.Now())
+	if err != nil {
+		t.Fatalf("seeding: %v", err)
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	stream, err := client.ExportURLs(streamCtx, &proto.ExportURLsRequest{BatchSize: 1})
+	if err != nil {
+		t.Fatalf("ExportURLs: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("first message: %v", err)
+	}
+	cancel()
+	// Messages already received may still be read
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if status.Code(err) != codes.Canceled {
+				t.Errorf("after cancelling: got %v, want Canceled", err)
+			}
+			break
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(buf.String(), "Export stopped after") {
+		if time.Now().After(deadline) {
+			t.Fatal("export still running 5s after the client cancelled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if strings.Contains(buf.String(), "Exported 5000 URLs") {
+		t.Error("export finished after the client cancelled")
+	}
+
+	// Invalid requests fail with the first message
+	stream, err = client.ExportURLs(context.Background(), &proto.ExportURLsRequest{BatchSize: -1})
+	if err != nil {
+		t.Fatalf("ExportURLs: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("negative batch_size: got %v, want InvalidArgument", err)
+	}
+}
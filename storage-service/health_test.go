@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestReadyCheck(t *testing.T) {
+	s := newTestServer(t)
+	handler := newHealthHTTPServer("0", s).Handler
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	if code := get("/readyz"); code != http.StatusOK {
+		t.Errorf("/readyz with the database up = %d, want 200", code)
+	}
+
+	// Readiness follows the database, liveness doesn't
+	s.db.Close()
+	if code := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz with the database closed = %d, want 503", code)
+	}
+	if code := get("/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz with the database closed = %d, want 200", code)
+	}
+}
+
+func TestWatchReadiness(t *testing.T) {
+	healthServer := health.NewServer()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(srv, healthServer)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial bufconn: %v", err)
+	}
+	defer conn.Close()
+	client := grpc_health_v1.NewHealthClient(conn)
+	service := proto.StorageService_ServiceDesc.ServiceName
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watch, err := client.Watch(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	expect := func(want grpc_health_v1.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		resp, err := watch.Recv()
+		if err != nil || resp.Status != want {
+			t.Fatalf("watched %v, %v, want %s", resp, err, want)
+		}
+	}
+	expect(grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN)
+
+	// Each dependency check returns the next result sent here
+	results := make(chan error)
+	check := func(context.Context) error {
+		select {
+		case err := <-results:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	const threshold = 50 * time.Millisecond
+	go watchReadiness(ctx, healthServer, []string{"", service}, check, time.Millisecond, threshold)
+
+	// Not serving until the first check passes
+	expect(grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	results <- nil
+	expect(grpc_health_v1.HealthCheckResponse_SERVING)
+
+	// A single failure doesn't flip the status
+	down := errors.New("database unreachable")
+	results <- down
+	results <- nil
+	results <- nil
+	for _, name := range []string{"", service} {
+		if resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: name}); err != nil || resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+			t.Errorf("%q after a blip = %v, %v, want SERVING", name, resp, err)
+		}
+	}
+
+	// Failing past the threshold does, until a check passes again
+	stop := make(chan struct{})
+	failing := time.Now()
+	go func() {
+		for {
+			select {
+			case results <- down:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	expect(grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	close(stop)
+	if elapsed := time.Since(failing); elapsed < threshold {
+		t.Errorf("flipped after failing for %v, want at least %v", elapsed, threshold)
+	}
+	results <- nil
+	expect(grpc_health_v1.HealthCheckResponse_SERVING)
+	if resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}); err != nil || resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Errorf("overall status = %v, %v, want SERVING", resp, err)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Headers url-service sets to the user and API key behind a change. They
+// are trusted as is, like the rest of url-service's requests.
+const (
+	actorHeader      = "x-actor"
+	actorKeyIDHeader = "x-actor-key-id"
+)
+
+// Actions recorded in url_history. Creating a link isn't one: the urls row
+// already keeps when and by whom.
+const (
+	historyUpdate   = "update"   // SaveURL pointed a live link somewhere else
+	historyRecreate = "recreate" // SaveURL replaced a deleted link
+	historyDisable  = "disable"
+	historyEnable   = "enable"
+	historyDelete   = "delete"
+	historyPurge    = "purge" // PurgeURL removed the row for good
+)
+
+// actor returns the user and API key url-service named for ctx, if any.
+func actor(ctx context.Context) (user, keyID string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(actorHeader); len(values) > 0 {
+		user = values[0]
+	}
+	if values := md.Get(actorKeyIDHeader); len(values) > 0 {
+		keyID = values[0]
+	}
+	return user, keyID
+}
+
+// recordHistory adds a url_history row in the transaction making the change,
+// so a change is never stored without its history or the other way round.
+func recordHistory(ctx context.Context, tx dbTx, shortCode, action, oldURL, newURL string) error {
+	user, keyID := actor(ctx)
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO url_history (tenant_id, short_code, action, actor, api_key_id, old_url, new_url, changed_at)
+		VALUES (`+keyTenant("$1")+`, `+keyCode("$1")+`, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7)
+	`, shortCode, action, user, keyID, oldURL, newURL, time.Now())
+	return err
+}
+
+// GetURLHistory returns a page of the changes to a URL, newest first. It
+// reads history for deleted and purged URLs too.
+func (s *storageServer) GetURLHistory(ctx context.Context, req *proto.GetURLHistoryRequest) (*proto.GetURLHistoryResponse, error) {
+	logf(ctx, "Storage GetURLHistory request for: %s", req.ShortCode)
+
+	if req.ShortCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "short_code is required")
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 || pageSize > maxFindLimit {
+		pageSize = maxFindLimit
+	}
+
+	// Row IDs only grow, so the cursor is the last ID returned
+	var before int64 = 1<<63 - 1
+	if req.PageToken != "" {
+		id, err := strconv.ParseInt(req.PageToken, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+		before = id
+	}
+
+	// Fetch one extra row to learn whether there is a next page
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, action, COALESCE(actor, ''), COALESCE(api_key_id, ''), COALESCE(old_url, ''), COALESCE(new_url, ''), changed_at
+		FROM url_history
+		WHERE `+keyIs("url_history", "$1")+` AND id < $2
+		ORDER BY id DESC
+		LIMIT $3
+	`, req.ShortCode, before, pageSize+1)
+	if err != nil {
+		logf(ctx, "Database error: %v", err)
+		return nil, dbError(err, "failed to get URL history")
+	}
+	defer rows.Close()
+
+	resp := &proto.GetURLHistoryResponse{}
+	var lastID int64
+	for rows.Next() {
+		if len(resp.Entries) == int(pageSize) {
+			resp.NextPageToken = strconv.FormatInt(lastID, 10)
+			break
+		}
+
+		var entry proto.URLHistoryEntry
+		var changedAt time.Time
+		if err := rows.Scan(&lastID, &entry.Action, &entry.Actor, &entry.ApiKeyId, &entry.OldUrl, &entry.NewUrl, &changedAt); err != nil {
+			return nil, dbError(err, "failed to scan URL history")
+		}
+		entry.ChangedAt = changedAt.Format(time.RFC3339)
+		resp.Entries = append(resp.Entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "failed to get URL history")
+	}
+
+	return resp, nil
+}
+
+// lockURL reads the destination and state of a URL in tx, locking the row
+// on Postgres until the transaction ends. It returns sql.ErrNoRows if the
+// row doesn't exist.
+func lockURL(ctx context.Context, tx dbTx, shortCode string) (originalURL string, active, deleted bool, err error) {
+	err = tx.QueryRowContext(ctx, `
+		SELECT original_url, is_active, deleted_at IS NOT NULL
+		FROM urls
+		WHERE `+keyIs("urls", "$1")+`
+	`+tx.dialect.sql(" FOR UPDATE", ""), shortCode).Scan(&originalURL, &active, &deleted)
+	return originalURL, active, deleted, err
+}
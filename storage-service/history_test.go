@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// asActor returns a context that carries the user and API key url-service
+// names as the author of a change.
+func asActor(user, keyID string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(actorHeader, user, actorKeyIDHeader, keyID))
+}
+
+func TestURLHistoryLifecycle(t *testing.T) {
+	s := newTestServer(t)
+	alice, moderator := asActor("alice", "alice-key"), asActor("moderator", "mod-key")
+
+	if _, err := s.SaveURL(alice, &proto.SaveURLRequest{ShortCode: "life", OriginalUrl: "https://example.com/v1", UserId: "alice"}); err != nil {
+		t.Fatalf("SaveURL: %v", err)
+	}
+	if _, err := s.SaveURL(alice, &proto.SaveURLRequest{ShortCode: "life", OriginalUrl: "https://example.com/v2"}); err != nil {
+		t.Fatalf("SaveURL overwrite: %v", err)
+	}
+	if _, err := s.SetURLStatus(moderator, &proto.SetURLStatusRequest{ShortCode: "life"}); err != nil {
+		t.Fatalf("SetURLStatus: %v", err)
+	}
+	if _, err := s.DeleteURL(alice, &proto.DeleteURLRequest{ShortCode: "life"}); err != nil {
+		t.Fatalf("DeleteURL: %v", err)
+	}
+
+	// Paged one entry at a time, newest first, readable after the delete
+	want := []*proto.URLHistoryEntry{
+		{Action: historyDelete, Actor: "alice", ApiKeyId: "alice-key", OldUrl: "https://example.com/v2"},
+		{Action: historyDisable, Actor: "moderator", ApiKeyId: "mod-key", OldUrl: "https://example.com/v2"},
+		{Action: historyUpdate, Actor: "alice", ApiKeyId: "alice-key", OldUrl: "https://example.com/v1", NewUrl: "https://example.com/v2"},
+	}
+	var got []*proto.URLHistoryEntry
+	token := ""
+	for page := 0; ; page++ {
+		resp, err := s.GetURLHistory(context.Background(), &proto.GetURLHistoryRequest{ShortCode: "life", PageSize: 1, PageToken: token})
+		if err != nil {
+			t.Fatalf("GetURLHistory page %d: %v", page, err)
+		}
+		got = append(got, resp.Entries...)
+		if token = resp.NextPageToken; token == "" {
+			break
+		}
+		if page > len(want) {
+			t.Fatalf("history pages never end")
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("history has %d entries, want %d: %v", len(got), len(want), got)
+	}
+	for i, entry := range got {
+		if entry.ChangedAt == "" {
+			t.Errorf("entry %d has no time", i)
+		}
+		w := want[i]
+		if entry.Action != w.Action || entry.Actor != w.Actor || entry.ApiKeyId != w.ApiKeyId || entry.OldUrl != w.OldUrl {
+			t.Errorf("entry %d = %v, want %v", i, entry, w)
+		}
+		if w.Action == historyUpdate && entry.NewUrl != w.NewUrl {
+			t.Errorf("update entry = %v, want new URL %s", entry, w.NewUrl)
+		}
+	}
+
+	// Another link's history stays its own
+	resp, err := s.GetURLHistory(context.Background(), &proto.GetURLHistoryRequest{ShortCode: "other"})
+	if err != nil || len(resp.Entries) != 0 {
+		t.Errorf("GetURLHistory of an unknown code = %v, %v, want no entries", resp, err)
+	}
+}
+
+func TestURLHistoryRejectsBadRequests(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		req  *proto.GetURLHistoryRequest
+	}{
+		{"no short code", &proto.GetURLHistoryRequest{}},
+		{"malformed token", &proto.GetURLHistoryRequest{ShortCode: "x", PageToken: "next"}},
+		{"negative token", &proto.GetURLHistoryRequest{ShortCode: "x", PageToken: "-4"}},
+	}
+	for _, tt := range tests {
+		if _, err := s.GetURLHistory(ctx, tt.req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: got %v, want InvalidArgument", tt.name, err)
+		}
+	}
+}
+
+func TestURLHistoryWithoutActor(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	saveURL(t, s, &proto.SaveURLRequest{ShortCode: "anon", OriginalUrl: "https://example.com/a"})
+	saveURL(t, s, &proto.SaveURLRequest{ShortCode: "anon", OriginalUrl: "https://example.com/b"})
+	if _, err := s.SetURLStatus(ctx, &proto.SetURLStatusRequest{ShortCode: "anon"}); err != nil {
+		t.Fatalf("SetURLStatus: %v", err)
+	}
+	if _, err := s.SetURLStatus(ctx, &proto.SetURLStatusRequest{ShortCode: "anon", Active: true}); err != nil {
+		t.Fatalf("SetURLStatus: %v", err)
+	}
+
+	resp, err := s.GetURLHistory(ctx, &proto.GetURLHistoryRequest{ShortCode: "anon"})
+	if err != nil {
+		t.Fatalf("GetURLHistory: %v", err)
+	}
+	var actions []string
+	for _, e := range resp.Entries {
+		actions = append(actions, e.Action)
+		if e.Actor != "" || e.ApiKeyId != "" {
+			t.Errorf("%s entry names actor %q and key %q without metadata", e.Action, e.Actor, e.ApiKeyId)
+		}
+	}
+	if !slices.Equal(actions, []string{historyEnable, historyDisable, historyUpdate}) {
+		t.Errorf("history = %v, want enable, disable and update", actions)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// shortCodeIDRange is the id_ranges row url-service draws sequence
+	// codes from
+	shortCodeIDRange = "short_codes"
+
+	maxIDRangeCount = 1000000
+)
+
+// AllocateIDRange reserves count consecutive IDs for the caller alone. The
+// UPDATE locks the counter row until it commits, so replicas allocating at
+// the same time get disjoint ranges. IDs of a range the caller never uses
+// are simply skipped.
+func (s *storageServer) AllocateIDRange(ctx context.Context, req *proto.AllocateIDRangeRequest) (*proto.AllocateIDRangeResponse, error) {
+	logf(ctx, "Storage AllocateIDRange request for %d IDs", req.Count)
+
+	if req.Count <= 0 || req.Count > maxIDRangeCount {
+		return nil, status.Errorf(codes.InvalidArgument, "count must be between 1 and %d", maxIDRangeCount)
+	}
+
+	var end int64
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE id_ranges SET next_id = next_id + $2 WHERE name = $1 RETURNING next_id
+	`, shortCodeIDRange, req.Count).Scan(&end)
+	if err == sql.ErrNoRows {
+		return nil, status.Errorf(codes.FailedPrecondition, "ID range %s is missing", shortCodeIDRange)
+	} else if err != nil {
+		logf(ctx, "Database error: %v", err)
+		return nil, dbError(err, "failed to allocate IDs")
+	}
+
+	logf(ctx, "Allocated IDs [%d, %d)", end-req.Count, end)
+	return &proto.AllocateIDRangeResponse{Start: end - req.Count, End: end}, nil
+}
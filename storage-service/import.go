@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	maxImportRejections = 1000
+	maxImportURLLength  = 2048
+)
+
+// importShortCodePattern accepts the codes the urls table can hold, with
+// the "<tenant>:" prefix of codes outside the default tenant.
+var importShortCodePattern = regexp.MustCompile(`^(?:[a-z0-9-]{1,20}:)?[A-Za-z0-9_-]{1,20}$`)
+
+// importConflicts ends the upsert of a chunk of imported URLs for each
+// conflict policy. Codes that already exist are looked up first, which
+// tells the inserted rows from the overwritten ones.
+var importConflicts = map[string]string{
+	"skip":      `ON CONFLICT (tenant_id, short_code) DO NOTHING RETURNING ` + keyOf("urls"),
+	"fail":      `ON CONFLICT (tenant_id, short_code) DO NOTHING RETURNING ` + keyOf("urls"),
+	"overwrite": importOverwrite + `RETURNING ` + keyOf("urls"),
+}
+
+var importInsert = `
+	INSERT INTO urls (tenant_id, short_code, original_url, created_at, updated_at, click_count, expires_at, user_id, deleted_at)
+	SELECT ` + keyTenant("code") + `, ` + keyCode("code") + `, url, created::timestamptz, created::timestamptz, clicks, NULLIF(expires, '')::timestamptz, NULLIF(owner, ''), NULLIF(deleted, '')::timestamptz
+	FROM unnest($1::text[], $2::text[], $3::text[], $4::bigint[], $5::text[], $6::text[], $7::text[]) AS t(code, url, created, clicks, expires, owner, deleted)
+`
+
+// sqliteImportInsert is importInsert reading JSON arrays. The WHERE lets
+// SQLite parse the ON CONFLICT after a join.
+var sqliteImportInsert = `
+	INSERT INTO urls (tenant_id, short_code, original_url, created_at, updated_at, click_count, expires_at, user_id, deleted_at)
+	SELECT ` + keyTenant("code.value") + `, ` + keyCode("code.value") + `, url.value, created.value, created.value, clicks.value, NULLIF(expires.value, ''), NULLIF(owner.value, ''), NULLIF(deleted.value, '')
+	FROM json_each($1) AS code
+		JOIN json_each($2) AS url ON url.key = code.key
+		JOIN json_each($3) AS created ON created.key = code.key
+		JOIN json_each($4) AS clicks ON clicks.key = code.key
+		JOIN json_each($5) AS expires ON expires.key = code.key
+		JOIN json_each($6) AS owner ON owner.key = code.key
+		JOIN json_each($7) AS deleted ON deleted.key = code.key
+	WHERE true
+`
+
+const importOverwrite = `
+	ON CONFLICT (tenant_id, short_code) DO UPDATE SET
+		original_url = EXCLUDED.original_url,
+		created_at = EXCLUDED.created_at,
+		click_count = EXCLUDED.click_count,
+		expires_at = EXCLUDED.expires_at,
+		user_id = EXCLUDED.user_id,
+		deleted_at = EXCLUDED.deleted_at
+`
+
+// importConflictError stops an import whose policy is to fail on codes
+// that already exist.
+type importConflictError struct {
+	shortCode string
+}
+
+func (e *importConflictError) Error() string {
+	return fmt.Sprintf("short code %s already exists", e.shortCode)
+}
+
+// ImportURLs loads URLs streamed by the client, typically from another
+// shortener or an ExportURLs backup. Valid URLs are written in chunks, each
+// in its own transaction, so an import that fails part way keeps the
+// chunks committed before the failure; invalid ones are reported and
+// skipped. The summary is returned once the client closes the stream.
+func (s *storageServer) ImportURLs(stream proto.StorageService_ImportURLsServer) error {
+	ctx := stream.Context()
+
+	resp := &proto.ImportURLsResponse{}
+	var policy string
+	var chunk []*proto.ExportedURL
+	inChunk := make(map[string]bool)
+	var index int64
+
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		err := s.importChunk(ctx, chunk, policy, resp)
+		chunk = chunk[:0]
+		clear(inChunk)
+		return err
+	}
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			logf(ctx, "Import stream ended after %d inserted and %d updated URLs: %v", resp.Inserted, resp.Updated, err)
+			return err
+		}
+
+		if policy == "" {
+			policy = req.OnConflict
+			if policy == "" {
+				policy = "skip"
+			}
+			if _, ok := importConflicts[policy]; !ok {
+				return status.Errorf(codes.InvalidArgument, "invalid on_conflict %q, want skip, overwrite or fail", req.OnConflict)
+			}
+			resp.DryRun = req.DryRun
+			logf(ctx, "Storage ImportURLs request with on_conflict %s (dry run %t)", policy, resp.DryRun)
+		}
+
+		for _, u := range req.Urls {
+			reason := validateImportedURL(u)
+			if reason == "" && inChunk[u.ShortCode] {
+				reason = "duplicate short code"
+			}
+			if reason != "" {
+				resp.Rejected++
+				if len(resp.Rejections) < maxImportRejections {
+					resp.Rejections = append(resp.Rejections, &proto.ImportRejection{Index: index, ShortCode: u.ShortCode, Reason: reason})
+				}
+				index++
+				continue
+			}
+			index++
+
+			chunk = append(chunk, u)
+			inChunk[u.ShortCode] = true
+			if len(chunk) >= s.batchChunkSize {
+				if err := flush(); err != nil {
+					return importError(ctx, err, resp)
+				}
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return importError(ctx, err, resp)
+	}
+
+	logf(ctx, "Imported URLs: %d inserted, %d updated, %d skipped, %d rejected (dry run %t)",
+		resp.Inserted, resp.Updated, resp.Skipped, resp.Rejected, resp.DryRun)
+	return stream.SendAndClose(resp)
+}
+
+// importError reports a failed import, including how far it got since
+// the committed chunks stay.
+func importError(ctx context.Context, err error, resp *proto.ImportURLsResponse) error {
+	logf(ctx, "Import stopped after %d inserted and %d updated URLs: %v", resp.Inserted, resp.Updated, err)
+
+	var conflict *importConflictError
+	if errors.As(err, &conflict) {
+		return status.Errorf(codes.AlreadyExists, "%v, %d URLs were inserted and %d updated before it", conflict, resp.Inserted, resp.Updated)
+	}
+	return dbError(err, fmt.Sprintf("failed to import URLs after %d inserted and %d updated", resp.Inserted, resp.Updated))
+}
+
+// importChunk writes one chunk in a transaction and adds its outcome to
+// resp. In a dry run it only looks up which codes already exist.
+func (s *storageServer) importChunk(ctx context.Context, chunk []*proto.ExportedURL, policy string, resp *proto.ImportURLsResponse) error {
+	n := len(chunk)
+	shortCodes := make([]string, 0, n)
+	for _, u := range chunk {
+		shortCodes = append(shortCodes, u.ShortCode)
+	}
+
+	if resp.DryRun {
+		existing, err := existingShortCodes(ctx, s.db, s.db.dialect, shortCodes)
+		if err != nil {
+			return err
+		}
+		if policy == "fail" && len(existing) > 0 {
+			return &importConflictError{shortCode: firstCode(shortCodes, func(code string) bool { return existing[code] })}
+		}
+		resp.Inserted += int64(n - len(existing))
+		if policy == "overwrite" {
+			resp.Updated += int64(len(existing))
+		} else {
+			resp.Skipped += int64(len(existing))
+		}
+		return nil
+	}
+
+	originalURLs := make([]string, 0, n)
+	createdAt := make([]string, 0, n)
+	clickCounts := make([]int64, 0, n)
+	expiresAt := make([]string, 0, n)
+	userIDs := make([]string, 0, n)
+	deletedAt := make([]string, 0, n)
+	now := time.Now().Format(time.RFC3339Nano)
+	for _, u := range chunk {
+		created := u.CreatedAt
+		if created == "" {
+			created = now
+		}
+		originalURLs = append(originalURLs, u.OriginalUrl)
+		createdAt = append(createdAt, created)
+		clickCounts = append(clickCounts, u.ClickCount)
+		expiresAt = append(expiresAt, u.ExpiresAt)
+		userIDs = append(userIDs, u.UserId)
+		deletedAt = append(deletedAt, u.DeletedAt)
+	}
+
+	d := s.db.dialect
+	query := d.sql(importInsert, sqliteImportInsert) + importConflicts[policy]
+	var inserted, updated int64
+	err := s.db.inTx(ctx, query, func(ctx context.Context, tx dbTx) error {
+		inserted, updated = 0, 0
+		existing, err := existingShortCodes(ctx, tx, d, shortCodes)
+		if err != nil {
+			return err
+		}
+		// Returning the error rolls the whole chunk back
+		if policy == "fail" && len(existing) > 0 {
+			return &importConflictError{shortCode: firstCode(shortCodes, func(code string) bool { return existing[code] })}
+		}
+
+		rows, err := tx.QueryContext(ctx, query, d.array(shortCodes), d.array(originalURLs), d.timestamps(createdAt),
+			d.array(clickCounts), d.timestamps(expiresAt), d.array(userIDs), d.timestamps(deletedAt))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		written := make(map[string]bool, n)
+		for rows.Next() {
+			var shortCode string
+			if err := rows.Scan(&shortCode); err != nil {
+				return err
+			}
+			written[shortCode] = true
+			if existing[shortCode] {
+				updated++
+			} else {
+				inserted++
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// A code inserted since the lookup
+		if policy == "fail" && len(written) < n {
+			return &importConflictError{shortCode: firstCode(shortCodes, func(code string) bool { return !written[code] })}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	resp.Inserted += inserted
+	resp.Updated += updated
+	resp.Skipped += int64(n) - inserted - updated
+	return nil
+}
+
+// queryer is a tracedDB or a dbTx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// existingShortCodes returns which of shortCodes are already stored.
+func existingShortCodes(ctx context.Context, q queryer, d dialect, shortCodes []string) (map[string]bool, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT `+keyOf("urls")+` FROM urls WHERE `+d.keysIn("urls", "$1")+`
+	`, d.array(shortCodes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var shortCode string
+		if err := rows.Scan(&shortCode); err != nil {
+			return nil, err
+		}
+		existing[shortCode] = true
+	}
+	return existing, rows.Err()
+}
+
+// firstCode returns the first of shortCodes that match reports, or "".
+func firstCode(shortCodes []string, match func(string) bool) string {
+	for _, shortCode := range shortCodes {
+		if match(shortCode) {
+			return shortCode
+		}
+	}
+	return ""
+}
+
+// validateImportedURL returns why u can't be imported, or "" if it can.
+func validateImportedURL(u *proto.ExportedURL) string {
+	if !importShortCodePattern.MatchString(u.ShortCode) {
+		return "short code must be 1 to 20 letters, digits, '-' or '_'"
+	}
+	if tenant, _, ok := strings.Cut(u.ShortCode, ":"); ok != (u.TenantId != "") || ok && tenant != u.TenantId {
+		return "short code must be prefixed by its tenant"
+	}
+	if len(u.OriginalUrl) > maxImportURLLength {
+		return fmt.Sprintf("original URL exceeds %d characters", maxImportURLLength)
+	}
+	parsed, err := url.Parse(u.OriginalUrl)
+	if err != nil {
+		return fmt.Sprintf("invalid original URL: %v", err)
+	}
+	if scheme := strings.ToLower(parsed.Scheme); scheme != "http" && scheme != "https" {
+		return "original URL must be http or https"
+	}
+	if parsed.Host == "" {
+		return "original URL has no host"
+	}
+	if u.ClickCount < 0 {
+		return "click count must not be negative"
+	}
+	for _, field := range []struct{ name, value string }{
+		{"created_at", u.CreatedAt},
+		{"expires_at", u.ExpiresAt},
+		{"deleted_at", u.DeletedAt},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339Nano, field.value); err != nil {
+			return fmt.Sprintf("invalid %s: %v", field.name, err)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// importURLs streams msgs to ImportURLs and returns its summary.
+func importURLs(t *testing.T, client proto.StorageServiceClient, msgs ...*proto.ImportURLsRequest) (*proto.ImportURLsResponse, error) {
+	t.Helper()
+	stream, err := client.ImportURLs(context.Background())
+	if err != nil {
+		t.Fatalf("ImportURLs: %v", err)
+	}
+	for _, msg := range msgs {
+		// A failed import stops reading, its error comes with the summary
+		if err := stream.Send(msg); err != nil {
+			break
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+// exported returns a valid URL to import for each code.
+func exported(codes ...string) []*proto.ExportedURL {
+	var urls []*proto.ExportedURL
+	for _, code := range codes {
+		urls = append(urls, &proto.ExportedURL{ShortCode: code, OriginalUrl: "https://import.example/" + code})
+	}
+	return urls
+}
+
+// storedCodes returns whether each code is stored.
+func storedCodes(t *testing.T, s *storageServer, codes ...string) map[string]bool {
+	t.Helper()
+	existing, err := existingShortCodes(context.Background(), s.db, s.db.dialect, codes)
+	if err != nil {
+		t.Fatalf("looking up codes: %v", err)
+	}
+	return existing
+}
+
+func TestImportURLsPolicies(t *testing.T) {
+	s := newTestServer(t)
+	client := dialBufconn(t, s)
+	saveURL(t, s, &proto.SaveURLRequest{ShortCode: "taken", OriginalUrl: "https://example.com/original"})
+
+	urls := append(exported("i1", "taken", "i2"),
+		&proto.ExportedURL{ShortCode: "bad code!", OriginalUrl: "https://example.com"},
+		&proto.ExportedURL{ShortCode: "ftp", OriginalUrl: "ftp://example.com"},
+		&proto.ExportedURL{ShortCode: "i1", OriginalUrl: "https://example.com/again"},
+	)
+	resp, err := importURLs(t, client, &proto.ImportURLsRequest{Urls: urls[:3]}, &proto.ImportURLsRequest{Urls: urls[3:]})
+	if err != nil {
+		t.Fatalf("skip import: %v", err)
+	}
+	if resp.Inserted != 2 || resp.Skipped != 1 || resp.Rejected != 3 || len(resp.Rejections) != 3 {
+		t.Errorf("skip import = %v, want 2 inserted, 1 skipped and 3 rejected", resp)
+	}
+	for i, r := range resp.Rejections {
+		if want := int64(3 + i); r.Index != want {
+			t.Errorf("rejection %d of %s at index %d, want %d", i, r.ShortCode, r.Index, want)
+		}
+	}
+	if got, _ := s.GetURL(context.Background(), &proto.GetURLRequest{ShortCode: "taken"}); got.OriginalUrl != "https://example.com/original" {
+		t.Errorf("skipped code now points at %s", got.OriginalUrl)
+	}
+
+	resp, err = importURLs(t, client, &proto.ImportURLsRequest{Urls: exported("taken", "i3"), OnConflict: "overwrite"})
+	if err != nil || resp.Inserted != 1 || resp.Updated != 1 {
+		t.Errorf("overwrite import = %v, %v, want 1 inserted and 1 updated", resp, err)
+	}
+	if got, _ := s.GetURL(context.Background(), &proto.GetURLRequest{ShortCode: "taken"}); got.OriginalUrl != "https://import.example/taken" {
+		t.Errorf("overwritten code points at %s", got.OriginalUrl)
+	}
+
+	if _, err := importURLs(t, client, &proto.ImportURLsRequest{OnConflict: "merge"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unknown policy: got %v, want InvalidArgument", err)
+	}
+}
+
+func TestImportURLsFailKeepsCommittedChunks(t *testing.T) {
+	s := newTestServer(t)
+	s.batchChunkSize = 2
+	client := dialBufconn(t, s)
+	saveURL(t, s, &proto.SaveURLRequest{ShortCode: "m5", OriginalUrl: "https://example.com/original"})
+
+	// Chunks m1 m2 and m3 m4 commit, m5 m6 fails on m5, m7 is never read
+	var msgs []*proto.ImportURLsRequest
+	for i := 1; i <= 7; i++ {
+		msgs = append(msgs, &proto.ImportURLsRequest{Urls: exported(fmt.Sprintf("m%d", i)), OnConflict: "fail"})
+	}
+	_, err := importURLs(t, client, msgs...)
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("import: got %v, want AlreadyExists", err)
+	}
+
+	stored := storedCodes(t, s, "m1", "m2", "m3", "m4", "m6", "m7")
+	for _, code := range []string{"m1", "m2", "m3", "m4"} {
+		if !stored[code] {
+			t.Errorf("%s from a committed chunk is missing", code)
+		}
+	}
+	for _, code := range []string{"m6", "m7"} {
+		if stored[code] {
+			t.Errorf("%s imported after the conflict", code)
+		}
+	}
+}
+
+func TestImportURLsDryRun(t *testing.T) {
+	s := newTestServer(t)
+	client := dialBufconn(t, s)
+	saveURL(t, s, &proto.SaveURLRequest{ShortCode: "taken", OriginalUrl: "https://example.com/original"})
+
+	tests := []struct {
+		policy                               string
+		inserted, updated, skipped, rejected int64
+	}{
+		{"skip", 2, 0, 1, 1},
+		{"overwrite", 2, 1, 0, 1},
+	}
+	for _, tt := range tests {
+		urls := append(exported("d1", "taken", "d2"), &proto.ExportedURL{ShortCode: "d3", OriginalUrl: "not a url"})
+		resp, err := importURLs(t, client, &proto.ImportURLsRequest{Urls: urls, DryRun: true, OnConflict: tt.policy})
+		if err != nil {
+			t.Fatalf("%s dry run: %v", tt.policy, err)
+		}
+		if !resp.DryRun || resp.Inserted != tt.inserted || resp.Updated != tt.updated || resp.Skipped != tt.skipped || resp.Rejected != tt.rejected {
+			t.Errorf("%s dry run = %v", tt.policy, resp)
+		}
+	}
+	if _, err := importURLs(t, client, &proto.ImportURLsRequest{Urls: exported("d1", "taken"), DryRun: true, OnConflict: "fail"}); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("fail dry run: got %v, want AlreadyExists", err)
+	}
+
+	if stored := storedCodes(t, s, "d1", "d2"); len(stored) != 0 {
+		t.Errorf("dry runs wrote %v", stored)
+	}
+	if got, _ := s.GetURL(context.Background(), &proto.GetURLRequest{ShortCode: "taken"}); got.OriginalUrl != "https://example.com/original" {
+		t.Errorf("dry run overwrote taken with %s", got.OriginalUrl)
+	}
+}
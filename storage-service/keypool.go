@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"log"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Pool keys look like url-service's random codes.
+const (
+	keyPoolCharset    = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	keyPoolCodeLength = 6
+
+	maxPopKeys = 1000
+
+	defaultKeyPoolRefillInterval = 10 * time.Second
+)
+
+// keyPoolConfig controls the refiller that keeps available_keys stocked.
+type keyPoolConfig struct {
+	size         int // Keys to fill up to
+	lowWatermark int // Refill once fewer keys than this are left
+	interval     time.Duration
+}
+
+// runKeyPool tops the pool up to its size whenever it drops below the low
+// watermark, checking every interval and whenever PopKeys comes up short,
+// until ctx is cancelled.
+func (s *storageServer) runKeyPool(ctx context.Context, config keyPoolConfig) {
+	log.Printf("Key pool refilling to %d keys below %d, checked every %s", config.size, config.lowWatermark, config.interval)
+
+	ticker := time.NewTicker(config.interval)
+	defer ticker.Stop()
+
+	for {
+		s.refillKeyPool(ctx, config)
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Key pool refiller stopped")
+			return
+		case <-ticker.C:
+		case <-s.keyPoolLow:
+		}
+	}
+}
+
+// refillKeyPool generates random codes in chunks and keeps those no URL or
+// pooled key has, until the pool is full again.
+func (s *storageServer) refillKeyPool(ctx context.Context, config keyPoolConfig) {
+	var available int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM available_keys`).Scan(&available); err != nil {
+		log.Printf("Failed to count pooled keys: %v", err)
+		return
+	}
+	s.metrics.keyPoolSize.Set(float64(available))
+	if available >= config.lowWatermark {
+		return
+	}
+
+	// Pooled codes go to any tenant, so none that a tenant has is added
+	d := s.db.dialect
+	query := d.sql(`
+		INSERT INTO available_keys (short_code)
+		SELECT code FROM unnest($1::text[]) AS t(code)
+		WHERE NOT EXISTS (SELECT 1 FROM urls WHERE urls.short_code = t.code)
+		ON CONFLICT (short_code) DO NOTHING
+	`, `
+		INSERT INTO available_keys (short_code)
+		SELECT t.value FROM json_each($1) AS t
+		WHERE NOT EXISTS (SELECT 1 FROM urls WHERE urls.short_code = t.value)
+		ON CONFLICT (short_code) DO NOTHING
+	`)
+
+	added := 0
+	for missing := config.size - available; missing > 0; {
+		keys, err := generateKeys(min(missing, s.batchChunkSize))
+		if err != nil {
+			log.Printf("Failed to generate pool keys: %v", err)
+			break
+		}
+		result, err := s.db.ExecContext(ctx, query, d.array(keys))
+		if err != nil {
+			log.Printf("Failed to refill key pool: %v", err)
+			break
+		}
+		n, _ := result.RowsAffected()
+		if n == 0 {
+			// Every code was taken, which only happens when the code
+			// space is close to full
+			log.Printf("Warning: key pool refill found no unused codes")
+			break
+		}
+		missing -= int(n)
+		added += int(n)
+	}
+
+	s.metrics.keyPoolSize.Set(float64(available + added))
+	log.Printf("Key pool refilled with %d keys (%d available)", added, available+added)
+}
+
+// generateKeys returns n random codes drawn uniformly from keyPoolCharset.
+func generateKeys(n int) ([]string, error) {
+	// Reject bytes above the largest multiple of the charset size to avoid
+	// modulo bias
+	const maxByte = 256 - (256 % len(keyPoolCharset))
+
+	keys := make([]string, 0, n)
+	code := make([]byte, 0, keyPoolCodeLength)
+	buf := make([]byte, n*keyPoolCodeLength*2)
+	for {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		for _, v := range buf {
+			if int(v) >= maxByte {
+				continue
+			}
+			code = append(code, keyPoolCharset[int(v)%len(keyPoolCharset)])
+			if len(code) < keyPoolCodeLength {
+				continue
+			}
+			keys = append(keys, string(code))
+			if len(keys) == n {
+				return keys, nil
+			}
+			code = code[:0]
+		}
+	}
+}
+
+// PopKeys removes up to count keys from the pool and returns them. SKIP
+// LOCKED lets concurrent callers take different keys instead of queueing
+// on the same rows, and the delete commits before any key is returned, so
+// no key is ever handed out twice. Keys a URL has taken since they were
+// pooled, e.g. as a custom alias, are dropped.
+func (s *storageServer) PopKeys(ctx context.Context, req *proto.PopKeysRequest) (*proto.PopKeysResponse, error) {
+	if req.Count <= 0 || req.Count > maxPopKeys {
+		return nil, status.Errorf(codes.InvalidArgument, "count must be between 1 and %d", maxPopKeys)
+	}
+
+	d := s.db.dialect
+	query := `
+		DELETE FROM available_keys
+		WHERE short_code IN (
+			SELECT short_code FROM available_keys LIMIT $1` + d.sql(" FOR UPDATE SKIP LOCKED", "") + `
+		)
+		RETURNING short_code
+	`
+	var keys []string
+	err := s.db.inTx(ctx, query, func(ctx context.Context, tx dbTx) error {
+		keys = keys[:0]
+		rows, err := tx.QueryContext(ctx, query, req.Count)
+		if err != nil {
+			return err
+		}
+		var popped []string
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				rows.Close()
+				return err
+			}
+			popped = append(popped, key)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(popped) == 0 {
+			return nil
+		}
+
+		used, err := existingShortCodes(ctx, tx, d, popped)
+		if err != nil {
+			return err
+		}
+		for _, key := range popped {
+			if !used[key] {
+				keys = append(keys, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logf(ctx, "Failed to pop pooled keys: %v", err)
+		return nil, dbError(err, "failed to pop keys")
+	}
+
+	s.metrics.keyPoolPopped.Add(float64(len(keys)))
+	if len(keys) < int(req.Count) {
+		s.metrics.keyPoolShort.Inc()
+		logf(ctx, "Key pool ran short: %d of %d keys", len(keys), req.Count)
+		// Wake the refiller rather than waiting for its next check
+		select {
+		case s.keyPoolLow <- struct{}{}:
+		default:
+		}
+	}
+	return &proto.PopKeysResponse{ShortCodes: keys}, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+)
+
+func TestPopKeysConcurrent(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		s.refillKeyPool(ctx, keyPoolConfig{size: 500, lowWatermark: 500})
+
+		var mu sync.Mutex
+		seen := make(map[string]int)
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// 20 callers asking for 30 keys each drain the pool
+				resp, err := s.PopKeys(ctx, &proto.PopKeysRequest{Count: 30})
+				if err != nil {
+					t.Errorf("PopKeys: %v", err)
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				for _, key := range resp.ShortCodes {
+					seen[key]++
+				}
+			}()
+		}
+		wg.Wait()
+
+		for key, n := range seen {
+			if n > 1 {
+				t.Errorf("key %s handed out %d times", key, n)
+			}
+		}
+		if len(seen) != 500 {
+			t.Errorf("handed out %d distinct keys, want all 500", len(seen))
+		}
+		resp, err := s.PopKeys(ctx, &proto.PopKeysRequest{Count: 1})
+		if err != nil || len(resp.ShortCodes) != 0 {
+			t.Errorf("PopKeys on an empty pool = %v, %v", resp, err)
+		}
+	})
+}
+
+func TestPopKeysDropsTakenCodes(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		s.refillKeyPool(ctx, keyPoolConfig{size: 10, lowWatermark: 10})
+
+		// A custom alias takes a pooled code after it was generated
+		var taken string
+		if err := s.db.QueryRowContext(ctx, `SELECT short_code FROM available_keys LIMIT 1`).Scan(&taken); err != nil {
+			t.Fatalf("reading a pooled key: %v", err)
+		}
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: taken, OriginalUrl: "https://example.com"})
+
+		resp, err := s.PopKeys(ctx, &proto.PopKeysRequest{Count: 10})
+		if err != nil {
+			t.Fatalf("PopKeys: %v", err)
+		}
+		if len(resp.ShortCodes) != 9 {
+			t.Errorf("PopKeys returned %d keys, want 9", len(resp.ShortCodes))
+		}
+		for _, key := range resp.ShortCodes {
+			if key == taken {
+				t.Errorf("PopKeys returned %s, which a URL has taken", key)
+			}
+		}
+	})
+}
+
+func TestPopKeysCount(t *testing.T) {
+	s := newTestServer(t)
+	for _, count := range []int32{0, -1, maxPopKeys + 1} {
+		if _, err := s.PopKeys(context.Background(), &proto.PopKeysRequest{Count: count}); err == nil {
+			t.Errorf("PopKeys with count %d succeeded", count)
+		}
+	}
+}
+
+func TestGenerateKeys(t *testing.T) {
+	keys, err := generateKeys(1000)
+	if err != nil {
+		t.Fatalf("generateKeys: %v", err)
+	}
+	if len(keys) != 1000 {
+		t.Fatalf("generateKeys returned %d keys, want 1000", len(keys))
+	}
+	for _, key := range keys {
+		if len(key) != keyPoolCodeLength || strings.Trim(key, keyPoolCharset) != "" {
+			t.Errorf("generated key %q is not %d characters of the charset", key, keyPoolCodeLength)
+		}
+	}
+}
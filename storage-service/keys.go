@@ -0,0 +1,36 @@
+package main
+
+// Links are keyed by tenant and code, the primary key of urls and of every
+// table that belongs to a link. The API still names a link by the key
+// url-service holds it under, "<tenant>:<code>" or a bare code in the
+// default tenant, so queries split the keys they are given into the two
+// columns and join the columns back into keys for the codes they return.
+
+// keyTenant is the tenant of the key expr. Tenant IDs can't contain ':', so
+// the tenant is everything before the first one.
+func keyTenant(expr string) string {
+	return "CASE WHEN strpos(" + expr + ", ':') > 0 THEN split_part(" + expr + ", ':', 1) ELSE '' END"
+}
+
+// keyCode is the code of the key expr.
+func keyCode(expr string) string {
+	return "substr(" + expr + ", strpos(" + expr + ", ':') + 1)"
+}
+
+// keyIs matches the rows of table, a table name or alias, keyed by the key
+// expr. It compares the key columns themselves, so lookups use the index.
+func keyIs(table, expr string) string {
+	return "(" + table + ".tenant_id = " + keyTenant(expr) + " AND " + table + ".short_code = " + keyCode(expr) + ")"
+}
+
+// keyOf is the key of the rows of table.
+func keyOf(table string) string {
+	return "CASE WHEN " + table + ".tenant_id = '' THEN " + table + ".short_code ELSE " + table + ".tenant_id || ':' || " + table + ".short_code END"
+}
+
+// keysIn matches the rows of table keyed by one of the keys of an array
+// parameter.
+func (d dialect) keysIn(table, param string) string {
+	keys := d.sql("unnest(CAST("+param+" AS text[])) AS k(value)", "json_each("+param+") AS k")
+	return "(" + table + ".tenant_id, " + table + ".short_code) IN (SELECT " + keyTenant("k.value") + ", " + keyCode("k.value") + " FROM " + keys + ")"
+}
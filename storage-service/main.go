@@ -3,44 +3,155 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	proto "github.com/syedalijabir/protos/storage-service"
 
 	"github.com/gin-gonic/gin"
-	_ "github.com/lib/pq"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
+const maxFindLimit = 100
+
+// maxTopURLs bounds GetTopURLs, which url-service calls at startup to warm
+// its caches.
+const maxTopURLs = 10000
+
 type storageServer struct {
 	proto.UnimplementedStorageServiceServer
-	db *sql.DB
+	db       tracedDB
+	replicas *replicaSet // nil without DB_REPLICA_HOSTS
+	cleanup  cleanupStats
+
+	keyPoolLow chan struct{} // Wakes the key pool refiller
+	metrics    *serviceMetrics
+	outbox     *outbox // nil without OUTBOX_WEBHOOK_URL
+
+	batchChunkSize int // Rows per statement of SaveURLs and BatchIncrementClicks
 }
 
 type Config struct {
+	DBDriver   string
+	SQLitePath string
+
 	Host     string
 	Port     string
 	User     string
 	Password string
 	DBName   string
 	SSLMode  string
+
+	CleanupInterval       time.Duration
+	CleanupBatchSize      int
+	SoftDeleteRetention   time.Duration
+	ClickEventRetention   time.Duration
+	PendingClickRetention time.Duration
+
+	DBMaxOpenConns     int
+	DBMaxIdleConns     int
+	DBConnMaxLifetime  time.Duration
+	DBConnMaxIdleTime  time.Duration
+	DBQueryTimeout     time.Duration
+	DBStatementTimeout time.Duration
+	DBRetryMaxAttempts int
+	DBRetryBaseDelay   time.Duration
+	DBBatchChunkSize   int
+
+	DBReplicaHosts         string
+	DBReplicaCheckInterval time.Duration
+
+	KeyPoolSize           int
+	KeyPoolLowWatermark   int
+	KeyPoolRefillInterval time.Duration
+
+	OutboxWebhookURL   string
+	OutboxPollInterval time.Duration
+	OutboxBatchSize    int
+	OutboxMaxAttempts  int
+	OutboxLease        time.Duration
+	OutboxRetention    time.Duration
+
+	RequestTimeout   time.Duration
+	MigrationTimeout time.Duration
+
+	HTTPPort           string
+	ReadinessInterval  time.Duration
+	UnhealthyThreshold time.Duration
+	ShutdownTimeout    time.Duration
+
+	// EnableReflection registers gRPC reflection for grpcurl, by default
+	// only with INSECURE_DEV_MODE
+	EnableReflection bool
 }
 
 func getConfig() Config {
 	return Config{
+		DBDriver:   getEnv("DB_DRIVER", driverPostgres),
+		SQLitePath: getEnv("SQLITE_PATH", "storage.db"),
+
 		Host:     getEnv("DB_HOST", "postgres"),
 		Port:     getEnv("DB_PORT", "5432"),
 		User:     getEnv("DB_USER", "postgres"),
 		Password: getEnv("DB_PASSWORD", "password"),
 		DBName:   getEnv("DB_NAME", "urlshortener"),
 		SSLMode:  getEnv("DB_SSLMODE", "disable"),
+
+		CleanupInterval:       getEnvDuration("CLEANUP_INTERVAL", time.Hour),
+		CleanupBatchSize:      getEnvInt("CLEANUP_BATCH_SIZE", 1000),
+		SoftDeleteRetention:   getEnvDuration("SOFT_DELETE_RETENTION", 30*24*time.Hour),
+		ClickEventRetention:   getEnvDuration("CLICK_EVENT_RETENTION", 90*24*time.Hour),
+		PendingClickRetention: getEnvDuration("PENDING_CLICK_RETENTION", defaultPendingClickRetention),
+
+		DBMaxOpenConns:     getEnvInt("DB_MAX_OPEN_CONNS", defaultDBMaxOpenConns),
+		DBMaxIdleConns:     getEnvInt("DB_MAX_IDLE_CONNS", defaultDBMaxIdleConns),
+		DBConnMaxLifetime:  getEnvDuration("DB_CONN_MAX_LIFETIME", defaultDBConnMaxLifetime),
+		DBConnMaxIdleTime:  getEnvDuration("DB_CONN_MAX_IDLE_TIME", defaultDBConnMaxIdleTime),
+		DBQueryTimeout:     getEnvDuration("DB_QUERY_TIMEOUT", defaultDBQueryTimeout),
+		DBStatementTimeout: getEnvDuration("DB_STATEMENT_TIMEOUT", defaultDBStatementTimeout),
+		DBRetryMaxAttempts: getEnvInt("DB_RETRY_MAX_ATTEMPTS", defaultDBRetryMaxAttempts),
+		DBRetryBaseDelay:   getEnvDuration("DB_RETRY_BASE_DELAY", defaultDBRetryBaseDelay),
+		DBBatchChunkSize:   getEnvInt("DB_BATCH_CHUNK_SIZE", defaultDBBatchChunkSize),
+
+		DBReplicaHosts:         getEnv("DB_REPLICA_HOSTS", ""),
+		DBReplicaCheckInterval: getEnvDuration("DB_REPLICA_CHECK_INTERVAL", defaultReplicaCheckInterval),
+
+		KeyPoolSize:           getEnvInt("KEY_POOL_SIZE", 0),
+		KeyPoolLowWatermark:   getEnvInt("KEY_POOL_LOW_WATERMARK", 0),
+		KeyPoolRefillInterval: getEnvDuration("KEY_POOL_REFILL_INTERVAL", defaultKeyPoolRefillInterval),
+
+		OutboxWebhookURL:   getEnv("OUTBOX_WEBHOOK_URL", ""),
+		OutboxPollInterval: getEnvDuration("OUTBOX_POLL_INTERVAL", defaultOutboxPollInterval),
+		OutboxBatchSize:    getEnvInt("OUTBOX_BATCH_SIZE", defaultOutboxBatchSize),
+		OutboxMaxAttempts:  getEnvInt("OUTBOX_MAX_ATTEMPTS", defaultOutboxMaxAttempts),
+		OutboxLease:        getEnvDuration("OUTBOX_LEASE", defaultOutboxLease),
+		OutboxRetention:    getEnvDuration("OUTBOX_RETENTION", defaultOutboxRetention),
+
+		RequestTimeout:   getEnvDuration("DEFAULT_REQUEST_TIMEOUT", defaultRequestTimeout),
+		MigrationTimeout: getEnvDuration("MIGRATION_TIMEOUT", defaultMigrationTimeout),
+
+		HTTPPort:           getEnv("HTTP_PORT", "8080"),
+		ReadinessInterval:  getEnvDuration("READINESS_INTERVAL", defaultReadinessInterval),
+		UnhealthyThreshold: getEnvDuration("UNHEALTHY_THRESHOLD", defaultUnhealthyThreshold),
+		ShutdownTimeout:    getEnvDuration("SHUTDOWN_TIMEOUT", defaultShutdownTimeout),
+
+		EnableReflection: getEnvBool("ENABLE_REFLECTION", getEnvBool("INSECURE_DEV_MODE", false)),
 	}
 }
 
@@ -51,11 +162,125 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvInt reads a positive integer from the environment, falling back to
+// the default if it is unset or invalid.
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Warning: invalid %s %q, using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// getEnvBool reads a boolean such as "true" or "1" from the environment,
+// falling back to the default if it is unset or invalid.
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using default %t", key, value, defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+// getEnvDuration reads a positive duration such as "30m" from the
+// environment, falling back to the default if it is unset or invalid.
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Warning: invalid %s %q, using default %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
 func NewStorageServer() (*storageServer, error) {
 	config := getConfig()
+	d := dialect{driver: config.DBDriver}
+
+	var db *sql.DB
+	var err error
+	switch config.DBDriver {
+	case driverPostgres:
+		db, err = openPostgres(config)
+	case driverSQLite:
+		db, err = openSQLite(config.SQLitePath)
+		if err != nil {
+			err = fmt.Errorf("failed to open SQLite database %s: %w", config.SQLitePath, err)
+		}
+	default:
+		err = fmt.Errorf("unknown DB_DRIVER %q, want %s or %s", config.DBDriver, driverPostgres, driverSQLite)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	migrateCtx, cancel := context.WithTimeout(context.Background(), config.MigrationTimeout)
+	defer cancel()
+	if err := migrate(migrateCtx, db, d); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
 
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		config.Host, config.Port, config.User, config.Password, config.DBName, config.SSLMode)
+	primary := tracedDB{
+		DB:               db,
+		dialect:          d,
+		queryTimeout:     config.DBQueryTimeout,
+		retryMaxAttempts: config.DBRetryMaxAttempts,
+		retryBaseDelay:   config.DBRetryBaseDelay,
+	}
+	var replicas *replicaSet
+	if config.DBReplicaHosts != "" {
+		if d.sqlite() {
+			log.Printf("Warning: DB_REPLICA_HOSTS is ignored with DB_DRIVER=%s", config.DBDriver)
+		} else if replicas, err = openReplicas(config, primary); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to open replicas: %w", err)
+		}
+	}
+
+	log.Printf("%s storage initialized successfully", d.sql("PostgreSQL", "SQLite"))
+	metrics := newServiceMetrics()
+	return &storageServer{
+		db:         primary,
+		replicas:   replicas,
+		keyPoolLow: make(chan struct{}, 1),
+		metrics:    metrics,
+		outbox: newOutbox(outboxConfig{
+			webhookURL:   config.OutboxWebhookURL,
+			pollInterval: config.OutboxPollInterval,
+			batchSize:    config.OutboxBatchSize,
+			maxAttempts:  config.OutboxMaxAttempts,
+			lease:        config.OutboxLease,
+			retention:    config.OutboxRetention,
+		}, metrics),
+		batchChunkSize: config.DBBatchChunkSize,
+	}, nil
+}
+
+// postgresConnString builds the connection string for one server.
+func postgresConnString(config Config, host, port string) string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s statement_timeout=%d",
+		host, port, config.User, config.Password, config.DBName, config.SSLMode,
+		config.DBStatementTimeout.Milliseconds())
+}
+
+// openPostgres connects to PostgreSQL, waiting for it to come up.
+func openPostgres(config Config) (*sql.DB, error) {
+	connStr := postgresConnString(config, config.Host, config.Port)
 
 	// Wait for PostgreSQL to be ready
 	var db *sql.DB
@@ -79,133 +304,1136 @@ func NewStorageServer() (*storageServer, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to PostgreSQL after retries: %v", err)
 	}
-
-	log.Println("PostgreSQL storage initialized successfully")
-	return &storageServer{db: db}, nil
+	configurePool(db, config)
+	return db, nil
 }
 
 func (s *storageServer) SaveURL(ctx context.Context, req *proto.SaveURLRequest) (*proto.SaveURLResponse, error) {
-	log.Printf("Storage SaveURL request for: %s -> %s", req.ShortCode, req.OriginalUrl)
+	logf(ctx, "Storage SaveURL request for: %s -> %s", req.ShortCode, req.OriginalUrl)
+
+	expiresAt, err := parseOptionalTime(req.ExpiresAt)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid expires_at: %v", err)
+	}
+	notBefore, err := parseOptionalTime(req.NotBefore)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid not_before: %v", err)
+	}
+	if req.MaxClicks < 0 {
+		return nil, status.Error(codes.InvalidArgument, "max_clicks must not be negative")
+	}
+	if err := validateVariants(req.Variants); err != nil {
+		return nil, err
+	}
+	if err := validateRules(req.Rules); err != nil {
+		return nil, err
+	}
+	if err := validateTags(req.Tags); err != nil {
+		return nil, err
+	}
 
-	// Use UPSERT (INSERT ON CONFLICT) to handle duplicates
-	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO urls (short_code, original_url, created_at, updated_at) 
-		VALUES ($1, $2, $3, $3)
-		ON CONFLICT (short_code) 
+	// Use UPSERT (INSERT ON CONFLICT) to handle duplicates. When an expected
+	// URL is given the existing row is only overwritten if it still matches,
+	// and with create_only a live row never is, which leaves creating a code
+	// to whichever save inserts it first. A deleted row is only replaced
+	// when resurrecting, and then starts over
+	// as if it had just been inserted. Variants, rules and the query template
+	// are replaced along with the destination, tags only when asked to.
+	query := `
+		INSERT INTO urls (tenant_id, short_code, original_url, created_at, updated_at, expires_at, api_key_id, user_id, max_clicks, fallback_url, not_before, coming_soon_url, sticky_variants, query_template) 
+		VALUES (` + keyTenant("$1") + `, ` + keyCode("$1") + `, $2, $3, $3, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($9, 0), NULLIF($10, ''), $11, NULLIF($12, ''), $13, NULLIF($14, ''))
+		ON CONFLICT (tenant_id, short_code) 
 		DO UPDATE SET 
 			original_url = EXCLUDED.original_url,
-			updated_at = EXCLUDED.updated_at
-	`, req.ShortCode, req.OriginalUrl, time.Now())
+			sticky_variants = EXCLUDED.sticky_variants,
+			query_template = EXCLUDED.query_template,
+			updated_at = EXCLUDED.updated_at,
+			expires_at = CASE WHEN urls.deleted_at IS NULL THEN COALESCE(EXCLUDED.expires_at, urls.expires_at) ELSE EXCLUDED.expires_at END,
+			click_count = CASE WHEN urls.deleted_at IS NULL THEN urls.click_count ELSE 0 END,
+			unique_clicks = CASE WHEN urls.deleted_at IS NULL THEN urls.unique_clicks ELSE 0 END,
+			bot_clicks = CASE WHEN urls.deleted_at IS NULL THEN urls.bot_clicks ELSE 0 END,
+			created_at = CASE WHEN urls.deleted_at IS NULL THEN urls.created_at ELSE EXCLUDED.created_at END,
+			api_key_id = CASE WHEN urls.deleted_at IS NULL THEN urls.api_key_id ELSE EXCLUDED.api_key_id END,
+			user_id = CASE WHEN urls.deleted_at IS NULL THEN urls.user_id ELSE EXCLUDED.user_id END,
+			max_clicks = CASE WHEN urls.deleted_at IS NULL THEN urls.max_clicks ELSE EXCLUDED.max_clicks END,
+			fallback_url = CASE WHEN urls.deleted_at IS NULL THEN urls.fallback_url ELSE EXCLUDED.fallback_url END,
+			not_before = CASE WHEN urls.deleted_at IS NULL THEN urls.not_before ELSE EXCLUDED.not_before END,
+			coming_soon_url = CASE WHEN urls.deleted_at IS NULL THEN urls.coming_soon_url ELSE EXCLUDED.coming_soon_url END,
+			is_active = CASE WHEN urls.deleted_at IS NULL THEN urls.is_active ELSE true END,
+			deleted_at = NULL
+		WHERE CASE WHEN urls.deleted_at IS NULL THEN NOT $15 AND ($4 = '' OR urls.original_url = $4) ELSE $8 END
+	`
+	// The existing row is read and locked first for its history entry
+	var saved, deleted bool
+	err = s.db.inTx(ctx, query, func(ctx context.Context, tx dbTx) error {
+		oldURL, _, wasDeleted, err := lockURL(ctx, tx, req.ShortCode)
+		existed := err == nil
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		deleted = wasDeleted
 
+		result, err := tx.ExecContext(ctx, query, req.ShortCode, req.OriginalUrl, time.Now(), req.ExpectedOriginalUrl, expiresAt, req.ApiKeyId, req.UserId, req.Resurrect,
+			req.MaxClicks, req.FallbackUrl, notBefore, req.ComingSoonUrl, req.StickyVariants && len(req.Variants) > 0, req.QueryTemplate, req.CreateOnly)
+		if err != nil {
+			return err
+		}
+		rowsAffected, _ := result.RowsAffected()
+		saved = rowsAffected > 0
+		if !saved {
+			return nil
+		}
+		if existed || len(req.Variants) > 0 {
+			if err := saveVariants(ctx, tx, req.ShortCode, req.Variants); err != nil {
+				return err
+			}
+		}
+		if existed || len(req.Rules) > 0 {
+			if err := saveRules(ctx, tx, req.ShortCode, req.Rules); err != nil {
+				return err
+			}
+		}
+		if req.SetTags || deleted || (!existed && len(req.Tags) > 0) {
+			if err := saveTags(ctx, tx, req.ShortCode, req.Tags); err != nil {
+				return err
+			}
+		}
+		// Clicks can be flushed before the link they are for is saved
+		if !existed {
+			if _, err := applyPendingClicks(ctx, tx, s.db.dialect, []string{req.ShortCode}); err != nil {
+				return err
+			}
+		}
+		event := outboxURLUpdated
+		if !existed || deleted {
+			event = outboxURLCreated
+		}
+		if err := s.outbox.enqueue(ctx, tx, event, req.ShortCode, req.OriginalUrl); err != nil {
+			return err
+		}
+		switch {
+		case !existed:
+			return nil
+		case deleted:
+			return recordHistory(ctx, tx, req.ShortCode, historyRecreate, oldURL, req.OriginalUrl)
+		case oldURL != req.OriginalUrl:
+			return recordHistory(ctx, tx, req.ShortCode, historyUpdate, oldURL, req.OriginalUrl)
+		}
+		return nil
+	})
 	if err != nil {
-		log.Printf("Failed to save URL to PostgreSQL: %v", err)
-		return &proto.SaveURLResponse{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
+		logf(ctx, "Failed to save URL to PostgreSQL: %v", err)
+		return nil, dbError(err, "failed to save URL")
+	}
+
+	if !saved {
+		if deleted {
+			logf(ctx, "URL %s was deleted and resurrect is not set", req.ShortCode)
+			return nil, status.Errorf(codes.AlreadyExists, "URL %s was deleted", req.ShortCode)
+		}
+		if req.CreateOnly {
+			logf(ctx, "URL %s already exists", req.ShortCode)
+			return nil, status.Errorf(codes.AlreadyExists, "URL %s already exists", req.ShortCode)
+		}
+		logf(ctx, "URL %s changed concurrently, expected %s", req.ShortCode, req.ExpectedOriginalUrl)
+		return nil, status.Errorf(codes.FailedPrecondition, "URL %s no longer points at the expected destination", req.ShortCode)
 	}
 
-	log.Printf("URL saved successfully to PostgreSQL: %s", req.ShortCode)
+	logf(ctx, "URL saved successfully to PostgreSQL: %s", req.ShortCode)
 	return &proto.SaveURLResponse{
 		Success: true,
 	}, nil
 }
 
+// SaveURLs inserts new URLs, one multi-row statement and transaction per
+// chunk. Codes that already exist are left untouched and missing from the
+// response, so the caller can pick new ones. A failed chunk doesn't stop
+// the others; its codes are reported as failed.
+func (s *storageServer) SaveURLs(ctx context.Context, req *proto.SaveURLsRequest) (*proto.SaveURLsResponse, error) {
+	logf(ctx, "Storage SaveURLs request for %d URLs", len(req.Urls))
+
+	for _, u := range req.Urls {
+		if _, err := parseOptionalTime(u.ExpiresAt); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid expires_at for %s: %v", u.ShortCode, err)
+		}
+	}
+
+	resp := &proto.SaveURLsResponse{}
+	var lastErr error
+	for start := 0; start < len(req.Urls); start += s.batchChunkSize {
+		chunk := req.Urls[start:min(start+s.batchChunkSize, len(req.Urls))]
+		inserted, err := s.saveURLChunk(ctx, chunk)
+		if err != nil {
+			logf(ctx, "Failed to save chunk of %d URLs to PostgreSQL: %v", len(chunk), err)
+			lastErr = err
+			// Once the deadline has passed later chunks fail the same way
+			if ctx.Err() != nil {
+				chunk = req.Urls[start:]
+			}
+			for _, u := range chunk {
+				resp.FailedShortCodes = append(resp.FailedShortCodes, u.ShortCode)
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		resp.InsertedShortCodes = append(resp.InsertedShortCodes, inserted...)
+	}
+	if len(req.Urls) > 0 && len(resp.FailedShortCodes) == len(req.Urls) {
+		return nil, dbError(lastErr, "failed to save URLs")
+	}
+
+	logf(ctx, "Saved %d of %d URLs to PostgreSQL (%d failed)", len(resp.InsertedShortCodes), len(req.Urls), len(resp.FailedShortCodes))
+	return resp, nil
+}
+
+// saveURLChunk inserts urls in one statement and returns the codes inserted.
+func (s *storageServer) saveURLChunk(ctx context.Context, urls []*proto.SaveURLRequest) ([]string, error) {
+	n := len(urls)
+	shortCodes := make([]string, 0, n)
+	originalURLs := make([]string, 0, n)
+	expiresAt := make([]string, 0, n)
+	apiKeyIDs := make([]string, 0, n)
+	userIDs := make([]string, 0, n)
+	originalURLByCode := make(map[string]string, n)
+	for _, u := range urls {
+		originalURLByCode[u.ShortCode] = u.OriginalUrl
+		shortCodes = append(shortCodes, u.ShortCode)
+		originalURLs = append(originalURLs, u.OriginalUrl)
+		expiresAt = append(expiresAt, u.ExpiresAt)
+		apiKeyIDs = append(apiKeyIDs, u.ApiKeyId)
+		userIDs = append(userIDs, u.UserId)
+	}
+
+	// SQLite reads the arrays as JSON, and needs the WHERE to parse
+	// ON CONFLICT after a join
+	query := s.db.dialect.sql(`
+		INSERT INTO urls (tenant_id, short_code, original_url, created_at, updated_at, expires_at, api_key_id, user_id)
+		SELECT `+keyTenant("code")+`, `+keyCode("code")+`, url, $6, $6, NULLIF(expires, '')::timestamptz, NULLIF(key_id, ''), NULLIF(owner, '')
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[]) AS t(code, url, expires, key_id, owner)
+		ON CONFLICT (tenant_id, short_code) DO NOTHING
+		RETURNING `+keyOf("urls")+`
+	`, `
+		INSERT INTO urls (tenant_id, short_code, original_url, created_at, updated_at, expires_at, api_key_id, user_id)
+		SELECT `+keyTenant("code.value")+`, `+keyCode("code.value")+`, url.value, $6, $6, NULLIF(expires.value, ''), NULLIF(key_id.value, ''), NULLIF(owner.value, '')
+		FROM json_each($1) AS code
+			JOIN json_each($2) AS url ON url.key = code.key
+			JOIN json_each($3) AS expires ON expires.key = code.key
+			JOIN json_each($4) AS key_id ON key_id.key = code.key
+			JOIN json_each($5) AS owner ON owner.key = code.key
+		WHERE true
+		ON CONFLICT (tenant_id, short_code) DO NOTHING
+		RETURNING `+keyOf("urls")+`
+	`)
+	d := s.db.dialect
+	var inserted []string
+	err := s.db.inTx(ctx, query, func(ctx context.Context, tx dbTx) error {
+		inserted = inserted[:0]
+		rows, err := tx.QueryContext(ctx, query, d.array(shortCodes), d.array(originalURLs), d.timestamps(expiresAt), d.array(apiKeyIDs), d.array(userIDs), time.Now())
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var shortCode string
+			if err := rows.Scan(&shortCode); err != nil {
+				rows.Close()
+				return err
+			}
+			inserted = append(inserted, shortCode)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// The events and held clicks are written once the rows are read,
+		// SQLite can't do both at once
+		if len(inserted) > 0 {
+			if _, err := applyPendingClicks(ctx, tx, d, inserted); err != nil {
+				return err
+			}
+		}
+		for _, shortCode := range inserted {
+			if err := s.outbox.enqueue(ctx, tx, outboxURLCreated, shortCode, originalURLByCode[shortCode]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return inserted, err
+}
+
 func (s *storageServer) GetURL(ctx context.Context, req *proto.GetURLRequest) (*proto.GetURLResponse, error) {
-	log.Printf("Storage GetURL request for: %s", req.ShortCode)
+	logf(ctx, "Storage GetURL request for: %s", req.ShortCode)
 
 	var originalURL string
-	var clickCount int64
+	var clickCount, maxClicks int64
 	var createdAt time.Time
+	var expiresAt, notBefore sql.NullTime
+	var userID, comingSoonURL, fallbackURL, queryTemplate sql.NullString
+	var disabled, sticky, deleted bool
 
-	err := s.db.QueryRowContext(ctx, `
-		SELECT original_url, click_count, created_at 
+	// Expired and deleted URLs are treated as not found unless asked for.
+	// URLs that aren't active yet are returned, url-service enforces
+	// not_before.
+	db := s.reader(req.ForcePrimary)
+	err := db.QueryRowContext(ctx, `
+		SELECT original_url, click_count, created_at, expires_at, user_id, COALESCE(max_clicks, 0),
+			not_before, coming_soon_url, fallback_url, NOT is_active, sticky_variants, query_template, deleted_at IS NOT NULL
 		FROM urls 
-		WHERE short_code = $1
-	`, req.ShortCode).Scan(&originalURL, &clickCount, &createdAt)
+		WHERE `+keyIs("urls", "$1")+`
+			AND ($3 OR deleted_at IS NULL)
+			AND ($2 OR expires_at IS NULL OR expires_at > NOW())
+	`, req.ShortCode, req.IncludeExpired, req.IncludeDeleted).Scan(&originalURL, &clickCount, &createdAt, &expiresAt, &userID, &maxClicks,
+		&notBefore, &comingSoonURL, &fallbackURL, &disabled, &sticky, &queryTemplate, &deleted)
 
 	if err == sql.ErrNoRows {
-		log.Printf("URL not found in PostgreSQL: %s", req.ShortCode)
-		return &proto.GetURLResponse{
-			Found: false,
-		}, nil
+		logf(ctx, "URL not found in PostgreSQL: %s", req.ShortCode)
+		return nil, status.Errorf(codes.NotFound, "URL not found: %s", req.ShortCode)
 	} else if err != nil {
-		log.Printf("PostgreSQL error: %v", err)
-		return &proto.GetURLResponse{
-			Found: false,
-			Error: err.Error(),
-		}, nil
+		logf(ctx, "PostgreSQL error: %v", err)
+		return nil, dbError(err, "failed to get URL")
+	}
+	variants, err := loadVariants(ctx, db, []string{req.ShortCode})
+	if err != nil {
+		logf(ctx, "PostgreSQL error: %v", err)
+		return nil, dbError(err, "failed to get URL")
+	}
+	rules, err := loadRules(ctx, db, []string{req.ShortCode})
+	if err != nil {
+		logf(ctx, "PostgreSQL error: %v", err)
+		return nil, dbError(err, "failed to get URL")
 	}
 
-	log.Printf("URL found in PostgreSQL: %s -> %s", req.ShortCode, originalURL)
+	logf(ctx, "URL found in PostgreSQL: %s -> %s", req.ShortCode, originalURL)
 	return &proto.GetURLResponse{
-		OriginalUrl: originalURL,
-		Found:       true,
+		OriginalUrl:    originalURL,
+		Found:          true,
+		ExpiresAt:      formatOptionalTime(expiresAt),
+		ClickCount:     clickCount,
+		CreatedAt:      createdAt.Format(time.RFC3339),
+		UserId:         userID.String,
+		MaxClicks:      maxClicks,
+		NotBefore:      formatOptionalTime(notBefore),
+		ComingSoonUrl:  comingSoonURL.String,
+		FallbackUrl:    fallbackURL.String,
+		Disabled:       disabled,
+		Variants:       variants[req.ShortCode],
+		StickyVariants: sticky,
+		Rules:          rules[req.ShortCode],
+		QueryTemplate:  queryTemplate.String,
+		Deleted:        deleted,
 	}, nil
 }
 
-func (s *storageServer) IncrementClick(ctx context.Context, req *proto.IncrementClickRequest) (*proto.IncrementClickResponse, error) {
-	log.Printf("Storage IncrementClick request for: %s", req.ShortCode)
+// GetURLs looks up several short codes with a single query.
+func (s *storageServer) GetURLs(ctx context.Context, req *proto.GetURLsRequest) (*proto.GetURLsResponse, error) {
+	logf(ctx, "Storage GetURLs request for %d codes", len(req.ShortCodes))
 
-	result, err := s.db.ExecContext(ctx, `
-		UPDATE urls 
-		SET click_count = click_count + 1, updated_at = $1
-		WHERE short_code = $2
-	`, time.Now(), req.ShortCode)
+	resp := &proto.GetURLsResponse{Urls: make(map[string]*proto.GetURLResponse)}
+	if len(req.ShortCodes) == 0 {
+		return resp, nil
+	}
 
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+keyOf("urls")+`, original_url, click_count, created_at, expires_at, user_id, NOT is_active, sticky_variants, query_template
+		FROM urls
+		WHERE `+s.db.dialect.keysIn("urls", "$1")+`
+			AND deleted_at IS NULL
+			AND ($2 OR expires_at IS NULL OR expires_at > NOW())
+	`, s.db.dialect.array(req.ShortCodes), req.IncludeExpired)
 	if err != nil {
-		log.Printf("Failed to increment click count: %v", err)
-		return &proto.IncrementClickResponse{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
+		logf(ctx, "PostgreSQL error: %v", err)
+		return nil, dbError(err, "failed to get URLs")
 	}
+	defer rows.Close()
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return &proto.IncrementClickResponse{
-			Success: false,
-			Error:   "URL not found",
-		}, nil
+	for rows.Next() {
+		var shortCode, originalURL string
+		var clickCount int64
+		var createdAt time.Time
+		var expiresAt sql.NullTime
+		var userID, queryTemplate sql.NullString
+		var disabled, sticky bool
+		if err := rows.Scan(&shortCode, &originalURL, &clickCount, &createdAt, &expiresAt, &userID, &disabled, &sticky, &queryTemplate); err != nil {
+			return nil, dbError(err, "failed to scan URL")
+		}
+		resp.Urls[shortCode] = &proto.GetURLResponse{
+			OriginalUrl:    originalURL,
+			Found:          true,
+			ExpiresAt:      formatOptionalTime(expiresAt),
+			ClickCount:     clickCount,
+			CreatedAt:      createdAt.Format(time.RFC3339),
+			UserId:         userID.String,
+			Disabled:       disabled,
+			StickyVariants: sticky,
+			QueryTemplate:  queryTemplate.String,
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "failed to get URLs")
 	}
 
-	log.Printf("Click count incremented in PostgreSQL for %s", req.ShortCode)
+	variants, err := loadVariants(ctx, s.db, req.ShortCodes)
+	if err != nil {
+		logf(ctx, "PostgreSQL error: %v", err)
+		return nil, dbError(err, "failed to get URLs")
+	}
+	for shortCode, v := range variants {
+		if u, ok := resp.Urls[shortCode]; ok {
+			u.Variants = v
+		}
+	}
+	rules, err := loadRules(ctx, s.db, req.ShortCodes)
+	if err != nil {
+		logf(ctx, "PostgreSQL error: %v", err)
+		return nil, dbError(err, "failed to get URLs")
+	}
+	for shortCode, r := range rules {
+		if u, ok := resp.Urls[shortCode]; ok {
+			u.Rules = r
+		}
+	}
+
+	logf(ctx, "Found %d of %d URLs in PostgreSQL", len(resp.Urls), len(req.ShortCodes))
+	return resp, nil
+}
+
+func (s *storageServer) IncrementClick(ctx context.Context, req *proto.IncrementClickRequest) (*proto.IncrementClickResponse, error) {
+	logf(ctx, "Storage IncrementClick request for: %s", req.ShortCode)
+
+	shortCodes := []string{req.ShortCode}
+	delta := clickDelta{clicks: 1, lastAccessed: sql.NullTime{Time: time.Now(), Valid: true}}
+	updated, buffered, err := s.incrementClickChunk(ctx, shortCodes, map[string]clickDelta{req.ShortCode: delta})
+	if err != nil {
+		logf(ctx, "Failed to increment click count: %v", err)
+		return nil, dbError(err, "failed to increment click count")
+	}
+
+	switch {
+	case buffered[req.ShortCode]:
+		logf(ctx, "Click held for %s until it is saved", req.ShortCode)
+	case !updated[req.ShortCode]:
+		return nil, status.Errorf(codes.NotFound, "URL not found: %s", req.ShortCode)
+	default:
+		logf(ctx, "Click count incremented in PostgreSQL for %s", req.ShortCode)
+	}
 	return &proto.IncrementClickResponse{
 		Success: true,
 	}, nil
 }
 
+// BatchIncrementClicks applies per-code click deltas, one statement and
+// transaction per chunk. Deltas for codes not saved yet are held until they
+// are, see pendingclicks.go. A failed chunk doesn't stop the others; its
+// codes are reported as failed so the caller can retry just those.
+func (s *storageServer) BatchIncrementClicks(ctx context.Context, req *proto.BatchIncrementClicksRequest) (*proto.BatchIncrementClicksResponse, error) {
+	logf(ctx, "Storage BatchIncrementClicks request for %d codes", len(req.Deltas))
+
+	if len(req.Deltas) == 0 {
+		return &proto.BatchIncrementClicksResponse{}, nil
+	}
+
+	// A code listed twice would only be updated once by UPDATE ... FROM, so
+	// merge duplicates. Sorting makes concurrent batches lock rows in the
+	// same order, so they can't deadlock.
+	merged := make(map[string]clickDelta, len(req.Deltas))
+	for _, d := range req.Deltas {
+		lastAccessed, err := parseOptionalTime(d.LastAccessedAt)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid last_accessed_at for %s: %v", d.ShortCode, err)
+		}
+		m := merged[d.ShortCode]
+		m.clicks += d.Delta
+		m.unique += d.UniqueDelta
+		m.bot += d.BotDelta
+		if lastAccessed.Valid && (!m.lastAccessed.Valid || lastAccessed.Time.After(m.lastAccessed.Time)) {
+			m.lastAccessed = lastAccessed
+		}
+		merged[d.ShortCode] = m
+	}
+	shortCodes := make([]string, 0, len(merged))
+	for shortCode := range merged {
+		shortCodes = append(shortCodes, shortCode)
+	}
+	sort.Strings(shortCodes)
+
+	resp := &proto.BatchIncrementClicksResponse{}
+	var lastErr error
+	for start := 0; start < len(shortCodes); start += s.batchChunkSize {
+		chunk := shortCodes[start:min(start+s.batchChunkSize, len(shortCodes))]
+		updated, buffered, err := s.incrementClickChunk(ctx, chunk, merged)
+		if err != nil {
+			logf(ctx, "Failed to increment click counts for a chunk of %d codes: %v", len(chunk), err)
+			lastErr = err
+			// Once the deadline has passed later chunks fail the same way
+			if ctx.Err() != nil {
+				resp.FailedShortCodes = append(resp.FailedShortCodes, shortCodes[start:]...)
+				break
+			}
+			resp.FailedShortCodes = append(resp.FailedShortCodes, chunk...)
+			continue
+		}
+
+		resp.Updated += int64(len(updated))
+		for _, shortCode := range chunk {
+			switch {
+			case buffered[shortCode]:
+				resp.BufferedShortCodes = append(resp.BufferedShortCodes, shortCode)
+			case !updated[shortCode]:
+				resp.MissingShortCodes = append(resp.MissingShortCodes, shortCode)
+			}
+		}
+	}
+	if len(resp.FailedShortCodes) == len(shortCodes) {
+		return nil, dbError(lastErr, "failed to increment click counts")
+	}
+
+	logf(ctx, "Click counts incremented in PostgreSQL for %d codes (%d held until saved, %d failed)", resp.Updated, len(resp.BufferedShortCodes), len(resp.FailedShortCodes))
+	return resp, nil
+}
+
+// clickDelta is what a batch adds to a URL's click counters, and the time
+// of the latest click among them.
+type clickDelta struct {
+	clicks, unique, bot int64
+	lastAccessed        sql.NullTime
+}
+
+// laterOf is the later of two nullable timestamp expressions.
+func laterOf(a, b string) string {
+	return `CASE WHEN ` + b + ` IS NULL OR ` + a + ` >= ` + b + ` THEN ` + a + ` ELSE ` + b + ` END`
+}
+
+// incrementClickChunk applies the deltas of shortCodes in one statement and
+// returns the codes that exist, then holds the deltas of those that were
+// never saved in the same transaction and returns those too.
+func (s *storageServer) incrementClickChunk(ctx context.Context, shortCodes []string, deltas map[string]clickDelta) (updated, buffered map[string]bool, err error) {
+	from, args := s.clickDeltaValues(shortCodes, deltas)
+	query := `
+		UPDATE urls
+		SET click_count = urls.click_count + v.delta,
+			unique_clicks = urls.unique_clicks + v.unique_delta,
+			bot_clicks = urls.bot_clicks + v.bot_delta,
+			last_accessed_at = ` + laterOf("urls.last_accessed_at", "v.last_accessed") + `,
+			updated_at = NOW()
+		FROM ` + from + `
+		WHERE ` + keyIs("urls", "v.short_code") + ` AND urls.deleted_at IS NULL
+		RETURNING ` + keyOf("urls") + `
+	`
+	err = s.db.inTx(ctx, query, func(ctx context.Context, tx dbTx) error {
+		updated = make(map[string]bool, len(shortCodes))
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var shortCode string
+			if err := rows.Scan(&shortCode); err != nil {
+				rows.Close()
+				return err
+			}
+			updated[shortCode] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		var missing []string
+		for _, shortCode := range shortCodes {
+			if !updated[shortCode] {
+				missing = append(missing, shortCode)
+			}
+		}
+		buffered, err = s.bufferClicks(ctx, tx, missing, deltas)
+		return err
+	})
+	return updated, buffered, err
+}
+
+// clickDeltaValues renders the deltas of shortCodes as a table v of
+// short_code, delta, unique_delta, bot_delta and last_accessed to select
+// from, and its arguments.
+func (s *storageServer) clickDeltaValues(shortCodes []string, deltas map[string]clickDelta) (string, []interface{}) {
+	// Postgres needs the types of the VALUES, SQLite can't name their
+	// columns in the alias
+	value := s.db.dialect.sql("($%d::varchar, $%d::bigint, $%d::bigint, $%d::bigint, $%d::timestamptz)", "($%d, $%d, $%d, $%d, $%d)")
+	values := make([]string, 0, len(shortCodes))
+	args := make([]interface{}, 0, len(shortCodes)*5)
+	for i, shortCode := range shortCodes {
+		values = append(values, fmt.Sprintf(value, i*5+1, i*5+2, i*5+3, i*5+4, i*5+5))
+		d := deltas[shortCode]
+		args = append(args, shortCode, d.clicks, d.unique, d.bot, d.lastAccessed)
+	}
+	from := s.db.dialect.sql(
+		`(VALUES `+strings.Join(values, ", ")+`) AS v(short_code, delta, unique_delta, bot_delta, last_accessed)`,
+		`(SELECT column1 AS short_code, column2 AS delta, column3 AS unique_delta, column4 AS bot_delta, column5 AS last_accessed FROM (VALUES `+strings.Join(values, ", ")+`)) AS v`,
+	)
+	return from, args
+}
+
 func (s *storageServer) GetStats(ctx context.Context, req *proto.GetStatsRequest) (*proto.GetStatsResponse, error) {
-	log.Printf("Storage GetStats request for: %s", req.ShortCode)
+	logf(ctx, "Storage GetStats request for: %s", req.ShortCode)
 
-	var originalURL string
-	var clickCount int64
+	var originalURL, title, description, imageURL string
+	var clickCount, uniqueClicks, botClicks int64
 	var createdAt time.Time
+	var expiresAt, deletedAt, lastAccessedAt sql.NullTime
 
-	err := s.db.QueryRowContext(ctx, `
-		SELECT original_url, click_count, created_at 
+	// Stats stay available after expiry so historical clicks can be seen,
+	// and after deletion when asked for
+	err := s.reader(req.ForcePrimary).QueryRowContext(ctx, `
+		SELECT original_url, click_count, unique_clicks, bot_clicks, created_at, expires_at, deleted_at, last_accessed_at,
+			COALESCE(title, ''), COALESCE(description, ''), COALESCE(image_url, '')
 		FROM urls 
-		WHERE short_code = $1
-	`, req.ShortCode).Scan(&originalURL, &clickCount, &createdAt)
+		WHERE `+keyIs("urls", "$1")+`
+			AND ($2 OR deleted_at IS NULL)
+	`, req.ShortCode, req.IncludeDeleted).Scan(&originalURL, &clickCount, &uniqueClicks, &botClicks, &createdAt, &expiresAt, &deletedAt, &lastAccessedAt,
+		&title, &description, &imageURL)
 
 	if err == sql.ErrNoRows {
-		return &proto.GetStatsResponse{
-			Error: "URL not found",
-		}, nil
+		return nil, status.Errorf(codes.NotFound, "URL not found: %s", req.ShortCode)
 	} else if err != nil {
-		return &proto.GetStatsResponse{
-			Error: err.Error(),
-		}, nil
+		logf(ctx, "PostgreSQL error: %v", err)
+		return nil, dbError(err, "failed to get stats")
 	}
 
 	return &proto.GetStatsResponse{
-		ShortCode:  req.ShortCode,
-		ClickCount: clickCount,
-		CreatedAt:  createdAt.Format(time.RFC3339),
+		ShortCode:      req.ShortCode,
+		ClickCount:     clickCount,
+		UniqueClicks:   uniqueClicks,
+		BotClicks:      botClicks,
+		CreatedAt:      createdAt.Format(time.RFC3339),
+		ExpiresAt:      formatOptionalTime(expiresAt),
+		DeletedAt:      formatOptionalTime(deletedAt),
+		LastAccessedAt: formatOptionalTime(lastAccessedAt),
+		Title:          title,
+		Description:    description,
+		ImageUrl:       imageURL,
+	}, nil
+}
+
+// SetURLStatus turns a URL off or back on. A disabled URL keeps its row
+// and history; url-service stops resolving it.
+func (s *storageServer) SetURLStatus(ctx context.Context, req *proto.SetURLStatusRequest) (*proto.SetURLStatusResponse, error) {
+	logf(ctx, "Storage SetURLStatus request for %s, active %t", req.ShortCode, req.Active)
+
+	query := `
+		UPDATE urls
+		SET is_active = $2, updated_at = NOW()
+		WHERE ` + keyIs("urls", "$1") + `
+	`
+	err := s.db.inTx(ctx, query, func(ctx context.Context, tx dbTx) error {
+		originalURL, active, deleted, err := lockURL(ctx, tx, req.ShortCode)
+		if err == sql.ErrNoRows || deleted {
+			return status.Errorf(codes.NotFound, "URL not found: %s", req.ShortCode)
+		} else if err != nil {
+			return err
+		}
+		if active == req.Active {
+			return nil
+		}
+
+		if _, err := tx.ExecContext(ctx, query, req.ShortCode, req.Active); err != nil {
+			return err
+		}
+		action, event := historyDisable, outboxURLDisabled
+		if req.Active {
+			action, event = historyEnable, outboxURLEnabled
+		}
+		if err := s.outbox.enqueue(ctx, tx, event, req.ShortCode, originalURL); err != nil {
+			return err
+		}
+		return recordHistory(ctx, tx, req.ShortCode, action, originalURL, originalURL)
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil, err
+	} else if err != nil {
+		logf(ctx, "Failed to set URL status: %v", err)
+		return nil, dbError(err, "failed to set URL status")
+	}
+	return &proto.SetURLStatusResponse{Active: req.Active}, nil
+}
+
+// DeleteURL soft deletes a URL. It stops resolving at once but keeps its
+// history until purged after the retention period.
+func (s *storageServer) DeleteURL(ctx context.Context, req *proto.DeleteURLRequest) (*proto.DeleteURLResponse, error) {
+	logf(ctx, "Storage DeleteURL request for: %s", req.ShortCode)
+
+	query := `
+		UPDATE urls
+		SET deleted_at = NOW()
+		WHERE ` + keyIs("urls", "$1") + ` AND deleted_at IS NULL
+		RETURNING original_url
+	`
+	err := s.db.inTx(ctx, query, func(ctx context.Context, tx dbTx) error {
+		var originalURL string
+		if err := tx.QueryRowContext(ctx, query, req.ShortCode).Scan(&originalURL); err != nil {
+			return err
+		}
+		if err := s.outbox.enqueue(ctx, tx, outboxURLDeleted, req.ShortCode, originalURL); err != nil {
+			return err
+		}
+		return recordHistory(ctx, tx, req.ShortCode, historyDelete, originalURL, "")
+	})
+	if err == sql.ErrNoRows {
+		return nil, status.Errorf(codes.NotFound, "URL not found: %s", req.ShortCode)
+	} else if err != nil {
+		logf(ctx, "Failed to delete URL from PostgreSQL: %v", err)
+		return nil, dbError(err, "failed to delete URL")
+	}
+
+	logf(ctx, "URL deleted from PostgreSQL: %s", req.ShortCode)
+	return &proto.DeleteURLResponse{
+		Success: true,
 	}, nil
 }
 
+// PurgeURL removes a URL's row, deleted or not, and with it its click
+// events, rather than waiting for cleanup to purge it. Its history is kept.
+// Purging a URL that is already gone is not an error, so a partly failed
+// takedown can be retried.
+func (s *storageServer) PurgeURL(ctx context.Context, req *proto.PurgeURLRequest) (*proto.PurgeURLResponse, error) {
+	logf(ctx, "Storage PurgeURL request for: %s", req.ShortCode)
+
+	if req.ShortCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "short_code is required")
+	}
+
+	query := `
+		DELETE FROM urls
+		WHERE ` + keyIs("urls", "$1") + `
+		RETURNING original_url
+	`
+	resp := &proto.PurgeURLResponse{}
+	err := s.db.inTx(ctx, query, func(ctx context.Context, tx dbTx) error {
+		resp.Purged = false
+		var originalURL string
+		err := tx.QueryRowContext(ctx, query, req.ShortCode).Scan(&originalURL)
+		if err == sql.ErrNoRows {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		resp.Purged = true
+		if err := s.outbox.enqueue(ctx, tx, outboxURLPurged, req.ShortCode, originalURL); err != nil {
+			return err
+		}
+		return recordHistory(ctx, tx, req.ShortCode, historyPurge, originalURL, "")
+	})
+	if err != nil {
+		logf(ctx, "Failed to purge URL: %v", err)
+		return nil, dbError(err, "failed to purge URL")
+	}
+
+	logf(ctx, "URL %s purged: %t", req.ShortCode, resp.Purged)
+	return resp, nil
+}
+
+// FindByOriginalURL returns the unexpired short codes for an exact original
+// URL, or for every URL on a host and its subdomains, oldest first. Pages
+// are keyed on (created_at, short_code) like ListURLs.
+func (s *storageServer) FindByOriginalURL(ctx context.Context, req *proto.FindByOriginalURLRequest) (*proto.FindByOriginalURLResponse, error) {
+	logf(ctx, "Storage FindByOriginalURL %s request for: %s", req.Match, req.OriginalUrl)
+
+	if req.OriginalUrl == "" {
+		return nil, status.Error(codes.InvalidArgument, "original_url is required")
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > maxFindLimit {
+		limit = maxFindLimit
+	}
+
+	// The zero cursor sorts before every row
+	afterCreated, afterCode := time.Time{}, ""
+	if req.PageToken != "" {
+		var err error
+		afterCreated, afterCode, err = decodePageToken(req.PageToken)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid page_token: %v", err)
+		}
+	}
+
+	// Both filters are backed by an index, exact matches recheck the URL
+	// since md5 can collide
+	var filter string
+	args := []interface{}{afterCreated, afterCode, limit + 1}
+	switch req.Match {
+	case "", "exact":
+		filter = "md5(original_url) = md5($4) AND original_url = $4"
+		args = append(args, req.OriginalUrl)
+	case "host":
+		reversed := reverseString(strings.ToLower(strings.TrimSuffix(req.OriginalUrl, ".")))
+		filter = fmt.Sprintf(`(%[1]s = $4 OR %[1]s LIKE $5 ESCAPE '\')`, s.db.dialect.originalHost())
+		args = append(args, reversed, escapeLike(reversed)+".%")
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid match %q, want exact or host", req.Match)
+	}
+	args = append(args, req.TenantId)
+	filter += fmt.Sprintf(" AND tenant_id = $%d", len(args))
+	if req.UnlimitedOnly {
+		filter += " AND max_clicks IS NULL"
+	}
+	if req.ActiveOnly {
+		filter += " AND is_active"
+	}
+	if req.PlainOnly {
+		filter += ` AND query_template IS NULL AND fallback_url IS NULL
+			AND NOT EXISTS (SELECT 1 FROM url_variants WHERE url_variants.tenant_id = urls.tenant_id AND url_variants.short_code = urls.short_code)
+			AND NOT EXISTS (SELECT 1 FROM url_redirect_rules WHERE url_redirect_rules.tenant_id = urls.tenant_id AND url_redirect_rules.short_code = urls.short_code)`
+	}
+
+	// Fetch one extra row to learn whether there is a next page
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+keyOf("urls")+`, created_at
+		FROM urls
+		WHERE `+filter+`
+			AND deleted_at IS NULL
+			AND (expires_at IS NULL OR expires_at > NOW())
+			AND (created_at, short_code) > ($1, `+keyCode("$2")+`)
+		ORDER BY created_at, short_code
+		LIMIT $3
+	`, args...)
+	if err != nil {
+		logf(ctx, "PostgreSQL error: %v", err)
+		return nil, dbError(err, "failed to find URL")
+	}
+	defer rows.Close()
+
+	resp := &proto.FindByOriginalURLResponse{}
+	var lastCreated time.Time
+	for rows.Next() {
+		if len(resp.ShortCodes) == int(limit) {
+			resp.NextPageToken = encodePageToken(lastCreated, resp.ShortCodes[len(resp.ShortCodes)-1])
+			break
+		}
+
+		var shortCode string
+		if err := rows.Scan(&shortCode, &lastCreated); err != nil {
+			return nil, dbError(err, "failed to scan short code")
+		}
+		resp.ShortCodes = append(resp.ShortCodes, shortCode)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "failed to find URL")
+	}
+
+	return resp, nil
+}
+
+// ListURLs returns a page of the user's URLs, newest first. Pages are keyed
+// on (created_at, short_code) rather than offsets so they stay stable while
+// the user creates links.
+func (s *storageServer) ListURLs(ctx context.Context, req *proto.ListURLsRequest) (*proto.ListURLsResponse, error) {
+	logf(ctx, "Storage ListURLs request for user: %s", req.UserId)
+
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 || pageSize > maxFindLimit {
+		pageSize = maxFindLimit
+	}
+	if err := validateTags(req.Tags); err != nil {
+		return nil, err
+	}
+
+	// The zero cursor sorts after every row
+	afterCreated, afterCode := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC), ""
+	if req.PageToken != "" {
+		var err error
+		afterCreated, afterCode, err = decodePageToken(req.PageToken)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid page_token: %v", err)
+		}
+	}
+
+	// URLs with all the tags are those with as many matching tag rows, a
+	// tag being stored once per URL
+	query := `
+		SELECT ` + keyOf("urls") + `, original_url, click_count, created_at, expires_at, COALESCE(max_clicks, 0), not_before, NOT is_active,
+			EXISTS (SELECT 1 FROM url_variants WHERE url_variants.tenant_id = urls.tenant_id AND url_variants.short_code = urls.short_code),
+			EXISTS (SELECT 1 FROM url_redirect_rules WHERE url_redirect_rules.tenant_id = urls.tenant_id AND url_redirect_rules.short_code = urls.short_code),
+			COALESCE(query_template, ''), last_accessed_at, COALESCE(title, ''), COALESCE(description, ''), COALESCE(image_url, '')
+		FROM urls
+		WHERE user_id = $1
+			AND tenant_id = $5
+			AND deleted_at IS NULL
+			AND (created_at, short_code) < ($2, ` + keyCode("$3") + `)`
+	args := []interface{}{req.UserId, afterCreated, afterCode, pageSize + 1, req.TenantId}
+	if len(req.Tags) > 0 {
+		query += `
+			AND short_code IN (
+				SELECT short_code FROM url_tags
+				WHERE tenant_id = $5 AND ` + s.db.dialect.anyOf("tag", "$6") + `
+				GROUP BY short_code
+				HAVING COUNT(*) = $7
+			)`
+		args = append(args, s.db.dialect.array(req.Tags), len(req.Tags))
+	}
+
+	// Fetch one extra row to learn whether there is a next page
+	rows, err := s.db.QueryContext(ctx, query+`
+		ORDER BY created_at DESC, short_code DESC
+		LIMIT $4
+	`, args...)
+	if err != nil {
+		logf(ctx, "PostgreSQL error: %v", err)
+		return nil, dbError(err, "failed to list URLs")
+	}
+	defer rows.Close()
+
+	resp := &proto.ListURLsResponse{}
+	var lastCreated time.Time
+	for rows.Next() {
+		if len(resp.Urls) == int(pageSize) {
+			last := resp.Urls[len(resp.Urls)-1]
+			resp.NextPageToken = encodePageToken(lastCreated, last.ShortCode)
+			break
+		}
+
+		var summary proto.URLSummary
+		var createdAt time.Time
+		var expiresAt, notBefore, lastAccessedAt sql.NullTime
+		if err := rows.Scan(&summary.ShortCode, &summary.OriginalUrl, &summary.ClickCount, &createdAt, &expiresAt, &summary.MaxClicks, &notBefore, &summary.Disabled, &summary.Split, &summary.Conditional, &summary.QueryTemplate, &lastAccessedAt,
+			&summary.Title, &summary.Description, &summary.ImageUrl); err != nil {
+			return nil, dbError(err, "failed to scan URL")
+		}
+		summary.CreatedAt = createdAt.Format(time.RFC3339)
+		summary.ExpiresAt = formatOptionalTime(expiresAt)
+		summary.NotBefore = formatOptionalTime(notBefore)
+		summary.LastAccessedAt = formatOptionalTime(lastAccessedAt)
+		resp.Urls = append(resp.Urls, &summary)
+		lastCreated = createdAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "failed to list URLs")
+	}
+
+	rows.Close()
+
+	if len(resp.Urls) > 0 {
+		shortCodes := make([]string, len(resp.Urls))
+		for i, u := range resp.Urls {
+			shortCodes[i] = u.ShortCode
+		}
+		tags, err := loadTags(ctx, s.db, shortCodes)
+		if err != nil {
+			logf(ctx, "PostgreSQL error: %v", err)
+			return nil, dbError(err, "failed to list URLs")
+		}
+		for _, u := range resp.Urls {
+			u.Tags = tags[u.ShortCode]
+		}
+	}
+	return resp, nil
+}
+
+// GetTopURLs returns the most clicked or most recently updated unexpired
+// URLs. With since, URLs are ranked by their click events since then.
+func (s *storageServer) GetTopURLs(ctx context.Context, req *proto.GetTopURLsRequest) (*proto.GetTopURLsResponse, error) {
+	logf(ctx, "Storage GetTopURLs request for %d URLs by %q since %q", req.Limit, req.OrderBy, req.Since)
+
+	limit := req.Limit
+	if limit <= 0 || limit > maxTopURLs {
+		limit = maxTopURLs
+	}
+
+	// Both orders are backed by an index, including the short code that
+	// breaks ties
+	var orderBy string
+	switch req.OrderBy {
+	case "", "clicks":
+		orderBy = "click_count DESC, short_code"
+	case "recent":
+		orderBy = "updated_at DESC, short_code"
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid order_by %q, want clicks or recent", req.OrderBy)
+	}
+
+	query := `
+		SELECT ` + keyOf("urls") + `, original_url, click_count, created_at, expires_at, COALESCE(max_clicks, 0), not_before, NOT is_active,
+			EXISTS (SELECT 1 FROM url_variants WHERE url_variants.tenant_id = urls.tenant_id AND url_variants.short_code = urls.short_code),
+			EXISTS (SELECT 1 FROM url_redirect_rules WHERE url_redirect_rules.tenant_id = urls.tenant_id AND url_redirect_rules.short_code = urls.short_code),
+			COALESCE(query_template, '')
+		FROM urls
+		WHERE tenant_id = $2
+			AND deleted_at IS NULL
+			AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY ` + orderBy + `
+		LIMIT $1
+	`
+	args := []interface{}{limit, req.TenantId}
+	if req.Since != "" {
+		if req.OrderBy == "recent" {
+			return nil, status.Error(codes.InvalidArgument, "since can only be used with order_by clicks")
+		}
+		since, err := time.Parse(time.RFC3339, req.Since)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid since: %v", err)
+		}
+
+		// Only the events in range are read, through their clicked_at index
+		query = `
+			SELECT ` + keyOf("urls") + `, urls.original_url, c.clicks, urls.created_at, urls.expires_at, COALESCE(urls.max_clicks, 0), urls.not_before, NOT urls.is_active,
+				EXISTS (SELECT 1 FROM url_variants WHERE url_variants.tenant_id = urls.tenant_id AND url_variants.short_code = urls.short_code),
+				EXISTS (SELECT 1 FROM url_redirect_rules WHERE url_redirect_rules.tenant_id = urls.tenant_id AND url_redirect_rules.short_code = urls.short_code),
+				COALESCE(urls.query_template, '')
+			FROM (
+				SELECT tenant_id, short_code, COUNT(*) AS clicks
+				FROM url_clicks
+				WHERE clicked_at >= $3
+				GROUP BY tenant_id, short_code
+			) AS c
+			JOIN urls ON urls.tenant_id = c.tenant_id AND urls.short_code = c.short_code
+			WHERE urls.tenant_id = $2
+				AND urls.deleted_at IS NULL
+				AND (urls.expires_at IS NULL OR urls.expires_at > NOW())
+			ORDER BY c.clicks DESC, urls.short_code
+			LIMIT $1
+		`
+		args = append(args, since)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logf(ctx, "PostgreSQL error: %v", err)
+		return nil, dbError(err, "failed to get top URLs")
+	}
+	defer rows.Close()
+
+	resp := &proto.GetTopURLsResponse{}
+	for rows.Next() {
+		var summary proto.URLSummary
+		var createdAt time.Time
+		var expiresAt, notBefore sql.NullTime
+		if err := rows.Scan(&summary.ShortCode, &summary.OriginalUrl, &summary.ClickCount, &createdAt, &expiresAt, &summary.MaxClicks, &notBefore, &summary.Disabled, &summary.Split, &summary.Conditional, &summary.QueryTemplate); err != nil {
+			return nil, dbError(err, "failed to scan URL")
+		}
+		summary.CreatedAt = createdAt.Format(time.RFC3339)
+		summary.ExpiresAt = formatOptionalTime(expiresAt)
+		summary.NotBefore = formatOptionalTime(notBefore)
+		resp.Urls = append(resp.Urls, &summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "failed to get top URLs")
+	}
+
+	return resp, nil
+}
+
+// CountURLs returns how many unexpired URLs the user owns.
+func (s *storageServer) CountURLs(ctx context.Context, req *proto.CountURLsRequest) (*proto.CountURLsResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	var active int64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM urls
+		WHERE user_id = $1
+			AND tenant_id = $2
+			AND deleted_at IS NULL
+			AND (expires_at IS NULL OR expires_at > NOW())
+	`, req.UserId, req.TenantId).Scan(&active)
+	if err != nil {
+		logf(ctx, "PostgreSQL error: %v", err)
+		return nil, dbError(err, "failed to count URLs")
+	}
+
+	return &proto.CountURLsResponse{Active: active}, nil
+}
+
+// GetGlobalStats summarizes the tenant's links. Every count is answered from
+// an index: the totals by index-only scans, and the rest from the created_at,
+// deleted_at and expires_at indexes, since deleted and expired rows are few.
+func (s *storageServer) GetGlobalStats(ctx context.Context, req *proto.GetGlobalStatsRequest) (*proto.GetGlobalStatsResponse, error) {
+	var resp proto.GetGlobalStatsResponse
+	var deleted, expired int64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM urls WHERE tenant_id = $2),
+			(SELECT COALESCE(SUM(click_count), 0) FROM urls WHERE tenant_id = $2),
+			(SELECT COUNT(*) FROM urls WHERE tenant_id = $2 AND created_at >= $1),
+			(SELECT COUNT(*) FROM urls WHERE tenant_id = $2 AND deleted_at IS NOT NULL),
+			(SELECT COUNT(*) FROM urls WHERE tenant_id = $2 AND expires_at IS NOT NULL AND expires_at <= NOW() AND deleted_at IS NULL)
+	`, time.Now().Add(-24*time.Hour), req.TenantId).Scan(&resp.TotalUrls, &resp.TotalClicks, &resp.CreatedLastDay, &deleted, &expired)
+	if err != nil {
+		logf(ctx, "PostgreSQL error: %v", err)
+		return nil, dbError(err, "failed to get global stats")
+	}
+	resp.ActiveUrls = resp.TotalUrls - deleted - expired
+
+	return &resp, nil
+}
+
+// encodePageToken builds an opaque cursor pointing after the given row.
+// Timestamps keep full precision so rows created in the same second aren't
+// skipped.
+func encodePageToken(createdAt time.Time, shortCode string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(createdAt.Format(time.RFC3339Nano) + "|" + shortCode))
+}
+
+func decodePageToken(token string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	created, shortCode, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, "", fmt.Errorf("malformed cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, created)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	return createdAt, shortCode, nil
+}
+
+// reverseString reverses s by rune, like Postgres reverse().
+func reverseString(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// escapeLike escapes the LIKE wildcards in s for use with ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// parseOptionalTime parses an RFC3339 timestamp where the empty string
+// means NULL.
+func parseOptionalTime(value string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return sql.NullTime{Time: t, Valid: true}, nil
+}
+
+// formatOptionalTime formats a nullable timestamp as RFC3339, or "" for NULL.
+func formatOptionalTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format(time.RFC3339)
+}
+
 func (s *storageServer) Close() error {
+	if s.replicas != nil {
+		if err := s.replicas.Close(); err != nil {
+			log.Printf("Warning: failed to close replicas: %v", err)
+		}
+	}
 	return s.db.Close()
 }
 
@@ -222,24 +1450,105 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create storage server: %v", err)
 	}
-	defer storageServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := getConfig()
+
+	shutdownTracing, err := setupTracing(ctx, "storage-service")
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+
+	go storageServer.runCleanup(ctx, cleanupConfig{
+		interval:              config.CleanupInterval,
+		batchSize:             config.CleanupBatchSize,
+		deletedRetention:      config.SoftDeleteRetention,
+		clickRetention:        config.ClickEventRetention,
+		pendingClickRetention: config.PendingClickRetention,
+	})
+	go storageServer.metrics.pollDBStats(ctx, storageServer.db.DB, dbStatsInterval)
+	if storageServer.replicas != nil {
+		go storageServer.replicas.watch(ctx, config.DBReplicaCheckInterval, storageServer.metrics)
+	}
+	if config.KeyPoolSize > 0 {
+		// Refill at half empty unless told otherwise
+		lowWatermark := config.KeyPoolLowWatermark
+		if lowWatermark == 0 || lowWatermark > config.KeyPoolSize {
+			lowWatermark = config.KeyPoolSize / 2
+		}
+		go storageServer.runKeyPool(ctx, keyPoolConfig{
+			size:         config.KeyPoolSize,
+			lowWatermark: lowWatermark,
+			interval:     config.KeyPoolRefillInterval,
+		})
+	}
+	if storageServer.outbox != nil {
+		go storageServer.runOutbox(ctx)
+	}
 
 	lis, err := net.Listen("tcp", ":50053")
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	server := grpc.NewServer()
+	server := grpc.NewServer(
+		grpc.StatsHandler(serverTracing()),
+		grpc.ChainUnaryInterceptor(
+			requestIDInterceptor(),
+			storageServer.metrics.UnaryServerInterceptor(),
+			recoveryInterceptor(),
+			deadlineInterceptor(config.RequestTimeout),
+		),
+		// Streams such as ExportURLs run for as long as the client reads, so
+		// they get no default deadline
+		grpc.ChainStreamInterceptor(
+			requestIDStreamInterceptor(),
+			storageServer.metrics.StreamServerInterceptor(),
+			recoveryStreamInterceptor(),
+		),
+		// Allow url-service's keepalive pings on idle connections
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             10 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	)
 	proto.RegisterStorageServiceServer(server, storageServer)
+	if config.EnableReflection {
+		reflection.Register(server)
+	}
 
 	// Register health service
 	healthServer := health.NewServer()
 	grpc_health_v1.RegisterHealthServer(server, healthServer)
-	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
-	healthServer.SetServingStatus("url.URLService", grpc_health_v1.HealthCheckResponse_SERVING)
+	go watchReadiness(ctx, healthServer, []string{"", proto.StorageService_ServiceDesc.ServiceName},
+		storageServer.db.PingContext, config.ReadinessInterval, config.UnhealthyThreshold)
+
+	log.Printf("Storage Service with %s starting on :50053 (gRPC reflection enabled: %t)", storageServer.db.dialect.sql("PostgreSQL", "SQLite"), config.EnableReflection)
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			log.Fatalf("failed to serve: %v", err)
+		}
+	}()
+
+	httpServer := newHealthHTTPServer(config.HTTPPort, storageServer)
+	go func() {
+		log.Printf("Health endpoints listening on %s", httpServer.Addr)
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("failed to serve health endpoints: %v", err)
+		}
+	}()
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-sigCtx.Done()
+
+	shutdown(server, httpServer, healthServer, storageServer, cancel, config.ShutdownTimeout)
 
-	log.Printf("Storage Service with PostgreSQL starting on :50053")
-	if err := server.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	tracingCtx, tracingCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer tracingCancel()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		log.Printf("Warning: failed to flush traces: %v", err)
 	}
 }
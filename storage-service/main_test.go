@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestServer returns a storage server on a fresh SQLite database.
+func newTestServer(t *testing.T) *storageServer {
+	t.Helper()
+	t.Setenv("DB_DRIVER", driverSQLite)
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "storage.db"))
+	s, err := NewStorageServer()
+	if err != nil {
+		t.Fatalf("NewStorageServer: %v", err)
+	}
+	t.Cleanup(func() { s.db.Close() })
+	return s
+}
+
+func TestSaveURLCreateOnlyKeepsLiveURL(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "alias1", OriginalUrl: "https://alice.example", UserId: "alice", Resurrect: true, CreateOnly: true})
+	if err != nil {
+		t.Fatalf("first save: %v", err)
+	}
+	_, err = s.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "alias1", OriginalUrl: "https://mallory.example", UserId: "mallory", Resurrect: true, CreateOnly: true})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("second save: got %v, want AlreadyExists", err)
+	}
+
+	resp, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "alias1"})
+	if err != nil {
+		t.Fatalf("GetURL: %v", err)
+	}
+	if resp.OriginalUrl != "https://alice.example" || resp.UserId != "alice" {
+		t.Errorf("GetURL = %s for %s, want https://alice.example for alice", resp.OriginalUrl, resp.UserId)
+	}
+}
+
+func TestSaveURLCreateOnlyResurrectsDeletedURL(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	if _, err := s.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "gone", OriginalUrl: "https://old.example", UserId: "alice", CreateOnly: true}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := s.DeleteURL(ctx, &proto.DeleteURLRequest{ShortCode: "gone"}); err != nil {
+		t.Fatalf("DeleteURL: %v", err)
+	}
+
+	_, err := s.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "gone", OriginalUrl: "https://new.example", UserId: "bob", CreateOnly: true})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("save without resurrect: got %v, want AlreadyExists", err)
+	}
+	if _, err := s.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "gone", OriginalUrl: "https://new.example", UserId: "bob", Resurrect: true, CreateOnly: true}); err != nil {
+		t.Fatalf("resurrect: %v", err)
+	}
+	resp, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "gone"})
+	if err != nil {
+		t.Fatalf("GetURL: %v", err)
+	}
+	if resp.OriginalUrl != "https://new.example" || resp.UserId != "bob" {
+		t.Errorf("GetURL = %s for %s, want https://new.example for bob", resp.OriginalUrl, resp.UserId)
+	}
+}
+
+func TestSaveURLUpdatesLiveURL(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	if _, err := s.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "moving", OriginalUrl: "https://a.example", UserId: "alice", CreateOnly: true}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	_, err := s.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "moving", OriginalUrl: "https://c.example", ExpectedOriginalUrl: "https://b.example"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("update with stale expectation: got %v, want FailedPrecondition", err)
+	}
+	if _, err := s.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "moving", OriginalUrl: "https://b.example", ExpectedOriginalUrl: "https://a.example"}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	resp, err := s.GetURL(ctx, &proto.GetURLRequest{ShortCode: "moving"})
+	if err != nil {
+		t.Fatalf("GetURL: %v", err)
+	}
+	if resp.OriginalUrl != "https://b.example" || resp.UserId != "alice" {
+		t.Errorf("GetURL = %s for %s, want https://b.example for alice", resp.OriginalUrl, resp.UserId)
+	}
+}
+
+// dialBufconn serves s over an in-memory listener until the test ends and
+// returns a client of it.
+func dialBufconn(t *testing.T, s *storageServer) proto.StorageServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	proto.RegisterStorageServiceServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial bufconn: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return proto.NewStorageServiceClient(conn)
+}
+
+func TestStatusCodes(t *testing.T) {
+	s := newTestServer(t)
+	client := dialBufconn(t, s)
+	ctx := context.Background()
+	if _, err := client.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "taken", OriginalUrl: "https://alice.example", CreateOnly: true}); err != nil {
+		t.Fatalf("SaveURL: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"unknown code", func() error {
+			_, err := client.GetURL(ctx, &proto.GetURLRequest{ShortCode: "missing"})
+			return err
+		}, codes.NotFound},
+		{"known code", func() error {
+			_, err := client.GetURL(ctx, &proto.GetURLRequest{ShortCode: "taken"})
+			return err
+		}, codes.OK},
+		{"stats of unknown code", func() error {
+			_, err := client.GetStats(ctx, &proto.GetStatsRequest{ShortCode: "missing"})
+			return err
+		}, codes.NotFound},
+		{"delete unknown code", func() error {
+			_, err := client.DeleteURL(ctx, &proto.DeleteURLRequest{ShortCode: "missing"})
+			return err
+		}, codes.NotFound},
+		{"code taken", func() error {
+			_, err := client.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "taken", OriginalUrl: "https://bob.example", CreateOnly: true})
+			return err
+		}, codes.AlreadyExists},
+	}
+	for _, tt := range tests {
+		if err := tt.call(); status.Code(err) != tt.want {
+			t.Errorf("%s: got %v, want %s", tt.name, err, tt.want)
+		}
+	}
+
+	// Other database errors are internal
+	s.db.Close()
+	if _, err := client.GetURL(ctx, &proto.GetURLRequest{ShortCode: "taken"}); status.Code(err) != codes.Internal {
+		t.Errorf("GetURL on a closed database: got %v, want Internal", err)
+	}
+}
+
+func TestSaveURLUpdateKeepsCreatedAt(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "moving", OriginalUrl: "https://a.example", UserId: "alice"})
+		times := func() (created, updated time.Time) {
+			t.Helper()
+			if err := s.db.QueryRow(`SELECT created_at, updated_at FROM urls WHERE short_code = $1`, "moving").Scan(&created, &updated); err != nil {
+				t.Fatalf("reading timestamps: %v", err)
+			}
+			return created, updated
+		}
+		created, updated := times()
+
+		time.Sleep(20 * time.Millisecond)
+		if _, err := s.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "moving", OriginalUrl: "https://b.example", ExpectedOriginalUrl: "https://a.example"}); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		createdAfter, updatedAfter := times()
+		if !createdAfter.Equal(created) || !updatedAfter.After(updated) {
+			t.Errorf("update moved created_at %s to %s and updated_at %s to %s, want created_at kept and updated_at bumped",
+				created, createdAfter, updated, updatedAfter)
+		}
+	})
+}
+
+func TestConfigReflection(t *testing.T) {
+	tests := []struct {
+		name       string
+		devMode    string
+		reflection string
+		want       bool
+	}{
+		{"default", "", "", false},
+		{"dev mode", "true", "", true},
+		{"dev mode without reflection", "true", "false", false},
+		{"reflection outside dev mode", "", "true", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("INSECURE_DEV_MODE", tt.devMode)
+		t.Setenv("ENABLE_REFLECTION", tt.reflection)
+		if got := getConfig().EnableReflection; got != tt.want {
+			t.Errorf("%s: reflection %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UpdateMetadata stores the title, description and image url-service read
+// from a link's destination page. A failed fetch only records its error,
+// so a link keeps the metadata it had. Fetches are best effort and may be
+// repeated, so the last one written wins.
+func (s *storageServer) UpdateMetadata(ctx context.Context, req *proto.UpdateMetadataRequest) (*proto.UpdateMetadataResponse, error) {
+	logf(ctx, "Storage UpdateMetadata request for: %s", req.ShortCode)
+
+	if req.ShortCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "short_code is required")
+	}
+
+	query := `
+		UPDATE urls
+		SET title = $2, description = $3, image_url = $4, metadata_error = NULL, metadata_fetched_at = NOW()
+		WHERE ` + keyIs("urls", "$1") + ` AND deleted_at IS NULL
+	`
+	args := []interface{}{req.ShortCode, req.Title, req.Description, req.ImageUrl}
+	if req.Error != "" {
+		query = `
+			UPDATE urls
+			SET metadata_error = $2, metadata_fetched_at = NOW()
+			WHERE ` + keyIs("urls", "$1") + ` AND deleted_at IS NULL
+		`
+		args = []interface{}{req.ShortCode, req.Error}
+	}
+
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logf(ctx, "Failed to update metadata: %v", err)
+		return nil, dbError(err, "failed to update metadata")
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return nil, status.Errorf(codes.NotFound, "URL not found: %s", req.ShortCode)
+	}
+	return &proto.UpdateMetadataResponse{}, nil
+}
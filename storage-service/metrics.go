@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const dbStatsInterval = 15 * time.Second
+
+// Metrics exported on /metrics. Names and labels are part of the service's
+// interface; dashboards and alerts depend on them, so don't rename.
+//
+//	storage_service_grpc_requests_total{method,code}       RPCs handled
+//	storage_service_grpc_request_duration_seconds{method}  RPC latency, dominated by PostgreSQL
+//	storage_service_db_max_open_connections                pool size limit, DB_MAX_OPEN_CONNS
+//	storage_service_db_open_connections                    connections open to PostgreSQL
+//	storage_service_db_in_use_connections                  connections currently in use
+//	storage_service_db_idle_connections                    idle connections
+//	storage_service_db_wait_count                          total waits for a free connection
+//	storage_service_db_wait_duration_seconds               total time spent waiting for a connection
+//	storage_service_db_reads_total{target}                 GetURL and GetStats reads, by "primary" or replica host
+//	storage_service_db_replica_healthy{host}               1 while a replica in DB_REPLICA_HOSTS answers pings
+//	storage_service_key_pool_size                          unused keys in available_keys, as of the last refill check
+//	storage_service_key_pool_popped_total                  keys handed out by PopKeys
+//	storage_service_key_pool_short_total                   PopKeys calls that got fewer keys than asked for
+//	storage_service_outbox_depth{status}                   outbox events "pending" delivery or "dead" after OUTBOX_MAX_ATTEMPTS
+//	storage_service_outbox_oldest_pending_seconds          age of the oldest pending outbox event, 0 with none
+//	storage_service_outbox_delivered_total                 outbox events delivered and marked done
+//	storage_service_outbox_failures_total                  outbox batches the webhook failed
+//	storage_service_outbox_dead_lettered_total             outbox events given up on
+type serviceMetrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+
+	dbMaxOpen      prometheus.Gauge
+	dbOpen         prometheus.Gauge
+	dbInUse        prometheus.Gauge
+	dbIdle         prometheus.Gauge
+	dbWaitCount    prometheus.Gauge
+	dbWaitDuration prometheus.Gauge
+
+	dbReads          *prometheus.CounterVec
+	dbReplicaHealthy *prometheus.GaugeVec
+
+	keyPoolSize   prometheus.Gauge
+	keyPoolPopped prometheus.Counter
+	keyPoolShort  prometheus.Counter
+
+	outboxDepth         *prometheus.GaugeVec
+	outboxOldestPending prometheus.Gauge
+	outboxDelivered     prometheus.Counter
+	outboxFailures      prometheus.Counter
+	outboxDeadLettered  prometheus.Counter
+}
+
+func newServiceMetrics() *serviceMetrics {
+	m := &serviceMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "storage_service_grpc_requests_total",
+			Help: "gRPC requests handled, by method and status code.",
+		}, []string{"method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "storage_service_grpc_request_duration_seconds",
+			Help:    "gRPC request latency, by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		dbMaxOpen: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "storage_service_db_max_open_connections",
+			Help: "Maximum connections the pool opens to PostgreSQL.",
+		}),
+		dbOpen: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "storage_service_db_open_connections",
+			Help: "Connections open to PostgreSQL.",
+		}),
+		dbInUse: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "storage_service_db_in_use_connections",
+			Help: "PostgreSQL connections currently in use.",
+		}),
+		dbIdle: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "storage_service_db_idle_connections",
+			Help: "Idle PostgreSQL connections.",
+		}),
+		dbWaitCount: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "storage_service_db_wait_count",
+			Help: "Total number of waits for a free PostgreSQL connection.",
+		}),
+		dbWaitDuration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "storage_service_db_wait_duration_seconds",
+			Help: "Total time spent waiting for a free PostgreSQL connection.",
+		}),
+		dbReads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "storage_service_db_reads_total",
+			Help: "Reads that can go to a replica, by the primary or replica that served them.",
+		}, []string{"target"}),
+		dbReplicaHealthy: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "storage_service_db_replica_healthy",
+			Help: "Whether a PostgreSQL replica answers pings and takes reads.",
+		}, []string{"host"}),
+		keyPoolSize: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "storage_service_key_pool_size",
+			Help: "Unused pre-generated keys, as of the last refill check.",
+		}),
+		keyPoolPopped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "storage_service_key_pool_popped_total",
+			Help: "Pre-generated keys handed out.",
+		}),
+		keyPoolShort: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "storage_service_key_pool_short_total",
+			Help: "Key pops that got fewer keys than asked for.",
+		}),
+		outboxDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "storage_service_outbox_depth",
+			Help: "Outbox events waiting for delivery or given up on, by status.",
+		}, []string{"status"}),
+		outboxOldestPending: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "storage_service_outbox_oldest_pending_seconds",
+			Help: "Age of the oldest outbox event waiting for delivery.",
+		}),
+		outboxDelivered: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "storage_service_outbox_delivered_total",
+			Help: "Outbox events delivered to the webhook.",
+		}),
+		outboxFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "storage_service_outbox_failures_total",
+			Help: "Outbox batches the webhook failed to take.",
+		}),
+		outboxDeadLettered: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "storage_service_outbox_dead_lettered_total",
+			Help: "Outbox events given up on after too many attempts.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.dbMaxOpen,
+		m.dbOpen,
+		m.dbInUse,
+		m.dbIdle,
+		m.dbWaitCount,
+		m.dbWaitDuration,
+		m.dbReads,
+		m.dbReplicaHealthy,
+		m.keyPoolSize,
+		m.keyPoolPopped,
+		m.keyPoolShort,
+		m.outboxDepth,
+		m.outboxOldestPending,
+		m.outboxDelivered,
+		m.outboxFailures,
+		m.outboxDeadLettered,
+	)
+	return m
+}
+
+// UnaryServerInterceptor records request counts and latency per RPC.
+func (m *serviceMetrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		method := path.Base(info.FullMethod)
+		m.requests.WithLabelValues(method, status.Code(err).String()).Inc()
+		m.requestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records the count and duration of streaming RPCs.
+func (m *serviceMetrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+
+		method := path.Base(info.FullMethod)
+		m.requests.WithLabelValues(method, status.Code(err).String()).Inc()
+		m.requestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// pollDBStats copies the connection pool stats into gauges until ctx is done.
+func (m *serviceMetrics) pollDBStats(ctx context.Context, db *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var prev sql.DBStats
+	for {
+		stats := db.Stats()
+		logPoolSaturation(prev, stats)
+		prev = stats
+		m.dbMaxOpen.Set(float64(stats.MaxOpenConnections))
+		m.dbOpen.Set(float64(stats.OpenConnections))
+		m.dbInUse.Set(float64(stats.InUse))
+		m.dbIdle.Set(float64(stats.Idle))
+		m.dbWaitCount.Set(float64(stats.WaitCount))
+		m.dbWaitDuration.Set(stats.WaitDuration.Seconds())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// gathered returns the value of every series of name in registry, keyed by
+// its labels as name=value pairs joined with commas.
+func gathered(t *testing.T, registry *prometheus.Registry, name string) map[string]float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	series := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			var labels string
+			for i, pair := range m.GetLabel() {
+				if i > 0 {
+					labels += ","
+				}
+				labels += pair.GetName() + "=" + pair.GetValue()
+			}
+			switch {
+			case m.Counter != nil:
+				series[labels] = m.Counter.GetValue()
+			case m.Gauge != nil:
+				series[labels] = m.Gauge.GetValue()
+			case m.Histogram != nil:
+				series[labels] = float64(m.Histogram.GetSampleCount())
+			}
+		}
+	}
+	return series
+}
+
+func TestMetricsAfterTraffic(t *testing.T) {
+	s := newTestServer(t)
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.UnaryInterceptor(s.metrics.UnaryServerInterceptor()))
+	proto.RegisterStorageServiceServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial bufconn: %v", err)
+	}
+	defer conn.Close()
+	client := proto.NewStorageServiceClient(conn)
+	ctx := context.Background()
+
+	if _, err := client.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "metered", OriginalUrl: "https://example.com"}); err != nil {
+		t.Fatalf("SaveURL: %v", err)
+	}
+	if _, err := client.GetURL(ctx, &proto.GetURLRequest{ShortCode: "metered"}); err != nil {
+		t.Fatalf("GetURL: %v", err)
+	}
+	if _, err := client.GetURL(ctx, &proto.GetURLRequest{ShortCode: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("GetURL(missing): got %v, want NotFound", err)
+	}
+
+	requests := gathered(t, s.metrics.registry, "storage_service_grpc_requests_total")
+	for labels, want := range map[string]float64{
+		"code=OK,method=SaveURL":      1,
+		"code=OK,method=GetURL":       1,
+		"code=NotFound,method=GetURL": 1,
+	} {
+		if requests[labels] != want {
+			t.Errorf("storage_service_grpc_requests_total{%s} = %v, want %v", labels, requests[labels], want)
+		}
+	}
+	if durations := gathered(t, s.metrics.registry, "storage_service_grpc_request_duration_seconds"); durations["method=GetURL"] != 2 {
+		t.Errorf("storage_service_grpc_request_duration_seconds observed %v GetURL calls, want 2", durations["method=GetURL"])
+	}
+	if reads := gathered(t, s.metrics.registry, "storage_service_db_reads_total"); reads["target=primary"] != 2 {
+		t.Errorf("storage_service_db_reads_total = %v, want 2 from the primary", reads)
+	}
+
+	// The pool gauges are filled in by the first poll
+	pollCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		s.metrics.pollDBStats(pollCtx, s.db.DB, time.Hour)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for gathered(t, s.metrics.registry, "storage_service_db_open_connections")[""] == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("storage_service_db_open_connections still 0 after 5s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	for _, name := range []string{"storage_service_db_max_open_connections", "storage_service_db_in_use_connections", "storage_service_db_idle_connections", "storage_service_db_wait_count", "storage_service_db_wait_duration_seconds"} {
+		if _, ok := gathered(t, s.metrics.registry, name)[""]; !ok {
+			t.Errorf("%s not exported", name)
+		}
+	}
+}
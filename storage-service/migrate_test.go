@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	for _, driver := range []string{driverPostgres, driverSQLite} {
+		migrations, err := loadMigrations(driver)
+		if err != nil {
+			t.Fatalf("%s: loadMigrations: %v", driver, err)
+		}
+		if len(migrations) == 0 {
+			t.Fatalf("%s: no migrations", driver)
+		}
+		for i, m := range migrations {
+			if m.version != i+1 || m.name == "" || strings.TrimSpace(m.sql) == "" {
+				t.Errorf("%s: migration %d is %04d_%s, want version %d with a name and SQL", driver, i, m.version, m.name, i+1)
+			}
+		}
+	}
+}
+
+func TestMigrateIdempotent(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		migrations, err := loadMigrations(s.db.dialect.driver)
+		if err != nil {
+			t.Fatalf("loadMigrations: %v", err)
+		}
+
+		// Replicas starting together each run every migration check
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := migrate(ctx, s.db.DB, s.db.dialect); err != nil {
+					t.Errorf("re-running migrate: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		var applied int
+		if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations`).Scan(&applied); err != nil {
+			t.Fatalf("count schema_migrations: %v", err)
+		}
+		if applied != len(migrations) {
+			t.Errorf("%d migrations recorded, want %d", applied, len(migrations))
+		}
+		if _, err := s.db.ExecContext(ctx, `SELECT short_code, original_url, expires_at, user_id, deleted_at FROM urls LIMIT 0`); err != nil {
+			t.Errorf("urls lacks a column: %v", err)
+		}
+	})
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		conn, err := s.db.Conn(ctx)
+		if err != nil {
+			t.Fatalf("Conn: %v", err)
+		}
+		defer conn.Close()
+
+		broken := migration{version: 9999, name: "broken", sql: `CREATE TABLE half_done (id INTEGER); SELECT no_such_column FROM urls`}
+		if err := applyMigration(ctx, conn, s.db.dialect, broken); err == nil {
+			t.Fatal("broken migration applied")
+		}
+		var recorded int
+		conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = 9999`).Scan(&recorded)
+		if recorded != 0 {
+			t.Error("broken migration recorded as applied")
+		}
+		if _, err := conn.ExecContext(ctx, `SELECT id FROM half_done`); err == nil {
+			t.Error("broken migration left a table behind")
+		}
+	})
+}
+
+func TestStartupFailsWithoutDatabase(t *testing.T) {
+	t.Setenv("DB_DRIVER", driverSQLite)
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "missing", "storage.db"))
+	if s, err := NewStorageServer(); err == nil {
+		s.db.Close()
+		t.Fatal("NewStorageServer succeeded without a database directory")
+	} else if !strings.Contains(err.Error(), "SQLite") {
+		t.Errorf("error %q doesn't say what failed", err)
+	}
+}
+
+func TestMigrateSplitsTenantKeys(t *testing.T) {
+	ctx := context.Background()
+	db, err := openSQLite(filepath.Join(t.TempDir(), "storage.db"))
+	if err != nil {
+		t.Fatalf("openSQLite: %v", err)
+	}
+	defer db.Close()
+	migrations, err := loadMigrations(driverSQLite)
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+
+	// A database from before links were keyed by tenant, with its rows
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)`); err != nil {
+		t.Fatalf("create schema_migrations: %v", err)
+	}
+	for _, m := range migrations {
+		if m.name == "key_urls_by_tenant" {
+			break
+		}
+		if err := applyMigration(ctx, conn, dialect{driver: driverSQLite}, m); err != nil {
+			t.Fatalf("migration %04d_%s: %v", m.version, m.name, err)
+		}
+	}
+	if _, err := conn.ExecContext(ctx, `
+		INSERT INTO urls (short_code, original_url, tenant_id) VALUES ('promo', 'https://example.com', ''), ('acme:promo', 'https://acme.example', 'acme');
+		INSERT INTO url_tags (short_code, tag) VALUES ('promo', 'sale'), ('acme:promo', 'sale');
+		INSERT INTO url_clicks (short_code, clicked_at) VALUES ('acme:promo', '2026-01-01 00:00:00.000000000+00:00');
+		INSERT INTO url_history (short_code, action, changed_at) VALUES ('acme:promo', 'create', '2026-01-01 00:00:00.000000000+00:00');
+	`); err != nil {
+		t.Fatalf("insert old rows: %v", err)
+	}
+	conn.Close()
+
+	if err := migrate(ctx, db, dialect{driver: driverSQLite}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	keys := func(table string) []string {
+		t.Helper()
+		rows, err := db.QueryContext(ctx, `SELECT tenant_id, short_code FROM `+table+` ORDER BY tenant_id, short_code`)
+		if err != nil {
+			t.Fatalf("read %s: %v", table, err)
+		}
+		defer rows.Close()
+		var keys []string
+		for rows.Next() {
+			var tenant, code string
+			if err := rows.Scan(&tenant, &code); err != nil {
+				t.Fatalf("read %s: %v", table, err)
+			}
+			keys = append(keys, tenant+"/"+code)
+		}
+		return keys
+	}
+	for table, want := range map[string][]string{
+		"urls":        {"/promo", "acme/promo"},
+		"url_tags":    {"/promo", "acme/promo"},
+		"url_clicks":  {"acme/promo"},
+		"url_history": {"acme/promo"},
+	} {
+		if got := keys(table); !slices.Equal(got, want) {
+			t.Errorf("%s keys %q, want %q", table, got, want)
+		}
+	}
+
+	// Each link's rows go with it and no other
+	if _, err := db.ExecContext(ctx, `DELETE FROM urls WHERE tenant_id = 'acme'`); err != nil {
+		t.Fatalf("delete acme's link: %v", err)
+	}
+	if got := keys("url_tags"); !slices.Equal(got, []string{"/promo"}) {
+		t.Errorf("url_tags keys after deleting acme's link %q, want only the default tenant's", got)
+	}
+	if got := keys("url_clicks"); len(got) != 0 {
+		t.Errorf("url_clicks keys after deleting acme's link %q, want none", got)
+	}
+}
@@ -0,0 +1,28 @@
+-- Written with IF NOT EXISTS so databases created before migrations adopt
+-- them unchanged.
+CREATE TABLE IF NOT EXISTS urls (
+    short_code VARCHAR(20) PRIMARY KEY,
+    original_url TEXT NOT NULL,
+    click_count BIGINT DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_urls_short_code ON urls(short_code);
+CREATE INDEX IF NOT EXISTS idx_urls_created_at ON urls(created_at);
+CREATE INDEX IF NOT EXISTS idx_urls_original_url ON urls(original_url);
+
+-- Auto-update updated_at timestamp
+CREATE OR REPLACE FUNCTION update_updated_at()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_update_updated_at ON urls;
+CREATE TRIGGER trigger_update_updated_at
+    BEFORE UPDATE ON urls
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at();
@@ -0,0 +1,6 @@
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS api_key_id TEXT;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS user_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_urls_expires_at ON urls(expires_at) WHERE expires_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_urls_user_created ON urls(user_id, created_at DESC, short_code DESC) WHERE user_id IS NOT NULL;
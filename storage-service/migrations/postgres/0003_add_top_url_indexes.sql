@@ -0,0 +1,3 @@
+-- Back both orders of GetTopURLs
+CREATE INDEX IF NOT EXISTS idx_urls_click_count ON urls(click_count DESC);
+CREATE INDEX IF NOT EXISTS idx_urls_updated_at ON urls(updated_at DESC);
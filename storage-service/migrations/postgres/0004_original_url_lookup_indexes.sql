@@ -0,0 +1,10 @@
+-- A btree on original_url rejects URLs longer than about 2.7kB, so exact
+-- lookups go through its md5 instead and recheck the URL.
+CREATE INDEX IF NOT EXISTS idx_urls_original_url_md5 ON urls(md5(original_url), created_at, short_code);
+DROP INDEX IF EXISTS idx_urls_original_url;
+
+-- Host lookups match the reversed host by prefix so subdomains are included.
+-- The expression must stay identical to originalHostExpr in main.go.
+CREATE INDEX IF NOT EXISTS idx_urls_original_host ON urls(
+    reverse(lower(substring(original_url from '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]+)'))) text_pattern_ops
+);
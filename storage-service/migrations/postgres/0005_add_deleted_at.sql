@@ -0,0 +1,4 @@
+-- Deleted URLs are kept, with their clicks, until the retention period ends
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_urls_deleted_at ON urls(deleted_at) WHERE deleted_at IS NOT NULL;
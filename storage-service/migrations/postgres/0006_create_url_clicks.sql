@@ -0,0 +1,13 @@
+-- One row per click, kept for the click event retention period. click_count
+-- on urls stays the source of cheap totals.
+CREATE TABLE IF NOT EXISTS url_clicks (
+    id BIGSERIAL PRIMARY KEY,
+    short_code VARCHAR(20) NOT NULL REFERENCES urls(short_code) ON DELETE CASCADE,
+    clicked_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    referrer TEXT,
+    user_agent TEXT,
+    country VARCHAR(2)
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_clicks_short_code_clicked_at ON url_clicks(short_code, clicked_at);
+CREATE INDEX IF NOT EXISTS idx_url_clicks_clicked_at ON url_clicks(clicked_at);
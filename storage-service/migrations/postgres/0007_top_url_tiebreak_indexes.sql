@@ -0,0 +1,6 @@
+-- GetTopURLs breaks ties by short code, so include it to read the order
+-- straight from the index
+CREATE INDEX IF NOT EXISTS idx_urls_click_count_short_code ON urls(click_count DESC, short_code);
+CREATE INDEX IF NOT EXISTS idx_urls_updated_at_short_code ON urls(updated_at DESC, short_code);
+DROP INDEX IF EXISTS idx_urls_click_count;
+DROP INDEX IF EXISTS idx_urls_updated_at;
@@ -0,0 +1,7 @@
+-- Values GetClickBreakdown groups by, derived by RecordClick from the
+-- referrer and user agent. Events recorded before stay NULL and age out
+-- with the click event retention.
+ALTER TABLE url_clicks
+    ADD COLUMN IF NOT EXISTS referrer_host TEXT,
+    ADD COLUMN IF NOT EXISTS browser TEXT,
+    ADD COLUMN IF NOT EXISTS device TEXT;
@@ -0,0 +1,4 @@
+-- Clicks url-service classified as bots or prefetches, counted apart from
+-- click_count. Events keep the same flag so time series can leave them out.
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS bot_clicks BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE url_clicks ADD COLUMN IF NOT EXISTS bot BOOLEAN NOT NULL DEFAULT false;
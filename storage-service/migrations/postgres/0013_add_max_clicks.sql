@@ -0,0 +1,6 @@
+-- Links that stop resolving after max_clicks clicks, such as one-time
+-- invites, optionally sending later visitors to fallback_url. NULL means
+-- unlimited.
+ALTER TABLE urls
+    ADD COLUMN IF NOT EXISTS max_clicks BIGINT,
+    ADD COLUMN IF NOT EXISTS fallback_url TEXT;
@@ -0,0 +1,6 @@
+-- Links that only start resolving at not_before, such as campaigns,
+-- optionally sending earlier visitors to coming_soon_url. The end of the
+-- window is the existing expires_at.
+ALTER TABLE urls
+    ADD COLUMN IF NOT EXISTS not_before TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS coming_soon_url TEXT;
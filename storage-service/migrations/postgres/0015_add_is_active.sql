@@ -0,0 +1,3 @@
+-- Links turned off with SetURLStatus, for instance over abuse, keep their
+-- row and history but stop resolving until turned back on.
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT true;
@@ -0,0 +1,15 @@
+-- One row per change to a link's destination or state, written in the same
+-- transaction as the change. Rows are never updated, and have no foreign
+-- key so they outlive the purge of deleted links.
+CREATE TABLE IF NOT EXISTS url_history (
+    id BIGSERIAL PRIMARY KEY,
+    short_code VARCHAR(20) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    actor TEXT,
+    api_key_id TEXT,
+    old_url TEXT,
+    new_url TEXT,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_history_short_code_id ON url_history(short_code, id DESC);
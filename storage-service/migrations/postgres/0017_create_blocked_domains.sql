@@ -0,0 +1,10 @@
+-- Destinations url-service refuses to shorten, or with DOMAIN_POLICY=allowlist
+-- the only ones it accepts. A host also matches its subdomains; a regex is
+-- matched against the whole URL. url-service keeps the list in memory.
+CREATE TABLE IF NOT EXISTS blocked_domains (
+    pattern TEXT NOT NULL,
+    is_regex BOOLEAN NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (pattern, is_regex)
+);
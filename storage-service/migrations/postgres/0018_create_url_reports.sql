@@ -0,0 +1,17 @@
+-- Abuse reports on links. reporter identifies who reported, without storing
+-- their address, and report_day is the UTC day, so one reporter counts once
+-- a day per link. Reports turn actioned when the link is disabled over them.
+CREATE TABLE IF NOT EXISTS url_reports (
+    id BIGSERIAL PRIMARY KEY,
+    short_code VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL,
+    reporter_contact TEXT,
+    reporter TEXT NOT NULL,
+    report_day VARCHAR(10) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'open',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    UNIQUE (short_code, reporter, report_day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_reports_short_code_status ON url_reports(short_code, status);
+CREATE INDEX IF NOT EXISTS idx_url_reports_status_id ON url_reports(status, id DESC);
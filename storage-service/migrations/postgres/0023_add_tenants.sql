@@ -0,0 +1,18 @@
+-- Tenants, each with its own short codes. url-service stores a tenant's
+-- codes as "<tenant>:<code>", so short_code stays the key every table joins
+-- on and is unique per tenant; tenant_id scopes listings, counts and top
+-- links. Existing rows belong to the default tenant, ''. Codes grow by the
+-- tenant prefix, hence the wider columns.
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(20) NOT NULL DEFAULT '';
+
+ALTER TABLE urls ALTER COLUMN short_code TYPE VARCHAR(64);
+ALTER TABLE url_clicks ALTER COLUMN short_code TYPE VARCHAR(64);
+ALTER TABLE url_history ALTER COLUMN short_code TYPE VARCHAR(64);
+ALTER TABLE url_reports ALTER COLUMN short_code TYPE VARCHAR(64);
+ALTER TABLE url_variants ALTER COLUMN short_code TYPE VARCHAR(64);
+ALTER TABLE url_redirect_rules ALTER COLUMN short_code TYPE VARCHAR(64);
+ALTER TABLE url_tags ALTER COLUMN short_code TYPE VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_urls_tenant_user_created ON urls(tenant_id, user_id, created_at DESC, short_code DESC) WHERE user_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_urls_tenant_click_count ON urls(tenant_id, click_count DESC, short_code);
+CREATE INDEX IF NOT EXISTS idx_urls_tenant_updated_at ON urls(tenant_id, updated_at DESC, short_code);
@@ -0,0 +1,16 @@
+-- One row per request to erase a user's data, with who made it and what it
+-- changed. Rows are never updated or purged, they are the record that the
+-- request was carried out.
+CREATE TABLE IF NOT EXISTS user_data_audit (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    tenant_id VARCHAR(20) NOT NULL DEFAULT '',
+    action VARCHAR(16) NOT NULL,
+    actor TEXT,
+    api_key_id TEXT,
+    deleted_urls BIGINT NOT NULL,
+    scrubbed_clicks BIGINT NOT NULL,
+    requested_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_data_audit_user ON user_data_audit(tenant_id, user_id, id DESC);
@@ -0,0 +1,8 @@
+-- The destination page's title and Open Graph description and image,
+-- fetched by url-service after a link is created when FETCH_METADATA is
+-- set. metadata_error holds why the last fetch failed, if it did.
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS title TEXT;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS image_url TEXT;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS metadata_fetched_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS metadata_error TEXT;
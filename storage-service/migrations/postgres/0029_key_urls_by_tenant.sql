@@ -0,0 +1,57 @@
+-- Links are keyed by (tenant_id, short_code) rather than by a short_code
+-- holding "<tenant>:<code>", so the same code is a row of its own in each
+-- tenant. Every table that belongs to a link gets tenant_id and keeps the
+-- bare code. The keys come off first, since a tenant's code can match one
+-- of the default tenant's once its prefix is stripped.
+ALTER TABLE url_clicks ADD COLUMN tenant_id VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE url_variants ADD COLUMN tenant_id VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE url_redirect_rules ADD COLUMN tenant_id VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE url_tags ADD COLUMN tenant_id VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE url_history ADD COLUMN tenant_id VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE url_reports ADD COLUMN tenant_id VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE pending_clicks ADD COLUMN tenant_id VARCHAR(20) NOT NULL DEFAULT '';
+
+ALTER TABLE url_clicks DROP CONSTRAINT url_clicks_short_code_fkey;
+ALTER TABLE url_variants DROP CONSTRAINT url_variants_short_code_fkey;
+ALTER TABLE url_variants DROP CONSTRAINT url_variants_pkey;
+ALTER TABLE url_variants DROP CONSTRAINT url_variants_short_code_name_key;
+ALTER TABLE url_redirect_rules DROP CONSTRAINT url_redirect_rules_short_code_fkey;
+ALTER TABLE url_redirect_rules DROP CONSTRAINT url_redirect_rules_pkey;
+ALTER TABLE url_tags DROP CONSTRAINT url_tags_short_code_fkey;
+ALTER TABLE url_tags DROP CONSTRAINT url_tags_pkey;
+ALTER TABLE url_reports DROP CONSTRAINT url_reports_short_code_reporter_report_day_key;
+ALTER TABLE pending_clicks DROP CONSTRAINT pending_clicks_pkey;
+ALTER TABLE urls DROP CONSTRAINT urls_pkey;
+
+-- urls already has tenant_id; the others split it off their keys
+UPDATE urls SET short_code = substr(short_code, strpos(short_code, ':') + 1) WHERE strpos(short_code, ':') > 0;
+UPDATE url_clicks SET tenant_id = split_part(short_code, ':', 1), short_code = substr(short_code, strpos(short_code, ':') + 1) WHERE strpos(short_code, ':') > 0;
+UPDATE url_variants SET tenant_id = split_part(short_code, ':', 1), short_code = substr(short_code, strpos(short_code, ':') + 1) WHERE strpos(short_code, ':') > 0;
+UPDATE url_redirect_rules SET tenant_id = split_part(short_code, ':', 1), short_code = substr(short_code, strpos(short_code, ':') + 1) WHERE strpos(short_code, ':') > 0;
+UPDATE url_tags SET tenant_id = split_part(short_code, ':', 1), short_code = substr(short_code, strpos(short_code, ':') + 1) WHERE strpos(short_code, ':') > 0;
+UPDATE url_history SET tenant_id = split_part(short_code, ':', 1), short_code = substr(short_code, strpos(short_code, ':') + 1) WHERE strpos(short_code, ':') > 0;
+UPDATE url_reports SET tenant_id = split_part(short_code, ':', 1), short_code = substr(short_code, strpos(short_code, ':') + 1) WHERE strpos(short_code, ':') > 0;
+UPDATE pending_clicks SET tenant_id = split_part(short_code, ':', 1), short_code = substr(short_code, strpos(short_code, ':') + 1) WHERE strpos(short_code, ':') > 0;
+
+ALTER TABLE urls ADD PRIMARY KEY (tenant_id, short_code);
+ALTER TABLE url_clicks ADD FOREIGN KEY (tenant_id, short_code) REFERENCES urls(tenant_id, short_code) ON DELETE CASCADE;
+ALTER TABLE url_variants ADD PRIMARY KEY (tenant_id, short_code, position);
+ALTER TABLE url_variants ADD UNIQUE (tenant_id, short_code, name);
+ALTER TABLE url_variants ADD FOREIGN KEY (tenant_id, short_code) REFERENCES urls(tenant_id, short_code) ON DELETE CASCADE;
+ALTER TABLE url_redirect_rules ADD PRIMARY KEY (tenant_id, short_code, position);
+ALTER TABLE url_redirect_rules ADD FOREIGN KEY (tenant_id, short_code) REFERENCES urls(tenant_id, short_code) ON DELETE CASCADE;
+ALTER TABLE url_tags ADD PRIMARY KEY (tenant_id, short_code, tag);
+ALTER TABLE url_tags ADD FOREIGN KEY (tenant_id, short_code) REFERENCES urls(tenant_id, short_code) ON DELETE CASCADE;
+ALTER TABLE url_reports ADD UNIQUE (tenant_id, short_code, reporter, report_day);
+ALTER TABLE pending_clicks ADD PRIMARY KEY (tenant_id, short_code);
+
+-- idx_urls_short_code stays, for the key pool, which hands out codes no
+-- tenant has
+DROP INDEX IF EXISTS idx_url_clicks_short_code_clicked_at;
+DROP INDEX IF EXISTS idx_url_tags_tag;
+DROP INDEX IF EXISTS idx_url_history_short_code_id;
+DROP INDEX IF EXISTS idx_url_reports_short_code_status;
+CREATE INDEX IF NOT EXISTS idx_url_clicks_short_code_clicked_at ON url_clicks(tenant_id, short_code, clicked_at);
+CREATE INDEX IF NOT EXISTS idx_url_tags_tag ON url_tags(tag, tenant_id, short_code);
+CREATE INDEX IF NOT EXISTS idx_url_history_short_code_id ON url_history(tenant_id, short_code, id DESC);
+CREATE INDEX IF NOT EXISTS idx_url_reports_short_code_status ON url_reports(tenant_id, short_code, status);
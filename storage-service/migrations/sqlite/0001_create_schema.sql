@@ -0,0 +1,42 @@
+-- The whole schema as of postgres/0007. Timestamps are TEXT in one UTC
+-- format (see sqliteTimeFormat) so they compare correctly as strings, and
+-- declared TIMESTAMP so the driver scans them into time.Time.
+CREATE TABLE IF NOT EXISTS urls (
+    short_code VARCHAR(20) PRIMARY KEY,
+    original_url TEXT NOT NULL,
+    click_count BIGINT DEFAULT 0,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    api_key_id TEXT,
+    user_id TEXT,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_urls_created_at ON urls(created_at);
+CREATE INDEX IF NOT EXISTS idx_urls_expires_at ON urls(expires_at) WHERE expires_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_urls_user_created ON urls(user_id, created_at DESC, short_code DESC) WHERE user_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_urls_click_count_short_code ON urls(click_count DESC, short_code);
+CREATE INDEX IF NOT EXISTS idx_urls_updated_at_short_code ON urls(updated_at DESC, short_code);
+CREATE INDEX IF NOT EXISTS idx_urls_original_url_md5 ON urls(md5(original_url), created_at, short_code);
+CREATE INDEX IF NOT EXISTS idx_urls_original_host ON urls(reverse(lower(url_host(original_url))));
+CREATE INDEX IF NOT EXISTS idx_urls_deleted_at ON urls(deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TRIGGER IF NOT EXISTS trigger_update_updated_at
+    AFTER UPDATE ON urls
+    FOR EACH ROW
+BEGIN
+    UPDATE urls SET updated_at = now() WHERE short_code = NEW.short_code;
+END;
+
+CREATE TABLE IF NOT EXISTS url_clicks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    short_code VARCHAR(20) NOT NULL REFERENCES urls(short_code) ON DELETE CASCADE,
+    clicked_at TIMESTAMP NOT NULL,
+    referrer TEXT,
+    user_agent TEXT,
+    country VARCHAR(2)
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_clicks_short_code_clicked_at ON url_clicks(short_code, clicked_at);
+CREATE INDEX IF NOT EXISTS idx_url_clicks_clicked_at ON url_clicks(clicked_at);
@@ -0,0 +1,12 @@
+-- Counters behind AllocateIDRange. A row rather than a sequence, since a
+-- sequence can't hand out a contiguous block of any size in one step; the
+-- row lock taken by the UPDATE serializes concurrent allocations instead.
+CREATE TABLE IF NOT EXISTS id_ranges (
+    name TEXT PRIMARY KEY,
+    next_id BIGINT NOT NULL
+);
+
+-- Sequence codes start at 62^6, the first seven character code, so they
+-- never meet the six character random ones.
+INSERT INTO id_ranges (name, next_id) VALUES ('short_codes', 56800235584)
+ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,5 @@
+-- Pre-generated codes that no URL uses yet, handed out by PopKeys and
+-- topped up by the key pool refiller.
+CREATE TABLE IF NOT EXISTS available_keys (
+    short_code VARCHAR(20) PRIMARY KEY
+);
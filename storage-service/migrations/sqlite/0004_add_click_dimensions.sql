@@ -0,0 +1,6 @@
+-- Values GetClickBreakdown groups by, derived by RecordClick from the
+-- referrer and user agent. Events recorded before stay NULL and age out
+-- with the click event retention.
+ALTER TABLE url_clicks ADD COLUMN referrer_host TEXT;
+ALTER TABLE url_clicks ADD COLUMN browser TEXT;
+ALTER TABLE url_clicks ADD COLUMN device TEXT;
@@ -0,0 +1,3 @@
+-- Clicks from visitors url-service hadn't seen within its dedup window,
+-- counted alongside click_count.
+ALTER TABLE urls ADD COLUMN unique_clicks BIGINT NOT NULL DEFAULT 0;
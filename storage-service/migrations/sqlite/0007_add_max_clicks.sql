@@ -0,0 +1,5 @@
+-- Links that stop resolving after max_clicks clicks, such as one-time
+-- invites, optionally sending later visitors to fallback_url. NULL means
+-- unlimited.
+ALTER TABLE urls ADD COLUMN max_clicks BIGINT;
+ALTER TABLE urls ADD COLUMN fallback_url TEXT;
@@ -0,0 +1,5 @@
+-- Links that only start resolving at not_before, such as campaigns,
+-- optionally sending earlier visitors to coming_soon_url. The end of the
+-- window is the existing expires_at.
+ALTER TABLE urls ADD COLUMN not_before TIMESTAMP;
+ALTER TABLE urls ADD COLUMN coming_soon_url TEXT;
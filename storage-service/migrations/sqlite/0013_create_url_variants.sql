@@ -0,0 +1,16 @@
+-- Weighted destinations of split links, which url-service picks from on
+-- every lookup. original_url keeps the first variant's, so listings and
+-- lookups by destination still find the link. url_clicks.variant records
+-- which variant each click was sent to.
+CREATE TABLE IF NOT EXISTS url_variants (
+    short_code VARCHAR(20) NOT NULL REFERENCES urls(short_code) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name VARCHAR(32) NOT NULL,
+    destination_url TEXT NOT NULL,
+    weight INTEGER NOT NULL,
+    PRIMARY KEY (short_code, position),
+    UNIQUE (short_code, name)
+);
+
+ALTER TABLE urls ADD COLUMN sticky_variants BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE url_clicks ADD COLUMN variant VARCHAR(32);
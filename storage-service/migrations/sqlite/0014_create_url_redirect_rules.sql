@@ -0,0 +1,14 @@
+-- Conditional destinations of links, which url-service tries in order on
+-- every lookup before the link's own destination. countries holds a comma
+-- separated list of country codes, NULL like device when any matches.
+-- url_clicks.redirect_rule records which rule each click matched.
+CREATE TABLE IF NOT EXISTS url_redirect_rules (
+    short_code VARCHAR(20) NOT NULL REFERENCES urls(short_code) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    device VARCHAR(16),
+    countries TEXT,
+    destination_url TEXT NOT NULL,
+    PRIMARY KEY (short_code, position)
+);
+
+ALTER TABLE url_clicks ADD COLUMN redirect_rule VARCHAR(16);
@@ -0,0 +1,4 @@
+-- Query parameters url-service adds to a link's destination on every
+-- redirect, such as utm_source, kept apart so original_url stays the clean
+-- URL that dedup and lookups by destination match.
+ALTER TABLE urls ADD COLUMN query_template TEXT;
@@ -0,0 +1,10 @@
+-- Free-form tags users organize their links with, normalized by
+-- url-service. The tag index serves ListURLs' tag filter, the primary key
+-- loading the tags of a page of links and counting a user's tags.
+CREATE TABLE IF NOT EXISTS url_tags (
+    short_code VARCHAR(20) NOT NULL REFERENCES urls(short_code) ON DELETE CASCADE,
+    tag VARCHAR(32) NOT NULL,
+    PRIMARY KEY (short_code, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_tags_tag ON url_tags(tag, short_code);
@@ -0,0 +1,21 @@
+-- One row per change to a link that has to reach the outbox webhook, written
+-- in the same transaction as the change. The dispatcher claims pending rows
+-- for a lease, delivers them and marks them done, or dead once they have
+-- failed too often. Done rows are deleted after OUTBOX_RETENTION.
+CREATE TABLE IF NOT EXISTS outbox (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_type VARCHAR(32) NOT NULL,
+    short_code VARCHAR(64) NOT NULL,
+    original_url TEXT,
+    status VARCHAR(8) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    claim_token TEXT,
+    claimed_until TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL,
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(next_attempt_at, id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_outbox_delivered ON outbox(delivered_at) WHERE status = 'done';
@@ -0,0 +1,14 @@
+-- Click deltas flushed for short codes that have no row yet, because
+-- url-service saves new links asynchronously and can flush their clicks
+-- first. They are added to the link when it is saved, and dropped after
+-- PENDING_CLICK_RETENTION if it never is.
+CREATE TABLE IF NOT EXISTS pending_clicks (
+    short_code VARCHAR(64) PRIMARY KEY,
+    click_count BIGINT NOT NULL DEFAULT 0,
+    unique_clicks BIGINT NOT NULL DEFAULT 0,
+    bot_clicks BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_pending_clicks_created_at ON pending_clicks(created_at);
@@ -0,0 +1,7 @@
+-- When each link was last clicked by a person, carried by url-service's
+-- click flushes rather than written per click, so links nobody uses can be
+-- found. Links never clicked count from their creation.
+ALTER TABLE urls ADD COLUMN last_accessed_at TIMESTAMP;
+ALTER TABLE pending_clicks ADD COLUMN last_accessed_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_urls_last_accessed ON urls(tenant_id, COALESCE(last_accessed_at, created_at)) WHERE deleted_at IS NULL;
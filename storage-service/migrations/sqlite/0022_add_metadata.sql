@@ -0,0 +1,8 @@
+-- The destination page's title and Open Graph description and image,
+-- fetched by url-service after a link is created when FETCH_METADATA is
+-- set. metadata_error holds why the last fetch failed, if it did.
+ALTER TABLE urls ADD COLUMN title TEXT;
+ALTER TABLE urls ADD COLUMN description TEXT;
+ALTER TABLE urls ADD COLUMN image_url TEXT;
+ALTER TABLE urls ADD COLUMN metadata_fetched_at TIMESTAMP;
+ALTER TABLE urls ADD COLUMN metadata_error TEXT;
@@ -0,0 +1,194 @@
+-- Links are keyed by (tenant_id, short_code) rather than by a short_code
+-- holding "<tenant>:<code>", so the same code is a row of its own in each
+-- tenant. Every table that belongs to a link gets tenant_id and keeps the
+-- bare code. SQLite can't change a primary key in place, so the tables are
+-- renamed, recreated and copied; the old children are dropped before the
+-- old urls so nothing cascades.
+ALTER TABLE urls RENAME TO urls_old;
+ALTER TABLE url_clicks RENAME TO url_clicks_old;
+ALTER TABLE url_variants RENAME TO url_variants_old;
+ALTER TABLE url_redirect_rules RENAME TO url_redirect_rules_old;
+ALTER TABLE url_tags RENAME TO url_tags_old;
+ALTER TABLE url_reports RENAME TO url_reports_old;
+ALTER TABLE pending_clicks RENAME TO pending_clicks_old;
+
+CREATE TABLE urls (
+    tenant_id VARCHAR(20) NOT NULL DEFAULT '',
+    short_code VARCHAR(20) NOT NULL,
+    original_url TEXT NOT NULL,
+    click_count BIGINT DEFAULT 0,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    api_key_id TEXT,
+    user_id TEXT,
+    deleted_at TIMESTAMP,
+    unique_clicks BIGINT NOT NULL DEFAULT 0,
+    bot_clicks BIGINT NOT NULL DEFAULT 0,
+    max_clicks BIGINT,
+    fallback_url TEXT,
+    not_before TIMESTAMP,
+    coming_soon_url TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT 1,
+    sticky_variants BOOLEAN NOT NULL DEFAULT 0,
+    query_template TEXT,
+    last_accessed_at TIMESTAMP,
+    title TEXT,
+    description TEXT,
+    image_url TEXT,
+    metadata_fetched_at TIMESTAMP,
+    metadata_error TEXT,
+    PRIMARY KEY (tenant_id, short_code)
+);
+
+CREATE TABLE url_clicks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id VARCHAR(20) NOT NULL DEFAULT '',
+    short_code VARCHAR(20) NOT NULL,
+    clicked_at TIMESTAMP NOT NULL,
+    referrer TEXT,
+    user_agent TEXT,
+    country VARCHAR(2),
+    referrer_host TEXT,
+    browser TEXT,
+    device TEXT,
+    bot BOOLEAN NOT NULL DEFAULT 0,
+    variant VARCHAR(32),
+    redirect_rule VARCHAR(16),
+    FOREIGN KEY (tenant_id, short_code) REFERENCES urls(tenant_id, short_code) ON DELETE CASCADE
+);
+
+CREATE TABLE url_variants (
+    tenant_id VARCHAR(20) NOT NULL DEFAULT '',
+    short_code VARCHAR(20) NOT NULL,
+    position INTEGER NOT NULL,
+    name VARCHAR(32) NOT NULL,
+    destination_url TEXT NOT NULL,
+    weight INTEGER NOT NULL,
+    PRIMARY KEY (tenant_id, short_code, position),
+    UNIQUE (tenant_id, short_code, name),
+    FOREIGN KEY (tenant_id, short_code) REFERENCES urls(tenant_id, short_code) ON DELETE CASCADE
+);
+
+CREATE TABLE url_redirect_rules (
+    tenant_id VARCHAR(20) NOT NULL DEFAULT '',
+    short_code VARCHAR(20) NOT NULL,
+    position INTEGER NOT NULL,
+    device VARCHAR(16),
+    countries TEXT,
+    destination_url TEXT NOT NULL,
+    PRIMARY KEY (tenant_id, short_code, position),
+    FOREIGN KEY (tenant_id, short_code) REFERENCES urls(tenant_id, short_code) ON DELETE CASCADE
+);
+
+CREATE TABLE url_tags (
+    tenant_id VARCHAR(20) NOT NULL DEFAULT '',
+    short_code VARCHAR(20) NOT NULL,
+    tag VARCHAR(32) NOT NULL,
+    PRIMARY KEY (tenant_id, short_code, tag),
+    FOREIGN KEY (tenant_id, short_code) REFERENCES urls(tenant_id, short_code) ON DELETE CASCADE
+);
+
+CREATE TABLE url_reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id VARCHAR(20) NOT NULL DEFAULT '',
+    short_code VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL,
+    reporter_contact TEXT,
+    reporter TEXT NOT NULL,
+    report_day VARCHAR(10) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'open',
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (tenant_id, short_code, reporter, report_day)
+);
+
+CREATE TABLE pending_clicks (
+    tenant_id VARCHAR(20) NOT NULL DEFAULT '',
+    short_code VARCHAR(20) NOT NULL,
+    click_count BIGINT NOT NULL DEFAULT 0,
+    unique_clicks BIGINT NOT NULL DEFAULT 0,
+    bot_clicks BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    last_accessed_at TIMESTAMP,
+    PRIMARY KEY (tenant_id, short_code)
+);
+
+-- urls already has tenant_id; the children split it off their keys
+INSERT INTO urls (tenant_id, short_code, original_url, click_count, created_at, updated_at, expires_at, api_key_id, user_id, deleted_at,
+    unique_clicks, bot_clicks, max_clicks, fallback_url, not_before, coming_soon_url, is_active, sticky_variants, query_template,
+    last_accessed_at, title, description, image_url, metadata_fetched_at, metadata_error)
+SELECT tenant_id, substr(short_code, instr(short_code, ':') + 1), original_url, click_count, created_at, updated_at, expires_at, api_key_id, user_id, deleted_at,
+    unique_clicks, bot_clicks, max_clicks, fallback_url, not_before, coming_soon_url, is_active, sticky_variants, query_template,
+    last_accessed_at, title, description, image_url, metadata_fetched_at, metadata_error
+FROM urls_old;
+
+INSERT INTO url_clicks (id, tenant_id, short_code, clicked_at, referrer, user_agent, country, referrer_host, browser, device, bot, variant, redirect_rule)
+SELECT id, substr(short_code, 1, max(instr(short_code, ':') - 1, 0)), substr(short_code, instr(short_code, ':') + 1),
+    clicked_at, referrer, user_agent, country, referrer_host, browser, device, bot, variant, redirect_rule
+FROM url_clicks_old;
+
+INSERT INTO url_variants (tenant_id, short_code, position, name, destination_url, weight)
+SELECT substr(short_code, 1, max(instr(short_code, ':') - 1, 0)), substr(short_code, instr(short_code, ':') + 1), position, name, destination_url, weight
+FROM url_variants_old;
+
+INSERT INTO url_redirect_rules (tenant_id, short_code, position, device, countries, destination_url)
+SELECT substr(short_code, 1, max(instr(short_code, ':') - 1, 0)), substr(short_code, instr(short_code, ':') + 1), position, device, countries, destination_url
+FROM url_redirect_rules_old;
+
+INSERT INTO url_tags (tenant_id, short_code, tag)
+SELECT substr(short_code, 1, max(instr(short_code, ':') - 1, 0)), substr(short_code, instr(short_code, ':') + 1), tag
+FROM url_tags_old;
+
+INSERT INTO url_reports (id, tenant_id, short_code, reason, reporter_contact, reporter, report_day, status, created_at)
+SELECT id, substr(short_code, 1, max(instr(short_code, ':') - 1, 0)), substr(short_code, instr(short_code, ':') + 1),
+    reason, reporter_contact, reporter, report_day, status, created_at
+FROM url_reports_old;
+
+INSERT INTO pending_clicks (tenant_id, short_code, click_count, unique_clicks, bot_clicks, created_at, updated_at, last_accessed_at)
+SELECT substr(short_code, 1, max(instr(short_code, ':') - 1, 0)), substr(short_code, instr(short_code, ':') + 1),
+    click_count, unique_clicks, bot_clicks, created_at, updated_at, last_accessed_at
+FROM pending_clicks_old;
+
+DROP TABLE url_clicks_old;
+DROP TABLE url_variants_old;
+DROP TABLE url_redirect_rules_old;
+DROP TABLE url_tags_old;
+DROP TABLE url_reports_old;
+DROP TABLE pending_clicks_old;
+DROP TABLE urls_old;
+
+-- History has no key to change, only the column to add
+ALTER TABLE url_history ADD COLUMN tenant_id VARCHAR(20) NOT NULL DEFAULT '';
+UPDATE url_history
+SET tenant_id = substr(short_code, 1, instr(short_code, ':') - 1),
+    short_code = substr(short_code, instr(short_code, ':') + 1)
+WHERE instr(short_code, ':') > 0;
+DROP INDEX IF EXISTS idx_url_history_short_code_id;
+CREATE INDEX IF NOT EXISTS idx_url_history_short_code_id ON url_history(tenant_id, short_code, id DESC);
+
+-- The indexes of urls as before, and the bare code alone for the key pool,
+-- which hands out codes no tenant has
+CREATE INDEX IF NOT EXISTS idx_urls_short_code ON urls(short_code);
+CREATE INDEX IF NOT EXISTS idx_urls_created_at ON urls(created_at);
+CREATE INDEX IF NOT EXISTS idx_urls_expires_at ON urls(expires_at) WHERE expires_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_urls_deleted_at ON urls(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_urls_original_url_md5 ON urls(md5(original_url), created_at, short_code);
+CREATE INDEX IF NOT EXISTS idx_urls_original_host ON urls(reverse(lower(url_host(original_url))));
+CREATE INDEX IF NOT EXISTS idx_urls_tenant_user_created ON urls(tenant_id, user_id, created_at DESC, short_code DESC) WHERE user_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_urls_tenant_click_count ON urls(tenant_id, click_count DESC, short_code);
+CREATE INDEX IF NOT EXISTS idx_urls_tenant_updated_at ON urls(tenant_id, updated_at DESC, short_code);
+CREATE INDEX IF NOT EXISTS idx_urls_last_accessed ON urls(tenant_id, COALESCE(last_accessed_at, created_at)) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_url_clicks_short_code_clicked_at ON url_clicks(tenant_id, short_code, clicked_at);
+CREATE INDEX IF NOT EXISTS idx_url_clicks_clicked_at ON url_clicks(clicked_at);
+CREATE INDEX IF NOT EXISTS idx_url_tags_tag ON url_tags(tag, tenant_id, short_code);
+CREATE INDEX IF NOT EXISTS idx_url_reports_short_code_status ON url_reports(tenant_id, short_code, status);
+CREATE INDEX IF NOT EXISTS idx_url_reports_status_id ON url_reports(status, id DESC);
+CREATE INDEX IF NOT EXISTS idx_pending_clicks_created_at ON pending_clicks(created_at);
+
+CREATE TRIGGER IF NOT EXISTS trigger_update_updated_at
+    AFTER UPDATE ON urls
+    FOR EACH ROW
+BEGIN
+    UPDATE urls SET updated_at = now() WHERE tenant_id = NEW.tenant_id AND short_code = NEW.short_code;
+END;
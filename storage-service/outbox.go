@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Events written to the outbox. Replacing a deleted link is a creation as
+// far as consumers are concerned.
+const (
+	outboxURLCreated  = "url.created"
+	outboxURLUpdated  = "url.updated"
+	outboxURLDisabled = "url.disabled"
+	outboxURLEnabled  = "url.enabled"
+	outboxURLDeleted  = "url.deleted"
+	outboxURLPurged   = "url.purged"
+)
+
+// Values of outbox.status.
+const (
+	outboxPending = "pending"
+	outboxDone    = "done"
+	outboxDead    = "dead"
+)
+
+const (
+	defaultOutboxPollInterval = time.Second
+	defaultOutboxBatchSize    = 100
+	defaultOutboxMaxAttempts  = 10
+	defaultOutboxLease        = time.Minute
+	defaultOutboxRetention    = 24 * time.Hour
+
+	outboxWebhookTimeout = 10 * time.Second
+	outboxMaxBackoff     = 5 * time.Minute
+)
+
+// Changes to links reach the outside through a transactional outbox rather
+// than being sent after the change commits, which loses them whenever the
+// process dies or the receiver is down in between. SaveURL, SaveURLs,
+// DeleteURL, SetURLStatus, PurgeURL and DeleteUserData write an outbox row
+// in the transaction making the change, so a change is never stored without
+// its event. The dispatcher claims pending rows for a lease, POSTs them to
+// OUTBOX_WEBHOOK_URL as a JSON array and marks them done. Delivery is at
+// least once: a dispatcher that dies mid-batch leaves its rows to be claimed
+// again once the lease runs out, and receivers dedupe on the event id.
+// Failed batches are retried with backoff, and rows that fail
+// OUTBOX_MAX_ATTEMPTS times are left as dead for an operator.
+
+// outboxConfig controls the dispatcher.
+type outboxConfig struct {
+	webhookURL   string
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	lease        time.Duration // How long a claimed batch is left to its dispatcher
+	retention    time.Duration // How long delivered rows are kept
+}
+
+// outbox writes and dispatches the events of changes. A nil outbox, used
+// when OUTBOX_WEBHOOK_URL is unset, writes nothing.
+type outbox struct {
+	config  outboxConfig
+	client  *http.Client
+	metrics *serviceMetrics
+}
+
+func newOutbox(config outboxConfig, metrics *serviceMetrics) *outbox {
+	if config.webhookURL == "" {
+		return nil
+	}
+	return &outbox{
+		config:  config,
+		client:  &http.Client{Timeout: outboxWebhookTimeout},
+		metrics: metrics,
+	}
+}
+
+// enqueue adds the event of a change to the transaction making it.
+func (o *outbox) enqueue(ctx context.Context, tx dbTx, eventType, shortCode, originalURL string) error {
+	if o == nil {
+		return nil
+	}
+	now := time.Now()
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO outbox (event_type, short_code, original_url, next_attempt_at, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $4)
+	`, eventType, shortCode, originalURL, now)
+	return err
+}
+
+// outboxEvent is the JSON delivered for each row. Its fields are part of
+// the service's interface, like metric names.
+type outboxEvent struct {
+	ID          string `json:"id"` // Unique and growing, for dedup
+	Type        string `json:"type"`
+	Time        string `json:"time"` // RFC3339 with nanoseconds, when the change was made
+	ShortCode   string `json:"short_code"`
+	TenantID    string `json:"tenant_id,omitempty"`
+	OriginalURL string `json:"original_url,omitempty"`
+	Attempt     int    `json:"attempt"` // From 1, more than 1 for redeliveries
+
+	id int64
+}
+
+// runOutbox dispatches the outbox every poll interval until ctx is
+// cancelled, right away again while it finds full batches.
+func (s *storageServer) runOutbox(ctx context.Context) {
+	o := s.outbox
+	log.Printf("Outbox dispatching to %s every %s in batches of %d, up to %d attempts",
+		o.config.webhookURL, o.config.pollInterval, o.config.batchSize, o.config.maxAttempts)
+
+	ticker := time.NewTicker(o.config.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		full := s.dispatchOutbox(ctx)
+		s.updateOutboxMetrics(ctx)
+		if full && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Outbox dispatcher stopped")
+			return
+		case <-ticker.C:
+			s.purgeOutbox(ctx)
+		}
+	}
+}
+
+// dispatchOutbox delivers one batch and reports whether it was full.
+func (s *storageServer) dispatchOutbox(ctx context.Context) bool {
+	o := s.outbox
+	token, events, err := s.claimOutbox(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to claim outbox events: %v", err)
+		}
+		return false
+	}
+	if len(events) == 0 {
+		return false
+	}
+
+	ids := make([]int64, len(events))
+	for i, e := range events {
+		ids[i] = e.id
+	}
+	if err := o.deliver(ctx, events); err != nil {
+		// Left claimed, the rows go out again once the lease runs out
+		if ctx.Err() != nil {
+			return false
+		}
+		log.Printf("Failed to deliver %d outbox events: %v", len(events), err)
+		o.metrics.outboxFailures.Inc()
+		if err := s.failOutbox(ctx, token, ids, err); err != nil {
+			log.Printf("Failed to record outbox failure: %v", err)
+		}
+		return false
+	}
+
+	marked, err := s.completeOutbox(ctx, token, ids)
+	if err != nil {
+		log.Printf("Failed to mark %d delivered outbox events done: %v", len(events), err)
+		return false
+	}
+	o.metrics.outboxDelivered.Add(float64(marked))
+	return len(events) == o.config.batchSize
+}
+
+// claimOutbox leases the oldest due pending rows to a new claim token. A
+// row whose lease ran out is due again, its dispatcher having died or hung.
+// Claiming counts as an attempt, so rows that keep killing the dispatcher
+// end up dead too.
+func (s *storageServer) claimOutbox(ctx context.Context) (string, []outboxEvent, error) {
+	o := s.outbox
+	token, err := newClaimToken()
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+
+	// Other replicas skip the rows being claimed rather than claiming them
+	// twice; SQLite has a single writer anyway
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE outbox
+		SET claim_token = $1, claimed_until = $2, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id
+			FROM outbox
+			WHERE status = 'pending' AND next_attempt_at <= $3 AND (claimed_until IS NULL OR claimed_until <= $3)
+			ORDER BY id
+			LIMIT $4`+s.db.dialect.sql(" FOR UPDATE SKIP LOCKED", "")+`
+		)
+		RETURNING id, event_type, short_code, COALESCE(original_url, ''), attempts, created_at
+	`, token, now.Add(o.config.lease), now, o.config.batchSize)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+
+	var events []outboxEvent
+	for rows.Next() {
+		var key string
+		var createdAt time.Time
+		var e outboxEvent
+		if err := rows.Scan(&e.id, &e.Type, &key, &e.OriginalURL, &e.Attempt, &createdAt); err != nil {
+			return "", nil, err
+		}
+		e.ID = strconv.FormatInt(e.id, 10)
+		e.Time = createdAt.UTC().Format(time.RFC3339Nano)
+		e.ShortCode = key
+		if tenant, code, ok := strings.Cut(key, ":"); ok {
+			e.TenantID, e.ShortCode = tenant, code
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return "", nil, err
+	}
+
+	// RETURNING comes in no particular order
+	sort.Slice(events, func(i, j int) bool { return events[i].id < events[j].id })
+	return token, events, nil
+}
+
+// deliver POSTs events to the webhook, which has to answer 2xx.
+func (o *outbox) deliver(ctx context.Context, events []outboxEvent) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.config.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// completeOutbox marks the rows of a delivered claim done. Only rows still
+// held by the claim are marked, so a row is marked done once even when a
+// dispatcher whose lease ran out delivers it after another one did.
+func (s *storageServer) completeOutbox(ctx context.Context, token string, ids []int64) (int64, error) {
+	d := s.db.dialect
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE outbox
+		SET status = 'done', delivered_at = $3, claim_token = NULL, claimed_until = NULL, last_error = NULL
+		WHERE claim_token = $1 AND status = 'pending' AND `+d.anyOf("id", "$2")+`
+	`, token, d.array(ids), time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// failOutbox releases the rows of a failed claim to be retried after a
+// backoff that doubles with each attempt, or marks those out of attempts
+// dead.
+func (s *storageServer) failOutbox(ctx context.Context, token string, ids []int64, cause error) error {
+	o := s.outbox
+	d := s.db.dialect
+	lastError := cause.Error()
+	query := `
+		UPDATE outbox
+		SET status = CASE WHEN attempts >= $3 THEN 'dead' ELSE 'pending' END,
+			next_attempt_at = $4, claim_token = NULL, claimed_until = NULL, last_error = $5
+		WHERE claim_token = $1 AND status = 'pending' AND ` + d.anyOf("id", "$2") + `
+		RETURNING status, attempts
+	`
+	return s.db.inTx(ctx, query, func(ctx context.Context, tx dbTx) error {
+		// The rows of a batch are mostly tried together, they share the
+		// backoff of the least tried one
+		var attempts int
+		if err := tx.QueryRowContext(ctx, `
+			SELECT COALESCE(MIN(attempts), 1) FROM outbox WHERE claim_token = $1
+		`, token).Scan(&attempts); err != nil {
+			return err
+		}
+		next := time.Now().Add(o.backoff(attempts))
+
+		rows, err := tx.QueryContext(ctx, query, token, d.array(ids), o.config.maxAttempts, next, lastError)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		var dead int
+		for rows.Next() {
+			var status string
+			var n int
+			if err := rows.Scan(&status, &n); err != nil {
+				return err
+			}
+			if status == outboxDead {
+				dead++
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if dead > 0 {
+			log.Printf("Warning: %d outbox events are dead after %d attempts: %s", dead, o.config.maxAttempts, lastError)
+			o.metrics.outboxDeadLettered.Add(float64(dead))
+		}
+		return nil
+	})
+}
+
+// backoff is the wait before the attempt after the given one.
+func (o *outbox) backoff(attempts int) time.Duration {
+	wait := o.config.pollInterval
+	for i := 1; i < attempts && wait < outboxMaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > outboxMaxBackoff {
+		wait = outboxMaxBackoff
+	}
+	return wait
+}
+
+// purgeOutbox deletes delivered rows past the retention. Dead rows stay
+// until someone deals with them.
+func (s *storageServer) purgeOutbox(ctx context.Context) {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM outbox WHERE status = 'done' AND delivered_at <= $1
+	`, time.Now().Add(-s.outbox.config.retention))
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Failed to purge delivered outbox events: %v", err)
+	}
+}
+
+// updateOutboxMetrics sets the depth gauges from the table.
+func (s *storageServer) updateOutboxMetrics(ctx context.Context) {
+	m := s.outbox.metrics
+	var pending, dead int64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM(CASE WHEN status = 'pending' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = 'dead' THEN 1 ELSE 0 END), 0)
+		FROM outbox
+		WHERE status <> 'done'
+	`).Scan(&pending, &dead)
+	var oldest sql.NullTime
+	if err == nil {
+		err = s.db.QueryRowContext(ctx, `
+			SELECT MIN(created_at) FROM outbox WHERE status = 'pending'
+		`).Scan(&oldest)
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to read outbox depth: %v", err)
+		}
+		return
+	}
+	m.outboxDepth.WithLabelValues(outboxPending).Set(float64(pending))
+	m.outboxDepth.WithLabelValues(outboxDead).Set(float64(dead))
+	age := 0.0
+	if oldest.Valid {
+		age = time.Since(oldest.Time).Seconds()
+	}
+	m.outboxOldestPending.Set(age)
+}
+
+// newClaimToken returns a random token naming one claim of outbox rows.
+func newClaimToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
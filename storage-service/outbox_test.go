@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+)
+
+// webhookRecorder is an outbox receiver that records the attempts at each
+// event and can hang until the sender gives up.
+type webhookRecorder struct {
+	mu       sync.Mutex
+	attempts map[string][]int
+	hang     bool
+	arrived  chan struct{}
+}
+
+func (w *webhookRecorder) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	var events []outboxEvent
+	if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.mu.Lock()
+	for _, e := range events {
+		w.attempts[e.ID] = append(w.attempts[e.ID], e.Attempt)
+	}
+	hang := w.hang
+	w.mu.Unlock()
+
+	if hang {
+		w.arrived <- struct{}{}
+		<-r.Context().Done()
+	}
+}
+
+func (w *webhookRecorder) reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.attempts = make(map[string][]int)
+	w.hang = false
+	w.arrived = make(chan struct{}, 1)
+}
+
+// delivered returns the attempts received for each event so far.
+func (w *webhookRecorder) delivered() map[string][]int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return maps.Clone(w.attempts)
+}
+
+func (w *webhookRecorder) setHang(hang bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.hang = hang
+}
+
+// setOutboxEnv points the storage servers a test creates at a receiver.
+func setOutboxEnv(t *testing.T, h http.Handler, batchSize int, lease time.Duration) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	t.Setenv("OUTBOX_WEBHOOK_URL", srv.URL)
+	t.Setenv("OUTBOX_BATCH_SIZE", fmt.Sprint(batchSize))
+	t.Setenv("OUTBOX_LEASE", lease.String())
+}
+
+func countOutbox(t *testing.T, s *storageServer, where string) int {
+	t.Helper()
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM outbox WHERE ` + where).Scan(&n); err != nil {
+		t.Fatalf("counting outbox rows: %v", err)
+	}
+	return n
+}
+
+func TestOutboxDispatcherKilledMidBatch(t *testing.T) {
+	const lease = 200 * time.Millisecond
+	webhook := &webhookRecorder{}
+	setOutboxEnv(t, webhook, 5, lease)
+
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		webhook.reset()
+		for i := 0; i < 12; i++ {
+			saveURL(t, s, &proto.SaveURLRequest{ShortCode: fmt.Sprintf("code%d", i), OriginalUrl: "https://example.com"})
+		}
+
+		// The dispatcher dies while the receiver has its first batch
+		webhook.setHang(true)
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-webhook.arrived
+			cancel()
+		}()
+		s.dispatchOutbox(ctx)
+		webhook.setHang(false)
+
+		if n := countOutbox(t, s, `claim_token IS NOT NULL AND status = 'pending'`); n != 5 {
+			t.Fatalf("%d rows claimed after the dispatcher died, want its batch of 5", n)
+		}
+		lost := webhook.delivered()
+		if len(lost) != 5 {
+			t.Fatalf("receiver got %d events of the lost batch, want 5", len(lost))
+		}
+		// Another dispatcher leaves the leased rows alone
+		_, events, err := s.claimOutbox(context.Background())
+		if err != nil {
+			t.Fatalf("claimOutbox: %v", err)
+		}
+		for _, e := range events {
+			if _, ok := lost[e.ID]; ok {
+				t.Errorf("event %s claimed while leased", e.ID)
+			}
+		}
+
+		// Once the leases run out everything goes out, the lost batch
+		// again and the rest once
+		time.Sleep(lease + 50*time.Millisecond)
+		for s.dispatchOutbox(context.Background()) {
+		}
+
+		delivered := webhook.delivered()
+		if len(delivered) != 12 {
+			t.Errorf("%d events delivered, want 12", len(delivered))
+		}
+		for id, attempts := range delivered {
+			_, redelivered := lost[id]
+			if redelivered && !slices.Equal(attempts, []int{1, 2}) || !redelivered && len(attempts) != 1 {
+				t.Errorf("event %s delivered as attempts %v", id, attempts)
+			}
+		}
+		if n := countOutbox(t, s, `status = 'done'`); n != 12 {
+			t.Errorf("%d rows done, want 12", n)
+		}
+	})
+}
+
+func TestOutboxCompletesOnce(t *testing.T) {
+	const lease = 100 * time.Millisecond
+	setOutboxEnv(t, http.NotFoundHandler(), 10, lease)
+
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		for i := 0; i < 3; i++ {
+			saveURL(t, s, &proto.SaveURLRequest{ShortCode: fmt.Sprintf("code%d", i), OriginalUrl: "https://example.com"})
+		}
+
+		stale, events, err := s.claimOutbox(ctx)
+		if err != nil || len(events) != 3 {
+			t.Fatalf("claimOutbox = %v, %v, want 3 events", events, err)
+		}
+		ids := make([]int64, len(events))
+		for i, e := range events {
+			ids[i] = e.id
+		}
+
+		// The first dispatcher hangs past its lease and a second one
+		// delivers the same rows
+		time.Sleep(lease + 20*time.Millisecond)
+		token, events, err := s.claimOutbox(ctx)
+		if err != nil || len(events) != 3 || events[0].Attempt != 2 {
+			t.Fatalf("claimOutbox after the lease = %v, %v, want 3 events on attempt 2", events, err)
+		}
+		if marked, err := s.completeOutbox(ctx, token, ids); err != nil || marked != 3 {
+			t.Errorf("completeOutbox = %d, %v, want 3", marked, err)
+		}
+
+		// The first one waking up marks nothing
+		if marked, err := s.completeOutbox(ctx, stale, ids); err != nil || marked != 0 {
+			t.Errorf("stale completeOutbox = %d, %v, want 0", marked, err)
+		}
+		if err := s.failOutbox(ctx, stale, ids, fmt.Errorf("timeout")); err != nil {
+			t.Errorf("stale failOutbox: %v", err)
+		}
+		if n := countOutbox(t, s, `status = 'done' AND last_error IS NULL`); n != 3 {
+			t.Errorf("%d rows done, want 3", n)
+		}
+	})
+}
+
+func TestOutboxFailureBacksOff(t *testing.T) {
+	setOutboxEnv(t, http.NotFoundHandler(), 10, time.Minute)
+	t.Setenv("OUTBOX_MAX_ATTEMPTS", "2")
+
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "code", OriginalUrl: "https://example.com"})
+
+		// A failed batch is released but not due until its backoff passes
+		s.dispatchOutbox(ctx)
+		if n := countOutbox(t, s, `status = 'pending' AND claim_token IS NULL AND last_error IS NOT NULL`); n != 1 {
+			t.Fatalf("%d rows released after a failure, want 1", n)
+		}
+		if _, events, _ := s.claimOutbox(ctx); len(events) != 0 {
+			t.Errorf("claimed %d events during the backoff", len(events))
+		}
+
+		// Out of attempts, the row is dead
+		if _, err := s.db.Exec(`UPDATE outbox SET next_attempt_at = $1`, time.Now()); err != nil {
+			t.Fatalf("skipping the backoff: %v", err)
+		}
+		s.dispatchOutbox(ctx)
+		if n := countOutbox(t, s, `status = 'dead'`); n != 1 {
+			t.Errorf("%d rows dead after 2 failed attempts, want 1", n)
+		}
+	})
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	o := &outbox{config: outboxConfig{pollInterval: time.Second}}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{20, outboxMaxBackoff},
+	}
+	for _, tt := range tests {
+		if got := o.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const defaultPendingClickRetention = 24 * time.Hour
+
+// url-service saves new links asynchronously and can flush their first
+// clicks before the link reaches storage. Rather than dropping them, the
+// deltas for a code with no row at all are held in pending_clicks and added
+// to the link when SaveURL or SaveURLs inserts it. A save racing the flush
+// can miss them, so the cleanup loop adds what is left for links that exist
+// by then, and drops deltas whose link never arrived after
+// PENDING_CLICK_RETENTION. Codes whose row is deleted still count as
+// missing.
+
+// bufferClicks holds the deltas of the shortCodes that have no row, adding
+// to what is already held for them, and returns those codes.
+func (s *storageServer) bufferClicks(ctx context.Context, tx dbTx, shortCodes []string, deltas map[string]clickDelta) (map[string]bool, error) {
+	buffered := make(map[string]bool, len(shortCodes))
+	if len(shortCodes) == 0 {
+		return buffered, nil
+	}
+
+	from, args := s.clickDeltaValues(shortCodes, deltas)
+	now := len(args) + 1
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`
+		INSERT INTO pending_clicks (tenant_id, short_code, click_count, unique_clicks, bot_clicks, last_accessed_at, created_at, updated_at)
+		SELECT `+keyTenant("v.short_code")+`, `+keyCode("v.short_code")+`, v.delta, v.unique_delta, v.bot_delta, v.last_accessed, $%d, $%d
+		FROM `+from+`
+		WHERE NOT EXISTS (SELECT 1 FROM urls WHERE `+keyIs("urls", "v.short_code")+`)
+		ON CONFLICT (tenant_id, short_code) DO UPDATE SET
+			click_count = pending_clicks.click_count + EXCLUDED.click_count,
+			unique_clicks = pending_clicks.unique_clicks + EXCLUDED.unique_clicks,
+			bot_clicks = pending_clicks.bot_clicks + EXCLUDED.bot_clicks,
+			last_accessed_at = `+laterOf("pending_clicks.last_accessed_at", "EXCLUDED.last_accessed_at")+`,
+			updated_at = EXCLUDED.updated_at
+		RETURNING `+keyOf("pending_clicks")+`
+	`, now, now), append(args, time.Now())...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var shortCode string
+		if err := rows.Scan(&shortCode); err != nil {
+			return nil, err
+		}
+		buffered[shortCode] = true
+	}
+	return buffered, rows.Err()
+}
+
+// applyPendingClicksQuery takes what is held for the codes in $1.
+func applyPendingClicksQuery(d dialect) string {
+	return `
+		DELETE FROM pending_clicks
+		WHERE ` + d.keysIn("pending_clicks", "$1") + `
+		RETURNING ` + keyOf("pending_clicks") + `, click_count, unique_clicks, bot_clicks, last_accessed_at
+	`
+}
+
+// applyPendingClicks adds the deltas held for shortCodes to their links and
+// forgets them. It returns the number of codes that had any.
+func applyPendingClicks(ctx context.Context, tx dbTx, d dialect, shortCodes []string) (int64, error) {
+	rows, err := tx.QueryContext(ctx, applyPendingClicksQuery(d), d.array(shortCodes))
+	if err != nil {
+		return 0, err
+	}
+	held := make(map[string]clickDelta)
+	for rows.Next() {
+		var shortCode string
+		var delta clickDelta
+		if err := rows.Scan(&shortCode, &delta.clicks, &delta.unique, &delta.bot, &delta.lastAccessed); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		held[shortCode] = delta
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Rarely more than a few, most saves find none
+	lastAccessed := d.sql("$5::timestamptz", "$5")
+	for shortCode, delta := range held {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE urls
+			SET click_count = click_count + $2,
+				unique_clicks = unique_clicks + $3,
+				bot_clicks = bot_clicks + $4,
+				last_accessed_at = `+laterOf("last_accessed_at", lastAccessed)+`
+			WHERE `+keyIs("urls", "$1")+`
+		`, shortCode, delta.clicks, delta.unique, delta.bot, delta.lastAccessed); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(held)), nil
+}
+
+// reconcilePendingClicks adds the deltas still held for links that exist
+// to them, up to batchSize at a time, and drops those held longer than
+// retention for links that don't.
+func (s *storageServer) reconcilePendingClicks(ctx context.Context, batchSize int, retention time.Duration) (applied, expired int64, err error) {
+	for ctx.Err() == nil {
+		var n int64
+		err = s.db.inTx(ctx, applyPendingClicksQuery(s.db.dialect), func(ctx context.Context, tx dbTx) error {
+			rows, err := tx.QueryContext(ctx, `
+				SELECT `+keyOf("p")+`
+				FROM pending_clicks p
+				JOIN urls ON urls.tenant_id = p.tenant_id AND urls.short_code = p.short_code
+				ORDER BY p.tenant_id, p.short_code
+				LIMIT $1
+			`, batchSize)
+			if err != nil {
+				return err
+			}
+			var shortCodes []string
+			for rows.Next() {
+				var shortCode string
+				if err := rows.Scan(&shortCode); err != nil {
+					rows.Close()
+					return err
+				}
+				shortCodes = append(shortCodes, shortCode)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil || len(shortCodes) == 0 {
+				return err
+			}
+			n, err = applyPendingClicks(ctx, tx, s.db.dialect, shortCodes)
+			return err
+		})
+		if err != nil {
+			return applied, 0, err
+		}
+		applied += n
+		if n < int64(batchSize) {
+			break
+		}
+	}
+
+	expired, err = s.deleteInBatches(ctx, batchSize, `
+		DELETE FROM pending_clicks
+		WHERE (tenant_id, short_code) IN (
+			SELECT tenant_id, short_code
+			FROM pending_clicks
+			WHERE created_at <= $2
+				AND NOT EXISTS (SELECT 1 FROM urls WHERE urls.tenant_id = pending_clicks.tenant_id AND urls.short_code = pending_clicks.short_code)
+			ORDER BY created_at
+			LIMIT $1
+		)
+	`, time.Now().Add(-retention))
+	return applied, expired, err
+}
+
+// cleanupPendingClicks runs reconcilePendingClicks for the cleanup loop.
+func (s *storageServer) cleanupPendingClicks(ctx context.Context, config cleanupConfig) error {
+	applied, expired, err := s.reconcilePendingClicks(ctx, config.batchSize, config.pendingClickRetention)
+	if applied > 0 || expired > 0 {
+		log.Printf("Added held clicks to %d saved URLs, dropped them for %d never saved", applied, expired)
+	}
+	return err
+}
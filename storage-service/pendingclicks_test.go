@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// clickStats returns the click, unique and bot counts of shortCode.
+func clickStats(t *testing.T, s *storageServer, shortCode string) [3]int64 {
+	t.Helper()
+	stats, err := s.GetStats(context.Background(), &proto.GetStatsRequest{ShortCode: shortCode})
+	if err != nil {
+		t.Fatalf("GetStats %s: %v", shortCode, err)
+	}
+	return [3]int64{stats.ClickCount, stats.UniqueClicks, stats.BotClicks}
+}
+
+// heldClicks returns the number of codes with clicks held.
+func heldClicks(t *testing.T, s *storageServer) int {
+	t.Helper()
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM pending_clicks`).Scan(&n); err != nil {
+		t.Fatalf("counting held clicks: %v", err)
+	}
+	return n
+}
+
+func TestConformancePendingClicksBeforeSave(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+
+		// The clicks of a link whose save is still queued are held
+		for i := 0; i < 3; i++ {
+			if _, err := s.IncrementClick(ctx, &proto.IncrementClickRequest{ShortCode: "early"}); err != nil {
+				t.Fatalf("IncrementClick before the save: %v", err)
+			}
+		}
+		resp, err := s.BatchIncrementClicks(ctx, &proto.BatchIncrementClicksRequest{Deltas: []*proto.ClickDelta{
+			{ShortCode: "early", Delta: 4, UniqueDelta: 2, BotDelta: 1},
+			{ShortCode: "batched", Delta: 2},
+		}})
+		if err != nil {
+			t.Fatalf("BatchIncrementClicks: %v", err)
+		}
+		buffered := slices.Sorted(slices.Values(resp.BufferedShortCodes))
+		if resp.Updated != 0 || !slices.Equal(buffered, []string{"batched", "early"}) {
+			t.Errorf("BatchIncrementClicks = %v, want both held", resp)
+		}
+		if n := heldClicks(t, s); n != 2 {
+			t.Errorf("%d codes held, want 2", n)
+		}
+
+		// And added once it lands, by SaveURL or SaveURLs
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "early", OriginalUrl: "https://example.com/early"})
+		if got := clickStats(t, s, "early"); got != [3]int64{7, 2, 1} {
+			t.Errorf("early has %v clicks, unique and bots, want 7, 2 and 1", got)
+		}
+		if _, err := s.SaveURLs(ctx, &proto.SaveURLsRequest{Urls: []*proto.SaveURLRequest{
+			{ShortCode: "batched", OriginalUrl: "https://example.com/batched"},
+			{ShortCode: "other", OriginalUrl: "https://example.com/other"},
+		}}); err != nil {
+			t.Fatalf("SaveURLs: %v", err)
+		}
+		if got := clickStats(t, s, "batched"); got[0] != 2 {
+			t.Errorf("batched has %d clicks, want 2", got[0])
+		}
+		if got := clickStats(t, s, "other"); got[0] != 0 {
+			t.Errorf("other has %d clicks, want none", got[0])
+		}
+		if n := heldClicks(t, s); n != 0 {
+			t.Errorf("%d codes still held after their saves", n)
+		}
+
+		// Saving again doesn't add them twice, and later clicks go straight on
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "early", OriginalUrl: "https://example.com/moved"})
+		if _, err := s.IncrementClick(ctx, &proto.IncrementClickRequest{ShortCode: "early"}); err != nil {
+			t.Fatalf("IncrementClick: %v", err)
+		}
+		if got := clickStats(t, s, "early"); got[0] != 8 {
+			t.Errorf("early has %d clicks after another save and click, want 8", got[0])
+		}
+
+		// A deleted link's clicks aren't held for it to come back
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "gone", OriginalUrl: "https://example.com/gone"})
+		if _, err := s.DeleteURL(ctx, &proto.DeleteURLRequest{ShortCode: "gone"}); err != nil {
+			t.Fatalf("DeleteURL: %v", err)
+		}
+		if _, err := s.IncrementClick(ctx, &proto.IncrementClickRequest{ShortCode: "gone"}); status.Code(err) != codes.NotFound {
+			t.Errorf("IncrementClick of a deleted link: got %v, want NotFound", err)
+		}
+		resp, err = s.BatchIncrementClicks(ctx, &proto.BatchIncrementClicksRequest{Deltas: []*proto.ClickDelta{{ShortCode: "gone", Delta: 1}}})
+		if err != nil || !slices.Equal(resp.MissingShortCodes, []string{"gone"}) || len(resp.BufferedShortCodes) != 0 {
+			t.Errorf("BatchIncrementClicks of a deleted link = %v, %v, want it missing", resp, err)
+		}
+	})
+}
+
+func TestConformancePendingClicksInterleaved(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		const clicks = 40
+
+		// Clicks flushed while the link is being saved, some before and some
+		// after
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		counted := 0
+		for i := 0; i < clicks; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := s.IncrementClick(ctx, &proto.IncrementClickRequest{ShortCode: "racing"}); err != nil {
+					t.Errorf("IncrementClick: %v", err)
+					return
+				}
+				mu.Lock()
+				counted++
+				mu.Unlock()
+			}()
+			if i == clicks/2 {
+				saveURL(t, s, &proto.SaveURLRequest{ShortCode: "racing", OriginalUrl: "https://example.com/racing"})
+			}
+		}
+		wg.Wait()
+
+		// Whatever a save raced past is settled by the cleanup loop
+		if _, _, err := s.reconcilePendingClicks(ctx, 10, time.Hour); err != nil {
+			t.Fatalf("reconcilePendingClicks: %v", err)
+		}
+		if got := clickStats(t, s, "racing"); got[0] != int64(counted) || counted != clicks {
+			t.Errorf("racing has %d clicks, %d of %d counted", got[0], counted, clicks)
+		}
+		if n := heldClicks(t, s); n != 0 {
+			t.Errorf("%d codes still held", n)
+		}
+	})
+}
+
+func TestConformanceReconcilePendingClicks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "raced", OriginalUrl: "https://example.com"})
+		// Held by a flush the save didn't see, and for a link never saved
+		old := time.Now().Add(-2 * time.Hour)
+		for _, held := range []struct {
+			code   string
+			clicks int64
+			at     time.Time
+		}{{"raced", 5, time.Now()}, {"never", 3, old}, {"recent", 1, time.Now()}} {
+			if _, err := s.db.ExecContext(ctx, `
+				INSERT INTO pending_clicks (short_code, click_count, unique_clicks, bot_clicks, created_at, updated_at)
+				VALUES ($1, $2, 0, 0, $3, $3)
+			`, held.code, held.clicks, held.at); err != nil {
+				t.Fatalf("holding clicks for %s: %v", held.code, err)
+			}
+		}
+
+		applied, expired, err := s.reconcilePendingClicks(ctx, 10, time.Hour)
+		if err != nil {
+			t.Fatalf("reconcilePendingClicks: %v", err)
+		}
+		if applied != 1 || expired != 1 {
+			t.Errorf("reconcilePendingClicks applied %d and expired %d, want 1 and 1", applied, expired)
+		}
+		if got := clickStats(t, s, "raced"); got[0] != 5 {
+			t.Errorf("raced has %d clicks, want the 5 held", got[0])
+		}
+		// A link saved within the retention still gets its clicks
+		if n := heldClicks(t, s); n != 1 {
+			t.Errorf("%d codes held, want only recent", n)
+		}
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "recent", OriginalUrl: "https://example.com"})
+		if got := clickStats(t, s, "recent"); got[0] != 1 {
+			t.Errorf("recent has %d clicks, want 1", got[0])
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const defaultReplicaCheckInterval = 5 * time.Second
+
+// replica is a read-only Postgres standby that GetURL and GetStats can be
+// served from.
+type replica struct {
+	host    string
+	db      tracedDB
+	healthy atomic.Bool
+}
+
+// replicaSet spreads reads over the healthy replicas in turn.
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+// openReplicas connects to the comma separated host[:port] list in
+// DB_REPLICA_HOSTS with the primary's credentials and pool settings. The
+// pools connect lazily, and replicas only take reads once watch has
+// pinged them.
+func openReplicas(config Config, primary tracedDB) (*replicaSet, error) {
+	set := &replicaSet{}
+	for _, addr := range strings.Split(config.DBReplicaHosts, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		host, port := addr, config.Port
+		if h, p, err := net.SplitHostPort(addr); err == nil {
+			host, port = h, p
+		}
+
+		db, err := sql.Open("postgres", postgresConnString(config, host, port))
+		if err != nil {
+			set.Close()
+			return nil, err
+		}
+		configurePool(db, config)
+
+		r := &replica{host: addr, db: primary}
+		r.db.DB = db
+		set.replicas = append(set.replicas, r)
+	}
+	if len(set.replicas) == 0 {
+		return nil, nil
+	}
+	return set, nil
+}
+
+// pick returns the next healthy replica, or nil if none is.
+func (rs *replicaSet) pick() *replica {
+	n := uint64(len(rs.replicas))
+	start := rs.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if r := rs.replicas[(start+i)%n]; r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// watch pings every replica each interval until ctx is cancelled. A
+// replica stops taking reads on its first failed ping, since the primary
+// can always serve them, and takes them again once a ping succeeds.
+func (rs *replicaSet) watch(ctx context.Context, interval time.Duration, metrics *serviceMetrics) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, r := range rs.replicas {
+			checkCtx, cancel := context.WithTimeout(ctx, interval)
+			err := r.db.PingContext(checkCtx)
+			cancel()
+
+			healthy := err == nil
+			if r.healthy.Swap(healthy) != healthy {
+				if healthy {
+					log.Printf("Replica %s is healthy, serving reads", r.host)
+				} else {
+					log.Printf("Replica %s is unhealthy, reading from the primary instead: %v", r.host, err)
+				}
+			}
+			if healthy {
+				metrics.dbReplicaHealthy.WithLabelValues(r.host).Set(1)
+			} else {
+				metrics.dbReplicaHealthy.WithLabelValues(r.host).Set(0)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (rs *replicaSet) Close() error {
+	var errs []error
+	for _, r := range rs.replicas {
+		errs = append(errs, r.db.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// reader returns the database to serve a read from: the next healthy
+// replica, or the primary when forcePrimary is set or none is healthy.
+// Replicas lag behind the primary, so reads that must see a write just
+// made set forcePrimary.
+func (s *storageServer) reader(forcePrimary bool) tracedDB {
+	if s.replicas != nil && !forcePrimary {
+		if r := s.replicas.pick(); r != nil {
+			s.metrics.dbReads.WithLabelValues(r.host).Inc()
+			return r.db
+		}
+	}
+	s.metrics.dbReads.WithLabelValues("primary").Inc()
+	return s.db
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+)
+
+// withReplicas gives s a replica for each name, each a SQLite database of
+// its own where "who" points at https://<name>.example, healthy until
+// marked otherwise.
+func withReplicas(t *testing.T, s *storageServer, names ...string) *replicaSet {
+	t.Helper()
+	set := &replicaSet{}
+	for _, name := range names {
+		standby := newTestServer(t)
+		saveURL(t, standby, &proto.SaveURLRequest{ShortCode: "who", OriginalUrl: "https://" + name + ".example"})
+		r := &replica{host: name, db: standby.db}
+		r.healthy.Store(true)
+		set.replicas = append(set.replicas, r)
+	}
+	s.replicas = set
+	return set
+}
+
+// readFrom returns which database served a GetURL of "who".
+func readFrom(t *testing.T, s *storageServer, forcePrimary bool) string {
+	t.Helper()
+	resp, err := s.GetURL(context.Background(), &proto.GetURLRequest{ShortCode: "who", ForcePrimary: forcePrimary})
+	if err != nil {
+		t.Fatalf("GetURL: %v", err)
+	}
+	switch resp.OriginalUrl {
+	case "https://r1.example":
+		return "r1"
+	case "https://r2.example":
+		return "r2"
+	}
+	return "primary"
+}
+
+func TestReplicaRouting(t *testing.T) {
+	s := newTestServer(t)
+	saveURL(t, s, &proto.SaveURLRequest{ShortCode: "who", OriginalUrl: "https://primary.example"})
+	set := withReplicas(t, s, "r1", "r2")
+
+	served := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		served[readFrom(t, s, false)]++
+	}
+	if served["r1"] != 5 || served["r2"] != 5 {
+		t.Errorf("reads served %v, want 5 by each replica", served)
+	}
+	if got := readFrom(t, s, true); got != "primary" {
+		t.Errorf("force_primary read served by %s", got)
+	}
+
+	set.replicas[0].healthy.Store(false)
+	for i := 0; i < 3; i++ {
+		if got := readFrom(t, s, false); got != "r2" {
+			t.Errorf("read with r1 unhealthy served by %s, want r2", got)
+		}
+	}
+	set.replicas[1].healthy.Store(false)
+	if got := readFrom(t, s, false); got != "primary" {
+		t.Errorf("read with no healthy replica served by %s, want primary", got)
+	}
+
+	reads := gathered(t, s.metrics.registry, "storage_service_db_reads_total")
+	for target, want := range map[string]float64{"target=r1": 5, "target=r2": 8, "target=primary": 2} {
+		if reads[target] != want {
+			t.Errorf("reads %s = %v, want %v", target, reads[target], want)
+		}
+	}
+}
+
+func TestReplicaWatch(t *testing.T) {
+	s := newTestServer(t)
+	saveURL(t, s, &proto.SaveURLRequest{ShortCode: "who", OriginalUrl: "https://primary.example"})
+	set := withReplicas(t, s, "r1", "r2")
+	for _, r := range set.replicas {
+		r.healthy.Store(false)
+	}
+
+	// A closed pool fails its pings, the other replica recovers
+	set.replicas[0].db.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		set.watch(ctx, 10*time.Millisecond, s.metrics)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for !set.replicas[1].healthy.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("r2 not healthy 5s after watching started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if set.replicas[0].healthy.Load() {
+		t.Error("r1 healthy with its pool closed")
+	}
+	if got := readFrom(t, s, false); got != "r2" {
+		t.Errorf("read served by %s, want r2", got)
+	}
+	healthy := gathered(t, s.metrics.registry, "storage_service_db_replica_healthy")
+	if healthy["host=r1"] != 0 || healthy["host=r2"] != 1 {
+		t.Errorf("replica health %v, want r1 0 and r2 1", healthy)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Report statuses.
+const (
+	reportOpen     = "open"
+	reportActioned = "actioned"
+)
+
+// ReportURL stores an abuse report on a URL. A reporter's second report on
+// the same code and UTC day is a duplicate and not stored. With a disable
+// threshold the URL is disabled, and its open reports actioned, in the same
+// transaction once that many distinct reporters have open reports.
+func (s *storageServer) ReportURL(ctx context.Context, req *proto.ReportURLRequest) (*proto.ReportURLResponse, error) {
+	logf(ctx, "Storage ReportURL request for: %s", req.ShortCode)
+
+	if req.ShortCode == "" || req.Reason == "" || req.Reporter == "" {
+		return nil, status.Error(codes.InvalidArgument, "short_code, reason and reporter are required")
+	}
+
+	now := time.Now()
+	query := `
+		INSERT INTO url_reports (tenant_id, short_code, reason, reporter_contact, reporter, report_day, created_at)
+		VALUES (` + keyTenant("$1") + `, ` + keyCode("$1") + `, $2, NULLIF($3, ''), $4, $5, $6)
+		ON CONFLICT (tenant_id, short_code, reporter, report_day) DO NOTHING
+		RETURNING id
+	`
+	var resp *proto.ReportURLResponse
+	err := s.db.inTx(ctx, query, func(ctx context.Context, tx dbTx) error {
+		resp = &proto.ReportURLResponse{}
+		originalURL, active, deleted, err := lockURL(ctx, tx, req.ShortCode)
+		if err == sql.ErrNoRows || deleted {
+			return status.Errorf(codes.NotFound, "URL not found: %s", req.ShortCode)
+		} else if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, query, req.ShortCode, req.Reason, req.ReporterContact, req.Reporter,
+			now.UTC().Format("2006-01-02"), now).Scan(&resp.ReportId)
+		if err == sql.ErrNoRows {
+			resp.Duplicate = true
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, `
+			SELECT COUNT(DISTINCT reporter) FROM url_reports WHERE `+keyIs("url_reports", "$1")+` AND status = $2
+		`, req.ShortCode, reportOpen).Scan(&resp.Reporters)
+		if err != nil {
+			return err
+		}
+		if req.DisableThreshold <= 0 || resp.Reporters < req.DisableThreshold {
+			return nil
+		}
+
+		if active {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE urls SET is_active = false, updated_at = NOW() WHERE `+keyIs("urls", "$1")+`
+			`, req.ShortCode); err != nil {
+				return err
+			}
+			if err := recordHistory(ctx, tx, req.ShortCode, historyDisable, originalURL, originalURL); err != nil {
+				return err
+			}
+			resp.Disabled = true
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE url_reports SET status = $2 WHERE `+keyIs("url_reports", "$1")+` AND status = $3
+		`, req.ShortCode, reportActioned, reportOpen)
+		return err
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil, err
+	} else if err != nil {
+		logf(ctx, "Failed to store report: %v", err)
+		return nil, dbError(err, "failed to store report")
+	}
+
+	if resp.Disabled {
+		logf(ctx, "URL %s disabled after reports from %d reporters", req.ShortCode, resp.Reporters)
+	}
+	return resp, nil
+}
+
+// ListReports returns a page of abuse reports, newest first, optionally
+// only those with a status or on one URL.
+func (s *storageServer) ListReports(ctx context.Context, req *proto.ListReportsRequest) (*proto.ListReportsResponse, error) {
+	logf(ctx, "Storage ListReports request for status %q, code %q", req.Status, req.ShortCode)
+
+	if req.Status != "" && req.Status != reportOpen && req.Status != reportActioned {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid status %q, want %s or %s", req.Status, reportOpen, reportActioned)
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 || pageSize > maxFindLimit {
+		pageSize = maxFindLimit
+	}
+
+	// Row IDs only grow, so the cursor is the last ID returned
+	var before int64 = 1<<63 - 1
+	if req.PageToken != "" {
+		id, err := strconv.ParseInt(req.PageToken, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+		before = id
+	}
+
+	// Fetch one extra row to learn whether there is a next page
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, `+keyOf("url_reports")+`, reason, COALESCE(reporter_contact, ''), status, created_at
+		FROM url_reports
+		WHERE id < $1
+			AND ($2 = '' OR status = $2)
+			AND ($3 = '' OR `+keyIs("url_reports", "$3")+`)
+			AND tenant_id = $5
+		ORDER BY id DESC
+		LIMIT $4
+	`, before, req.Status, req.ShortCode, pageSize+1, req.TenantId)
+	if err != nil {
+		logf(ctx, "Database error: %v", err)
+		return nil, dbError(err, "failed to list reports")
+	}
+	defer rows.Close()
+
+	resp := &proto.ListReportsResponse{}
+	for rows.Next() {
+		if len(resp.Reports) == int(pageSize) {
+			resp.NextPageToken = strconv.FormatInt(resp.Reports[len(resp.Reports)-1].Id, 10)
+			break
+		}
+
+		var report proto.AbuseReport
+		var createdAt time.Time
+		if err := rows.Scan(&report.Id, &report.ShortCode, &report.Reason, &report.ReporterContact, &report.Status, &createdAt); err != nil {
+			return nil, dbError(err, "failed to scan report")
+		}
+		report.CreatedAt = createdAt.Format(time.RFC3339)
+		resp.Reports = append(resp.Reports, &report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "failed to list reports")
+	}
+
+	return resp, nil
+}
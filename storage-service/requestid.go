@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// requestIDHeader carries the ID that ties together log lines from every
+// service involved in a single user action.
+const requestIDHeader = "x-request-id"
+
+type requestIDKey struct{}
+
+// requestIDInterceptor stores the request ID forwarded by url-service in the
+// context so handler logs can be correlated with the caller's.
+func requestIDInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var id string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(requestIDHeader); len(values) > 0 {
+				id = values[0]
+			}
+		}
+		if id == "" {
+			return handler(ctx, req)
+		}
+
+		return handler(context.WithValue(ctx, requestIDKey{}, id), req)
+	}
+}
+
+// requestIDStreamInterceptor does the same for streaming RPCs.
+func requestIDStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		var id string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(requestIDHeader); len(values) > 0 {
+				id = values[0]
+			}
+		}
+		if id == "" {
+			return handler(srv, ss)
+		}
+
+		return handler(srv, &contextStream{ServerStream: ss, ctx: context.WithValue(ctx, requestIDKey{}, id)})
+	}
+}
+
+// contextStream overrides the context of a server stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// requestID returns the request ID stored in ctx, if any.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// logf logs like log.Printf, prefixed with the request ID from ctx.
+func logf(ctx context.Context, format string, args ...interface{}) {
+	if id := requestID(ctx); id != "" {
+		log.Printf("[%s] %s", id, fmt.Sprintf(format, args...))
+		return
+	}
+	log.Printf(format, args...)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of log.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *syncBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+func TestRequestIDInterceptor(t *testing.T) {
+	var buf syncBuffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		name string
+		md   metadata.MD
+		want string
+	}{
+		{"forwarded", metadata.Pairs(requestIDHeader, "req-31"), "req-31"},
+		{"none", metadata.Pairs("x-other", "value"), ""},
+		{"no metadata", nil, ""},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.md != nil {
+			ctx = metadata.NewIncomingContext(ctx, tt.md)
+		}
+		buf.Reset()
+		var got string
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			got = requestID(ctx)
+			logf(ctx, "Storage GetURL request for: abc123")
+			return nil, nil
+		}
+		requestIDInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/storage.StorageService/GetURL"}, handler)
+
+		if got != tt.want {
+			t.Errorf("%s: handler saw request ID %q, want %q", tt.name, got, tt.want)
+		}
+		if tagged := strings.Contains(buf.String(), "["+tt.want+"] Storage GetURL"); tagged != (tt.want != "") {
+			t.Errorf("%s: log line %q tagged %v, want %v", tt.name, buf.String(), tagged, tt.want != "")
+		}
+	}
+}
+
+func TestRequestIDStreamInterceptor(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestIDHeader, "req-31"))
+	var got string
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		got = requestID(ss.Context())
+		return nil
+	}
+	requestIDStreamInterceptor()(nil, &contextStream{ctx: ctx}, &grpc.StreamServerInfo{}, handler)
+	if got != "req-31" {
+		t.Errorf("stream handler saw request ID %q, want req-31", got)
+	}
+}
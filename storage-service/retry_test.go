@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// flakyDriver fails statements with the queued errors, one per statement,
+// and runs them successfully once the queue is empty.
+type flakyDriver struct {
+	mu    sync.Mutex
+	errs  []error
+	calls int
+}
+
+func (d *flakyDriver) Connect(context.Context) (driver.Conn, error) { return flakyConn{d}, nil }
+func (d *flakyDriver) Driver() driver.Driver                        { return d }
+func (d *flakyDriver) Open(string) (driver.Conn, error)             { return flakyConn{d}, nil }
+
+func (d *flakyDriver) next() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls++
+	if len(d.errs) == 0 {
+		return nil
+	}
+	err := d.errs[0]
+	d.errs = d.errs[1:]
+	return err
+}
+
+func (d *flakyDriver) statements() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.calls
+}
+
+type flakyConn struct{ d *flakyDriver }
+
+func (flakyConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (flakyConn) Close() error              { return nil }
+func (flakyConn) Begin() (driver.Tx, error) { return nil, errors.New("transactions not supported") }
+
+func (c flakyConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	if err := c.d.next(); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c flakyConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	if err := c.d.next(); err != nil {
+		return nil, err
+	}
+	return &oneRow{}, nil
+}
+
+// oneRow is a result of the single value 1.
+type oneRow struct{ done bool }
+
+func (*oneRow) Columns() []string { return []string{"n"} }
+func (*oneRow) Close() error      { return nil }
+
+func (r *oneRow) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+// newFlakyDB returns a tracedDB on a flakyDriver that fails with errs first.
+func newFlakyDB(t *testing.T, errs ...error) (tracedDB, *flakyDriver) {
+	t.Helper()
+	d := &flakyDriver{errs: errs}
+	db := sql.OpenDB(d)
+	t.Cleanup(func() { db.Close() })
+	return tracedDB{DB: db, retryMaxAttempts: 3, retryBaseDelay: time.Millisecond}, d
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	var buf syncBuffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	serialization := &pq.Error{Code: "40001"}
+	tests := []struct {
+		name      string
+		query     string
+		errs      []error
+		wantErr   bool
+		wantCalls int
+	}{
+		{"serialization then success", "UPDATE urls SET clicks = clicks + 1", []error{serialization}, false, 2},
+		{"deadlock and refused then success", "INSERT INTO urls VALUES (1)", []error{&pq.Error{Code: "40P01"}, syscall.ECONNREFUSED}, false, 3},
+		{"admin shutdown then success", "SELECT 1 FROM urls", []error{&pq.Error{Code: "57P01"}}, false, 2},
+		{"reset read", "SELECT 1 FROM urls", []error{syscall.ECONNRESET}, false, 2},
+		{"out of attempts", "UPDATE urls SET clicks = 0", []error{serialization, serialization, serialization, serialization}, true, 3},
+		{"unique violation", "INSERT INTO urls VALUES (1)", []error{&pq.Error{Code: "23505"}}, true, 1},
+		{"reset write", "INSERT INTO urls VALUES (1)", []error{syscall.ECONNRESET}, true, 1},
+	}
+	for _, tt := range tests {
+		db, d := newFlakyDB(t, tt.errs...)
+		buf.Reset()
+
+		var err error
+		if strings.HasPrefix(tt.query, "SELECT") {
+			var n int
+			err = db.QueryRowContext(context.Background(), tt.query).Scan(&n)
+		} else {
+			_, err = db.ExecContext(context.Background(), tt.query)
+		}
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if n := d.statements(); n != tt.wantCalls {
+			t.Errorf("%s: %d attempts, want %d", tt.name, n, tt.wantCalls)
+		}
+		if retries := strings.Count(buf.String(), "Transient database error"); retries != tt.wantCalls-1 {
+			t.Errorf("%s: logged %d retries, want %d", tt.name, retries, tt.wantCalls-1)
+		}
+		if tt.wantCalls > 1 && !strings.Contains(buf.String(), "(attempt 1/3)") {
+			t.Errorf("%s: retry log %q has no attempt count", tt.name, buf.String())
+		}
+	}
+}
+
+func TestRetryBoundedByContext(t *testing.T) {
+	// Canceled callers aren't retried
+	db, d := newFlakyDB(t, &pq.Error{Code: "40001"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	db.ExecContext(ctx, "UPDATE urls SET clicks = 0")
+	if n := d.statements(); n > 1 {
+		t.Errorf("canceled: %d attempts, want at most 1", n)
+	}
+
+	// Nor are callers whose deadline ends before the backoff would
+	db, d = newFlakyDB(t, &pq.Error{Code: "40001"})
+	db.retryBaseDelay = time.Second
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := db.ExecContext(ctx, "UPDATE urls SET clicks = 0"); err == nil {
+		t.Error("short deadline: retried past the deadline")
+	}
+	if n := d.statements(); n != 1 || time.Since(start) > 50*time.Millisecond {
+		t.Errorf("short deadline: %d attempts in %v, want 1 without waiting", n, time.Since(start))
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err       error
+		operation string
+		want      bool
+	}{
+		{nil, "SELECT", false},
+		{context.Canceled, "SELECT", false},
+		{context.DeadlineExceeded, "SELECT", false},
+		{&pq.Error{Code: "40001"}, "UPDATE", true},
+		{&pq.Error{Code: "57P03"}, "INSERT", true},
+		{&pq.Error{Code: "08006"}, "SELECT", true},
+		{&pq.Error{Code: "08006"}, "INSERT", false},
+		{&pq.Error{Code: "23505"}, "INSERT", false},
+		{fmt.Errorf("dial: %w", syscall.ECONNREFUSED), "DELETE", true},
+		{io.ErrUnexpectedEOF, "SELECT", true},
+		{io.ErrUnexpectedEOF, "UPDATE", false},
+		{driver.ErrBadConn, "SELECT", true},
+		{errors.New("syntax error"), "SELECT", false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err, tt.operation); got != tt.want {
+			t.Errorf("isTransient(%v, %s) = %v, want %v", tt.err, tt.operation, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxRedirectRules bounds the rules of one link. url-service checks the
+// rest of what makes rules valid.
+const maxRedirectRules = 10
+
+func validateRules(rules []*proto.RedirectRule) error {
+	if len(rules) > maxRedirectRules {
+		return status.Errorf(codes.InvalidArgument, "at most %d rules are allowed", maxRedirectRules)
+	}
+	for i, r := range rules {
+		if r.Url == "" {
+			return status.Errorf(codes.InvalidArgument, "rule %d needs a URL", i+1)
+		}
+		for _, country := range r.Countries {
+			if country == "" || strings.Contains(country, ",") {
+				return status.Errorf(codes.InvalidArgument, "rule %d has an invalid country %q", i+1, country)
+			}
+		}
+	}
+	return nil
+}
+
+// saveRules replaces the redirect rules of shortCode in tx; none removes
+// them.
+func saveRules(ctx context.Context, tx dbTx, shortCode string, rules []*proto.RedirectRule) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM url_redirect_rules WHERE `+keyIs("url_redirect_rules", "$1"), shortCode); err != nil {
+		return err
+	}
+	for i, r := range rules {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO url_redirect_rules (tenant_id, short_code, position, device, countries, destination_url)
+			VALUES (`+keyTenant("$1")+`, `+keyCode("$1")+`, $2, NULLIF($3, ''), NULLIF($4, ''), $5)
+		`, shortCode, i, r.Device, strings.Join(r.Countries, ","), r.Url)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadRules returns the redirect rules of the given codes, in order, keyed
+// by code. Codes without rules are absent.
+func loadRules(ctx context.Context, db tracedDB, shortCodes []string) (map[string][]*proto.RedirectRule, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+keyOf("url_redirect_rules")+`, device, countries, destination_url
+		FROM url_redirect_rules
+		WHERE `+db.dialect.keysIn("url_redirect_rules", "$1")+`
+		ORDER BY tenant_id, short_code, position
+	`, db.dialect.array(shortCodes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make(map[string][]*proto.RedirectRule)
+	for rows.Next() {
+		var shortCode string
+		var device, countries sql.NullString
+		r := &proto.RedirectRule{}
+		if err := rows.Scan(&shortCode, &device, &countries, &r.Url); err != nil {
+			return nil, err
+		}
+		r.Device = device.String
+		if countries.Valid {
+			r.Countries = strings.Split(countries.String, ",")
+		}
+		rules[shortCode] = append(rules[shortCode], r)
+	}
+	return rules, rows.Err()
+}
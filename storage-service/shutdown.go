@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+)
+
+const defaultShutdownTimeout = 15 * time.Second
+
+// shutdown stops advertising the service, drains in-flight RPCs for up to
+// timeout, then stops background work and closes the database.
+func shutdown(server *grpc.Server, httpServer *http.Server, healthServer *health.Server, storageServer *storageServer, stopBackground func(), timeout time.Duration) {
+	log.Printf("Shutting down, draining for up to %s", timeout)
+
+	// Flip health first so load balancers stop routing new requests here
+	healthServer.Shutdown()
+
+	gracefulStop(server, timeout)
+
+	httpCtx, httpCancel := context.WithTimeout(context.Background(), time.Second)
+	if err := httpServer.Shutdown(httpCtx); err != nil {
+		log.Printf("Warning: failed to stop health endpoints: %v", err)
+	}
+	httpCancel()
+	stopBackground()
+
+	if err := storageServer.Close(); err != nil {
+		log.Printf("Warning: failed to close database: %v", err)
+	}
+	log.Printf("Storage Service stopped")
+}
+
+// gracefulStop waits for in-flight RPCs to finish, forcing the server to stop
+// once timeout elapses.
+func gracefulStop(server *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Warning: drain timed out after %s, forcing stop", timeout)
+		server.Stop()
+	}
+}
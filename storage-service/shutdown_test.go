@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	s := newTestServer(t)
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	proto.RegisterStorageServiceServer(server, s)
+	healthServer := health.NewServer()
+	grpc_health_v1.RegisterHealthServer(server, healthServer)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial bufconn: %v", err)
+	}
+	defer conn.Close()
+	client := proto.NewStorageServiceClient(conn)
+	ctx := context.Background()
+	if _, err := client.SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "abc123", OriginalUrl: "https://example.com"}); err != nil {
+		t.Fatalf("SaveURL: %v", err)
+	}
+
+	httpLis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	httpServer := newHealthHTTPServer("0", s)
+	go httpServer.Serve(httpLis)
+
+	// By the time background work stops, health has flipped and both
+	// servers are down, but the database is still open for it
+	var stopped bool
+	stopBackground := func() {
+		stopped = true
+		resp, err := healthServer.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		if err != nil || resp.Status != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("health = %v, %v when background work stopped, want NOT_SERVING", resp, err)
+		}
+		callCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		if _, err := client.GetURL(callCtx, &proto.GetURLRequest{ShortCode: "abc123"}); err == nil {
+			t.Error("gRPC server still answering when background work stopped")
+		}
+		if _, err := http.Get("http://" + httpLis.Addr().String() + "/healthz"); err == nil {
+			t.Error("health endpoints still answering when background work stopped")
+		}
+		if err := s.db.PingContext(ctx); err != nil {
+			t.Errorf("database closed before background work stopped: %v", err)
+		}
+	}
+	shutdown(server, httpServer, healthServer, s, stopBackground, 5*time.Second)
+
+	if !stopped {
+		t.Error("background work never stopped")
+	}
+	if err := s.db.PingContext(ctx); err == nil {
+		t.Error("database still open after shutdown")
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	// Time zones for bucketing clicks, which Postgres does itself
+	_ "time/tzdata"
+
+	"modernc.org/sqlite"
+)
+
+// sqliteTimeFormat is how timestamps are stored on SQLite. Always in UTC
+// and with every fractional digit, so comparing them as strings orders
+// them by time.
+const sqliteTimeFormat = "2006-01-02 15:04:05.000000000-07:00"
+
+// urlHostPattern matches the host of a URL like originalHost does on
+// Postgres.
+var urlHostPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]+)`)
+
+func formatSQLiteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+// The Postgres functions the shared queries use, plus url_host for the
+// host index.
+func init() {
+	sqlite.MustRegisterScalarFunction("now", 0, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		return formatSQLiteTime(time.Now()), nil
+	})
+	sqlite.MustRegisterDeterministicScalarFunction("md5", 1, sqliteTextFunction(func(s string) interface{} {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}))
+	sqlite.MustRegisterDeterministicScalarFunction("reverse", 1, sqliteTextFunction(func(s string) interface{} {
+		return reverseString(s)
+	}))
+	sqlite.MustRegisterDeterministicScalarFunction("strpos", 2, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		if args[0] == nil || args[1] == nil {
+			return nil, nil
+		}
+		s := sqliteText(args[0])
+		i := strings.Index(s, sqliteText(args[1]))
+		if i < 0 {
+			return int64(0), nil
+		}
+		// Counted in characters, like substr
+		return int64(utf8.RuneCountInString(s[:i]) + 1), nil
+	})
+	sqlite.MustRegisterDeterministicScalarFunction("split_part", 3, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		if args[0] == nil || args[1] == nil || args[2] == nil {
+			return nil, nil
+		}
+		parts := strings.Split(sqliteText(args[0]), sqliteText(args[1]))
+		n, ok := args[2].(int64)
+		if !ok || n < 1 || int(n) > len(parts) {
+			return "", nil
+		}
+		return parts[n-1], nil
+	})
+	sqlite.MustRegisterDeterministicScalarFunction("url_host", 1, sqliteTextFunction(func(s string) interface{} {
+		if m := urlHostPattern.FindStringSubmatch(s); m != nil {
+			return m[1]
+		}
+		return nil
+	}))
+}
+
+// sqliteTextFunction adapts fn to a one argument SQL function that, like
+// its Postgres counterpart, returns NULL for NULL.
+func sqliteTextFunction(fn func(string) interface{}) func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+	return func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+		return fn(sqliteText(args[0])), nil
+	}
+}
+
+// sqliteText is a function argument as text.
+func sqliteText(v driver.Value) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// openSQLite opens the database file at path, or a private in-memory
+// database for ":memory:".
+func openSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite runs one writer at a time anyway, and a single connection
+	// keeps an in-memory database from being one per connection
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
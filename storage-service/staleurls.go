@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ListStaleURLs returns the links nobody has clicked since older_than,
+// those never clicked counting from their creation, longest unclicked
+// first. last_accessed_at only moves when url-service flushes clicks, so it
+// lags behind by up to a flush interval. A cleanup policy can delete the
+// links it gets and ask again.
+func (s *storageServer) ListStaleURLs(ctx context.Context, req *proto.ListStaleURLsRequest) (*proto.ListStaleURLsResponse, error) {
+	logf(ctx, "Storage ListStaleURLs request for URLs unclicked since %q", req.OlderThan)
+
+	olderThan, err := time.Parse(time.RFC3339, req.OlderThan)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid older_than: %v", err)
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > maxFindLimit {
+		limit = maxFindLimit
+	}
+
+	// The COALESCE matches idx_urls_last_accessed
+	rows, err := s.reader(false).QueryContext(ctx, `
+		SELECT `+keyOf("urls")+`, original_url, click_count, created_at, expires_at, COALESCE(max_clicks, 0), not_before, NOT is_active,
+			COALESCE(query_template, ''), last_accessed_at
+		FROM urls
+		WHERE tenant_id = $3
+			AND deleted_at IS NULL
+			AND COALESCE(last_accessed_at, created_at) < $1
+		ORDER BY COALESCE(last_accessed_at, created_at), short_code
+		LIMIT $2
+	`, olderThan, limit, req.TenantId)
+	if err != nil {
+		logf(ctx, "PostgreSQL error: %v", err)
+		return nil, dbError(err, "failed to list stale URLs")
+	}
+	defer rows.Close()
+
+	resp := &proto.ListStaleURLsResponse{}
+	for rows.Next() {
+		var summary proto.URLSummary
+		var createdAt time.Time
+		var expiresAt, notBefore, lastAccessedAt sql.NullTime
+		if err := rows.Scan(&summary.ShortCode, &summary.OriginalUrl, &summary.ClickCount, &createdAt, &expiresAt, &summary.MaxClicks, &notBefore, &summary.Disabled, &summary.QueryTemplate, &lastAccessedAt); err != nil {
+			return nil, dbError(err, "failed to scan URL")
+		}
+		summary.CreatedAt = createdAt.Format(time.RFC3339)
+		summary.ExpiresAt = formatOptionalTime(expiresAt)
+		summary.NotBefore = formatOptionalTime(notBefore)
+		summary.LastAccessedAt = formatOptionalTime(lastAccessedAt)
+		resp.Urls = append(resp.Urls, &summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "failed to list stale URLs")
+	}
+
+	logf(ctx, "Found %d stale URLs", len(resp.Urls))
+	return resp, nil
+}
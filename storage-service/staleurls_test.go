@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// staleCodes returns the short codes ListStaleURLs returns, in order.
+func staleCodes(t *testing.T, s *storageServer, olderThan time.Time, limit int32) []string {
+	t.Helper()
+	resp, err := s.ListStaleURLs(context.Background(), &proto.ListStaleURLsRequest{OlderThan: rfc3339(olderThan), Limit: limit})
+	if err != nil {
+		t.Fatalf("ListStaleURLs: %v", err)
+	}
+	var shortCodes []string
+	for _, u := range resp.Urls {
+		shortCodes = append(shortCodes, u.ShortCode)
+	}
+	return shortCodes
+}
+
+func TestConformanceListStaleURLs(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		now := time.Now().Truncate(time.Second)
+		for _, code := range []string{"older", "old", "fresh", "unclicked", "gone"} {
+			saveURL(t, s, &proto.SaveURLRequest{ShortCode: code, OriginalUrl: "https://example.com/" + code})
+		}
+		if _, err := s.BatchIncrementClicks(ctx, &proto.BatchIncrementClicksRequest{Deltas: []*proto.ClickDelta{
+			{ShortCode: "older", Delta: 1, LastAccessedAt: rfc3339(now.Add(-3 * time.Hour))},
+			{ShortCode: "old", Delta: 1, LastAccessedAt: rfc3339(now.Add(-2 * time.Hour))},
+			{ShortCode: "fresh", Delta: 1, LastAccessedAt: rfc3339(now.Add(time.Minute))},
+			{ShortCode: "gone", Delta: 1, LastAccessedAt: rfc3339(now.Add(-4 * time.Hour))},
+		}}); err != nil {
+			t.Fatalf("BatchIncrementClicks: %v", err)
+		}
+		// A flush carrying an earlier access doesn't move it back
+		if _, err := s.BatchIncrementClicks(ctx, &proto.BatchIncrementClicksRequest{Deltas: []*proto.ClickDelta{
+			{ShortCode: "older", Delta: 1, LastAccessedAt: rfc3339(now.Add(-5 * time.Hour))},
+		}}); err != nil {
+			t.Fatalf("BatchIncrementClicks: %v", err)
+		}
+		if _, err := s.DeleteURL(ctx, &proto.DeleteURLRequest{ShortCode: "gone"}); err != nil {
+			t.Fatalf("DeleteURL: %v", err)
+		}
+
+		tests := []struct {
+			name      string
+			olderThan time.Time
+			limit     int32
+			want      []string
+		}{
+			{"an hour ago", now.Add(-time.Hour), 0, []string{"older", "old"}},
+			{"limited", now.Add(-time.Hour), 1, []string{"older"}},
+			// Never clicked counts from creation
+			{"now", now.Add(time.Second), 0, []string{"older", "old", "unclicked"}},
+			{"before any", now.Add(-4 * time.Hour), 0, nil},
+		}
+		for _, tt := range tests {
+			if got := staleCodes(t, s, tt.olderThan, tt.limit); !slices.Equal(got, tt.want) {
+				t.Errorf("%s: ListStaleURLs = %v, want %v", tt.name, got, tt.want)
+			}
+		}
+
+		resp, err := s.ListStaleURLs(ctx, &proto.ListStaleURLsRequest{OlderThan: rfc3339(now.Add(time.Second))})
+		if err != nil || len(resp.Urls) != 3 {
+			t.Fatalf("ListStaleURLs = %v, %v", resp, err)
+		}
+		if got, want := resp.Urls[0].LastAccessedAt, rfc3339(now.Add(-3*time.Hour)); got != want || resp.Urls[0].ClickCount != 2 {
+			t.Errorf("older listed last accessed %s with %d clicks, want %s and 2", got, resp.Urls[0].ClickCount, want)
+		}
+		if resp.Urls[2].LastAccessedAt != "" {
+			t.Errorf("unclicked listed last accessed %s, want none", resp.Urls[2].LastAccessedAt)
+		}
+
+		if _, err := s.ListStaleURLs(ctx, &proto.ListStaleURLsRequest{OlderThan: "yesterday"}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ListStaleURLs with a bad time: got %v, want InvalidArgument", err)
+		}
+	})
+}
+
+func TestConformancePendingClicksLastAccess(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *storageServer) {
+		ctx := context.Background()
+		at := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+		// Held clicks keep the latest access until the link is saved
+		for _, accessed := range []time.Time{at.Add(-time.Minute), at, at.Add(-2 * time.Minute)} {
+			if _, err := s.BatchIncrementClicks(ctx, &proto.BatchIncrementClicksRequest{Deltas: []*proto.ClickDelta{
+				{ShortCode: "later", Delta: 1, LastAccessedAt: rfc3339(accessed)},
+			}}); err != nil {
+				t.Fatalf("BatchIncrementClicks: %v", err)
+			}
+		}
+		saveURL(t, s, &proto.SaveURLRequest{ShortCode: "later", OriginalUrl: "https://example.com/later"})
+		stats, err := s.GetStats(ctx, &proto.GetStatsRequest{ShortCode: "later"})
+		if err != nil || stats.ClickCount != 3 || stats.LastAccessedAt != rfc3339(at) {
+			t.Errorf("GetStats = %v, %v, want 3 clicks last accessed %s", stats, err, rfc3339(at))
+		}
+	})
+}
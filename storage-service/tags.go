@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"unicode/utf8"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Bounds on the tags of one URL. url-service normalizes them.
+const (
+	maxTagsPerURL = 10
+	maxTagLength  = 32
+)
+
+func validateTags(tags []string) error {
+	if len(tags) > maxTagsPerURL {
+		return status.Errorf(codes.InvalidArgument, "at most %d tags are allowed", maxTagsPerURL)
+	}
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if tag == "" || utf8.RuneCountInString(tag) > maxTagLength {
+			return status.Errorf(codes.InvalidArgument, "tag %q must be between 1 and %d characters", tag, maxTagLength)
+		}
+		if seen[tag] {
+			return status.Errorf(codes.InvalidArgument, "duplicate tag %q", tag)
+		}
+		seen[tag] = true
+	}
+	return nil
+}
+
+// saveTags replaces the tags of shortCode in tx; none removes them.
+func saveTags(ctx context.Context, tx dbTx, shortCode string, tags []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM url_tags WHERE `+keyIs("url_tags", "$1"), shortCode); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO url_tags (tenant_id, short_code, tag) VALUES (`+keyTenant("$1")+`, `+keyCode("$1")+`, $2)`, shortCode, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTags returns the tags of the given codes, sorted, keyed by code.
+// Codes without tags are absent.
+func loadTags(ctx context.Context, db tracedDB, shortCodes []string) (map[string][]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+keyOf("url_tags")+`, tag
+		FROM url_tags
+		WHERE `+db.dialect.keysIn("url_tags", "$1")+`
+		ORDER BY tenant_id, short_code, tag
+	`, db.dialect.array(shortCodes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[string][]string)
+	for rows.Next() {
+		var shortCode, tag string
+		if err := rows.Scan(&shortCode, &tag); err != nil {
+			return nil, err
+		}
+		tags[shortCode] = append(tags[shortCode], tag)
+	}
+	return tags, rows.Err()
+}
+
+// ListTags returns the tags of a user's URLs with how many URLs have each.
+// Like ListURLs it counts expired URLs but not deleted ones.
+func (s *storageServer) ListTags(ctx context.Context, req *proto.ListTagsRequest) (*proto.ListTagsResponse, error) {
+	logf(ctx, "Storage ListTags request for user: %s", req.UserId)
+
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT url_tags.tag, COUNT(*)
+		FROM urls
+		JOIN url_tags ON url_tags.tenant_id = urls.tenant_id AND url_tags.short_code = urls.short_code
+		WHERE urls.user_id = $1
+			AND urls.tenant_id = $2
+			AND urls.deleted_at IS NULL
+		GROUP BY url_tags.tag
+		ORDER BY COUNT(*) DESC, url_tags.tag
+	`, req.UserId, req.TenantId)
+	if err != nil {
+		logf(ctx, "PostgreSQL error: %v", err)
+		return nil, dbError(err, "failed to list tags")
+	}
+	defer rows.Close()
+
+	resp := &proto.ListTagsResponse{}
+	for rows.Next() {
+		t := &proto.TagCount{}
+		if err := rows.Scan(&t.Tag, &t.Urls); err != nil {
+			return nil, dbError(err, "failed to scan tag")
+		}
+		resp.Tags = append(resp.Tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "failed to list tags")
+	}
+	return resp, nil
+}
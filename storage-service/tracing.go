@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/stats"
+)
+
+// setupTracing exports traces over OTLP when an exporter endpoint is set in
+// the standard OTEL_EXPORTER_OTLP_* variables; otherwise tracing stays a
+// no-op. Sampling follows OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG.
+// The returned function flushes pending spans.
+func setupTracing(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if !tracingEnabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func tracingEnabled() bool {
+	if os.Getenv("OTEL_SDK_DISABLED") == "true" {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// serverTracing creates spans for incoming RPCs, skipping health checks.
+func serverTracing() stats.Handler {
+	return otelgrpc.NewServerHandler(otelgrpc.WithFilter(filters.Not(filters.HealthCheck())))
+}
+
+// tracedDB wraps the database so every statement gets a client span named
+// after its SQL operation, e.g. "UPDATE urls".
+type tracedDB struct {
+	*sql.DB
+	dialect      dialect
+	queryTimeout time.Duration // Per statement, on top of the request deadline
+
+	retryMaxAttempts int
+	retryBaseDelay   time.Duration
+}
+
+var dbTracer = otel.Tracer("storage-service/db")
+
+func (db tracedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := db.startSpan(ctx, query)
+	defer span.End()
+
+	args = db.dialect.args(args)
+	var result sql.Result
+	err := db.retry(ctx, query, func(ctx context.Context) (err error) {
+		result, err = db.DB.ExecContext(db.withQueryTimeout(ctx), query, args...)
+		return err
+	})
+	recordDBError(span, err)
+	return result, err
+}
+
+func (db tracedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := db.startSpan(ctx, query)
+	defer span.End()
+
+	args = db.dialect.args(args)
+	var rows *sql.Rows
+	err := db.retry(ctx, query, func(ctx context.Context) (err error) {
+		rows, err = db.DB.QueryContext(db.withQueryTimeout(ctx), query, args...)
+		return err
+	})
+	recordDBError(span, err)
+	return rows, err
+}
+
+func (db tracedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := db.startSpan(ctx, query)
+	defer span.End()
+
+	args = db.dialect.args(args)
+	var row *sql.Row
+	db.retry(ctx, query, func(ctx context.Context) error {
+		row = db.DB.QueryRowContext(db.withQueryTimeout(ctx), query, args...)
+		return row.Err()
+	})
+	if err := row.Err(); err != sql.ErrNoRows {
+		recordDBError(span, err)
+	}
+	return row
+}
+
+func (db tracedDB) startSpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	operation, table := sqlOperation(query)
+	name := operation
+	if table != "" {
+		name += " " + table
+	}
+	return dbTracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", db.dialect.system()),
+			attribute.String("db.operation", operation),
+			attribute.String("db.sql.table", table),
+		),
+	)
+}
+
+func recordDBError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+}
+
+// sqlOperation returns the statement keyword and the table it targets.
+func sqlOperation(query string) (operation, table string) {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	operation = strings.ToUpper(fields[0])
+	for i, field := range fields[:len(fields)-1] {
+		switch strings.ToUpper(field) {
+		case "INTO", "UPDATE", "FROM":
+			return operation, fields[i+1]
+		}
+	}
+	return operation, ""
+}
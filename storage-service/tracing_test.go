@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var (
+	spansOnce sync.Once
+	spans     *tracetest.SpanRecorder
+)
+
+// recordSpans installs a tracer provider recording every span for the rest
+// of the test binary. It is installed once: dbTracer and the gRPC handlers
+// keep the provider they were first given.
+func recordSpans() *tracetest.SpanRecorder {
+	spansOnce.Do(func() {
+		spans = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	return spans
+}
+
+func TestTracingParentsDBSpans(t *testing.T) {
+	spans := recordSpans()
+	s := newTestServer(t)
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.StatsHandler(serverTracing()))
+	proto.RegisterStorageServiceServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()))
+	if err != nil {
+		t.Fatalf("dial bufconn: %v", err)
+	}
+	defer conn.Close()
+	before := len(spans.Ended())
+
+	// The caller's span, as url-service's ShortenURL would be
+	ctx, caller := otel.Tracer("test").Start(context.Background(), "ShortenURL")
+	_, err = proto.NewStorageServiceClient(conn).SaveURL(ctx, &proto.SaveURLRequest{ShortCode: "traced", OriginalUrl: "https://example.com"})
+	caller.End()
+	if err != nil {
+		t.Fatalf("SaveURL: %v", err)
+	}
+
+	var client, server sdktrace.ReadOnlySpan
+	var statements []sdktrace.ReadOnlySpan
+	for _, span := range spans.Ended()[before:] {
+		switch {
+		case span.Name() == "storage.StorageService/SaveURL" && span.SpanKind().String() == "client":
+			client = span
+		case span.Name() == "storage.StorageService/SaveURL":
+			server = span
+		case span.InstrumentationScope().Name == "storage-service/db":
+			statements = append(statements, span)
+		}
+	}
+	if client == nil || server == nil {
+		t.Fatalf("client span %v, server span %v, want both", client, server)
+	}
+	if client.Parent().SpanID() != caller.SpanContext().SpanID() || server.Parent().SpanID() != client.SpanContext().SpanID() {
+		t.Errorf("server span is not a grandchild of the caller's span across the call")
+	}
+	if server.SpanContext().TraceID() != caller.SpanContext().TraceID() {
+		t.Errorf("server span in trace %s, want %s", server.SpanContext().TraceID(), caller.SpanContext().TraceID())
+	}
+
+	// Every statement is a child of the RPC, named after its SQL operation
+	var insert bool
+	for _, span := range statements {
+		if span.Parent().SpanID() != server.SpanContext().SpanID() {
+			t.Errorf("statement %q has parent %s, want the SaveURL span", span.Name(), span.Parent().SpanID())
+		}
+		insert = insert || span.Name() == "INSERT urls"
+	}
+	if !insert {
+		var names []string
+		for _, span := range statements {
+			names = append(names, span.Name())
+		}
+		t.Errorf("statements %v, want an INSERT urls", names)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Device classes of click events.
+const (
+	deviceDesktop = "desktop"
+	deviceMobile  = "mobile"
+	deviceTablet  = "tablet"
+	deviceBot     = "bot"
+	deviceOther   = "other"
+)
+
+// botMarkers are lowercase substrings of crawler, preview fetcher and HTTP
+// library user agents.
+var botMarkers = []string{"bot", "crawler", "spider", "slurp", "facebookexternalhit", "headless", "curl/", "wget/", "python-", "go-http-client", "okhttp", "java/"}
+
+// browserMarkers maps user agent tokens to browser families, checked in
+// order since most browsers also claim to be Safari and many Chrome.
+var browserMarkers = []struct{ token, family string }{
+	{"Edg", "Edge"},
+	{"OPR/", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+}
+
+// classifyUserAgent derives the browser family and device class of a click
+// from its user agent. It returns empty strings for an empty user agent.
+func classifyUserAgent(ua string) (browser, device string) {
+	if ua == "" {
+		return "", ""
+	}
+
+	lower := strings.ToLower(ua)
+	for _, marker := range botMarkers {
+		if strings.Contains(lower, marker) {
+			return "Other", deviceBot
+		}
+	}
+
+	browser = "Other"
+	for _, m := range browserMarkers {
+		if strings.Contains(ua, m.token) {
+			browser = m.family
+			break
+		}
+	}
+
+	switch {
+	case strings.Contains(ua, "iPad") || strings.Contains(lower, "tablet") ||
+		(strings.Contains(ua, "Android") && !strings.Contains(ua, "Mobile")):
+		device = deviceTablet
+	case strings.Contains(ua, "Mobile") || strings.Contains(ua, "iPhone") || strings.Contains(ua, "Android"):
+		device = deviceMobile
+	case strings.Contains(ua, "Windows") || strings.Contains(ua, "Macintosh") || strings.Contains(ua, "X11") || strings.Contains(ua, "CrOS"):
+		device = deviceDesktop
+	default:
+		device = deviceOther
+	}
+	return browser, device
+}
+
+// referrerHost returns the lowercase host a referrer points to without a
+// leading "www.", or "" if it has none.
+func referrerHost(referrer string) string {
+	u, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
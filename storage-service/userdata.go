@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// userDataDelete is the user_data_audit action of DeleteUserData.
+const userDataDelete = "delete"
+
+// DeleteUserData erases a user's data in a tenant: their URLs are soft
+// deleted, each with its history, and the visitor details of the click
+// events on them are cleared, keeping only when each click happened so
+// counts and time series still add up. Every call is audited, repeated ones
+// included. It is idempotent: a repeat deletes and scrubs nothing more but
+// returns the same codes, so a caller can retry dropping their cached copies.
+func (s *storageServer) DeleteUserData(ctx context.Context, req *proto.DeleteUserDataRequest) (*proto.DeleteUserDataResponse, error) {
+	logf(ctx, "Storage DeleteUserData request for user: %s", req.UserId)
+
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	query := `
+		UPDATE urls
+		SET deleted_at = NOW()
+		WHERE user_id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+		RETURNING ` + keyOf("urls") + `, original_url
+	`
+	resp := &proto.DeleteUserDataResponse{}
+	err := s.db.inTx(ctx, query, func(ctx context.Context, tx dbTx) error {
+		resp.Reset()
+
+		// The history is written once the rows are read, SQLite can't do
+		// both at once
+		rows, err := tx.QueryContext(ctx, query, req.UserId, req.TenantId)
+		if err != nil {
+			return err
+		}
+		var originalURLs []string
+		for rows.Next() {
+			var shortCode, originalURL string
+			if err := rows.Scan(&shortCode, &originalURL); err != nil {
+				rows.Close()
+				return err
+			}
+			resp.DeletedShortCodes = append(resp.DeletedShortCodes, shortCode)
+			originalURLs = append(originalURLs, originalURL)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for i, shortCode := range resp.DeletedShortCodes {
+			if err := recordHistory(ctx, tx, shortCode, historyDelete, originalURLs[i], ""); err != nil {
+				return err
+			}
+			if err := s.outbox.enqueue(ctx, tx, outboxURLDeleted, shortCode, originalURLs[i]); err != nil {
+				return err
+			}
+		}
+		resp.DeletedUrls = int64(len(resp.DeletedShortCodes))
+
+		result, err := tx.ExecContext(ctx, `
+			UPDATE url_clicks
+			SET referrer = NULL, user_agent = NULL, country = NULL,
+				referrer_host = NULL, browser = NULL, device = NULL
+			WHERE tenant_id = $2
+				AND short_code IN (SELECT short_code FROM urls WHERE user_id = $1 AND tenant_id = $2)
+				AND (referrer IS NOT NULL OR user_agent IS NOT NULL OR country IS NOT NULL
+					OR referrer_host IS NOT NULL OR browser IS NOT NULL OR device IS NOT NULL)
+		`, req.UserId, req.TenantId)
+		if err != nil {
+			return err
+		}
+		if resp.ScrubbedClicks, err = result.RowsAffected(); err != nil {
+			return err
+		}
+
+		rows, err = tx.QueryContext(ctx, `
+			SELECT `+keyOf("urls")+` FROM urls WHERE user_id = $1 AND tenant_id = $2 ORDER BY short_code
+		`, req.UserId, req.TenantId)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var shortCode string
+			if err := rows.Scan(&shortCode); err != nil {
+				rows.Close()
+				return err
+			}
+			resp.ShortCodes = append(resp.ShortCodes, shortCode)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		actorUser, keyID := actor(ctx)
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO user_data_audit (user_id, tenant_id, action, actor, api_key_id, deleted_urls, scrubbed_clicks, requested_at)
+			VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7, $8)
+		`, req.UserId, req.TenantId, userDataDelete, actorUser, keyID, resp.DeletedUrls, resp.ScrubbedClicks, time.Now())
+		return err
+	})
+	if err != nil {
+		logf(ctx, "Failed to delete user data: %v", err)
+		return nil, dbError(err, "failed to delete user data")
+	}
+
+	logf(ctx, "Data of user %s deleted: %d URLs deleted, %d click events scrubbed, %d URLs in all", req.UserId, resp.DeletedUrls, resp.ScrubbedClicks, len(resp.ShortCodes))
+	return resp, nil
+}
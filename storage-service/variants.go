@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+
+	proto "github.com/syedalijabir/protos/storage-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxVariants bounds the destinations of one split link. url-service checks
+// the rest of what makes variants valid.
+const maxVariants = 10
+
+func validateVariants(variants []*proto.Variant) error {
+	if len(variants) > maxVariants {
+		return status.Errorf(codes.InvalidArgument, "at most %d variants are allowed", maxVariants)
+	}
+	names := make(map[string]bool, len(variants))
+	for i, v := range variants {
+		if v.Name == "" || v.Url == "" || v.Weight <= 0 {
+			return status.Errorf(codes.InvalidArgument, "variant %d needs a name, a URL and a positive weight", i)
+		}
+		if names[v.Name] {
+			return status.Errorf(codes.InvalidArgument, "duplicate variant name %q", v.Name)
+		}
+		names[v.Name] = true
+	}
+	return nil
+}
+
+// saveVariants replaces the variants of shortCode in tx; none makes it a
+// plain link again.
+func saveVariants(ctx context.Context, tx dbTx, shortCode string, variants []*proto.Variant) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM url_variants WHERE `+keyIs("url_variants", "$1"), shortCode); err != nil {
+		return err
+	}
+	for i, v := range variants {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO url_variants (tenant_id, short_code, position, name, destination_url, weight)
+			VALUES (`+keyTenant("$1")+`, `+keyCode("$1")+`, $2, $3, $4, $5)
+		`, shortCode, i, v.Name, v.Url, v.Weight)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadVariants returns the variants of the given codes, in order, keyed by
+// code. Codes that aren't split links are absent.
+func loadVariants(ctx context.Context, db tracedDB, shortCodes []string) (map[string][]*proto.Variant, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+keyOf("url_variants")+`, name, destination_url, weight
+		FROM url_variants
+		WHERE `+db.dialect.keysIn("url_variants", "$1")+`
+		ORDER BY tenant_id, short_code, position
+	`, db.dialect.array(shortCodes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := make(map[string][]*proto.Variant)
+	for rows.Next() {
+		var shortCode string
+		v := &proto.Variant{}
+		if err := rows.Scan(&shortCode, &v.Name, &v.Url, &v.Weight); err != nil {
+			return nil, err
+		}
+		variants[shortCode] = append(variants[shortCode], v)
+	}
+	return variants, rows.Err()
+}
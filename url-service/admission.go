@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"log"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultAdmissionQueueHigh  = defaultAsyncQueueSize * 3 / 4
+	defaultAdmissionQueueLow   = defaultAsyncQueueSize / 4
+	defaultAdmissionClicksHigh = 50000
+	defaultAdmissionClicksLow  = 10000
+
+	// admissionRetryAfter is the retry-after sent with shed writes, about
+	// as long as a backlog takes to drain.
+	admissionRetryAfter = time.Second
+)
+
+// admissionMethods are the writes shed while the service is saturated. They
+// are what fills the async queue; lookups stay served.
+var admissionMethods = map[string]bool{
+	url_service.URLService_ShortenURL_FullMethodName:   true,
+	url_service.URLService_BatchShorten_FullMethodName: true,
+}
+
+// admission sheds writes once async work piles up faster than it drains,
+// before a blocking queue stalls every caller or a dropping one loses
+// writes. The service saturates when the async queue depth or the clicks
+// not yet flushed to storage reach their high watermark, and only recovers
+// once both are back at their low watermark, so it doesn't flap around a
+// single threshold. A zero high watermark leaves that signal out.
+type admission struct {
+	queueHigh, queueLow   int
+	clicksHigh, clicksLow int64
+	queueDepth            func() int
+	unflushedClicks       func() int64
+
+	mu        sync.Mutex
+	saturated bool
+	since     time.Time
+
+	rejected *prometheus.CounterVec
+}
+
+func newAdmission(cfg Config, tasks *taskQueue, clicks *clickBatcher) *admission {
+	return &admission{
+		queueHigh:       cfg.AdmissionQueueHigh,
+		queueLow:        cfg.AdmissionQueueLow,
+		clicksHigh:      cfg.AdmissionClicksHigh,
+		clicksLow:       cfg.AdmissionClicksLow,
+		queueDepth:      tasks.Depth,
+		unflushedClicks: clicks.Unflushed,
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "url_service_admission_rejected_total",
+			Help: "Writes rejected while async work was saturated, by method.",
+		}, []string{"method"}),
+	}
+}
+
+// Saturated reports whether writes are being shed, moving between the
+// states as the watermarks are crossed.
+func (a *admission) Saturated() bool {
+	depth, clicks := a.queueDepth(), a.unflushedClicks()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch {
+	case !a.saturated && (a.queueHigh > 0 && depth >= a.queueHigh || a.clicksHigh > 0 && clicks >= a.clicksHigh):
+		a.saturated, a.since = true, time.Now()
+		log.Printf("Warning: saturated with %d queued tasks and %d unflushed clicks, shedding writes", depth, clicks)
+	case a.saturated && (a.queueHigh == 0 || depth <= a.queueLow) && (a.clicksHigh == 0 || clicks <= a.clicksLow):
+		a.saturated = false
+		log.Printf("Drained to %d queued tasks and %d unflushed clicks after %s, accepting writes again",
+			depth, clicks, time.Since(a.since).Round(time.Millisecond))
+	}
+	return a.saturated
+}
+
+// level is how close the service is to saturating, as the larger share of
+// a high watermark reached.
+func (a *admission) level() float64 {
+	var level float64
+	if a.queueHigh > 0 {
+		level = float64(a.queueDepth()) / float64(a.queueHigh)
+	}
+	if a.clicksHigh > 0 {
+		level = max(level, float64(a.unflushedClicks())/float64(a.clicksHigh))
+	}
+	return level
+}
+
+// admissionInterceptor rejects the writes in admissionMethods with
+// ResourceExhausted and a retry-after while a is saturated.
+func admissionInterceptor(a *admission) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !admissionMethods[info.FullMethod] || !a.Saturated() {
+			return handler(ctx, req)
+		}
+
+		a.rejected.WithLabelValues(path.Base(info.FullMethod)).Inc()
+		seconds := int64(admissionRetryAfter / time.Second)
+		if err := grpc.SetTrailer(ctx, metadata.Pairs(retryAfterHeader, strconv.FormatInt(seconds, 10))); err != nil {
+			logf(ctx, "Warning: failed to set retry-after trailer: %v", err)
+		}
+		return nil, status.Errorf(codes.ResourceExhausted, "service is overloaded, retry in %ds", seconds)
+	}
+}
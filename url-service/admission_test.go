@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAdmissionHysteresis(t *testing.T) {
+	var depth int
+	var clicks int64
+	a := &admission{
+		queueHigh: 100, queueLow: 20,
+		clicksHigh: 1000, clicksLow: 200,
+		queueDepth:      func() int { return depth },
+		unflushedClicks: func() int64 { return clicks },
+	}
+	steps := []struct {
+		name   string
+		depth  int
+		clicks int64
+		want   bool
+	}{
+		{"idle", 0, 0, false},
+		{"below both high watermarks", 99, 999, false},
+		{"queue at its high watermark", 100, 0, true},
+		// Between the watermarks it stays where it was
+		{"queue draining", 50, 0, true},
+		{"queue at its low watermark", 20, 0, false},
+		{"queue filling again", 50, 0, false},
+		{"clicks at their high watermark", 0, 1000, true},
+		{"clicks draining", 0, 500, true},
+		// Both have to drain
+		{"clicks drained but the queue filled", 60, 100, true},
+		{"both at their low watermark", 20, 200, false},
+	}
+	for _, step := range steps {
+		depth, clicks = step.depth, step.clicks
+		if got := a.Saturated(); got != step.want {
+			t.Errorf("%s: Saturated() = %v with %d queued and %d clicks, want %v", step.name, got, depth, clicks, step.want)
+		}
+	}
+
+	// A zero high watermark leaves the signal out
+	a.queueHigh = 0
+	depth, clicks = 1<<20, 0
+	if a.Saturated() {
+		t.Error("saturated by the queue with its watermark off")
+	}
+	if got := a.level(); got != 0 {
+		t.Errorf("level() = %v with the queue left out and no clicks, want 0", got)
+	}
+	clicks = 500
+	if got := a.level(); got != 0.5 {
+		t.Errorf("level() = %v with half the clicks watermark, want 0.5", got)
+	}
+}
+
+// shedServer serves s with admission control over an in-memory listener.
+func shedServer(t *testing.T, s *urlServer) url_service.URLServiceClient {
+	t.Helper()
+	conn := dialBufconn(t, func(srv *grpc.Server) { url_service.RegisterURLServiceServer(srv, s) },
+		grpc.UnaryInterceptor(admissionInterceptor(s.admission)))
+	return url_service.NewURLServiceClient(conn)
+}
+
+// readsServed looks up shortCode n times at once and returns the slowest
+// lookup, failing the test if any fails.
+func readsServed(t *testing.T, client url_service.URLServiceClient, shortCode string, n int) time.Duration {
+	t.Helper()
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		slowest time.Duration
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			_, err := client.GetOriginalURL(context.Background(), &url_service.GetOriginalRequest{ShortCode: shortCode})
+			took := time.Since(start)
+			if err != nil {
+				t.Errorf("GetOriginalURL while shedding writes: %v", err)
+			}
+			mu.Lock()
+			slowest = max(slowest, took)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return slowest
+}
+
+func TestAdmissionShedsWritesOnQueueDepth(t *testing.T) {
+	s, _, _ := newTestServer(t, map[string]string{
+		"URL_SYNC_PERSIST": "true", "ASYNC_WORKERS": "1", "ASYNC_QUEUE_SIZE": "256",
+		"ADMISSION_QUEUE_HIGH": "8", "ADMISSION_QUEUE_LOW": "2", "ADMISSION_CLICKS_HIGH": "0",
+	})
+	client := shedServer(t, s)
+	ctx := context.Background()
+	if _, err := client.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "queued"}); err != nil {
+		t.Fatalf("ShortenURL before the backlog: %v", err)
+	}
+	waitFor(t, "the queue to drain", func() bool { return s.tasks.Depth() == 0 })
+
+	// A stalled worker backs the queue up past its high watermark
+	release := stall(t, s.tasks)
+	for i := 0; i < 8; i++ {
+		s.tasks.Submit("backlog", func() {})
+	}
+	var trailer metadata.MD
+	_, err := client.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com/shed"}, grpc.Trailer(&trailer))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("ShortenURL with the queue backed up: got %v, want ResourceExhausted", err)
+	}
+	if got := trailer.Get(retryAfterHeader); len(got) != 1 || got[0] != "1" {
+		t.Errorf("retry-after trailer %q, want 1", got)
+	}
+	if _, err := client.BatchShorten(ctx, &url_service.BatchShortenRequest{Items: []*url_service.ShortenRequest{{OriginalUrl: "https://example.com/batch"}}}); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("BatchShorten with the queue backed up: got %v, want ResourceExhausted", err)
+	}
+
+	// Reads go on being served, without waiting for the backlog
+	if slowest := readsServed(t, client, "queued", 20); slowest > time.Second {
+		t.Errorf("slowest read took %v while shedding writes", slowest)
+	}
+	if got := gathered(t, s.metrics.registry, "url_service_admission_saturated")[""]; got != 1 {
+		t.Errorf("url_service_admission_saturated %v, want 1", got)
+	}
+	if got := gathered(t, s.metrics.registry, "url_service_admission_saturation")[""]; got < 1 {
+		t.Errorf("url_service_admission_saturation %v, want at least 1", got)
+	}
+	rejected := gathered(t, s.metrics.registry, "url_service_admission_rejected_total")
+	if rejected["method=ShortenURL"] != 1 || rejected["method=BatchShorten"] != 1 {
+		t.Errorf("url_service_admission_rejected_total %v, want one ShortenURL and one BatchShorten", rejected)
+	}
+
+	// Once drained writes are accepted again
+	close(release)
+	waitFor(t, "the queue to drain", func() bool { return s.tasks.Depth() <= 2 })
+	if _, err := client.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com/after"}); err != nil {
+		t.Errorf("ShortenURL after the queue drained: %v", err)
+	}
+	if got := gathered(t, s.metrics.registry, "url_service_admission_saturated")[""]; got != 0 {
+		t.Errorf("url_service_admission_saturated %v after draining, want 0", got)
+	}
+}
+
+func TestAdmissionShedsWritesOnUnflushedClicks(t *testing.T) {
+	s, _, _ := newTestServer(t, map[string]string{
+		"URL_SYNC_PERSIST": "true", "CLICK_FLUSH_INTERVAL": "1h", "CLICK_FLUSH_THRESHOLD": "1000000",
+		"ADMISSION_QUEUE_HIGH": "0", "ADMISSION_CLICKS_HIGH": "50", "ADMISSION_CLICKS_LOW": "10",
+	})
+	client := shedServer(t, s)
+	ctx := context.Background()
+	if _, err := client.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "clicked"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+
+	// A burst of reads piles up clicks faster than they are flushed
+	readsServed(t, client, "clicked", 60)
+	if got := s.clicks.Unflushed(); got != 60 {
+		t.Fatalf("%d clicks unflushed after 60 reads, want 60", got)
+	}
+	for i := 0; i < 3; i++ {
+		_, err := client.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: fmt.Sprintf("https://example.com/%d", i)})
+		if status.Code(err) != codes.ResourceExhausted {
+			t.Errorf("ShortenURL %d with clicks backed up: got %v, want ResourceExhausted", i, err)
+		}
+	}
+	readsServed(t, client, "clicked", 20)
+	if got := gathered(t, s.metrics.registry, "url_service_unflushed_clicks")[""]; got != 80 {
+		t.Errorf("url_service_unflushed_clicks %v, want 80", got)
+	}
+
+	s.clicks.Flush(ctx)
+	if _, err := client.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com/flushed"}); err != nil {
+		t.Errorf("ShortenURL after the clicks were flushed: %v", err)
+	}
+	if got := gathered(t, s.metrics.registry, "url_service_admission_rejected_total")["method=ShortenURL"]; got != 3 {
+		t.Errorf("rejected ShortenURL %v, want 3", got)
+	}
+}
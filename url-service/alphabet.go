@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+const (
+	defaultShortCodeLength = 6
+	minShortCodeLength     = 4
+	maxShortCodeLength     = 12
+
+	minAlphabetSize = 10
+)
+
+// Named values of SHORT_CODE_ALPHABET. Any other value is used as the
+// alphabet itself.
+var alphabetPresets = map[string]string{
+	"default": shortCodeCharset,
+	// Leaves out 0/O/o, 1/l/I, which are easily confused when a code is
+	// read aloud or copied from print
+	"human-safe": "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789",
+}
+
+// resolveAlphabet returns the alphabet SHORT_CODE_ALPHABET names, either a
+// preset or the characters given.
+func resolveAlphabet(value string) (string, error) {
+	alphabet, ok := alphabetPresets[value]
+	if !ok {
+		alphabet = value
+	}
+	if err := validateAlphabet(alphabet); err != nil {
+		return "", err
+	}
+	return alphabet, nil
+}
+
+// validateAlphabet requires an alphabet to be at least minAlphabetSize
+// distinct characters that a custom alias may use too, so generated codes
+// are valid aliases and resolve through the same routes.
+func validateAlphabet(alphabet string) error {
+	if utf8.RuneCountInString(alphabet) < minAlphabetSize {
+		return fmt.Errorf("must be default, human-safe or at least %d characters", minAlphabetSize)
+	}
+	seen := make(map[rune]bool, len(alphabet))
+	for _, r := range alphabet {
+		if !isAliasChar(r) {
+			return fmt.Errorf("contains invalid character %q, allowed are letters, digits, '_' and '-'", r)
+		}
+		if seen[r] {
+			return fmt.Errorf("contains %q more than once", r)
+		}
+		seen[r] = true
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+)
+
+func TestShortCodeAlphabets(t *testing.T) {
+	tests := []struct {
+		name     string
+		alphabet string // SHORT_CODE_ALPHABET
+		length   string // SHORT_CODE_LENGTH
+		strategy string
+		chars    string
+		wantLen  int
+	}{
+		{"default random", "default", "", codeStrategyRandom, shortCodeCharset, 6},
+		{"default sequence", "default", "", codeStrategySequence, shortCodeCharset, 7},
+		{"human-safe random", "human-safe", "8", codeStrategyRandom, alphabetPresets["human-safe"], 8},
+		{"human-safe sequence", "human-safe", "8", codeStrategySequence, alphabetPresets["human-safe"], 9},
+		{"custom random", "abcdefghjk", "12", codeStrategyRandom, "abcdefghjk", 12},
+		{"custom sequence", "abcdefghjk", "4", codeStrategySequence, "abcdefghjk", 5},
+	}
+	for _, tt := range tests {
+		env := map[string]string{"URL_SYNC_PERSIST": "true", "SHORT_CODE_ALPHABET": tt.alphabet, "CODE_STRATEGY": tt.strategy}
+		if tt.length != "" {
+			env["SHORT_CODE_LENGTH"] = tt.length
+		}
+		s, _, _ := newTestServer(t, env)
+		ctx := withKey(context.Background(), "alice-key", "alice")
+		for i := 0; i < 20; i++ {
+			resp, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com"})
+			if err != nil {
+				t.Fatalf("%s: ShortenURL: %v", tt.name, err)
+			}
+			code := resp.ShortCode
+			if len(code) != tt.wantLen || strings.Trim(code, tt.chars) != "" {
+				t.Errorf("%s: code %q, want %d characters of %s", tt.name, code, tt.wantLen, tt.chars)
+			}
+		}
+	}
+}
+
+func TestHumanSafeKeepsOtherCodes(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true", "SHORT_CODE_ALPHABET": "human-safe", "SHORT_CODE_LENGTH": "8"})
+	ctx := withKey(context.Background(), "alice-key", "alice")
+
+	// Codes made under an earlier configuration still resolve
+	for _, code := range []string{"abc0O1", "lI0Oaa", "x1y2z3w4v5u6"} {
+		storage.put(&storage_service.SaveURLRequest{ShortCode: code, OriginalUrl: "https://example.com/" + code})
+		resp, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: code})
+		if err != nil || resp.OriginalUrl != "https://example.com/"+code {
+			t.Errorf("GetOriginalURL(%s) = %v, %v", code, resp, err)
+		}
+	}
+
+	// Aliases are validated on their own, ambiguous characters included
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "Oil0"}); err != nil {
+		t.Errorf("ShortenURL with alias Oil0: %v", err)
+	}
+}
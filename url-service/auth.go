@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strings"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// apiKeyHeader carries the caller's API key on mutating RPCs.
+const apiKeyHeader = "x-api-key"
+
+// Headers naming the user and API key behind a call to storage, which
+// records them in each link's history.
+const (
+	actorHeader      = "x-actor"
+	actorKeyIDHeader = "x-actor-key-id"
+)
+
+// authenticatedMethods change or list links and need an API key. Lookups
+// and stats stay public, but a key sent to them is checked too, since a
+// lookup with skip_stats must come from a key holder.
+var authenticatedMethods = map[string]bool{
+	url_service.URLService_ShortenURL_FullMethodName:       true,
+	url_service.URLService_UpdateURL_FullMethodName:        true,
+	url_service.URLService_DeleteURL_FullMethodName:        true,
+	url_service.URLService_SetURLStatus_FullMethodName:     true,
+	url_service.URLService_GetURLHistory_FullMethodName:    true,
+	url_service.URLService_ListReports_FullMethodName:      true,
+	url_service.URLService_PurgeURL_FullMethodName:         true,
+	url_service.URLService_StreamClicks_FullMethodName:     true,
+	url_service.URLService_ListURLs_FullMethodName:         true,
+	url_service.URLService_ListTags_FullMethodName:         true,
+	url_service.URLService_BatchShorten_FullMethodName:     true,
+	url_service.URLService_GetTopURLs_FullMethodName:       true,
+	url_service.URLService_DeleteUserData_FullMethodName:   true,
+	url_service.URLService_ExportUserData_FullMethodName:   true,
+	url_service.URLService_WriteHeapProfile_FullMethodName: true,
+}
+
+type apiKey struct {
+	id      string
+	user    string
+	tenant  string
+	revoked bool
+}
+
+// apiKeyStore maps the SHA-256 of each key to its ID so the plaintext keys
+// aren't kept around after loading.
+type apiKeyStore struct {
+	keys map[[sha256.Size]byte]apiKey
+}
+
+// loadAPIKeys reads "id:key", "id:key:user" or "id:key:user:tenant" entries
+// from the comma separated API_KEYS list and the newline separated
+// API_KEYS_FILE. Links are owned by the key's user, which defaults to the key
+// ID so a user with several keys can rotate them, in the key's tenant if it
+// is bound to one. Keys whose ID is in the comma separated
+// revoked list are kept so their callers get PermissionDenied rather than
+// Unauthenticated.
+func loadAPIKeys(list, path, revoked string) (*apiKeyStore, error) {
+	entries := strings.Split(list, ",")
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read API keys file: %v", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), "#") {
+				continue
+			}
+			entries = append(entries, line)
+		}
+	}
+
+	revokedIDs := make(map[string]bool)
+	for _, id := range strings.Split(revoked, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			revokedIDs[id] = true
+		}
+	}
+
+	store := &apiKeyStore{keys: make(map[[sha256.Size]byte]apiKey)}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		fields := strings.Split(entry, ":")
+		if len(fields) < 2 || len(fields) > 4 || fields[0] == "" || fields[1] == "" {
+			return nil, fmt.Errorf("invalid API key entry for %q, want id:key, id:key:user or id:key:user:tenant", fields[0])
+		}
+		key := apiKey{id: fields[0], user: fields[0], revoked: revokedIDs[fields[0]]}
+		if len(fields) >= 3 && fields[2] != "" {
+			key.user = fields[2]
+		}
+		if len(fields) == 4 {
+			key.tenant = fields[3]
+		}
+		store.keys[sha256.Sum256([]byte(fields[1]))] = key
+	}
+	return store, nil
+}
+
+// Enabled reports whether any keys are configured. Without keys every RPC
+// is allowed, as before authentication existed.
+func (s *apiKeyStore) Enabled() bool {
+	return len(s.keys) > 0
+}
+
+var errMissingAPIKey = status.Error(codes.Unauthenticated, "missing API key")
+
+// authenticate returns the key in ctx's metadata.
+func (s *apiKeyStore) authenticate(ctx context.Context) (apiKey, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(apiKeyHeader)
+	if len(values) == 0 || values[0] == "" {
+		return apiKey{}, errMissingAPIKey
+	}
+
+	key, ok := s.keys[sha256.Sum256([]byte(values[0]))]
+	if !ok {
+		return apiKey{}, status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	if key.revoked {
+		return apiKey{}, status.Error(codes.PermissionDenied, "API key has been revoked")
+	}
+	return key, nil
+}
+
+type apiKeyCtxKey struct{}
+
+// authInterceptor rejects calls to authenticatedMethods without a valid API
+// key, and calls to any method with an invalid one, and stores the key in
+// the context of those that have one. With allowAnonymous, for
+// INSECURE_DEV_MODE, calls without any key are let through as if
+// authentication were off; a key that is sent is still checked.
+func authInterceptor(keys *apiKeyStore, allowAnonymous bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !keys.Enabled() {
+			return handler(ctx, req)
+		}
+
+		key, err := keys.authenticate(ctx)
+		if err == errMissingAPIKey && (allowAnonymous || !authenticatedMethods[info.FullMethod]) {
+			return handler(ctx, req)
+		}
+		if err != nil {
+			logf(ctx, "Rejected %s: %v", info.FullMethod, err)
+			return nil, err
+		}
+		return handler(context.WithValue(ctx, apiKeyCtxKey{}, key), req)
+	}
+}
+
+// authStreamInterceptor is authInterceptor for streaming RPCs.
+func authStreamInterceptor(keys *apiKeyStore, allowAnonymous bool) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !keys.Enabled() {
+			return handler(srv, ss)
+		}
+
+		ctx := ss.Context()
+		key, err := keys.authenticate(ctx)
+		if err == errMissingAPIKey && (allowAnonymous || !authenticatedMethods[info.FullMethod]) {
+			return handler(srv, ss)
+		}
+		if err != nil {
+			logf(ctx, "Rejected %s: %v", info.FullMethod, err)
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: context.WithValue(ctx, apiKeyCtxKey{}, key)})
+	}
+}
+
+// authenticatedStream swaps in the context carrying the caller's key.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// apiKeyID returns the ID of the key that authenticated ctx, if any.
+func apiKeyID(ctx context.Context) string {
+	key, _ := ctx.Value(apiKeyCtxKey{}).(apiKey)
+	return key.id
+}
+
+// userID returns the user whose key authenticated ctx, if any.
+func userID(ctx context.Context) string {
+	key, _ := ctx.Value(apiKeyCtxKey{}).(apiKey)
+	return key.user
+}
+
+// apiKeyTenant returns the tenant the key that authenticated ctx is bound
+// to, if any.
+func apiKeyTenant(ctx context.Context) string {
+	key, _ := ctx.Value(apiKeyCtxKey{}).(apiKey)
+	return key.tenant
+}
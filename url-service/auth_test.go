@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthInterceptor(t *testing.T) {
+	keys, err := loadAPIKeys("monitor:s3cret,old:0ld", "", "old")
+	if err != nil {
+		t.Fatalf("loadAPIKeys: %v", err)
+	}
+	noKeys, err := loadAPIKeys("", "", "")
+	if err != nil {
+		t.Fatalf("loadAPIKeys: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		keys   *apiKeyStore
+		method string
+		key    string
+		want   codes.Code
+		keyID  string
+	}{
+		{"lookup without key", keys, url_service.URLService_GetOriginalURL_FullMethodName, "", codes.OK, ""},
+		{"lookup with key", keys, url_service.URLService_GetOriginalURL_FullMethodName, "s3cret", codes.OK, "monitor"},
+		{"lookup with made-up key", keys, url_service.URLService_GetOriginalURL_FullMethodName, "guess", codes.Unauthenticated, ""},
+		{"lookup with revoked key", keys, url_service.URLService_GetOriginalURL_FullMethodName, "0ld", codes.PermissionDenied, ""},
+		{"write without key", keys, url_service.URLService_ShortenURL_FullMethodName, "", codes.Unauthenticated, ""},
+		{"write with key", keys, url_service.URLService_ShortenURL_FullMethodName, "s3cret", codes.OK, "monitor"},
+		{"made-up key without keys", noKeys, url_service.URLService_GetOriginalURL_FullMethodName, "guess", codes.OK, ""},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.key != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(apiKeyHeader, tt.key))
+		}
+		var keyID string
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			keyID = apiKeyID(ctx)
+			return nil, nil
+		}
+		_, err := authInterceptor(tt.keys, false)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+		if status.Code(err) != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+		if keyID != tt.keyID {
+			t.Errorf("%s: handler saw key %q, want %q", tt.name, keyID, tt.keyID)
+		}
+	}
+}
+
+func TestAuthMutatingRPCs(t *testing.T) {
+	keys, err := loadAPIKeys("monitor:s3cret,old:0ld", "", "old")
+	if err != nil {
+		t.Fatalf("loadAPIKeys: %v", err)
+	}
+	for _, method := range []string{
+		url_service.URLService_ShortenURL_FullMethodName,
+		url_service.URLService_UpdateURL_FullMethodName,
+		url_service.URLService_DeleteURL_FullMethodName,
+	} {
+		for key, want := range map[string]codes.Code{
+			"s3cret": codes.OK,
+			"":       codes.Unauthenticated,
+			"0ld":    codes.PermissionDenied,
+		} {
+			ctx := context.Background()
+			if key != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(apiKeyHeader, key))
+			}
+			called := false
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				return nil, nil
+			}
+			_, err := authInterceptor(keys, false)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+			if status.Code(err) != want {
+				t.Errorf("%s with key %q: got %v, want %v", method, key, err, want)
+			}
+			if called != (want == codes.OK) {
+				t.Errorf("%s with key %q: handler called %v", method, key, called)
+			}
+		}
+	}
+}
+
+func TestAuthInsecureDevMode(t *testing.T) {
+	keys, err := loadAPIKeys("monitor:s3cret,old:0ld", "", "old")
+	if err != nil {
+		t.Fatalf("loadAPIKeys: %v", err)
+	}
+	// Keyless calls get through for grpcurl, wrong keys still don't
+	for key, want := range map[string]codes.Code{
+		"":       codes.OK,
+		"s3cret": codes.OK,
+		"guess":  codes.Unauthenticated,
+		"0ld":    codes.PermissionDenied,
+	} {
+		ctx := context.Background()
+		if key != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(apiKeyHeader, key))
+		}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+		_, err := authInterceptor(keys, true)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: url_service.URLService_ShortenURL_FullMethodName}, handler)
+		if status.Code(err) != want {
+			t.Errorf("ShortenURL with key %q: got %v, want %v", key, err, want)
+		}
+	}
+}
+
+func TestLoadAPIKeysFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte("# rotated monthly\nci:c1-key:deploy\n\nold:0ld\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := loadAPIKeys("monitor:s3cret", path, "old")
+	if err != nil {
+		t.Fatalf("loadAPIKeys: %v", err)
+	}
+	for key, want := range map[string]apiKey{
+		"s3cret": {id: "monitor", user: "monitor"},
+		"c1-key": {id: "ci", user: "deploy"},
+	} {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(apiKeyHeader, key))
+		if got, err := keys.authenticate(ctx); err != nil || got != want {
+			t.Errorf("authenticate(%s) = %+v, %v, want %+v", key, got, err, want)
+		}
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(apiKeyHeader, "0ld"))
+	if _, err := keys.authenticate(ctx); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("authenticate with a revoked key from the file: got %v, want PermissionDenied", err)
+	}
+
+	if _, err := loadAPIKeys("no-key", "", ""); err == nil {
+		t.Error("loadAPIKeys accepted an entry without a key")
+	}
+}
+
+func TestShortenURLRecordsKeyID(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+	ctx := withKey(context.Background(), "ci-key", "deploy")
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "keyed"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	req, ok := storage.url("keyed")
+	if !ok {
+		t.Fatal("keyed not saved")
+	}
+	if req.ApiKeyId != "ci-key" || req.UserId != "deploy" {
+		t.Errorf("saved with key %q, user %q, want ci-key, deploy", req.ApiKeyId, req.UserId)
+	}
+}
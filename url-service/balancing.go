@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// Load balancing policies of a downstream client. round_robin keeps a
+// connection to every address the target resolves to, such as each pod
+// behind a Kubernetes headless service, and spreads calls over them;
+// pick_first sends everything to one.
+const (
+	lbRoundRobin = "round_robin"
+	lbPickFirst  = "pick_first"
+)
+
+const (
+	defaultStorageResolveInterval = 30 * time.Second
+
+	// dnsMinResolutionInterval is how often gRPC's DNS resolver looks up a
+	// target at most, unless the resolve interval asks for more.
+	dnsMinResolutionInterval = 30 * time.Second
+)
+
+// balancing is how a client spreads its calls over the addresses of its
+// target.
+type balancing struct {
+	policy string
+	// resolveInterval looks the target up again this often, so replicas
+	// added by a scale up get traffic. 0 only does when a connection drops.
+	resolveInterval time.Duration
+	// waitForReady holds calls while no address is reachable, as while the
+	// endpoints of a headless service are replaced, instead of failing them
+	// at once. They still fail at their deadline.
+	waitForReady bool
+}
+
+func storageBalancing(cfg Config) balancing {
+	return balancing{
+		policy:          cfg.StorageLBPolicy,
+		resolveInterval: cfg.StorageResolveInterval,
+		waitForReady:    cfg.StorageWaitForReady,
+	}
+}
+
+// cacheBalancing sticks each cache node's connection to one address: the
+// ring places keys on nodes, and spreading a node's calls over several
+// replicas would split its keys between them. The ring fails over to the
+// next node rather than waiting.
+var cacheBalancing = balancing{policy: lbPickFirst}
+
+// refreshingResolverBuilder builds gRPC's DNS resolver, asked to resolve
+// again every interval. On its own it only does when a connection is lost,
+// which a new replica never causes.
+type refreshingResolverBuilder struct {
+	resolver.Builder
+	interval time.Duration
+}
+
+func newRefreshingResolver(interval time.Duration) resolver.Builder {
+	return refreshingResolverBuilder{Builder: resolver.Get("dns"), interval: interval}
+}
+
+func (b refreshingResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	r, err := b.Builder.Build(target, cc, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.ResolveNow(resolver.ResolveNowOptions{})
+			}
+		}
+	}()
+	return &refreshingResolver{Resolver: r, stop: cancel}, nil
+}
+
+type refreshingResolver struct {
+	resolver.Resolver
+	stop context.CancelFunc
+}
+
+func (r *refreshingResolver) Close() {
+	r.stop()
+	r.Resolver.Close()
+}
+
+// downstreamMetrics counts a client's connections and calls by the backend
+// address they went to, showing how evenly calls are spread.
+type downstreamMetrics struct {
+	connections *prometheus.GaugeVec
+	rpcs        *prometheus.CounterVec
+
+	mu   sync.Mutex
+	open map[[2]string]int // connections by service and backend
+}
+
+func newDownstreamMetrics() *downstreamMetrics {
+	return &downstreamMetrics{
+		connections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "url_service_downstream_connections",
+			Help: "Open connections to downstream services, by service and backend address.",
+		}, []string{"service", "backend"}),
+		rpcs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "url_service_downstream_rpcs_total",
+			Help: "Calls to downstream services, retries included, by service, backend address and status code.",
+		}, []string{"service", "backend", "code"}),
+		open: make(map[[2]string]int),
+	}
+}
+
+// connected adds delta to the connections open to backend, dropping the
+// series of a backend with none so replicas that went away don't linger.
+func (m *downstreamMetrics) connected(service, backend string, delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := [2]string{service, backend}
+	m.open[key] += delta
+	if m.open[key] > 0 {
+		m.connections.WithLabelValues(service, backend).Set(float64(m.open[key]))
+		return
+	}
+	delete(m.open, key)
+	m.connections.DeleteLabelValues(service, backend)
+}
+
+// statsHandler reports a client's connections and calls to m, as service.
+func (m *downstreamMetrics) statsHandler(service string) stats.Handler {
+	return downstreamStats{metrics: m, service: service}
+}
+
+type downstreamStats struct {
+	metrics *downstreamMetrics
+	service string
+}
+
+type connBackendKey struct{}
+
+// rpcBackend is where an attempt went, once its headers are sent.
+type rpcBackend struct {
+	addr string
+}
+
+type rpcBackendKey struct{}
+
+func (h downstreamStats) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return context.WithValue(ctx, connBackendKey{}, info.RemoteAddr.String())
+}
+
+func (h downstreamStats) HandleConn(ctx context.Context, s stats.ConnStats) {
+	backend, _ := ctx.Value(connBackendKey{}).(string)
+	switch s.(type) {
+	case *stats.ConnBegin:
+		h.metrics.connected(h.service, backend, 1)
+	case *stats.ConnEnd:
+		h.metrics.connected(h.service, backend, -1)
+	}
+}
+
+// TagRPC is called for every attempt of a call, so retries are counted on
+// the backend they went to.
+func (h downstreamStats) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, rpcBackendKey{}, &rpcBackend{})
+}
+
+func (h downstreamStats) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	backend, ok := ctx.Value(rpcBackendKey{}).(*rpcBackend)
+	if !ok {
+		return
+	}
+	switch s := s.(type) {
+	case *stats.OutHeader:
+		if s.RemoteAddr != nil {
+			backend.addr = s.RemoteAddr.String()
+		}
+	case *stats.End:
+		// Attempts that never reached a backend, such as those still
+		// waiting for one at their deadline
+		addr := backend.addr
+		if addr == "" {
+			addr = "none"
+		}
+		h.metrics.rpcs.WithLabelValues(h.service, addr, status.Code(s.Error).String()).Inc()
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+// serveFakeStorage serves a fake storage holding the link "spread" until
+// the test ends, and returns its address.
+func serveFakeStorage(t *testing.T) string {
+	t.Helper()
+	storage := newFakeStorage()
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "spread", OriginalUrl: "https://example.com"})
+	return serveGRPC(t, func(srv *grpc.Server) {
+		storage_service.RegisterStorageServiceServer(srv, storage)
+		grpc_health_v1.RegisterHealthServer(srv, storage.health)
+	})
+}
+
+// newBalancedStorageClient returns a storage client balanced as policy over
+// the addresses r resolves to, and the metrics it reports to.
+func newBalancedStorageClient(t *testing.T, r *manual.Resolver, policy string) (storage_service.StorageServiceClient, *downstreamMetrics, *prometheus.Registry) {
+	t.Helper()
+	cfg, err := loadConfig(nil, testEnv(map[string]string{"BREAKER_FAILURE_THRESHOLD": "100"}))
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	metrics := newDownstreamMetrics()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(metrics.connections, metrics.rpcs)
+	// A scheme of the test's own, as resolvers are registered for the process
+	resolver.Register(r)
+	breaker := newCircuitBreaker("storage-service", cfg.BreakerFailureThreshold, cfg.BreakerOpenTimeout)
+	lb := balancing{policy: policy, waitForReady: true}
+	conn, err := newClientConn(r.Scheme()+":///storage", cfg, breaker, lb, "storage-service", metrics)
+	if err != nil {
+		t.Fatalf("newClientConn: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return storage_service.NewStorageServiceClient(conn), metrics, registry
+}
+
+// testScheme returns a resolver scheme unique to the test.
+func testScheme(t *testing.T) string {
+	return strings.ToLower(strings.NewReplacer("/", "-", "_", "-").Replace(t.Name()))
+}
+
+// lookups makes n GetURL calls with client, failing the test on any error.
+func lookups(t *testing.T, client storage_service.StorageServiceClient, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := client.GetURL(ctx, &storage_service.GetURLRequest{ShortCode: "spread"})
+		cancel()
+		if err != nil {
+			t.Fatalf("GetURL %d: %v", i, err)
+		}
+	}
+}
+
+// rpcsByBackend returns the successful calls counted for each backend.
+func rpcsByBackend(t *testing.T, registry *prometheus.Registry, backends ...string) []float64 {
+	t.Helper()
+	rpcs := gathered(t, registry, "url_service_downstream_rpcs_total")
+	counts := make([]float64, len(backends))
+	for i, backend := range backends {
+		counts[i] = rpcs[fmt.Sprintf("backend=%s,code=OK,service=storage-service", backend)]
+	}
+	return counts
+}
+
+func TestStorageRoundRobin(t *testing.T) {
+	a, b := serveFakeStorage(t), serveFakeStorage(t)
+	r := manual.NewBuilderWithScheme(testScheme(t))
+	r.InitialState(resolver.State{Addresses: []resolver.Address{{Addr: a}, {Addr: b}}})
+	client, _, registry := newBalancedStorageClient(t, r, lbRoundRobin)
+
+	// Calls go out as soon as one replica is up; once both are they alternate
+	lookups(t, client, 1)
+	waitFor(t, "both connections", func() bool {
+		return len(gathered(t, registry, "url_service_downstream_connections")) == 2
+	})
+	before := rpcsByBackend(t, registry, a, b)
+	lookups(t, client, 100)
+	after := rpcsByBackend(t, registry, a, b)
+	for i, addr := range []string{a, b} {
+		if got := after[i] - before[i]; got < 40 || got > 60 {
+			t.Errorf("%s served %v of 100 calls, want about half", addr, got)
+		}
+	}
+	connections := gathered(t, registry, "url_service_downstream_connections")
+	for _, addr := range []string{a, b} {
+		if got := connections["backend="+addr+",service=storage-service"]; got != 1 {
+			t.Errorf("connections to %s %v, want 1", addr, got)
+		}
+	}
+}
+
+func TestStoragePickFirst(t *testing.T) {
+	a, b := serveFakeStorage(t), serveFakeStorage(t)
+	r := manual.NewBuilderWithScheme(testScheme(t))
+	r.InitialState(resolver.State{Addresses: []resolver.Address{{Addr: a}, {Addr: b}}})
+	client, _, registry := newBalancedStorageClient(t, r, lbPickFirst)
+
+	lookups(t, client, 50)
+	counts := rpcsByBackend(t, registry, a, b)
+	if counts[0]+counts[1] != 50 || counts[0] != 0 && counts[1] != 0 {
+		t.Errorf("calls by backend %v, want all 50 on one", counts)
+	}
+}
+
+func TestStorageScaleEvent(t *testing.T) {
+	a, b, c := serveFakeStorage(t), serveFakeStorage(t), serveFakeStorage(t)
+	r := manual.NewBuilderWithScheme(testScheme(t))
+	r.InitialState(resolver.State{Addresses: []resolver.Address{{Addr: a}, {Addr: b}}})
+	client, _, registry := newBalancedStorageClient(t, r, lbRoundRobin)
+	lookups(t, client, 1)
+
+	// Calls keep going while the endpoints are replaced, none may fail
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				_, err := client.GetURL(ctx, &storage_service.GetURLRequest{ShortCode: "spread"})
+				cancel()
+				if err != nil {
+					t.Errorf("GetURL during the scale event: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	r.UpdateState(resolver.State{Addresses: []resolver.Address{{Addr: b}, {Addr: c}}})
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	// The replica that went away gets no more calls and its series goes
+	waitFor(t, "the old connection to close", func() bool {
+		connections := gathered(t, registry, "url_service_downstream_connections")
+		return connections["backend="+a+",service=storage-service"] == 0 && len(connections) == 2
+	})
+	before := rpcsByBackend(t, registry, a, b, c)
+	lookups(t, client, 100)
+	after := rpcsByBackend(t, registry, a, b, c)
+	if after[0] != before[0] {
+		t.Errorf("%s served %v calls after it was removed", a, after[0]-before[0])
+	}
+	for i, addr := range []string{b, c} {
+		if got := after[i+1] - before[i+1]; got < 40 || got > 60 {
+			t.Errorf("%s served %v of 100 calls, want about half", addr, got)
+		}
+	}
+}
+
+func TestStorageWaitForReady(t *testing.T) {
+	a := serveFakeStorage(t)
+	r := manual.NewBuilderWithScheme(testScheme(t))
+	r.InitialState(resolver.State{Addresses: []resolver.Address{}})
+	client, _, _ := newBalancedStorageClient(t, r, lbRoundRobin)
+
+	// With no endpoint yet the call waits instead of failing
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := client.GetURL(ctx, &storage_service.GetURLRequest{ShortCode: "spread"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("GetURL with no endpoint returned %v, want it to wait", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	r.UpdateState(resolver.State{Addresses: []resolver.Address{{Addr: a}}})
+	if err := <-done; err != nil {
+		t.Errorf("GetURL once an endpoint came up: %v", err)
+	}
+}
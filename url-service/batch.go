@@ -0,0 +1,443 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cache_service "github.com/syedalijabir/protos/cache-service"
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultMaxBatchSize = 1000
+
+// batchItem is one link of a BatchShorten call on its way to storage.
+type batchItem struct {
+	index        int
+	shortCode    string
+	custom       bool
+	requestedURL string
+	originalURL  string
+	expiresAt    time.Time
+}
+
+// BatchShorten creates many links in one call. Items are validated
+// independently and written with a single storage insert, so one bad item
+// only fails itself. Results are positionally aligned with the request.
+func (s *urlServer) BatchShorten(ctx context.Context, req *url_service.BatchShortenRequest) (*url_service.BatchShortenResponse, error) {
+	logf(ctx, "BatchShorten request for %d URLs", len(req.Items))
+
+	if len(req.Items) > s.maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "batch of %d URLs exceeds the maximum of %d", len(req.Items), s.maxBatchSize)
+	}
+	if err := s.checkQuota(ctx, len(req.Items)); err != nil {
+		return nil, err
+	}
+
+	results := make([]*url_service.BatchShortenResult, len(req.Items))
+	fail := func(i int, err error) {
+		st := status.Convert(err)
+		results[i] = &url_service.BatchShortenResult{Code: int32(st.Code()), Error: st.Message()}
+	}
+
+	// 1. Validate every item and claim its code within the batch
+	claimed := make(map[string]bool)
+	var pending []*batchItem
+	for i, item := range req.Items {
+		b, err := s.prepareBatchItem(ctx, i, item, claimed)
+		if err != nil {
+			fail(i, err)
+			continue
+		}
+		claimed[b.shortCode] = true
+		pending = append(pending, b)
+	}
+	pending = s.screenBatch(ctx, pending, fail)
+
+	// 2. Insert, giving generated codes that collide with stored ones a new
+	// code on each round
+	for attempt := 1; len(pending) > 0; attempt++ {
+		inserted, failed, err := s.saveBatch(ctx, pending)
+		if err != nil {
+			logf(ctx, "Failed to persist batch: %v", err)
+			for _, b := range pending {
+				fail(b.index, status.Error(codes.Unavailable, "failed to persist URL"))
+			}
+			break
+		}
+
+		var retry []*batchItem
+		for _, b := range pending {
+			switch {
+			case inserted[b.shortCode]:
+				results[b.index] = &url_service.BatchShortenResult{Url: s.createdBatchItem(ctx, b)}
+			case failed[b.shortCode]:
+				fail(b.index, status.Error(codes.Unavailable, "failed to persist URL"))
+			case b.custom:
+				fail(b.index, status.Error(codes.AlreadyExists, "Custom alias already exists"))
+			case attempt == maxShortCodeAttempts:
+				fail(b.index, status.Error(codes.ResourceExhausted, "could not generate a unique short code"))
+			default:
+				logf(ctx, "Short code collision for %s (attempt %d/%d)", b.shortCode, attempt, maxShortCodeAttempts)
+				b.shortCode, err = s.generateBatchShortCode(ctx, claimed)
+				if err != nil {
+					fail(b.index, err)
+					continue
+				}
+				claimed[b.shortCode] = true
+				retry = append(retry, b)
+			}
+		}
+		pending = retry
+	}
+
+	logf(ctx, "BatchShorten finished for %d URLs", len(req.Items))
+	return &url_service.BatchShortenResponse{Results: results}, nil
+}
+
+// screenBatch checks the destinations of the batch with the reputation
+// provider in one lookup, failing the items it rejects, and returns the rest.
+func (s *urlServer) screenBatch(ctx context.Context, items []*batchItem, fail func(int, error)) []*batchItem {
+	urls := make([]string, len(items))
+	for i, b := range items {
+		urls[i] = b.originalURL
+	}
+	errs := s.reputation.Screen(ctx, urls)
+	if len(errs) == 0 {
+		return items
+	}
+
+	kept := items[:0]
+	for _, b := range items {
+		if err := errs[b.originalURL]; err != nil {
+			fail(b.index, err)
+			continue
+		}
+		kept = append(kept, b)
+	}
+	return kept
+}
+
+// prepareBatchItem validates one item and picks its code. claimed holds the
+// codes already taken by earlier items of the batch.
+func (s *urlServer) prepareBatchItem(ctx context.Context, index int, item *url_service.ShortenRequest, claimed map[string]bool) (*batchItem, error) {
+	if len(item.Variants) > 0 || item.StickyVariants {
+		return nil, status.Error(codes.InvalidArgument, "variants aren't supported in batches")
+	}
+	if len(item.Rules) > 0 {
+		return nil, status.Error(codes.InvalidArgument, "rules aren't supported in batches")
+	}
+	if item.QueryTemplate != "" {
+		return nil, status.Error(codes.InvalidArgument, "query templates aren't supported in batches")
+	}
+	if len(item.Tags) > 0 {
+		return nil, status.Error(codes.InvalidArgument, "tags aren't supported in batches")
+	}
+	if item.CodeStrategy == codeStrategyHash {
+		return nil, status.Error(codes.InvalidArgument, "hash codes aren't supported in batches")
+	}
+	if _, err := s.codeStrategy(item.CodeStrategy); err != nil {
+		return nil, err
+	}
+	if item.TenantId != "" && item.TenantId != tenantID(ctx) {
+		return nil, status.Error(codes.InvalidArgument, "tenant_id of an item must be the batch's tenant")
+	}
+	// Other shorteners' redirects aren't followed for a whole batch
+	var aliasKey string
+	if item.CustomAlias != "" {
+		aliasKey = tenantKey(tenantID(ctx), item.CustomAlias)
+	}
+	linkURL, err := s.resolveLinkChain(ctx, item.OriginalUrl, aliasKey, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validator.Validate(linkURL); err != nil {
+		return nil, err
+	}
+	originalURL, err := s.canonicalURL(linkURL)
+	if err != nil {
+		return nil, err
+	}
+	if item.TtlSeconds < 0 {
+		return nil, status.Error(codes.InvalidArgument, "ttl_seconds must not be negative")
+	}
+
+	b := &batchItem{index: index, requestedURL: item.OriginalUrl, originalURL: originalURL}
+	if item.TtlSeconds > 0 {
+		b.expiresAt = time.Now().Add(time.Duration(item.TtlSeconds) * time.Second)
+	}
+
+	if item.CustomAlias != "" {
+		if err := s.aliases.Validate(item.CustomAlias); err != nil {
+			return nil, err
+		}
+		key := tenantKey(tenantID(ctx), item.CustomAlias)
+		if claimed[key] || s.urls.Contains(key) {
+			return nil, status.Error(codes.AlreadyExists, "Custom alias already exists")
+		}
+		b.shortCode, b.custom = key, true
+		return b, nil
+	}
+
+	b.shortCode, err = s.generateBatchShortCode(ctx, claimed)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// generateBatchShortCode returns the key in ctx's tenant of a random or
+// sequence code not reserved, in memory or claimed by the batch. Storage collisions are detected by the
+// insert, so batches don't need the key pool either. With CODE_STRATEGY=hash
+// they get random codes too, a hash code takes a lookup per item.
+func (s *urlServer) generateBatchShortCode(ctx context.Context, claimed map[string]bool) (string, error) {
+	if s.ids != nil {
+		s.metrics.shortCodes.WithLabelValues(codeStrategySequence).Inc()
+		return s.nextSequenceCode(ctx, claimed)
+	}
+	s.metrics.shortCodes.WithLabelValues(codeStrategyRandom).Inc()
+	for attempt := 1; attempt <= maxShortCodeAttempts; attempt++ {
+		shortCode, err := generateShortCode(s.codeAlphabet, s.codeLength)
+		if err != nil {
+			return "", status.Errorf(codes.Internal, "failed to generate short code: %v", err)
+		}
+		key := tenantKey(tenantID(ctx), shortCode)
+		if s.aliases.Validate(shortCode) != nil || claimed[key] || s.urls.Contains(key) {
+			continue
+		}
+		return key, nil
+	}
+	return "", status.Error(codes.ResourceExhausted, "could not generate a unique short code")
+}
+
+// saveBatch writes items to storage and returns the codes that were inserted
+// and those storage failed to write. The others already existed.
+func (s *urlServer) saveBatch(ctx context.Context, items []*batchItem) (inserted, failed map[string]bool, err error) {
+	urls := make([]*storage_service.SaveURLRequest, 0, len(items))
+	for _, b := range items {
+		urls = append(urls, &storage_service.SaveURLRequest{
+			ShortCode:   b.shortCode,
+			OriginalUrl: b.originalURL,
+			ExpiresAt:   formatOptionalTime(b.expiresAt),
+			ApiKeyId:    apiKeyID(ctx),
+			UserId:      userID(ctx),
+			TenantId:    tenantID(ctx),
+		})
+	}
+
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	resp, err := s.storageClient.SaveURLs(storageCtx, &storage_service.SaveURLsRequest{Urls: urls})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	inserted = make(map[string]bool, len(resp.InsertedShortCodes))
+	for _, shortCode := range resp.InsertedShortCodes {
+		inserted[shortCode] = true
+	}
+	failed = make(map[string]bool, len(resp.FailedShortCodes))
+	for _, shortCode := range resp.FailedShortCodes {
+		failed[shortCode] = true
+	}
+	return inserted, failed, nil
+}
+
+// createdBatchItem makes a stored item visible and builds its result. Bulk
+// imports are rarely resolved right away, so memory and cache are left to
+// fill on first lookup.
+func (s *urlServer) createdBatchItem(ctx context.Context, b *batchItem) *url_service.ShortenResponse {
+	s.mu.Lock()
+	delete(s.deleted, b.shortCode)
+	s.mu.Unlock()
+	if b.custom {
+		s.forgetMissing(ctx, b.shortCode)
+	}
+	s.publishCreated(ctx, b.shortCode, b.originalURL, b.expiresAt)
+	s.metadata.Fetch(ctx, b.shortCode, b.originalURL)
+
+	return &url_service.ShortenResponse{
+		ShortCode:     b.shortCode,
+		OriginalUrl:   b.requestedURL,
+		NormalizedUrl: b.originalURL,
+		ShortUrl:      s.shortURL(b.shortCode),
+		CreatedAt:     time.Now().Format(time.RFC3339),
+		ExpiresAt:     formatOptionalTime(b.expiresAt),
+		TenantId:      tenantID(ctx),
+	}
+}
+
+// BatchGetOriginal resolves many short codes with at most one cache and one
+// storage round trip: memory first, then a cache multi-get (which also
+// covers the not-found sentinels), then a single storage query for the rest.
+// Bulk lookups come from analytics rather than visitors, so they don't warm
+// memory or the cache.
+func (s *urlServer) BatchGetOriginal(ctx context.Context, req *url_service.BatchGetOriginalRequest) (*url_service.BatchGetOriginalResponse, error) {
+	logf(ctx, "BatchGetOriginal request for %d codes", len(req.ShortCodes))
+
+	if len(req.ShortCodes) > s.maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "batch of %d codes exceeds the maximum of %d", len(req.ShortCodes), s.maxBatchSize)
+	}
+
+	// Codes may repeat, so results are filled per distinct code
+	found := make(map[string]*url_service.GetOriginalResponse)
+	var remaining []string
+	seen := make(map[string]bool)
+	for _, shortCode := range req.ShortCodes {
+		if seen[shortCode] {
+			continue
+		}
+		seen[shortCode] = true
+
+		if s.isDeleted(shortCode) {
+			continue
+		}
+		if entry, ok := s.urls.Get(shortCode); ok && !isExpired(entry.expiresAt) && entry.fresh(time.Now()) {
+			s.metrics.lookup("memory")
+			if entry.disabled {
+				found[shortCode] = &url_service.GetOriginalResponse{Disabled: true}
+			} else {
+				found[shortCode] = foundResponse(shortCode, entry.originalURL, entry.routes)
+			}
+			continue
+		}
+		remaining = append(remaining, shortCode)
+	}
+
+	remaining = s.batchFromCache(ctx, remaining, found)
+	if err := s.batchFromStorage(ctx, remaining, found); err != nil {
+		return nil, err
+	}
+
+	results := make([]*url_service.GetOriginalResponse, len(req.ShortCodes))
+	for i, shortCode := range req.ShortCodes {
+		resp, ok := found[shortCode]
+		if !ok {
+			resp = &url_service.GetOriginalResponse{}
+		}
+		results[i] = resp
+		if req.CountClicks && resp.Found {
+			s.incrementStats(&storage_service.ClickEvent{ShortCode: shortCode, Variant: resp.Variant, Rule: resp.Rule})
+		}
+	}
+	return &url_service.BatchGetOriginalResponse{Results: results}, nil
+}
+
+// batchFromCache fills found from the cache and returns the codes that still
+// need storage. A cache failure just sends every code to storage.
+func (s *urlServer) batchFromCache(ctx context.Context, shortCodes []string, found map[string]*url_service.GetOriginalResponse) []string {
+	if len(shortCodes) == 0 {
+		return nil
+	}
+
+	cacheCtx, cancel := s.cacheReadCtx(ctx)
+	defer cancel()
+
+	// URLs and not-found sentinels live in separate namespaces, read in
+	// parallel
+	var urls, missing []*cache_service.GetResponse
+	g, gctx := errgroup.WithContext(cacheCtx)
+	g.Go(func() (err error) {
+		urls, err = s.mgetNamespace(gctx, urlNamespace, shortCodes)
+		return err
+	})
+	if s.live().negativeTTL > 0 {
+		g.Go(func() (err error) {
+			missing, err = s.mgetNamespace(gctx, notFoundNamespace, shortCodes)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		logf(ctx, "Warning: cache multi-get failed, falling back to storage: %v", err)
+		return shortCodes
+	}
+
+	var remaining []string
+	for i, shortCode := range shortCodes {
+		var cachedURL string
+		var cachedRoutes linkRoutes
+		if urls[i].Found {
+			cachedURL, cachedRoutes = parseCacheValue(urls[i].Value)
+		}
+		switch {
+		case cachedURL != "":
+			s.metrics.lookup("cache")
+			found[shortCode] = foundResponse(shortCode, cachedURL, cachedRoutes)
+		case missing != nil && missing[i].Found:
+			s.metrics.lookup("negative_cache")
+		default:
+			remaining = append(remaining, shortCode)
+		}
+	}
+	return remaining
+}
+
+// mgetNamespace reads keys from one cache namespace, one result per key.
+func (s *urlServer) mgetNamespace(ctx context.Context, namespace string, keys []string) ([]*cache_service.GetResponse, error) {
+	resp, err := s.cacheClient.MGet(ctx, &cache_service.MGetRequest{Namespace: namespace, Keys: keys})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Values) != len(keys) {
+		return nil, fmt.Errorf("got %d values for %d %s keys", len(resp.Values), len(keys), namespace)
+	}
+	return resp.Values, nil
+}
+
+// batchFromStorage fills found from a single storage query.
+func (s *urlServer) batchFromStorage(ctx context.Context, shortCodes []string, found map[string]*url_service.GetOriginalResponse) error {
+	if len(shortCodes) == 0 {
+		return nil
+	}
+
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	resp, err := s.storageClient.GetURLs(storageCtx, &storage_service.GetURLsRequest{
+		ShortCodes:     shortCodes,
+		IncludeExpired: true,
+	})
+	if err != nil {
+		logf(ctx, "Storage batch lookup failed: %v", err)
+		return status.Error(codes.Unavailable, "storage unavailable")
+	}
+
+	for _, shortCode := range shortCodes {
+		stored, ok := resp.Urls[shortCode]
+		switch {
+		case !ok:
+			s.metrics.lookup("not_found")
+		case isExpired(parseOptionalTime(stored.ExpiresAt)):
+			s.metrics.lookup("expired")
+			found[shortCode] = &url_service.GetOriginalResponse{Expired: true}
+		case stored.Disabled:
+			s.metrics.lookup("storage")
+			found[shortCode] = &url_service.GetOriginalResponse{Disabled: true}
+		default:
+			s.metrics.lookup("storage")
+			found[shortCode] = foundResponse(shortCode, stored.OriginalUrl, routesFromStorage(stored))
+		}
+	}
+	return nil
+}
+
+// foundResponse resolves a link for a bulk lookup. With no visitor to match
+// rules against or keep sticky, links with rules go to their default and
+// split links get a variant at random.
+func foundResponse(shortCode, originalURL string, routes linkRoutes) *url_service.GetOriginalResponse {
+	target := linkTarget{URL: originalURL}
+	if len(routes.rules) > 0 {
+		target.Rule = ruleDefault
+	}
+	if routes.variants != nil {
+		v := routes.variants.Pick("")
+		target.URL, target.Variant = v.URL, v.Name
+	}
+	_, shortCode = splitTenantKey(shortCode)
+	target.URL = applyQueryTemplate(target.URL, routes.queryTemplate, shortCode, target.Variant)
+	return target.response()
+}
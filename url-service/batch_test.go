@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+)
+
+func TestBatchGetOriginalOneStorageRoundTrip(t *testing.T) {
+	s, storage, cache := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+	ctx := withKey(context.Background(), "alice-key", "alice")
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com/memory", CustomAlias: "memory"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	cache.set("url:cached", "https://example.com/cached")
+	cache.set("notfound:gone", "1")
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "stored1", OriginalUrl: "https://example.com/1"})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "stored2", OriginalUrl: "https://example.com/2"})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "old", OriginalUrl: "https://example.com/old", ExpiresAt: time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)})
+
+	codes := []string{"memory", "cached", "stored1", "ghost", "stored2", "gone", "old", "stored1"}
+	resp, err := s.BatchGetOriginal(ctx, &url_service.BatchGetOriginalRequest{ShortCodes: codes})
+	if err != nil {
+		t.Fatalf("BatchGetOriginal: %v", err)
+	}
+
+	want := map[string]string{
+		"memory":  "https://example.com/memory",
+		"cached":  "https://example.com/cached",
+		"stored1": "https://example.com/1",
+		"stored2": "https://example.com/2",
+	}
+	if len(resp.Results) != len(codes) {
+		t.Fatalf("%d results for %d codes", len(resp.Results), len(codes))
+	}
+	for i, code := range codes {
+		result := resp.Results[i]
+		if result.Found != (want[code] != "") || result.OriginalUrl != want[code] {
+			t.Errorf("result %d (%s) = %v, want %q", i, code, result, want[code])
+		}
+	}
+	if !resp.Results[6].Expired {
+		t.Errorf("old not reported expired: %v", resp.Results[6])
+	}
+
+	// Only the misses go to storage, each once, in one call
+	storage.mu.Lock()
+	calls := slices.Clone(storage.getURLsCalls)
+	storage.mu.Unlock()
+	if len(calls) != 1 {
+		t.Fatalf("%d storage round trips, want 1", len(calls))
+	}
+	asked := slices.Clone(calls[0])
+	slices.Sort(asked)
+	if !slices.Equal(asked, []string{"ghost", "old", "stored1", "stored2"}) {
+		t.Errorf("storage asked for %v, want ghost, old, stored1 and stored2", asked)
+	}
+}
+
+func TestBatchGetOriginalCountClicks(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "stored", OriginalUrl: "https://example.com"})
+	ctx := context.Background()
+
+	for _, count := range []bool{false, true} {
+		if _, err := s.BatchGetOriginal(ctx, &url_service.BatchGetOriginalRequest{ShortCodes: []string{"stored", "ghost"}, CountClicks: count}); err != nil {
+			t.Fatalf("BatchGetOriginal: %v", err)
+		}
+		want := int64(0)
+		if count {
+			want = 1
+		}
+		if n := s.clicks.Pending("stored") + storage.clicks("stored"); n != want {
+			t.Errorf("count_clicks %v: %d clicks, want %d", count, n, want)
+		}
+	}
+	if n := s.clicks.Pending("ghost"); n != 0 {
+		t.Errorf("%d clicks on a missing code", n)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import "strings"
+
+// defaultBotMarkers are lowercase substrings of the user agents of
+// crawlers, link preview fetchers and HTTP libraries. BOT_USER_AGENTS adds
+// to them.
+var defaultBotMarkers = []string{
+	"bot", "crawler", "spider", "slurp", "facebookexternalhit", "embedly",
+	"preview", "headless", "lighthouse", "curl/", "wget/", "python-",
+	"go-http-client", "okhttp", "java/", "httpclient", "axios/",
+}
+
+// botClassifier tells bots apart from people by their user agent.
+type botClassifier struct {
+	markers []string
+}
+
+// newBotClassifier matches the default markers and extra, ignoring case
+// and blank entries.
+func newBotClassifier(extra []string) *botClassifier {
+	markers := append([]string(nil), defaultBotMarkers...)
+	for _, marker := range extra {
+		if marker = strings.ToLower(strings.TrimSpace(marker)); marker != "" {
+			markers = append(markers, marker)
+		}
+	}
+	return &botClassifier{markers: markers}
+}
+
+// IsBot reports whether userAgent belongs to a bot. Clicks without a user
+// agent come from API callers more often than bots, so they count as human.
+func (b *botClassifier) IsBot(userAgent string) bool {
+	lower := strings.ToLower(userAgent)
+	for _, marker := range b.markers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
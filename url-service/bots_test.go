@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+)
+
+func TestBotClassifier(t *testing.T) {
+	bots := []string{
+		"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+		"Slackbot 1.0 (+https://api.slack.com/robots)",
+		"Twitterbot/1.0",
+		"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+		"LinkedInBot/1.0 (compatible; Mozilla/5.0; Apache-HttpClient +http://www.linkedin.com)",
+		"Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)",
+		"TelegramBot (like TwitterBot)",
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+		"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)",
+		"Mozilla/5.0 (compatible; YandexBot/3.0; +http://yandex.com/bots)",
+		"Mozilla/5.0 (compatible; Yahoo! Slurp; http://help.yahoo.com/help/us/ysearch/slurp)",
+		"Mozilla/5.0 (compatible; Embedly/0.2; +http://support.embed.ly/)",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/119.0.0.0 Safari/537.36",
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/112.0.0.0 Safari/537.36 Chrome-Lighthouse",
+		"curl/8.4.0",
+		"Wget/1.21.4",
+		"python-requests/2.31.0",
+		"Go-http-client/1.1",
+		"okhttp/4.12.0",
+		"axios/1.6.2",
+		"Java/17.0.2",
+		"Apache-HttpClient/4.5.14 (Java/17.0.2)",
+	}
+	humans := []string{
+		"",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
+		"Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+		"WhatsApp/2.23.20.0 A",
+	}
+
+	c := newBotClassifier(nil)
+	for _, ua := range bots {
+		if !c.IsBot(ua) {
+			t.Errorf("IsBot(%q) = false, want true", ua)
+		}
+	}
+	for _, ua := range humans {
+		if c.IsBot(ua) {
+			t.Errorf("IsBot(%q) = true, want false", ua)
+		}
+	}
+
+	// Extra markers ignore case and blanks
+	c = newBotClassifier([]string{" WhatsApp/ ", ""})
+	if !c.IsBot("WhatsApp/2.23.20.0 A") {
+		t.Error("extra marker WhatsApp/ not matched")
+	}
+	if c.IsBot(humans[1]) {
+		t.Error("blank marker matched everything")
+	}
+}
+
+func TestBotClicksCountedApart(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"BOT_USER_AGENTS": "WhatsApp/"})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "shared", OriginalUrl: "https://example.com"})
+	ctx := context.Background()
+
+	lookups := []*url_service.GetOriginalRequest{
+		{ShortCode: "shared", UserAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"},
+		{ShortCode: "shared", UserAgent: "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"},
+		{ShortCode: "shared", UserAgent: "WhatsApp/2.23.20.0 A"},
+		{ShortCode: "shared", UserAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0", Prefetch: true},
+	}
+	for _, req := range lookups {
+		// Bots still get the redirect
+		if resp, err := s.GetOriginalURL(ctx, req); err != nil || resp.OriginalUrl != "https://example.com" {
+			t.Fatalf("GetOriginalURL for %q = %v, %v", req.UserAgent, resp, err)
+		}
+	}
+	s.tasks.Close(ctx)
+
+	stats, err := s.GetURLStats(ctx, &url_service.StatsRequest{ShortCode: "shared"})
+	if err != nil {
+		t.Fatalf("GetURLStats: %v", err)
+	}
+	if stats.ClickCount != 1 || stats.BotClicks != 3 || stats.UniqueClicks != 1 {
+		t.Errorf("GetURLStats = %d clicks, %d bots and %d unique, want 1, 3 and 1", stats.ClickCount, stats.BotClicks, stats.UniqueClicks)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerOpenTimeout      = 10 * time.Second
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerClosed:
+		return "closed"
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// circuitBreaker fails calls to a dependency fast once it has failed
+// threshold times in a row. After openTimeout a single probe call is let
+// through; its outcome closes or re-opens the breaker.
+type circuitBreaker struct {
+	name        string
+	threshold   int
+	openTimeout time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newCircuitBreaker(name string, threshold int, openTimeout time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		name:        name,
+		threshold:   threshold,
+		openTimeout: openTimeout,
+	}
+}
+
+// State returns the current breaker state.
+func (b *circuitBreaker) State() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// available reports whether a call would be let through now, without
+// claiming the half-open probe.
+func (b *circuitBreaker) available() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		return time.Since(b.openedAt) >= b.openTimeout
+	case breakerHalfOpen:
+		return !b.probing
+	}
+	return true
+}
+
+// allow reports whether a call may proceed.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.setState(breakerHalfOpen)
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		// Only one probe at a time
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// record updates the breaker with the outcome of an allowed call.
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isDependencyFailure(err) {
+		b.failures = 0
+		b.probing = false
+		b.setState(breakerClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.probing = false
+		b.openedAt = time.Now()
+		b.setState(breakerOpen)
+	}
+}
+
+// setState changes state and logs the transition. Caller must hold b.mu.
+func (b *circuitBreaker) setState(state breakerState) {
+	if b.state == state {
+		return
+	}
+	log.Printf("Circuit breaker %s: %s -> %s", b.name, b.state, state)
+	b.state = state
+}
+
+// UnaryClientInterceptor guards every unary call on a client connection.
+func (b *circuitBreaker) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !b.allow() {
+			return status.Errorf(codes.Unavailable, "%s circuit breaker open", b.name)
+		}
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		b.record(err)
+		return err
+	}
+}
+
+// isDependencyFailure reports whether err means the dependency is unhealthy,
+// as opposed to a normal application error like NotFound.
+func isDependencyFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
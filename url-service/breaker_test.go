@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	b := newCircuitBreaker("storage", 3, 20*time.Millisecond)
+	down := status.Error(codes.Unavailable, "storage down")
+	expect := func(step string, want breakerState) {
+		t.Helper()
+		if got := b.State(); got != want {
+			t.Fatalf("%s: breaker %s, want %s", step, got, want)
+		}
+	}
+
+	// Application errors and interrupted runs of failures keep it closed
+	b.record(down)
+	b.record(down)
+	b.record(status.Error(codes.NotFound, "no such code"))
+	b.record(down)
+	b.record(down)
+	expect("two failures after a NotFound", breakerClosed)
+
+	b.record(down)
+	expect("three failures in a row", breakerOpen)
+	if b.allow() || b.available() {
+		t.Fatal("open breaker let a call through")
+	}
+
+	// After the timeout a single probe goes through; its failure re-opens
+	time.Sleep(25 * time.Millisecond)
+	if !b.available() || !b.allow() {
+		t.Fatal("breaker held the probe back after the timeout")
+	}
+	expect("probe", breakerHalfOpen)
+	if b.allow() {
+		t.Fatal("half-open breaker let a second call through")
+	}
+	b.record(down)
+	expect("failed probe", breakerOpen)
+
+	// A successful probe closes it
+	time.Sleep(25 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("breaker held the second probe back")
+	}
+	b.record(nil)
+	expect("successful probe", breakerClosed)
+	if !b.allow() || !b.allow() {
+		t.Fatal("closed breaker held calls back")
+	}
+}
+
+func TestStorageBreakerOpenServesMemory(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{
+		"URL_SYNC_PERSIST":          "true",
+		"BREAKER_FAILURE_THRESHOLD": "2",
+		"BREAKER_OPEN_TIMEOUT":      "1h",
+	})
+	ctx := context.Background()
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "known"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+
+	var lookups atomic.Int32
+	storage.afterGet = func(string) { lookups.Add(1) }
+	storage.mu.Lock()
+	storage.getErr = status.Error(codes.Internal, "disk failure")
+	storage.mu.Unlock()
+	for _, code := range []string{"miss1", "miss2"} {
+		if _, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: code}); status.Code(err) != codes.Unavailable {
+			t.Fatalf("GetOriginalURL(%s) with storage failing: got %v, want Unavailable", code, err)
+		}
+	}
+	if state := s.storageBreaker.State(); state != breakerOpen {
+		t.Fatalf("storage breaker %s after two failures, want open", state)
+	}
+
+	// Misses fail fast without reaching storage, known codes still resolve
+	lookups.Store(0)
+	storage.mu.Lock()
+	storage.getErr = nil
+	storage.mu.Unlock()
+	if _, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "miss3"}); status.Code(err) != codes.Unavailable {
+		t.Errorf("GetOriginalURL(miss3) with the breaker open: got %v, want Unavailable", err)
+	}
+	resp, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "known"})
+	if err != nil || resp.OriginalUrl != "https://example.com" {
+		t.Errorf("GetOriginalURL(known) with the breaker open = %v, %v, want https://example.com", resp, err)
+	}
+	if n := lookups.Load(); n != 0 {
+		t.Errorf("storage looked up %d codes with the breaker open, want 0", n)
+	}
+}
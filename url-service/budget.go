@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	cache_service "github.com/syedalijabir/protos/cache-service"
+)
+
+const (
+	defaultLookupCacheBudget    = 30 * time.Millisecond
+	defaultLookupHedgeDelay     = 5 * time.Millisecond
+	defaultLookupStorageReserve = 100 * time.Millisecond
+)
+
+// lookupBudget splits a GetOriginalURL deadline between its layers. The
+// cache gets a short slice, never eating into what storage is left with,
+// and storage gets the rest. Memory answers without a budget.
+type lookupBudget struct {
+	// cache bounds each cache read of a lookup
+	cache time.Duration
+	// hedge is how long a lookup that memory could answer waits for the
+	// cache; 0 waits the whole cache budget
+	hedge time.Duration
+	// storageReserve is kept back from the cache when the caller's
+	// deadline is near
+	storageReserve time.Duration
+}
+
+// lookupCacheCtx derives the context for a cache read made by a lookup on
+// behalf of ctx, bounded by limit and by the time left before ctx's
+// deadline less the storage reserve. ok is false when there is no time
+// left for the cache at all.
+func (s *urlServer) lookupCacheCtx(ctx context.Context, limit time.Duration) (_ context.Context, _ context.CancelFunc, ok bool) {
+	if deadline, set := ctx.Deadline(); set {
+		limit = min(limit, time.Until(deadline)-s.budget.storageReserve)
+	}
+	if limit <= 0 {
+		return nil, nil, false
+	}
+	cacheCtx, cancel := context.WithTimeout(outgoingContext(ctx), limit)
+	return cacheCtx, cancel, true
+}
+
+// readCachedURL looks shortCode up in the cache within the lookup budget and
+// returns "" on a miss or when the cache doesn't answer in time. The cache
+// is preferred over memory, which can be stale after another replica changed
+// the link, but when inMemory it is only given the hedge delay: a slow cache
+// then costs a possibly stale answer rather than a slow one.
+func (s *urlServer) readCachedURL(ctx context.Context, shortCode string, inMemory bool) (string, linkRoutes) {
+	limit := s.budget.cache
+	if inMemory && s.budget.hedge > 0 {
+		limit = min(limit, s.budget.hedge)
+	}
+	cacheCtx, cancel, ok := s.lookupCacheCtx(ctx, limit)
+	if !ok {
+		logf(ctx, "No time left to read the cache for: %s", shortCode)
+		return "", linkRoutes{}
+	}
+	defer cancel()
+
+	start := time.Now()
+	resp, err := s.cacheClient.Get(cacheCtx, &cache_service.GetRequest{Namespace: urlNamespace, Key: shortCode})
+	s.metrics.layerDuration("cache", time.Since(start))
+	if cacheCtx.Err() != nil && inMemory {
+		logf(ctx, "Cache too slow, answering from memory: %s", shortCode)
+	}
+	if err != nil || !resp.Found {
+		return "", linkRoutes{}
+	}
+	return parseCacheValue(resp.Value)
+}
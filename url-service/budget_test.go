@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+)
+
+// p99 returns the 99th percentile of durations.
+func p99(durations []time.Duration) time.Duration {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	return sorted[len(sorted)*99/100]
+}
+
+func TestLookupBudgetSlowCache(t *testing.T) {
+	const (
+		cacheBudget = 30 * time.Millisecond
+		hedgeDelay  = 5 * time.Millisecond
+		// slack covers scheduling, storage and the race detector
+		slack = 40 * time.Millisecond
+	)
+	s, storage, cache := newTestServer(t, map[string]string{"LOOKUP_CACHE_BUDGET": "30ms", "LOOKUP_HEDGE_DELAY": "5ms"})
+	for i := 0; i < 100; i++ {
+		storage.put(&storage_service.SaveURLRequest{ShortCode: fmt.Sprintf("slow%03d", i), OriginalUrl: fmt.Sprintf("https://example.com/%d", i)})
+	}
+	cache.mu.Lock()
+	cache.getDelay = 5 * time.Second
+	cache.mu.Unlock()
+
+	// lookups times a lookup of every link
+	lookups := func() []time.Duration {
+		var took []time.Duration
+		for i := 0; i < 100; i++ {
+			start := time.Now()
+			resp, err := s.GetOriginalURL(context.Background(), &url_service.GetOriginalRequest{ShortCode: fmt.Sprintf("slow%03d", i)})
+			took = append(took, time.Since(start))
+			if err != nil || resp.OriginalUrl != fmt.Sprintf("https://example.com/%d", i) {
+				t.Fatalf("GetOriginalURL(slow%03d) = %v, %v", i, resp, err)
+			}
+		}
+		return took
+	}
+
+	// Going to storage costs the cache budget, not the cache's delay
+	if got := p99(lookups()); got > cacheBudget+slack {
+		t.Errorf("p99 from storage %v with the cache hung, want under %v", got, cacheBudget+slack)
+	}
+	// And with memory to fall back on, only the hedge delay
+	if got := p99(lookups()); got > hedgeDelay+slack {
+		t.Errorf("p99 from memory %v with the cache hung, want under %v", got, hedgeDelay+slack)
+	}
+
+	layers := gathered(t, s.metrics.registry, "url_service_lookup_layer_duration_seconds")
+	if layers["layer=cache"] != 200 || layers["layer=storage"] != 100 {
+		t.Errorf("layer durations %v, want 200 cache and 100 storage reads", layers)
+	}
+	lookupsBySource := gathered(t, s.metrics.registry, "url_service_lookups_total")
+	if lookupsBySource["source=storage"] != 100 || lookupsBySource["source=memory"] != 100 {
+		t.Errorf("lookups %v, want 100 from storage and 100 from memory", lookupsBySource)
+	}
+}
+
+func TestLookupBudgetHedge(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		getDelay time.Duration
+		want     string
+	}{
+		{"fast cache wins over memory", nil, 0, "https://example.com/cached"},
+		{"slow cache hedged with memory", map[string]string{"LOOKUP_HEDGE_DELAY": "5ms"}, time.Second, "https://example.com/memory"},
+		{"without hedging the cache gets its whole budget", map[string]string{"LOOKUP_CACHE_BUDGET": "500ms", "LOOKUP_HEDGE_DELAY": "0s"}, 50 * time.Millisecond, "https://example.com/cached"},
+		{"cache past its budget", map[string]string{"LOOKUP_CACHE_BUDGET": "20ms", "LOOKUP_HEDGE_DELAY": "0s"}, time.Second, "https://example.com/memory"},
+	}
+	for _, tt := range tests {
+		s, _, cache := newTestServer(t, tt.env)
+		if _, err := s.ShortenURL(context.Background(), &url_service.ShortenRequest{OriginalUrl: "https://example.com/memory", CustomAlias: "hedged"}); err != nil {
+			t.Fatalf("%s: ShortenURL: %v", tt.name, err)
+		}
+		// Another replica has since moved the link
+		waitForCacheEntry(t, cache, "url:hedged", true)
+		cache.set("url:hedged", "https://example.com/cached")
+		cache.mu.Lock()
+		cache.getDelay = tt.getDelay
+		cache.mu.Unlock()
+
+		resp, err := s.GetOriginalURL(context.Background(), &url_service.GetOriginalRequest{ShortCode: "hedged"})
+		if err != nil || resp.OriginalUrl != tt.want {
+			t.Errorf("%s: GetOriginalURL = %v, %v, want %s", tt.name, resp, err, tt.want)
+		}
+	}
+}
+
+func TestLookupBudgetStorageReserve(t *testing.T) {
+	s, storage, cache := newTestServer(t, map[string]string{"LOOKUP_CACHE_BUDGET": "5s", "LOOKUP_STORAGE_RESERVE": "100ms"})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "reserved", OriginalUrl: "https://example.com"})
+	cache.mu.Lock()
+	cache.getDelay = 5 * time.Second
+	cache.mu.Unlock()
+
+	tests := []struct {
+		name      string
+		deadline  time.Duration
+		maxTook   time.Duration
+		cacheRead bool
+	}{
+		// The cache gets what is left after the reserve, storage the rest
+		{"cache cut short", 300 * time.Millisecond, 250 * time.Millisecond, true},
+		// The cache is skipped when only the reserve is left
+		{"cache skipped", 80 * time.Millisecond, 60 * time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		s.urls.Remove("reserved")
+		before := gathered(t, s.metrics.registry, "url_service_lookup_layer_duration_seconds")["layer=cache"]
+		ctx, cancel := context.WithTimeout(context.Background(), tt.deadline)
+		start := time.Now()
+		resp, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "reserved"})
+		took := time.Since(start)
+		cancel()
+		if err != nil || resp.OriginalUrl != "https://example.com" {
+			t.Errorf("%s: GetOriginalURL = %v, %v, want an answer from storage", tt.name, resp, err)
+		}
+		if took > tt.maxTook {
+			t.Errorf("%s: lookup took %v, want under %v", tt.name, took, tt.maxTook)
+		}
+		after := gathered(t, s.metrics.registry, "url_service_lookup_layer_duration_seconds")["layer=cache"]
+		if read := after > before; read != tt.cacheRead {
+			t.Errorf("%s: cache read %v, want %v", tt.name, read, tt.cacheRead)
+		}
+	}
+}
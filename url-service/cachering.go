@@ -0,0 +1,588 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cache_service "github.com/syedalijabir/protos/cache-service"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const defaultCacheVirtualNodes = 100
+
+// cacheNode is one cache-service replica on the ring, with its own
+// connection and breaker so a failing replica doesn't take the others down.
+type cacheNode struct {
+	addr    string
+	conn    *grpc.ClientConn
+	client  cache_service.CacheServiceClient
+	health  grpc_health_v1.HealthClient
+	breaker *circuitBreaker
+	stop    context.CancelFunc // stops watching conn, nil until the ring runs
+}
+
+type ringPoint struct {
+	hash uint64
+	node *cacheNode
+}
+
+// cacheRing is a CacheServiceClient spread over the cache-service replicas
+// in CACHE_SERVICE_ADDR. Keys are placed by consistent hashing with
+// virtual nodes, so a short code always lands on the same replica and a
+// replica joining or leaving only moves the keys it owns. Calls for a
+// replica whose breaker is open, or that turns out to be unavailable, go to
+// the next replica on the ring.
+type cacheRing struct {
+	cfg       Config
+	addrs     []string // as configured
+	single    bool     // one address used as is, which keeps the plain breaker name
+	failovers *prometheus.CounterVec
+	metrics   *downstreamMetrics
+
+	mu       sync.RWMutex
+	nodes    map[string]*cacheNode // by address
+	points   []ringPoint           // sorted by hash
+	watchCtx context.Context       // set once the ring runs
+}
+
+func newCacheRing(cfg Config, failovers *prometheus.CounterVec, metrics *downstreamMetrics) (*cacheRing, error) {
+	r := &cacheRing{
+		cfg:       cfg,
+		addrs:     splitAddrs(cfg.CacheServiceAddr),
+		failovers: failovers,
+		metrics:   metrics,
+		nodes:     make(map[string]*cacheNode),
+	}
+	r.single = len(r.addrs) == 1 && cfg.CacheResolveInterval == 0
+
+	addrs := r.addrs
+	if cfg.CacheResolveInterval > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.DialTimeout)
+		resolved, err := resolveAddrs(ctx, r.addrs)
+		cancel()
+		if err != nil {
+			log.Printf("Warning: failed to resolve cache-service nodes, using them as configured: %v", err)
+		} else {
+			addrs = resolved
+		}
+	}
+	if err := r.setNodes(addrs); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// splitAddrs splits a comma-separated address list, dropping empty entries.
+func splitAddrs(list string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// resolveAddrs expands every host:port whose host is a name into one
+// address per IP it resolves to, sorted.
+func resolveAddrs(ctx context.Context, addrs []string) ([]string, error) {
+	var resolved []string
+	for _, addr := range addrs {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) != nil {
+			resolved = append(resolved, addr)
+			continue
+		}
+		ips, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			resolved = append(resolved, net.JoinHostPort(ip, port))
+		}
+	}
+	slices.Sort(resolved)
+	return slices.Compact(resolved), nil
+}
+
+// ringHash hashes s onto the ring. FNV alone clusters similar strings such
+// as the virtual nodes of one address, so it is mixed with the splitmix64
+// finalizer.
+func ringHash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// setNodes makes addrs the ring's members. Nodes that stay keep their
+// connection and their keys; only keys owned by nodes that come or go move.
+func (r *cacheRing) setNodes(addrs []string) error {
+	r.mu.RLock()
+	current := r.nodes
+	r.mu.RUnlock()
+
+	nodes := make(map[string]*cacheNode, len(addrs))
+	var added []*cacheNode
+	for _, addr := range addrs {
+		if node, ok := current[addr]; ok {
+			nodes[addr] = node
+			continue
+		}
+		node, err := r.newNode(addr)
+		if err != nil {
+			for _, node := range added {
+				node.conn.Close()
+			}
+			return err
+		}
+		nodes[addr] = node
+		added = append(added, node)
+	}
+
+	points := make([]ringPoint, 0, len(nodes)*r.cfg.CacheVirtualNodes)
+	for addr, node := range nodes {
+		for i := 0; i < r.cfg.CacheVirtualNodes; i++ {
+			points = append(points, ringPoint{hash: ringHash(addr + "#" + strconv.Itoa(i)), node: node})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+
+	r.mu.Lock()
+	r.nodes, r.points = nodes, points
+	if r.watchCtx != nil {
+		for _, node := range added {
+			r.watch(node)
+		}
+	}
+	r.mu.Unlock()
+
+	for addr, node := range current {
+		if _, ok := nodes[addr]; ok {
+			continue
+		}
+		log.Printf("Cache ring: removed %s", addr)
+		if node.stop != nil {
+			node.stop()
+		}
+		node.conn.Close()
+	}
+	// The first members aren't news
+	if len(current) > 0 {
+		for _, node := range added {
+			log.Printf("Cache ring: added %s", node.addr)
+		}
+	}
+	return nil
+}
+
+func (r *cacheRing) newNode(addr string) (*cacheNode, error) {
+	name := "cache-service"
+	if !r.single {
+		name += " " + addr
+	}
+	breaker := newCircuitBreaker(name, r.cfg.BreakerFailureThreshold, r.cfg.BreakerOpenTimeout)
+	conn, err := newClientConn(addr, r.cfg, breaker, cacheBalancing, "cache-service", r.metrics)
+	if err != nil {
+		return nil, err
+	}
+	return &cacheNode{
+		addr:    addr,
+		conn:    conn,
+		client:  cache_service.NewCacheServiceClient(conn),
+		health:  grpc_health_v1.NewHealthClient(conn),
+		breaker: breaker,
+	}, nil
+}
+
+// watch logs the node's connection state until it leaves the ring or the
+// ring stops. Caller must hold r.mu.
+func (r *cacheRing) watch(node *cacheNode) {
+	ctx, cancel := context.WithCancel(r.watchCtx)
+	node.stop = cancel
+	go watchConnState(ctx, node.breaker.name, node.conn)
+}
+
+// Run watches the nodes' connections and, with CACHE_RESOLVE_INTERVAL,
+// re-resolves the configured addresses to follow replicas joining and
+// leaving, until ctx is cancelled.
+func (r *cacheRing) Run(ctx context.Context) {
+	r.mu.Lock()
+	r.watchCtx = ctx
+	for _, node := range r.nodes {
+		r.watch(node)
+	}
+	r.mu.Unlock()
+
+	if r.cfg.CacheResolveInterval == 0 {
+		return
+	}
+	ticker := time.NewTicker(r.cfg.CacheResolveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		resolveCtx, cancel := context.WithTimeout(ctx, r.cfg.DialTimeout)
+		addrs, err := resolveAddrs(resolveCtx, r.addrs)
+		cancel()
+		// Keep the current members rather than emptying the ring on a
+		// DNS hiccup
+		if err != nil || len(addrs) == 0 {
+			log.Printf("Warning: failed to resolve cache-service nodes, keeping the current ones: %v", err)
+			continue
+		}
+		if err := r.setNodes(addrs); err != nil {
+			log.Printf("Warning: failed to update the cache ring: %v", err)
+		}
+	}
+}
+
+// pick returns the node owning key and the node to call for it: the first
+// one from the owner on, other than skip, whose breaker lets calls through.
+// When none does, that is the owner, and the call fails fast.
+func (r *cacheRing) pick(key string, skip *cacheNode) (owner, node *cacheNode) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hash := ringHash(key)
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= hash })
+	seen := make(map[*cacheNode]bool, len(r.nodes))
+	for i := 0; i < len(r.points) && len(seen) < len(r.nodes); i++ {
+		candidate := r.points[(start+i)%len(r.points)].node
+		if seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+		if owner == nil {
+			owner = candidate
+		}
+		if candidate != skip && candidate.breaker.available() {
+			return owner, candidate
+		}
+	}
+	return owner, owner
+}
+
+func (r *cacheRing) failedOver(from *cacheNode) {
+	r.failovers.WithLabelValues(from.addr).Inc()
+}
+
+// ringCall makes call for key on the node picked for it, and once more on
+// the next node if that one turns out to be unavailable.
+func ringCall[T any](r *cacheRing, key string, call func(cache_service.CacheServiceClient) (T, error)) (T, error) {
+	owner, node := r.pick(key, nil)
+	if node != owner {
+		r.failedOver(owner)
+	}
+	resp, err := call(node.client)
+	if status.Code(err) != codes.Unavailable {
+		return resp, err
+	}
+	if _, next := r.pick(key, node); next != node {
+		r.failedOver(node)
+		return call(next.client)
+	}
+	return resp, err
+}
+
+// groupKeys splits key indexes by the node to call for them.
+func (r *cacheRing) groupKeys(keys []string, indexes []int, skip *cacheNode) map[*cacheNode][]int {
+	groups := make(map[*cacheNode][]int)
+	for _, i := range indexes {
+		owner, node := r.pick(keys[i], skip)
+		if node != owner {
+			r.failedOver(owner)
+		}
+		groups[node] = append(groups[node], i)
+	}
+	return groups
+}
+
+// fanOut calls every key group on its node in parallel. A group whose node
+// is unavailable is regrouped once without it.
+func (r *cacheRing) fanOut(ctx context.Context, keys []string, call func(context.Context, *cacheNode, []int) error) error {
+	indexes := make([]int, len(keys))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	for node, group := range r.groupKeys(keys, indexes, nil) {
+		g.Go(func() error {
+			err := call(gctx, node, group)
+			if status.Code(err) != codes.Unavailable {
+				return err
+			}
+			retry := r.groupKeys(keys, group, node)
+			if _, same := retry[node]; same {
+				return err
+			}
+			r.failedOver(node)
+			rg, rctx := errgroup.WithContext(gctx)
+			for next, group := range retry {
+				rg.Go(func() error { return call(rctx, next, group) })
+			}
+			return rg.Wait()
+		})
+	}
+	return g.Wait()
+}
+
+// members returns a snapshot of the ring's nodes, sorted by address.
+func (r *cacheRing) members() []*cacheNode {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	nodes := make([]*cacheNode, 0, len(r.nodes))
+	for _, node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].addr < nodes[j].addr })
+	return nodes
+}
+
+// Breakers returns the nodes' circuit breakers.
+func (r *cacheRing) Breakers() []*circuitBreaker {
+	var breakers []*circuitBreaker
+	for _, node := range r.members() {
+		breakers = append(breakers, node.breaker)
+	}
+	return breakers
+}
+
+// Check returns an error unless at least one node answers its health check
+// as serving; the others' keys fail over to it.
+func (r *cacheRing) Check(ctx context.Context) error {
+	var errs []string
+	for _, node := range r.members() {
+		resp, err := node.health.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Sprintf("%s unreachable: %v", node.breaker.name, err))
+		case resp.Status != grpc_health_v1.HealthCheckResponse_SERVING:
+			errs = append(errs, fmt.Sprintf("%s not serving: %s", node.breaker.name, resp.Status))
+		default:
+			return nil
+		}
+	}
+	return fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+// Close closes the connections to every node.
+func (r *cacheRing) Close() error {
+	var firstErr error
+	for _, node := range r.members() {
+		if err := node.conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (r *cacheRing) Get(ctx context.Context, in *cache_service.GetRequest, opts ...grpc.CallOption) (*cache_service.GetResponse, error) {
+	return ringCall(r, in.Key, func(c cache_service.CacheServiceClient) (*cache_service.GetResponse, error) {
+		return c.Get(ctx, in, opts...)
+	})
+}
+
+func (r *cacheRing) Set(ctx context.Context, in *cache_service.SetRequest, opts ...grpc.CallOption) (*cache_service.SetResponse, error) {
+	return ringCall(r, in.Key, func(c cache_service.CacheServiceClient) (*cache_service.SetResponse, error) {
+		return c.Set(ctx, in, opts...)
+	})
+}
+
+func (r *cacheRing) SetIfAbsent(ctx context.Context, in *cache_service.SetRequest, opts ...grpc.CallOption) (*cache_service.SetIfAbsentResponse, error) {
+	return ringCall(r, in.Key, func(c cache_service.CacheServiceClient) (*cache_service.SetIfAbsentResponse, error) {
+		return c.SetIfAbsent(ctx, in, opts...)
+	})
+}
+
+// Delete removes key from its owner and from the node its calls fail over
+// to, in parallel. The failover node may hold a copy written while the
+// owner was down, which it would serve again the next time the owner is;
+// the owner may hold one from before. The delete fails if either does.
+func (r *cacheRing) Delete(ctx context.Context, in *cache_service.DeleteRequest, opts ...grpc.CallOption) (*cache_service.DeleteResponse, error) {
+	owner, _ := r.pick(in.Key, nil)
+	_, failover := r.pick(in.Key, owner)
+	if failover == owner {
+		return owner.client.Delete(ctx, in, opts...)
+	}
+
+	// Neither delete cancels the other
+	var g errgroup.Group
+	var resp *cache_service.DeleteResponse
+	g.Go(func() (err error) {
+		resp, err = owner.client.Delete(ctx, in, opts...)
+		return err
+	})
+	g.Go(func() error {
+		_, err := failover.client.Delete(ctx, in, opts...)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *cacheRing) Exists(ctx context.Context, in *cache_service.ExistsRequest, opts ...grpc.CallOption) (*cache_service.ExistsResponse, error) {
+	return ringCall(r, in.Key, func(c cache_service.CacheServiceClient) (*cache_service.ExistsResponse, error) {
+		return c.Exists(ctx, in, opts...)
+	})
+}
+
+// MGet reads each node's keys in one call per node and merges the values
+// back into request order.
+func (r *cacheRing) MGet(ctx context.Context, in *cache_service.MGetRequest, opts ...grpc.CallOption) (*cache_service.MGetResponse, error) {
+	values := make([]*cache_service.GetResponse, len(in.Keys))
+	err := r.fanOut(ctx, in.Keys, func(ctx context.Context, node *cacheNode, group []int) error {
+		keys := make([]string, len(group))
+		for j, i := range group {
+			keys[j] = in.Keys[i]
+		}
+		resp, err := node.client.MGet(ctx, &cache_service.MGetRequest{Namespace: in.Namespace, Keys: keys}, opts...)
+		if err != nil {
+			return err
+		}
+		if len(resp.Values) != len(keys) {
+			return status.Errorf(codes.Internal, "%s returned %d values for %d keys", node.addr, len(resp.Values), len(keys))
+		}
+		for j, i := range group {
+			values[i] = resp.Values[j]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &cache_service.MGetResponse{Values: values}, nil
+}
+
+// MSet writes each node's entries in one call per node. It succeeds only
+// if every node stored its entries.
+func (r *cacheRing) MSet(ctx context.Context, in *cache_service.MSetRequest, opts ...grpc.CallOption) (*cache_service.MSetResponse, error) {
+	keys := make([]string, len(in.Entries))
+	for i, entry := range in.Entries {
+		keys[i] = entry.Key
+	}
+	var mu sync.Mutex
+	success := true
+	err := r.fanOut(ctx, keys, func(ctx context.Context, node *cacheNode, group []int) error {
+		entries := make([]*cache_service.SetRequest, len(group))
+		for j, i := range group {
+			entries[j] = in.Entries[i]
+		}
+		resp, err := node.client.MSet(ctx, &cache_service.MSetRequest{Entries: entries}, opts...)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		success = success && resp.Success
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &cache_service.MSetResponse{Success: success}, nil
+}
+
+// eachNode calls every node in parallel.
+func (r *cacheRing) eachNode(ctx context.Context, call func(context.Context, cache_service.CacheServiceClient) error) error {
+	g, gctx := errgroup.WithContext(ctx)
+	for _, node := range r.members() {
+		g.Go(func() error { return call(gctx, node.client) })
+	}
+	return g.Wait()
+}
+
+// GetCacheStats adds up the counters of every node.
+func (r *cacheRing) GetCacheStats(ctx context.Context, in *cache_service.GetCacheStatsRequest, opts ...grpc.CallOption) (*cache_service.GetCacheStatsResponse, error) {
+	var mu sync.Mutex
+	total := &cache_service.GetCacheStatsResponse{NamespaceEntries: make(map[string]int64)}
+	err := r.eachNode(ctx, func(ctx context.Context, c cache_service.CacheServiceClient) error {
+		resp, err := c.GetCacheStats(ctx, in, opts...)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		total.Hits += resp.Hits
+		total.Misses += resp.Misses
+		total.Sets += resp.Sets
+		total.Deletes += resp.Deletes
+		total.Evictions += resp.Evictions
+		total.Expired += resp.Expired
+		total.Swept += resp.Swept
+		total.Entries += resp.Entries
+		total.MemoryBytes += resp.MemoryBytes
+		for namespace, n := range resp.NamespaceEntries {
+			total.NamespaceEntries[namespace] += n
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reads := total.Hits + total.Misses; reads > 0 {
+		total.HitRatio = float64(total.Hits) / float64(reads)
+	}
+	return total, nil
+}
+
+func (r *cacheRing) ResetCacheStats(ctx context.Context, in *cache_service.ResetCacheStatsRequest, opts ...grpc.CallOption) (*cache_service.ResetCacheStatsResponse, error) {
+	err := r.eachNode(ctx, func(ctx context.Context, c cache_service.CacheServiceClient) error {
+		_, err := c.ResetCacheStats(ctx, in, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &cache_service.ResetCacheStatsResponse{Success: true}, nil
+}
+
+// FlushNamespace flushes the namespace on every node.
+func (r *cacheRing) FlushNamespace(ctx context.Context, in *cache_service.FlushNamespaceRequest, opts ...grpc.CallOption) (*cache_service.FlushNamespaceResponse, error) {
+	var mu sync.Mutex
+	total := &cache_service.FlushNamespaceResponse{}
+	err := r.eachNode(ctx, func(ctx context.Context, c cache_service.CacheServiceClient) error {
+		resp, err := c.FlushNamespace(ctx, in, opts...)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		total.Deleted += resp.Deleted
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return total, nil
+}
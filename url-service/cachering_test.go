@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	cache_service "github.com/syedalijabir/protos/cache-service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// newTestRing returns a ring over addrs and the metrics its failovers are
+// counted in. Nothing needs to listen on addrs until the ring is called.
+func newTestRing(t *testing.T, env map[string]string, addrs ...string) (*cacheRing, *serviceMetrics) {
+	t.Helper()
+	all := map[string]string{"CACHE_SERVICE_ADDR": strings.Join(addrs, ",")}
+	for k, v := range env {
+		all[k] = v
+	}
+	cfg, err := loadConfig(nil, testEnv(all))
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	metrics := newServiceMetrics()
+	r, err := newCacheRing(cfg, metrics.cacheFailovers, metrics.downstream)
+	if err != nil {
+		t.Fatalf("newCacheRing: %v", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r, metrics
+}
+
+// owners returns the address owning each of keys.
+func owners(r *cacheRing, keys []string) map[string]string {
+	owned := make(map[string]string, len(keys))
+	for _, key := range keys {
+		owner, _ := r.pick(key, nil)
+		owned[key] = owner.addr
+	}
+	return owned
+}
+
+// testKeys returns n short-code-like keys.
+func testKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("c%06d", i)
+	}
+	return keys
+}
+
+// serveFakeCache serves a fake cache until the test ends and returns it
+// with its address.
+func serveFakeCache(t *testing.T) (*fakeCache, string) {
+	t.Helper()
+	cache := newFakeCache()
+	addr := serveGRPC(t, func(srv *grpc.Server) {
+		cache_service.RegisterCacheServiceServer(srv, cache)
+		grpc_health_v1.RegisterHealthServer(srv, cache.health)
+	})
+	return cache, addr
+}
+
+func TestCacheRingDistribution(t *testing.T) {
+	addrs := []string{"10.0.0.1:50052", "10.0.0.2:50052", "10.0.0.3:50052", "10.0.0.4:50052", "10.0.0.5:50052"}
+	r, _ := newTestRing(t, nil, addrs...)
+	keys := testKeys(100000)
+
+	counts := map[string]int{}
+	for _, addr := range owners(r, keys) {
+		counts[addr]++
+	}
+	mean := len(keys) / len(addrs)
+	for _, addr := range addrs {
+		// With 100 virtual nodes each node owns its share give or take 15%
+		if n := counts[addr]; n < mean*85/100 || n > mean*115/100 {
+			t.Errorf("%s owns %d keys, want %d±15%%", addr, n, mean)
+		}
+	}
+
+	// The same key lands on the same node every time, and on every replica
+	other, _ := newTestRing(t, nil, addrs[4], addrs[2], addrs[0], addrs[3], addrs[1])
+	want, got := owners(r, keys[:1000]), owners(other, keys[:1000])
+	for _, key := range keys[:1000] {
+		if got[key] != want[key] {
+			t.Fatalf("%s on %s, and on %s with the addresses in another order", key, want[key], got[key])
+		}
+	}
+}
+
+func TestCacheRingRemapping(t *testing.T) {
+	addrs := []string{"10.0.0.1:50052", "10.0.0.2:50052", "10.0.0.3:50052", "10.0.0.4:50052", "10.0.0.5:50052"}
+	r, _ := newTestRing(t, nil, addrs...)
+	keys := testKeys(50000)
+	before := owners(r, keys)
+	kept := r.members()[1]
+
+	// Removing a node only moves its own keys
+	removed := addrs[2]
+	if err := r.setNodes(append(append([]string(nil), addrs[:2]...), addrs[3:]...)); err != nil {
+		t.Fatalf("setNodes: %v", err)
+	}
+	after := owners(r, keys)
+	moved := 0
+	for _, key := range keys {
+		switch {
+		case before[key] == removed:
+			moved++
+			if after[key] == removed {
+				t.Fatalf("%s still on the removed node", key)
+			}
+		case after[key] != before[key]:
+			t.Errorf("%s moved from %s to %s though its node stayed", key, before[key], after[key])
+		}
+	}
+	if share := float64(moved) / float64(len(keys)); share < 0.15 || share > 0.25 {
+		t.Errorf("%.3f of the keys moved, want about a fifth", share)
+	}
+	// Nodes that stay keep their connection
+	if r.members()[1] != kept {
+		t.Error("a node that stayed was reconnected")
+	}
+
+	// Adding it back only moves keys onto it
+	if err := r.setNodes(addrs); err != nil {
+		t.Fatalf("setNodes: %v", err)
+	}
+	for key, addr := range owners(r, keys) {
+		if addr != before[key] {
+			t.Errorf("%s on %s after the node came back, was on %s", key, addr, before[key])
+		}
+	}
+}
+
+func TestCacheRingFailover(t *testing.T) {
+	live, liveAddr := serveFakeCache(t)
+	// Nothing listens on a port that was just released
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	dead := lis.Addr().String()
+	lis.Close()
+	r, metrics := newTestRing(t, nil, liveAddr, dead)
+
+	var onDead []string
+	for key, addr := range owners(r, testKeys(50)) {
+		if addr == dead {
+			onDead = append(onDead, key)
+		}
+	}
+	if len(onDead) == 0 {
+		t.Fatal("no key on the dead node")
+	}
+	ctx := context.Background()
+	for _, key := range onDead {
+		if _, err := r.Set(ctx, &cache_service.SetRequest{Namespace: urlNamespace, Key: key, Value: "https://example.com/" + key}); err != nil {
+			t.Fatalf("Set(%s) with its node down: %v", key, err)
+		}
+		resp, err := r.Get(ctx, &cache_service.GetRequest{Namespace: urlNamespace, Key: key})
+		if err != nil || !resp.Found || resp.Value != "https://example.com/"+key {
+			t.Errorf("Get(%s) with its node down = %v, %v, want it from the next node", key, resp, err)
+		}
+		if _, ok := live.entry(urlNamespace + ":" + key); !ok {
+			t.Errorf("%s not stored on the live node", key)
+		}
+	}
+	if got := gathered(t, metrics.registry, "url_service_cache_failovers_total")["node="+dead]; got != float64(2*len(onDead)) {
+		t.Errorf("url_service_cache_failovers_total %v, want %d", got, 2*len(onDead))
+	}
+}
+
+// flakyCache is a fakeCache that can be taken down and brought back,
+// failing every Get, Set and Delete as Unavailable while it is down.
+type flakyCache struct {
+	*fakeCache
+	down atomic.Bool
+}
+
+var errCacheDown = status.Error(codes.Unavailable, "cache down")
+
+func (f *flakyCache) Get(ctx context.Context, req *cache_service.GetRequest) (*cache_service.GetResponse, error) {
+	if f.down.Load() {
+		return nil, errCacheDown
+	}
+	return f.fakeCache.Get(ctx, req)
+}
+
+func (f *flakyCache) Set(ctx context.Context, req *cache_service.SetRequest) (*cache_service.SetResponse, error) {
+	if f.down.Load() {
+		return nil, errCacheDown
+	}
+	return f.fakeCache.Set(ctx, req)
+}
+
+func (f *flakyCache) Delete(ctx context.Context, req *cache_service.DeleteRequest) (*cache_service.DeleteResponse, error) {
+	if f.down.Load() {
+		return nil, errCacheDown
+	}
+	return f.fakeCache.Delete(ctx, req)
+}
+
+func TestCacheRingDeleteReachesFailover(t *testing.T) {
+	caches := map[string]*flakyCache{}
+	var addrs []string
+	for i := 0; i < 2; i++ {
+		cache := &flakyCache{fakeCache: newFakeCache()}
+		addr := serveGRPC(t, func(srv *grpc.Server) { cache_service.RegisterCacheServiceServer(srv, cache) })
+		caches[addr] = cache
+		addrs = append(addrs, addr)
+	}
+	// The breakers stay closed, so every call tries the owner first
+	r, _ := newTestRing(t, map[string]string{"BREAKER_FAILURE_THRESHOLD": "100"}, addrs...)
+	ctx := context.Background()
+	key := testKeys(1)[0]
+	owner := caches[owners(r, []string{key})[key]]
+	var failover *flakyCache
+	for _, cache := range caches {
+		if cache != owner {
+			failover = cache
+		}
+	}
+	get := func() *cache_service.GetResponse {
+		t.Helper()
+		resp, err := r.Get(ctx, &cache_service.GetRequest{Namespace: urlNamespace, Key: key})
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		return resp
+	}
+
+	// With the owner down the link is cached on the failover node
+	owner.down.Store(true)
+	if _, err := r.Set(ctx, &cache_service.SetRequest{Namespace: urlNamespace, Key: key, Value: "https://old.example"}); err != nil {
+		t.Fatalf("Set with the owner down: %v", err)
+	}
+	if _, ok := failover.entry(urlNamespace + ":" + key); !ok {
+		t.Fatal("entry not cached on the failover node")
+	}
+
+	// The owner comes back and the link changes
+	owner.down.Store(false)
+	if _, err := r.Delete(ctx, &cache_service.DeleteRequest{Namespace: urlNamespace, Key: key}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := r.Set(ctx, &cache_service.SetRequest{Namespace: urlNamespace, Key: key, Value: "https://new.example"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if resp := get(); resp.Value != "https://new.example" {
+		t.Errorf("Get with the owner back = %v, want https://new.example", resp)
+	}
+
+	// Going down again, the owner leaves no stale copy to fail over to
+	owner.down.Store(true)
+	if resp := get(); resp.Found {
+		t.Errorf("Get with the owner down again = %v, want a miss rather than the old link", resp)
+	}
+
+	// A delete the owner misses is reported, after clearing the failover
+	if _, err := r.Set(ctx, &cache_service.SetRequest{Namespace: urlNamespace, Key: key, Value: "https://new.example"}); err != nil {
+		t.Fatalf("Set with the owner down: %v", err)
+	}
+	if _, err := r.Delete(ctx, &cache_service.DeleteRequest{Namespace: urlNamespace, Key: key}); status.Code(err) != codes.Unavailable {
+		t.Errorf("Delete with the owner down: got %v, want Unavailable", err)
+	}
+	if _, ok := failover.entry(urlNamespace + ":" + key); ok {
+		t.Error("Delete with the owner down left the failover node's entry")
+	}
+}
+
+func TestCacheRingMultiKey(t *testing.T) {
+	caches := map[string]*fakeCache{}
+	var addrs []string
+	for i := 0; i < 3; i++ {
+		cache, addr := serveFakeCache(t)
+		caches[addr] = cache
+		addrs = append(addrs, addr)
+	}
+	r, _ := newTestRing(t, nil, addrs...)
+	ctx := context.Background()
+	keys := testKeys(60)
+
+	var entries []*cache_service.SetRequest
+	for _, key := range keys {
+		entries = append(entries, &cache_service.SetRequest{Namespace: urlNamespace, Key: key, Value: "v" + key, TtlSeconds: 60})
+	}
+	if resp, err := r.MSet(ctx, &cache_service.MSetRequest{Entries: entries}); err != nil || !resp.Success {
+		t.Fatalf("MSet = %v, %v", resp, err)
+	}
+	// Each entry is stored on its owner only
+	owned := owners(r, keys)
+	for _, key := range keys {
+		for addr, cache := range caches {
+			if _, ok := cache.entry(urlNamespace + ":" + key); ok != (addr == owned[key]) {
+				t.Errorf("%s stored on %s: %v, owner %s", key, addr, ok, owned[key])
+			}
+		}
+	}
+
+	// Values come back in request order, misses included
+	want := []string{keys[59], "missing", keys[0], keys[30], keys[0]}
+	resp, err := r.MGet(ctx, &cache_service.MGetRequest{Namespace: urlNamespace, Keys: want})
+	if err != nil || len(resp.Values) != len(want) {
+		t.Fatalf("MGet = %v, %v", resp, err)
+	}
+	for i, key := range want {
+		got := resp.Values[i]
+		if key == "missing" {
+			if got.Found {
+				t.Errorf("MGet found %v for a missing key", got)
+			}
+			continue
+		}
+		if !got.Found || got.Value != "v"+key {
+			t.Errorf("MGet value %d = %v, want v%s", i, got, key)
+		}
+	}
+}
+
+func TestCacheRingCheck(t *testing.T) {
+	up, upAddr := serveFakeCache(t)
+	down, downAddr := serveFakeCache(t)
+	r, _ := newTestRing(t, nil, upAddr, downAddr)
+	ctx := context.Background()
+
+	// One node serving is enough
+	down.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	if err := r.Check(ctx); err != nil {
+		t.Errorf("Check with one node serving: %v", err)
+	}
+	up.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	if err := r.Check(ctx); err == nil {
+		t.Error("Check passed with no node serving")
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultClickFeedBuffer         = 256
+	defaultClickFeedMaxSubscribers = 100
+)
+
+// clickFeed fans the clicks this replica serves out to StreamClicks
+// subscribers. Publishing never blocks a redirect: each subscriber has a
+// bounded buffer, and once it is full the oldest click in it is dropped to
+// make room.
+type clickFeed struct {
+	buffer         int
+	maxSubscribers int
+
+	mu     sync.RWMutex
+	subs   map[*clickSubscription]struct{}
+	closed chan struct{}
+
+	dropped atomic.Int64
+}
+
+type clickSubscription struct {
+	tenant    string
+	shortCode string // empty for every code of the tenant
+	events    chan *url_service.LiveClick
+	dropped   atomic.Int64 // since the last click delivered
+}
+
+func newClickFeed(buffer, maxSubscribers int) *clickFeed {
+	return &clickFeed{
+		buffer:         buffer,
+		maxSubscribers: maxSubscribers,
+		subs:           make(map[*clickSubscription]struct{}),
+		closed:         make(chan struct{}),
+	}
+}
+
+// Subscribe registers a subscriber to the clicks on shortCode, or on every
+// code of tenant if it is empty.
+func (f *clickFeed) Subscribe(tenant, shortCode string) (*clickSubscription, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	select {
+	case <-f.closed:
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	default:
+	}
+	if len(f.subs) >= f.maxSubscribers {
+		return nil, status.Errorf(codes.ResourceExhausted, "too many click feed subscribers, at most %d", f.maxSubscribers)
+	}
+
+	sub := &clickSubscription{
+		tenant:    tenant,
+		shortCode: shortCode,
+		events:    make(chan *url_service.LiveClick, f.buffer),
+	}
+	f.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+func (f *clickFeed) Unsubscribe(sub *clickSubscription) {
+	f.mu.Lock()
+	delete(f.subs, sub)
+	f.mu.Unlock()
+}
+
+// Publish hands click to every interested subscriber without waiting on any.
+func (f *clickFeed) Publish(click *url_service.LiveClick) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for sub := range f.subs {
+		if sub.tenant != click.TenantId || sub.shortCode != "" && sub.shortCode != click.ShortCode {
+			continue
+		}
+		// Subscribers only read the clicks, so they can share one
+		for !sub.offer(click) {
+			if sub.dropOldest() {
+				f.dropped.Add(1)
+			}
+		}
+	}
+}
+
+func (sub *clickSubscription) offer(click *url_service.LiveClick) bool {
+	select {
+	case sub.events <- click:
+		return true
+	default:
+		return false
+	}
+}
+
+func (sub *clickSubscription) dropOldest() bool {
+	select {
+	case <-sub.events:
+		sub.dropped.Add(1)
+		return true
+	default:
+		// The subscriber read one in the meantime
+		return false
+	}
+}
+
+// Close ends every subscription so StreamClicks calls return before the
+// server drains, and turns away new ones.
+func (f *clickFeed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	select {
+	case <-f.closed:
+	default:
+		close(f.closed)
+	}
+}
+
+// Subscribers returns the number of open subscriptions.
+func (f *clickFeed) Subscribers() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.subs)
+}
+
+// Dropped returns how many clicks slow subscribers have missed.
+func (f *clickFeed) Dropped() int64 {
+	return f.dropped.Load()
+}
+
+// publishClick passes a counted click on to the click feed and, as
+// url.clicked, to the event broker.
+func (s *urlServer) publishClick(click *storage_service.ClickEvent, bot bool) {
+	now := time.Now().UTC()
+	tenant, shortCode := splitTenantKey(click.ShortCode)
+	s.feed.Publish(&url_service.LiveClick{
+		ShortCode: shortCode,
+		ClickedAt: now.Format("2006-01-02T15:04:05.000Z07:00"),
+		Country:   click.Country,
+		Referrer:  click.Referrer,
+		Bot:       bot,
+		Variant:   click.Variant,
+		Rule:      click.Rule,
+		TenantId:  tenant,
+	})
+
+	e := newURLEvent(eventURLClicked, click.ShortCode)
+	e.Time = now.Format(time.RFC3339Nano)
+	e.Referrer = click.Referrer
+	e.Country = click.Country
+	e.Bot = bot
+	e.Variant = click.Variant
+	e.Rule = click.Rule
+	s.events.Publish(e)
+}
+
+// StreamClicks sends the clicks this replica serves as they happen, on one
+// code or, for admins, on all of them in the caller's tenant. Only the
+// owner of a code can follow it. A subscriber that falls behind misses the oldest clicks, counted in
+// the dropped field of the next one it gets.
+func (s *urlServer) StreamClicks(req *url_service.StreamClicksRequest, stream url_service.URLService_StreamClicksServer) error {
+	ctx := stream.Context()
+	logf(ctx, "StreamClicks request for %q", req.ShortCode)
+
+	var err error
+	if req.ShortCode == "" {
+		err = s.checkAdmin(ctx)
+	} else {
+		err = s.checkOwner(ctx, req.ShortCode)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, shortCode := splitTenantKey(req.ShortCode)
+	sub, err := s.feed.Subscribe(tenantID(ctx), shortCode)
+	if err != nil {
+		return err
+	}
+	defer s.feed.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-s.feed.closed:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case click := <-sub.events:
+			if dropped := sub.dropped.Swap(0); dropped > 0 {
+				// Other subscribers share the click, so count on a copy
+				click = proto.Clone(click).(*url_service.LiveClick)
+				click.Dropped = dropped
+			}
+			if err := stream.Send(click); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// received drains the clicks waiting for sub, by referrer.
+func received(sub *clickSubscription) []string {
+	var got []string
+	for {
+		select {
+		case click := <-sub.events:
+			got = append(got, click.Referrer)
+		default:
+			return got
+		}
+	}
+}
+
+func TestClickFeedFanOut(t *testing.T) {
+	feed := newClickFeed(8, 10)
+	subscribe := func(tenant, shortCode string) *clickSubscription {
+		sub, err := feed.Subscribe(tenant, shortCode)
+		if err != nil {
+			t.Fatalf("subscribe %q/%q: %v", tenant, shortCode, err)
+		}
+		return sub
+	}
+	all, abc, again, other := subscribe("", ""), subscribe("", "abc"), subscribe("", "abc"), subscribe("acme", "")
+
+	feed.Publish(&url_service.LiveClick{ShortCode: "abc", Referrer: "1"})
+	feed.Publish(&url_service.LiveClick{ShortCode: "xyz", Referrer: "2"})
+	feed.Publish(&url_service.LiveClick{ShortCode: "abc", Referrer: "3", TenantId: "acme"})
+
+	tests := []struct {
+		name string
+		sub  *clickSubscription
+		want string
+	}{
+		{"every code", all, "[1 2]"},
+		{"one code", abc, "[1]"},
+		{"same code again", again, "[1]"},
+		{"other tenant", other, "[3]"},
+	}
+	for _, tt := range tests {
+		if got := received(tt.sub); fmt.Sprint(got) != tt.want {
+			t.Errorf("%s: got clicks %v, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// Unsubscribed, it gets nothing more
+	feed.Unsubscribe(abc)
+	feed.Publish(&url_service.LiveClick{ShortCode: "abc", Referrer: "4"})
+	if got := received(abc); len(got) != 0 {
+		t.Errorf("unsubscribed subscriber got %v", got)
+	}
+	if got := received(again); fmt.Sprint(got) != "[4]" {
+		t.Errorf("remaining subscriber got %v, want [4]", got)
+	}
+}
+
+func TestClickFeedDropsOldest(t *testing.T) {
+	feed := newClickFeed(2, 10)
+	slow, _ := feed.Subscribe("", "")
+	fast, _ := feed.Subscribe("", "")
+
+	// Nothing reads slow, yet publishing goes on
+	for i := 1; i <= 5; i++ {
+		feed.Publish(&url_service.LiveClick{ShortCode: "abc", Referrer: strconv.Itoa(i)})
+		if got := received(fast); fmt.Sprint(got) != "["+strconv.Itoa(i)+"]" {
+			t.Fatalf("fast subscriber got %v for click %d", got, i)
+		}
+	}
+	if got := received(slow); fmt.Sprint(got) != "[4 5]" {
+		t.Errorf("slow subscriber got %v, want the newest [4 5]", got)
+	}
+	if n := slow.dropped.Load(); n != 3 {
+		t.Errorf("slow subscriber dropped %d, want 3", n)
+	}
+	if n := feed.Dropped(); n != 3 {
+		t.Errorf("feed dropped %d, want 3", n)
+	}
+}
+
+func TestClickFeedLimits(t *testing.T) {
+	feed := newClickFeed(1, 2)
+	first, _ := feed.Subscribe("", "")
+	if _, err := feed.Subscribe("", "abc"); err != nil {
+		t.Fatalf("second subscriber: %v", err)
+	}
+	if _, err := feed.Subscribe("", ""); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("subscriber past the limit: got %v, want ResourceExhausted", err)
+	}
+	feed.Unsubscribe(first)
+	if _, err := feed.Subscribe("", ""); err != nil {
+		t.Errorf("subscriber after one left: %v", err)
+	}
+
+	feed.Close()
+	feed.Close()
+	feed.Unsubscribe(first)
+	if _, err := feed.Subscribe("", ""); status.Code(err) != codes.Unavailable {
+		t.Errorf("subscriber after close: got %v, want Unavailable", err)
+	}
+}
+
+// blockingClickStream is a StreamClicks stream whose sends wait for the
+// test to take them. Each send is announced on sending first.
+type blockingClickStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	sending chan struct{}
+	sent    chan *url_service.LiveClick
+}
+
+func (s *blockingClickStream) Context() context.Context { return s.ctx }
+
+func (s *blockingClickStream) Send(click *url_service.LiveClick) error {
+	s.sending <- struct{}{}
+	select {
+	case s.sent <- click:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+func TestStreamClicksSlowConsumer(t *testing.T) {
+	s, _, _ := newTestServer(t, map[string]string{"CLICK_FEED_BUFFER": "2", "ADMIN_USERS": "root"})
+	ctx, cancel := context.WithCancel(withKey(context.Background(), "root-key", "root"))
+	stream := &blockingClickStream{ctx: ctx, sending: make(chan struct{}, 8), sent: make(chan *url_service.LiveClick)}
+	done := make(chan error, 1)
+	go func() { done <- s.StreamClicks(&url_service.StreamClicksRequest{}, stream) }()
+	waitFor(t, "subscription", func() bool { return s.feed.Subscribers() == 1 })
+
+	// The first click is stuck in Send while four more come in
+	s.feed.Publish(&url_service.LiveClick{ShortCode: "abc", Referrer: "0"})
+	<-stream.sending
+	for i := 1; i <= 4; i++ {
+		s.feed.Publish(&url_service.LiveClick{ShortCode: "abc", Referrer: strconv.Itoa(i), Variant: "b"})
+	}
+
+	want := []struct {
+		referrer string
+		dropped  int64
+	}{{"0", 0}, {"3", 2}, {"4", 0}}
+	for _, w := range want {
+		click := <-stream.sent
+		if click.Referrer != w.referrer || click.Dropped != w.dropped {
+			t.Errorf("sent click %s with %d dropped, want %s with %d", click.Referrer, click.Dropped, w.referrer, w.dropped)
+		}
+		if w.dropped > 0 && click.Variant != "b" {
+			t.Errorf("click with a dropped count lost its variant: %+v", click)
+		}
+	}
+
+	cancel()
+	if err := <-done; status.Code(err) != codes.Canceled {
+		t.Errorf("StreamClicks after cancel: got %v, want Canceled", err)
+	}
+	if n := s.feed.Subscribers(); n != 0 {
+		t.Errorf("%d subscribers left after cancel", n)
+	}
+}
+
+func TestStreamClicks(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"ADMIN_USERS": "root"})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "abc", OriginalUrl: "https://alice.example", UserId: "alice"})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "xyz", OriginalUrl: "https://bob.example", UserId: "bob"})
+	keys, err := loadAPIKeys("alice:a-key,bob:b-key,root:r-key", "", "")
+	if err != nil {
+		t.Fatalf("loadAPIKeys: %v", err)
+	}
+	conn := dialBufconn(t, func(srv *grpc.Server) { url_service.RegisterURLServiceServer(srv, s) },
+		grpc.StreamInterceptor(authStreamInterceptor(keys, false)))
+	client := url_service.NewURLServiceClient(conn)
+	open := func(key, shortCode string) url_service.URLService_StreamClicksClient {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), apiKeyHeader, key)
+		stream, err := client.StreamClicks(ctx, &url_service.StreamClicksRequest{ShortCode: shortCode})
+		if err != nil {
+			t.Fatalf("StreamClicks: %v", err)
+		}
+		return stream
+	}
+
+	// Only owners follow a code, and only admins every code
+	for _, tt := range []struct {
+		name, key, code string
+	}{{"not the owner", "b-key", "abc"}, {"not an admin", "a-key", ""}} {
+		if _, err := open(tt.key, tt.code).Recv(); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("%s: got %v, want PermissionDenied", tt.name, err)
+		}
+	}
+
+	alice, root := open("a-key", "abc"), open("r-key", "")
+	waitFor(t, "subscriptions", func() bool { return s.feed.Subscribers() == 2 })
+	for _, code := range []string{"abc", "xyz"} {
+		if _, err := s.GetOriginalURL(context.Background(), &url_service.GetOriginalRequest{ShortCode: code, Country: "DE", Referrer: "https://news.example/" + code}); err != nil {
+			t.Fatalf("GetOriginalURL(%s): %v", code, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		stream url_service.URLService_StreamClicksClient
+		want   []string
+	}{
+		{"owner", alice, []string{"abc"}},
+		{"admin", root, []string{"abc", "xyz"}},
+	}
+	for _, tt := range tests {
+		for _, code := range tt.want {
+			click, err := tt.stream.Recv()
+			if err != nil {
+				t.Fatalf("%s: Recv: %v", tt.name, err)
+			}
+			if click.ShortCode != code || click.Country != "DE" || click.Referrer != "https://news.example/"+code || click.ClickedAt == "" {
+				t.Errorf("%s: got %+v, want a click on %s", tt.name, click, code)
+			}
+		}
+	}
+
+	// Shutdown ends the streams rather than waiting on them
+	s.feed.Close()
+	for _, tt := range tests {
+		if _, err := tt.stream.Recv(); status.Code(err) != codes.Unavailable {
+			t.Errorf("%s: Recv after shutdown: got %v, want Unavailable", tt.name, err)
+		}
+	}
+	if _, err := open("r-key", "").Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("subscribing after shutdown: got %v, want Unavailable", err)
+	}
+}
+
+// waitFor waits until done reports true, failing after 5s.
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("no %s after 5s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
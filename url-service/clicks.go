@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	cache_service "github.com/syedalijabir/protos/cache-service"
+	storage_service "github.com/syedalijabir/protos/storage-service"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultClickFlushInterval  = 5 * time.Second
+	defaultClickFlushThreshold = 100
+
+	// maxPendingClickEvents bounds the click events held while storage is
+	// unavailable. Counts are kept regardless, so only the details of
+	// clicks past the limit are lost.
+	maxPendingClickEvents = 100000
+	clickEventChunkSize   = 1000
+)
+
+// clickTallies are the counts kept beside a URL's click count: clicks from
+// new visitors among them, and clicks by bots, which aren't among them. They
+// also carry when a person last clicked it, flushed with them so tracking it
+// costs no write of its own.
+type clickTallies struct {
+	unique, bot  int64
+	lastAccessed time.Time // zero if unknown
+}
+
+// merge adds o's counts to t and keeps the later access.
+func (t clickTallies) merge(o clickTallies) clickTallies {
+	t.unique += o.unique
+	t.bot += o.bot
+	if o.lastAccessed.After(t.lastAccessed) {
+		t.lastAccessed = o.lastAccessed
+	}
+	return t
+}
+
+// clickBatcher accumulates click deltas in memory and writes them to storage
+// in batches, either on a timer or once a single code gets hot enough.
+// Pending deltas are keyed by short code and live independently of the URL
+// LRU, so evicting a URL never drops its unflushed clicks.
+type clickBatcher struct {
+	mu        sync.Mutex
+	pending   map[string]int64
+	tallies   map[string]clickTallies
+	events    []*storage_service.ClickEvent
+	dropped   int   // Events dropped since the last flush
+	unflushed int64 // Clicks in pending
+	threshold int64
+	interval  time.Duration
+	flushNow  chan struct{}
+	done      chan struct{}
+
+	storageClient storage_service.StorageServiceClient
+	cacheClient   cache_service.CacheServiceClient
+	batchSizes    prometheus.Observer
+}
+
+func newClickBatcher(storageClient storage_service.StorageServiceClient, cacheClient cache_service.CacheServiceClient, interval time.Duration, threshold int64, batchSizes prometheus.Observer) *clickBatcher {
+	return &clickBatcher{
+		pending:       make(map[string]int64),
+		tallies:       make(map[string]clickTallies),
+		threshold:     threshold,
+		interval:      interval,
+		flushNow:      make(chan struct{}, 1),
+		done:          make(chan struct{}),
+		storageClient: storageClient,
+		cacheClient:   cacheClient,
+		batchSizes:    batchSizes,
+	}
+}
+
+// stamp dates click with the current time unless it already has one, and
+// returns its time.
+func stamp(click *storage_service.ClickEvent) time.Time {
+	if click.ClickedAt == "" {
+		now := time.Now().UTC()
+		click.ClickedAt = now.Format(time.RFC3339Nano)
+		return now
+	}
+	clickedAt, err := time.Parse(time.RFC3339Nano, click.ClickedAt)
+	if err != nil {
+		return time.Now().UTC()
+	}
+	return clickedAt
+}
+
+// Add records a single click, stamping it with the current time unless it
+// already has one.
+func (b *clickBatcher) Add(click *storage_service.ClickEvent) {
+	clickedAt := stamp(click)
+
+	b.mu.Lock()
+	b.pending[click.ShortCode]++
+	b.unflushed++
+	hot := b.pending[click.ShortCode] >= b.threshold
+	b.accessed(click.ShortCode, clickedAt)
+	b.appendEvents([]*storage_service.ClickEvent{click})
+	b.mu.Unlock()
+
+	if hot {
+		select {
+		case b.flushNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// AddEvent records the details of a click counted elsewhere, such as one
+// claimed from a limited link's storage counter.
+func (b *clickBatcher) AddEvent(click *storage_service.ClickEvent) {
+	clickedAt := stamp(click)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.accessed(click.ShortCode, clickedAt)
+	b.appendEvents([]*storage_service.ClickEvent{click})
+}
+
+// accessed notes a click by a person on shortCode at clickedAt. Caller must
+// hold b.mu.
+func (b *clickBatcher) accessed(shortCode string, clickedAt time.Time) {
+	b.tallies[shortCode] = b.tallies[shortCode].merge(clickTallies{lastAccessed: clickedAt})
+}
+
+// AddUnique counts a click already added as one from a new visitor.
+func (b *clickBatcher) AddUnique(shortCode string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.tallies[shortCode]
+	t.unique++
+	b.tallies[shortCode] = t
+}
+
+// AddBot records a click by a bot or prefetch. It is kept as an event but
+// counted apart from the clicks of people, and never triggers a flush.
+func (b *clickBatcher) AddBot(click *storage_service.ClickEvent) {
+	stamp(click)
+	click.Bot = true
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.tallies[click.ShortCode]
+	t.bot++
+	b.tallies[click.ShortCode] = t
+	b.appendEvents([]*storage_service.ClickEvent{click})
+}
+
+// Pending returns the number of clicks for shortCode not yet written to storage.
+func (b *clickBatcher) Pending(shortCode string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.pending[shortCode]
+}
+
+// Unflushed returns the number of clicks not yet written to storage, those
+// being flushed aside.
+func (b *clickBatcher) Unflushed() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.unflushed
+}
+
+// PendingTallies returns the unique and bot clicks for shortCode not yet
+// written to storage, and the latest click among them.
+func (b *clickBatcher) PendingTallies(shortCode string) clickTallies {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tallies[shortCode]
+}
+
+// Run flushes pending clicks until ctx is cancelled, then performs a final
+// synchronous flush before returning.
+func (b *clickBatcher) Run(ctx context.Context) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			b.Flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			b.Flush(ctx)
+		case <-b.flushNow:
+			b.Flush(ctx)
+		}
+	}
+}
+
+// Wait blocks until Run has performed its final flush.
+func (b *clickBatcher) Wait() {
+	<-b.done
+}
+
+// Flush writes all pending deltas and click events to storage. On failure
+// they are merged back so the next flush retries them.
+func (b *clickBatcher) Flush(ctx context.Context) {
+	b.mu.Lock()
+	batch := b.pending
+	b.pending = make(map[string]int64)
+	b.unflushed = 0
+	tallies := b.tallies
+	b.tallies = make(map[string]clickTallies)
+	events := b.events
+	b.events = nil
+	dropped := b.dropped
+	b.dropped = 0
+	b.mu.Unlock()
+
+	if dropped > 0 {
+		log.Printf("Warning: dropped %d click events while storage was behind", dropped)
+	}
+	b.flushEvents(ctx, events)
+	// A unique click can be counted after its click was flushed, and bot
+	// clicks and those counted by storage have no delta of their own
+	for shortCode := range tallies {
+		if _, ok := batch[shortCode]; !ok {
+			batch[shortCode] = 0
+		}
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	b.batchSizes.Observe(float64(len(batch)))
+
+	deltas := make([]*storage_service.ClickDelta, 0, len(batch))
+	for shortCode, delta := range batch {
+		t := tallies[shortCode]
+		deltas = append(deltas, &storage_service.ClickDelta{
+			ShortCode:      shortCode,
+			Delta:          delta,
+			UniqueDelta:    t.unique,
+			BotDelta:       t.bot,
+			LastAccessedAt: formatOptionalTime(t.lastAccessed),
+		})
+	}
+
+	storageCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	resp, err := b.storageClient.BatchIncrementClicks(storageCtx, &storage_service.BatchIncrementClicksRequest{Deltas: deltas})
+	cancel()
+	if err != nil {
+		log.Printf("Failed to flush clicks for %d codes, will retry: %v", len(batch), err)
+		b.requeue(batch, tallies)
+		return
+	}
+
+	if len(resp.FailedShortCodes) > 0 {
+		log.Printf("Failed to flush clicks for %d codes, will retry", len(resp.FailedShortCodes))
+		failed := make(map[string]int64, len(resp.FailedShortCodes))
+		failedTallies := make(map[string]clickTallies, len(resp.FailedShortCodes))
+		for _, shortCode := range resp.FailedShortCodes {
+			failed[shortCode] = batch[shortCode]
+			failedTallies[shortCode] = tallies[shortCode]
+			delete(batch, shortCode)
+		}
+		b.requeue(failed, failedTallies)
+	}
+	for _, shortCode := range resp.MissingShortCodes {
+		log.Printf("Warning: dropping %d clicks for unknown short code %s", batch[shortCode], shortCode)
+		delete(batch, shortCode)
+	}
+	// Storage holds the clicks of codes it hasn't seen saved yet, as when
+	// the save is still queued here, and adds them once it is
+	if len(resp.BufferedShortCodes) > 0 {
+		log.Printf("Flushed clicks for %d codes, %d of them held by storage until saved", resp.Updated+int64(len(resp.BufferedShortCodes)), len(resp.BufferedShortCodes))
+	} else {
+		log.Printf("Flushed clicks for %d codes", resp.Updated)
+	}
+
+	// Drop cached counts so the next stats lookup reads the new totals
+	for shortCode := range batch {
+		namespaces := []string{countNamespace}
+		if tallies[shortCode] != (clickTallies{}) {
+			namespaces = append(namespaces, talliesNamespace)
+		}
+		for _, namespace := range namespaces {
+			cacheCtx, cancel := context.WithTimeout(ctx, time.Second)
+			if _, err := b.cacheClient.Delete(cacheCtx, &cache_service.DeleteRequest{Namespace: namespace, Key: shortCode}); err != nil {
+				log.Printf("Warning: failed to invalidate cached count for %s: %v", shortCode, err)
+			}
+			cancel()
+		}
+	}
+}
+
+// flushEvents records events in chunks, requeueing the chunks that fail.
+func (b *clickBatcher) flushEvents(ctx context.Context, events []*storage_service.ClickEvent) {
+	for start := 0; start < len(events); start += clickEventChunkSize {
+		chunk := events[start:min(start+clickEventChunkSize, len(events))]
+
+		storageCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		_, err := b.storageClient.RecordClick(storageCtx, &storage_service.RecordClickRequest{Events: chunk})
+		cancel()
+		if err != nil {
+			log.Printf("Failed to record %d click events, will retry: %v", len(events)-start, err)
+			b.mu.Lock()
+			b.appendEvents(events[start:])
+			b.mu.Unlock()
+			return
+		}
+	}
+}
+
+// appendEvents queues events, dropping those past maxPendingClickEvents.
+// Caller must hold b.mu.
+func (b *clickBatcher) appendEvents(events []*storage_service.ClickEvent) {
+	room := max(maxPendingClickEvents-len(b.events), 0)
+	if len(events) > room {
+		b.dropped += len(events) - room
+		events = events[:room]
+	}
+	b.events = append(b.events, events...)
+}
+
+func (b *clickBatcher) requeue(batch map[string]int64, tallies map[string]clickTallies) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for shortCode, delta := range batch {
+		if delta != 0 {
+			b.pending[shortCode] += delta
+			b.unflushed += delta
+		}
+	}
+	for shortCode, t := range tallies {
+		if t != (clickTallies{}) {
+			b.tallies[shortCode] = b.tallies[shortCode].merge(t)
+		}
+	}
+}
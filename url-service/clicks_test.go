@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestClickBatcher returns a click batcher writing to the fake storage
+// of s.
+func newTestClickBatcher(s *urlServer, interval time.Duration, threshold int64) *clickBatcher {
+	return newClickBatcher(s.storageClient, s.cacheClient, interval, threshold, prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_click_flush_size"}))
+}
+
+func TestClickBatcherConcurrentAdds(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	b := newTestClickBatcher(s, 5*time.Millisecond, 50)
+	ctx, cancel := context.WithCancel(context.Background())
+	go b.Run(ctx)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 50; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				b.Add(&storage_service.ClickEvent{ShortCode: "code" + strconv.Itoa(i%10)})
+			}
+		}()
+	}
+	wg.Wait()
+	// Whatever the ticker and threshold left is flushed on the way out
+	cancel()
+	b.Wait()
+
+	for i := 0; i < 10; i++ {
+		if n := storage.clicks("code" + strconv.Itoa(i)); n != 1000 {
+			t.Errorf("code%d has %d clicks in storage, want 1000", i, n)
+		}
+	}
+	if b.Unflushed() != 0 {
+		t.Errorf("%d clicks left unflushed", b.Unflushed())
+	}
+}
+
+func TestClickBatcherRetriesFailedFlush(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	b := newTestClickBatcher(s, time.Hour, 1000)
+	ctx := context.Background()
+	storage.incrementErrs = []error{status.Error(codes.Unavailable, "down"), status.Error(codes.Unavailable, "down")}
+	for i := 0; i < 5; i++ {
+		b.Add(&storage_service.ClickEvent{ShortCode: "flaky"})
+	}
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		b.Flush(ctx)
+		if n := b.Pending("flaky"); n != 5 {
+			t.Fatalf("after failed flush %d: %d clicks pending, want 5", attempt, n)
+		}
+	}
+	// Clicks made meanwhile join the retried ones
+	b.Add(&storage_service.ClickEvent{ShortCode: "flaky"})
+	b.Flush(ctx)
+	if n := storage.clicks("flaky"); n != 6 || b.Pending("flaky") != 0 {
+		t.Errorf("storage has %d clicks with %d pending, want 6 and 0", n, b.Pending("flaky"))
+	}
+
+	// Codes storage reports failed are retried on their own
+	storage.mu.Lock()
+	storage.failCodes = map[string]bool{"bad": true}
+	storage.mu.Unlock()
+	b.Add(&storage_service.ClickEvent{ShortCode: "bad"})
+	b.Add(&storage_service.ClickEvent{ShortCode: "good"})
+	b.Flush(ctx)
+	if storage.clicks("good") != 1 || b.Pending("good") != 0 || b.Pending("bad") != 1 {
+		t.Errorf("after a partial failure: good has %d in storage and %d pending, bad %d pending", storage.clicks("good"), b.Pending("good"), b.Pending("bad"))
+	}
+	storage.mu.Lock()
+	storage.failCodes = nil
+	storage.mu.Unlock()
+	b.Flush(ctx)
+	if storage.clicks("bad") != 1 || storage.clicks("good") != 1 {
+		t.Errorf("after the retry: bad has %d clicks and good %d, want 1 each", storage.clicks("bad"), storage.clicks("good"))
+	}
+}
+
+func TestClickBatcherFlushesHotCode(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	b := newTestClickBatcher(s, time.Hour, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx)
+
+	for i := 0; i < 3; i++ {
+		b.Add(&storage_service.ClickEvent{ShortCode: "hot"})
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for storage.clicks("hot") != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("hot code has %d clicks in storage 5s after reaching the threshold, want 3", storage.clicks("hot"))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 100 * time.Millisecond
+	defaultRetryMaxBackoff     = time.Second
+
+	clientKeepaliveTime    = 30 * time.Second
+	clientKeepaliveTimeout = 10 * time.Second
+)
+
+// idempotentMethods lists the downstream RPCs that are safe to retry.
+// Click increments are deliberately absent: a retried increment after a lost
+// response would double count. A retried AllocateIDRange or PopKeys at worst
+// leaves IDs or keys unused.
+var idempotentMethods = map[string][]string{
+	"cache.CacheService":     {"Get", "Set", "Delete", "MGet", "MSet", "Exists"},
+	"storage.StorageService": {"SaveURL", "GetURL", "GetStats", "DeleteURL", "FindByOriginalURL", "GetCleanupStats", "ListURLs", "CountURLs", "GetURLs", "GetTopURLs", "GetClickTimeSeries", "GetClickBreakdown", "GetGlobalStats", "AllocateIDRange", "PopKeys", "SetURLStatus", "GetURLHistory", "ListBlockedDomains", "PurgeURL", "ListTags", "DeleteUserData", "ListStaleURLs", "UpdateMetadata"},
+}
+
+// retryPolicy configures transparent retries of idempotent RPCs.
+type retryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// serviceConfig renders the gRPC default service config for policy, with
+// the lbPolicy load balancing policy.
+func (p retryPolicy) serviceConfig(lbPolicy string) (string, error) {
+	type name struct {
+		Service string `json:"service"`
+		Method  string `json:"method"`
+	}
+	type retry struct {
+		MaxAttempts          int      `json:"maxAttempts"`
+		InitialBackoff       string   `json:"initialBackoff"`
+		MaxBackoff           string   `json:"maxBackoff"`
+		BackoffMultiplier    float64  `json:"backoffMultiplier"`
+		RetryableStatusCodes []string `json:"retryableStatusCodes"`
+	}
+	type methodConfig struct {
+		Name        []name `json:"name"`
+		RetryPolicy retry  `json:"retryPolicy"`
+	}
+
+	var names []name
+	for service, methods := range idempotentMethods {
+		for _, method := range methods {
+			names = append(names, name{Service: service, Method: method})
+		}
+	}
+
+	config := struct {
+		LoadBalancingConfig []map[string]struct{} `json:"loadBalancingConfig"`
+		MethodConfig        []methodConfig        `json:"methodConfig"`
+	}{
+		LoadBalancingConfig: []map[string]struct{}{{lbPolicy: {}}},
+		MethodConfig: []methodConfig{{
+			Name: names,
+			RetryPolicy: retry{
+				MaxAttempts:          p.MaxAttempts,
+				InitialBackoff:       fmt.Sprintf("%.3fs", p.InitialBackoff.Seconds()),
+				MaxBackoff:           fmt.Sprintf("%.3fs", p.MaxBackoff.Seconds()),
+				BackoffMultiplier:    2,
+				RetryableStatusCodes: []string{"UNAVAILABLE"},
+			},
+		}},
+	}
+
+	b, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// newClientConn creates a lazily connecting client for a downstream service
+// that retries idempotent RPCs, keeps idle connections alive and reconnects
+// with backoff after the downstream restarts. Calls go through breaker, are
+// spread over the addresses addr resolves to as lb says, and are reported to
+// metrics as service.
+func newClientConn(addr string, cfg Config, breaker *circuitBreaker, lb balancing, service string, metrics *downstreamMetrics) (*grpc.ClientConn, error) {
+	policy := retryPolicy{
+		MaxAttempts:    cfg.RetryMaxAttempts,
+		InitialBackoff: cfg.RetryInitialBackoff,
+		MaxBackoff:     cfg.RetryMaxBackoff,
+	}
+	serviceConfig, err := policy.serviceConfig(lb.policy)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(serviceConfig),
+		grpc.WithDefaultCallOptions(grpc.WaitForReady(lb.waitForReady)),
+		grpc.WithStatsHandler(clientTracing()),
+		grpc.WithStatsHandler(metrics.statsHandler(service)),
+		grpc.WithUnaryInterceptor(breaker.UnaryClientInterceptor()),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoff.DefaultConfig,
+			MinConnectTimeout: cfg.DialTimeout,
+		}),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                clientKeepaliveTime,
+			Timeout:             clientKeepaliveTimeout,
+			PermitWithoutStream: true,
+		}),
+	}
+	// Targets without a scheme, such as host:port, use the DNS resolver
+	if lb.resolveInterval > 0 {
+		opts = append(opts, grpc.WithResolvers(newRefreshingResolver(lb.resolveInterval)))
+	}
+	return grpc.NewClient(addr, opts...)
+}
+
+// watchConnState logs when the connection to a downstream becomes
+// unreachable and when it recovers, until ctx is cancelled.
+func watchConnState(ctx context.Context, name string, conn *grpc.ClientConn) {
+	conn.Connect()
+
+	reachable := true
+	state := conn.GetState()
+	for conn.WaitForStateChange(ctx, state) {
+		state = conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			if !reachable {
+				log.Printf("%s reachable again", name)
+			}
+			reachable = true
+		case connectivity.TransientFailure:
+			if reachable {
+				log.Printf("Warning: %s unreachable, reconnecting", name)
+			}
+			reachable = false
+		case connectivity.Idle:
+			// Reconnect eagerly so the next RPC doesn't pay for the dial
+			conn.Connect()
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// serveStorageOn serves storage on addr until stopped or the test ends.
+func serveStorageOn(t *testing.T, addr string, storage *fakeStorage) *grpc.Server {
+	t.Helper()
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("listen on %s: %v", addr, err)
+	}
+	srv := grpc.NewServer()
+	storage_service.RegisterStorageServiceServer(srv, storage)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return srv
+}
+
+func TestStorageRestartRecovers(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	storage := newFakeStorage()
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "abc123", OriginalUrl: "https://example.com"})
+	first := serveStorageOn(t, addr, storage)
+	s, _, _ := newTestServer(t, map[string]string{"STORAGE_SERVICE_ADDR": addr, "BREAKER_FAILURE_THRESHOLD": "1000"})
+	ctx := context.Background()
+	lookup := func() error {
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		_, err := s.storageClient.GetURL(ctx, &storage_service.GetURLRequest{ShortCode: "abc123"})
+		return err
+	}
+	if err := lookup(); err != nil {
+		t.Fatalf("GetURL before the restart: %v", err)
+	}
+
+	first.Stop()
+	if err := lookup(); err == nil {
+		t.Fatal("GetURL with storage down succeeded")
+	}
+
+	// The same client reconnects once storage is back
+	serveStorageOn(t, addr, storage)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		err := lookup()
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GetURL 10s after the restart: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestRetriesIdempotentCalls(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"GRPC_RETRY_INITIAL_BACKOFF": "1ms", "GRPC_RETRY_MAX_BACKOFF": "10ms"})
+	unavailable := status.Error(codes.Unavailable, "storage restarting")
+	storage.mu.Lock()
+	storage.saveErrs = []error{unavailable, unavailable}
+	storage.incrementErrs = []error{unavailable}
+	storage.mu.Unlock()
+	ctx := context.Background()
+
+	// Within the three attempts a save is retried through two failures
+	if _, err := s.storageClient.SaveURL(ctx, &storage_service.SaveURLRequest{ShortCode: "abc123", OriginalUrl: "https://example.com"}); err != nil {
+		t.Errorf("SaveURL: %v", err)
+	}
+	if _, ok := storage.url("abc123"); !ok {
+		t.Error("abc123 not saved")
+	}
+
+	// A retried increment could count twice, so its failure is returned
+	_, err := s.storageClient.BatchIncrementClicks(ctx, &storage_service.BatchIncrementClicksRequest{Deltas: []*storage_service.ClickDelta{{ShortCode: "abc123", Delta: 1}}})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("BatchIncrementClicks: got %v, want Unavailable", err)
+	}
+}
+
+func TestRetryPolicyServiceConfig(t *testing.T) {
+	policy := retryPolicy{MaxAttempts: 4, InitialBackoff: 250 * time.Millisecond, MaxBackoff: 2 * time.Second}
+	raw, err := policy.serviceConfig("round_robin")
+	if err != nil {
+		t.Fatalf("serviceConfig: %v", err)
+	}
+
+	var config struct {
+		LoadBalancingConfig []map[string]any `json:"loadBalancingConfig"`
+		MethodConfig        []struct {
+			Name        []struct{ Service, Method string } `json:"name"`
+			RetryPolicy struct {
+				MaxAttempts          int      `json:"maxAttempts"`
+				InitialBackoff       string   `json:"initialBackoff"`
+				MaxBackoff           string   `json:"maxBackoff"`
+				RetryableStatusCodes []string `json:"retryableStatusCodes"`
+			} `json:"retryPolicy"`
+		} `json:"methodConfig"`
+	}
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		t.Fatalf("unmarshal %s: %v", raw, err)
+	}
+	if len(config.LoadBalancingConfig) != 1 || config.LoadBalancingConfig[0]["round_robin"] == nil {
+		t.Errorf("loadBalancingConfig = %v, want round_robin", config.LoadBalancingConfig)
+	}
+	if len(config.MethodConfig) != 1 {
+		t.Fatalf("%d method configs, want 1", len(config.MethodConfig))
+	}
+	retry := config.MethodConfig[0].RetryPolicy
+	if retry.MaxAttempts != 4 || retry.InitialBackoff != "0.250s" || retry.MaxBackoff != "2.000s" || len(retry.RetryableStatusCodes) != 1 || retry.RetryableStatusCodes[0] != "UNAVAILABLE" {
+		t.Errorf("retryPolicy = %+v", retry)
+	}
+
+	methods := make(map[string]bool)
+	for _, name := range config.MethodConfig[0].Name {
+		methods[name.Service+"/"+name.Method] = true
+	}
+	for method, want := range map[string]bool{
+		"storage.StorageService/GetURL":               true,
+		"cache.CacheService/Get":                      true,
+		"storage.StorageService/BatchIncrementClicks": false,
+		"storage.StorageService/ClaimClick":           false,
+	} {
+		if methods[method] != want {
+			t.Errorf("%s retried: %v, want %v", method, methods[method], want)
+		}
+	}
+}
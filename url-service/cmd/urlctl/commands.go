@@ -0,0 +1,357 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultImportBatchSize stays well under url-service's MAX_BATCH_SIZE.
+const defaultImportBatchSize = 100
+
+func (c *cli) shorten(args []string) error {
+	fs := c.flags("shorten")
+	alias := fs.String("alias", "", "custom alias instead of a generated code")
+	ttl := fs.Duration("ttl", 0, "lifetime of the link, 0 for none")
+	maxClicks := fs.Int64("max-clicks", 0, "clicks after which the link stops working, 0 for no limit")
+	tags := fs.String("tags", "", "comma separated tags to organize the link by")
+	if err := c.parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	ctx, cancel := c.ctx()
+	defer cancel()
+	resp, err := c.client.ShortenURL(ctx, &url_service.ShortenRequest{
+		OriginalUrl: fs.Arg(0),
+		CustomAlias: *alias,
+		TtlSeconds:  int64(*ttl / time.Second),
+		MaxClicks:   *maxClicks,
+		Tags:        splitTags(*tags),
+	})
+	if err != nil {
+		return err
+	}
+	return c.print(resp, func(w io.Writer) {
+		fmt.Fprintf(w, "short_code\t%s\n", resp.ShortCode)
+		fmt.Fprintf(w, "short_url\t%s\n", orDash(resp.ShortUrl))
+		fmt.Fprintf(w, "original_url\t%s\n", resp.OriginalUrl)
+		fmt.Fprintf(w, "expires_at\t%s\n", orDash(resp.ExpiresAt))
+		fmt.Fprintf(w, "tags\t%s\n", orDash(strings.Join(resp.Tags, ",")))
+	})
+}
+
+// resolve looks a code up without counting a click, unless asked to.
+func (c *cli) resolve(args []string) error {
+	fs := c.flags("resolve")
+	count := fs.Bool("count", false, "count the lookup as a click")
+	if err := c.parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	ctx, cancel := c.ctx()
+	defer cancel()
+	code := fs.Arg(0)
+	resp, err := c.client.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: code, SkipStats: !*count})
+	if err != nil {
+		return err
+	}
+	if err := c.print(resp, func(w io.Writer) {
+		if resp.Found {
+			fmt.Fprintln(w, resp.OriginalUrl)
+		}
+	}); err != nil {
+		return err
+	}
+
+	switch {
+	case resp.Disabled:
+		return notFound("%s is disabled", code)
+	case resp.Exhausted:
+		return notFound("%s has used up its clicks", code)
+	case resp.Expired:
+		return notFound("%s has expired", code)
+	case !resp.Found:
+		return notFound("%s is not active yet", code)
+	}
+	return nil
+}
+
+func (c *cli) stats(args []string) error {
+	fs := c.flags("stats")
+	breakdowns := fs.Bool("breakdowns", false, "include top referrers, countries, browsers and devices")
+	if err := c.parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	ctx, cancel := c.ctx()
+	defer cancel()
+	resp, err := c.client.GetURLStats(ctx, &url_service.StatsRequest{ShortCode: fs.Arg(0), IncludeBreakdowns: *breakdowns})
+	if err != nil {
+		return err
+	}
+	return c.print(resp, func(w io.Writer) {
+		fmt.Fprintf(w, "short_code\t%s\n", resp.ShortCode)
+		fmt.Fprintf(w, "click_count\t%d\n", resp.ClickCount)
+		fmt.Fprintf(w, "unique_clicks\t%d\n", resp.UniqueClicks)
+		fmt.Fprintf(w, "bot_clicks\t%d\n", resp.BotClicks)
+		fmt.Fprintf(w, "created_at\t%s\n", orDash(resp.CreatedAt))
+		fmt.Fprintf(w, "expires_at\t%s\n", orDash(resp.ExpiresAt))
+		fmt.Fprintf(w, "expired\t%t\n", resp.Expired)
+		for _, b := range []struct {
+			name    string
+			entries []*url_service.BreakdownEntry
+		}{
+			{"top_referrers", resp.TopReferrers},
+			{"countries", resp.Countries},
+			{"browsers", resp.Browsers},
+			{"devices", resp.Devices},
+		} {
+			for _, e := range b.entries {
+				fmt.Fprintf(w, "%s\t%s\t%d\n", b.name, e.Value, e.Clicks)
+			}
+		}
+	})
+}
+
+func (c *cli) delete(args []string) error {
+	fs := c.flags("delete")
+	if err := c.parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	ctx, cancel := c.ctx()
+	defer cancel()
+	resp, err := c.client.DeleteURL(ctx, &url_service.DeleteURLRequest{ShortCode: fs.Arg(0)})
+	if err != nil {
+		return err
+	}
+	return c.print(resp, func(w io.Writer) {
+		fmt.Fprintf(w, "deleted\t%s\n", fs.Arg(0))
+	})
+}
+
+// list prints a page of the caller's links, or with -all every page.
+func (c *cli) list(args []string) error {
+	fs := c.flags("list")
+	user := fs.String("user", "", "user whose links to list, defaults to the API key's")
+	tags := fs.String("tags", "", "comma separated tags the links must all have")
+	pageSize := fs.Int("page-size", 0, "links per page, at most 100")
+	pageToken := fs.String("page-token", "", "next_page_token of the previous page")
+	all := fs.Bool("all", false, "follow next_page_token to the last page")
+	if err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	out := &url_service.ListURLsResponse{}
+	token := *pageToken
+	for {
+		ctx, cancel := c.ctx()
+		resp, err := c.client.ListURLs(ctx, &url_service.ListURLsRequest{
+			UserId:    *user,
+			PageSize:  int32(*pageSize),
+			PageToken: token,
+			Tags:      splitTags(*tags),
+		})
+		cancel()
+		if err != nil {
+			return err
+		}
+		out.Urls = append(out.Urls, resp.Urls...)
+		out.NextPageToken = resp.NextPageToken
+		if !*all || resp.NextPageToken == "" {
+			break
+		}
+		token = resp.NextPageToken
+	}
+
+	if err := c.print(out, func(w io.Writer) {
+		fmt.Fprintln(w, "CODE\tCLICKS\tCREATED\tEXPIRES\tDISABLED\tTAGS\tURL")
+		for _, u := range out.Urls {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%t\t%s\t%s\n", u.ShortCode, u.ClickCount, orDash(u.CreatedAt), orDash(u.ExpiresAt), u.Disabled, orDash(strings.Join(u.Tags, ",")), u.OriginalUrl)
+		}
+	}); err != nil {
+		return err
+	}
+	if out.NextPageToken != "" && c.format == outputTable {
+		fmt.Fprintf(c.stderr, "More links: urlctl list -page-token %s\n", out.NextPageToken)
+	}
+	return nil
+}
+
+// tags prints the tags of the caller's links with how many links have each.
+func (c *cli) tags(args []string) error {
+	fs := c.flags("tags")
+	user := fs.String("user", "", "user whose tags to list, defaults to the API key's")
+	if err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	ctx, cancel := c.ctx()
+	defer cancel()
+	resp, err := c.client.ListTags(ctx, &url_service.ListTagsRequest{UserId: *user})
+	if err != nil {
+		return err
+	}
+	return c.print(resp, func(w io.Writer) {
+		fmt.Fprintln(w, "TAG\tLINKS")
+		for _, t := range resp.Tags {
+			fmt.Fprintf(w, "%s\t%d\n", t.Tag, t.Urls)
+		}
+	})
+}
+
+// splitTags splits a comma separated -tags flag. url-service normalizes
+// the tags.
+func splitTags(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+// importFailure is a CSV row that couldn't be shortened.
+type importFailure struct {
+	Line        int    `json:"line"`
+	OriginalURL string `json:"original_url"`
+	Code        string `json:"code"`
+	Error       string `json:"error"`
+}
+
+type importSummary struct {
+	Total    int             `json:"total"`
+	Created  int             `json:"created"`
+	Failed   int             `json:"failed"`
+	Failures []importFailure `json:"failures,omitempty"`
+}
+
+type importRow struct {
+	line int
+	req  *url_service.ShortenRequest
+}
+
+// importCSV shortens every row of a CSV file with BatchShorten. Rows are
+// original_url, then optionally custom_alias and ttl_seconds; a first row
+// starting with "original_url" is taken as a header. A failed batch fails
+// its rows and the import carries on with the next.
+func (c *cli) importCSV(args []string) error {
+	fs := c.flags("import")
+	batchSize := fs.Int("batch-size", defaultImportBatchSize, "URLs per BatchShorten call")
+	if err := c.parse(fs, args, 1); err != nil {
+		return err
+	}
+	if *batchSize <= 0 {
+		fmt.Fprintf(c.stderr, "urlctl: -batch-size must be positive\n")
+		return errUsage
+	}
+
+	rows, err := c.readImport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	summary := importSummary{Total: len(rows)}
+	for start := 0; start < len(rows); start += *batchSize {
+		batch := rows[start:min(start+*batchSize, len(rows))]
+		items := make([]*url_service.ShortenRequest, len(batch))
+		for i, row := range batch {
+			items[i] = row.req
+		}
+
+		ctx, cancel := c.ctx()
+		resp, err := c.client.BatchShorten(ctx, &url_service.BatchShortenRequest{Items: items})
+		cancel()
+		for i, row := range batch {
+			code, msg := codes.OK, ""
+			switch {
+			case err != nil:
+				s := status.Convert(err)
+				code, msg = s.Code(), s.Message()
+			case i >= len(resp.Results):
+				code, msg = codes.Internal, "no result returned"
+			case resp.Results[i].Code != 0:
+				code, msg = codes.Code(resp.Results[i].Code), resp.Results[i].Error
+			}
+			if code == codes.OK {
+				summary.Created++
+				continue
+			}
+			summary.Failed++
+			summary.Failures = append(summary.Failures, importFailure{
+				Line:        row.line,
+				OriginalURL: row.req.OriginalUrl,
+				Code:        code.String(),
+				Error:       msg,
+			})
+		}
+	}
+
+	if err := c.printValue(summary, func(w io.Writer) {
+		for _, f := range summary.Failures {
+			fmt.Fprintf(w, "line %d\t%s\t%s\t%s\n", f.Line, f.OriginalURL, f.Code, f.Error)
+		}
+		fmt.Fprintf(w, "Imported %d of %d URLs, %d failed\n", summary.Created, summary.Total, summary.Failed)
+	}); err != nil {
+		return err
+	}
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d of %d URLs failed to import", summary.Failed, summary.Total)
+	}
+	return nil
+}
+
+// readImport reads the rows of the CSV file at path, or stdin for "-".
+func (c *cli) readImport(path string) ([]importRow, error) {
+	in := c.stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	var rows []importRow
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		if len(rows) == 0 && line == 1 && strings.EqualFold(record[0], "original_url") {
+			continue
+		}
+		if len(record) > 3 {
+			return nil, fmt.Errorf("line %d: want original_url[,custom_alias[,ttl_seconds]], got %d fields", line, len(record))
+		}
+
+		req := &url_service.ShortenRequest{OriginalUrl: record[0]}
+		if len(record) > 1 {
+			req.CustomAlias = record[1]
+		}
+		if len(record) > 2 && record[2] != "" {
+			ttl, err := strconv.ParseInt(record[2], 10, 64)
+			if err != nil || ttl < 0 {
+				return nil, fmt.Errorf("line %d: invalid ttl_seconds %q", line, record[2])
+			}
+			req.TtlSeconds = ttl
+		}
+		rows = append(rows, importRow{line: line, req: req})
+	}
+	return rows, nil
+}
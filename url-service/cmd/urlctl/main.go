@@ -0,0 +1,214 @@
+// Command urlctl shortens, resolves and inspects links from the terminal by
+// calling url-service over gRPC.
+//
+//	urlctl [-addr host:port] [-api-key key] [-output table|json] <command> [flags] [args]
+//
+// The address and key default to URLCTL_ADDR and URLCTL_API_KEY. urlctl
+// exits with 0 on success, 1 on errors, 2 on bad usage and 3 when a link
+// isn't found, has expired or is disabled.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultAddr    = "localhost:50051"
+	defaultTimeout = 10 * time.Second
+
+	// apiKeyHeader matches url-service's
+	apiKeyHeader = "x-api-key"
+)
+
+// Exit codes.
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitUsage    = 2
+	exitNotFound = 3
+)
+
+// dialOptions are the options urlctl connects to url-service with.
+var dialOptions = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+
+// errUsage marks mistakes in the command line, which have been reported by
+// the time it is returned.
+var errUsage = errors.New("usage")
+
+var commands = map[string]func(c *cli, args []string) error{
+	"shorten": (*cli).shorten,
+	"resolve": (*cli).resolve,
+	"stats":   (*cli).stats,
+	"delete":  (*cli).delete,
+	"list":    (*cli).list,
+	"tags":    (*cli).tags,
+	"import":  (*cli).importCSV,
+}
+
+// usages are kept apart from commands, whose flag sets print them.
+var usages = map[string]string{
+	"shorten": "shorten [-alias a] [-ttl d] [-max-clicks n] [-tags t1,t2] URL",
+	"resolve": "resolve [-count] CODE",
+	"stats":   "stats [-breakdowns] CODE",
+	"delete":  "delete CODE",
+	"list":    "list [-user u] [-tags t1,t2] [-page-size n] [-page-token t] [-all]",
+	"tags":    "tags [-user u]",
+	"import":  "import [-batch-size n] FILE.csv, or - for stdin",
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, os.Getenv))
+}
+
+// run parses the global flags, dials url-service and runs the command,
+// returning the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer, getenv func(string) string) int {
+	fs := flag.NewFlagSet("urlctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { printUsage(fs) }
+	addr := fs.String("addr", envOr(getenv("URLCTL_ADDR"), defaultAddr), "url-service host:port (URLCTL_ADDR)")
+	apiKey := fs.String("api-key", getenv("URLCTL_API_KEY"), "API key for authenticated commands (URLCTL_API_KEY)")
+	output := fs.String("output", outputTable, "output format, table or json")
+	timeout := fs.Duration("timeout", defaultTimeout, "timeout of each call")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if *output != outputTable && *output != outputJSON {
+		fmt.Fprintf(stderr, "urlctl: invalid -output %q, want %s or %s\n", *output, outputTable, outputJSON)
+		return exitUsage
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "urlctl: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return exitUsage
+	}
+
+	conn, err := grpc.NewClient(*addr, dialOptions...)
+	if err != nil {
+		fmt.Fprintf(stderr, "urlctl: invalid address %s: %v\n", *addr, err)
+		return exitUsage
+	}
+	defer conn.Close()
+
+	c := &cli{
+		client:  url_service.NewURLServiceClient(conn),
+		apiKey:  *apiKey,
+		timeout: *timeout,
+		format:  *output,
+		stdin:   stdin,
+		stdout:  stdout,
+		stderr:  stderr,
+	}
+	return c.exitCode(cmd(c, fs.Args()[1:]))
+}
+
+func printUsage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintf(w, "Usage: urlctl [flags] <command> [command flags] [args]\n\nCommands:\n")
+	names := make([]string, 0, len(usages))
+	for name := range usages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n", usages[name])
+	}
+	fmt.Fprintf(w, "\nFlags:\n")
+	fs.PrintDefaults()
+}
+
+func envOr(value, defaultValue string) string {
+	if value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// cli runs commands against one url-service connection.
+type cli struct {
+	client  url_service.URLServiceClient
+	apiKey  string
+	timeout time.Duration
+	format  string
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+// ctx returns the context for one call, carrying the API key if there is
+// one.
+func (c *cli) ctx() (context.Context, context.CancelFunc) {
+	ctx := context.Background()
+	if c.apiKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, apiKeyHeader, c.apiKey)
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// flags returns the flag set of a command, which reports its own errors.
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: urlctl %s\n", usages[name])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses a command's flags and checks it got want arguments.
+func (c *cli) parse(fs *flag.FlagSet, args []string, want int) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != want {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// exitCode reports err, if any, and maps it to an exit code.
+func (c *cli) exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errUsage):
+		return exitUsage
+	}
+
+	if s, ok := status.FromError(err); ok && s.Code() != codes.Unknown {
+		fmt.Fprintf(c.stderr, "urlctl: %s: %s\n", s.Code(), s.Message())
+		if s.Code() == codes.NotFound {
+			return exitNotFound
+		}
+		return exitError
+	}
+	fmt.Fprintf(c.stderr, "urlctl: %v\n", err)
+	return exitError
+}
+
+// notFound returns a NotFound error for links that exist but don't resolve.
+func notFound(format string, args ...interface{}) error {
+	return status.Errorf(codes.NotFound, format, args...)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeURLService answers urlctl's calls from memory. Codes starting with
+// "taken" can't be created.
+type fakeURLService struct {
+	url_service.UnimplementedURLServiceServer
+
+	mu      sync.Mutex
+	links   map[string]*url_service.GetOriginalResponse
+	keys    []string
+	batches [][]*url_service.ShortenRequest
+	// pages are the pages ListURLs hands out, token "1" being the second
+	pages []*url_service.ListURLsResponse
+	// err fails DeleteURL when set
+	err error
+}
+
+func (f *fakeURLService) record(ctx context.Context) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys = append(f.keys, strings.Join(md.Get(apiKeyHeader), ","))
+}
+
+func (f *fakeURLService) ShortenURL(ctx context.Context, req *url_service.ShortenRequest) (*url_service.ShortenResponse, error) {
+	f.record(ctx)
+	if strings.HasPrefix(req.CustomAlias, "taken") {
+		return nil, status.Error(codes.AlreadyExists, "custom alias already in use")
+	}
+	return &url_service.ShortenResponse{ShortCode: req.CustomAlias, ShortUrl: "https://sho.rt/" + req.CustomAlias, OriginalUrl: req.OriginalUrl, Tags: req.Tags}, nil
+}
+
+func (f *fakeURLService) GetOriginalURL(ctx context.Context, req *url_service.GetOriginalRequest) (*url_service.GetOriginalResponse, error) {
+	f.record(ctx)
+	if resp, ok := f.links[req.ShortCode]; ok {
+		return resp, nil
+	}
+	return nil, status.Error(codes.NotFound, "URL not found")
+}
+
+func (f *fakeURLService) GetURLStats(ctx context.Context, req *url_service.StatsRequest) (*url_service.StatsResponse, error) {
+	f.record(ctx)
+	return &url_service.StatsResponse{ShortCode: req.ShortCode, ClickCount: 42, UniqueClicks: 7}, nil
+}
+
+func (f *fakeURLService) DeleteURL(ctx context.Context, req *url_service.DeleteURLRequest) (*url_service.DeleteURLResponse, error) {
+	f.record(ctx)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &url_service.DeleteURLResponse{Success: true}, nil
+}
+
+func (f *fakeURLService) ListURLs(ctx context.Context, req *url_service.ListURLsRequest) (*url_service.ListURLsResponse, error) {
+	f.record(ctx)
+	page := 0
+	if req.PageToken != "" {
+		page = 1
+	}
+	return f.pages[page], nil
+}
+
+func (f *fakeURLService) BatchShorten(ctx context.Context, req *url_service.BatchShortenRequest) (*url_service.BatchShortenResponse, error) {
+	f.record(ctx)
+	f.mu.Lock()
+	f.batches = append(f.batches, req.Items)
+	f.mu.Unlock()
+	resp := &url_service.BatchShortenResponse{}
+	for _, item := range req.Items {
+		shortened, err := f.ShortenURL(ctx, item)
+		resp.Results = append(resp.Results, &url_service.BatchShortenResult{
+			Url:   shortened,
+			Code:  int32(status.Code(err)),
+			Error: status.Convert(err).Message(),
+		})
+	}
+	return resp, nil
+}
+
+// runCLI runs urlctl with args against f over an in-process connection and
+// returns its exit code and output.
+func runCLI(t *testing.T, f *fakeURLService, env map[string]string, stdin string, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	url_service.RegisterURLServiceServer(srv, f)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	saved := dialOptions
+	dialOptions = []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+	defer func() { dialOptions = saved }()
+
+	var out, errOut bytes.Buffer
+	getenv := func(key string) string { return env[key] }
+	code = run(append([]string{"-addr", "passthrough:///bufconn"}, args...), strings.NewReader(stdin), &out, &errOut, getenv)
+	return code, out.String(), errOut.String()
+}
+
+func TestShortenOutputs(t *testing.T) {
+	f := &fakeURLService{}
+	code, out, errOut := runCLI(t, f, nil, "", "shorten", "-alias", "docs", "-tags", "a,b", "https://example.com/docs")
+	if code != exitOK {
+		t.Fatalf("shorten exited %d: %s", code, errOut)
+	}
+	for _, want := range []string{"short_code    docs", "short_url     https://sho.rt/docs", "expires_at    -", "tags          a,b"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("table output %q lacks %q", out, want)
+		}
+	}
+
+	code, out, errOut = runCLI(t, f, nil, "", "-output", "json", "shorten", "-alias", "docs", "https://example.com/docs")
+	var resp map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &resp); code != exitOK || err != nil || resp["short_code"] != "docs" || resp["original_url"] != "https://example.com/docs" {
+		t.Errorf("json output %q, exit %d: %s", out, code, errOut)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"none", nil, nil, ""},
+		{"env", map[string]string{"URLCTL_API_KEY": "from-env"}, nil, "from-env"},
+		{"flag over env", map[string]string{"URLCTL_API_KEY": "from-env"}, []string{"-api-key", "from-flag"}, "from-flag"},
+	}
+	for _, tt := range tests {
+		f := &fakeURLService{}
+		args := append(tt.args, "stats", "abc")
+		if code, _, errOut := runCLI(t, f, tt.env, "", args...); code != exitOK {
+			t.Fatalf("%s: stats exited %d: %s", tt.name, code, errOut)
+		}
+		if len(f.keys) != 1 || f.keys[0] != tt.want {
+			t.Errorf("%s: sent keys %q, want %q", tt.name, f.keys, tt.want)
+		}
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	f := &fakeURLService{links: map[string]*url_service.GetOriginalResponse{
+		"live":     {OriginalUrl: "https://example.com", Found: true},
+		"disabled": {Disabled: true},
+		"expired":  {Expired: true},
+	}}
+	tests := []struct {
+		name string
+		args []string
+		err  error
+		want int
+	}{
+		{"resolve", []string{"resolve", "live"}, nil, exitOK},
+		{"resolve unknown", []string{"resolve", "ghost"}, nil, exitNotFound},
+		{"resolve disabled", []string{"resolve", "disabled"}, nil, exitNotFound},
+		{"resolve expired", []string{"resolve", "expired"}, nil, exitNotFound},
+		{"delete", []string{"delete", "live"}, nil, exitOK},
+		{"delete unknown", []string{"delete", "ghost"}, status.Error(codes.NotFound, "URL not found"), exitNotFound},
+		{"delete unavailable", []string{"delete", "live"}, status.Error(codes.Unavailable, "storage down"), exitError},
+		{"delete denied", []string{"delete", "live"}, status.Error(codes.PermissionDenied, "not yours"), exitError},
+		{"shorten taken alias", []string{"shorten", "-alias", "taken", "https://example.com"}, nil, exitError},
+		{"no command", nil, nil, exitUsage},
+		{"unknown command", []string{"explode"}, nil, exitUsage},
+		{"missing argument", []string{"resolve"}, nil, exitUsage},
+		{"extra argument", []string{"stats", "a", "b"}, nil, exitUsage},
+		{"unknown flag", []string{"resolve", "-loud", "live"}, nil, exitUsage},
+		{"invalid output", []string{"-output", "xml", "resolve", "live"}, nil, exitUsage},
+	}
+	for _, tt := range tests {
+		f.err = tt.err
+		code, _, errOut := runCLI(t, f, nil, "", tt.args...)
+		if code != tt.want {
+			t.Errorf("%s: exited %d, want %d: %s", tt.name, code, tt.want, errOut)
+		}
+		if code != exitOK && errOut == "" {
+			t.Errorf("%s: exited %d without saying why", tt.name, code)
+		}
+	}
+}
+
+func TestListAllPages(t *testing.T) {
+	f := &fakeURLService{pages: []*url_service.ListURLsResponse{
+		{Urls: []*url_service.URLSummary{{ShortCode: "one", OriginalUrl: "https://example.com/1", ClickCount: 3}}, NextPageToken: "1"},
+		{Urls: []*url_service.URLSummary{{ShortCode: "two", OriginalUrl: "https://example.com/2", Disabled: true}}},
+	}}
+
+	code, out, errOut := runCLI(t, f, nil, "", "list")
+	if code != exitOK || !strings.Contains(out, "one") || strings.Contains(out, "two") || !strings.Contains(errOut, "-page-token 1") {
+		t.Errorf("list exited %d with %q and %q, want the first page and a hint", code, out, errOut)
+	}
+	code, out, errOut = runCLI(t, f, nil, "", "list", "-all")
+	if code != exitOK || !strings.Contains(out, "one") || !strings.Contains(out, "two") || errOut != "" {
+		t.Errorf("list -all exited %d with %q and %q, want both pages", code, out, errOut)
+	}
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[0], "CODE") {
+		t.Errorf("list -all printed %q, want a header and two rows", out)
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	csv := "original_url,custom_alias,ttl_seconds\n" +
+		"https://example.com/1,one,\n" +
+		"https://example.com/2,taken2,60\n" +
+		"https://example.com/3\n" +
+		"https://example.com/4,four,3600\n" +
+		"https://example.com/5,taken5\n"
+	path := filepath.Join(t.TempDir(), "links.csv")
+	if err := os.WriteFile(path, []byte(csv), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeURLService{}
+	code, out, _ := runCLI(t, f, nil, "", "-output", "json", "import", "-batch-size", "2", path)
+	if code != exitError {
+		t.Errorf("import with failures exited %d, want %d", code, exitError)
+	}
+	var summary importSummary
+	if err := json.Unmarshal([]byte(out), &summary); err != nil {
+		t.Fatalf("summary %q: %v", out, err)
+	}
+	if summary.Total != 5 || summary.Created != 3 || summary.Failed != 2 {
+		t.Errorf("summary %+v, want 3 of 5 created", summary)
+	}
+	if len(summary.Failures) != 2 || summary.Failures[0].Line != 3 || summary.Failures[0].Code != "AlreadyExists" || summary.Failures[1].Line != 6 {
+		t.Errorf("failures %+v, want lines 3 and 6 with AlreadyExists", summary.Failures)
+	}
+	// Batched, the header left out and TTLs parsed
+	if len(f.batches) != 3 || len(f.batches[0]) != 2 || len(f.batches[2]) != 1 {
+		t.Fatalf("batches %v, want 2, 2 and 1 URLs", f.batches)
+	}
+	if first := f.batches[0][0]; first.OriginalUrl != "https://example.com/1" || first.CustomAlias != "one" || first.TtlSeconds != 0 {
+		t.Errorf("first row sent as %v", first)
+	}
+	if f.batches[1][1].TtlSeconds != 3600 {
+		t.Errorf("fourth row sent with TTL %d, want 3600", f.batches[1][1].TtlSeconds)
+	}
+
+	// From stdin, all succeeding, with the table summary
+	code, out, errOut := runCLI(t, &fakeURLService{}, nil, "https://example.com/a,a\nhttps://example.com/b,b\n", "import", "-")
+	if code != exitOK || !strings.Contains(out, "Imported 2 of 2 URLs, 0 failed") {
+		t.Errorf("import from stdin exited %d with %q: %s", code, out, errOut)
+	}
+
+	code, _, errOut = runCLI(t, &fakeURLService{}, nil, "https://example.com/a,a,soon\n", "import", "-")
+	if code != exitError || !strings.Contains(errOut, `line 1: invalid ttl_seconds "soon"`) {
+		t.Errorf("import of a bad TTL exited %d: %s", code, errOut)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Output formats.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+var jsonOptions = protojson.MarshalOptions{Multiline: true, Indent: "  ", UseProtoNames: true}
+
+// print writes msg as JSON, or as the table that table writes.
+func (c *cli) print(msg proto.Message, table func(w io.Writer)) error {
+	if c.format == outputJSON {
+		data, err := jsonOptions.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(c.stdout, "%s\n", data)
+		return err
+	}
+	return c.table(table)
+}
+
+// printValue is print for results that aren't messages.
+func (c *cli) printValue(v interface{}, table func(w io.Writer)) error {
+	if c.format == outputJSON {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	return c.table(table)
+}
+
+// table lines up the tab separated columns table writes.
+func (c *cli) table(table func(w io.Writer)) error {
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+// orDash stands in for empty table cells.
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
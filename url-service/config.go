@@ -0,0 +1,599 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultGRPCPort    = "50051"
+	defaultDialTimeout = 5 * time.Second
+	defaultCacheTTL    = 60 * time.Second
+)
+
+// Config holds everything url-service reads at startup. Values come from the
+// environment, and the command-line flags registered in loadConfig override
+// the environment.
+type Config struct {
+	GRPCPort           string
+	HTTPPort           string
+	GRPCWebPort        string   // serves gRPC-Web to browsers when set
+	GRPCWebOrigins     []string // origins allowed to call gRPC-Web cross-origin
+	CacheServiceAddr   string   // comma-separated, keys are spread over the nodes
+	StorageServiceAddr string
+	DialTimeout        time.Duration
+	CacheReadTimeout   time.Duration
+	CacheTimeout       time.Duration
+	StorageTimeout     time.Duration
+	CacheTTL           time.Duration
+	NegativeCacheTTL   time.Duration
+	RequestTimeout     time.Duration
+
+	CacheVirtualNodes    int
+	CacheResolveInterval time.Duration // 0 uses the cache addresses as configured
+
+	StorageLBPolicy        string        // round_robin or pick_first over the addresses of STORAGE_SERVICE_ADDR
+	StorageResolveInterval time.Duration // 0 resolves again only when a connection drops
+	StorageWaitForReady    bool
+
+	LookupCacheBudget    time.Duration
+	LookupHedgeDelay     time.Duration // 0 waits for the cache even when memory could answer
+	LookupStorageReserve time.Duration
+
+	RetryMaxAttempts    int
+	RetryInitialBackoff time.Duration
+	RetryMaxBackoff     time.Duration
+
+	BreakerFailureThreshold int
+	BreakerOpenTimeout      time.Duration
+
+	MaxURLLength       int
+	ShortenerDomains   []string
+	URLCacheEntries    int
+	MemoryStaleAfter   time.Duration // 0 keeps memory entries fresh
+	MemoryMaxStaleness time.Duration // 0 never serves stale entries
+	DedupURLs          bool
+	NormalizeURLs      bool
+	SyncPersist        bool
+	CodeStrategy       string
+	IDBlockSize        int
+
+	ShortCodeLength   int
+	ShortCodeAlphabet string
+
+	ReservedAliases     string
+	ReservedAliasesFile string
+
+	APIKeys        string
+	APIKeysFile    string
+	RevokedAPIKeys string
+
+	ShortenRateLimit  float64
+	ShortenRateBurst  int
+	BatchRateLimit    float64
+	BatchRateBurst    int
+	LookupRateLimit   float64
+	LookupRateBurst   int
+	RateLimitIdleTTL  time.Duration
+	TrustForwardedFor bool
+
+	GeoIPDBPath   string
+	BotUserAgents []string
+
+	DomainPolicy       string
+	DomainRulesRefresh time.Duration
+
+	ReputationProvider   string
+	SafeBrowsingAPIKey   string
+	SafeBrowsingURL      string
+	ReputationTimeout    time.Duration
+	ReputationFailClosed bool
+	ReputationCacheTTL   time.Duration
+	ReputationRescan     time.Duration
+
+	FetchMetadata    bool // fetch new links' page titles and Open Graph tags, see metadata.go
+	MetadataTimeout  time.Duration
+	MetadataMaxBytes int
+
+	SelfLinkPolicy   string   // links to our own short links, see selflinks.go
+	KnownShorteners  []string // added to defaultShorteners
+	LinkChainMaxHops int      // 0 doesn't follow other shorteners
+	LinkChainTimeout time.Duration
+
+	MaxURLsPerUser int
+	MaxBatchSize   int
+
+	AdminUsers             []string
+	ReportDisableThreshold int
+
+	BaseURL            string
+	DefaultFallbackURL string // where links that stopped resolving go without a fallback_url of their own
+	Tenants            string // comma separated id or id=base_url, besides the default tenant
+
+	ClickFlushInterval  time.Duration
+	ClickFlushThreshold int64
+	UniqueClickWindow   time.Duration
+
+	ClickFeedBuffer         int
+	ClickFeedMaxSubscribers int
+
+	EventsBroker        string
+	NATSURL             string
+	EventsSubjectPrefix string
+	KafkaBrokers        string
+	EventsTopic         string
+	EventsQueueSize     int
+	EventsBatchSize     int
+	EventsFlushInterval time.Duration
+	EventsTimeout       time.Duration
+
+	WarmupURLs    int
+	WarmupOrder   string
+	WarmupTimeout time.Duration
+
+	AsyncWorkers     int
+	AsyncQueueSize   int
+	AsyncQueuePolicy string
+
+	AdmissionQueueHigh  int // 0 leaves the async queue out of admission control
+	AdmissionQueueLow   int
+	AdmissionClicksHigh int64 // 0 leaves unflushed clicks out of admission control
+	AdmissionClicksLow  int64
+
+	PersistMaxAttempts int
+	PersistBaseDelay   time.Duration
+	PersistWALDir      string
+
+	ReadinessInterval  time.Duration
+	UnhealthyThreshold time.Duration
+	ShutdownTimeout    time.Duration
+
+	DebugEndpoints bool   // pprof and /debug/vars, see debug.go
+	DebugAddr      string // serves them on their own listener instead of HTTP_PORT
+	HeapProfileDir string // where WriteHeapProfile writes
+
+	ConfigFile          string        // JSON settings over the environment, see reload.go
+	ConfigWatchInterval time.Duration // 0 only reloads on SIGHUP
+	LogLevel            string
+
+	// InsecureDevMode lets calls without an API key through and turns on
+	// reflection by default, so grpcurl can be used against a local stack.
+	InsecureDevMode  bool
+	EnableReflection bool
+}
+
+// loadConfig builds the config from defaults, then the environment (read
+// through getenv), then CONFIG_FILE, then args. It fails on the first
+// invalid value.
+func loadConfig(args []string, getenv func(string) string) (Config, error) {
+	configFile := getenv("CONFIG_FILE")
+	if configFile != "" {
+		values, err := readConfigFile(configFile)
+		if err != nil {
+			return Config{}, err
+		}
+		environ := getenv
+		getenv = func(key string) string {
+			if value, ok := values[key]; ok {
+				return value
+			}
+			return environ(key)
+		}
+	}
+	env := envReader{getenv: getenv}
+
+	// CACHE_SERVICE_HOST and STORAGE_SERVICE_HOST predate the *_ADDR
+	// variables and are still honoured with the default ports.
+	cacheHost := env.str("CACHE_SERVICE_HOST", "cache-service")
+	storageHost := env.str("STORAGE_SERVICE_HOST", "storage-service")
+	devMode := env.bool("INSECURE_DEV_MODE", false)
+
+	cfg := Config{
+		GRPCPort:           env.str("GRPC_PORT", defaultGRPCPort),
+		HTTPPort:           env.str("HTTP_PORT", defaultHTTPPort),
+		GRPCWebPort:        env.str("GRPC_WEB_PORT", ""),
+		GRPCWebOrigins:     strings.Split(env.str("GRPC_WEB_ALLOWED_ORIGINS", ""), ","),
+		CacheServiceAddr:   env.str("CACHE_SERVICE_ADDR", net.JoinHostPort(cacheHost, "50052")),
+		StorageServiceAddr: env.str("STORAGE_SERVICE_ADDR", net.JoinHostPort(storageHost, "50053")),
+		DialTimeout:        env.duration("DIAL_TIMEOUT", defaultDialTimeout),
+		CacheReadTimeout:   env.duration("CACHE_READ_TIMEOUT", defaultCacheReadTimeout),
+		CacheTimeout:       env.duration("CACHE_TIMEOUT", defaultCacheTimeout),
+		StorageTimeout:     env.duration("STORAGE_TIMEOUT", defaultStorageTimeout),
+		CacheTTL:           env.duration("CACHE_TTL", defaultCacheTTL),
+		NegativeCacheTTL:   env.duration("NEGATIVE_CACHE_TTL", defaultNegativeCacheTTL),
+		RequestTimeout:     env.duration("DEFAULT_REQUEST_TIMEOUT", defaultRequestTimeout),
+
+		CacheVirtualNodes:    env.int("CACHE_VIRTUAL_NODES", defaultCacheVirtualNodes),
+		CacheResolveInterval: env.duration("CACHE_RESOLVE_INTERVAL", 0),
+
+		StorageLBPolicy:        env.str("STORAGE_LB_POLICY", lbRoundRobin),
+		StorageResolveInterval: env.duration("STORAGE_RESOLVE_INTERVAL", defaultStorageResolveInterval),
+		StorageWaitForReady:    env.bool("STORAGE_WAIT_FOR_READY", true),
+
+		LookupCacheBudget:    env.duration("LOOKUP_CACHE_BUDGET", defaultLookupCacheBudget),
+		LookupHedgeDelay:     env.duration("LOOKUP_HEDGE_DELAY", defaultLookupHedgeDelay),
+		LookupStorageReserve: env.duration("LOOKUP_STORAGE_RESERVE", defaultLookupStorageReserve),
+
+		RetryMaxAttempts:    env.int("GRPC_RETRY_MAX_ATTEMPTS", defaultRetryMaxAttempts),
+		RetryInitialBackoff: env.duration("GRPC_RETRY_INITIAL_BACKOFF", defaultRetryInitialBackoff),
+		RetryMaxBackoff:     env.duration("GRPC_RETRY_MAX_BACKOFF", defaultRetryMaxBackoff),
+
+		BreakerFailureThreshold: env.int("BREAKER_FAILURE_THRESHOLD", defaultBreakerFailureThreshold),
+		BreakerOpenTimeout:      env.duration("BREAKER_OPEN_TIMEOUT", defaultBreakerOpenTimeout),
+
+		MaxURLLength:       env.int("MAX_URL_LENGTH", defaultMaxURLLength),
+		ShortenerDomains:   strings.Split(env.str("SHORTENER_DOMAINS", ""), ","),
+		URLCacheEntries:    env.int("URL_CACHE_MAX_ENTRIES", defaultURLCacheMaxEntries),
+		MemoryStaleAfter:   env.duration("MEMORY_STALE_AFTER", defaultMemoryStaleAfter),
+		MemoryMaxStaleness: env.duration("MEMORY_MAX_STALENESS", defaultMemoryMaxStaleness),
+		DedupURLs:          env.bool("DEDUPLICATE_URLS", false),
+		NormalizeURLs:      env.bool("NORMALIZE_URLS", false),
+		SyncPersist:        env.bool("URL_SYNC_PERSIST", false),
+		CodeStrategy:       env.str("CODE_STRATEGY", codeStrategyRandom),
+		IDBlockSize:        env.int("ID_BLOCK_SIZE", defaultIDBlockSize),
+
+		ShortCodeLength:   env.int("SHORT_CODE_LENGTH", defaultShortCodeLength),
+		ShortCodeAlphabet: env.str("SHORT_CODE_ALPHABET", "default"),
+
+		ReservedAliases:     env.str("RESERVED_ALIASES", ""),
+		ReservedAliasesFile: env.str("RESERVED_ALIASES_FILE", ""),
+
+		APIKeys:        env.str("API_KEYS", ""),
+		APIKeysFile:    env.str("API_KEYS_FILE", ""),
+		RevokedAPIKeys: env.str("REVOKED_API_KEYS", ""),
+
+		ShortenRateLimit:  env.float("SHORTEN_RATE_LIMIT", defaultShortenRateLimit),
+		ShortenRateBurst:  env.int("SHORTEN_RATE_BURST", defaultShortenRateBurst),
+		BatchRateLimit:    env.float("BATCH_RATE_LIMIT", defaultBatchRateLimit),
+		BatchRateBurst:    env.int("BATCH_RATE_BURST", defaultBatchRateBurst),
+		LookupRateLimit:   env.float("LOOKUP_RATE_LIMIT", 0),
+		LookupRateBurst:   env.int("LOOKUP_RATE_BURST", defaultLookupRateBurst),
+		RateLimitIdleTTL:  env.duration("RATE_LIMIT_IDLE_TTL", defaultRateLimitIdleTTL),
+		TrustForwardedFor: env.bool("TRUST_FORWARDED_FOR", false),
+
+		GeoIPDBPath:   env.str("GEOIP_DB_PATH", ""),
+		BotUserAgents: strings.Split(env.str("BOT_USER_AGENTS", ""), ","),
+
+		DomainPolicy:       env.str("DOMAIN_POLICY", domainPolicyBlocklist),
+		DomainRulesRefresh: env.duration("DOMAIN_RULES_REFRESH_INTERVAL", defaultDomainRulesRefresh),
+
+		ReputationProvider:   env.str("REPUTATION_PROVIDER", reputationNone),
+		SafeBrowsingAPIKey:   env.str("SAFE_BROWSING_API_KEY", ""),
+		SafeBrowsingURL:      env.str("SAFE_BROWSING_URL", defaultSafeBrowsingURL),
+		ReputationTimeout:    env.duration("REPUTATION_TIMEOUT", defaultReputationTimeout),
+		ReputationFailClosed: env.bool("REPUTATION_FAIL_CLOSED", false),
+		ReputationCacheTTL:   env.duration("REPUTATION_CACHE_TTL", defaultReputationCacheTTL),
+		ReputationRescan:     env.duration("REPUTATION_RESCAN_INTERVAL", defaultReputationRescan),
+
+		FetchMetadata:    env.bool("FETCH_METADATA", false),
+		MetadataTimeout:  env.duration("METADATA_FETCH_TIMEOUT", defaultMetadataTimeout),
+		MetadataMaxBytes: env.int("METADATA_MAX_BYTES", defaultMetadataMaxBytes),
+
+		SelfLinkPolicy:   env.str("SELF_LINK_POLICY", selfLinkReject),
+		KnownShorteners:  strings.Split(env.str("KNOWN_SHORTENERS", ""), ","),
+		LinkChainMaxHops: env.int("LINK_CHAIN_MAX_HOPS", defaultLinkChainMaxHops),
+		LinkChainTimeout: env.duration("LINK_CHAIN_TIMEOUT", defaultLinkChainTimeout),
+
+		MaxURLsPerUser: env.int("MAX_URLS_PER_USER", 0),
+		MaxBatchSize:   env.int("MAX_BATCH_SIZE", defaultMaxBatchSize),
+
+		AdminUsers:             strings.Split(env.str("ADMIN_USERS", ""), ","),
+		ReportDisableThreshold: env.int("REPORT_DISABLE_THRESHOLD", defaultReportDisableThreshold),
+
+		BaseURL:            env.str("BASE_URL", ""),
+		DefaultFallbackURL: env.str("DEFAULT_FALLBACK_URL", ""),
+		Tenants:            env.str("TENANTS", ""),
+
+		ClickFlushInterval:  env.duration("CLICK_FLUSH_INTERVAL", defaultClickFlushInterval),
+		ClickFlushThreshold: int64(env.int("CLICK_FLUSH_THRESHOLD", defaultClickFlushThreshold)),
+		UniqueClickWindow:   env.duration("UNIQUE_CLICK_WINDOW", defaultUniqueClickWindow),
+
+		ClickFeedBuffer:         env.int("CLICK_FEED_BUFFER", defaultClickFeedBuffer),
+		ClickFeedMaxSubscribers: env.int("CLICK_FEED_MAX_SUBSCRIBERS", defaultClickFeedMaxSubscribers),
+
+		EventsBroker:        env.str("EVENTS_BROKER", ""),
+		NATSURL:             env.str("NATS_URL", "nats://localhost:4222"),
+		EventsSubjectPrefix: env.str("EVENTS_SUBJECT_PREFIX", "urlshortener"),
+		KafkaBrokers:        env.str("KAFKA_BROKERS", ""),
+		EventsTopic:         env.str("EVENTS_TOPIC", "url-events"),
+		EventsQueueSize:     env.int("EVENTS_QUEUE_SIZE", defaultEventsQueueSize),
+		EventsBatchSize:     env.int("EVENTS_BATCH_SIZE", defaultEventsBatchSize),
+		EventsFlushInterval: env.duration("EVENTS_FLUSH_INTERVAL", defaultEventsFlushInterval),
+		EventsTimeout:       env.duration("EVENTS_TIMEOUT", defaultEventsTimeout),
+
+		WarmupURLs:    env.int("WARMUP_URLS", defaultWarmupURLs),
+		WarmupOrder:   env.str("WARMUP_ORDER", "clicks"),
+		WarmupTimeout: env.duration("WARMUP_TIMEOUT", defaultWarmupTimeout),
+
+		AsyncWorkers:     env.int("ASYNC_WORKERS", defaultAsyncWorkers),
+		AsyncQueueSize:   env.int("ASYNC_QUEUE_SIZE", defaultAsyncQueueSize),
+		AsyncQueuePolicy: env.str("ASYNC_QUEUE_POLICY", overflowBlock),
+
+		AdmissionQueueHigh:  env.int("ADMISSION_QUEUE_HIGH", defaultAdmissionQueueHigh),
+		AdmissionQueueLow:   env.int("ADMISSION_QUEUE_LOW", defaultAdmissionQueueLow),
+		AdmissionClicksHigh: int64(env.int("ADMISSION_CLICKS_HIGH", defaultAdmissionClicksHigh)),
+		AdmissionClicksLow:  int64(env.int("ADMISSION_CLICKS_LOW", defaultAdmissionClicksLow)),
+
+		PersistMaxAttempts: env.int("PERSIST_RETRY_MAX_ATTEMPTS", defaultPersistMaxAttempts),
+		PersistBaseDelay:   env.duration("PERSIST_RETRY_BASE_DELAY", defaultPersistBaseDelay),
+		PersistWALDir:      env.str("PERSIST_WAL_DIR", ""),
+
+		ReadinessInterval:  env.duration("READINESS_INTERVAL", defaultReadinessInterval),
+		UnhealthyThreshold: env.duration("UNHEALTHY_THRESHOLD", defaultUnhealthyThreshold),
+		ShutdownTimeout:    env.duration("SHUTDOWN_TIMEOUT", defaultShutdownTimeout),
+
+		DebugEndpoints: env.bool("DEBUG_ENDPOINTS", false),
+		DebugAddr:      env.str("DEBUG_ADDR", ""),
+		HeapProfileDir: env.str("HEAP_PROFILE_DIR", os.TempDir()),
+
+		ConfigFile:          configFile,
+		ConfigWatchInterval: env.duration("CONFIG_WATCH_INTERVAL", 0),
+		LogLevel:            env.str("LOG_LEVEL", logLevelInfo),
+
+		InsecureDevMode:  devMode,
+		EnableReflection: env.bool("ENABLE_REFLECTION", devMode),
+	}
+	if env.err != nil {
+		return Config{}, env.err
+	}
+
+	fs := flag.NewFlagSet("url-service", flag.ContinueOnError)
+	fs.StringVar(&cfg.GRPCPort, "grpc-port", cfg.GRPCPort, "gRPC listen port (GRPC_PORT)")
+	fs.StringVar(&cfg.HTTPPort, "http-port", cfg.HTTPPort, "health endpoint listen port (HTTP_PORT)")
+	fs.StringVar(&cfg.CacheServiceAddr, "cache-addr", cfg.CacheServiceAddr, "cache service host:port, comma-separated for several nodes (CACHE_SERVICE_ADDR)")
+	fs.StringVar(&cfg.StorageServiceAddr, "storage-addr", cfg.StorageServiceAddr, "storage service host:port (STORAGE_SERVICE_ADDR)")
+	fs.DurationVar(&cfg.DialTimeout, "dial-timeout", cfg.DialTimeout, "downstream connect timeout (DIAL_TIMEOUT)")
+	fs.DurationVar(&cfg.CacheTTL, "cache-ttl", cfg.CacheTTL, "TTL of cached URLs and counts (CACHE_TTL)")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func (c Config) validate() error {
+	if err := validatePort(c.GRPCPort); err != nil {
+		return fmt.Errorf("invalid GRPC_PORT: %v", err)
+	}
+	if err := validatePort(c.HTTPPort); err != nil {
+		return fmt.Errorf("invalid HTTP_PORT: %v", err)
+	}
+	if c.GRPCWebPort != "" {
+		if err := validatePort(c.GRPCWebPort); err != nil {
+			return fmt.Errorf("invalid GRPC_WEB_PORT: %v", err)
+		}
+	}
+	if c.DebugAddr != "" {
+		if err := validateAddr(c.DebugAddr); err != nil {
+			return fmt.Errorf("invalid DEBUG_ADDR: %v", err)
+		}
+	}
+	cacheAddrs := splitAddrs(c.CacheServiceAddr)
+	if len(cacheAddrs) == 0 {
+		return fmt.Errorf("invalid CACHE_SERVICE_ADDR: no address")
+	}
+	for _, addr := range cacheAddrs {
+		if err := validateAddr(addr); err != nil {
+			return fmt.Errorf("invalid CACHE_SERVICE_ADDR: %v", err)
+		}
+	}
+	if err := validateAddr(c.StorageServiceAddr); err != nil {
+		return fmt.Errorf("invalid STORAGE_SERVICE_ADDR: %v", err)
+	}
+	if c.BaseURL != "" {
+		if err := validateBaseURL(c.BaseURL); err != nil {
+			return fmt.Errorf("invalid BASE_URL: %v", err)
+		}
+	}
+	tenants, err := parseTenants(c.Tenants, c.BaseURL)
+	if err != nil {
+		return fmt.Errorf("invalid TENANTS: %v", err)
+	}
+	if c.DefaultFallbackURL != "" {
+		// The blocked domains and reserved aliases aren't known yet, they
+		// are checked on use
+		if err := newURLValidator(c.MaxURLLength, newSelfLinks(c, tenants.baseURLs, nil), nil).Validate(c.DefaultFallbackURL); err != nil {
+			return fmt.Errorf("invalid DEFAULT_FALLBACK_URL: %s", status.Convert(err).Message())
+		}
+	}
+	if _, err := resolveAlphabet(c.ShortCodeAlphabet); err != nil {
+		return fmt.Errorf("invalid SHORT_CODE_ALPHABET: %v", err)
+	}
+
+	checks := []struct {
+		name string
+		ok   bool
+		want string
+	}{
+		{"DIAL_TIMEOUT", c.DialTimeout > 0, "must be positive"},
+		{"CACHE_READ_TIMEOUT", c.CacheReadTimeout > 0, "must be positive"},
+		{"CACHE_TIMEOUT", c.CacheTimeout > 0, "must be positive"},
+		{"STORAGE_TIMEOUT", c.StorageTimeout > 0, "must be positive"},
+		{"CACHE_TTL", c.CacheTTL >= time.Second, "must be at least 1s"},
+		{"DEFAULT_REQUEST_TIMEOUT", c.RequestTimeout > 0, "must be positive"},
+		{"CACHE_VIRTUAL_NODES", c.CacheVirtualNodes >= 1 && c.CacheVirtualNodes <= 1000, "must be between 1 and 1000"},
+		{"CACHE_RESOLVE_INTERVAL", c.CacheResolveInterval == 0 || c.CacheResolveInterval >= time.Second, "must be 0 (disabled) or at least 1s"},
+		{"STORAGE_LB_POLICY", c.StorageLBPolicy == lbRoundRobin || c.StorageLBPolicy == lbPickFirst, "must be round_robin or pick_first"},
+		{"STORAGE_RESOLVE_INTERVAL", c.StorageResolveInterval == 0 || c.StorageResolveInterval >= time.Second, "must be 0 (disabled) or at least 1s"},
+		{"LOOKUP_CACHE_BUDGET", c.LookupCacheBudget > 0, "must be positive"},
+		{"LOOKUP_HEDGE_DELAY", c.LookupHedgeDelay >= 0 && c.LookupHedgeDelay <= c.LookupCacheBudget, "must be between 0 (disabled) and LOOKUP_CACHE_BUDGET"},
+		{"LOOKUP_STORAGE_RESERVE", c.LookupStorageReserve >= 0, "must not be negative"},
+		{"NEGATIVE_CACHE_TTL", c.NegativeCacheTTL == 0 || c.NegativeCacheTTL >= time.Second, "must be 0 (disabled) or at least 1s"},
+		{"GRPC_RETRY_MAX_ATTEMPTS", c.RetryMaxAttempts >= 2, "must be at least 2"},
+		{"GRPC_RETRY_INITIAL_BACKOFF", c.RetryInitialBackoff > 0, "must be positive"},
+		{"GRPC_RETRY_MAX_BACKOFF", c.RetryMaxBackoff >= c.RetryInitialBackoff, "must not be below GRPC_RETRY_INITIAL_BACKOFF"},
+		{"BREAKER_FAILURE_THRESHOLD", c.BreakerFailureThreshold > 0, "must be positive"},
+		{"BREAKER_OPEN_TIMEOUT", c.BreakerOpenTimeout > 0, "must be positive"},
+		{"MAX_URL_LENGTH", c.MaxURLLength > 0, "must be positive"},
+		{"URL_CACHE_MAX_ENTRIES", c.URLCacheEntries > 0, "must be positive"},
+		{"MEMORY_STALE_AFTER", c.MemoryStaleAfter >= 0, "must not be negative"},
+		{"MEMORY_MAX_STALENESS", c.MemoryMaxStaleness >= 0, "must not be negative"},
+		{"SHORTEN_RATE_LIMIT", c.ShortenRateLimit >= 0, "must be 0 (unlimited) or positive"},
+		{"SHORTEN_RATE_BURST", c.ShortenRateBurst > 0, "must be positive"},
+		{"LOOKUP_RATE_LIMIT", c.LookupRateLimit >= 0, "must be 0 (unlimited) or positive"},
+		{"LOOKUP_RATE_BURST", c.LookupRateBurst > 0, "must be positive"},
+		{"BATCH_RATE_LIMIT", c.BatchRateLimit >= 0, "must be 0 (unlimited) or positive"},
+		{"BATCH_RATE_BURST", c.BatchRateBurst > 0, "must be positive"},
+		{"MAX_BATCH_SIZE", c.MaxBatchSize > 0, "must be positive"},
+		// A batch takes a token per item, so a smaller burst would turn away every full batch
+		{"BATCH_RATE_BURST", c.BatchRateLimit == 0 || c.BatchRateBurst >= c.MaxBatchSize, "must be at least MAX_BATCH_SIZE"},
+		{"LOOKUP_RATE_BURST", c.LookupRateLimit == 0 || c.LookupRateBurst >= c.MaxBatchSize, "must be at least MAX_BATCH_SIZE when LOOKUP_RATE_LIMIT is set"},
+		{"MAX_URLS_PER_USER", c.MaxURLsPerUser >= 0, "must be 0 (unlimited) or positive"},
+		{"REPORT_DISABLE_THRESHOLD", c.ReportDisableThreshold >= 0, "must be 0 (never disable) or positive"},
+		{"RATE_LIMIT_IDLE_TTL", c.RateLimitIdleTTL > 0, "must be positive"},
+		{"DOMAIN_POLICY", c.DomainPolicy == domainPolicyBlocklist || c.DomainPolicy == domainPolicyAllowlist, "must be blocklist or allowlist"},
+		{"DOMAIN_RULES_REFRESH_INTERVAL", c.DomainRulesRefresh > 0, "must be positive"},
+		{"REPUTATION_PROVIDER", c.ReputationProvider == reputationNone || c.ReputationProvider == reputationSafeBrowsing, "must be empty or safebrowsing"},
+		{"SAFE_BROWSING_API_KEY", c.ReputationProvider != reputationSafeBrowsing || c.SafeBrowsingAPIKey != "", "is required with REPUTATION_PROVIDER=safebrowsing"},
+		{"REPUTATION_TIMEOUT", c.ReputationTimeout > 0, "must be positive"},
+		{"REPUTATION_CACHE_TTL", c.ReputationCacheTTL > 0, "must be positive"},
+		{"REPUTATION_RESCAN_INTERVAL", c.ReputationRescan == 0 || c.ReputationRescan >= time.Minute, "must be 0 (disabled) or at least 1m"},
+		{"METADATA_FETCH_TIMEOUT", c.MetadataTimeout > 0, "must be positive"},
+		{"METADATA_MAX_BYTES", c.MetadataMaxBytes > 0, "must be positive"},
+		{"SELF_LINK_POLICY", c.SelfLinkPolicy == selfLinkReject || c.SelfLinkPolicy == selfLinkResolve, "must be reject or resolve"},
+		{"LINK_CHAIN_MAX_HOPS", c.LinkChainMaxHops >= 0, "must not be negative"},
+		{"LINK_CHAIN_TIMEOUT", c.LinkChainTimeout > 0, "must be positive"},
+		{"CLICK_FLUSH_INTERVAL", c.ClickFlushInterval > 0, "must be positive"},
+		{"CLICK_FLUSH_THRESHOLD", c.ClickFlushThreshold > 0, "must be positive"},
+		{"CLICK_FEED_BUFFER", c.ClickFeedBuffer > 0, "must be positive"},
+		{"CLICK_FEED_MAX_SUBSCRIBERS", c.ClickFeedMaxSubscribers > 0, "must be positive"},
+		{"EVENTS_BROKER", c.EventsBroker == "" || c.EventsBroker == eventsBrokerNATS || c.EventsBroker == eventsBrokerKafka, "must be empty, nats or kafka"},
+		{"KAFKA_BROKERS", c.EventsBroker != eventsBrokerKafka || len(splitAddrs(c.KafkaBrokers)) > 0, "is required with EVENTS_BROKER=kafka"},
+		{"EVENTS_QUEUE_SIZE", c.EventsQueueSize > 0, "must be positive"},
+		{"EVENTS_BATCH_SIZE", c.EventsBatchSize > 0, "must be positive"},
+		{"EVENTS_FLUSH_INTERVAL", c.EventsFlushInterval > 0, "must be positive"},
+		{"EVENTS_TIMEOUT", c.EventsTimeout > 0, "must be positive"},
+		{"UNIQUE_CLICK_WINDOW", c.UniqueClickWindow == 0 || (c.UniqueClickWindow >= time.Minute && c.UniqueClickWindow <= 24*time.Hour), "must be 0 (disabled) or between 1m and 24h"},
+		{"WARMUP_URLS", c.WarmupURLs >= 0, "must be 0 (disabled) or positive"},
+		{"WARMUP_ORDER", c.WarmupOrder == "clicks" || c.WarmupOrder == "recent", "must be clicks or recent"},
+		{"CODE_STRATEGY", c.CodeStrategy == codeStrategyRandom || c.CodeStrategy == codeStrategySequence || c.CodeStrategy == codeStrategyPool || c.CodeStrategy == codeStrategyHash, "must be random, sequence, pool or hash"},
+		{"SHORT_CODE_LENGTH", c.ShortCodeLength >= minShortCodeLength && c.ShortCodeLength <= maxShortCodeLength, "must be between 4 and 12"},
+		{"WARMUP_TIMEOUT", c.WarmupTimeout > 0, "must be positive"},
+		{"ADMISSION_QUEUE_HIGH", c.AdmissionQueueHigh >= 0 && c.AdmissionQueueHigh <= c.AsyncQueueSize, "must be between 0 (disabled) and ASYNC_QUEUE_SIZE"},
+		{"ADMISSION_QUEUE_LOW", c.AdmissionQueueHigh == 0 || c.AdmissionQueueLow >= 0 && c.AdmissionQueueLow < c.AdmissionQueueHigh, "must not be negative and must be below ADMISSION_QUEUE_HIGH"},
+		{"ADMISSION_CLICKS_HIGH", c.AdmissionClicksHigh >= 0, "must be 0 (disabled) or positive"},
+		{"ADMISSION_CLICKS_LOW", c.AdmissionClicksHigh == 0 || c.AdmissionClicksLow >= 0 && c.AdmissionClicksLow < c.AdmissionClicksHigh, "must not be negative and must be below ADMISSION_CLICKS_HIGH"},
+		{"PERSIST_RETRY_MAX_ATTEMPTS", c.PersistMaxAttempts > 0, "must be positive"},
+		{"PERSIST_RETRY_BASE_DELAY", c.PersistBaseDelay > 0, "must be positive"},
+		{"READINESS_INTERVAL", c.ReadinessInterval > 0, "must be positive"},
+		{"UNHEALTHY_THRESHOLD", c.UnhealthyThreshold >= 0, "must not be negative"},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout > 0, "must be positive"},
+		{"HEAP_PROFILE_DIR", c.HeapProfileDir != "", "must not be empty"},
+		{"CONFIG_WATCH_INTERVAL", c.ConfigWatchInterval == 0 || c.ConfigWatchInterval >= time.Second, "must be 0 (disabled) or at least 1s"},
+		{"LOG_LEVEL", c.LogLevel == logLevelInfo || c.LogLevel == logLevelWarn, "must be info or warn"},
+	}
+	for _, check := range checks {
+		if !check.ok {
+			return fmt.Errorf("invalid %s: %s", check.name, check.want)
+		}
+	}
+	return nil
+}
+
+func validatePort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n <= 0 || n > 65535 {
+		return fmt.Errorf("%q is not a port number", port)
+	}
+	return nil
+}
+
+func validateBaseURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute http(s) URL", rawURL)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("%q must not have a query or fragment", rawURL)
+	}
+	return nil
+}
+
+func validateAddr(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%q is not host:port", addr)
+	}
+	if host == "" {
+		return fmt.Errorf("%q has an empty host", addr)
+	}
+	return validatePort(port)
+}
+
+// envReader parses typed environment values, remembering the first error.
+type envReader struct {
+	getenv func(string) string
+	err    error
+}
+
+func (r *envReader) str(key, defaultValue string) string {
+	if value := r.getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func (r *envReader) int(key string, defaultValue int) int {
+	value := r.getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		r.fail(key, value)
+	}
+	return n
+}
+
+func (r *envReader) float(key string, defaultValue float64) float64 {
+	value := r.getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		r.fail(key, value)
+	}
+	return f
+}
+
+func (r *envReader) bool(key string, defaultValue bool) bool {
+	value := r.getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		r.fail(key, value)
+	}
+	return b
+}
+
+func (r *envReader) duration(key string, defaultValue time.Duration) time.Duration {
+	value := r.getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		r.fail(key, value)
+	}
+	return d
+}
+
+func (r *envReader) fail(key, value string) {
+	if r.err == nil {
+		r.err = fmt.Errorf("invalid %s: %q", key, value)
+	}
+}
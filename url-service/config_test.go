@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		args        []string
+		wantPort    string
+		wantStorage string
+		wantTTL     time.Duration
+	}{
+		{"defaults", nil, nil, defaultGRPCPort, "storage-service:50053", defaultCacheTTL},
+		{"legacy host", map[string]string{"STORAGE_SERVICE_HOST": "db-proxy"}, nil, defaultGRPCPort, "db-proxy:50053", defaultCacheTTL},
+		{
+			"env",
+			map[string]string{"GRPC_PORT": "6000", "STORAGE_SERVICE_HOST": "db-proxy", "STORAGE_SERVICE_ADDR": "storage:7000", "CACHE_TTL": "5m"},
+			nil, "6000", "storage:7000", 5 * time.Minute,
+		},
+		{
+			"flags over env",
+			map[string]string{"GRPC_PORT": "6000", "STORAGE_SERVICE_ADDR": "storage:7000", "CACHE_TTL": "5m"},
+			[]string{"-grpc-port", "6001", "-storage-addr", "storage:7001", "-cache-ttl", "10m"},
+			"6001", "storage:7001", 10 * time.Minute,
+		},
+		{"flags over defaults", nil, []string{"-grpc-port=6002"}, "6002", "storage-service:50053", defaultCacheTTL},
+	}
+	for _, tt := range tests {
+		cfg, err := loadConfig(tt.args, testEnv(tt.env))
+		if err != nil {
+			t.Errorf("%s: loadConfig: %v", tt.name, err)
+			continue
+		}
+		if cfg.GRPCPort != tt.wantPort || cfg.StorageServiceAddr != tt.wantStorage || cfg.CacheTTL != tt.wantTTL {
+			t.Errorf("%s: port %s, storage %s, TTL %v, want %s, %s, %v", tt.name, cfg.GRPCPort, cfg.StorageServiceAddr, cfg.CacheTTL, tt.wantPort, tt.wantStorage, tt.wantTTL)
+		}
+	}
+}
+
+func TestLoadConfigReflection(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want bool
+	}{
+		{"default", nil, false},
+		{"dev mode", map[string]string{"INSECURE_DEV_MODE": "true"}, true},
+		{"dev mode without reflection", map[string]string{"INSECURE_DEV_MODE": "true", "ENABLE_REFLECTION": "false"}, false},
+		{"reflection outside dev mode", map[string]string{"ENABLE_REFLECTION": "true"}, true},
+	}
+	for _, tt := range tests {
+		cfg, err := loadConfig(nil, testEnv(tt.env))
+		if err != nil {
+			t.Errorf("%s: loadConfig: %v", tt.name, err)
+			continue
+		}
+		if cfg.EnableReflection != tt.want {
+			t.Errorf("%s: reflection %v, want %v", tt.name, cfg.EnableReflection, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{"port not a number", map[string]string{"GRPC_PORT": "http"}, nil, "GRPC_PORT"},
+		{"port out of range", map[string]string{"HTTP_PORT": "70000"}, nil, "HTTP_PORT"},
+		{"port flag not a number", nil, []string{"-grpc-port", "grpc"}, "GRPC_PORT"},
+		{"addr without port", map[string]string{"STORAGE_SERVICE_ADDR": "storage"}, nil, "STORAGE_SERVICE_ADDR"},
+		{"addr without host", map[string]string{"STORAGE_SERVICE_ADDR": ":50053"}, nil, "STORAGE_SERVICE_ADDR"},
+		{"empty cache addr flag", nil, []string{"-cache-addr", ""}, "CACHE_SERVICE_ADDR"},
+		{"bad cache node", map[string]string{"CACHE_SERVICE_ADDR": "cache-a:50052,cache-b"}, nil, "CACHE_SERVICE_ADDR"},
+		{"no cache node", map[string]string{"CACHE_SERVICE_ADDR": " , "}, nil, "CACHE_SERVICE_ADDR"},
+		{"no virtual nodes", map[string]string{"CACHE_VIRTUAL_NODES": "0"}, nil, "CACHE_VIRTUAL_NODES"},
+		{"resolve interval under a second", map[string]string{"CACHE_RESOLVE_INTERVAL": "100ms"}, nil, "CACHE_RESOLVE_INTERVAL"},
+		{"unknown storage balancing", map[string]string{"STORAGE_LB_POLICY": "least_request"}, nil, "STORAGE_LB_POLICY"},
+		{"storage resolved too often", map[string]string{"STORAGE_RESOLVE_INTERVAL": "100ms"}, nil, "STORAGE_RESOLVE_INTERVAL"},
+		{"duration without unit", map[string]string{"DIAL_TIMEOUT": "5"}, nil, "DIAL_TIMEOUT"},
+		{"zero dial timeout", map[string]string{"DIAL_TIMEOUT": "0s"}, nil, "DIAL_TIMEOUT"},
+		{"TTL under a second", map[string]string{"CACHE_TTL": "500ms"}, nil, "CACHE_TTL"},
+		{"zero cache budget", map[string]string{"LOOKUP_CACHE_BUDGET": "0s"}, nil, "LOOKUP_CACHE_BUDGET"},
+		{"hedge past the cache budget", map[string]string{"LOOKUP_CACHE_BUDGET": "10ms", "LOOKUP_HEDGE_DELAY": "20ms"}, nil, "LOOKUP_HEDGE_DELAY"},
+		{"negative storage reserve", map[string]string{"LOOKUP_STORAGE_RESERVE": "-1s"}, nil, "LOOKUP_STORAGE_RESERVE"},
+		{"queue watermark past the queue", map[string]string{"ASYNC_QUEUE_SIZE": "100", "ADMISSION_QUEUE_HIGH": "101"}, nil, "ADMISSION_QUEUE_HIGH"},
+		{"queue low watermark at the high one", map[string]string{"ADMISSION_QUEUE_HIGH": "100", "ADMISSION_QUEUE_LOW": "100"}, nil, "ADMISSION_QUEUE_LOW"},
+		{"negative clicks watermark", map[string]string{"ADMISSION_CLICKS_HIGH": "-1"}, nil, "ADMISSION_CLICKS_HIGH"},
+		{"clicks low watermark over the high one", map[string]string{"ADMISSION_CLICKS_HIGH": "100", "ADMISSION_CLICKS_LOW": "200"}, nil, "ADMISSION_CLICKS_LOW"},
+		{"config watched too often", map[string]string{"CONFIG_WATCH_INTERVAL": "100ms"}, nil, "CONFIG_WATCH_INTERVAL"},
+		{"debug addr without port", map[string]string{"DEBUG_ENDPOINTS": "true", "DEBUG_ADDR": "localhost"}, nil, "DEBUG_ADDR"},
+		{"unknown log level", map[string]string{"LOG_LEVEL": "debug"}, nil, "LOG_LEVEL"},
+		{"code length too short", map[string]string{"SHORT_CODE_LENGTH": "3"}, nil, "SHORT_CODE_LENGTH"},
+		{"code length too long", map[string]string{"SHORT_CODE_LENGTH": "13"}, nil, "SHORT_CODE_LENGTH"},
+		{"alphabet too small", map[string]string{"SHORT_CODE_ALPHABET": "abc"}, nil, "SHORT_CODE_ALPHABET"},
+		{"alphabet with duplicates", map[string]string{"SHORT_CODE_ALPHABET": "abcdefghija"}, nil, "SHORT_CODE_ALPHABET"},
+		{"alphabet with invalid characters", map[string]string{"SHORT_CODE_ALPHABET": "abcdefghij+"}, nil, "SHORT_CODE_ALPHABET"},
+		{"unknown flag", nil, []string{"-no-such-flag"}, "no-such-flag"},
+	}
+	for _, tt := range tests {
+		_, err := loadConfig(tt.args, testEnv(tt.env))
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: got %v, want an error mentioning %s", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	defaultCacheReadTimeout = 150 * time.Millisecond
+	defaultCacheTimeout     = time.Second
+	defaultStorageTimeout   = 3 * time.Second
+)
+
+// dependencyTimeouts bounds every downstream call. Cache reads get a tight
+// budget so a slow cache degrades to memory or storage instead of stalling
+// the lookup.
+type dependencyTimeouts struct {
+	cacheRead time.Duration
+	cache     time.Duration
+	storage   time.Duration
+}
+
+// cacheReadCtx derives the context for a cache Get made on behalf of ctx.
+func (s *urlServer) cacheReadCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(outgoingContext(ctx), s.timeouts.cacheRead)
+}
+
+// cacheCtx derives the context for a cache write or delete made on behalf of ctx.
+func (s *urlServer) cacheCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(outgoingContext(ctx), s.timeouts.cache)
+}
+
+// storageCtx derives the context for a storage call made on behalf of ctx.
+func (s *urlServer) storageCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(outgoingContext(ctx), s.timeouts.storage)
+}
+
+// detach returns a context for async work started by a request. It keeps
+// the request's values and forwardable metadata but not its cancellation, so
+// the work outlives the RPC that started it.
+func detach(ctx context.Context) context.Context {
+	return context.WithoutCancel(outgoingContext(ctx))
+}
+
+// outgoingContext forwards the incoming request metadata (request ID, auth)
+// to downstream calls. Transport headers describe the incoming hop only and
+// are dropped.
+func outgoingContext(ctx context.Context) context.Context {
+	in, _ := metadata.FromIncomingContext(ctx)
+	id := requestID(ctx)
+	user, keyID := userID(ctx), apiKeyID(ctx)
+	if len(in) == 0 && id == "" && keyID == "" {
+		return ctx
+	}
+
+	out, _ := metadata.FromOutgoingContext(ctx)
+	out = out.Copy()
+	for key, values := range in {
+		if !forwardMetadataKey(key) {
+			continue
+		}
+		if _, exists := out[key]; !exists {
+			out[key] = append([]string(nil), values...)
+		}
+	}
+	// Generated IDs aren't in the incoming metadata
+	if id != "" {
+		out.Set(requestIDHeader, id)
+	}
+	// Storage records who made a change from these, never from the caller
+	if keyID != "" {
+		out.Set(actorHeader, user)
+		out.Set(actorKeyIDHeader, keyID)
+	}
+	return metadata.NewOutgoingContext(ctx, out)
+}
+
+// withActor names actor, rather than the caller's user and key, as who made
+// the changes storage records for calls made with ctx.
+func withActor(ctx context.Context, actor string) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Set(actorHeader, actor)
+	md.Delete(actorKeyIDHeader)
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+func forwardMetadataKey(key string) bool {
+	if strings.HasPrefix(key, ":") || strings.HasPrefix(key, "grpc-") {
+		return false
+	}
+	switch key {
+	// Trace context is propagated by the tracing client handler, API keys
+	// are only meaningful to this service, and actors are set from them
+	case "content-type", "user-agent", "te", "traceparent", "tracestate", "baggage", apiKeyHeader, actorHeader, actorKeyIDHeader:
+		return false
+	}
+	return true
+}
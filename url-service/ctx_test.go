@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestDetach(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(
+		requestIDHeader, "req-1",
+		"x-client", "mobile",
+		apiKeyHeader, "secret",
+		"content-type", "application/grpc",
+		"traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+	))
+	ctx = withKey(ctx, "alice-key", "alice")
+	detached := detach(ctx)
+	cancel()
+
+	if err := detached.Err(); err != nil {
+		t.Errorf("detached context cancelled with the request: %v", err)
+	}
+	if _, ok := detached.Deadline(); ok {
+		t.Error("detached context has a deadline")
+	}
+	if userID(detached) != "alice" {
+		t.Errorf("detached context lost the caller, got %q", userID(detached))
+	}
+
+	// Only metadata meant for downstream services is forwarded
+	out, _ := metadata.FromOutgoingContext(detached)
+	for key, want := range map[string]string{
+		requestIDHeader:  "req-1",
+		"x-client":       "mobile",
+		actorHeader:      "alice",
+		actorKeyIDHeader: "alice-key",
+		apiKeyHeader:     "",
+		"content-type":   "",
+		"traceparent":    "",
+	} {
+		var got string
+		if values := out.Get(key); len(values) > 0 {
+			got = values[0]
+		}
+		if got != want {
+			t.Errorf("outgoing %s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestAsyncSaveKeepsRequestMetadata(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	ctx, cancel := context.WithCancel(withKey(context.Background(), "alice-key", "alice"))
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(requestIDHeader, "req-2"))
+
+	resp, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com"})
+	if err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	// The request is over before the save runs
+	cancel()
+	s.tasks.Close(context.Background())
+
+	md := storage.savedWith(resp.ShortCode)
+	if got := md.Get(requestIDHeader); len(got) != 1 || got[0] != "req-2" {
+		t.Errorf("save carried request ID %v, want req-2", got)
+	}
+	if got := md.Get(actorHeader); len(got) != 1 || got[0] != "alice" {
+		t.Errorf("save carried actor %v, want alice", got)
+	}
+}
+
+func TestSlowCacheFallsBackToStorage(t *testing.T) {
+	s, storage, cache := newTestServer(t, nil)
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "abc123", OriginalUrl: "https://example.com"})
+	cache.mu.Lock()
+	cache.getDelay = 5 * time.Second
+	cache.mu.Unlock()
+
+	start := time.Now()
+	resp, err := s.GetOriginalURL(context.Background(), &url_service.GetOriginalRequest{ShortCode: "abc123"})
+	if err != nil || resp.OriginalUrl != "https://example.com" {
+		t.Fatalf("GetOriginalURL = %v, %v, want https://example.com", resp, err)
+	}
+	// A hung cache costs its read budget, not the whole lookup
+	if elapsed := time.Since(start); elapsed > defaultCacheReadTimeout+time.Second {
+		t.Errorf("lookup took %v with the cache hung, want about %v", elapsed, defaultCacheReadTimeout)
+	}
+}
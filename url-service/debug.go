@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"time"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// With DEBUG_ENDPOINTS set, the net/http/pprof profiles are served under
+// /debug/pprof/ and a snapshot of the runtime and the service's memory under
+// /debug/vars, on the health endpoints' port or, with DEBUG_ADDR, a listener
+// of their own such as localhost:6060 that only the host can reach. Where
+// no port can be reached at all, WriteHeapProfile writes a heap profile to
+// HEAP_PROFILE_DIR to be copied off the replica.
+
+// registerDebug adds the debug endpoints to router.
+func (s *urlServer) registerDebug(router *gin.Engine) {
+	router.GET("/debug/vars", s.DebugVars)
+	router.GET("/debug/pprof/*profile", func(c *gin.Context) {
+		switch c.Param("profile") {
+		case "/cmdline":
+			pprof.Cmdline(c.Writer, c.Request)
+		case "/profile":
+			pprof.Profile(c.Writer, c.Request)
+		case "/symbol":
+			pprof.Symbol(c.Writer, c.Request)
+		case "/trace":
+			pprof.Trace(c.Writer, c.Request)
+		default:
+			// Index serves the named profiles, heap and goroutine among them
+			pprof.Index(c.Writer, c.Request)
+		}
+	})
+	router.POST("/debug/pprof/symbol", gin.WrapF(pprof.Symbol))
+}
+
+// newDebugHTTPServer serves only the debug endpoints, on addr.
+func newDebugHTTPServer(addr string, s *urlServer) *http.Server {
+	router := gin.New()
+	router.Use(gin.Recovery())
+	s.registerDebug(router)
+
+	return &http.Server{
+		Addr:    addr,
+		Handler: router,
+	}
+}
+
+// debugVars is the body of /debug/vars.
+type debugVars struct {
+	Goroutines int              `json:"goroutines"`
+	Memory     debugMemory      `json:"memory"`
+	GC         debugGC          `json:"gc"`
+	Caches     map[string]int   `json:"caches"` // entries held by each in-memory cache
+	Queues     map[string]int64 `json:"queues"` // work waiting in each background queue
+}
+
+type debugMemory struct {
+	HeapAllocBytes    uint64 `json:"heap_alloc_bytes"`
+	HeapInuseBytes    uint64 `json:"heap_inuse_bytes"`
+	HeapObjects       uint64 `json:"heap_objects"`
+	StackInuseBytes   uint64 `json:"stack_inuse_bytes"`
+	SysBytes          uint64 `json:"sys_bytes"`
+	TotalAllocBytes   uint64 `json:"total_alloc_bytes"`
+	HeapReleasedBytes uint64 `json:"heap_released_bytes"`
+}
+
+type debugGC struct {
+	Cycles       uint32  `json:"cycles"`
+	Forced       uint32  `json:"forced"`
+	LastGC       string  `json:"last_gc,omitempty"` // RFC3339
+	LastPauseMs  float64 `json:"last_pause_ms"`
+	TotalPauseMs float64 `json:"total_pause_ms"`
+	CPUFraction  float64 `json:"cpu_fraction"`
+	NextGCBytes  uint64  `json:"next_gc_bytes"`
+}
+
+// DebugVars reports what memory and goroutines are going to. Reading the
+// memory stats stops the world briefly, so it isn't for frequent polling;
+// /metrics has the same figures for that.
+func (s *urlServer) DebugVars(c *gin.Context) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	s.mu.RLock()
+	deleted, missing := len(s.deleted), len(s.missing)
+	s.mu.RUnlock()
+
+	vars := debugVars{
+		Goroutines: runtime.NumGoroutine(),
+		Memory: debugMemory{
+			HeapAllocBytes:    mem.HeapAlloc,
+			HeapInuseBytes:    mem.HeapInuse,
+			HeapObjects:       mem.HeapObjects,
+			StackInuseBytes:   mem.StackInuse,
+			SysBytes:          mem.Sys,
+			TotalAllocBytes:   mem.TotalAlloc,
+			HeapReleasedBytes: mem.HeapReleased,
+		},
+		GC: debugGC{
+			Cycles:       mem.NumGC,
+			Forced:       mem.NumForcedGC,
+			TotalPauseMs: float64(mem.PauseTotalNs) / 1e6,
+			CPUFraction:  mem.GCCPUFraction,
+			NextGCBytes:  mem.NextGC,
+		},
+		Caches: map[string]int{
+			"urls":       s.urls.Len(),
+			"tombstones": deleted,
+			"not_found":  missing,
+		},
+		Queues: map[string]int64{
+			"async_tasks":      int64(s.tasks.Depth()),
+			"unpersisted_urls": int64(s.persister.Pending()),
+			"unflushed_clicks": s.clicks.Unflushed(),
+		},
+	}
+	if mem.NumGC > 0 {
+		vars.GC.LastGC = time.Unix(0, int64(mem.LastGC)).UTC().Format(time.RFC3339)
+		vars.GC.LastPauseMs = float64(mem.PauseNs[(mem.NumGC+255)%256]) / 1e6
+	}
+	c.JSON(http.StatusOK, vars)
+}
+
+// WriteHeapProfile writes a heap profile of this replica to a new file in
+// HEAP_PROFILE_DIR, after a GC so it shows what is live.
+func (s *urlServer) WriteHeapProfile(ctx context.Context, req *url_service.WriteHeapProfileRequest) (*url_service.WriteHeapProfileResponse, error) {
+	logf(ctx, "WriteHeapProfile request")
+
+	if !s.debugEndpoints {
+		return nil, status.Error(codes.FailedPrecondition, "debug endpoints are disabled, set DEBUG_ENDPOINTS")
+	}
+	if err := s.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	path, size, err := writeHeapProfile(s.heapProfileDir, now)
+	if err != nil {
+		logf(ctx, "Failed to write heap profile: %v", err)
+		return nil, status.Error(codes.Internal, "failed to write heap profile")
+	}
+	logf(ctx, "Heap profile written to %s (%d bytes)", path, size)
+	return &url_service.WriteHeapProfileResponse{
+		Path:      path,
+		Bytes:     size,
+		WrittenAt: now.Format(time.RFC3339),
+	}, nil
+}
+
+func writeHeapProfile(dir string, now time.Time) (string, int64, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", 0, err
+	}
+	f, err := os.CreateTemp(dir, fmt.Sprintf("heap-%s-*.pprof", now.Format("20060102T150405Z")))
+	if err != nil {
+		return "", 0, err
+	}
+	path := filepath.Clean(f.Name())
+
+	runtime.GC()
+	if err := runtimepprof.Lookup("heap").WriteTo(f, 0); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", 0, err
+	}
+	info, err := f.Stat()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", 0, err
+	}
+	return path, info.Size(), nil
+}
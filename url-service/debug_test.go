@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// checkHeapProfile fails the test unless profile is a non-empty gzipped
+// pprof profile.
+func checkHeapProfile(t *testing.T, what string, profile []byte) {
+	t.Helper()
+	r, err := gzip.NewReader(bytes.NewReader(profile))
+	if err != nil {
+		t.Fatalf("%s is not a gzipped profile: %v", what, err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil || len(data) == 0 {
+		t.Fatalf("%s: read %d bytes, %v, want a profile", what, len(data), err)
+	}
+}
+
+func TestDebugEndpoints(t *testing.T) {
+	s, _, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+	for _, alias := range []string{"debug1", "debug2"} {
+		if _, err := s.ShortenURL(context.Background(), &url_service.ShortenRequest{OriginalUrl: "https://example.com/" + alias, CustomAlias: alias}); err != nil {
+			t.Fatalf("ShortenURL(%s): %v", alias, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		metrics bool
+	}{
+		{"health port", newHealthHTTPServer("0", s, true).Handler, true},
+		{"own listener", newDebugHTTPServer("localhost:0", s).Handler, false},
+	}
+	for _, tt := range tests {
+		rec := serveGet(tt.handler, "/debug/pprof/heap")
+		if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+			t.Fatalf("%s: GET /debug/pprof/heap = %d with %d bytes, want a profile", tt.name, rec.Code, rec.Body.Len())
+		}
+		checkHeapProfile(t, tt.name+" heap profile", rec.Body.Bytes())
+
+		if rec := serveGet(tt.handler, "/debug/pprof/goroutine?debug=1"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine") {
+			t.Errorf("%s: GET /debug/pprof/goroutine = %d, %.100s", tt.name, rec.Code, rec.Body.String())
+		}
+		if rec := serveGet(tt.handler, "/debug/pprof/"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "heap") {
+			t.Errorf("%s: GET /debug/pprof/ = %d, want the index of profiles", tt.name, rec.Code)
+		}
+		if rec := serveGet(tt.handler, "/metrics"); (rec.Code == http.StatusOK) != tt.metrics {
+			t.Errorf("%s: GET /metrics = %d, want served %v", tt.name, rec.Code, tt.metrics)
+		}
+
+		rec = serveGet(tt.handler, "/debug/vars")
+		var vars debugVars
+		if err := json.Unmarshal(rec.Body.Bytes(), &vars); rec.Code != http.StatusOK || err != nil {
+			t.Fatalf("%s: GET /debug/vars = %d, %v", tt.name, rec.Code, err)
+		}
+		if vars.Goroutines == 0 || vars.Memory.HeapAllocBytes == 0 || vars.Memory.SysBytes == 0 {
+			t.Errorf("%s: /debug/vars %+v, want goroutines and memory", tt.name, vars)
+		}
+		if vars.Caches["urls"] != 2 {
+			t.Errorf("%s: /debug/vars caches %v, want 2 urls", tt.name, vars.Caches)
+		}
+		for _, queue := range []string{"async_tasks", "unpersisted_urls", "unflushed_clicks"} {
+			if _, ok := vars.Queues[queue]; !ok {
+				t.Errorf("%s: /debug/vars queues %v, missing %s", tt.name, vars.Queues, queue)
+			}
+		}
+	}
+
+	// Without DEBUG_ENDPOINTS none of it is served
+	handler := newHealthHTTPServer("0", s, false).Handler
+	for _, path := range []string{"/debug/pprof/heap", "/debug/vars"} {
+		if rec := serveGet(handler, path); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s with debug endpoints off = %d, want 404", path, rec.Code)
+		}
+	}
+}
+
+func TestWriteHeapProfile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "profiles")
+	s, _, _ := newTestServer(t, map[string]string{"DEBUG_ENDPOINTS": "true", "HEAP_PROFILE_DIR": dir, "ADMIN_USERS": "root"})
+	root := withKey(context.Background(), "root-key", "root")
+
+	if _, err := s.WriteHeapProfile(withKey(context.Background(), "alice-key", "alice"), &url_service.WriteHeapProfileRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("WriteHeapProfile by a user who isn't an admin: got %v, want PermissionDenied", err)
+	}
+
+	var paths []string
+	for i := 0; i < 2; i++ {
+		resp, err := s.WriteHeapProfile(root, &url_service.WriteHeapProfileRequest{})
+		if err != nil {
+			t.Fatalf("WriteHeapProfile: %v", err)
+		}
+		if filepath.Dir(resp.Path) != dir || !strings.HasPrefix(filepath.Base(resp.Path), "heap-") || resp.WrittenAt == "" {
+			t.Errorf("WriteHeapProfile = %v, want a heap- file in %s", resp, dir)
+		}
+		profile, err := os.ReadFile(resp.Path)
+		if err != nil {
+			t.Fatalf("read profile: %v", err)
+		}
+		if int64(len(profile)) != resp.Bytes {
+			t.Errorf("profile of %d bytes, reported %d", len(profile), resp.Bytes)
+		}
+		checkHeapProfile(t, resp.Path, profile)
+		paths = append(paths, resp.Path)
+	}
+	// Profiles taken the same second don't overwrite each other
+	if paths[0] == paths[1] {
+		t.Errorf("both profiles written to %s", paths[0])
+	}
+
+	off, _, _ := newTestServer(t, map[string]string{"HEAP_PROFILE_DIR": dir, "ADMIN_USERS": "root"})
+	if _, err := off.WriteHeapProfile(root, &url_service.WriteHeapProfileRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("WriteHeapProfile with debug endpoints off: got %v, want FailedPrecondition", err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SetURLStatus turns a link off, for instance over abuse, or back on.
+// Disabled links keep their stats and history but stop resolving. The
+// change is written to storage first, then the link is dropped from memory
+// and the cache before returning, so the next lookup on this replica sees
+// it whatever the cache TTL. Other replicas see it once their in-memory
+// copy is evicted, as with deletes.
+func (s *urlServer) SetURLStatus(ctx context.Context, req *url_service.SetURLStatusRequest) (*url_service.SetURLStatusResponse, error) {
+	logf(ctx, "SetURLStatus request for %s, active %t", req.ShortCode, req.Active)
+
+	if req.ShortCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "short code is required")
+	}
+	if err := s.checkOwner(ctx, req.ShortCode); err != nil {
+		return nil, err
+	}
+
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	_, err := s.storageClient.SetURLStatus(storageCtx, &storage_service.SetURLStatusRequest{
+		ShortCode: req.ShortCode,
+		Active:    req.Active,
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil, status.Error(codes.NotFound, "URL not found")
+	} else if err != nil {
+		logf(ctx, "Failed to set URL status in storage: %v", err)
+		return nil, status.Error(codes.Unavailable, "failed to set URL status in storage")
+	}
+
+	s.urls.Remove(req.ShortCode)
+	s.invalidateCache(detach(ctx), req.ShortCode)
+
+	logf(ctx, "URL %s is now active: %t", req.ShortCode, req.Active)
+	return &url_service.SetURLStatusResponse{
+		ShortCode: req.ShortCode,
+		Active:    req.Active,
+	}, nil
+}
+
+// disabledResponse answers a lookup of a disabled link, redirecting to its
+// fallback if it has one. Such lookups don't count as clicks.
+func (s *urlServer) disabledResponse(ctx context.Context, shortCode string, entry urlEntry) *url_service.GetOriginalResponse {
+	logf(ctx, "URL %s is disabled", shortCode)
+	if resp := s.fallbackResponse(ctx, shortCode, entry.fallbackURL, resolutionDisabled); resp != nil {
+		return resp
+	}
+	return &url_service.GetOriginalResponse{Disabled: true, Resolution: resolutionDisabled}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (f *fakeStorage) SetURLStatus(ctx context.Context, req *storage_service.SetURLStatusRequest) (*storage_service.SetURLStatusResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.urls[req.ShortCode]; !ok {
+		return nil, status.Errorf(codes.NotFound, "URL not found: %s", req.ShortCode)
+	}
+	f.disabled[req.ShortCode] = !req.Active
+	return &storage_service.SetURLStatusResponse{Active: req.Active}, nil
+}
+
+func TestSetURLStatusTakesEffectImmediately(t *testing.T) {
+	s, storage, cache := newTestServer(t, map[string]string{"CACHE_TTL": "24h"})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "abuse", OriginalUrl: "https://example.com", UserId: "alice"})
+	cache.set("url:abuse", "https://example.com")
+	ctx := context.Background()
+	lookup := &url_service.GetOriginalRequest{ShortCode: "abuse"}
+
+	if got, err := s.GetOriginalURL(ctx, lookup); err != nil || got.OriginalUrl != "https://example.com" {
+		t.Fatalf("GetOriginalURL = %v, %v", got, err)
+	}
+
+	// Only the owner turns it off
+	if _, err := s.SetURLStatus(withKey(ctx, "mallory-key", "mallory"), &url_service.SetURLStatusRequest{ShortCode: "abuse"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("SetURLStatus by another user: got %v, want PermissionDenied", err)
+	}
+	owner := withKey(ctx, "alice-key", "alice")
+	if resp, err := s.SetURLStatus(owner, &url_service.SetURLStatusRequest{ShortCode: "abuse"}); err != nil || resp.Active {
+		t.Fatalf("SetURLStatus = %v, %v", resp, err)
+	}
+
+	// The day-long cache entry and memory don't outlive the change
+	if _, ok := cache.entry("url:abuse"); ok {
+		t.Error("cache still holds the disabled URL")
+	}
+	if s.urls.Contains("abuse") {
+		t.Error("memory still holds the disabled URL")
+	}
+	got, err := s.GetOriginalURL(ctx, lookup)
+	if err != nil || !got.Disabled || got.OriginalUrl != "" || got.Resolution != resolutionDisabled {
+		t.Errorf("GetOriginalURL of a disabled URL = %v, %v, want disabled", got, err)
+	}
+	// Not even once it is back in memory
+	if got, err := s.GetOriginalURL(ctx, lookup); err != nil || !got.Disabled {
+		t.Errorf("second GetOriginalURL of a disabled URL = %v, %v, want disabled", got, err)
+	}
+
+	if resp, err := s.SetURLStatus(owner, &url_service.SetURLStatusRequest{ShortCode: "abuse", Active: true}); err != nil || !resp.Active {
+		t.Fatalf("SetURLStatus = %v, %v", resp, err)
+	}
+	if got, err := s.GetOriginalURL(ctx, lookup); err != nil || got.Disabled || got.OriginalUrl != "https://example.com" {
+		t.Errorf("GetOriginalURL of a reactivated URL = %v, %v", got, err)
+	}
+
+	if _, err := s.SetURLStatus(ctx, &url_service.SetURLStatusRequest{ShortCode: "ghost"}); status.Code(err) != codes.NotFound {
+		t.Errorf("SetURLStatus of an unknown code: got %v, want NotFound", err)
+	}
+	if _, err := s.SetURLStatus(ctx, &url_service.SetURLStatusRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("SetURLStatus without a code: got %v, want InvalidArgument", err)
+	}
+}
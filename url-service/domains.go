@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Domain policies. With a blocklist the blocked domains in storage can't be
+// shortened; with an allowlist, for locked-down deployments, they are the
+// only destinations that can.
+const (
+	domainPolicyBlocklist = "blocklist"
+	domainPolicyAllowlist = "allowlist"
+)
+
+const defaultDomainRulesRefresh = time.Minute
+
+// domainRules is url-service's copy of the blocked domains, reloaded from
+// storage every DOMAIN_RULES_REFRESH_INTERVAL so replicas pick up changes
+// without a restart.
+type domainRules struct {
+	allowlist atomic.Bool
+
+	mu      sync.RWMutex
+	hosts   map[string]bool
+	regexes []*regexp.Regexp
+
+	rejected atomic.Int64
+}
+
+func newDomainRules(policy string) *domainRules {
+	r := &domainRules{hosts: make(map[string]bool)}
+	r.SetPolicy(policy)
+	return r
+}
+
+// SetPolicy switches between blocking and allowing the listed domains.
+func (r *domainRules) SetPolicy(policy string) {
+	r.allowlist.Store(policy == domainPolicyAllowlist)
+}
+
+// Set replaces the rules. Regexes that don't compile, which storage already
+// rejects, are skipped.
+func (r *domainRules) Set(domains []*storage_service.BlockedDomain) {
+	hosts := make(map[string]bool, len(domains))
+	var regexes []*regexp.Regexp
+	for _, d := range domains {
+		if !d.Regex {
+			hosts[d.Pattern] = true
+			continue
+		}
+		re, err := regexp.Compile(d.Pattern)
+		if err != nil {
+			log.Printf("Warning: skipping invalid blocked domain regex %q: %v", d.Pattern, err)
+			continue
+		}
+		regexes = append(regexes, re)
+	}
+
+	r.mu.Lock()
+	r.hosts = hosts
+	r.regexes = regexes
+	r.mu.Unlock()
+}
+
+// Check returns PermissionDenied if the rules don't allow u to be shortened.
+func (r *domainRules) Check(u *url.URL) error {
+	matched, allowlist := r.matches(u), r.allowlist.Load()
+	switch {
+	case matched && !allowlist:
+		r.rejected.Add(1)
+		return status.Errorf(codes.PermissionDenied, "destination %s is blocked", u.Hostname())
+	case !matched && allowlist:
+		r.rejected.Add(1)
+		return status.Errorf(codes.PermissionDenied, "destination %s is not on the allowlist", u.Hostname())
+	}
+	return nil
+}
+
+// matches reports whether u's host or one of its parent domains is listed,
+// or whether a listed regex matches u.
+func (r *domainRules) matches(u *url.URL) bool {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for h := host; h != ""; {
+		if r.hosts[h] {
+			return true
+		}
+		_, parent, ok := strings.Cut(h, ".")
+		if !ok {
+			break
+		}
+		h = parent
+	}
+
+	rawURL := u.String()
+	for _, re := range r.regexes {
+		if re.MatchString(rawURL) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rejected returns how many destinations the rules have turned down.
+func (r *domainRules) Rejected() int64 {
+	return r.rejected.Load()
+}
+
+// refreshDomainRules reloads the rules from storage. On failure the current
+// rules stay in place.
+func (s *urlServer) refreshDomainRules(ctx context.Context) error {
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	resp, err := s.storageClient.ListBlockedDomains(storageCtx, &storage_service.ListBlockedDomainsRequest{})
+	if err != nil {
+		return err
+	}
+	s.domains.Set(resp.Domains)
+	return nil
+}
+
+// runDomainRules refreshes the rules every DOMAIN_RULES_REFRESH_INTERVAL,
+// as of each refresh, until ctx is done.
+func (s *urlServer) runDomainRules(ctx context.Context) {
+	for {
+		timer := time.NewTimer(s.live().domainRulesRefresh)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			if err := s.refreshDomainRules(ctx); err != nil {
+				log.Printf("Warning: failed to refresh blocked domains: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (f *fakeStorage) ListBlockedDomains(ctx context.Context, req *storage_service.ListBlockedDomainsRequest) (*storage_service.ListBlockedDomainsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &storage_service.ListBlockedDomainsResponse{Domains: f.blockedDomains}, nil
+}
+
+// setBlockedDomains replaces what ListBlockedDomains returns.
+func (f *fakeStorage) setBlockedDomains(domains ...*storage_service.BlockedDomain) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.blockedDomains = domains
+}
+
+func TestDomainRulesMatching(t *testing.T) {
+	rules := newDomainRules(domainPolicyBlocklist)
+	rules.Set([]*storage_service.BlockedDomain{
+		{Pattern: "evil.example"},
+		{Pattern: "phish.test"},
+		{Pattern: `^https?://[^/]+/wp-login\.php`, Regex: true},
+		{Pattern: "([unclosed", Regex: true},
+	})
+	tests := []struct {
+		rawURL  string
+		blocked bool
+	}{
+		{"https://evil.example/login", true},
+		{"https://login.evil.example/", true},
+		{"https://a.b.c.evil.example/", true},
+		{"https://EVIL.Example./", true},
+		{"https://evil.example:8443/", true},
+		{"https://phish.test", true},
+		{"https://notevil.example/", false},
+		{"https://evil.example.org/", false},
+		{"https://example/", false},
+		{"https://blog.example.com/wp-login.php", true},
+		{"https://blog.example.com/docs/wp-login.php", false},
+		{"https://example.com/", false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.rawURL)
+		if err != nil {
+			t.Fatalf("parse %s: %v", tt.rawURL, err)
+		}
+		err = rules.Check(u)
+		if blocked := status.Code(err) == codes.PermissionDenied; blocked != tt.blocked {
+			t.Errorf("Check(%s) = %v, want blocked %v", tt.rawURL, err, tt.blocked)
+		}
+	}
+
+	// The allowlist lets through what the blocklist stops, subdomains too
+	rules.SetPolicy(domainPolicyAllowlist)
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.rawURL)
+		err := rules.Check(u)
+		if blocked := status.Code(err) == codes.PermissionDenied; blocked == tt.blocked {
+			t.Errorf("allowlist Check(%s) = %v, want blocked %v", tt.rawURL, err, !tt.blocked)
+		}
+	}
+	if got := rules.Rejected(); got != int64(len(tests)) {
+		t.Errorf("Rejected = %d, want %d, one per turned down URL", got, len(tests))
+	}
+}
+
+func TestShortenURLBlockedDomains(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true", "DOMAIN_RULES_REFRESH_INTERVAL": "10ms"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.runDomainRules(ctx)
+
+	shorten := func(rawURL string) error {
+		_, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: rawURL})
+		return err
+	}
+	// waitFor retries rawURL until the refreshed rules give want
+	waitFor := func(rawURL string, want codes.Code) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			err := shorten(rawURL)
+			if status.Code(err) == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("ShortenURL(%s) = %v, want %v after a refresh", rawURL, err, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if err := shorten("https://login.evil.example/"); err != nil {
+		t.Fatalf("ShortenURL before blocking: %v", err)
+	}
+	storage.setBlockedDomains(&storage_service.BlockedDomain{Pattern: "evil.example"})
+	waitFor("https://login.evil.example/", codes.PermissionDenied)
+	if err := shorten("https://example.com/"); err != nil {
+		t.Errorf("ShortenURL of another domain: %v", err)
+	}
+	rejected := gathered(t, s.metrics.registry, "url_service_blocked_destinations_total")[""]
+	if rejected < 1 {
+		t.Errorf("blocked destinations metric %v, want at least 1", rejected)
+	}
+
+	// Unblocking reaches the replica the same way
+	storage.setBlockedDomains()
+	waitFor("https://login.evil.example/", codes.OK)
+}
+
+func TestShortenURLAllowlist(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true", "DOMAIN_POLICY": domainPolicyAllowlist})
+	storage.setBlockedDomains(&storage_service.BlockedDomain{Pattern: "corp.example"})
+	ctx := context.Background()
+	if err := s.refreshDomainRules(ctx); err != nil {
+		t.Fatalf("refreshDomainRules: %v", err)
+	}
+
+	tests := []struct {
+		rawURL string
+		want   codes.Code
+	}{
+		{"https://corp.example/handbook", codes.OK},
+		{"https://wiki.corp.example/", codes.OK},
+		{"https://example.com/", codes.PermissionDenied},
+		{"https://corp.example.attacker.test/", codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: tt.rawURL}); status.Code(err) != tt.want {
+			t.Errorf("ShortenURL(%s) = %v, want %v", tt.rawURL, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event brokers url-service can publish link events to.
+const (
+	eventsBrokerNATS  = "nats"
+	eventsBrokerKafka = "kafka"
+)
+
+// Event types.
+const (
+	eventURLCreated = "url.created"
+	eventURLClicked = "url.clicked"
+	eventURLDeleted = "url.deleted"
+)
+
+// eventSchemaVersion is bumped only for changes consumers have to handle;
+// adding fields isn't one.
+const eventSchemaVersion = 1
+
+const (
+	defaultEventsQueueSize     = 10000
+	defaultEventsBatchSize     = 100
+	defaultEventsFlushInterval = time.Second
+	defaultEventsTimeout       = 5 * time.Second
+)
+
+// urlEvent is the JSON published for each event. Its fields are part of the
+// service's interface, like metric names.
+type urlEvent struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	Version     int    `json:"version"`
+	Time        string `json:"time"` // RFC3339 with nanoseconds
+	ShortCode   string `json:"short_code"`
+	TenantID    string `json:"tenant_id,omitempty"`
+	OriginalURL string `json:"original_url,omitempty"` // url.created
+	Owner       string `json:"owner,omitempty"`        // url.created, the API key's user
+	ExpiresAt   string `json:"expires_at,omitempty"`   // url.created
+	Referrer    string `json:"referrer,omitempty"`     // url.clicked
+	Country     string `json:"country,omitempty"`      // url.clicked
+	Bot         bool   `json:"bot,omitempty"`          // url.clicked, not in click_count
+	Variant     string `json:"variant,omitempty"`      // url.clicked, on split links
+	Rule        string `json:"rule,omitempty"`         // url.clicked, on links with rules
+}
+
+// newURLEvent builds an event on the code keyed by key.
+func newURLEvent(eventType, key string) *urlEvent {
+	tenant, shortCode := splitTenantKey(key)
+	return &urlEvent{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		Version:   eventSchemaVersion,
+		Time:      time.Now().UTC().Format(time.RFC3339Nano),
+		ShortCode: shortCode,
+		TenantID:  tenant,
+	}
+}
+
+// eventSink delivers batches of events to a broker, in order.
+type eventSink interface {
+	Publish(ctx context.Context, events []*urlEvent) error
+	Close() error
+}
+
+// eventPublisher queues events and hands them to its sink in batches from a
+// single goroutine, so the requests raising them never wait on the broker
+// and events on the same code keep their order. When the queue is full or
+// the broker fails, events are dropped and counted. A nil publisher, used
+// when EVENTS_BROKER is unset, drops everything silently.
+type eventPublisher struct {
+	sink          eventSink
+	queue         chan *urlEvent
+	batchSize     int
+	flushInterval time.Duration
+	timeout       time.Duration
+	done          chan struct{}
+
+	published atomic.Int64
+	dropped   atomic.Int64
+}
+
+// newEventPublisher returns the publisher for cfg.EventsBroker, or nil if
+// it is unset.
+func newEventPublisher(cfg Config) (*eventPublisher, error) {
+	var sink eventSink
+	switch cfg.EventsBroker {
+	case "":
+		return nil, nil
+	case eventsBrokerNATS:
+		var err error
+		if sink, err = newNATSSink(cfg.NATSURL, cfg.EventsSubjectPrefix, cfg.EventsTimeout); err != nil {
+			return nil, err
+		}
+	case eventsBrokerKafka:
+		sink = newKafkaSink(cfg.KafkaBrokers, cfg.EventsTopic, cfg.EventsBatchSize, cfg.EventsTimeout)
+	default:
+		return nil, fmt.Errorf("unknown EVENTS_BROKER %q", cfg.EventsBroker)
+	}
+
+	return &eventPublisher{
+		sink:          sink,
+		queue:         make(chan *urlEvent, cfg.EventsQueueSize),
+		batchSize:     cfg.EventsBatchSize,
+		flushInterval: cfg.EventsFlushInterval,
+		timeout:       cfg.EventsTimeout,
+		done:          make(chan struct{}),
+	}, nil
+}
+
+// Publish queues e without blocking.
+func (p *eventPublisher) Publish(e *urlEvent) {
+	if p == nil {
+		return
+	}
+	select {
+	case p.queue <- e:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+// Run sends queued events until ctx is cancelled, then sends what is left,
+// for up to the publish timeout, and closes the sink.
+func (p *eventPublisher) Run(ctx context.Context) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*urlEvent, 0, p.batchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			// A broker that is down fails the rest fast instead of holding
+			// up shutdown for a timeout per batch
+			drainCtx, cancel := context.WithTimeout(context.Background(), p.timeout)
+			for len(p.queue) > 0 {
+				batch = append(batch, <-p.queue)
+				if len(batch) == p.batchSize {
+					batch = p.flush(drainCtx, batch)
+				}
+			}
+			p.flush(drainCtx, batch)
+			cancel()
+			if err := p.sink.Close(); err != nil {
+				log.Printf("Warning: failed to close event broker connection: %v", err)
+			}
+			return
+		case e := <-p.queue:
+			batch = append(batch, e)
+			if len(batch) == p.batchSize {
+				batch = p.flush(context.Background(), batch)
+			}
+		case <-ticker.C:
+			batch = p.flush(context.Background(), batch)
+		}
+	}
+}
+
+// flush sends batch and returns it emptied for reuse.
+func (p *eventPublisher) flush(ctx context.Context, batch []*urlEvent) []*urlEvent {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	if err := p.sink.Publish(ctx, batch); err != nil {
+		log.Printf("Warning: dropped %d events, publishing failed: %v", len(batch), err)
+		p.dropped.Add(int64(len(batch)))
+	} else {
+		p.published.Add(int64(len(batch)))
+	}
+	clear(batch)
+	return batch[:0]
+}
+
+// Wait blocks until Run has sent its last batch.
+func (p *eventPublisher) Wait() {
+	if p == nil {
+		return
+	}
+	<-p.done
+}
+
+// Published returns how many events the broker has accepted.
+func (p *eventPublisher) Published() int64 {
+	if p == nil {
+		return 0
+	}
+	return p.published.Load()
+}
+
+// Dropped returns how many events were lost to a full queue or a failing
+// broker.
+func (p *eventPublisher) Dropped() int64 {
+	if p == nil {
+		return 0
+	}
+	return p.dropped.Load()
+}
+
+// publishCreated raises url.created for a new link.
+func (s *urlServer) publishCreated(ctx context.Context, shortCode, originalURL string, expiresAt time.Time) {
+	e := newURLEvent(eventURLCreated, shortCode)
+	e.OriginalURL = originalURL
+	e.Owner = userID(ctx)
+	e.ExpiresAt = formatOptionalTime(expiresAt)
+	s.events.Publish(e)
+}
+
+// publishDeleted raises url.deleted for a deleted or purged link.
+func (s *urlServer) publishDeleted(shortCode string) {
+	s.events.Publish(newURLEvent(eventURLDeleted, shortCode))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaBatchTimeout bounds how long the writer holds a partition's records
+// for more. The publisher already hands it whole batches, which the hash
+// balancer splits by partition, so it shouldn't wait long for the rest.
+const kafkaBatchTimeout = 10 * time.Millisecond
+
+// kafkaSink produces events to a Kafka topic, one produce request per
+// partition and batch. Records are keyed by short code and placed by hash,
+// so the events of a code land on one partition and stay in order.
+type kafkaSink struct {
+	writer *kafka.Writer
+}
+
+// newKafkaSink produces to topic on the brokers in brokers, a
+// comma-separated list used to discover the rest of the cluster. Nothing is
+// dialed until the first batch, so the cluster doesn't have to be up before
+// url-service.
+func newKafkaSink(brokers, topic string, batchSize int, timeout time.Duration) *kafkaSink {
+	return &kafkaSink{writer: &kafka.Writer{
+		Addr:         kafka.TCP(splitAddrs(brokers)...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchSize:    batchSize,
+		BatchTimeout: kafkaBatchTimeout,
+		WriteTimeout: timeout,
+		// The publisher drops a failed batch rather than holding up the
+		// ones behind it
+		MaxAttempts: 1,
+	}}
+}
+
+func (s *kafkaSink) Publish(ctx context.Context, events []*urlEvent) error {
+	msgs := make([]kafka.Message, len(events))
+	for i, e := range events {
+		value, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		msgs[i] = kafka.Message{Key: []byte(e.ShortCode), Value: value}
+	}
+	return s.writer.WriteMessages(ctx, msgs...)
+}
+
+func (s *kafkaSink) Close() error {
+	return s.writer.Close()
+}
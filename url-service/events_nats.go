@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsSink publishes each event to <prefix>.<type>, url.created to
+// urlshortener.url.created for instance, so consumers can subscribe to the
+// types they want.
+type natsSink struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+// newNATSSink connects to the NATS servers in url, a comma-separated list.
+// If none is reachable yet it keeps trying in the background, buffering
+// what is published in the meantime, so the broker doesn't have to be up
+// before url-service.
+func newNATSSink(url, prefix string, timeout time.Duration) (*natsSink, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("url-service"),
+		nats.Timeout(timeout),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("Warning: disconnected from NATS: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(c *nats.Conn) {
+			log.Printf("Reconnected to NATS at %s", c.ConnectedUrl())
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS at %s: %v", url, err)
+	}
+	return &natsSink{conn: conn, prefix: prefix}, nil
+}
+
+func (s *natsSink) Publish(ctx context.Context, events []*urlEvent) error {
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if err := s.conn.Publish(s.prefix+"."+e.Type, data); err != nil {
+			return err
+		}
+	}
+	// Publish only buffers; a round trip to the server confirms it got them.
+	// While reconnecting, the client holds them until it is back.
+	if s.conn.IsConnected() {
+		return s.conn.FlushWithContext(ctx)
+	}
+	return nil
+}
+
+// Close sends what is still buffered before disconnecting.
+func (s *natsSink) Close() error {
+	if err := s.conn.Drain(); err != nil {
+		s.conn.Close()
+		return err
+	}
+	return nil
+}
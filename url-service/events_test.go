@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"github.com/segmentio/kafka-go"
+	kafkaprotocol "github.com/segmentio/kafka-go/protocol"
+	"github.com/segmentio/kafka-go/protocol/metadata"
+	"github.com/segmentio/kafka-go/protocol/produce"
+)
+
+// fakeSink records the batches published to it, failing while err is set.
+type fakeSink struct {
+	mu      sync.Mutex
+	batches [][]*urlEvent
+	err     error
+	closed  bool
+}
+
+func (f *fakeSink) Publish(ctx context.Context, events []*urlEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.batches = append(f.batches, append([]*urlEvent(nil), events...))
+	return nil
+}
+
+func (f *fakeSink) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+// events returns every event published so far, in order.
+func (f *fakeSink) events() []*urlEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var all []*urlEvent
+	for _, batch := range f.batches {
+		all = append(all, batch...)
+	}
+	return all
+}
+
+// newTestEventPublisher returns a publisher to sink, not yet running.
+func newTestEventPublisher(sink eventSink, queueSize, batchSize int) *eventPublisher {
+	return &eventPublisher{
+		sink:          sink,
+		queue:         make(chan *urlEvent, queueSize),
+		batchSize:     batchSize,
+		flushInterval: 10 * time.Millisecond,
+		timeout:       time.Second,
+		done:          make(chan struct{}),
+	}
+}
+
+func TestEventPublisherOrderPerCode(t *testing.T) {
+	sink := &fakeSink{}
+	p := newTestEventPublisher(sink, 1000, 7)
+	ctx, cancel := context.WithCancel(context.Background())
+	go p.Run(ctx)
+
+	for i := 0; i < 300; i++ {
+		e := newURLEvent(eventURLClicked, "code"+strconv.Itoa(i%3))
+		e.Referrer = strconv.Itoa(i)
+		p.Publish(e)
+	}
+	// What is still queued is sent on the way out
+	cancel()
+	p.Wait()
+
+	sink.mu.Lock()
+	batches := sink.batches
+	sink.mu.Unlock()
+	last := map[string]int{}
+	for i, batch := range batches {
+		if len(batch) > 7 {
+			t.Errorf("batch %d has %d events, want at most 7", i, len(batch))
+		}
+		for _, e := range batch {
+			seq, _ := strconv.Atoi(e.Referrer)
+			if prev, ok := last[e.ShortCode]; ok && seq <= prev {
+				t.Errorf("%s: event %d after %d", e.ShortCode, seq, prev)
+			}
+			last[e.ShortCode] = seq
+		}
+	}
+	if got := len(sink.events()); got != 300 || p.Published() != 300 || p.Dropped() != 0 {
+		t.Errorf("sent %d events, %d published and %d dropped, want all 300 published", got, p.Published(), p.Dropped())
+	}
+	if !sink.closed {
+		t.Error("sink not closed after Run returned")
+	}
+}
+
+func TestEventPublisherDrops(t *testing.T) {
+	// A full queue drops rather than blocking the caller
+	p := newTestEventPublisher(&fakeSink{}, 2, 10)
+	for i := 0; i < 5; i++ {
+		p.Publish(newURLEvent(eventURLCreated, "abc"))
+	}
+	if n := p.Dropped(); n != 3 {
+		t.Errorf("dropped %d events past a queue of 2, want 3", n)
+	}
+
+	// So does a broker that is down, a batch at a time
+	sink := &fakeSink{err: errors.New("connection refused")}
+	p = newTestEventPublisher(sink, 10, 4)
+	for i := 0; i < 4; i++ {
+		p.Publish(newURLEvent(eventURLCreated, "abc"))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go p.Run(ctx)
+	waitFor(t, "failed batch", func() bool { return p.Dropped() == 4 })
+	cancel()
+	p.Wait()
+	if n := p.Published(); n != 0 {
+		t.Errorf("published %d events to a failing broker", n)
+	}
+}
+
+func TestEventPublisherUnconfigured(t *testing.T) {
+	p, err := newEventPublisher(Config{})
+	if err != nil || p != nil {
+		t.Fatalf("newEventPublisher without a broker = %v, %v, want nil", p, err)
+	}
+	// Every method is a no-op on the nil publisher
+	p.Publish(newURLEvent(eventURLCreated, "abc"))
+	p.Wait()
+	if p.Published() != 0 || p.Dropped() != 0 {
+		t.Errorf("nil publisher counted %d published, %d dropped", p.Published(), p.Dropped())
+	}
+
+	if _, err := newEventPublisher(Config{EventsBroker: "rabbitmq"}); err == nil {
+		t.Error("newEventPublisher accepted an unknown broker")
+	}
+}
+
+func TestServerPublishesEvents(t *testing.T) {
+	s, _, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+	sink := &fakeSink{}
+	s.events = newTestEventPublisher(sink, 100, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	go s.events.Run(ctx)
+
+	alice := withKey(context.Background(), "alice-key", "alice")
+	resp, err := s.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: "https://example.com/a", CustomAlias: "evt"})
+	if err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	if _, err := s.GetOriginalURL(context.Background(), &url_service.GetOriginalRequest{ShortCode: resp.ShortCode, Country: "NL", Referrer: "https://news.example"}); err != nil {
+		t.Fatalf("GetOriginalURL: %v", err)
+	}
+	if _, err := s.DeleteURL(alice, &url_service.DeleteURLRequest{ShortCode: resp.ShortCode}); err != nil {
+		t.Fatalf("DeleteURL: %v", err)
+	}
+	cancel()
+	s.events.Wait()
+
+	events := sink.events()
+	if len(events) != 3 {
+		t.Fatalf("published %d events, want 3", len(events))
+	}
+	created, clicked, deleted := events[0], events[1], events[2]
+	if created.Type != eventURLCreated || created.OriginalURL != "https://example.com/a" || created.Owner != "alice" {
+		t.Errorf("first event %+v, want url.created by alice", created)
+	}
+	if clicked.Type != eventURLClicked || clicked.Country != "NL" || clicked.Referrer != "https://news.example" || clicked.Bot {
+		t.Errorf("second event %+v, want url.clicked from NL", clicked)
+	}
+	if deleted.Type != eventURLDeleted {
+		t.Errorf("third event %+v, want url.deleted", deleted)
+	}
+	ids := map[string]bool{}
+	for _, e := range events {
+		if e.ShortCode != "evt" || e.Version != eventSchemaVersion || e.ID == "" || ids[e.ID] {
+			t.Errorf("event %+v lacks its code, version or a unique ID", e)
+		}
+		ids[e.ID] = true
+		if _, err := time.Parse(time.RFC3339Nano, e.Time); err != nil {
+			t.Errorf("%s: time %q: %v", e.Type, e.Time, err)
+		}
+	}
+	if got := gathered(t, s.metrics.registry, "url_service_events_published_total")[""]; got != 3 {
+		t.Errorf("url_service_events_published_total %v, want 3", got)
+	}
+}
+
+func TestEventSchema(t *testing.T) {
+	e := newURLEvent(eventURLCreated, tenantKey("acme", "abc"))
+	e.ID, e.Time, e.OriginalURL = "id-1", "2026-01-02T03:04:05Z", "https://example.com"
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `{"id":"id-1","type":"url.created","version":1,"time":"2026-01-02T03:04:05Z","short_code":"abc","tenant_id":"acme","original_url":"https://example.com"}`
+	if string(data) != want {
+		t.Errorf("event JSON\n%s\nwant\n%s", data, want)
+	}
+}
+
+// fakeKafka is a kafka.RoundTripper standing in for a cluster with one
+// broker and a topic of partitions partitions. It records the key and value
+// of every record produced, by partition, and fails produce requests with
+// err while it is set.
+type fakeKafka struct {
+	topic      string
+	partitions int
+
+	mu       sync.Mutex
+	produced map[int32][][2]string
+	err      kafka.Error
+}
+
+func (f *fakeKafka) RoundTrip(ctx context.Context, addr net.Addr, req kafkaprotocol.Message) (kafkaprotocol.Message, error) {
+	switch req := req.(type) {
+	case *metadata.Request:
+		resp := &metadata.Response{Brokers: []metadata.ResponseBroker{{NodeID: 1, Host: "127.0.0.1", Port: 9092}}}
+		topic := metadata.ResponseTopic{Name: f.topic}
+		for i := 0; i < f.partitions; i++ {
+			topic.Partitions = append(topic.Partitions, metadata.ResponsePartition{PartitionIndex: int32(i), LeaderID: 1})
+		}
+		resp.Topics = append(resp.Topics, topic)
+		return resp, nil
+	case *produce.Request:
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		resp := &produce.Response{}
+		for _, t := range req.Topics {
+			rt := produce.ResponseTopic{Topic: t.Topic}
+			for _, p := range t.Partitions {
+				rt.Partitions = append(rt.Partitions, produce.ResponsePartition{Partition: p.Partition, ErrorCode: int16(f.err)})
+				for {
+					r, err := p.RecordSet.Records.ReadRecord()
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					if err != nil {
+						return nil, err
+					}
+					key, _ := kafkaprotocol.ReadAll(r.Key)
+					value, _ := kafkaprotocol.ReadAll(r.Value)
+					f.produced[p.Partition] = append(f.produced[p.Partition], [2]string{string(key), string(value)})
+				}
+			}
+			resp.Topics = append(resp.Topics, rt)
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("unexpected kafka request %T", req)
+}
+
+func TestKafkaSink(t *testing.T) {
+	cluster := &fakeKafka{topic: "url-events", partitions: 4, produced: map[int32][][2]string{}}
+	sink := newKafkaSink("127.0.0.1:9092", "url-events", 100, time.Second)
+	sink.writer.Transport = cluster
+	defer sink.Close()
+
+	var events []*urlEvent
+	for i := 0; i < 20; i++ {
+		events = append(events, newURLEvent(eventURLClicked, "code"+strconv.Itoa(i%5)))
+	}
+	if err := sink.Publish(context.Background(), events); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	// A code's events all land on one partition, in the order published
+	cluster.mu.Lock()
+	partitionOf := map[string]int32{}
+	seen := map[string][]string{}
+	total := 0
+	for partition, records := range cluster.produced {
+		for _, r := range records {
+			key, value := r[0], r[1]
+			if p, ok := partitionOf[key]; ok && p != partition {
+				t.Errorf("%s produced to partitions %d and %d", key, p, partition)
+			}
+			partitionOf[key] = partition
+			var e urlEvent
+			if err := json.Unmarshal([]byte(value), &e); err != nil || e.ShortCode != key {
+				t.Errorf("record keyed %s holds %s, %v", key, value, err)
+			}
+			seen[key] = append(seen[key], e.ID)
+			total++
+		}
+	}
+	cluster.mu.Unlock()
+	if total != len(events) {
+		t.Errorf("produced %d records, want %d", total, len(events))
+	}
+	want := map[string][]string{}
+	for _, e := range events {
+		want[e.ShortCode] = append(want[e.ShortCode], e.ID)
+	}
+	for key, ids := range want {
+		if !slices.Equal(seen[key], ids) {
+			t.Errorf("%s produced as %v, want %v", key, seen[key], ids)
+		}
+	}
+
+	// A batch the brokers refuse fails
+	cluster.mu.Lock()
+	cluster.err = kafka.NotEnoughReplicas
+	cluster.mu.Unlock()
+	if err := sink.Publish(context.Background(), events[:1]); err == nil {
+		t.Error("Publish succeeded with the brokers refusing the batch")
+	}
+}
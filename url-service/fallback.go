@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+)
+
+// Resolutions of a lookup, reported in GetOriginalResponse.resolution. The
+// last four say why a link no longer goes to its destination.
+const (
+	resolutionDestination = "destination"
+	resolutionComingSoon  = "coming_soon"
+	resolutionExpired     = "expired"
+	resolutionExhausted   = "exhausted"
+	resolutionDisabled    = "disabled"
+	resolutionDeleted     = "deleted"
+)
+
+// fallback returns where visitors of a link that no longer resolves go:
+// its own fallback URL, which was screened when the link was created, or
+// else DEFAULT_FALLBACK_URL. The default is checked against the blocked
+// domains on every use, since they change after startup. It returns "" if
+// there is no fallback.
+func (s *urlServer) fallback(ctx context.Context, fallbackURL string) string {
+	if fallbackURL != "" || s.defaultFallback == "" {
+		return fallbackURL
+	}
+	if err := s.validator.Validate(s.defaultFallback); err != nil {
+		logf(ctx, "Warning: not redirecting to DEFAULT_FALLBACK_URL: %v", err)
+		return ""
+	}
+	return s.defaultFallback
+}
+
+// fallbackResponse answers a lookup of a link that no longer resolves for
+// reason with a redirect to its fallback, or returns nil if it has none.
+// Such lookups don't count as clicks.
+func (s *urlServer) fallbackResponse(ctx context.Context, shortCode, fallbackURL, reason string) *url_service.GetOriginalResponse {
+	destination := s.fallback(ctx, fallbackURL)
+	if destination == "" {
+		return nil
+	}
+	logf(ctx, "URL %s is %s, redirecting to its fallback", shortCode, reason)
+	return &url_service.GetOriginalResponse{OriginalUrl: destination, Found: true, Resolution: reason}
+}
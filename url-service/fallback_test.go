@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (f *fakeStorage) ClaimClick(ctx context.Context, req *storage_service.ClaimClickRequest) (*storage_service.ClaimClickResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.urls[req.ShortCode]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "URL not found: %s", req.ShortCode)
+	}
+	if u.MaxClicks > 0 && f.clickCounts[req.ShortCode] >= u.MaxClicks {
+		return &storage_service.ClaimClickResponse{FallbackUrl: u.FallbackUrl}, nil
+	}
+	if !req.Peek {
+		f.clickCounts[req.ShortCode]++
+	}
+	return &storage_service.ClaimClickResponse{Claimed: true, Remaining: max(u.MaxClicks-f.clickCounts[req.ShortCode], 0)}, nil
+}
+
+func TestFallbackTriggers(t *testing.T) {
+	const (
+		own         = "https://example.com/own"
+		serverWide  = "https://example.com/default"
+		destination = "https://example.com/live"
+	)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	links := []*storage_service.SaveURLRequest{
+		{ShortCode: "live", OriginalUrl: destination},
+		{ShortCode: "expired", OriginalUrl: destination, ExpiresAt: past, FallbackUrl: own},
+		{ShortCode: "expired-bare", OriginalUrl: destination, ExpiresAt: past},
+		{ShortCode: "used", OriginalUrl: destination, MaxClicks: 1, FallbackUrl: own},
+		{ShortCode: "used-bare", OriginalUrl: destination, MaxClicks: 1},
+		{ShortCode: "off", OriginalUrl: destination, FallbackUrl: own},
+		{ShortCode: "off-bare", OriginalUrl: destination},
+		{ShortCode: "gone", OriginalUrl: destination, FallbackUrl: own},
+		{ShortCode: "gone-bare", OriginalUrl: destination},
+	}
+
+	tests := []struct {
+		code       string
+		withOwn    string // resolution, "" for an error
+		withoutAny string // with neither fallback
+		wantURL    string // with DEFAULT_FALLBACK_URL
+	}{
+		{"live", resolutionDestination, resolutionDestination, destination},
+		{"expired", resolutionExpired, resolutionExpired, own},
+		{"expired-bare", resolutionExpired, resolutionExpired, serverWide},
+		{"used", resolutionExhausted, resolutionExhausted, own},
+		{"used-bare", resolutionExhausted, resolutionExhausted, serverWide},
+		{"off", resolutionDisabled, resolutionDisabled, own},
+		{"off-bare", resolutionDisabled, resolutionDisabled, serverWide},
+		{"gone", resolutionDeleted, resolutionDeleted, own},
+		{"gone-bare", resolutionDeleted, "", serverWide},
+		{"ghost", "", "", ""},
+	}
+	for _, defaultFallback := range []string{serverWide, ""} {
+		s, storage, _ := newTestServer(t, map[string]string{"DEFAULT_FALLBACK_URL": defaultFallback})
+		for _, link := range links {
+			storage.put(link)
+		}
+		storage.mu.Lock()
+		storage.clickCounts["used"], storage.clickCounts["used-bare"] = 1, 1
+		storage.disabled["off"], storage.disabled["off-bare"] = true, true
+		for _, code := range []string{"gone", "gone-bare"} {
+			storage.deleted[code] = storage.urls[code]
+			delete(storage.urls, code)
+		}
+		storage.mu.Unlock()
+
+		for _, tt := range tests {
+			name := tt.code + " with DEFAULT_FALLBACK_URL " + defaultFallback
+			resp, err := s.GetOriginalURL(context.Background(), &url_service.GetOriginalRequest{ShortCode: tt.code})
+
+			wantURL, wantResolution := tt.wantURL, tt.withOwn
+			if defaultFallback == "" && wantURL == serverWide {
+				wantURL, wantResolution = "", tt.withoutAny
+			}
+			if wantResolution == "" {
+				if status.Code(err) != codes.NotFound {
+					t.Errorf("%s: got %v, %v, want NotFound", name, resp, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			if resp.Resolution != wantResolution || resp.OriginalUrl != wantURL || resp.Found != (wantURL != "") {
+				t.Errorf("%s: got %s to %q, found %v, want %s to %q", name, resp.Resolution, resp.OriginalUrl, resp.Found, wantResolution, wantURL)
+			}
+			// Without a fallback the reason is still flagged
+			if wantURL == "" && !resp.Expired && !resp.Exhausted && !resp.Disabled {
+				t.Errorf("%s: no fallback and no reason in %v", name, resp)
+			}
+		}
+		if n := storage.clicks("live"); n != 0 {
+			t.Errorf("DEFAULT_FALLBACK_URL %q: fallback lookups claimed %d clicks", defaultFallback, n)
+		}
+	}
+}
+
+func TestFallbackValidation(t *testing.T) {
+	s, _, _ := newTestServer(t, map[string]string{"DEFAULT_FALLBACK_URL": "https://evil.example/landing"})
+	ctx := context.Background()
+
+	// A link's own fallback is screened like its destination
+	for _, fallback := range []string{"javascript:alert(1)", "ftp://example.com/file"} {
+		_, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", FallbackUrl: fallback})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("fallback %s: got %v, want InvalidArgument", fallback, err)
+		}
+	}
+	s.domains.Set([]*storage_service.BlockedDomain{{Pattern: "evil.example"}})
+	_, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", FallbackUrl: "https://evil.example/x"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("blocked fallback: got %v, want PermissionDenied", err)
+	}
+
+	// The default is checked on use, since domains are blocked after startup
+	if got := s.fallback(ctx, ""); got != "" {
+		t.Errorf("fallback to a blocked default = %q, want none", got)
+	}
+	if got := s.fallback(ctx, "https://example.com/own"); got != "https://example.com/own" {
+		t.Errorf("own fallback = %q, want it over the default", got)
+	}
+
+	// Invalid defaults fail at startup
+	for _, fallback := range []string{"not a url", "javascript:alert(1)"} {
+		if _, err := loadConfig(nil, testEnv(map[string]string{"DEFAULT_FALLBACK_URL": fallback})); err == nil {
+			t.Errorf("loadConfig accepted DEFAULT_FALLBACK_URL %q", fallback)
+		}
+	}
+}
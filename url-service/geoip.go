@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// geoIP resolves client IPs to countries with a MaxMind DB file such as
+// GeoLite2-Country.mmdb. Only the country is ever passed on; the IP itself
+// is not recorded anywhere.
+type geoIP struct {
+	db                *maxminddb.Reader
+	trustForwardedFor bool
+}
+
+func openGeoIP(path string, trustForwardedFor bool) (*geoIP, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &geoIP{db: db, trustForwardedFor: trustForwardedFor}, nil
+}
+
+// CallerCountry returns the country of the caller of ctx's request, or ""
+// if it is unknown.
+func (g *geoIP) CallerCountry(ctx context.Context) string {
+	return g.Country(callerIP(ctx, g.trustForwardedFor))
+}
+
+// Country returns the ISO 3166-1 alpha-2 code of the country ip is in, or
+// "" if ip is invalid or not in the database. ip may be an
+// X-Forwarded-For list, whose first entry is the client.
+func (g *geoIP) Country(ip string) string {
+	ip, _, _ = strings.Cut(ip, ",")
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return ""
+	}
+	var country string
+	if err := g.db.Lookup(addr.Unmap()).DecodePath(&country, "country", "iso_code"); err != nil {
+		return ""
+	}
+	return country
+}
+
+func (g *geoIP) Close() error {
+	return g.db.Close()
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/prototext"
+)
+
+// mmdbNode is a node of a MaxMind DB search tree under construction. Each
+// side leads to another node, to the data at offset data-1, or nowhere.
+type mmdbNode struct {
+	next [2]*mmdbNode
+	data [2]int
+}
+
+// mmdbString encodes s as a MaxMind DB UTF-8 string.
+func mmdbString(s string) []byte {
+	return append([]byte{2<<5 | byte(len(s))}, s...)
+}
+
+// mmdbUint encodes n as a MaxMind DB unsigned integer of type typ.
+func mmdbUint(typ byte, n uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], n)
+	digits := strings.TrimLeft(string(b[:]), "\x00")
+	if typ < 8 {
+		return append([]byte{typ<<5 | byte(len(digits))}, digits...)
+	}
+	return append([]byte{byte(len(digits)), typ - 7}, digits...)
+}
+
+// writeTestGeoIPDB writes an IPv4 MaxMind DB mapping each prefix to a
+// country, like a tiny GeoLite2-Country, and returns its path.
+func writeTestGeoIPDB(t *testing.T, countries map[string]string) string {
+	t.Helper()
+	root := &mmdbNode{}
+	var data []byte
+	for prefix, country := range countries {
+		p := netip.MustParsePrefix(prefix)
+		offset := len(data)
+		data = append(data, 7<<5|1)
+		data = append(data, mmdbString("country")...)
+		data = append(data, 7<<5|1)
+		data = append(data, mmdbString("iso_code")...)
+		data = append(data, mmdbString(country)...)
+
+		ip, n := p.Addr().As4(), root
+		for i := 0; i < p.Bits(); i++ {
+			bit := ip[i/8] >> (7 - i%8) & 1
+			if i == p.Bits()-1 {
+				n.data[bit] = offset + 1
+				break
+			}
+			if n.next[bit] == nil {
+				n.next[bit] = &mmdbNode{}
+			}
+			n = n.next[bit]
+		}
+	}
+
+	var nodes []*mmdbNode
+	index := make(map[*mmdbNode]int)
+	var number func(n *mmdbNode)
+	number = func(n *mmdbNode) {
+		index[n] = len(nodes)
+		nodes = append(nodes, n)
+		for _, next := range n.next {
+			if next != nil {
+				number(next)
+			}
+		}
+	}
+	number(root)
+
+	// 24 bit records: a node, data 16 bytes past the tree, or no data
+	var file []byte
+	count := len(nodes)
+	for _, n := range nodes {
+		for side := 0; side < 2; side++ {
+			record := count
+			if n.next[side] != nil {
+				record = index[n.next[side]]
+			} else if n.data[side] > 0 {
+				record = count + 16 + n.data[side] - 1
+			}
+			file = append(file, byte(record>>16), byte(record>>8), byte(record))
+		}
+	}
+	file = append(file, make([]byte, 16)...)
+	file = append(file, data...)
+
+	file = append(file, "\xAB\xCD\xEFMaxMind.com"...)
+	file = append(file, 7<<5|9)
+	for _, field := range []struct {
+		key   string
+		value []byte
+	}{
+		{"node_count", mmdbUint(6, uint64(count))},
+		{"record_size", mmdbUint(5, 24)},
+		{"ip_version", mmdbUint(5, 4)},
+		{"database_type", mmdbString("Test-Country")},
+		{"languages", []byte{0, 4}},
+		{"binary_format_major_version", mmdbUint(5, 2)},
+		{"binary_format_minor_version", mmdbUint(5, 0)},
+		{"build_epoch", mmdbUint(9, 1700000000)},
+		{"description", []byte{7 << 5}},
+	} {
+		file = append(file, mmdbString(field.key)...)
+		file = append(file, field.value...)
+	}
+
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+	if err := os.WriteFile(path, file, 0o644); err != nil {
+		t.Fatalf("writing GeoIP database: %v", err)
+	}
+	return path
+}
+
+var testCountries = map[string]string{
+	"81.2.69.0/24":    "GB",
+	"216.160.83.0/24": "US",
+	"2.125.160.0/19":  "DE",
+}
+
+func TestGeoIPCountry(t *testing.T) {
+	g, err := openGeoIP(writeTestGeoIPDB(t, testCountries), false)
+	if err != nil {
+		t.Fatalf("openGeoIP: %v", err)
+	}
+	defer g.Close()
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"81.2.69.160", "GB"},
+		{"216.160.83.56", "US"},
+		{"2.125.170.1", "DE"},
+		{"::ffff:81.2.69.1", "GB"},
+		{"81.2.69.5, 10.0.0.1", "GB"},
+		{"10.0.0.1", ""},
+		{"81.2.70.1", ""},
+		{"not an ip", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := g.Country(tt.ip); got != tt.want {
+			t.Errorf("Country(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestClickRecordsCountryNotIP(t *testing.T) {
+	path := writeTestGeoIPDB(t, testCountries)
+	s, _, _ := newTestServer(t, map[string]string{"GEOIP_DB_PATH": path, "TRUST_FORWARDED_FOR": "true"})
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-forwarded-for", "216.160.83.56"))
+
+	tests := []struct {
+		name string
+		req  *url_service.GetOriginalRequest
+		want string
+	}{
+		{"looked up", &url_service.GetOriginalRequest{ShortCode: "abc", Referrer: "https://news.example/", UserAgent: "Mozilla/5.0"}, "US"},
+		{"given by the caller", &url_service.GetOriginalRequest{ShortCode: "abc", Country: "FR"}, "FR"},
+	}
+	for _, tt := range tests {
+		click := s.clickFromRequest(ctx, tt.req)
+		if click.Country != tt.want || click.Referrer != tt.req.Referrer || click.UserAgent != tt.req.UserAgent {
+			t.Errorf("%s: click %v, want country %s with the request's referrer and user agent", tt.name, click, tt.want)
+		}
+		if text := prototext.Format(click); strings.Contains(text, "216.160.83.56") {
+			t.Errorf("%s: click keeps the IP: %s", tt.name, text)
+		}
+	}
+
+	if _, err := openGeoIP(filepath.Join(t.TempDir(), "missing.mmdb"), false); err == nil {
+		t.Error("openGeoIP opened a missing file")
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
+	"google.golang.org/grpc"
+)
+
+// grpcWebRequestHeaders are the headers browsers may send cross-origin. The
+// wrapper only allows the ones listed, so the gRPC-Web protocol's own
+// headers have to be named alongside ours.
+var grpcWebRequestHeaders = []string{"content-type", "x-grpc-web", "x-user-agent", "grpc-timeout", apiKeyHeader, requestIDHeader}
+
+// newGRPCWebServer serves server's RPCs to browsers over gRPC-Web on port.
+// Calls go through server itself, so they pass the same interceptors as
+// native gRPC, authentication and rate limits included, and errors reach the
+// client as the usual grpc-status and grpc-message. Cross-origin calls are
+// allowed from allowedOrigins, where "*" allows any origin.
+func newGRPCWebServer(port string, server *grpc.Server, allowedOrigins []string) *http.Server {
+	origins := make(map[string]bool)
+	for _, origin := range allowedOrigins {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins[strings.TrimSuffix(origin, "/")] = true
+		}
+	}
+
+	wrapped := grpcweb.WrapServer(server,
+		grpcweb.WithOriginFunc(func(origin string) bool {
+			return origins["*"] || origins[origin]
+		}),
+		grpcweb.WithAllowedRequestHeaders(grpcWebRequestHeaders),
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Anything else would reach the gRPC server, which only speaks HTTP/2
+		if !wrapped.IsGrpcWebRequest(r) && !wrapped.IsAcceptableGrpcCorsRequest(r) {
+			http.NotFound(w, r)
+			return
+		}
+		wrapped.ServeHTTP(w, r)
+	})
+
+	return &http.Server{
+		Addr:    ":" + port,
+		Handler: handler,
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+)
+
+// newGRPCWebTestServer serves s over gRPC-Web, behind the same auth
+// interceptor as native gRPC with key s3cret, allowing origin.
+func newGRPCWebTestServer(t *testing.T, s *urlServer, origin string) *httptest.Server {
+	t.Helper()
+	keys, err := loadAPIKeys("web:s3cret", "", "")
+	if err != nil {
+		t.Fatalf("loadAPIKeys: %v", err)
+	}
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(authInterceptor(keys, false)))
+	url_service.RegisterURLServiceServer(server, s)
+	web := httptest.NewServer(newGRPCWebServer("0", server, []string{origin}).Handler)
+	t.Cleanup(web.Close)
+	return web
+}
+
+// grpcWebCall posts req to method the way a browser's gRPC-Web client does
+// and returns the status code and message, and the response if there is one.
+func grpcWebCall(t *testing.T, web *httptest.Server, method string, req, resp proto.Message, header http.Header) (codes.Code, string) {
+	t.Helper()
+	payload, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	frame := append([]byte{0, 0, 0, 0, 0}, payload...)
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+
+	httpReq, err := http.NewRequest(http.MethodPost, web.URL+method, bytes.NewReader(frame))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		httpReq.Header[k] = v
+	}
+	httpReq.Header.Set("Content-Type", "application/grpc-web+proto")
+	httpReq.Header.Set("X-Grpc-Web", "1")
+	httpResp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		t.Fatalf("POST %s: %v", method, err)
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		t.Fatalf("POST %s: HTTP %s", method, httpResp.Status)
+	}
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	// Errors before any message come back in the headers alone
+	trailers := map[string]string{
+		"grpc-status":  httpResp.Header.Get("Grpc-Status"),
+		"grpc-message": httpResp.Header.Get("Grpc-Message"),
+	}
+	for len(body) >= 5 {
+		flags, size := body[0], binary.BigEndian.Uint32(body[1:5])
+		data := body[5 : 5+size]
+		body = body[5+size:]
+		if flags&0x80 == 0 {
+			if err := proto.Unmarshal(data, resp); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\r\n") {
+			if k, v, ok := strings.Cut(line, ":"); ok {
+				trailers[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+			}
+		}
+	}
+	code, err := strconv.Atoi(trailers["grpc-status"])
+	if err != nil {
+		t.Fatalf("POST %s: grpc-status %q", method, trailers["grpc-status"])
+	}
+	return codes.Code(code), trailers["grpc-message"]
+}
+
+func TestGRPCWebShortenURL(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+	web := newGRPCWebTestServer(t, s, "https://app.example")
+	req := &url_service.ShortenRequest{OriginalUrl: "https://example.com/web", CustomAlias: "fromweb"}
+
+	// The auth interceptor applies as it does to native calls
+	var resp url_service.ShortenResponse
+	if code, msg := grpcWebCall(t, web, url_service.URLService_ShortenURL_FullMethodName, req, &resp, nil); code != codes.Unauthenticated {
+		t.Errorf("ShortenURL without a key: %v %q, want Unauthenticated", code, msg)
+	}
+
+	header := http.Header{"X-Api-Key": {"s3cret"}}
+	code, msg := grpcWebCall(t, web, url_service.URLService_ShortenURL_FullMethodName, req, &resp, header)
+	if code != codes.OK || resp.ShortCode != "fromweb" || resp.OriginalUrl != "https://example.com/web" {
+		t.Fatalf("ShortenURL = %v, %v %q", &resp, code, msg)
+	}
+	if u, ok := storage.url("fromweb"); !ok || u.OriginalUrl != "https://example.com/web" {
+		t.Errorf("storage holds %v, %v", u, ok)
+	}
+
+	// Status codes come through as is
+	code, msg = grpcWebCall(t, web, url_service.URLService_ShortenURL_FullMethodName, req, &resp, header)
+	if code != codes.AlreadyExists || msg == "" {
+		t.Errorf("ShortenURL of a taken alias: %v %q, want AlreadyExists", code, msg)
+	}
+	var lookup url_service.GetOriginalResponse
+	if code, _ := grpcWebCall(t, web, url_service.URLService_GetOriginalURL_FullMethodName, &url_service.GetOriginalRequest{ShortCode: "nowhere"}, &lookup, nil); code != codes.NotFound {
+		t.Errorf("GetOriginalURL of an unknown code: %v, want NotFound", code)
+	}
+}
+
+func TestGRPCWebCORS(t *testing.T) {
+	s, _, _ := newTestServer(t, nil)
+	web := newGRPCWebTestServer(t, s, "https://app.example/")
+
+	tests := []struct {
+		name   string
+		origin string
+		want   string
+	}{
+		{"allowed origin", "https://app.example", "https://app.example"},
+		{"other origin", "https://evil.example", ""},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodOptions, web.URL+url_service.URLService_ShortenURL_FullMethodName, nil)
+		req.Header.Set("Origin", tt.origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "content-type,x-api-key,x-grpc-web,x-user-agent")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: preflight: %v", tt.name, err)
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != tt.want {
+			t.Errorf("%s: Access-Control-Allow-Origin %q, want %q", tt.name, got, tt.want)
+		}
+		if allowed := strings.ToLower(resp.Header.Get("Access-Control-Allow-Headers")); tt.want != "" && !strings.Contains(allowed, "x-api-key") {
+			t.Errorf("%s: allowed headers %q lack x-api-key", tt.name, allowed)
+		}
+	}
+
+	// Plain HTTP that isn't gRPC-Web never reaches the gRPC server
+	resp, err := http.Get(web.URL + url_service.URLService_ShortenURL_FullMethodName)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("plain GET: HTTP %d, want 404", resp.StatusCode)
+	}
+}
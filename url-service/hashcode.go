@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"strings"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// With the hash strategy a link's code is derived from its destination: the
+// SHA-256 of the normalized URL, written in the code alphabet, cut to
+// SHORT_CODE_LENGTH characters. Shortening the same URL again, on any
+// replica, lands on the code that already holds it and returns that link.
+//
+// Two URLs whose hashes start with the same characters collide. The code
+// then holds the URL that was shortened first, and the other one takes the
+// next character of its hash too, and another while that is taken as well,
+// up to the longest alias. Which URL gets the longer code depends only on
+// which came first, and once given, a URL keeps its code for as long as
+// the link exists.
+
+// codeStrategy returns the strategy of a request that asked for requested,
+// CODE_STRATEGY if it didn't. Sequence and pool need setting up at start,
+// so a request can only pick random or hash.
+func (s *urlServer) codeStrategy(requested string) (string, error) {
+	switch requested {
+	case "", s.defaultStrategy:
+		return s.defaultStrategy, nil
+	case codeStrategyRandom, codeStrategyHash:
+		return requested, nil
+	}
+	return "", status.Error(codes.InvalidArgument, "code_strategy must be random or hash")
+}
+
+// hashDigits writes the SHA-256 of originalURL in alphabet, least
+// significant digit first, so every prefix is as evenly spread as the whole.
+func hashDigits(originalURL, alphabet string) string {
+	sum := sha256.Sum256([]byte(originalURL))
+	n := new(big.Int).SetBytes(sum[:])
+	base := big.NewInt(int64(len(alphabet)))
+	digit := new(big.Int)
+
+	var b strings.Builder
+	for n.Sign() > 0 {
+		n.DivMod(n, base, digit)
+		b.WriteByte(alphabet[digit.Int64()])
+	}
+	return b.String()
+}
+
+// hashShortCode returns the key in ctx's tenant of originalURL's hash code,
+// and whether it already holds originalURL. It fails closed: if storage
+// can't be reached, a code that looks free may hold another URL.
+func (s *urlServer) hashShortCode(ctx context.Context, originalURL string) (string, bool, error) {
+	digits := hashDigits(originalURL, s.codeAlphabet)
+	for length := s.codeLength; length <= min(len(digits), maxAliasLength); length++ {
+		shortCode := digits[:length]
+		if err := s.aliases.Validate(shortCode); err != nil {
+			logf(ctx, "Hash code %s rejected: %v", shortCode, err)
+			continue
+		}
+
+		key := tenantKey(tenantID(ctx), shortCode)
+		holder, expired, err := s.shortCodeHolder(ctx, key)
+		if err != nil {
+			logf(ctx, "Failed to look up hash code %s: %v", shortCode, err)
+			return "", false, status.Error(codes.Unavailable, "unable to verify short code availability, please retry")
+		}
+		switch {
+		case holder == "":
+			s.metrics.shortCodes.WithLabelValues(codeStrategyHash).Inc()
+			return key, false, nil
+		case holder == originalURL && !expired:
+			return key, true, nil
+		}
+		logf(ctx, "Hash code collision for %s, extending it", shortCode)
+	}
+	return "", false, status.Error(codes.ResourceExhausted, "failed to find a free hash code")
+}
+
+// shortCodeHolder returns the URL shortCode holds, empty if it is free,
+// looking in memory and then on storage's primary like shortCodeExists.
+// An expired link still holds its code; expired reports that it does.
+func (s *urlServer) shortCodeHolder(ctx context.Context, shortCode string) (holder string, expired bool, err error) {
+	if entry, ok := s.urls.Get(shortCode); ok {
+		return entry.originalURL, isExpired(entry.expiresAt), nil
+	}
+
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	storageResp, err := s.storageClient.GetURL(storageCtx, &storage_service.GetURLRequest{ShortCode: shortCode, IncludeExpired: true, ForcePrimary: true})
+	if status.Code(err) == codes.NotFound {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if storageResp.Error != "" {
+		return "", false, fmt.Errorf("storage error: %s", storageResp.Error)
+	}
+	if !storageResp.Found {
+		return "", false, nil
+	}
+	return storageResp.OriginalUrl, isExpired(parseOptionalTime(storageResp.ExpiresAt)), nil
+}
+
+// existingLinkResponse answers a ShortenURL call with the link shortCode
+// already holds for originalURL.
+func (s *urlServer) existingLinkResponse(ctx context.Context, shortCode, requestedURL, originalURL string) *url_service.ShortenResponse {
+	return &url_service.ShortenResponse{
+		ShortCode:     shortCode,
+		OriginalUrl:   requestedURL,
+		NormalizedUrl: originalURL,
+		ShortUrl:      s.shortURL(shortCode),
+		TenantId:      tenantID(ctx),
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// collidingURLs returns two URLs whose hash codes share their first length
+// characters.
+func collidingURLs(t *testing.T, alphabet string, length int) (string, string) {
+	t.Helper()
+	seen := map[string]string{}
+	for i := 0; i < 1_000_000; i++ {
+		url := fmt.Sprintf("https://example.com/%d", i)
+		prefix := hashDigits(url, alphabet)[:length]
+		if other, ok := seen[prefix]; ok {
+			return other, url
+		}
+		seen[prefix] = url
+	}
+	t.Fatalf("no URLs colliding on %d characters", length)
+	return "", ""
+}
+
+func TestHashDigits(t *testing.T) {
+	a := hashDigits("https://example.com/a", shortCodeCharset)
+	if a != hashDigits("https://example.com/a", shortCodeCharset) {
+		t.Error("hashDigits differs between calls")
+	}
+	if a == hashDigits("https://example.com/b", shortCodeCharset) {
+		t.Error("hashDigits the same for different URLs")
+	}
+	// 256 bits take at most 43 base 62 digits
+	if len(a) < 40 || len(a) > 43 || strings.Trim(a, shortCodeCharset) != "" {
+		t.Errorf("hashDigits = %q, want about 43 characters of the alphabet", a)
+	}
+	if digits := hashDigits("https://example.com/a", "0123456789"); strings.Trim(digits, "0123456789") != "" {
+		t.Errorf("hashDigits in decimal = %q", digits)
+	}
+}
+
+func TestHashCodeStrategy(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true", "CODE_STRATEGY": "hash"})
+	ctx := context.Background()
+	shorten := func(req *url_service.ShortenRequest) string {
+		t.Helper()
+		resp, err := s.ShortenURL(ctx, req)
+		if err != nil {
+			t.Fatalf("ShortenURL(%s): %v", req.OriginalUrl, err)
+		}
+		return resp.ShortCode
+	}
+
+	want := hashDigits("https://example.com/hashed", s.codeAlphabet)[:s.codeLength]
+	if code := shorten(&url_service.ShortenRequest{OriginalUrl: "https://example.com/hashed"}); code != want {
+		t.Errorf("hash code %s, want %s", code, want)
+	}
+	// The same URL lands on the same link, from memory and from storage as
+	// another replica would
+	if code := shorten(&url_service.ShortenRequest{OriginalUrl: "https://example.com/hashed"}); code != want {
+		t.Errorf("hash code shortening again %s, want %s", code, want)
+	}
+	s.urls.Remove(want)
+	if code := shorten(&url_service.ShortenRequest{OriginalUrl: "https://example.com/hashed"}); code != want {
+		t.Errorf("hash code found in storage %s, want %s", code, want)
+	}
+	if got := gathered(t, s.metrics.registry, "url_service_short_codes_generated_total")["source=hash"]; got != 1 {
+		t.Errorf("hash codes generated %v, want 1", got)
+	}
+
+	// Custom aliases and random codes can still be asked for
+	if code := shorten(&url_service.ShortenRequest{OriginalUrl: "https://example.com/hashed", CustomAlias: "chosen"}); code != "chosen" {
+		t.Errorf("ShortenURL with an alias got %s", code)
+	}
+	if code := shorten(&url_service.ShortenRequest{OriginalUrl: "https://example.com/hashed", CodeStrategy: codeStrategyRandom}); code == want {
+		t.Error("random code asked for, got the hash code")
+	}
+	if u, _ := storage.url(want); u.OriginalUrl != "https://example.com/hashed" {
+		t.Errorf("storage holds %s at %s", u.OriginalUrl, want)
+	}
+
+	// Storage down, a code that looks free may not be
+	storage.mu.Lock()
+	storage.getErr = status.Error(codes.Unavailable, "database is down")
+	storage.mu.Unlock()
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com/unverified"}); status.Code(err) != codes.Unavailable {
+		t.Errorf("ShortenURL with storage down: got %v, want Unavailable", err)
+	}
+}
+
+func TestHashCodeCollisions(t *testing.T) {
+	// The shortest codes make colliding URLs quick to find
+	s, storage, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true", "CODE_STRATEGY": "hash", "SHORT_CODE_LENGTH": "4"})
+	ctx := context.Background()
+	first, second := collidingURLs(t, s.codeAlphabet, 4)
+	shorten := func(url string) string {
+		t.Helper()
+		resp, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: url})
+		if err != nil {
+			t.Fatalf("ShortenURL(%s): %v", url, err)
+		}
+		return resp.ShortCode
+	}
+
+	// The URL shortened first keeps the short code, the other takes one
+	// more character of its hash, every time
+	firstDigits, secondDigits := hashDigits(first, s.codeAlphabet), hashDigits(second, s.codeAlphabet)
+	if code := shorten(first); code != firstDigits[:4] {
+		t.Errorf("%s got %s, want %s", first, code, firstDigits[:4])
+	}
+	if code := shorten(second); code != secondDigits[:5] {
+		t.Errorf("%s colliding on %s got %s, want %s", second, firstDigits[:4], code, secondDigits[:5])
+	}
+	for _, url := range []string{first, second} {
+		s.urls.Remove(hashDigits(url, s.codeAlphabet)[:4])
+		s.urls.Remove(hashDigits(url, s.codeAlphabet)[:5])
+	}
+	if code := shorten(second); code != secondDigits[:5] {
+		t.Errorf("%s shortened again got %s, want its longer code %s", second, code, secondDigits[:5])
+	}
+	if code := shorten(first); code != firstDigits[:4] {
+		t.Errorf("%s shortened again got %s, want %s", first, code, firstDigits[:4])
+	}
+
+	// While longer codes are taken too, it keeps extending
+	third := "https://example.com/third"
+	digits := hashDigits(third, s.codeAlphabet)
+	for length := 4; length <= 6; length++ {
+		storage.put(&storage_service.SaveURLRequest{ShortCode: digits[:length], OriginalUrl: fmt.Sprintf("https://elsewhere.example/%d", length)})
+	}
+	if code := shorten(third); code != digits[:7] {
+		t.Errorf("%s with three codes taken got %s, want %s", third, code, digits[:7])
+	}
+	// An expired link holds its code, even for its own URL
+	fourth := "https://example.com/fourth"
+	digits = hashDigits(fourth, s.codeAlphabet)
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	storage.put(&storage_service.SaveURLRequest{ShortCode: digits[:4], OriginalUrl: fourth, ExpiresAt: past})
+	if code := shorten(fourth); code != digits[:5] {
+		t.Errorf("%s with its expired code taken got %s, want %s", fourth, code, digits[:5])
+	}
+}
+
+func TestCodeStrategyPerRequest(t *testing.T) {
+	s, _, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+	ctx := context.Background()
+
+	resp, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com/asked", CodeStrategy: codeStrategyHash})
+	if want := hashDigits("https://example.com/asked", s.codeAlphabet)[:s.codeLength]; err != nil || resp.ShortCode != want {
+		t.Errorf("ShortenURL asking for a hash code = %v, %v, want %s", resp, err, want)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		strategy string
+	}{
+		{"sequence", codeStrategySequence},
+		{"pool", codeStrategyPool},
+		{"unknown", "md5"},
+	} {
+		if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CodeStrategy: tt.strategy}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: got %v, want InvalidArgument", tt.name, err)
+		}
+	}
+	batch, err := s.BatchShorten(ctx, &url_service.BatchShortenRequest{Items: []*url_service.ShortenRequest{{OriginalUrl: "https://example.com", CodeStrategy: codeStrategyHash}}})
+	if err != nil || len(batch.Results) != 1 || codes.Code(batch.Results[0].Code) != codes.InvalidArgument {
+		t.Errorf("BatchShorten with a hash code item = %v, %v, want it InvalidArgument", batch, err)
+	}
+}
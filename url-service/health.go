@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const defaultHTTPPort = "8080"
+
+// newHealthHTTPServer serves /healthz (liveness) and /readyz (readiness) for
+// HTTP probes, /metrics for Prometheus, and the debug endpoints with debug.
+func newHealthHTTPServer(port string, s *urlServer, debug bool) *http.Server {
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.GET("/healthz", s.HealthCheck)
+	router.GET("/readyz", s.ReadyCheck)
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(s.metrics.registry, promhttp.HandlerOpts{})))
+	if debug {
+		s.registerDebug(router)
+	}
+
+	return &http.Server{
+		Addr:    ":" + port,
+		Handler: router,
+	}
+}
+
+// ReadyCheck reports ready only when the cache and storage services answer
+// their gRPC health checks.
+func (s *urlServer) ReadyCheck(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	if err := s.checkDependencies(ctx); err != nil {
+		log.Printf("Readiness check failed: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "not ready",
+			"service": "url-service",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	breakers := make(gin.H)
+	for _, b := range s.breakers() {
+		breakers[b.name] = b.State().String()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "ready",
+		"service":  "url-service",
+		"breakers": breakers,
+	})
+}
+
+// checkDependencies returns an error if any downstream service is not
+// serving. One cache node serving is enough, the others fail over to it.
+func (s *urlServer) checkDependencies(ctx context.Context) error {
+	if err := s.cacheRing.Check(ctx); err != nil {
+		return err
+	}
+	for name, client := range s.dependencies {
+		resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		if err != nil {
+			return fmt.Errorf("%s unreachable: %v", name, err)
+		}
+		if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+			return fmt.Errorf("%s not serving: %s", name, resp.Status)
+		}
+	}
+	return nil
+}
+
+const (
+	defaultReadinessInterval  = 5 * time.Second
+	defaultUnhealthyThreshold = 15 * time.Second
+)
+
+// watchReadiness keeps the gRPC health status of services in line with
+// check. Services start NOT_SERVING, flip to SERVING after the first
+// successful check, and flip back only once check has kept failing for
+// longer than threshold, so a single blip doesn't drain the instance.
+func watchReadiness(ctx context.Context, healthServer *health.Server, services []string, check func(context.Context) error, interval, threshold time.Duration) {
+	current := grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	setStatus := func(status grpc_health_v1.HealthCheckResponse_ServingStatus) {
+		for _, service := range services {
+			healthServer.SetServingStatus(service, status)
+		}
+		if status != current {
+			log.Printf("Health status changed: %s -> %s", current, status)
+			current = status
+		}
+	}
+	setStatus(current)
+
+	var failingSince time.Time
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		err := check(checkCtx)
+		cancel()
+
+		switch {
+		case err == nil:
+			failingSince = time.Time{}
+			setStatus(grpc_health_v1.HealthCheckResponse_SERVING)
+		case failingSince.IsZero():
+			failingSince = time.Now()
+			log.Printf("Dependency check failed: %v", err)
+		case time.Since(failingSince) >= threshold:
+			log.Printf("Dependency check failing for %s: %v", time.Since(failingSince).Round(time.Second), err)
+			setStatus(grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
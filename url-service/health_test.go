@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// serveGet serves a GET of path from handler and returns the response.
+func serveGet(handler http.Handler, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestReadyCheck(t *testing.T) {
+	s, storage, cache := newTestServer(t, nil)
+	handler := newHealthHTTPServer("0", s, false).Handler
+
+	steps := []struct {
+		name             string
+		storage, cache   grpc_health_v1.HealthCheckResponse_ServingStatus
+		wantCode         int
+		wantErrorMention string
+	}{
+		{"all serving", grpc_health_v1.HealthCheckResponse_SERVING, grpc_health_v1.HealthCheckResponse_SERVING, http.StatusOK, ""},
+		{"storage down", grpc_health_v1.HealthCheckResponse_NOT_SERVING, grpc_health_v1.HealthCheckResponse_SERVING, http.StatusServiceUnavailable, "storage-service"},
+		{"storage back", grpc_health_v1.HealthCheckResponse_SERVING, grpc_health_v1.HealthCheckResponse_SERVING, http.StatusOK, ""},
+		{"cache down", grpc_health_v1.HealthCheckResponse_SERVING, grpc_health_v1.HealthCheckResponse_NOT_SERVING, http.StatusServiceUnavailable, "not serving"},
+		{"cache back", grpc_health_v1.HealthCheckResponse_SERVING, grpc_health_v1.HealthCheckResponse_SERVING, http.StatusOK, ""},
+	}
+	for _, step := range steps {
+		storage.health.SetServingStatus("", step.storage)
+		cache.health.SetServingStatus("", step.cache)
+
+		rec := serveGet(handler, "/readyz")
+		if rec.Code != step.wantCode || !strings.Contains(rec.Body.String(), step.wantErrorMention) {
+			t.Errorf("%s: /readyz = %d %s, want %d mentioning %q", step.name, rec.Code, rec.Body, step.wantCode, step.wantErrorMention)
+		}
+		// Liveness doesn't depend on anything downstream
+		if rec := serveGet(handler, "/healthz"); rec.Code != http.StatusOK {
+			t.Errorf("%s: /healthz = %d, want 200", step.name, rec.Code)
+		}
+	}
+}
+
+func TestWatchReadiness(t *testing.T) {
+	healthServer := health.NewServer()
+	conn := dialBufconn(t, func(srv *grpc.Server) { grpc_health_v1.RegisterHealthServer(srv, healthServer) })
+	client := grpc_health_v1.NewHealthClient(conn)
+	service := url_service.URLService_ServiceDesc.ServiceName
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watch, err := client.Watch(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	expect := func(want grpc_health_v1.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		resp, err := watch.Recv()
+		if err != nil || resp.Status != want {
+			t.Fatalf("watched %v, %v, want %s", resp, err, want)
+		}
+	}
+	expect(grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN)
+
+	// Each dependency check returns the next result sent here
+	results := make(chan error)
+	check := func(context.Context) error {
+		select {
+		case err := <-results:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	const threshold = 50 * time.Millisecond
+	go watchReadiness(ctx, healthServer, []string{"", service}, check, time.Millisecond, threshold)
+
+	// Not serving until the first check passes
+	expect(grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	results <- nil
+	expect(grpc_health_v1.HealthCheckResponse_SERVING)
+
+	// A single failure doesn't flip the status
+	down := errors.New("storage-service unreachable")
+	results <- down
+	results <- nil
+	results <- nil
+	for _, name := range []string{"", service} {
+		if resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: name}); err != nil || resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+			t.Errorf("%q after a blip = %v, %v, want SERVING", name, resp, err)
+		}
+	}
+
+	// Failing past the threshold does, until a check passes again
+	stop := make(chan struct{})
+	failing := time.Now()
+	go func() {
+		for {
+			select {
+			case results <- down:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	expect(grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	close(stop)
+	if elapsed := time.Since(failing); elapsed < threshold {
+		t.Errorf("flipped after failing for %v, want at least %v", elapsed, threshold)
+	}
+	results <- nil
+	expect(grpc_health_v1.HealthCheckResponse_SERVING)
+	if resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}); err != nil || resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Errorf("overall status = %v, %v, want SERVING", resp, err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetURLHistory returns a page of the changes to one of the caller's links,
+// newest first: who pointed it elsewhere, disabled, enabled or deleted it.
+func (s *urlServer) GetURLHistory(ctx context.Context, req *url_service.GetURLHistoryRequest) (*url_service.GetURLHistoryResponse, error) {
+	logf(ctx, "GetURLHistory request for: %s", req.ShortCode)
+
+	if req.ShortCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "short code is required")
+	}
+	if err := s.checkOwner(ctx, req.ShortCode); err != nil {
+		return nil, err
+	}
+
+	pageSize := req.PageSize
+	if pageSize < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	}
+	if pageSize == 0 {
+		pageSize = defaultListPageSize
+	}
+	pageSize = min(pageSize, maxListPageSize)
+
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	resp, err := s.storageClient.GetURLHistory(storageCtx, &storage_service.GetURLHistoryRequest{
+		ShortCode: req.ShortCode,
+		PageSize:  pageSize,
+		PageToken: req.PageToken,
+	})
+	if status.Code(err) == codes.InvalidArgument {
+		return nil, err
+	} else if err != nil {
+		logf(ctx, "Failed to get history of %s: %v", req.ShortCode, err)
+		return nil, status.Error(codes.Unavailable, "failed to get URL history")
+	}
+
+	out := &url_service.GetURLHistoryResponse{NextPageToken: resp.NextPageToken}
+	for _, e := range resp.Entries {
+		out.Entries = append(out.Entries, &url_service.URLHistoryEntry{
+			Action:    e.Action,
+			Actor:     e.Actor,
+			ApiKeyId:  e.ApiKeyId,
+			OldUrl:    e.OldUrl,
+			NewUrl:    e.NewUrl,
+			ChangedAt: e.ChangedAt,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// misbehavingHealth panics on Check for the service "panic" and holds the
+// call open until its context ends for "slow".
+type misbehavingHealth struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (misbehavingHealth) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	switch req.Service {
+	case "panic":
+		panic("handler bug")
+	case "slow":
+		select {
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func TestRecoveryAndDeadlineInterceptors(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(recoveryInterceptor(), deadlineInterceptor(50*time.Millisecond)))
+	grpc_health_v1.RegisterHealthServer(server, misbehavingHealth{})
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial bufconn: %v", err)
+	}
+	defer conn.Close()
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	tests := []struct {
+		name     string
+		service  string
+		deadline time.Duration
+		want     codes.Code
+	}{
+		{"panic", "panic", 0, codes.Internal},
+		{"server survives the panic", "", 0, codes.OK},
+		{"slow without a deadline", "slow", 0, codes.DeadlineExceeded},
+		{"slow within the caller's deadline", "slow", 5 * time.Second, codes.OK},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if tt.deadline > 0 {
+			ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+		}
+		start := time.Now()
+		_, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: tt.service})
+		cancel()
+		if got := status.Code(err); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+		if tt.want == codes.DeadlineExceeded && time.Since(start) > 400*time.Millisecond {
+			t.Errorf("%s: took %v, want about the 50ms default", tt.name, time.Since(start))
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+)
+
+// popPooledKey takes a pre-generated code from storage's key pool. Storage
+// removes the key as it hands it out, so no other replica gets it. It
+// returns the key of the code in ctx's tenant, or "" when the pool is empty
+// or storage can't be reached, and the caller generates a code instead.
+func (s *urlServer) popPooledKey(ctx context.Context) string {
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	resp, err := s.storageClient.PopKeys(storageCtx, &storage_service.PopKeysRequest{Count: 1})
+	if err != nil {
+		logf(ctx, "Warning: failed to pop a pooled key, generating one: %v", err)
+		return ""
+	}
+	for _, shortCode := range resp.ShortCodes {
+		// Storage doesn't know the reserved words
+		key := tenantKey(tenantID(ctx), shortCode)
+		if s.aliases.Validate(shortCode) == nil && !s.urls.Contains(key) {
+			return key
+		}
+	}
+	logf(ctx, "Key pool is empty, generating a code")
+	return ""
+}
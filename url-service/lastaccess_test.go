@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClickBatcherFlushesLastAccess(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	b := newTestClickBatcher(s, time.Hour, 1000)
+	ctx := context.Background()
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	click := func(shortCode string, after time.Duration) *storage_service.ClickEvent {
+		return &storage_service.ClickEvent{ShortCode: shortCode, ClickedAt: base.Add(after).Format(time.RFC3339Nano)}
+	}
+
+	// Clicks out of order, a bot's click after them all, and one counted
+	// by storage
+	b.Add(click("a", time.Minute))
+	b.Add(click("a", 3*time.Minute))
+	b.Add(click("a", 2*time.Minute))
+	b.Add(click("b", 0))
+	b.AddBot(click("a", 10*time.Minute))
+	b.AddEvent(click("c", 5*time.Minute))
+	b.Flush(ctx)
+
+	storage.mu.Lock()
+	calls := storage.incrementCalls
+	storage.mu.Unlock()
+	if calls != 1 {
+		t.Errorf("%d BatchIncrementClicks calls, want the access times in the one flush", calls)
+	}
+	for _, tt := range []struct {
+		code string
+		want time.Time
+	}{
+		{"a", base.Add(3 * time.Minute)},
+		{"b", base},
+		{"c", base.Add(5 * time.Minute)},
+	} {
+		storage.mu.Lock()
+		got := storage.lastAccessed[tt.code]
+		storage.mu.Unlock()
+		if !got.Equal(tt.want) {
+			t.Errorf("%s last accessed %v, want %v", tt.code, got, tt.want)
+		}
+	}
+	if n := storage.clicks("a"); n != 3 {
+		t.Errorf("a has %d clicks, want 3 without the bot's", n)
+	}
+
+	// A retried flush keeps the latest of the access times
+	storage.mu.Lock()
+	storage.incrementErrs = []error{status.Error(codes.Unavailable, "down")}
+	storage.mu.Unlock()
+	b.Add(click("d", 7*time.Minute))
+	b.Flush(ctx)
+	b.Add(click("d", 6*time.Minute))
+	b.Flush(ctx)
+	storage.mu.Lock()
+	got := storage.lastAccessed["d"]
+	storage.mu.Unlock()
+	if want := base.Add(7 * time.Minute); !got.Equal(want) {
+		t.Errorf("d last accessed %v after a retried flush, want %v", got, want)
+	}
+}
+
+func TestLastAccessedInStats(t *testing.T) {
+	s, _, cache := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+	alice := withKey(context.Background(), "alice-key", "alice")
+	if _, err := s.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "visited"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	check := func(step, want string) {
+		t.Helper()
+		stats, err := s.GetURLStats(alice, &url_service.StatsRequest{ShortCode: "visited"})
+		if err != nil || stats.LastAccessedAt != want {
+			t.Errorf("%s: GetURLStats = %v, %v, want last accessed %q", step, stats, err, want)
+		}
+		list, err := s.ListURLs(alice, &url_service.ListURLsRequest{})
+		if err != nil || len(list.Urls) != 1 || list.Urls[0].LastAccessedAt != want {
+			t.Errorf("%s: ListURLs = %v, %v, want last accessed %q", step, list, err, want)
+		}
+	}
+
+	check("never clicked", "")
+	waitForCacheEntry(t, cache, talliesNamespace+":visited", true)
+
+	// Clicks not yet flushed count, and once flushed the tallies cached
+	// without them are dropped
+	at := time.Now().UTC().Truncate(time.Second)
+	s.clicks.Add(&storage_service.ClickEvent{ShortCode: "visited", ClickedAt: at.Format(time.RFC3339Nano)})
+	check("before the flush", at.Format(time.RFC3339))
+	s.clicks.Flush(context.Background())
+	waitForCacheEntry(t, cache, talliesNamespace+":visited", false)
+	check("after the flush", at.Format(time.RFC3339))
+}
+
+func TestCachedTalliesLastAccess(t *testing.T) {
+	s, _, cache := newTestServer(t, nil)
+	ctx := context.Background()
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  string
+		want   clickTallies
+		cached bool
+	}{
+		{"accessed", fmt.Sprintf("3 1 %d", at.Unix()), clickTallies{unique: 3, bot: 1, lastAccessed: at}, true},
+		{"never accessed", "3 1 0", clickTallies{unique: 3, bot: 1}, true},
+		// Cached before the last access was
+		{"two fields", "3 1", clickTallies{}, false},
+	}
+	for _, tt := range tests {
+		cache.set(talliesNamespace+":"+tt.name, tt.value)
+		got, cached := s.cachedTallies(ctx, tt.name)
+		if got != tt.want || cached != tt.cached {
+			t.Errorf("%s: cachedTallies = %+v, %v, want %+v, %v", tt.name, got, cached, tt.want, tt.cached)
+		}
+	}
+
+	// What cacheTallies writes reads back the same
+	s.cacheTallies(ctx, "round", clickTallies{unique: 2, lastAccessed: at})
+	waitForCacheEntry(t, cache, talliesNamespace+":round", true)
+	if got, _ := s.cachedTallies(ctx, "round"); got != (clickTallies{unique: 2, lastAccessed: at}) {
+		t.Errorf("cachedTallies after cacheTallies = %+v", got)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// validateClickLimit checks the max_clicks of a new link.
+func validateClickLimit(req *url_service.ShortenRequest) error {
+	if req.MaxClicks < 0 {
+		return status.Error(codes.InvalidArgument, "max_clicks must not be negative")
+	}
+	return nil
+}
+
+// claimClick resolves a link with max_clicks. Its clicks are counted by
+// storage before redirecting, with a conditional update that concurrent
+// clicks on any replica can't push past the limit, so these links are
+// never served from the cache. Bots, prefetches and lookups that skip
+// stats only check that a click is left.
+func (s *urlServer) claimClick(ctx context.Context, req *url_service.GetOriginalRequest, entry urlEntry) (*url_service.GetOriginalResponse, error) {
+	bot := req.Prefetch || s.bots.IsBot(req.UserAgent)
+	peek := bot || req.SkipStats
+
+	storageCtx, cancel := s.storageCtx(ctx)
+	resp, err := s.storageClient.ClaimClick(storageCtx, &storage_service.ClaimClickRequest{
+		ShortCode: req.ShortCode,
+		Peek:      peek,
+	})
+	cancel()
+	if status.Code(err) == codes.NotFound {
+		s.urls.Remove(req.ShortCode)
+		return nil, status.Error(codes.NotFound, "URL not found")
+	}
+	if err != nil {
+		logf(ctx, "Storage click claim failed for %s: %v", req.ShortCode, err)
+		return nil, status.Error(codes.Unavailable, "storage unavailable")
+	}
+
+	if !resp.Claimed {
+		logf(ctx, "URL %s has reached its click limit", req.ShortCode)
+		if fallback := s.fallbackResponse(ctx, req.ShortCode, resp.FallbackUrl, resolutionExhausted); fallback != nil {
+			return fallback, nil
+		}
+		return &url_service.GetOriginalResponse{Exhausted: true, Resolution: resolutionExhausted}, nil
+	}
+
+	target := s.destination(ctx, req, entry.originalURL, entry.routes)
+	switch {
+	case req.SkipStats:
+	case bot:
+		click := s.clickFromRequest(ctx, req)
+		click.Variant, click.Rule = target.Variant, target.Rule
+		s.publishClick(click, true)
+		s.clicks.AddBot(click)
+	default:
+		// The count is already in storage, only the event is left
+		click := s.clickFromRequest(ctx, req)
+		click.Variant, click.Rule = target.Variant, target.Rule
+		s.publishClick(click, false)
+		s.clicks.AddEvent(click)
+		s.trackVisitor(ctx, req.ShortCode, req.UserAgent)
+
+		// The cached count is stale now, and once the last click is used
+		// nothing cached for the code may be served
+		bg := detach(ctx)
+		s.tasks.Submit("invalidate "+req.ShortCode, func() {
+			s.invalidateCache(bg, req.ShortCode)
+		})
+	}
+
+	return target.response(), nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const defaultURLCacheMaxEntries = 100000
+
+// urlEntry is everything url-service keeps in memory about a short code.
+type urlEntry struct {
+	originalURL string
+	createdAt   time.Time
+	expiresAt   time.Time
+	clickCount  int64  // persisted click count when the entry was loaded from storage
+	userID      string // owner, if known
+	maxClicks   int64  // 0 if unlimited, see claimClick
+
+	notBefore     time.Time  // zero if active from creation
+	comingSoonURL string     // destination before notBefore
+	fallbackURL   string     // destination once expired, out of clicks, disabled or deleted
+	disabled      bool       // turned off with SetURLStatus
+	deleted       bool       // deleted but not purged yet, never kept in memory
+	routes        linkRoutes // variants, rules and query template
+
+	refreshedAt time.Time // when storage, the cache or this replica last confirmed the entry
+	staleAt     time.Time // zero if never stale, see stale.go
+}
+
+type lruItem struct {
+	shortCode string
+	entry     urlEntry
+}
+
+// urlLRU is a size-bounded map of short codes to entries that evicts the
+// least recently used entry once maxEntries is exceeded. All operations are
+// O(1) and safe for concurrent use.
+type urlLRU struct {
+	mu         sync.Mutex
+	maxEntries int
+	staleAfter time.Duration // 0 keeps entries fresh
+	ll         *list.List
+	items      map[string]*list.Element
+
+	// onEvict, if set, is called without the lock held for every entry
+	// dropped to make room for a new one.
+	onEvict func(shortCode string, entry urlEntry)
+}
+
+func newURLLRU(maxEntries int, staleAfter time.Duration) *urlLRU {
+	return &urlLRU{
+		maxEntries: maxEntries,
+		staleAfter: staleAfter,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get returns the entry for a short code and marks it as recently used.
+func (c *urlLRU) Get(shortCode string) (urlEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[shortCode]
+	if !ok {
+		return urlEntry{}, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*lruItem).entry, true
+}
+
+// Contains reports whether a short code is present without affecting its
+// recency.
+func (c *urlLRU) Contains(shortCode string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.items[shortCode]
+	return ok
+}
+
+// Set adds or replaces the entry for a short code. Entries without
+// refreshedAt are taken as confirmed now.
+func (c *urlLRU) Set(shortCode string, entry urlEntry) {
+	c.stamp(&entry)
+	c.mu.Lock()
+	if el, ok := c.items[shortCode]; ok {
+		el.Value.(*lruItem).entry = entry
+		c.ll.MoveToFront(el)
+		c.mu.Unlock()
+		return
+	}
+	evicted := c.insert(shortCode, entry)
+	c.mu.Unlock()
+
+	c.notifyEvicted(evicted)
+}
+
+// AddIfAbsent adds the entry only if the short code is not present and
+// reports whether it was added. It stamps entries like Set.
+func (c *urlLRU) AddIfAbsent(shortCode string, entry urlEntry) bool {
+	c.stamp(&entry)
+	c.mu.Lock()
+	if _, ok := c.items[shortCode]; ok {
+		c.mu.Unlock()
+		return false
+	}
+	evicted := c.insert(shortCode, entry)
+	c.mu.Unlock()
+
+	c.notifyEvicted(evicted)
+	return true
+}
+
+// Update applies fn to the entry of a short code if present and reports
+// whether it was found.
+func (c *urlLRU) Update(shortCode string, fn func(entry *urlEntry)) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[shortCode]
+	if !ok {
+		return false
+	}
+	fn(&el.Value.(*lruItem).entry)
+	return true
+}
+
+// Remove deletes a short code and reports whether it was present. Removed
+// entries are not passed to onEvict.
+func (c *urlLRU) Remove(shortCode string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[shortCode]
+	if !ok {
+		return false
+	}
+	c.ll.Remove(el)
+	delete(c.items, shortCode)
+	return true
+}
+
+// Len returns the number of entries.
+func (c *urlLRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+func (c *urlLRU) stamp(entry *urlEntry) {
+	if !entry.refreshedAt.IsZero() {
+		return
+	}
+	entry.refreshedAt = time.Now()
+	if c.staleAfter > 0 {
+		entry.staleAt = entry.refreshedAt.Add(c.staleAfter)
+	}
+}
+
+// insert adds a new entry and returns the items evicted to stay within
+// maxEntries. The caller must hold c.mu.
+func (c *urlLRU) insert(shortCode string, entry urlEntry) []*lruItem {
+	c.items[shortCode] = c.ll.PushFront(&lruItem{shortCode: shortCode, entry: entry})
+
+	var evicted []*lruItem
+	for c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		oldest := c.ll.Back()
+		item := oldest.Value.(*lruItem)
+		c.ll.Remove(oldest)
+		delete(c.items, item.shortCode)
+		evicted = append(evicted, item)
+	}
+	return evicted
+}
+
+func (c *urlLRU) notifyEvicted(evicted []*lruItem) {
+	if c.onEvict == nil {
+		return
+	}
+	for _, item := range evicted {
+		c.onEvict(item.shortCode, item.entry)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+)
+
+func TestURLLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newURLLRU(3, 0)
+	var evicted []string
+	c.onEvict = func(shortCode string, entry urlEntry) {
+		evicted = append(evicted, shortCode+"="+entry.originalURL)
+	}
+
+	for _, code := range []string{"a", "b", "c"} {
+		c.Set(code, urlEntry{originalURL: "https://" + code + ".example"})
+	}
+	// Reading a makes b the least recently used, Contains leaves recency alone
+	c.Get("a")
+	c.Contains("b")
+	c.Set("d", urlEntry{originalURL: "https://d.example"})
+	if added := c.AddIfAbsent("a", urlEntry{originalURL: "https://other.example"}); added {
+		t.Error("AddIfAbsent replaced a")
+	}
+	c.AddIfAbsent("e", urlEntry{originalURL: "https://e.example"})
+	c.Remove("d")
+
+	if fmt.Sprint(evicted) != "[b=https://b.example c=https://c.example]" {
+		t.Errorf("evicted %v, want b and then c", evicted)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+	if entry, ok := c.Get("a"); !ok || entry.originalURL != "https://a.example" || entry.refreshedAt.IsZero() {
+		t.Errorf("Get(a) = %+v, %v", entry, ok)
+	}
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if !c.Update("e", func(entry *urlEntry) { entry.createdAt = createdAt }) || c.Update("d", func(*urlEntry) {}) {
+		t.Error("Update found the wrong entries")
+	}
+	if entry, _ := c.Get("e"); !entry.createdAt.Equal(createdAt) {
+		t.Errorf("Get(e) after Update = %+v", entry)
+	}
+}
+
+func TestEvictedURLServedFromStorage(t *testing.T) {
+	s, storage, cache := newTestServer(t, nil)
+	s.urls = newURLLRU(2, 0)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		storage.put(&storage_service.SaveURLRequest{ShortCode: "code" + strconv.Itoa(i), OriginalUrl: fmt.Sprintf("https://%d.example", i)})
+	}
+
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 5; i++ {
+			code := "code" + strconv.Itoa(i)
+			// Nor is the cache there to help
+			cache.expire("url:" + code)
+			resp, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: code})
+			if err != nil || resp.OriginalUrl != fmt.Sprintf("https://%d.example", i) {
+				t.Errorf("round %d: GetOriginalURL(%s) = %v, %v", round, code, resp, err)
+			}
+			if n := s.urls.Len(); n > 2 {
+				t.Fatalf("memory holds %d URLs, want at most 2", n)
+			}
+		}
+	}
+
+	// Evicting an entry loses none of its clicks, which wait in the batcher
+	for i := 0; i < 5; i++ {
+		if n := s.clicks.Pending("code" + strconv.Itoa(i)); n != 2 {
+			t.Errorf("code%d has %d clicks pending, want 2", i, n)
+		}
+	}
+}
+
+// BenchmarkURLLRUBounded stores a new code on every iteration. Entries and
+// heap stay flat at the cap however many codes went through.
+func BenchmarkURLLRUBounded(b *testing.B) {
+	const maxEntries = 10000
+	c := newURLLRU(maxEntries, 0)
+	entry := urlEntry{originalURL: "https://example.com/some/long/path"}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.Set("code"+strconv.Itoa(i), entry)
+	}
+	b.StopTimer()
+
+	runtime.GC()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	b.ReportMetric(float64(c.Len()), "entries")
+	b.ReportMetric(float64(mem.HeapAlloc)/(1<<20), "heap-MiB")
+	if c.Len() > maxEntries {
+		b.Fatalf("%d entries, want at most %d", c.Len(), maxEntries)
+	}
+}
@@ -2,13 +2,20 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	cache_service "github.com/syedalijabir/protos/cache-service"
@@ -16,366 +23,1460 @@ import (
 	url_service "github.com/syedalijabir/protos/url-service"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/singleflight"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/resolver/dns"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	shortCodeCharset     = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	maxShortCodeAttempts = 5
+
+	cacheDeleteAttempts = 3
+)
+
+// Cache namespaces. cache-service keeps each in its own keyspace.
+const (
+	urlNamespace        = "url"        // short code to original URL, or a cachedLink as JSON
+	countNamespace      = "count"      // short code to click count
+	talliesNamespace    = "tallies"    // short code to unique and bot click counts
+	notFoundNamespace   = "notfound"   // sentinels for codes known not to exist
+	visitorNamespace    = "visitor"    // recent visitor hashes and their daily salt
+	reputationNamespace = "reputation" // claims of the periodic reputation rescan
 )
 
 type urlServer struct {
 	url_service.UnimplementedURLServiceServer
-	mu            sync.RWMutex
-	urls          map[string]string
-	createdAt     map[string]time.Time
-	cacheClient   cache_service.CacheServiceClient
-	storageClient storage_service.StorageServiceClient
+	metrics           *serviceMetrics
+	urls              *urlLRU
+	mu                sync.RWMutex
+	deleted           map[string]time.Time // tombstones of deleted codes, until stale cache entries expire
+	missing           map[string]time.Time // codes recently found not to exist
+	settings          atomic.Pointer[liveConfig]
+	maxStaleness      time.Duration // how old a memory entry served while storage is down may be, 0 never serves one
+	timeouts          dependencyTimeouts
+	budget            lookupBudget
+	cacheClient       cache_service.CacheServiceClient
+	cacheRing         *cacheRing // behind cacheClient
+	storageClient     storage_service.StorageServiceClient
+	storageConn       *grpc.ClientConn
+	storageBreaker    *circuitBreaker
+	flights           singleflight.Group // dedupes concurrent storage lookups and cache warms per code
+	dependencies      map[string]grpc_health_v1.HealthClient
+	validator         *urlValidator
+	selfLinks         *selfLinks
+	domains           *domainRules
+	reputation        *reputationChecker
+	metadata          *metadataFetcher
+	admins            map[string]bool // users allowed to list abuse reports
+	reportThreshold   int32           // distinct reporters that disable a link, 0 never
+	trustForwardedFor bool
+	aliases           *aliasValidator
+	clicks            *clickBatcher
+	tasks             *taskQueue
+	rateLimits        *rateLimits
+	admission         *admission // sheds writes while tasks or clicks back up
+	persister         *urlPersister
+	syncPersist       bool            // always persist before ShortenURL returns
+	ids               *idAllocator    // nil unless CODE_STRATEGY=sequence
+	keyPool           bool            // take codes from storage's key pool, CODE_STRATEGY=pool
+	defaultStrategy   string          // CODE_STRATEGY, for requests that don't pick one
+	codeAlphabet      string          // characters of random and sequence codes
+	codeLength        int             // length of random codes, sequence codes are one longer
+	geoIP             *geoIP          // nil unless GEOIP_DB_PATH is set
+	visitors          *visitorTracker // nil if UNIQUE_CLICK_WINDOW is 0
+	bots              *botClassifier
+	feed              *clickFeed      // clicks for StreamClicks subscribers
+	events            *eventPublisher // nil unless EVENTS_BROKER is set
+	dedupURLs         bool
+	normalizeURLs     bool
+	maxURLsPerUser    int
+	tenants           *tenantSet
+	defaultFallback   string // DEFAULT_FALLBACK_URL
+	maxBatchSize      int
+	debugEndpoints    bool
+	heapProfileDir    string
 }
 
-func NewURLServer() (*urlServer, error) {
-	cacheHost := getEnv("CACHE_SERVICE_HOST", "cache-service")
-	storageHost := getEnv("STORAGE_SERVICE_HOST", "storage-service")
+func NewURLServer(cfg Config) (*urlServer, error) {
+	tasks, err := newTaskQueue(cfg.AsyncWorkers, cfg.AsyncQueueSize, cfg.AsyncQueuePolicy)
+	if err != nil {
+		return nil, err
+	}
 
-	cacheConn, err := grpc.Dial(cacheHost+":50052", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	reservedAliases, err := loadReservedAliases(cfg.ReservedAliases, cfg.ReservedAliasesFile)
 	if err != nil {
 		return nil, err
 	}
 
-	storageConn, err := grpc.Dial(storageHost+":50053", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	codeAlphabet, err := resolveAlphabet(cfg.ShortCodeAlphabet)
 	if err != nil {
 		return nil, err
 	}
 
-	return &urlServer{
-		urls:          make(map[string]string),
-		createdAt:     make(map[string]time.Time),
-		cacheClient:   cache_service.NewCacheServiceClient(cacheConn),
-		storageClient: storage_service.NewStorageServiceClient(storageConn),
-	}, nil
+	var geo *geoIP
+	if cfg.GeoIPDBPath != "" {
+		if geo, err = openGeoIP(cfg.GeoIPDBPath, cfg.TrustForwardedFor); err != nil {
+			return nil, fmt.Errorf("failed to open GeoIP database %s: %w", cfg.GeoIPDBPath, err)
+		}
+	}
+
+	metrics := newServiceMetrics()
+
+	cacheRing, err := newCacheRing(cfg, metrics.cacheFailovers, metrics.downstream)
+	if err != nil {
+		return nil, err
+	}
+
+	storageBreaker := newCircuitBreaker("storage-service", cfg.BreakerFailureThreshold, cfg.BreakerOpenTimeout)
+	storageConn, err := newClientConn(cfg.StorageServiceAddr, cfg, storageBreaker, storageBalancing(cfg), "storage-service", metrics.downstream)
+	if err != nil {
+		return nil, err
+	}
+
+	var cacheClient cache_service.CacheServiceClient = cacheRing
+	storageClient := storage_service.NewStorageServiceClient(storageConn)
+
+	persister, err := newURLPersister(storageClient, cfg.StorageTimeout, cfg.PersistMaxAttempts, cfg.PersistBaseDelay, cfg.PersistWALDir)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := newEventPublisher(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	domains := newDomainRules(cfg.DomainPolicy)
+
+	tenants, err := parseTenants(cfg.Tenants, cfg.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	aliases := newAliasValidator(reservedAliases)
+	selfLinks := newSelfLinks(cfg, tenants.baseURLs, aliases)
+
+	s := &urlServer{
+		metrics:      metrics,
+		urls:         newURLLRU(cfg.URLCacheEntries, cfg.MemoryStaleAfter),
+		deleted:      make(map[string]time.Time),
+		missing:      make(map[string]time.Time),
+		maxStaleness: cfg.MemoryMaxStaleness,
+		timeouts: dependencyTimeouts{
+			cacheRead: cfg.CacheReadTimeout,
+			cache:     cfg.CacheTimeout,
+			storage:   cfg.StorageTimeout,
+		},
+		budget: lookupBudget{
+			cache:          cfg.LookupCacheBudget,
+			hedge:          cfg.LookupHedgeDelay,
+			storageReserve: cfg.LookupStorageReserve,
+		},
+		cacheClient:    cacheClient,
+		cacheRing:      cacheRing,
+		storageClient:  storageClient,
+		storageConn:    storageConn,
+		storageBreaker: storageBreaker,
+		dependencies: map[string]grpc_health_v1.HealthClient{
+			"storage-service": grpc_health_v1.NewHealthClient(storageConn),
+		},
+		clicks:            newClickBatcher(storageClient, cacheClient, cfg.ClickFlushInterval, cfg.ClickFlushThreshold, metrics.clickFlushSize),
+		tasks:             tasks,
+		rateLimits:        newRateLimits(cfg),
+		persister:         persister,
+		syncPersist:       cfg.SyncPersist,
+		validator:         newURLValidator(cfg.MaxURLLength, selfLinks, domains),
+		selfLinks:         selfLinks,
+		domains:           domains,
+		reputation:        newReputationChecker(cfg, metrics.reputation),
+		metadata:          newMetadataFetcher(cfg, storageClient, metrics.metadata),
+		admins:            make(map[string]bool),
+		reportThreshold:   int32(cfg.ReportDisableThreshold),
+		trustForwardedFor: cfg.TrustForwardedFor,
+		aliases:           aliases,
+		dedupURLs:         cfg.DedupURLs,
+		normalizeURLs:     cfg.NormalizeURLs,
+		maxURLsPerUser:    cfg.MaxURLsPerUser,
+		tenants:           tenants,
+		defaultFallback:   cfg.DefaultFallbackURL,
+		maxBatchSize:      cfg.MaxBatchSize,
+		debugEndpoints:    cfg.DebugEndpoints,
+		heapProfileDir:    cfg.HeapProfileDir,
+		keyPool:           cfg.CodeStrategy == codeStrategyPool,
+		defaultStrategy:   cfg.CodeStrategy,
+		codeAlphabet:      codeAlphabet,
+		codeLength:        cfg.ShortCodeLength,
+		geoIP:             geo,
+		bots:              newBotClassifier(cfg.BotUserAgents),
+		feed:              newClickFeed(cfg.ClickFeedBuffer, cfg.ClickFeedMaxSubscribers),
+		events:            events,
+	}
+	if cfg.UniqueClickWindow > 0 {
+		s.visitors = newVisitorTracker(cacheClient, cfg.UniqueClickWindow, cfg.TrustForwardedFor)
+	}
+	for _, user := range cfg.AdminUsers {
+		if user = strings.TrimSpace(user); user != "" {
+			s.admins[user] = true
+		}
+	}
+	if cfg.CodeStrategy == codeStrategySequence {
+		s.ids = newIDAllocator(int64(cfg.IDBlockSize), s.allocateIDs)
+	}
+	s.settings.Store(newLiveConfig(cfg))
+	s.admission = newAdmission(cfg, tasks, s.clicks)
+	persister.lost = s.forgetLost
+	metrics.registerServer(s)
+
+	return s, nil
 }
 
 func (s *urlServer) ShortenURL(ctx context.Context, req *url_service.ShortenRequest) (*url_service.ShortenResponse, error) {
-	log.Printf("ShortenURL request for: %s", req.OriginalUrl)
-
-	shortCode := generateShortCode()
-	if req.CustomAlias != "" {
-		shortCode = req.CustomAlias
+	logf(ctx, "ShortenURL request for: %s", req.OriginalUrl)
+
+	// A link to one of our own, directly or through other shorteners, is
+	// turned down or takes that link's destination
+	linkURL := req.OriginalUrl
+	if linkURL != "" {
+		var aliasKey string
+		var err error
+		if req.CustomAlias != "" {
+			aliasKey = tenantKey(tenantID(ctx), req.CustomAlias)
+		}
+		if linkURL, err = s.resolveLinkChain(ctx, linkURL, aliasKey, true); err != nil {
+			logf(ctx, "Rejected URL: %v", err)
+			return nil, err
+		}
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	// Split links store their first variant as the original URL
+	originalURL, routes, err := s.newLinkRoutes(linkURL, req.Variants, req.StickyVariants, req.Rules, req.QueryTemplate)
+	if err != nil {
+		logf(ctx, "Rejected URL: %v", err)
+		return nil, err
+	}
+	requestedURL := req.OriginalUrl
+	if routes.variants != nil {
+		requestedURL = originalURL
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
 
-	if _, exists := s.urls[shortCode]; exists {
-		return &url_service.ShortenResponse{
-			Error: "Custom alias already exists",
-		}, nil
+	if req.TtlSeconds < 0 {
+		return nil, status.Error(codes.InvalidArgument, "ttl_seconds must not be negative")
+	}
+	var expiresAt time.Time
+	if req.TtlSeconds > 0 {
+		expiresAt = time.Now().Add(time.Duration(req.TtlSeconds) * time.Second)
+	}
+	notBefore, expiresAt, err := linkWindow(req, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateClickLimit(req); err != nil {
+		return nil, err
+	}
+	if err := s.validateFallbacks(req, notBefore); err != nil {
+		return nil, err
+	}
+	strategy, err := s.codeStrategy(req.CodeStrategy)
+	if err != nil {
+		return nil, err
 	}
 
-	s.urls[shortCode] = req.OriginalUrl
-	s.createdAt[shortCode] = time.Now()
+	// The reputation lookup runs while the code is picked
+	screened := s.screenDestinations(ctx, append(routes.URLs(), originalURL, req.FallbackUrl, req.ComingSoonUrl)...)
 
-	// Persist to storage (async)
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		defer cancel()
+	// Reuse an existing code for the same destination. Custom aliases,
+	// limited links, tagged links and links with routes or a fallback
+	// always create a new link.
+	if req.CustomAlias == "" && req.MaxClicks == 0 && req.FallbackUrl == "" && len(tags) == 0 && routes.plain() && (req.ReuseExisting || s.dedupURLs) {
+		if existing := s.findExistingShortCode(ctx, originalURL); existing != "" {
+			if err := screened(); err != nil {
+				return nil, err
+			}
+			logf(ctx, "Reusing existing short code %s for %s", existing, originalURL)
+			return s.existingLinkResponse(ctx, existing, requestedURL, originalURL), nil
+		}
+	}
 
-		_, err := s.storageClient.SaveURL(ctx, &storage_service.SaveURLRequest{
-			ShortCode:   shortCode,
-			OriginalUrl: req.OriginalUrl,
-		})
+	// A hash code that already holds the URL is its link, whatever else the
+	// request asks for
+	var shortCode string
+	if req.CustomAlias == "" && strategy == codeStrategyHash {
+		key, existing, err := s.hashShortCode(ctx, originalURL)
 		if err != nil {
-			log.Printf("Warning: failed to persist URL to storage: %v", err)
-		} else {
-			log.Printf("URL persisted to storage: %s", shortCode)
+			return nil, err
 		}
-	}()
+		if existing {
+			if err := screened(); err != nil {
+				return nil, err
+			}
+			logf(ctx, "Hash code %s already holds %s", key, originalURL)
+			return s.existingLinkResponse(ctx, key, requestedURL, originalURL), nil
+		}
+		shortCode = key
+	}
 
-	// Cache the URL with initial count (async)
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		defer cancel()
+	if err := s.checkQuota(ctx, 1); err != nil {
+		return nil, err
+	}
 
-		// Cache URL value
-		_, err := s.cacheClient.Set(ctx, &cache_service.SetRequest{
-			Key:        "url:" + shortCode,
-			Value:      req.OriginalUrl,
-			TtlSeconds: 60,
-		})
+	if req.CustomAlias != "" {
+		if err := s.aliases.Validate(req.CustomAlias); err != nil {
+			return nil, err
+		}
+		shortCode = tenantKey(tenantID(ctx), req.CustomAlias)
+		if err := s.checkAliasAvailable(ctx, shortCode); err != nil {
+			return nil, err
+		}
+	} else if shortCode == "" {
+		shortCode, err = s.generateUniqueShortCode(ctx, strategy)
 		if err != nil {
-			log.Printf("Warning: failed to cache URL: %v", err)
+			return nil, err
 		}
+	}
+
+	if err := screened(); err != nil {
+		return nil, err
+	}
+
+	createdAt := time.Now()
+	added := s.urls.AddIfAbsent(shortCode, urlEntry{
+		originalURL: originalURL,
+		createdAt:   createdAt,
+		expiresAt:   expiresAt,
+		userID:      userID(ctx),
+		maxClicks:   req.MaxClicks,
+
+		notBefore:     notBefore,
+		comingSoonURL: req.ComingSoonUrl,
+		fallbackURL:   req.FallbackUrl,
+		routes:        routes,
+	})
+	if !added {
+		if req.CustomAlias != "" {
+			return nil, status.Error(codes.AlreadyExists, "Custom alias already exists")
+		}
+		// Another request for the same URL took the hash code first
+		if entry, ok := s.urls.Get(shortCode); ok && entry.originalURL == originalURL {
+			return s.existingLinkResponse(ctx, shortCode, requestedURL, originalURL), nil
+		}
+		return nil, status.Error(codes.Aborted, "short code was taken concurrently, please retry")
+	}
+
+	s.mu.Lock()
+	delete(s.deleted, shortCode)
+	s.mu.Unlock()
+	s.forgetMissing(ctx, shortCode)
+
+	// Async work carries the request's metadata but outlives it
+	bg := detach(ctx)
+
+	// Limited links are persisted inline too, since storage counts their clicks
+	if req.WaitForPersistence || s.syncPersist || req.MaxClicks > 0 {
+		// Persist inline so the caller knows the link is durable
+		storageCtx, cancel := s.storageCtx(ctx)
+		defer cancel()
+		save := &storage_service.SaveURLRequest{
+			ShortCode:      shortCode,
+			OriginalUrl:    originalURL,
+			ExpiresAt:      formatOptionalTime(expiresAt),
+			ApiKeyId:       apiKeyID(ctx),
+			UserId:         userID(ctx),
+			Resurrect:      true,
+			MaxClicks:      req.MaxClicks,
+			FallbackUrl:    req.FallbackUrl,
+			NotBefore:      formatOptionalTime(notBefore),
+			ComingSoonUrl:  req.ComingSoonUrl,
+			Variants:       routes.variants.storage(),
+			StickyVariants: req.StickyVariants,
+			Rules:          routes.rules.storage(),
+			QueryTemplate:  routes.queryTemplate,
+			Tags:           tags,
+			SetTags:        true,
+			TenantId:       tenantID(ctx),
+			CreateOnly:     true,
+		}
+		_, err := s.storageClient.SaveURL(storageCtx, save)
+		if status.Code(err) == codes.AlreadyExists && savedAlready(storageCtx, s.storageClient, save) {
+			err = nil
+		}
+		switch {
+		case status.Code(err) == codes.AlreadyExists:
+			// Another instance saved the code since it was checked
+			s.urls.Remove(shortCode)
+			if req.CustomAlias != "" {
+				return nil, status.Error(codes.AlreadyExists, "Custom alias already exists")
+			}
+			return nil, status.Error(codes.Aborted, "short code was taken concurrently, please retry")
+		case err != nil:
+			logf(ctx, "Failed to persist URL to storage: %v", err)
+			s.urls.Remove(shortCode)
+			return nil, status.Error(codes.Unavailable, "failed to persist URL")
+		}
+		logf(ctx, "URL persisted to storage: %s", shortCode)
+	} else {
+		// Persist to storage (async), retrying in the background on failure
+		s.tasks.Submit("persist "+shortCode, func() {
+			s.persister.Save(bg, &pendingSave{
+				ShortCode:     shortCode,
+				OriginalURL:   originalURL,
+				ExpiresAt:     formatOptionalTime(expiresAt),
+				APIKeyID:      apiKeyID(ctx),
+				UserID:        userID(ctx),
+				NotBefore:     formatOptionalTime(notBefore),
+				ComingSoonURL: req.ComingSoonUrl,
+				FallbackURL:   req.FallbackUrl,
+				Variants:      routes.variants,
+				Rules:         routes.rules,
+				QueryTemplate: routes.queryTemplate,
+				Tags:          tags,
+			})
+		})
+	}
+
+	// Cache the URL with initial count (async)
+	s.tasks.Submit("cache "+shortCode, func() {
+		ctx, cancel := s.cacheCtx(bg)
+		defer cancel()
 
 		// Initialize click count in cache
-		_, err = s.cacheClient.Set(ctx, &cache_service.SetRequest{
-			Key:        "count:" + shortCode,
+		entries := []*cache_service.SetRequest{{
+			Namespace:  countNamespace,
+			Key:        shortCode,
 			Value:      "0",
-			TtlSeconds: 60,
-		})
-		if err != nil {
-			log.Printf("Warning: failed to initialize click count: %v", err)
+			TtlSeconds: s.live().cacheTTLSeconds,
+		}}
+		// Cache URL value, never beyond the link's expiry. Limited links
+		// aren't cached, every click has to reach storage, and neither are
+		// links before their window opens.
+		if ttl := s.cacheTTL(expiresAt); ttl > 0 && req.MaxClicks == 0 && isActive(notBefore) {
+			entries = append(entries, &cache_service.SetRequest{
+				Namespace:  urlNamespace,
+				Key:        shortCode,
+				Value:      cacheValue(originalURL, routes),
+				TtlSeconds: ttl,
+			})
 		}
-	}()
 
-	log.Printf("Shortened URL created: %s -> %s", shortCode, req.OriginalUrl)
+		if _, err := s.cacheClient.MSet(ctx, &cache_service.MSetRequest{Entries: entries}); err != nil {
+			logf(ctx, "Warning: failed to cache URL: %v", err)
+		}
+	})
+
+	logf(ctx, "Shortened URL created: %s -> %s", shortCode, originalURL)
+	s.publishCreated(ctx, shortCode, originalURL, expiresAt)
+	s.metadata.Fetch(ctx, shortCode, originalURL)
 
 	return &url_service.ShortenResponse{
-		ShortCode:   shortCode,
-		OriginalUrl: req.OriginalUrl,
+		ShortCode:     shortCode,
+		OriginalUrl:   requestedURL,
+		NormalizedUrl: originalURL,
+		ShortUrl:      s.shortURL(shortCode),
+		CreatedAt:     createdAt.Format(time.RFC3339),
+		ExpiresAt:     formatOptionalTime(expiresAt),
+		Variants:      routes.variants.proto(),
+		Rules:         routes.rules.proto(),
+		QueryTemplate: routes.queryTemplate,
+		Tags:          tags,
+		TenantId:      tenantID(ctx),
 	}, nil
 }
 
 func (s *urlServer) GetOriginalURL(ctx context.Context, req *url_service.GetOriginalRequest) (*url_service.GetOriginalResponse, error) {
-	log.Printf("GetOriginalURL request for: %s", req.ShortCode)
+	logf(ctx, "GetOriginalURL request for: %s", req.ShortCode)
 
-	// 1. First try cache (fastest)
-	cacheResp, err := s.cacheClient.Get(ctx, &cache_service.GetRequest{Key: "url:" + req.ShortCode})
-	if err == nil && cacheResp.Found {
-		log.Printf("Cache hit for: %s", req.ShortCode)
-
-		// Increment count in cache and storage (async)
-		go s.incrementStats(req.ShortCode)
+	// Lookups are public, but keeping one out of the counts, and out of a
+	// link's max_clicks, is for key holders
+	if req.SkipStats && apiKeyID(ctx) == "" {
+		return nil, status.Error(codes.Unauthenticated, "skip_stats requires an API key")
+	}
 
-		return &url_service.GetOriginalResponse{
-			OriginalUrl: cacheResp.Value,
-			Found:       true,
-		}, nil
+	// 0. A recently deleted code may still have a stale cache entry, only
+	// storage knows whether it has a fallback
+	recentlyDeleted := s.isDeleted(req.ShortCode)
+	if recentlyDeleted {
+		logf(ctx, "URL recently deleted: %s", req.ShortCode)
 	}
 
-	// 2. Try in-memory store
-	s.mu.RLock()
-	originalURL, exists := s.urls[req.ShortCode]
-	s.mu.RUnlock()
+	// 1. Check memory up front, lazily evicting expired entries, so a slow
+	// cache can be hedged with it
+	entry, exists := s.urls.Get(req.ShortCode)
+	exists = exists && !recentlyDeleted
 
-	if exists {
-		log.Printf("Memory hit for: %s", req.ShortCode)
-		// Warm the cache for next time
-		go s.warmCache(req.ShortCode, originalURL)
+	if exists && isExpired(entry.expiresAt) {
+		logf(ctx, "Evicting expired URL from memory: %s", req.ShortCode)
+		s.urls.Remove(req.ShortCode)
+		exists = false
+	}
+
+	// 2. Try the cache, which other replicas keep up to date, within its
+	// share of the deadline. Only fresh memory entries hedge it.
+	fresh := exists && entry.fresh(time.Now())
+	var cachedURL string
+	var cachedRoutes linkRoutes
+	if !recentlyDeleted {
+		cachedURL, cachedRoutes = s.readCachedURL(ctx, req.ShortCode, fresh)
+	}
+	if cachedURL != "" {
+		logf(ctx, "Cache hit for: %s", req.ShortCode)
+		s.metrics.lookup("cache")
+		if !exists {
+			s.rememberCached(req.ShortCode, cachedURL, cachedRoutes)
+		}
+		target := s.destination(ctx, req, cachedURL, cachedRoutes)
 
 		// Increment count in cache and storage (async)
-		go s.incrementStats(req.ShortCode)
+		if !req.SkipStats {
+			s.recordClick(ctx, req, target)
+		}
 
-		return &url_service.GetOriginalResponse{
-			OriginalUrl: originalURL,
-			Found:       true,
-		}, nil
+		return target.response(), nil
 	}
 
-	// 3. Try persistent storage (slowest)
-	storageResp, err := s.storageClient.GetURL(ctx, &storage_service.GetURLRequest{ShortCode: req.ShortCode})
-	if err == nil && storageResp.Found {
-		log.Printf("Storage hit for: %s", req.ShortCode)
+	// 3. Fall back to memory
+	if fresh {
+		logf(ctx, "Memory hit for: %s", req.ShortCode)
+		s.metrics.lookup("memory")
+		return s.memoryResponse(ctx, req, entry)
+	}
 
-		s.mu.Lock()
-		s.urls[req.ShortCode] = storageResp.OriginalUrl
-		s.createdAt[req.ShortCode] = time.Now()
-		s.mu.Unlock()
+	// 4. Skip storage for codes recently found not to exist
+	if !exists && s.isKnownMissing(ctx, req.ShortCode) {
+		logf(ctx, "Negative cache hit for: %s", req.ShortCode)
+		s.metrics.lookup("negative_cache")
+		return nil, status.Error(codes.NotFound, "URL not found")
+	}
 
-		go s.warmCache(req.ShortCode, storageResp.OriginalUrl)
+	// 5. Try persistent storage (slowest) with the rest of the deadline,
+	// which also revalidates a stale memory entry
+	stored, found, err := s.loadFromStorage(ctx, req.ShortCode)
+	if err != nil {
+		if exists {
+			return s.serveStale(ctx, req, entry, err)
+		}
+		return nil, err
+	}
+	if exists && (!found || stored.deleted) {
+		// Not written yet, memory is all there is
+		if !found && s.persister.IsPending(req.ShortCode) {
+			logf(ctx, "Memory hit for unpersisted URL: %s", req.ShortCode)
+			s.metrics.lookup("memory")
+			return s.memoryResponse(ctx, req, entry)
+		}
+		logf(ctx, "Dropping stale URL from memory: %s", req.ShortCode)
+		s.urls.Remove(req.ShortCode)
+	}
+	if found && stored.deleted {
+		logf(ctx, "URL deleted: %s", req.ShortCode)
+		s.metrics.lookup("deleted")
+		if resp := s.fallbackResponse(ctx, req.ShortCode, stored.fallbackURL, resolutionDeleted); resp != nil {
+			return resp, nil
+		}
+		s.rememberMissing(ctx, req.ShortCode)
+		return nil, status.Error(codes.NotFound, "URL not found")
+	}
+	if found && isExpired(stored.expiresAt) {
+		logf(ctx, "URL expired: %s", req.ShortCode)
+		s.metrics.lookup("expired")
+		return s.expiredResponse(ctx, req.ShortCode, stored), nil
+	}
+	if found {
+		logf(ctx, "Storage hit for: %s", req.ShortCode)
+		s.metrics.lookup("storage")
+		if stored.disabled {
+			return s.disabledResponse(ctx, req.ShortCode, stored), nil
+		}
+		if !isActive(stored.notBefore) {
+			return notYetActive(ctx, req.ShortCode, stored)
+		}
+		if stored.maxClicks > 0 {
+			return s.claimClick(ctx, req, stored)
+		}
+		target := s.destination(ctx, req, stored.originalURL, stored.routes)
 
 		// Increment count in cache and storage (async)
-		go s.incrementStats(req.ShortCode)
+		if !req.SkipStats {
+			s.recordClick(ctx, req, target)
+		}
 
-		return &url_service.GetOriginalResponse{
-			OriginalUrl: storageResp.OriginalUrl,
-			Found:       true,
-		}, nil
+		return target.response(), nil
 	}
 
-	log.Printf("URL not found: %s", req.ShortCode)
-	return &url_service.GetOriginalResponse{
-		Found: false,
-	}, nil
+	logf(ctx, "URL not found: %s", req.ShortCode)
+	s.metrics.lookup("not_found")
+	s.rememberMissing(ctx, req.ShortCode)
+	return nil, status.Error(codes.NotFound, "URL not found")
+}
+
+// memoryResponse answers a lookup from a memory entry and warms the cache
+// with it.
+func (s *urlServer) memoryResponse(ctx context.Context, req *url_service.GetOriginalRequest, entry urlEntry) (*url_service.GetOriginalResponse, error) {
+	if entry.disabled {
+		return s.disabledResponse(ctx, req.ShortCode, entry), nil
+	}
+	if !isActive(entry.notBefore) {
+		return notYetActive(ctx, req.ShortCode, entry)
+	}
+	if entry.maxClicks > 0 {
+		return s.claimClick(ctx, req, entry)
+	}
+	// Warm the cache for next time
+	bg := detach(ctx)
+	s.tasks.Submit("warm cache "+req.ShortCode, func() {
+		s.warmCache(bg, req.ShortCode, cacheValue(entry.originalURL, entry.routes), entry.expiresAt)
+	})
+	target := s.destination(ctx, req, entry.originalURL, entry.routes)
+
+	// Increment count in cache and storage (async)
+	if !req.SkipStats {
+		s.recordClick(ctx, req, target)
+	}
+
+	return target.response(), nil
 }
 
 func (s *urlServer) GetURLStats(ctx context.Context, req *url_service.StatsRequest) (*url_service.StatsResponse, error) {
-	log.Printf("GetURLStats request for: %s", req.ShortCode)
+	logf(ctx, "GetURLStats request for: %s", req.ShortCode)
+
+	resp, err := s.urlStats(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.IncludeBreakdowns {
+		if err := s.addBreakdowns(ctx, resp); err != nil {
+			return nil, err
+		}
+	}
+	if req.IncludeMetadata {
+		if err := s.addMetadata(ctx, resp); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
 
+// urlStats returns the click counts, creation and expiry time of a URL.
+func (s *urlServer) urlStats(ctx context.Context, req *url_service.StatsRequest) (*url_service.StatsResponse, error) {
 	// 1. Try to get click count from cache first
-	countResp, err := s.cacheClient.Get(ctx, &cache_service.GetRequest{Key: "count:" + req.ShortCode})
+	cacheCtx, cancel := s.cacheReadCtx(ctx)
+	countResp, err := s.cacheClient.Get(cacheCtx, &cache_service.GetRequest{Namespace: countNamespace, Key: req.ShortCode})
+	cancel()
 	if err == nil && countResp.Found {
 		clickCount, err := strconv.ParseInt(countResp.Value, 10, 64)
 		if err == nil {
-			log.Printf("Cache stats hit for: %s, count: %d", req.ShortCode, clickCount)
-
-			// Try to get creation time
-			var createdAt time.Time
-			s.mu.RLock()
-			if ct, exists := s.createdAt[req.ShortCode]; exists {
-				createdAt = ct
+			logf(ctx, "Cache stats hit for: %s, count: %d", req.ShortCode, clickCount)
+			tallies, talliesCached := s.cachedTallies(ctx, req.ShortCode)
+
+			// Try to get creation and expiry time. A cached count can lag
+			// behind what storage already had, so never report less.
+			var createdAt, expiresAt time.Time
+			if entry, exists := s.urls.Get(req.ShortCode); exists {
+				createdAt = entry.createdAt
+				expiresAt = entry.expiresAt
+				clickCount = max(clickCount, entry.clickCount)
 			}
-			s.mu.RUnlock()
 
-			// If creation time not in memory, get from storage
-			if createdAt.IsZero() {
-				storageResp, err := s.storageClient.GetStats(ctx, &storage_service.GetStatsRequest{ShortCode: req.ShortCode})
+			// If creation time or the tallies aren't known here, get
+			// them from storage
+			if createdAt.IsZero() || !talliesCached {
+				storageCtx, cancel := s.storageCtx(ctx)
+				storageResp, err := s.storageClient.GetStats(storageCtx, &storage_service.GetStatsRequest{ShortCode: req.ShortCode})
+				cancel()
 				if err == nil && storageResp.Error == "" {
+					expiresAt = parseOptionalTime(storageResp.ExpiresAt)
+					clickCount = max(clickCount, storageResp.ClickCount)
+					tallies = storageTallies(storageResp)
+					if !talliesCached {
+						s.cacheTallies(ctx, req.ShortCode, tallies)
+					}
 					// Parse storage creation time
 					if ct, err := time.Parse(time.RFC3339, storageResp.CreatedAt); err == nil {
 						createdAt = ct
 						// Cache the creation time in memory for future requests
-						s.mu.Lock()
-						s.createdAt[req.ShortCode] = createdAt
-						s.mu.Unlock()
+						s.setCreatedAt(req.ShortCode, createdAt)
 					}
 				}
 			}
 
+			tallies = tallies.merge(s.clicks.PendingTallies(req.ShortCode))
 			return &url_service.StatsResponse{
-				ShortCode:  req.ShortCode,
-				ClickCount: clickCount,
-				CreatedAt:  createdAt.Format(time.RFC3339),
+				ShortCode:      req.ShortCode,
+				ClickCount:     clickCount + s.clicks.Pending(req.ShortCode),
+				UniqueClicks:   tallies.unique,
+				BotClicks:      tallies.bot,
+				CreatedAt:      createdAt.Format(time.RFC3339),
+				ExpiresAt:      formatOptionalTime(expiresAt),
+				Expired:        isExpired(expiresAt),
+				LastAccessedAt: formatOptionalTime(tallies.lastAccessed),
 			}, nil
 		}
 	}
 
 	// 2. Fall back to storage if cache miss
-	storageResp, err := s.storageClient.GetStats(ctx, &storage_service.GetStatsRequest{ShortCode: req.ShortCode})
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	storageResp, err := s.storageClient.GetStats(storageCtx, &storage_service.GetStatsRequest{ShortCode: req.ShortCode})
+	if err != nil && status.Code(err) != codes.NotFound {
+		logf(ctx, "Storage stats lookup failed for %s: %v", req.ShortCode, err)
+		return nil, status.Error(codes.Unavailable, "storage unavailable")
+	}
 	if err == nil && storageResp.Error == "" {
 		// Update cache with stats from storage (async)
-		go func() {
-			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		bg := detach(ctx)
+		s.tasks.Submit("cache stats "+req.ShortCode, func() {
+			ctx, cancel := s.cacheCtx(bg)
 			defer cancel()
 
 			// Cache the count
 			countStr := fmt.Sprintf("%d", storageResp.ClickCount)
 			_, err := s.cacheClient.Set(ctx, &cache_service.SetRequest{
-				Key:        "count:" + req.ShortCode,
+				Namespace:  countNamespace,
+				Key:        req.ShortCode,
 				Value:      countStr,
-				TtlSeconds: 60,
+				TtlSeconds: s.live().cacheTTLSeconds,
 			})
 			if err != nil {
-				log.Printf("Warning: failed to cache stats: %v", err)
+				logf(ctx, "Warning: failed to cache stats: %v", err)
 			}
-		}()
+		})
+		tallies := storageTallies(storageResp)
+		s.cacheTallies(ctx, req.ShortCode, tallies)
 
 		// Cache creation time in memory
 		if createdAt, err := time.Parse(time.RFC3339, storageResp.CreatedAt); err == nil {
-			s.mu.Lock()
-			s.createdAt[req.ShortCode] = createdAt
-			s.mu.Unlock()
+			s.setCreatedAt(req.ShortCode, createdAt)
 		}
 
+		tallies = tallies.merge(s.clicks.PendingTallies(req.ShortCode))
 		return &url_service.StatsResponse{
-			ShortCode:  req.ShortCode,
-			ClickCount: storageResp.ClickCount,
-			CreatedAt:  storageResp.CreatedAt,
+			ShortCode:      req.ShortCode,
+			ClickCount:     storageResp.ClickCount + s.clicks.Pending(req.ShortCode),
+			UniqueClicks:   tallies.unique,
+			BotClicks:      tallies.bot,
+			CreatedAt:      storageResp.CreatedAt,
+			ExpiresAt:      storageResp.ExpiresAt,
+			Expired:        isExpired(parseOptionalTime(storageResp.ExpiresAt)),
+			LastAccessedAt: formatOptionalTime(tallies.lastAccessed),
 		}, nil
 	}
 
-	return &url_service.StatsResponse{
-		Error: "URL not found",
+	return nil, status.Error(codes.NotFound, "URL not found")
+}
+
+// cachedTallies returns the unique and bot click counts and last access
+// cached for shortCode, stored as "unique bot unix_seconds" with 0 for never
+// accessed, and whether there were any.
+func (s *urlServer) cachedTallies(ctx context.Context, shortCode string) (clickTallies, bool) {
+	cacheCtx, cancel := s.cacheReadCtx(ctx)
+	defer cancel()
+	resp, err := s.cacheClient.Get(cacheCtx, &cache_service.GetRequest{Namespace: talliesNamespace, Key: shortCode})
+	if err != nil || !resp.Found {
+		return clickTallies{}, false
+	}
+	var t clickTallies
+	var lastAccessed int64
+	// Values cached before the last access was, with two fields, miss
+	if _, err := fmt.Sscanf(resp.Value, "%d %d %d", &t.unique, &t.bot, &lastAccessed); err != nil {
+		return clickTallies{}, false
+	}
+	if lastAccessed > 0 {
+		t.lastAccessed = time.Unix(lastAccessed, 0).UTC()
+	}
+	return t, true
+}
+
+// cacheTallies caches the unique and bot click counts and last access
+// storage returned for shortCode, in the background.
+func (s *urlServer) cacheTallies(ctx context.Context, shortCode string, t clickTallies) {
+	bg := detach(ctx)
+	s.tasks.Submit("cache click tallies "+shortCode, func() {
+		ctx, cancel := s.cacheCtx(bg)
+		defer cancel()
+		_, err := s.cacheClient.Set(ctx, &cache_service.SetRequest{
+			Namespace:  talliesNamespace,
+			Key:        shortCode,
+			Value:      fmt.Sprintf("%d %d %d", t.unique, t.bot, unixOrZero(t.lastAccessed)),
+			TtlSeconds: s.live().cacheTTLSeconds,
+		})
+		if err != nil {
+			logf(ctx, "Warning: failed to cache click tallies: %v", err)
+		}
+	})
+}
+
+// storageTallies returns the tallies of a storage stats response.
+func storageTallies(resp *storage_service.GetStatsResponse) clickTallies {
+	return clickTallies{
+		unique:       resp.UniqueClicks,
+		bot:          resp.BotClicks,
+		lastAccessed: parseOptionalTime(resp.LastAccessedAt),
+	}
+}
+
+// lastAccessedAt is the later of the last access storage has and that of
+// clicks not yet flushed to it.
+func lastAccessedAt(stored string, pending clickTallies) string {
+	t := parseOptionalTime(stored)
+	if pending.lastAccessed.After(t) {
+		t = pending.lastAccessed
+	}
+	return formatOptionalTime(t)
+}
+
+// unixOrZero returns t in Unix seconds, 0 for the zero time.
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// addBreakdowns adds storage's click breakdowns to resp. Unlike the count,
+// they aren't cached, so they fail without storage.
+func (s *urlServer) addBreakdowns(ctx context.Context, resp *url_service.StatsResponse) error {
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	breakdown, err := s.storageClient.GetClickBreakdown(storageCtx, &storage_service.GetClickBreakdownRequest{ShortCode: resp.ShortCode})
+	if err != nil {
+		logf(ctx, "Storage click breakdown failed for %s: %v", resp.ShortCode, err)
+		return status.Error(codes.Unavailable, "storage unavailable")
+	}
+	resp.TopReferrers = breakdownEntries(breakdown.Referrers)
+	resp.Countries = breakdownEntries(breakdown.Countries)
+	resp.Browsers = breakdownEntries(breakdown.Browsers)
+	resp.Devices = breakdownEntries(breakdown.Devices)
+	resp.Variants = breakdownEntries(breakdown.Variants)
+	resp.Rules = breakdownEntries(breakdown.Rules)
+	return nil
+}
+
+// addMetadata adds the destination page's title, description and image
+// storage has for resp's link. Like the breakdowns they aren't cached.
+func (s *urlServer) addMetadata(ctx context.Context, resp *url_service.StatsResponse) error {
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	stats, err := s.storageClient.GetStats(storageCtx, &storage_service.GetStatsRequest{ShortCode: resp.ShortCode})
+	if status.Code(err) == codes.NotFound {
+		// Not persisted yet, so not fetched yet either
+		return nil
+	}
+	if err != nil {
+		logf(ctx, "Storage metadata lookup failed for %s: %v", resp.ShortCode, err)
+		return status.Error(codes.Unavailable, "storage unavailable")
+	}
+	resp.Title = stats.Title
+	resp.Description = stats.Description
+	resp.ImageUrl = stats.ImageUrl
+	return nil
+}
+
+func breakdownEntries(entries []*storage_service.BreakdownEntry) []*url_service.BreakdownEntry {
+	out := make([]*url_service.BreakdownEntry, len(entries))
+	for i, e := range entries {
+		out[i] = &url_service.BreakdownEntry{Value: e.Value, Clicks: e.Clicks}
+	}
+	return out
+}
+
+func (s *urlServer) DeleteURL(ctx context.Context, req *url_service.DeleteURLRequest) (*url_service.DeleteURLResponse, error) {
+	logf(ctx, "DeleteURL request for: %s", req.ShortCode)
+
+	if req.ShortCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "short code is required")
+	}
+	if err := s.checkOwner(ctx, req.ShortCode); err != nil {
+		return nil, err
+	}
+
+	// 1. Delete from storage first so a storage failure leaves everything intact
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	_, err := s.storageClient.DeleteURL(storageCtx, &storage_service.DeleteURLRequest{ShortCode: req.ShortCode})
+	notFound := status.Code(err) == codes.NotFound
+	if err != nil && !notFound {
+		logf(ctx, "Failed to delete URL from storage: %v", err)
+		return nil, status.Error(codes.Unavailable, "failed to delete URL from storage")
+	}
+
+	// 2. Drop the in-memory copy and remember the deletion until any stale
+	// cache entries have expired
+	inMemory := s.urls.Remove(req.ShortCode)
+	s.persister.Forget(req.ShortCode)
+	s.markDeleted(req.ShortCode)
+
+	// 3. Invalidate the cache, even if the caller has given up by now
+	s.invalidateCache(detach(ctx), req.ShortCode)
+
+	if notFound && !inMemory {
+		logf(ctx, "URL not found for delete: %s", req.ShortCode)
+		return nil, status.Error(codes.NotFound, "URL not found")
+	}
+
+	logf(ctx, "URL deleted: %s", req.ShortCode)
+	s.publishDeleted(req.ShortCode)
+	return &url_service.DeleteURLResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *urlServer) UpdateURL(ctx context.Context, req *url_service.UpdateURLRequest) (*url_service.UpdateURLResponse, error) {
+	logf(ctx, "UpdateURL request for: %s -> %s", req.ShortCode, req.OriginalUrl)
+
+	if req.ShortCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "short code is required")
+	}
+
+	linkURL := req.OriginalUrl
+	if linkURL != "" {
+		var err error
+		if linkURL, err = s.resolveLinkChain(ctx, linkURL, req.ShortCode, true); err != nil {
+			logf(ctx, "Rejected URL: %v", err)
+			return nil, err
+		}
+	}
+
+	// An update replaces the routes too: without any the link is plain
+	originalURL, routes, err := s.newLinkRoutes(linkURL, req.Variants, req.StickyVariants, req.Rules, req.QueryTemplate)
+	if err != nil {
+		logf(ctx, "Rejected URL: %v", err)
+		return nil, err
+	}
+	// Tags are only replaced when asked to, so updating a destination
+	// doesn't need them
+	if len(req.Tags) > 0 && !req.SetTags {
+		return nil, status.Error(codes.InvalidArgument, "tags need set_tags")
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. Make sure the code exists and belongs to the caller before writing
+	// through the upsert
+	if err := s.checkOwner(ctx, req.ShortCode); err != nil {
+		return nil, err
+	}
+	current, err := s.lookupURL(ctx, req.ShortCode)
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpectedOriginalUrl != "" && current.originalURL != req.ExpectedOriginalUrl {
+		return nil, status.Error(codes.FailedPrecondition, "URL no longer points at the expected destination")
+	}
+	if err := s.screenDestinations(ctx, append(routes.URLs(), originalURL)...)(); err != nil {
+		return nil, err
+	}
+
+	// 2. Write through to storage, which rejects the write if another update won the race
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	_, err = s.storageClient.SaveURL(storageCtx, &storage_service.SaveURLRequest{
+		ShortCode:           req.ShortCode,
+		OriginalUrl:         originalURL,
+		ExpectedOriginalUrl: req.ExpectedOriginalUrl,
+		Variants:            routes.variants.storage(),
+		StickyVariants:      req.StickyVariants,
+		Rules:               routes.rules.storage(),
+		QueryTemplate:       routes.queryTemplate,
+		Tags:                tags,
+		SetTags:             req.SetTags,
+	})
+	if status.Code(err) == codes.FailedPrecondition {
+		return nil, err
+	} else if err != nil {
+		logf(ctx, "Failed to update URL in storage: %v", err)
+		return nil, status.Error(codes.Unavailable, "failed to update URL in storage")
+	}
+
+	// 3. Update memory and any save still waiting to be retried
+	s.persister.Update(req.ShortCode, originalURL, routes)
+	if req.SetTags {
+		s.persister.UpdateTags(req.ShortCode, tags)
+	}
+	s.forgetMissing(ctx, req.ShortCode)
+	var expiresAt time.Time
+	s.urls.Update(req.ShortCode, func(entry *urlEntry) {
+		entry.originalURL = originalURL
+		entry.routes = routes
+		expiresAt = entry.expiresAt
+	})
+
+	// 4. Invalidate then re-set the cache so the old destination isn't served until its TTL
+	s.refreshCachedURL(detach(ctx), req.ShortCode, cacheValue(originalURL, routes), expiresAt, current.maxClicks > 0 || current.disabled || !isActive(current.notBefore))
+
+	logf(ctx, "URL updated: %s -> %s", req.ShortCode, originalURL)
+	return &url_service.UpdateURLResponse{
+		ShortCode:     req.ShortCode,
+		OriginalUrl:   originalURL,
+		Variants:      routes.variants.proto(),
+		Rules:         routes.rules.proto(),
+		QueryTemplate: routes.queryTemplate,
+		Tags:          tags,
 	}, nil
 }
 
 // Helper methods
-func (s *urlServer) incrementStats(shortCode string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+
+// shortURL returns the public link for the code keyed by key under its
+// tenant's base URL, which may carry a path prefix such as
+// https://sho.rt/r/. Without a base URL it returns "" rather than guess a
+// host.
+func (s *urlServer) shortURL(key string) string {
+	tenant, shortCode := splitTenantKey(key)
+	baseURL := s.tenants.baseURLs[tenant]
+	if baseURL == "" {
+		return ""
+	}
+	link, err := url.JoinPath(baseURL, shortCode)
+	if err != nil {
+		// Base URLs are validated at startup
+		return ""
+	}
+	return link
+}
+
+// canonicalURL returns the form of a validated URL that is stored, which is
+// the normalized URL when normalization is enabled.
+func (s *urlServer) canonicalURL(rawURL string) (string, error) {
+	if !s.normalizeURLs {
+		return rawURL, nil
+	}
+	normalized, err := normalizeURL(rawURL)
+	if err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "original URL could not be normalized: %v", err)
+	}
+	return normalized, nil
+}
+
+// findExistingShortCode returns the oldest short code stored for the
+// original URL, or "" if there is none. Dedup is best effort, so storage
+// errors are logged and treated as no match.
+func (s *urlServer) findExistingShortCode(ctx context.Context, originalURL string) string {
+	storageCtx, cancel := s.storageCtx(ctx)
 	defer cancel()
+	resp, err := s.storageClient.FindByOriginalURL(storageCtx, &storage_service.FindByOriginalURLRequest{
+		OriginalUrl:   originalURL,
+		Limit:         1,
+		UnlimitedOnly: true,
+		ActiveOnly:    true,
+		PlainOnly:     true,
+		TenantId:      tenantID(ctx),
+	})
+	if err != nil {
+		logf(ctx, "Warning: failed to look up existing short code: %v", err)
+		return ""
+	}
+	if len(resp.ShortCodes) == 0 {
+		return ""
+	}
 
-	// 1. Get current count from cache
-	countResp, err := s.cacheClient.Get(ctx, &cache_service.GetRequest{Key: "count:" + shortCode})
+	shortCode := resp.ShortCodes[0]
+	s.urls.AddIfAbsent(shortCode, urlEntry{originalURL: originalURL})
 
-	if err == nil && countResp.Found {
-		currentCount, parseErr := strconv.ParseInt(countResp.Value, 10, 64)
-		if parseErr == nil {
-			newCount := currentCount + 1
-			newCountStr := fmt.Sprintf("%d", newCount)
-
-			// Update cache with new count
-			_, err = s.cacheClient.Set(ctx, &cache_service.SetRequest{
-				Key:        "count:" + shortCode,
-				Value:      newCountStr,
-				TtlSeconds: 60,
-			})
+	return shortCode
+}
 
-			if err == nil {
-				// Async update to storage
-				go func() {
-					storageCtx, storageCancel := context.WithTimeout(context.Background(), 3*time.Second)
-					defer storageCancel()
-
-					_, err := s.storageClient.IncrementClick(storageCtx, &storage_service.IncrementClickRequest{
-						ShortCode: shortCode,
-					})
-					if err != nil {
-						log.Printf("Warning: failed to update storage stats: %v", err)
-					}
-				}()
+// loadFromStorage fetches a short code from storage into memory and warms the
+// cache. Concurrent misses for the same code share one storage lookup, which
+// runs detached from any single caller so one caller giving up doesn't fail
+// the others; each caller still returns when its own context is done.
+// Expired and deleted codes are returned so callers can tell them from
+// unknown ones, but they aren't kept.
+func (s *urlServer) loadFromStorage(ctx context.Context, shortCode string) (urlEntry, bool, error) {
+	bg := detach(ctx)
+	ch := s.flights.DoChan("url:"+shortCode, func() (interface{}, error) {
+		lookupCtx, cancel := s.storageCtx(bg)
+		defer cancel()
 
-				log.Printf("Cache count incremented for %s: %d -> %d", shortCode, currentCount, newCount)
-				return
-			}
+		start := time.Now()
+		storageResp, err := s.storageClient.GetURL(lookupCtx, &storage_service.GetURLRequest{
+			ShortCode:      shortCode,
+			IncludeExpired: true,
+			IncludeDeleted: true,
+		})
+		s.metrics.layerDuration("storage", time.Since(start))
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		if err != nil {
+			logf(ctx, "Storage lookup failed for %s: %v", shortCode, err)
+			return nil, status.Error(codes.Unavailable, "storage unavailable")
+		}
+		if !storageResp.Found {
+			return nil, nil
 		}
-	}
 
-	// If cache operation failed, try storage directly
-	log.Printf("Cache operation failed for %s, falling back to storage: %v", shortCode, err)
+		createdAt, err := time.Parse(time.RFC3339, storageResp.CreatedAt)
+		if err != nil {
+			createdAt = time.Now()
+		}
+		entry := urlEntry{
+			originalURL: storageResp.OriginalUrl,
+			createdAt:   createdAt,
+			expiresAt:   parseOptionalTime(storageResp.ExpiresAt),
+			clickCount:  storageResp.ClickCount,
+			userID:      storageResp.UserId,
+			maxClicks:   storageResp.MaxClicks,
+
+			notBefore:     parseOptionalTime(storageResp.NotBefore),
+			comingSoonURL: storageResp.ComingSoonUrl,
+			fallbackURL:   storageResp.FallbackUrl,
+			disabled:      storageResp.Disabled,
+			deleted:       storageResp.Deleted,
+			routes:        routesFromStorage(storageResp),
+		}
+		if entry.deleted || isExpired(entry.expiresAt) {
+			return entry, nil
+		}
+		s.urls.Set(shortCode, entry)
+		if entry.maxClicks > 0 || entry.disabled || !isActive(entry.notBefore) {
+			return entry, nil
+		}
 
-	storageCtx, storageCancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer storageCancel()
+		s.tasks.Submit("warm cache "+shortCode, func() {
+			s.warmCache(bg, shortCode, cacheValue(entry.originalURL, entry.routes), entry.expiresAt)
+		})
 
-	_, err = s.storageClient.IncrementClick(storageCtx, &storage_service.IncrementClickRequest{
-		ShortCode: shortCode,
+		return entry, nil
 	})
+
+	select {
+	case <-ctx.Done():
+		return urlEntry{}, false, status.FromContextError(ctx.Err()).Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return urlEntry{}, false, res.Err
+		}
+		if res.Val == nil {
+			return urlEntry{}, false, nil
+		}
+		return res.Val.(urlEntry), true, nil
+	}
+}
+
+// lookupURL returns the current destination and click limit of a short
+// code from memory or storage without touching the cache or stats. Storage
+// is read on its primary, since the result guards an update.
+func (s *urlServer) lookupURL(ctx context.Context, shortCode string) (urlEntry, error) {
+	if entry, exists := s.urls.Get(shortCode); exists && !isExpired(entry.expiresAt) && entry.fresh(time.Now()) {
+		return entry, nil
+	}
+
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	storageResp, err := s.storageClient.GetURL(storageCtx, &storage_service.GetURLRequest{ShortCode: shortCode, ForcePrimary: true})
+	if status.Code(err) == codes.NotFound || (err == nil && !storageResp.Found) {
+		return urlEntry{}, status.Error(codes.NotFound, "URL not found")
+	}
 	if err != nil {
-		log.Printf("Warning: failed to increment stats in storage: %v", err)
+		logf(ctx, "Storage lookup failed for %s: %v", shortCode, err)
+		return urlEntry{}, status.Error(codes.Unavailable, "storage unavailable")
 	}
+	return urlEntry{
+		originalURL: storageResp.OriginalUrl,
+		maxClicks:   storageResp.MaxClicks,
+		notBefore:   parseOptionalTime(storageResp.NotBefore),
+		disabled:    storageResp.Disabled,
+		routes:      routesFromStorage(storageResp),
+	}, nil
 }
 
-func (s *urlServer) warmCache(shortCode, originalURL string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// refreshCachedURL deletes and then re-sets the cached destination of a
+// short code, value as made by cacheValue. If the set fails the delete
+// still prevents stale redirects. Links that mustn't be cached, such as
+// limited ones, are only deleted.
+func (s *urlServer) refreshCachedURL(ctx context.Context, shortCode, value string, expiresAt time.Time, uncached bool) {
+	ctx, cancel := s.cacheCtx(ctx)
 	defer cancel()
 
-	// Cache URL
+	if _, err := s.cacheClient.Delete(ctx, &cache_service.DeleteRequest{Namespace: urlNamespace, Key: shortCode}); err != nil {
+		logf(ctx, "Warning: failed to invalidate cached URL for %s: %v", shortCode, err)
+	}
+
+	ttl := s.cacheTTL(expiresAt)
+	if ttl <= 0 || uncached {
+		return
+	}
 	_, err := s.cacheClient.Set(ctx, &cache_service.SetRequest{
-		Key:        "url:" + shortCode,
-		Value:      originalURL,
-		TtlSeconds: 60,
+		Namespace:  urlNamespace,
+		Key:        shortCode,
+		Value:      value,
+		TtlSeconds: ttl,
 	})
 	if err != nil {
-		log.Printf("Warning: failed to warm URL cache: %v", err)
+		logf(ctx, "Warning: failed to cache updated URL: %v", err)
+	}
+}
+
+// setCreatedAt records the creation time of a short code that is already
+// held in memory.
+func (s *urlServer) setCreatedAt(shortCode string, createdAt time.Time) {
+	s.urls.Update(shortCode, func(entry *urlEntry) {
+		entry.createdAt = createdAt
+	})
+}
+
+// markDeleted remembers that shortCode is gone until any stale cache entries
+// for it have expired.
+func (s *urlServer) markDeleted(shortCode string) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for code, until := range s.deleted {
+		if now.After(until) {
+			delete(s.deleted, code)
+		}
+	}
+	s.deleted[shortCode] = now.Add(time.Duration(s.live().cacheTTLSeconds) * time.Second)
+}
+
+func (s *urlServer) isDeleted(shortCode string) bool {
+	s.mu.RLock()
+	until, deleted := s.deleted[shortCode]
+	s.mu.RUnlock()
+	return deleted && time.Now().Before(until)
+}
+
+// forgetLost drops a new URL whose code another URL took in storage before
+// it was persisted, so the code resolves to that one.
+func (s *urlServer) forgetLost(shortCode string) {
+	s.urls.Remove(shortCode)
+	s.tasks.Submit("invalidate "+shortCode, func() {
+		s.invalidateCache(context.Background(), shortCode)
+	})
+}
+
+// invalidateCache removes every cache entry for a short code, retrying
+// failed deletes. Keys that can't be deleted are logged so they can be
+// invalidated manually, and returned.
+func (s *urlServer) invalidateCache(ctx context.Context, shortCode string) error {
+	var failed []error
+	for _, namespace := range []string{urlNamespace, countNamespace, talliesNamespace} {
+		key := namespace + ":" + shortCode
+		var err error
+		for attempt := 1; attempt <= cacheDeleteAttempts; attempt++ {
+			cacheCtx, cancel := s.cacheCtx(ctx)
+			_, err = s.cacheClient.Delete(cacheCtx, &cache_service.DeleteRequest{Namespace: namespace, Key: shortCode})
+			cancel()
+			if err == nil {
+				break
+			}
+			logf(ctx, "Failed to delete cache key %s (attempt %d/%d): %v", key, attempt, cacheDeleteAttempts, err)
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+		if err != nil {
+			logf(ctx, "Warning: cache key %s could not be invalidated and needs manual invalidation: %v", key, err)
+			failed = append(failed, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// incrementStats records a click. Clicks are written to storage in batches
+// by the click batcher, which also invalidates the cached count.
+func (s *urlServer) incrementStats(click *storage_service.ClickEvent) {
+	s.clicks.Add(click)
+}
+
+// recordClick counts the click a lookup makes and whether it is unique.
+// Prefetches and clicks by bots still redirect but are counted apart, and
+// never as unique.
+func (s *urlServer) recordClick(ctx context.Context, req *url_service.GetOriginalRequest, target linkTarget) {
+	click := s.clickFromRequest(ctx, req)
+	click.Variant, click.Rule = target.Variant, target.Rule
+	bot := req.Prefetch || s.bots.IsBot(req.UserAgent)
+	s.publishClick(click, bot)
+	if bot {
+		s.clicks.AddBot(click)
 		return
 	}
+	s.incrementStats(click)
+	s.trackVisitor(ctx, req.ShortCode, req.UserAgent)
+}
+
+// clickFromRequest describes the click a lookup counts.
+func (s *urlServer) clickFromRequest(ctx context.Context, req *url_service.GetOriginalRequest) *storage_service.ClickEvent {
+	return &storage_service.ClickEvent{
+		ShortCode: req.ShortCode,
+		Referrer:  req.Referrer,
+		UserAgent: req.UserAgent,
+		Country:   s.requestCountry(ctx, req),
+	}
+}
 
-	// Also ensure count exists in cache
-	countResp, err := s.cacheClient.Get(ctx, &cache_service.GetRequest{Key: "count:" + shortCode})
+// requestCountry returns the visitor's country. Without one from the
+// caller, it is looked up from the caller's IP when a GeoIP database is
+// configured, and is "" if that fails too.
+func (s *urlServer) requestCountry(ctx context.Context, req *url_service.GetOriginalRequest) string {
+	if req.Country == "" && s.geoIP != nil {
+		return s.geoIP.CallerCountry(ctx)
+	}
+	return req.Country
+}
+
+// warmCache populates the cache for a short code with value, as made by
+// cacheValue. Concurrent warms of the same code collapse into one.
+func (s *urlServer) warmCache(ctx context.Context, shortCode, value string, expiresAt time.Time) {
+	s.flights.Do("warm:"+shortCode, func() (interface{}, error) {
+		s.doWarmCache(ctx, shortCode, value, expiresAt)
+		return nil, nil
+	})
+}
+
+func (s *urlServer) doWarmCache(parent context.Context, shortCode, value string, expiresAt time.Time) {
+	ctx, cancel := s.cacheCtx(parent)
+	defer cancel()
+
+	ttl := s.cacheTTL(expiresAt)
+	if ttl <= 0 {
+		return
+	}
+
+	entries := []*cache_service.SetRequest{{
+		Namespace:  urlNamespace,
+		Key:        shortCode,
+		Value:      value,
+		TtlSeconds: ttl,
+	}}
+
+	// Also ensure count exists in cache, written together with the URL
+	countResp, err := s.cacheClient.Get(ctx, &cache_service.GetRequest{Namespace: countNamespace, Key: shortCode})
 	if err != nil || !countResp.Found {
 		// try to get from storage
-		storageCtx, storageCancel := context.WithTimeout(context.Background(), 3*time.Second)
+		storageCtx, storageCancel := s.storageCtx(parent)
 		defer storageCancel()
 
 		statsResp, err := s.storageClient.GetStats(storageCtx, &storage_service.GetStatsRequest{ShortCode: shortCode})
 		if err == nil && statsResp.Error == "" {
-			// Cache the count
-			countStr := fmt.Sprintf("%d", statsResp.ClickCount)
-			_, err := s.cacheClient.Set(ctx, &cache_service.SetRequest{
-				Key:        "count:" + shortCode,
-				Value:      countStr,
-				TtlSeconds: 60,
-			})
-			if err != nil {
-				log.Printf("Warning: failed to warm count cache: %v", err)
-			}
-		} else {
-			// Initialize with 0 if not found in storage
-			_, err := s.cacheClient.Set(ctx, &cache_service.SetRequest{
-				Key:        "count:" + shortCode,
-				Value:      "0",
-				TtlSeconds: 60,
+			entries = append(entries, &cache_service.SetRequest{
+				Namespace:  countNamespace,
+				Key:        shortCode,
+				Value:      fmt.Sprintf("%d", statsResp.ClickCount),
+				TtlSeconds: s.live().cacheTTLSeconds,
 			})
-			if err != nil {
-				log.Printf("Warning: failed to initialize count cache: %v", err)
-			}
 		}
+		// Leave the count uncached if storage can't supply it; a cached zero
+		// would hide the real count until it expired
+	}
+
+	if _, err := s.cacheClient.MSet(ctx, &cache_service.MSetRequest{Entries: entries}); err != nil {
+		logf(ctx, "Warning: failed to warm cache: %v", err)
 	}
 }
 
-func generateShortCode() string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, 6)
-	for i := range b {
-		b[i] = charset[time.Now().UnixNano()%int64(len(charset))]
+// generateUniqueShortCode generates random short codes until one is found
+// that is neither in memory nor in storage, and returns its key in ctx's
+// tenant. Sequence and pooled codes are unique by construction and skip
+// the storage lookup; an empty key pool falls back to random codes.
+func (s *urlServer) generateUniqueShortCode(ctx context.Context, strategy string) (string, error) {
+	if strategy == codeStrategySequence {
+		s.metrics.shortCodes.WithLabelValues(codeStrategySequence).Inc()
+		return s.nextSequenceCode(ctx, nil)
+	}
+	if strategy == codeStrategyPool {
+		if shortCode := s.popPooledKey(ctx); shortCode != "" {
+			s.metrics.shortCodes.WithLabelValues(codeStrategyPool).Inc()
+			return shortCode, nil
+		}
+	}
+	s.metrics.shortCodes.WithLabelValues(codeStrategyRandom).Inc()
+	for attempt := 1; attempt <= maxShortCodeAttempts; attempt++ {
+		shortCode, err := generateShortCode(s.codeAlphabet, s.codeLength)
+		if err != nil {
+			return "", status.Errorf(codes.Internal, "failed to generate short code: %v", err)
+		}
+		if err := s.aliases.Validate(shortCode); err != nil {
+			logf(ctx, "Generated short code %s rejected: %v (attempt %d/%d)", shortCode, err, attempt, maxShortCodeAttempts)
+			continue
+		}
+
+		key := tenantKey(tenantID(ctx), shortCode)
+		exists, err := s.shortCodeExists(ctx, key)
+		if err != nil {
+			// A code storage can't vouch for may already be someone's link.
+			logf(ctx, "Failed to check short code %s in storage: %v", shortCode, err)
+			return "", status.Error(codes.Unavailable, "unable to verify short code availability, please retry")
+		}
+		if !exists {
+			return key, nil
+		}
+		logf(ctx, "Short code collision for %s (attempt %d/%d)", shortCode, attempt, maxShortCodeAttempts)
 	}
-	return string(b)
+
+	return "", status.Errorf(codes.ResourceExhausted, "failed to generate a unique short code after %d attempts", maxShortCodeAttempts)
+}
+
+// checkAliasAvailable rejects custom aliases that are already taken in
+// memory or in storage. It fails closed: if storage can't be reached the
+// alias is refused rather than risking overwriting an existing link.
+func (s *urlServer) checkAliasAvailable(ctx context.Context, alias string) error {
+	exists, err := s.shortCodeExists(ctx, alias)
+	if err != nil {
+		logf(ctx, "Failed to verify custom alias %s: %v", alias, err)
+		return status.Error(codes.Unavailable, "unable to verify custom alias availability, please retry")
+	}
+	if exists {
+		return status.Error(codes.AlreadyExists, "Custom alias already exists")
+	}
+	return nil
+}
+
+// shortCodeExists reports whether a short code is taken, checking the
+// in-memory map first and then storage's primary, which a replica may
+// lag behind. An expired link still holds its row, so it counts as taken.
+func (s *urlServer) shortCodeExists(ctx context.Context, shortCode string) (bool, error) {
+	if s.urls.Contains(shortCode) {
+		return true, nil
+	}
+
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	storageResp, err := s.storageClient.GetURL(storageCtx, &storage_service.GetURLRequest{ShortCode: shortCode, IncludeExpired: true, ForcePrimary: true})
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if storageResp.Error != "" {
+		return false, fmt.Errorf("storage error: %s", storageResp.Error)
+	}
+	return storageResp.Found, nil
+}
+
+// generateShortCode returns a random code of length characters drawn
+// uniformly from alphabet using crypto/rand.
+func generateShortCode(alphabet string, length int) (string, error) {
+	// Reject bytes above the largest multiple of the alphabet size to avoid
+	// modulo bias
+	maxByte := 256 - (256 % len(alphabet))
+
+	b := make([]byte, length)
+	buf := make([]byte, length*2)
+	for i := 0; i < len(b); {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, v := range buf {
+			if int(v) >= maxByte {
+				continue
+			}
+			b[i] = alphabet[int(v)%len(alphabet)]
+			i++
+			if i == len(b) {
+				break
+			}
+		}
+	}
+	return string(b), nil
 }
 
 func (s *urlServer) HealthCheck(c *gin.Context) {
@@ -386,36 +1487,220 @@ func (s *urlServer) HealthCheck(c *gin.Context) {
 	})
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// cacheTTL returns the TTL for a cached URL, capped so the entry never
+// outlives the link's expiry. It returns 0 when the link is about to
+// expire and shouldn't be cached at all.
+func (s *urlServer) cacheTTL(expiresAt time.Time) int32 {
+	if expiresAt.IsZero() {
+		return s.live().cacheTTLSeconds
 	}
-	return defaultValue
+	remaining := int32(time.Until(expiresAt) / time.Second)
+	if remaining <= 0 {
+		return 0
+	}
+	return min(remaining, s.live().cacheTTLSeconds)
+}
+
+func isExpired(expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && !time.Now().Before(expiresAt)
+}
+
+// parseOptionalTime parses an RFC3339 timestamp, returning the zero time
+// for empty or malformed values.
+func parseOptionalTime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Printf("Warning: invalid timestamp %q: %v", value, err)
+		return time.Time{}
+	}
+	return t
+}
+
+// formatOptionalTime formats a timestamp as RFC3339, or "" for the zero time.
+func formatOptionalTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// breakers returns the circuit breakers of every downstream, one per cache
+// node.
+func (s *urlServer) breakers() []*circuitBreaker {
+	return append(s.cacheRing.Breakers(), s.storageBreaker)
+}
+
+// Close closes the downstream client connections and the GeoIP database.
+func (s *urlServer) Close() error {
+	firstErr := s.cacheRing.Close()
+	if err := s.storageConn.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if s.geoIP != nil {
+		if err := s.geoIP.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
 
 func main() {
-	urlServer, err := NewURLServer()
+	load := func() (Config, error) {
+		return loadConfig(os.Args[1:], os.Getenv)
+	}
+	cfg, err := load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	setLogLevel(cfg.LogLevel)
+	// The resolver would otherwise hold lookups back to its own interval
+	if cfg.StorageResolveInterval > 0 {
+		dns.SetMinResolutionInterval(min(cfg.StorageResolveInterval, dnsMinResolutionInterval))
+	}
+
+	shutdownTracing, err := setupTracing(context.Background(), "url-service")
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+
+	urlServer, err := NewURLServer(cfg)
 	if err != nil {
 		log.Fatalf("Failed to create URL server: %v", err)
 	}
 
-	lis, err := net.Listen("tcp", ":50051")
+	apiKeys, err := loadAPIKeys(cfg.APIKeys, cfg.APIKeysFile, cfg.RevokedAPIKeys)
+	if err != nil {
+		log.Fatalf("Failed to load API keys: %v", err)
+	}
+	for _, key := range apiKeys.keys {
+		if !urlServer.tenants.Known(key.tenant) {
+			log.Fatalf("API key %s is bound to unknown tenant %q", key.id, key.tenant)
+		}
+	}
+	if !apiKeys.Enabled() {
+		log.Printf("Warning: no API keys configured, mutating RPCs are unauthenticated")
+	} else if cfg.InsecureDevMode {
+		log.Printf("Warning: INSECURE_DEV_MODE is set, calls without an API key are unauthenticated")
+	}
+
+	lis, err := net.Listen("tcp", ":"+cfg.GRPCPort)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	server := grpc.NewServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go urlServer.clicks.Run(ctx)
+	go urlServer.persister.Run(ctx)
+	if urlServer.events != nil {
+		go urlServer.events.Run(ctx)
+	}
+	go urlServer.metrics.logHitRatio(ctx, time.Minute)
+	go urlServer.runDomainRules(ctx)
+	go urlServer.runReputationRescan(ctx, cfg.ReputationRescan)
+	go urlServer.metadata.Run(ctx)
+	go urlServer.cacheRing.Run(ctx)
+	go watchConnState(ctx, "storage-service", urlServer.storageConn)
+
+	server := grpc.NewServer(
+		grpc.StatsHandler(serverTracing()),
+		grpc.ChainUnaryInterceptor(
+			requestIDInterceptor(),
+			urlServer.metrics.UnaryServerInterceptor(),
+			recoveryInterceptor(),
+			authInterceptor(apiKeys, cfg.InsecureDevMode),
+			tenantInterceptor(urlServer.tenants),
+			admissionInterceptor(urlServer.admission),
+			rateLimitInterceptor(urlServer.rateLimits.byMethod(), cfg.TrustForwardedFor),
+			deadlineInterceptor(cfg.RequestTimeout),
+		),
+		// StreamClicks runs until the client goes away, so it gets no deadline
+		grpc.ChainStreamInterceptor(
+			authStreamInterceptor(apiKeys, cfg.InsecureDevMode),
+			tenantStreamInterceptor(urlServer.tenants),
+		),
+	)
 	url_service.RegisterURLServiceServer(server, urlServer)
+	if cfg.EnableReflection {
+		reflection.Register(server)
+	}
 
 	healthServer := health.NewServer()
 	grpc_health_v1.RegisterHealthServer(server, healthServer)
+	go watchReadiness(ctx, healthServer, []string{"", url_service.URLService_ServiceDesc.ServiceName},
+		urlServer.checkDependencies, cfg.ReadinessInterval, cfg.UnhealthyThreshold)
+
+	// Without its rules an allowlist turns everything down until the next refresh
+	if err := urlServer.refreshDomainRules(ctx); err != nil {
+		log.Printf("Warning: failed to load blocked domains: %v", err)
+	}
 
-	log.Printf("URL Service starting on :50051")
+	// Bounded by WARMUP_TIMEOUT so a slow storage can't hold up startup
+	urlServer.warmUp(ctx, cfg.WarmupURLs, cfg.WarmupOrder, cfg.WarmupTimeout)
+
+	log.Printf("URL Service starting on :%s (gRPC reflection enabled: %t)", cfg.GRPCPort, cfg.EnableReflection)
 	log.Printf("Connected to:")
-	log.Printf("  - Cache Service: :50052")
-	log.Printf("  - Storage Service: :50053")
+	log.Printf("  - Cache Service: %s", cfg.CacheServiceAddr)
+	log.Printf("  - Storage Service: %s", cfg.StorageServiceAddr)
+
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			log.Fatalf("failed to serve: %v", err)
+		}
+	}()
+
+	httpServer := newHealthHTTPServer(cfg.HTTPPort, urlServer, cfg.DebugEndpoints && cfg.DebugAddr == "")
+	go func() {
+		log.Printf("Health endpoints listening on %s", httpServer.Addr)
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("failed to serve health endpoints: %v", err)
+		}
+	}()
+
+	var debugServer *http.Server
+	if cfg.DebugEndpoints {
+		log.Printf("Warning: DEBUG_ENDPOINTS is set, profiles are served without authentication")
+	}
+	if cfg.DebugEndpoints && cfg.DebugAddr != "" {
+		debugServer = newDebugHTTPServer(cfg.DebugAddr, urlServer)
+		go func() {
+			log.Printf("Debug endpoints listening on %s", debugServer.Addr)
+			if err := debugServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("failed to serve debug endpoints: %v", err)
+			}
+		}()
+	}
+
+	var webServer *http.Server
+	if cfg.GRPCWebPort != "" {
+		webServer = newGRPCWebServer(cfg.GRPCWebPort, server, cfg.GRPCWebOrigins)
+		go func() {
+			log.Printf("gRPC-Web listening on %s", webServer.Addr)
+			if err := webServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("failed to serve gRPC-Web: %v", err)
+			}
+		}()
+	}
+
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+	go urlServer.runConfigReloads(ctx, cfg, reloads, load)
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-sigCtx.Done()
+
+	shutdown(server, webServer, httpServer, healthServer, urlServer, cancel, cfg.ShutdownTimeout)
+	if debugServer != nil {
+		debugServer.Close()
+	}
 
-	if err := server.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	tracingCtx, tracingCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer tracingCancel()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		log.Printf("Warning: failed to flush traces: %v", err)
 	}
 }
@@ -0,0 +1,1229 @@
+package main
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cache_service "github.com/syedalijabir/protos/cache-service"
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeStorage is a storage-service keeping URLs in memory. Methods tests
+// don't need are left unimplemented.
+type fakeStorage struct {
+	storage_service.UnimplementedStorageServiceServer
+
+	mu   sync.Mutex
+	urls map[string]*storage_service.SaveURLRequest
+
+	// afterGet, when set, is called by GetURL once it has looked a code up
+	afterGet func(shortCode string)
+	// afterStats, when set, is called by GetStats before it looks a code up
+	afterStats func(shortCode string)
+	// getErr, when set, fails every GetURL. Set it under mu once lookups
+	// may be running in the background.
+	getErr error
+
+	// metadata holds what UpdateMetadata stored for each code
+	metadata map[string]*storage_service.UpdateMetadataRequest
+	// clickCounts holds the click count GetStats returns for each code,
+	// which BatchIncrementClicks adds to
+	clickCounts map[string]int64
+	// incrementErrs fails as many BatchIncrementClicks calls as it holds
+	incrementErrs []error
+	// failCodes are reported failed by BatchIncrementClicks while set
+	failCodes map[string]bool
+	// lastAccessed holds the latest access BatchIncrementClicks got for
+	// each code, and incrementCalls how many calls it took
+	lastAccessed   map[string]time.Time
+	incrementCalls int
+	// saveErrs fails as many SaveURL calls as it holds
+	saveErrs []error
+	// saveMD holds the incoming metadata of the last save of each code
+	saveMD map[string]metadata.MD
+	// getURLsCalls holds the codes of each GetURLs call
+	getURLsCalls [][]string
+	// topURLs is what GetTopURLs returns, after waiting topDelay or until
+	// the call is cancelled, and topReqs the requests it got
+	topURLs  []*storage_service.URLSummary
+	topDelay time.Duration
+	topReqs  []*storage_service.GetTopURLsRequest
+	// nextID starts the next block AllocateIDRange hands out, after
+	// firstSequenceID
+	nextID int64
+	// disabled holds the codes SetURLStatus turned off
+	disabled map[string]bool
+	// deleted holds what DeleteURL removed, which GetURL returns when asked
+	// to include deleted URLs
+	deleted map[string]*storage_service.SaveURLRequest
+	// blockedDomains is what ListBlockedDomains returns
+	blockedDomains []*storage_service.BlockedDomain
+	// reports holds the reports ReportURL stored, duplicates left out
+	reports []*storage_service.ReportURLRequest
+
+	// health is the service's gRPC health, serving until set otherwise
+	health *health.Server
+}
+
+// fakeCreatedAt is the creation time fakeStorage reports for every URL.
+const fakeCreatedAt = "2024-01-02T03:04:05Z"
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{
+		urls:         make(map[string]*storage_service.SaveURLRequest),
+		metadata:     make(map[string]*storage_service.UpdateMetadataRequest),
+		clickCounts:  make(map[string]int64),
+		lastAccessed: make(map[string]time.Time),
+		saveMD:       make(map[string]metadata.MD),
+		disabled:     make(map[string]bool),
+		deleted:      make(map[string]*storage_service.SaveURLRequest),
+		health:       health.NewServer(),
+	}
+}
+
+// put stores a URL as if another instance had saved it.
+func (f *fakeStorage) put(req *storage_service.SaveURLRequest) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.urls[req.ShortCode] = req
+}
+
+// savedWith returns the incoming metadata of the last save of shortCode.
+func (f *fakeStorage) savedWith(shortCode string) metadata.MD {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.saveMD[shortCode]
+}
+
+func (f *fakeStorage) url(shortCode string) (*storage_service.SaveURLRequest, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	req, ok := f.urls[shortCode]
+	return req, ok
+}
+
+func (f *fakeStorage) SaveURL(ctx context.Context, req *storage_service.SaveURLRequest) (*storage_service.SaveURLResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.saveErrs) > 0 {
+		err := f.saveErrs[0]
+		f.saveErrs = f.saveErrs[1:]
+		return nil, err
+	}
+	f.saveMD[req.ShortCode], _ = metadata.FromIncomingContext(ctx)
+	if current, ok := f.urls[req.ShortCode]; ok {
+		if req.CreateOnly {
+			return nil, status.Errorf(codes.AlreadyExists, "URL %s already exists", req.ShortCode)
+		}
+		if req.ExpectedOriginalUrl != "" && current.OriginalUrl != req.ExpectedOriginalUrl {
+			return nil, status.Errorf(codes.FailedPrecondition, "URL %s no longer points at the expected destination", req.ShortCode)
+		}
+		updated := proto.Clone(current).(*storage_service.SaveURLRequest)
+		updated.OriginalUrl = req.OriginalUrl
+		if req.SetTags {
+			updated.Tags = req.Tags
+		}
+		f.urls[req.ShortCode] = updated
+		return &storage_service.SaveURLResponse{Success: true}, nil
+	}
+	f.urls[req.ShortCode] = req
+	return &storage_service.SaveURLResponse{Success: true}, nil
+}
+
+func (f *fakeStorage) SaveURLs(ctx context.Context, req *storage_service.SaveURLsRequest) (*storage_service.SaveURLsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := &storage_service.SaveURLsResponse{}
+	for _, u := range req.Urls {
+		if _, ok := f.urls[u.ShortCode]; ok {
+			continue
+		}
+		f.urls[u.ShortCode] = u
+		resp.InsertedShortCodes = append(resp.InsertedShortCodes, u.ShortCode)
+	}
+	return resp, nil
+}
+
+func (f *fakeStorage) GetURL(ctx context.Context, req *storage_service.GetURLRequest) (*storage_service.GetURLResponse, error) {
+	f.mu.Lock()
+	if err := f.getErr; err != nil {
+		f.mu.Unlock()
+		return nil, err
+	}
+	u, ok := f.urls[req.ShortCode]
+	deleted := false
+	if !ok && req.IncludeDeleted {
+		u, ok = f.deleted[req.ShortCode]
+		deleted = ok
+	}
+	clickCount := f.clickCounts[req.ShortCode]
+	disabled := f.disabled[req.ShortCode]
+	f.mu.Unlock()
+	if f.afterGet != nil {
+		f.afterGet(req.ShortCode)
+	}
+	if !ok || !req.IncludeExpired && isExpired(parseOptionalTime(u.ExpiresAt)) {
+		return nil, status.Errorf(codes.NotFound, "URL not found: %s", req.ShortCode)
+	}
+	return &storage_service.GetURLResponse{
+		OriginalUrl:    u.OriginalUrl,
+		Found:          true,
+		UserId:         u.UserId,
+		MaxClicks:      u.MaxClicks,
+		ExpiresAt:      u.ExpiresAt,
+		ClickCount:     clickCount,
+		CreatedAt:      fakeCreatedAt,
+		NotBefore:      u.NotBefore,
+		ComingSoonUrl:  u.ComingSoonUrl,
+		FallbackUrl:    u.FallbackUrl,
+		Disabled:       disabled,
+		Deleted:        deleted,
+		Variants:       u.Variants,
+		StickyVariants: u.StickyVariants && len(u.Variants) > 0,
+		Rules:          u.Rules,
+		QueryTemplate:  u.QueryTemplate,
+	}, nil
+}
+
+func (f *fakeStorage) GetURLs(ctx context.Context, req *storage_service.GetURLsRequest) (*storage_service.GetURLsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.getURLsCalls = append(f.getURLsCalls, req.ShortCodes)
+	resp := &storage_service.GetURLsResponse{Urls: make(map[string]*storage_service.GetURLResponse)}
+	for _, shortCode := range req.ShortCodes {
+		if u, ok := f.urls[shortCode]; ok {
+			resp.Urls[shortCode] = &storage_service.GetURLResponse{OriginalUrl: u.OriginalUrl, Found: true, ExpiresAt: u.ExpiresAt, CreatedAt: fakeCreatedAt}
+		}
+	}
+	return resp, nil
+}
+
+func (f *fakeStorage) GetStats(ctx context.Context, req *storage_service.GetStatsRequest) (*storage_service.GetStatsResponse, error) {
+	if f.afterStats != nil {
+		f.afterStats(req.ShortCode)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.urls[req.ShortCode]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "URL not found: %s", req.ShortCode)
+	}
+	return &storage_service.GetStatsResponse{
+		ShortCode:      req.ShortCode,
+		ClickCount:     f.clickCounts[req.ShortCode],
+		CreatedAt:      fakeCreatedAt,
+		ExpiresAt:      u.ExpiresAt,
+		LastAccessedAt: formatOptionalTime(f.lastAccessed[req.ShortCode]),
+	}, nil
+}
+
+func (f *fakeStorage) UpdateMetadata(ctx context.Context, req *storage_service.UpdateMetadataRequest) (*storage_service.UpdateMetadataResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.urls[req.ShortCode]; !ok {
+		return nil, status.Errorf(codes.NotFound, "URL not found: %s", req.ShortCode)
+	}
+	f.metadata[req.ShortCode] = req
+	return &storage_service.UpdateMetadataResponse{}, nil
+}
+
+func (f *fakeStorage) DeleteURL(ctx context.Context, req *storage_service.DeleteURLRequest) (*storage_service.DeleteURLResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.urls[req.ShortCode]; !ok {
+		return nil, status.Errorf(codes.NotFound, "URL not found: %s", req.ShortCode)
+	}
+	f.deleted[req.ShortCode] = f.urls[req.ShortCode]
+	delete(f.urls, req.ShortCode)
+	return &storage_service.DeleteURLResponse{Success: true}, nil
+}
+
+func (f *fakeStorage) CountURLs(ctx context.Context, req *storage_service.CountURLsRequest) (*storage_service.CountURLsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := &storage_service.CountURLsResponse{}
+	for key, u := range f.urls {
+		if tenant, _ := splitTenantKey(key); tenant == req.TenantId && u.UserId == req.UserId {
+			resp.Active++
+		}
+	}
+	return resp, nil
+}
+
+// FindByOriginalURL matches whole URLs only, in code order.
+func (f *fakeStorage) FindByOriginalURL(ctx context.Context, req *storage_service.FindByOriginalURLRequest) (*storage_service.FindByOriginalURLResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var found []string
+	for key, u := range f.urls {
+		if tenant, _ := splitTenantKey(key); tenant != req.TenantId || u.OriginalUrl != req.OriginalUrl || req.UnlimitedOnly && u.MaxClicks > 0 {
+			continue
+		}
+		if req.PlainOnly && (u.QueryTemplate != "" || u.FallbackUrl != "" || len(u.Variants) > 0 || len(u.Rules) > 0) {
+			continue
+		}
+		found = append(found, key)
+	}
+	slices.Sort(found)
+	if req.Limit > 0 && len(found) > int(req.Limit) {
+		found = found[:req.Limit]
+	}
+	return &storage_service.FindByOriginalURLResponse{ShortCodes: found}, nil
+}
+
+func (f *fakeStorage) BatchIncrementClicks(ctx context.Context, req *storage_service.BatchIncrementClicksRequest) (*storage_service.BatchIncrementClicksResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.incrementErrs) > 0 {
+		err := f.incrementErrs[0]
+		f.incrementErrs = f.incrementErrs[1:]
+		return nil, err
+	}
+	f.incrementCalls++
+	resp := &storage_service.BatchIncrementClicksResponse{}
+	for _, d := range req.Deltas {
+		if f.failCodes[d.ShortCode] {
+			resp.FailedShortCodes = append(resp.FailedShortCodes, d.ShortCode)
+			continue
+		}
+		f.clickCounts[d.ShortCode] += d.Delta
+		if at := parseOptionalTime(d.LastAccessedAt); at.After(f.lastAccessed[d.ShortCode]) {
+			f.lastAccessed[d.ShortCode] = at
+		}
+		resp.Updated++
+	}
+	return resp, nil
+}
+
+func (f *fakeStorage) clicks(shortCode string) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.clickCounts[shortCode]
+}
+
+func (f *fakeStorage) storedMetadata(shortCode string) *storage_service.UpdateMetadataRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.metadata[shortCode]
+}
+
+// fakeCache is a cache-service keeping entries in memory, without TTLs.
+type fakeCache struct {
+	cache_service.UnimplementedCacheServiceServer
+
+	mu      sync.Mutex
+	entries map[string]string
+	ttls    map[string]int32 // The TTL each entry was last set with
+
+	// deleteErr, when set, fails every Delete
+	deleteErr error
+	// getDelay holds up every Get, or until the caller gives up
+	getDelay time.Duration
+
+	// health is the service's gRPC health, serving until set otherwise
+	health *health.Server
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{entries: make(map[string]string), ttls: make(map[string]int32), health: health.NewServer()}
+}
+
+func (f *fakeCache) Get(ctx context.Context, req *cache_service.GetRequest) (*cache_service.GetResponse, error) {
+	f.mu.Lock()
+	delay := f.getDelay
+	f.mu.Unlock()
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	value, ok := f.entries[req.Namespace+":"+req.Key]
+	return &cache_service.GetResponse{Value: value, Found: ok}, nil
+}
+
+func (f *fakeCache) Set(ctx context.Context, req *cache_service.SetRequest) (*cache_service.SetResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries[req.Namespace+":"+req.Key] = req.Value
+	f.ttls[req.Namespace+":"+req.Key] = req.TtlSeconds
+	return &cache_service.SetResponse{Success: true}, nil
+}
+
+func (f *fakeCache) SetIfAbsent(ctx context.Context, req *cache_service.SetRequest) (*cache_service.SetIfAbsentResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := req.Namespace + ":" + req.Key
+	if _, ok := f.entries[key]; ok {
+		return &cache_service.SetIfAbsentResponse{}, nil
+	}
+	f.entries[key] = req.Value
+	f.ttls[key] = req.TtlSeconds
+	return &cache_service.SetIfAbsentResponse{Stored: true}, nil
+}
+
+func (f *fakeCache) Delete(ctx context.Context, req *cache_service.DeleteRequest) (*cache_service.DeleteResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.deleteErr != nil {
+		return nil, f.deleteErr
+	}
+	delete(f.entries, req.Namespace+":"+req.Key)
+	return &cache_service.DeleteResponse{Success: true}, nil
+}
+
+func (f *fakeCache) MGet(ctx context.Context, req *cache_service.MGetRequest) (*cache_service.MGetResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := &cache_service.MGetResponse{}
+	for _, key := range req.Keys {
+		value, ok := f.entries[req.Namespace+":"+key]
+		resp.Values = append(resp.Values, &cache_service.GetResponse{Value: value, Found: ok})
+	}
+	return resp, nil
+}
+
+func (f *fakeCache) MSet(ctx context.Context, req *cache_service.MSetRequest) (*cache_service.MSetResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, e := range req.Entries {
+		f.entries[e.Namespace+":"+e.Key] = e.Value
+		f.ttls[e.Namespace+":"+e.Key] = e.TtlSeconds
+	}
+	return &cache_service.MSetResponse{Success: true}, nil
+}
+
+func (f *fakeCache) Exists(ctx context.Context, req *cache_service.ExistsRequest) (*cache_service.ExistsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.entries[req.Namespace+":"+req.Key]
+	return &cache_service.ExistsResponse{Exists: ok}, nil
+}
+
+// entry returns the value the cache holds under key.
+func (f *fakeCache) entry(key string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	value, ok := f.entries[key]
+	return value, ok
+}
+
+func (f *fakeCache) ttl(key string) int32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ttls[key]
+}
+
+// expire drops an entry as if its TTL had run out.
+func (f *fakeCache) expire(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.entries, key)
+}
+
+func (f *fakeCache) set(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries[key] = value
+}
+
+// serveGRPC serves the services register adds on a local port until the
+// test ends, and returns its address.
+func serveGRPC(t *testing.T, register func(*grpc.Server)) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+// dialBufconn serves the services register adds over an in-memory
+// listener until the test ends, and returns a connection to them.
+func dialBufconn(t *testing.T, register func(*grpc.Server), opts ...grpc.ServerOption) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(opts...)
+	register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial bufconn: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// testEnv returns a getenv for loadConfig reading env.
+func testEnv(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+// newTestServer returns a url-service on fake storage and cache services,
+// configured with env.
+func newTestServer(t *testing.T, env map[string]string) (*urlServer, *fakeStorage, *fakeCache) {
+	t.Helper()
+	storage, cache := newFakeStorage(), newFakeCache()
+	all := map[string]string{
+		"STORAGE_SERVICE_ADDR": serveGRPC(t, func(srv *grpc.Server) {
+			storage_service.RegisterStorageServiceServer(srv, storage)
+			grpc_health_v1.RegisterHealthServer(srv, storage.health)
+		}),
+		"CACHE_SERVICE_ADDR": serveGRPC(t, func(srv *grpc.Server) {
+			cache_service.RegisterCacheServiceServer(srv, cache)
+			grpc_health_v1.RegisterHealthServer(srv, cache.health)
+		}),
+	}
+	for k, v := range env {
+		all[k] = v
+	}
+	cfg, err := loadConfig(nil, testEnv(all))
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	s, err := NewURLServer(cfg)
+	if err != nil {
+		t.Fatalf("NewURLServer: %v", err)
+	}
+	t.Cleanup(func() {
+		s.tasks.Close(context.Background())
+		s.Close()
+	})
+	return s, storage, cache
+}
+
+// withKey returns ctx authenticated by an API key of user.
+func withKey(ctx context.Context, id, user string) context.Context {
+	return context.WithValue(ctx, apiKeyCtxKey{}, apiKey{id: id, user: user})
+}
+
+func TestShortenURLAliasTakenAfterCheck(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+
+	// Another instance saves the alias right after it was found free
+	storage.afterGet = func(shortCode string) {
+		storage.put(&storage_service.SaveURLRequest{ShortCode: shortCode, OriginalUrl: "https://alice.example", UserId: "alice"})
+	}
+	ctx := withKey(context.Background(), "mallory-key", "mallory")
+	_, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://mallory.example", CustomAlias: "alias1"})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("ShortenURL: got %v, want AlreadyExists", err)
+	}
+
+	if u, _ := storage.url("alias1"); u.OriginalUrl != "https://alice.example" || u.UserId != "alice" {
+		t.Errorf("storage holds %s for %s, want https://alice.example for alice", u.OriginalUrl, u.UserId)
+	}
+	if entry, ok := s.urls.Get("alias1"); ok {
+		t.Errorf("memory still holds %s for alias1", entry.originalURL)
+	}
+}
+
+func TestShortenURLExpiredAliasTaken(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+
+	// An expired link keeps its row, which an insert-only save can't take
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "alias1", OriginalUrl: "https://alice.example", UserId: "alice", ExpiresAt: past})
+	ctx := withKey(context.Background(), "mallory-key", "mallory")
+	_, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://mallory.example", CustomAlias: "alias1"})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("ShortenURL: got %v, want AlreadyExists", err)
+	}
+	if entry, ok := s.urls.Get("alias1"); ok {
+		t.Errorf("memory holds %s for alias1", entry.originalURL)
+	}
+
+	exists, err := s.shortCodeExists(context.Background(), "alias1")
+	if err != nil || !exists {
+		t.Errorf("shortCodeExists(alias1) = %v, %v, want true", exists, err)
+	}
+}
+
+func TestSavedAlready(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+
+	// A retried save finds the row its first attempt wrote
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "mine", OriginalUrl: "https://bob.example", UserId: "bob"})
+	req := &storage_service.SaveURLRequest{ShortCode: "mine", OriginalUrl: "https://bob.example", UserId: "bob", CreateOnly: true}
+	if !savedAlready(context.Background(), s.storageClient, req) {
+		t.Error("savedAlready = false for the same URL and owner")
+	}
+	req.UserId = "mallory"
+	if savedAlready(context.Background(), s.storageClient, req) {
+		t.Error("savedAlready = true for another owner")
+	}
+}
+
+func TestPersisterDropsLostCode(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+
+	storage.afterGet = func(shortCode string) {
+		storage.put(&storage_service.SaveURLRequest{ShortCode: shortCode, OriginalUrl: "https://alice.example", UserId: "alice"})
+	}
+	ctx := withKey(context.Background(), "mallory-key", "mallory")
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://mallory.example", CustomAlias: "alias2"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+
+	// The async save finds the code taken and drops the URL from memory
+	deadline := time.Now().Add(5 * time.Second)
+	for s.urls.Contains("alias2") {
+		if time.Now().After(deadline) {
+			t.Fatal("memory still holds alias2")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if u, _ := storage.url("alias2"); u.OriginalUrl != "https://alice.example" {
+		t.Errorf("storage holds %s, want https://alice.example", u.OriginalUrl)
+	}
+	if n := s.persister.Pending(); n != 0 {
+		t.Errorf("%d saves pending, want 0", n)
+	}
+}
+
+func TestGenerateShortCode(t *testing.T) {
+	for name, alphabet := range alphabetPresets {
+		for length := minShortCodeLength; length <= maxShortCodeLength; length++ {
+			for i := 0; i < 1000; i++ {
+				code, err := generateShortCode(alphabet, length)
+				if err != nil {
+					t.Fatalf("generateShortCode: %v", err)
+				}
+				if len(code) != length {
+					t.Fatalf("%s: got %q, want %d characters", name, code, length)
+				}
+				if i := strings.IndexFunc(code, func(r rune) bool { return !strings.ContainsRune(alphabet, r) }); i >= 0 {
+					t.Fatalf("%s: %q has %q, which isn't in the alphabet", name, code, code[i])
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateShortCodeUnique(t *testing.T) {
+	const n = 300000
+	seen := make(map[string]bool, n)
+	used := make(map[rune]bool)
+	for i := 0; i < n; i++ {
+		code, err := generateShortCode(shortCodeCharset, maxShortCodeLength)
+		if err != nil {
+			t.Fatalf("generateShortCode: %v", err)
+		}
+		if seen[code] {
+			t.Fatalf("%q generated twice in %d codes", code, i+1)
+		}
+		seen[code] = true
+		for _, r := range code {
+			used[r] = true
+		}
+	}
+	if len(used) != len(shortCodeCharset) {
+		t.Errorf("codes use %d of the %d characters", len(used), len(shortCodeCharset))
+	}
+}
+
+func TestGenerateUniqueShortCode(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	code, err := s.generateUniqueShortCode(context.Background(), codeStrategyRandom)
+	if err != nil || len(code) != defaultShortCodeLength {
+		t.Fatalf("generateUniqueShortCode = %q, %v", code, err)
+	}
+
+	storage.mu.Lock()
+	storage.getErr = status.Error(codes.Internal, "database is down")
+	storage.mu.Unlock()
+	if _, err := s.generateUniqueShortCode(context.Background(), codeStrategyRandom); status.Code(err) != codes.Unavailable {
+		t.Fatalf("generateUniqueShortCode with storage down: got %v, want Unavailable", err)
+	}
+}
+
+func TestShortenURLCustomAlias(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	ctx := context.Background()
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "stored", OriginalUrl: "https://example.com/stored"})
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com/held", CustomAlias: "held"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+
+	tests := []struct {
+		alias string
+		want  codes.Code
+	}{
+		{"free", codes.OK},
+		// Taken by this instance, or only known to storage
+		{"held", codes.AlreadyExists},
+		{"stored", codes.AlreadyExists},
+	}
+	for _, tt := range tests {
+		resp, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com/new", CustomAlias: tt.alias})
+		if status.Code(err) != tt.want {
+			t.Errorf("ShortenURL with alias %s: got %v, want %v", tt.alias, err, tt.want)
+			continue
+		}
+		if err == nil && resp.ShortCode != tt.alias {
+			t.Errorf("ShortenURL with alias %s got code %s", tt.alias, resp.ShortCode)
+		}
+	}
+
+	// An alias storage can't vouch for is refused
+	storage.mu.Lock()
+	storage.getErr = status.Error(codes.Internal, "database is down")
+	storage.mu.Unlock()
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com/new", CustomAlias: "unchecked"}); status.Code(err) != codes.Unavailable {
+		t.Errorf("ShortenURL with storage down: got %v, want Unavailable", err)
+	}
+}
+
+func TestStatusCodes(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "taken", OriginalUrl: "https://alice.example"})
+	conn := dialBufconn(t, func(srv *grpc.Server) { url_service.RegisterURLServiceServer(srv, s) })
+	client := url_service.NewURLServiceClient(conn)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"unknown code", func() error {
+			_, err := client.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "missing"})
+			return err
+		}, codes.NotFound},
+		{"known code", func() error {
+			_, err := client.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "taken"})
+			return err
+		}, codes.OK},
+		{"taken alias", func() error {
+			_, err := client.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://bob.example", CustomAlias: "taken"})
+			return err
+		}, codes.AlreadyExists},
+		{"bad URL", func() error {
+			_, err := client.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "javascript:alert(1)"})
+			return err
+		}, codes.InvalidArgument},
+		{"new link", func() error {
+			_, err := client.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://bob.example"})
+			return err
+		}, codes.OK},
+	}
+	for _, tt := range tests {
+		if err := tt.call(); status.Code(err) != tt.want {
+			t.Errorf("%s: got %v, want %s", tt.name, err, tt.want)
+		}
+	}
+
+	// Storage failing is not the caller's fault
+	storage.mu.Lock()
+	storage.getErr = status.Error(codes.Internal, "disk on fire")
+	storage.mu.Unlock()
+	if _, err := client.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "other"}); status.Code(err) != codes.Unavailable {
+		t.Errorf("GetOriginalURL with storage failing: got %v, want Unavailable", err)
+	}
+}
+
+func TestDeleteURL(t *testing.T) {
+	s, storage, cache := newTestServer(t, nil)
+	ctx := context.Background()
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://bob.example", CustomAlias: "gone"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	waitFor(t, "save of gone", func() bool { _, ok := storage.url("gone"); return ok })
+	waitFor(t, "cache entry for gone", func() bool { _, ok := cache.entry("count:gone"); return ok })
+
+	if _, err := s.DeleteURL(ctx, &url_service.DeleteURLRequest{ShortCode: "gone"}); err != nil {
+		t.Fatalf("DeleteURL: %v", err)
+	}
+	if _, ok := storage.url("gone"); ok {
+		t.Error("storage still holds gone")
+	}
+	if s.urls.Contains("gone") {
+		t.Error("memory still holds gone")
+	}
+	for _, key := range []string{"url:gone", "count:gone"} {
+		if value, ok := cache.entry(key); ok {
+			t.Errorf("cache still holds %s for %s", value, key)
+		}
+	}
+
+	// A cache entry that survived the delete isn't served
+	cache.set("url:gone", "https://bob.example")
+	if _, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "gone"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetOriginalURL after delete: got %v, want NotFound", err)
+	}
+
+	// Deleting again, or something that never existed, is NotFound
+	for _, code := range []string{"gone", "never"} {
+		if _, err := s.DeleteURL(ctx, &url_service.DeleteURLRequest{ShortCode: code}); status.Code(err) != codes.NotFound {
+			t.Errorf("DeleteURL of %s: got %v, want NotFound", code, err)
+		}
+	}
+
+	// A URL storage never got is deleted from memory, even with the
+	// cache down
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://bob.example", CustomAlias: "kept"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	waitFor(t, "save of kept", func() bool { _, ok := storage.url("kept"); return ok })
+	storage.mu.Lock()
+	delete(storage.urls, "kept")
+	storage.mu.Unlock()
+	cache.mu.Lock()
+	cache.deleteErr = status.Error(codes.Unavailable, "cache down")
+	cache.mu.Unlock()
+	if _, err := s.DeleteURL(ctx, &url_service.DeleteURLRequest{ShortCode: "kept"}); err != nil {
+		t.Errorf("DeleteURL with the cache down: %v, want the delete to stand", err)
+	}
+}
+
+func TestInvalidateCacheReportsKeys(t *testing.T) {
+	s, _, cache := newTestServer(t, nil)
+	cache.deleteErr = status.Error(codes.Unavailable, "cache down")
+
+	err := s.invalidateCache(context.Background(), "stuck")
+	if err == nil {
+		t.Fatal("invalidateCache succeeded with the cache down")
+	}
+	for _, namespace := range []string{urlNamespace, countNamespace, talliesNamespace} {
+		if key := namespace + ":stuck"; !strings.Contains(err.Error(), key) {
+			t.Errorf("invalidateCache error %q doesn't name %s", err, key)
+		}
+	}
+}
+
+func TestUpdateURL(t *testing.T) {
+	s, storage, cache := newTestServer(t, nil)
+	ctx := context.Background()
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://a.example", CustomAlias: "moving"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	waitFor(t, "save of moving", func() bool { _, ok := storage.url("moving"); return ok })
+	waitFor(t, "cache entry for moving", func() bool { _, ok := cache.entry("count:moving"); return ok })
+	if _, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "moving"}); err != nil {
+		t.Fatalf("GetOriginalURL: %v", err)
+	}
+
+	resp, err := s.UpdateURL(ctx, &url_service.UpdateURLRequest{ShortCode: "moving", OriginalUrl: "https://b.example", ExpectedOriginalUrl: "https://a.example"})
+	if err != nil || resp.OriginalUrl != "https://b.example" {
+		t.Fatalf("UpdateURL = %v, %v", resp, err)
+	}
+	if u, _ := storage.url("moving"); u.OriginalUrl != "https://b.example" {
+		t.Errorf("storage holds %s, want https://b.example", u.OriginalUrl)
+	}
+	// The cache holds the new destination rather than serving the old one until its TTL
+	if value, _ := cache.entry("url:moving"); value != "https://b.example" {
+		t.Errorf("cache holds %q, want https://b.example", value)
+	}
+	if got, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "moving"}); err != nil || got.OriginalUrl != "https://b.example" {
+		t.Errorf("GetOriginalURL = %v, %v, want https://b.example", got, err)
+	}
+
+	tests := []struct {
+		name string
+		req  *url_service.UpdateURLRequest
+		want codes.Code
+	}{
+		{"unknown code", &url_service.UpdateURLRequest{ShortCode: "never", OriginalUrl: "https://c.example"}, codes.NotFound},
+		{"lost race", &url_service.UpdateURLRequest{ShortCode: "moving", OriginalUrl: "https://c.example", ExpectedOriginalUrl: "https://a.example"}, codes.FailedPrecondition},
+		{"bad URL", &url_service.UpdateURLRequest{ShortCode: "moving", OriginalUrl: "ftp://c.example"}, codes.InvalidArgument},
+		{"no code", &url_service.UpdateURLRequest{OriginalUrl: "https://c.example"}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		if _, err := s.UpdateURL(ctx, tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: got %v, want %s", tt.name, err, tt.want)
+		}
+	}
+	if u, _ := storage.url("moving"); u.OriginalUrl != "https://b.example" {
+		t.Errorf("storage holds %s after rejected updates, want https://b.example", u.OriginalUrl)
+	}
+}
+
+func TestUpdateURLReplacesLongCachedValue(t *testing.T) {
+	s, storage, cache := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true", "CACHE_TTL": "24h"})
+	ctx := withKey(context.Background(), "bob-key", "bob")
+
+	// Another replica cached the old destination for a day
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "moving", OriginalUrl: "https://a.example", UserId: "bob"})
+	cache.set("url:moving", "https://a.example")
+	cache.set("notfound:moving", "1")
+
+	if _, err := s.UpdateURL(ctx, &url_service.UpdateURLRequest{ShortCode: "moving", OriginalUrl: "https://b.example"}); err != nil {
+		t.Fatalf("UpdateURL: %v", err)
+	}
+	if _, ok := cache.entry("notfound:moving"); ok {
+		t.Error("sentinel still cached after the update")
+	}
+	if value, _ := cache.entry("url:moving"); value != "https://b.example" {
+		t.Errorf("cache holds %q after the update, want https://b.example", value)
+	}
+
+	// A replica without it in memory gets the new destination from the cache
+	s.urls.Remove("moving")
+	storage.getErr = status.Error(codes.Internal, "disk failure")
+	got, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "moving"})
+	if err != nil || got.OriginalUrl != "https://b.example" {
+		t.Errorf("GetOriginalURL right after the update = %v, %v, want https://b.example", got, err)
+	}
+}
+
+func TestShortenURLReusesStoredCode(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	ctx := context.Background()
+
+	// Only storage has the URL, as after a restart
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "stored1", OriginalUrl: "https://example.com/page"})
+	resp, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com/page", ReuseExisting: true})
+	if err != nil || resp.ShortCode != "stored1" {
+		t.Errorf("ShortenURL with reuse_existing = %v, %v, want stored1", resp, err)
+	}
+
+	tests := []struct {
+		name string
+		req  *url_service.ShortenRequest
+	}{
+		{"without reuse_existing", &url_service.ShortenRequest{OriginalUrl: "https://example.com/page"}},
+		{"custom alias", &url_service.ShortenRequest{OriginalUrl: "https://example.com/page", ReuseExisting: true, CustomAlias: "mine"}},
+		{"other URL", &url_service.ShortenRequest{OriginalUrl: "https://example.com/other", ReuseExisting: true}},
+	}
+	for _, tt := range tests {
+		resp, err := s.ShortenURL(ctx, tt.req)
+		if err != nil || resp.ShortCode == "stored1" {
+			t.Errorf("%s: ShortenURL = %v, %v, want a new code", tt.name, resp, err)
+		}
+	}
+}
+
+func TestShortenURLDedupEnv(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	s.dedupURLs = true
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "stored1", OriginalUrl: "https://example.com/page"})
+
+	resp, err := s.ShortenURL(context.Background(), &url_service.ShortenRequest{OriginalUrl: "https://example.com/page"})
+	if err != nil || resp.ShortCode != "stored1" {
+		t.Errorf("ShortenURL with DEDUPLICATE_URLS = %v, %v, want stored1", resp, err)
+	}
+}
+
+func TestShortenURLExpires(t *testing.T) {
+	s, storage, cache := newTestServer(t, nil)
+	ctx := context.Background()
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "brief", TtlSeconds: 2}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	waitFor(t, "save of brief", func() bool { _, ok := storage.url("brief"); return ok })
+	if u, _ := storage.url("brief"); u.ExpiresAt == "" {
+		t.Error("storage holds brief without an expiry")
+	}
+	// The cache entry can't outlive the link
+	waitFor(t, "cache entry for brief", func() bool { _, ok := cache.entry("count:brief"); return ok })
+	if ttl := cache.ttl("url:brief"); ttl < 1 || ttl > 2 {
+		t.Errorf("cached for %ds, want at most the 2s the link has left", ttl)
+	}
+	if _, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "brief"}); err != nil {
+		t.Fatalf("GetOriginalURL before the expiry: %v", err)
+	}
+
+	time.Sleep(2100 * time.Millisecond)
+	cache.expire("url:brief")
+	// Expired links resolve to nothing, told apart from unknown ones
+	if got, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "brief"}); err != nil || got.OriginalUrl != "" || !got.Expired {
+		t.Errorf("GetOriginalURL after the expiry = %v, %v, want expired without a URL", got, err)
+	}
+	if s.urls.Contains("brief") {
+		t.Error("memory still holds the expired URL")
+	}
+
+	// Its clicks are still reported, stored and pending
+	storage.mu.Lock()
+	storage.clickCounts["brief"] = 7
+	storage.mu.Unlock()
+	cache.expire("count:brief")
+	stats, err := s.GetURLStats(ctx, &url_service.StatsRequest{ShortCode: "brief"})
+	if err != nil || !stats.Expired || stats.ClickCount != 8 {
+		t.Errorf("GetURLStats = %v, %v, want expired with 8 clicks", stats, err)
+	}
+
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", TtlSeconds: -1}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ShortenURL with a negative TTL: got %v, want InvalidArgument", err)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	s, _, _ := newTestServer(t, map[string]string{"CACHE_TTL": "1h"})
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		want      int32
+	}{
+		{"never expires", time.Time{}, 3600},
+		{"expires later", time.Now().Add(2 * time.Hour), 3600},
+		{"expires sooner", time.Now().Add(90*time.Second + 500*time.Millisecond), 90},
+		{"about to expire", time.Now().Add(500 * time.Millisecond), 0},
+		{"expired", time.Now().Add(-time.Minute), 0},
+	}
+	for _, tt := range tests {
+		if got := s.cacheTTL(tt.expiresAt); got != tt.want {
+			t.Errorf("%s: cacheTTL = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShortenURLWaitForPersistence(t *testing.T) {
+	tests := []struct {
+		name        string
+		syncPersist bool // As URL_SYNC_PERSIST sets it
+		wait        bool
+	}{
+		{"request flag", false, true},
+		{"URL_SYNC_PERSIST", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, storage, _ := newTestServer(t, nil)
+			s.syncPersist = tt.syncPersist
+			ctx := context.Background()
+
+			// A failed write is reported and leaves nothing behind
+			storage.mu.Lock()
+			storage.saveErrs = []error{status.Error(codes.Internal, "disk full")}
+			storage.mu.Unlock()
+			_, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "durable", WaitForPersistence: tt.wait})
+			if status.Code(err) != codes.Unavailable {
+				t.Fatalf("ShortenURL with storage failing: got %v, want Unavailable", err)
+			}
+			if entry, ok := s.urls.Get("durable"); ok {
+				t.Errorf("memory still holds %s for durable", entry.originalURL)
+			}
+			if s.persister.Pending() != 0 {
+				t.Error("durable was queued for a retry")
+			}
+			if _, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "durable"}); status.Code(err) != codes.NotFound {
+				t.Errorf("GetOriginalURL after the failure: got %v, want NotFound", err)
+			}
+
+			// A successful write is in storage by the time the code is returned
+			resp, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "durable", WaitForPersistence: tt.wait})
+			if err != nil {
+				t.Fatalf("ShortenURL: %v", err)
+			}
+			if u, ok := storage.url(resp.ShortCode); !ok || u.OriginalUrl != "https://example.com" {
+				t.Errorf("storage holds %v, want https://example.com", u)
+			}
+		})
+	}
+}
+
+func TestStatsSurviveRestart(t *testing.T) {
+	// A fresh server over storage a previous instance filled, with a count
+	// in the cache that fell behind storage
+	s, storage, cache := newTestServer(t, nil)
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "popular", OriginalUrl: "https://example.com"})
+	storage.mu.Lock()
+	storage.clickCounts["popular"] = 41
+	storage.mu.Unlock()
+	cache.set("count:popular", "0")
+
+	ctx := context.Background()
+	if _, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "popular"}); err != nil {
+		t.Fatalf("GetOriginalURL: %v", err)
+	}
+	entry, ok := s.urls.Get("popular")
+	if !ok || entry.clickCount != 41 || entry.createdAt.Format(time.RFC3339) != fakeCreatedAt {
+		t.Errorf("memory holds %d clicks created %v, want 41 created %s", entry.clickCount, entry.createdAt, fakeCreatedAt)
+	}
+
+	// With the click that lookup made, not yet flushed
+	stats, err := s.GetURLStats(ctx, &url_service.StatsRequest{ShortCode: "popular"})
+	if err != nil {
+		t.Fatalf("GetURLStats: %v", err)
+	}
+	if stats.ClickCount != 42 || stats.CreatedAt != fakeCreatedAt {
+		t.Errorf("GetURLStats = %d clicks created %s, want 42 created %s", stats.ClickCount, stats.CreatedAt, fakeCreatedAt)
+	}
+}
+
+func TestShortenURLShortURL(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+	}{
+		{"", ""},
+		{"https://sho.rt", "https://sho.rt/Ab3dE9"},
+		{"https://sho.rt/", "https://sho.rt/Ab3dE9"},
+		{"https://sho.rt/r", "https://sho.rt/r/Ab3dE9"},
+		{"https://sho.rt/r/", "https://sho.rt/r/Ab3dE9"},
+		{"http://localhost:8080/go/r/", "http://localhost:8080/go/r/Ab3dE9"},
+	}
+	for _, tt := range tests {
+		s, _, _ := newTestServer(t, map[string]string{"BASE_URL": tt.baseURL})
+		before := time.Now().Add(-time.Second)
+		resp, err := s.ShortenURL(withKey(context.Background(), "alice-key", "alice"), &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "Ab3dE9", TtlSeconds: 3600})
+		if err != nil {
+			t.Fatalf("BASE_URL %q: ShortenURL: %v", tt.baseURL, err)
+		}
+		if resp.ShortUrl != tt.want {
+			t.Errorf("BASE_URL %q: short URL %q, want %q", tt.baseURL, resp.ShortUrl, tt.want)
+		}
+
+		created, err := time.Parse(time.RFC3339, resp.CreatedAt)
+		if err != nil || created.Before(before) {
+			t.Errorf("BASE_URL %q: created_at %q, want about now", tt.baseURL, resp.CreatedAt)
+		}
+		expires, err := time.Parse(time.RFC3339, resp.ExpiresAt)
+		if err != nil || expires.Sub(created) < 59*time.Minute || expires.Sub(created) > 61*time.Minute {
+			t.Errorf("BASE_URL %q: expires_at %q, want an hour after created_at %q", tt.baseURL, resp.ExpiresAt, resp.CreatedAt)
+		}
+	}
+
+	for _, baseURL := range []string{"sho.rt", "ftp://sho.rt", "https://sho.rt/?ref=1", "https:///r/"} {
+		if _, err := loadConfig(nil, testEnv(map[string]string{"BASE_URL": baseURL})); err == nil || !strings.Contains(err.Error(), "BASE_URL") {
+			t.Errorf("BASE_URL %q: got %v, want an error", baseURL, err)
+		}
+	}
+}
+
+func TestBatchShortenMixedItems(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "taken", OriginalUrl: "https://other.example", UserId: "bob"})
+	ctx := withKey(context.Background(), "key1", "alice")
+
+	items := []*url_service.ShortenRequest{
+		{OriginalUrl: "https://example.com/1"},
+		{OriginalUrl: "not a url"},
+		{OriginalUrl: "https://example.com/2", CustomAlias: "mine"},
+		{OriginalUrl: "https://example.com/3", CustomAlias: "mine"},
+		{OriginalUrl: "https://example.com/4", CustomAlias: "taken"},
+		{OriginalUrl: "https://example.com/5", TtlSeconds: -1},
+		{OriginalUrl: "https://example.com/6"},
+	}
+	resp, err := s.BatchShorten(ctx, &url_service.BatchShortenRequest{Items: items})
+	if err != nil {
+		t.Fatalf("BatchShorten: %v", err)
+	}
+
+	want := []codes.Code{codes.OK, codes.InvalidArgument, codes.OK, codes.AlreadyExists, codes.AlreadyExists, codes.InvalidArgument, codes.OK}
+	if len(resp.Results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(resp.Results), len(want))
+	}
+	for i, result := range resp.Results {
+		if codes.Code(result.Code) != want[i] {
+			t.Errorf("item %d: got %v (%s), want %v", i, codes.Code(result.Code), result.Error, want[i])
+		}
+		if (result.Url != nil) != (want[i] == codes.OK) {
+			t.Errorf("item %d: url %v with code %v", i, result.Url, codes.Code(result.Code))
+		}
+	}
+
+	// The items that succeeded are stored, and the taken alias kept its URL
+	for i, result := range resp.Results {
+		if result.Url == nil {
+			continue
+		}
+		u, ok := storage.url(result.Url.ShortCode)
+		if !ok || u.OriginalUrl != items[i].OriginalUrl || u.UserId != "alice" {
+			t.Errorf("item %d: stored %v", i, u)
+		}
+	}
+	if u, _ := storage.url("taken"); u.OriginalUrl != "https://other.example" {
+		t.Errorf("taken alias now points at %s", u.OriginalUrl)
+	}
+}
+
+func TestReflectionListsServices(t *testing.T) {
+	s, _, _ := newTestServer(t, nil)
+	conn := dialBufconn(t, func(srv *grpc.Server) {
+		url_service.RegisterURLServiceServer(srv, s)
+		grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
+		reflection.Register(srv)
+	})
+
+	stream, err := grpc_reflection_v1.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatalf("ServerReflectionInfo: %v", err)
+	}
+	defer stream.CloseSend()
+	if err := stream.Send(&grpc_reflection_v1.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1.ServerReflectionRequest_ListServices{},
+	}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	var names []string
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		names = append(names, service.Name)
+	}
+	for _, want := range []string{url_service.URLService_ServiceDesc.ServiceName, grpc_health_v1.Health_ServiceDesc.ServiceName} {
+		if !slices.Contains(names, want) {
+			t.Errorf("reflection lists %v, want %s among them", names, want)
+		}
+	}
+
+	// grpcurl also needs the descriptors behind the names
+	if err := stream.Send(&grpc_reflection_v1.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: url_service.URLService_ServiceDesc.ServiceName},
+	}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if resp, err := stream.Recv(); err != nil || len(resp.GetFileDescriptorResponse().GetFileDescriptorProto()) == 0 {
+		t.Errorf("descriptor of %s = %v, %v", url_service.URLService_ServiceDesc.ServiceName, resp, err)
+	}
+}
+
+func TestGetOriginalURLSkipStats(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "counted", OriginalUrl: "https://example.com"})
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		skipStats bool
+		want      codes.Code
+		clicks    int64
+	}{
+		{"click", context.Background(), false, codes.OK, 1},
+		{"skip without key", context.Background(), true, codes.Unauthenticated, 1},
+		{"skip with key", withKey(context.Background(), "monitor-key", "monitor"), true, codes.OK, 1},
+		{"click with key", withKey(context.Background(), "monitor-key", "monitor"), false, codes.OK, 2},
+	}
+	for _, tt := range tests {
+		resp, err := s.GetOriginalURL(tt.ctx, &url_service.GetOriginalRequest{ShortCode: "counted", SkipStats: tt.skipStats})
+		if status.Code(err) != tt.want {
+			t.Fatalf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+		if err == nil && resp.OriginalUrl != "https://example.com" {
+			t.Errorf("%s: resolved to %q", tt.name, resp.OriginalUrl)
+		}
+		if n := s.clicks.Pending("counted"); n != tt.clicks {
+			t.Errorf("%s: %d clicks counted, want %d", tt.name, n, tt.clicks)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultMetadataTimeout  = 5 * time.Second
+	defaultMetadataMaxBytes = 512 << 10
+
+	metadataQueueSize    = 1000
+	metadataWorkers      = 4
+	maxMetadataRedirects = 3
+
+	// A link created with async persistence may not be in storage by the
+	// time its page is fetched, so a save that finds no row is retried,
+	// after 1s, 2s, 4s and 8s
+	metadataSaveAttempts  = 5
+	metadataSaveBaseDelay = time.Second
+
+	maxMetadataTitle       = 300
+	maxMetadataDescription = 1000
+	maxMetadataImageURL    = 2048
+	maxMetadataError       = 300
+)
+
+// Outcomes of url_service_metadata_fetches_total.
+const (
+	metadataOK      = "ok"
+	metadataFailed  = "error"
+	metadataDropped = "dropped"
+)
+
+// Addresses a fetch may not connect to besides loopback, private,
+// link-local, multicast and unspecified ones.
+var metadataDeniedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved, and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, may reach any IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+	netip.MustParsePrefix("2001:db8::/32"),  // documentation
+}
+
+// publicAddr reports whether a metadata fetch may connect to addr.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return false
+	}
+	for _, p := range metadataDeniedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// pageMetadata is what a fetch reads from a destination page.
+type pageMetadata struct {
+	title       string
+	description string
+	imageURL    string
+}
+
+type metadataJob struct {
+	ctx         context.Context // detached from the ShortenURL call, for its request ID
+	shortCode   string
+	originalURL string
+}
+
+// metadataFetcher reads the title, description and image of new links'
+// destination pages and stores them, with FETCH_METADATA set. Fetches run
+// on a few workers of their own so slow sites never hold up the async task
+// queue; when the fetcher's queue is full new links are skipped. Every
+// connection, redirects included, is checked once the host is resolved, so
+// a link can't make url-service reach an internal address. A nil fetcher,
+// used when FETCH_METADATA is unset, skips everything.
+type metadataFetcher struct {
+	client         *http.Client
+	storage        storage_service.StorageServiceClient
+	storageTimeout time.Duration
+	timeout        time.Duration
+	maxBytes       int64
+	outcomes       *prometheus.CounterVec
+	queue          chan metadataJob
+
+	// allow decides which resolved addresses may be connected to
+	allow func(netip.Addr) bool
+}
+
+// newMetadataFetcher returns the fetcher for cfg, or nil if FETCH_METADATA
+// is unset.
+func newMetadataFetcher(cfg Config, storage storage_service.StorageServiceClient, outcomes *prometheus.CounterVec) *metadataFetcher {
+	if !cfg.FetchMetadata {
+		return nil
+	}
+
+	f := &metadataFetcher{
+		storage:        storage,
+		storageTimeout: cfg.StorageTimeout,
+		timeout:        cfg.MetadataTimeout,
+		maxBytes:       int64(cfg.MetadataMaxBytes),
+		outcomes:       outcomes,
+		queue:          make(chan metadataJob, metadataQueueSize),
+		allow:          publicAddr,
+	}
+	dialer := &net.Dialer{
+		Timeout: cfg.MetadataTimeout,
+		Control: f.checkAddr,
+	}
+	f.client = &http.Client{
+		Transport: &http.Transport{
+			// A proxy would make the connection, out of checkAddr's sight
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   cfg.MetadataTimeout,
+			ResponseHeaderTimeout: cfg.MetadataTimeout,
+			MaxIdleConns:          metadataWorkers,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxMetadataRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxMetadataRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// checkAddr refuses connections to addresses f doesn't allow.
+func (f *metadataFetcher) checkAddr(network, address string, _ syscall.RawConn) error {
+	return checkDialAddr(f.allow, address)
+}
+
+// checkDialAddr returns an error unless allow accepts the host of address,
+// the host:port about to be dialed. Called from a dialer's Control, it
+// runs after DNS resolution, so a public name resolving to an internal
+// address is caught too.
+func checkDialAddr(allow func(netip.Addr) bool, address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !allow(addr) {
+		return fmt.Errorf("address %s is not public", addr)
+	}
+	return nil
+}
+
+// Fetch queues the metadata of shortCode's destination without blocking.
+func (f *metadataFetcher) Fetch(ctx context.Context, shortCode, originalURL string) {
+	if f == nil {
+		return
+	}
+	select {
+	case f.queue <- metadataJob{ctx: detach(ctx), shortCode: shortCode, originalURL: originalURL}:
+	default:
+		f.outcomes.WithLabelValues(metadataDropped).Inc()
+		logf(ctx, "Warning: metadata queue full, not fetching %s", shortCode)
+	}
+}
+
+// Run fetches queued pages until ctx is cancelled. Whatever is still
+// queued then is dropped, metadata being best effort.
+func (f *metadataFetcher) Run(ctx context.Context) {
+	if f == nil {
+		return
+	}
+	for i := 0; i < metadataWorkers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-f.queue:
+					f.process(ctx, job)
+				}
+			}
+		}()
+	}
+	<-ctx.Done()
+}
+
+// process fetches job's page and stores what it found, or why it failed.
+func (f *metadataFetcher) process(ctx context.Context, job metadataJob) {
+	req := &storage_service.UpdateMetadataRequest{ShortCode: job.shortCode}
+	meta, err := f.fetch(ctx, job.originalURL)
+	if err != nil {
+		logf(job.ctx, "Failed to fetch metadata for %s: %v", job.shortCode, err)
+		f.outcomes.WithLabelValues(metadataFailed).Inc()
+		req.Error = truncateRunes(err.Error(), maxMetadataError)
+	} else {
+		f.outcomes.WithLabelValues(metadataOK).Inc()
+		req.Title = meta.title
+		req.Description = meta.description
+		req.ImageUrl = meta.imageURL
+	}
+	f.save(job.ctx, req, 1)
+}
+
+// save stores req, retrying while storage has no row for the link yet.
+func (f *metadataFetcher) save(ctx context.Context, req *storage_service.UpdateMetadataRequest, attempt int) {
+	storageCtx, cancel := context.WithTimeout(ctx, f.storageTimeout)
+	_, err := f.storage.UpdateMetadata(storageCtx, req)
+	cancel()
+	if status.Code(err) == codes.NotFound && attempt < metadataSaveAttempts {
+		time.AfterFunc(metadataSaveBaseDelay<<(attempt-1), func() { f.save(ctx, req, attempt+1) })
+		return
+	}
+	if err != nil {
+		logf(ctx, "Warning: failed to store metadata for %s: %v", req.ShortCode, err)
+	}
+}
+
+// fetch gets rawURL, following a few redirects, and reads the metadata in
+// the first maxBytes of the page it ends on.
+func (f *metadataFetcher) fetch(ctx context.Context, rawURL string) (pageMetadata, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return pageMetadata{}, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; url-shortener metadata fetcher)")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		// Leave out the URL, storage already has it
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return pageMetadata{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return pageMetadata{}, fmt.Errorf("destination returned %s", resp.Status)
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return pageMetadata{}, fmt.Errorf("destination is not an HTML page: %q", resp.Header.Get("Content-Type"))
+	}
+
+	meta := parseMetadata(io.LimitReader(resp.Body, f.maxBytes))
+	if meta.imageURL != "" {
+		meta.imageURL = resolveImageURL(resp.Request.URL, meta.imageURL)
+	}
+	return meta, nil
+}
+
+// parseMetadata reads the <title> and the og:title, og:description,
+// description and og:image meta tags of a page, stopping at the end of its
+// head. Open Graph's description wins over the plain one and the page's
+// title over og:title.
+func parseMetadata(r io.Reader) pageMetadata {
+	var meta pageMetadata
+	var ogTitle, plainDescription string
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			// The end of the page or of what was read of it
+			return finishMetadata(meta, ogTitle, plainDescription)
+		case html.EndTagToken:
+			if name, _ := z.TagName(); atom.Lookup(name) == atom.Head {
+				return finishMetadata(meta, ogTitle, plainDescription)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Body:
+				return finishMetadata(meta, ogTitle, plainDescription)
+			case atom.Title:
+				if meta.title == "" && z.Next() == html.TextToken {
+					meta.title = string(z.Text())
+				}
+			case atom.Meta:
+				var property, content string
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = z.TagAttr()
+					switch string(key) {
+					case "property", "name":
+						if property == "" {
+							property = strings.ToLower(string(val))
+						}
+					case "content":
+						content = string(val)
+					}
+				}
+				switch property {
+				case "og:title":
+					ogTitle = content
+				case "og:description":
+					meta.description = content
+				case "description":
+					plainDescription = content
+				case "og:image", "og:image:url":
+					if meta.imageURL == "" {
+						meta.imageURL = content
+					}
+				}
+			}
+		}
+	}
+}
+
+func finishMetadata(meta pageMetadata, ogTitle, plainDescription string) pageMetadata {
+	if cleanText(meta.title) == "" {
+		meta.title = ogTitle
+	}
+	if cleanText(meta.description) == "" {
+		meta.description = plainDescription
+	}
+	return pageMetadata{
+		title:       truncateRunes(cleanText(meta.title), maxMetadataTitle),
+		description: truncateRunes(cleanText(meta.description), maxMetadataDescription),
+		imageURL:    strings.TrimSpace(meta.imageURL),
+	}
+}
+
+// resolveImageURL returns an og:image relative to the page it was found on,
+// or empty if it isn't an http(s) URL or is too long to keep.
+func resolveImageURL(page *url.URL, image string) string {
+	u, err := page.Parse(image)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return ""
+	}
+	if s := u.String(); len(s) <= maxMetadataImageURL {
+		return s
+	}
+	return ""
+}
+
+// cleanText collapses runs of whitespace and replaces invalid UTF-8, which
+// pages in other encodings produce.
+func cleanText(s string) string {
+	return strings.Join(strings.Fields(strings.ToValidUTF8(s, "\uFFFD")), " ")
+}
+
+// truncateRunes cuts s to at most n characters.
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
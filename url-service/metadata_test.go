@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newTestFetcher returns a metadata fetcher connecting only to the
+// addresses allow accepts.
+func newTestFetcher(t *testing.T, allow func(netip.Addr) bool) *metadataFetcher {
+	t.Helper()
+	cfg := Config{FetchMetadata: true, MetadataTimeout: 2 * time.Second, MetadataMaxBytes: defaultMetadataMaxBytes, StorageTimeout: time.Second}
+	outcomes := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_metadata_fetches_total"}, []string{"outcome"})
+	f := newMetadataFetcher(cfg, nil, outcomes)
+	f.allow = allow
+	return f
+}
+
+// newPage serves an HTML page on addr, a loopback address of the test
+// host, and counts the requests it got.
+func newPage(t *testing.T, addr string, handler http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	lis, err := net.Listen("tcp", addr+":0")
+	if err != nil {
+		t.Skipf("listen on %s: %v", addr, err)
+	}
+	var hits atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		handler(w, r)
+	}))
+	srv.Listener.Close()
+	srv.Listener = lis
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func htmlPage(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, body)
+	}
+}
+
+func redirectTo(target string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target, http.StatusFound)
+	}
+}
+
+// only127001 stands in for publicAddr, so httptest servers on 127.0.0.1
+// count as public and those on other loopback addresses as internal.
+func only127001(addr netip.Addr) bool {
+	return addr == netip.MustParseAddr("127.0.0.1")
+}
+
+func TestMetadataFetchRedirectChain(t *testing.T) {
+	f := newTestFetcher(t, only127001)
+	final, _ := newPage(t, "127.0.0.1", htmlPage(`<html><head>
+		<title> Final
+		page </title>
+		<meta property="og:description" content="Where the chain ends">
+		<meta property="og:image" content="/cover.png">
+		</head><body><title>Not this</title></body></html>`))
+	second, _ := newPage(t, "127.0.0.1", redirectTo(final.URL+"/article"))
+	first, _ := newPage(t, "127.0.0.1", redirectTo(second.URL))
+
+	meta, err := f.fetch(context.Background(), first.URL)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	want := pageMetadata{title: "Final page", description: "Where the chain ends", imageURL: final.URL + "/cover.png"}
+	if meta != want {
+		t.Errorf("fetch = %+v, want %+v", meta, want)
+	}
+}
+
+func TestMetadataFetchTooManyRedirects(t *testing.T) {
+	f := newTestFetcher(t, only127001)
+	next := "/page"
+	page, _ := newPage(t, "127.0.0.1", htmlPage("<title>Too far</title>"))
+	for i := 0; i <= maxMetadataRedirects; i++ {
+		srv, _ := newPage(t, "127.0.0.1", redirectTo(page.URL+next))
+		page, next = srv, ""
+	}
+	if _, err := f.fetch(context.Background(), page.URL); err == nil || !strings.Contains(err.Error(), "redirects") {
+		t.Errorf("fetch over %d redirects: %v, want refused", maxMetadataRedirects+1, err)
+	}
+}
+
+func TestMetadataFetchRefusesPrivateTarget(t *testing.T) {
+	// With the real check, the loopback test server is off limits
+	f := newTestFetcher(t, publicAddr)
+	internal, hits := newPage(t, "127.0.0.1", htmlPage("<title>Internal</title>"))
+	if _, err := f.fetch(context.Background(), internal.URL); err == nil || !strings.Contains(err.Error(), "not public") {
+		t.Errorf("fetch of %s: %v, want refused", internal.URL, err)
+	}
+
+	// A public page redirecting to an internal address is refused too
+	f = newTestFetcher(t, only127001)
+	internal, hits2 := newPage(t, "127.0.0.2", htmlPage("<title>Internal</title>"))
+	public, _ := newPage(t, "127.0.0.1", redirectTo(internal.URL))
+	if _, err := f.fetch(context.Background(), public.URL); err == nil || !strings.Contains(err.Error(), "not public") {
+		t.Errorf("fetch redirecting to %s: %v, want refused", internal.URL, err)
+	}
+	if hits.Load() != 0 || hits2.Load() != 0 {
+		t.Error("an internal page was requested")
+	}
+}
+
+func TestMetadataProcessStoresOutcome(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"FETCH_METADATA": "true"})
+	f := s.metadata
+	f.allow = only127001
+	page, _ := newPage(t, "127.0.0.1", htmlPage(`<title>Stored</title>`))
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "good", OriginalUrl: page.URL})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "bad", OriginalUrl: page.URL + "/missing"})
+
+	ctx := context.Background()
+	f.process(ctx, metadataJob{ctx: ctx, shortCode: "good", originalURL: page.URL})
+	f.process(ctx, metadataJob{ctx: ctx, shortCode: "bad", originalURL: "http://127.0.0.2:1/"})
+
+	if got := storage.storedMetadata("good"); got == nil || got.Title != "Stored" || got.Error != "" {
+		t.Errorf("stored %v for the page, want its title", got)
+	}
+	// A failed fetch is stored as such rather than dropped
+	if got := storage.storedMetadata("bad"); got == nil || !strings.Contains(got.Error, "not public") || got.Title != "" {
+		t.Errorf("stored %v for the refused page, want its error", got)
+	}
+}
+
+func TestMetadataFetchNotHTML(t *testing.T) {
+	f := newTestFetcher(t, only127001)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"json", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"title": "no"}`)
+		}},
+		{"not found", http.NotFound},
+	}
+	for _, tt := range tests {
+		srv, _ := newPage(t, "127.0.0.1", tt.handler)
+		if meta, err := f.fetch(context.Background(), srv.URL); err == nil {
+			t.Errorf("%s: fetch = %+v, want an error", tt.name, meta)
+		}
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false}, // cloud metadata
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"fc00::1", false},
+		{"fe80::1", false},
+		{"::ffff:10.0.0.1", false}, // IPv4-mapped private
+		{"64:ff9b::a00:1", false},  // NAT64 of 10.0.0.1
+	}
+	for _, tt := range tests {
+		if got := publicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("publicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestCheckDialAddr(t *testing.T) {
+	tests := []struct {
+		address string
+		ok      bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:80", true},
+		{"10.0.0.1:80", false},
+		{"[::1]:443", false},
+		{"example.com:80", false}, // not resolved
+		{"93.184.216.34", false},  // no port
+	}
+	for _, tt := range tests {
+		if err := checkDialAddr(publicAddr, tt.address); (err == nil) != tt.ok {
+			t.Errorf("checkDialAddr(%s) = %v, want ok %v", tt.address, err, tt.ok)
+		}
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+		want pageMetadata
+	}{
+		{"og title when no title", `<head><meta property="og:title" content="OG"><meta name="description" content="Plain"></head>`,
+			pageMetadata{title: "OG", description: "Plain"}},
+		{"og description wins", `<head><meta name="description" content="Plain"><meta property="og:description" content="Open Graph"></head>`,
+			pageMetadata{description: "Open Graph"}},
+		{"stops at body", `<head><title>Head</title></head><body><meta property="og:image" content="https://example.com/late.png"></body>`,
+			pageMetadata{title: "Head"}},
+		{"long title", "<title>" + strings.Repeat("é", maxMetadataTitle+10) + "</title>",
+			pageMetadata{title: strings.Repeat("é", maxMetadataTitle)}},
+		{"invalid utf-8", "<title>caf\xe9</title>", pageMetadata{title: "caf�"}},
+	}
+	for _, tt := range tests {
+		if got := parseMetadata(strings.NewReader(tt.page)); got != tt.want {
+			t.Errorf("%s: parseMetadata = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"log"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Metrics exported on /metrics. Names and labels are part of the service's
+// interface; dashboards and alerts depend on them, so don't rename.
+//
+//	url_service_grpc_requests_total{method,code}          RPCs handled
+//	url_service_grpc_request_duration_seconds{method}     RPC latency
+//	url_service_lookups_total{source}                     GetOriginalURL outcomes: cache, memory, stale, storage, negative_cache, expired, deleted, not_found
+//	url_service_lookup_layer_duration_seconds{layer}      GetOriginalURL reads of the cache, negative_cache and storage, timeouts included
+//	url_service_async_queue_depth                         tasks waiting for a worker
+//	url_service_async_tasks_dropped_total                 tasks dropped by the overflow policy
+//	url_service_unpersisted_urls                          URLs waiting for a storage retry
+//	url_service_unflushed_clicks                          clicks not yet written to storage
+//	url_service_admission_saturated                       1 while ShortenURL and BatchShorten are shed
+//	url_service_admission_saturation                      async queue or unflushed clicks over their high watermark, whichever is higher; 1 saturates
+//	url_service_admission_rejected_total{method}          writes shed while saturated
+//	url_service_click_flush_batch_size                    codes per click flush
+//	url_service_circuit_breaker_state{dependency}         0 closed, 1 open, 2 half-open
+//	url_service_cache_nodes                               cache-service nodes on the ring
+//	url_service_cache_failovers_total{node}               cache calls sent past a node whose breaker was open or that was unavailable
+//	url_service_downstream_connections{service,backend}   open connections to each cache-service and storage-service address
+//	url_service_downstream_rpcs_total{service,backend,code} downstream call attempts by the address they went to, "none" if they reached none
+//	url_service_short_codes_generated_total{source}       generated codes: random, sequence, pool or hash
+//	url_service_blocked_destinations_total                destinations turned down by the blocked domains
+//	url_service_reputation_total{outcome}                 reputation screening: clean, malicious, error, and links disabled by the rescan
+//	url_service_metadata_fetches_total{outcome}           destination page metadata fetches: ok, error, or dropped by a full queue
+//	url_service_click_feed_subscribers                    open StreamClicks subscriptions
+//	url_service_click_feed_dropped_total                  clicks dropped for StreamClicks subscribers that fell behind
+//	url_service_events_published_total                    events accepted by the event broker
+//	url_service_events_dropped_total                      events dropped by a full queue or a failing broker
+type serviceMetrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	lookups         *prometheus.CounterVec
+	layers          *prometheus.HistogramVec
+	cacheFailovers  *prometheus.CounterVec
+	clickFlushSize  prometheus.Histogram
+	shortCodes      *prometheus.CounterVec
+	reputation      *prometheus.CounterVec
+	metadata        *prometheus.CounterVec
+	downstream      *downstreamMetrics
+
+	// Lookups by source since the last hit ratio log line
+	windowMu sync.Mutex
+	window   map[string]int64
+}
+
+func newServiceMetrics() *serviceMetrics {
+	m := &serviceMetrics{
+		registry: prometheus.NewRegistry(),
+		window:   make(map[string]int64),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "url_service_grpc_requests_total",
+			Help: "gRPC requests handled, by method and status code.",
+		}, []string{"method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "url_service_grpc_request_duration_seconds",
+			Help:    "gRPC request latency, by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		lookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "url_service_lookups_total",
+			Help: "GetOriginalURL lookups, by where the answer came from.",
+		}, []string{"source"}),
+		layers: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "url_service_lookup_layer_duration_seconds",
+			Help:    "Latency of the reads made by GetOriginalURL, by layer, including those cut short by the lookup budget.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"layer"}),
+		cacheFailovers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "url_service_cache_failovers_total",
+			Help: "Cache calls sent to the next node on the ring, by the node that was skipped.",
+		}, []string{"node"}),
+		clickFlushSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "url_service_click_flush_batch_size",
+			Help:    "Number of short codes written per click flush.",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 8),
+		}),
+		shortCodes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "url_service_short_codes_generated_total",
+			Help: "Short codes generated, by where they came from. Pool fallbacks count as random.",
+		}, []string{"source"}),
+		reputation: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "url_service_reputation_total",
+			Help: "Destinations screened against the reputation provider, by outcome, and links disabled by the rescan.",
+		}, []string{"outcome"}),
+		metadata: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "url_service_metadata_fetches_total",
+			Help: "Destination pages fetched for their title and Open Graph tags, by outcome.",
+		}, []string{"outcome"}),
+		downstream: newDownstreamMetrics(),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.lookups,
+		m.layers,
+		m.cacheFailovers,
+		m.clickFlushSize,
+		m.shortCodes,
+		m.reputation,
+		m.metadata,
+		m.downstream.connections,
+		m.downstream.rpcs,
+	)
+	return m
+}
+
+// registerServer exports gauges read from the server's components at scrape time.
+func (m *serviceMetrics) registerServer(s *urlServer) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "url_service_async_queue_depth",
+			Help: "Async tasks waiting for a worker.",
+		}, func() float64 { return float64(s.tasks.Depth()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "url_service_async_tasks_dropped_total",
+			Help: "Async tasks dropped because the queue was full or closed.",
+		}, func() float64 { return float64(s.tasks.Dropped()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "url_service_unpersisted_urls",
+			Help: "Short codes handed out but not yet written to storage.",
+		}, func() float64 { return float64(s.persister.Pending()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "url_service_unflushed_clicks",
+			Help: "Clicks counted but not yet written to storage.",
+		}, func() float64 { return float64(s.clicks.Unflushed()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "url_service_admission_saturated",
+			Help: "1 while writes are rejected because async work is backed up.",
+		}, func() float64 {
+			if s.admission.Saturated() {
+				return 1
+			}
+			return 0
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "url_service_admission_saturation",
+			Help: "Async queue depth or unflushed clicks as a share of their high watermark, whichever is higher.",
+		}, s.admission.level),
+		s.admission.rejected,
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "url_service_blocked_destinations_total",
+			Help: "URLs not shortened because of the blocked domains or the allowlist.",
+		}, func() float64 { return float64(s.domains.Rejected()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "url_service_click_feed_subscribers",
+			Help: "Open StreamClicks subscriptions.",
+		}, func() float64 { return float64(s.feed.Subscribers()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "url_service_click_feed_dropped_total",
+			Help: "Clicks dropped for StreamClicks subscribers that fell behind.",
+		}, func() float64 { return float64(s.feed.Dropped()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "url_service_events_published_total",
+			Help: "Events accepted by the event broker.",
+		}, func() float64 { return float64(s.events.Published()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "url_service_events_dropped_total",
+			Help: "Events dropped because the queue was full or the broker failed.",
+		}, func() float64 { return float64(s.events.Dropped()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "url_service_cache_nodes",
+			Help: "cache-service nodes on the ring.",
+		}, func() float64 { return float64(len(s.cacheRing.members())) }),
+		breakerCollector{s: s},
+	)
+}
+
+var breakerStateDesc = prometheus.NewDesc("url_service_circuit_breaker_state",
+	"Circuit breaker state: 0 closed, 1 open, 2 half-open.", []string{"dependency"}, nil)
+
+// breakerCollector exports the state of every breaker at scrape time, since
+// cache nodes come and go with the ring.
+type breakerCollector struct {
+	s *urlServer
+}
+
+func (c breakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- breakerStateDesc
+}
+
+func (c breakerCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, b := range c.s.breakers() {
+		ch <- prometheus.MustNewConstMetric(breakerStateDesc, prometheus.GaugeValue, float64(b.State()), b.name)
+	}
+}
+
+// UnaryServerInterceptor records request counts and latency per RPC.
+func (m *serviceMetrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		method := path.Base(info.FullMethod)
+		m.requests.WithLabelValues(method, status.Code(err).String()).Inc()
+		m.requestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		return resp, err
+	}
+}
+
+// lookup counts a GetOriginalURL outcome.
+func (m *serviceMetrics) lookup(source string) {
+	m.lookups.WithLabelValues(source).Inc()
+
+	m.windowMu.Lock()
+	m.window[source]++
+	m.windowMu.Unlock()
+}
+
+// layerDuration records how long a lookup's read of layer took.
+func (m *serviceMetrics) layerDuration(layer string, d time.Duration) {
+	m.layers.WithLabelValues(layer).Observe(d.Seconds())
+}
+
+// logHitRatio logs, every interval, the share of lookups answered from the
+// shared cache or memory without reaching storage, until ctx is cancelled.
+func (m *serviceMetrics) logHitRatio(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		m.windowMu.Lock()
+		window := m.window
+		m.window = make(map[string]int64)
+		m.windowMu.Unlock()
+
+		var total int64
+		for _, n := range window {
+			total += n
+		}
+		if total == 0 {
+			continue
+		}
+		cached := window["cache"] + window["negative_cache"]
+		memory := window["memory"] + window["stale"]
+		hits := cached + memory
+		log.Printf("Lookup hit ratio over the last %s: %.1f%% (%d cache, %d memory, %d storage of %d lookups)",
+			interval, 100*float64(hits)/float64(total), cached, memory, total-hits, total)
+	}
+}
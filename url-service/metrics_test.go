@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// gathered returns the value of every series of name in registry, keyed by
+// its labels as name=value pairs joined with commas.
+func gathered(t *testing.T, registry *prometheus.Registry, name string) map[string]float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	series := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			var labels string
+			for i, pair := range m.GetLabel() {
+				if i > 0 {
+					labels += ","
+				}
+				labels += pair.GetName() + "=" + pair.GetValue()
+			}
+			switch {
+			case m.Counter != nil:
+				series[labels] = m.Counter.GetValue()
+			case m.Gauge != nil:
+				series[labels] = m.Gauge.GetValue()
+			case m.Histogram != nil:
+				series[labels] = float64(m.Histogram.GetSampleCount())
+			}
+		}
+	}
+	return series
+}
+
+func TestMetricsAfterTraffic(t *testing.T) {
+	s, _, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+	conn := dialBufconn(t, func(srv *grpc.Server) { url_service.RegisterURLServiceServer(srv, s) },
+		grpc.UnaryInterceptor(s.metrics.UnaryServerInterceptor()))
+	client := url_service.NewURLServiceClient(conn)
+	ctx := context.Background()
+
+	if _, err := client.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "metered"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "metered"}); err != nil {
+			t.Fatalf("GetOriginalURL: %v", err)
+		}
+	}
+	if _, err := client.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("GetOriginalURL(missing): got %v, want NotFound", err)
+	}
+
+	requests := gathered(t, s.metrics.registry, "url_service_grpc_requests_total")
+	for labels, want := range map[string]float64{
+		"code=OK,method=ShortenURL":           1,
+		"code=OK,method=GetOriginalURL":       3,
+		"code=NotFound,method=GetOriginalURL": 1,
+	} {
+		if requests[labels] != want {
+			t.Errorf("url_service_grpc_requests_total{%s} = %v, want %v", labels, requests[labels], want)
+		}
+	}
+	if durations := gathered(t, s.metrics.registry, "url_service_grpc_request_duration_seconds"); durations["method=GetOriginalURL"] != 4 {
+		t.Errorf("url_service_grpc_request_duration_seconds observed %v GetOriginalURL calls, want 4", durations["method=GetOriginalURL"])
+	}
+
+	// Every lookup is counted once by where it was answered
+	lookups := gathered(t, s.metrics.registry, "url_service_lookups_total")
+	var total float64
+	for _, n := range lookups {
+		total += n
+	}
+	if total != 4 || lookups["source=not_found"] != 1 {
+		t.Errorf("url_service_lookups_total = %v, want 4 lookups with 1 not_found", lookups)
+	}
+
+	for _, name := range []string{"url_service_async_queue_depth", "url_service_unpersisted_urls", "url_service_unflushed_clicks"} {
+		if _, ok := gathered(t, s.metrics.registry, name)[""]; !ok {
+			t.Errorf("%s not exported", name)
+		}
+	}
+	breakers := gathered(t, s.metrics.registry, "url_service_circuit_breaker_state")
+	if state, ok := breakers["dependency=storage-service"]; !ok || state != float64(breakerClosed) {
+		t.Errorf("url_service_circuit_breaker_state = %v, want storage-service closed", breakers)
+	}
+}
+
+func TestLogHitRatio(t *testing.T) {
+	logs := captureLogs(t)
+	m := newServiceMetrics()
+	for _, source := range []string{"cache", "cache", "memory", "negative_cache", "storage"} {
+		m.lookup(source)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.logHitRatio(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	want := "hit ratio over the last 10ms: 80.0% (3 cache, 1 memory, 1 storage of 5 lookups)"
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(logs.String(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("logs %q, want %q", logs.String(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	// Each window is logged once, and empty ones not at all
+	if n := strings.Count(logs.String(), "hit ratio"); n != 1 {
+		t.Errorf("%d hit ratio lines, want 1", n)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	cache_service "github.com/syedalijabir/protos/cache-service"
+)
+
+const (
+	defaultNegativeCacheTTL = 60 * time.Second
+
+	// Not-found entries are also kept in memory, but only briefly and in
+	// limited number: other replicas can create a code without being able
+	// to invalidate this replica's memory.
+	negativeMemoryTTL        = 5 * time.Second
+	negativeMemoryMaxEntries = 10000
+)
+
+// isKnownMissing reports whether shortCode was recently looked up and found
+// not to exist, checking memory first and then the shared cache.
+func (s *urlServer) isKnownMissing(ctx context.Context, shortCode string) bool {
+	if s.live().negativeTTL == 0 {
+		return false
+	}
+
+	s.mu.RLock()
+	until, ok := s.missing[shortCode]
+	s.mu.RUnlock()
+	if ok && time.Now().Before(until) {
+		return true
+	}
+
+	cacheCtx, cancel, ok := s.lookupCacheCtx(ctx, s.budget.cache)
+	if !ok {
+		return false
+	}
+	defer cancel()
+	start := time.Now()
+	resp, err := s.cacheClient.Exists(cacheCtx, &cache_service.ExistsRequest{Namespace: notFoundNamespace, Key: shortCode})
+	s.metrics.layerDuration("negative_cache", time.Since(start))
+	return err == nil && resp.Exists
+}
+
+// rememberMissing records that shortCode doesn't exist, unless it was
+// created while the lookup that found it missing was in flight.
+func (s *urlServer) rememberMissing(ctx context.Context, shortCode string) {
+	if s.live().negativeTTL == 0 || s.urls.Contains(shortCode) {
+		return
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	if len(s.missing) >= negativeMemoryMaxEntries {
+		for code, until := range s.missing {
+			if now.After(until) {
+				delete(s.missing, code)
+			}
+		}
+	}
+	if len(s.missing) < negativeMemoryMaxEntries {
+		s.missing[shortCode] = now.Add(min(negativeMemoryTTL, s.live().negativeTTL))
+	}
+	s.mu.Unlock()
+
+	bg := detach(ctx)
+	s.tasks.Submit("negative cache "+shortCode, func() {
+		ctx, cancel := s.cacheCtx(bg)
+		defer cancel()
+
+		_, err := s.cacheClient.Set(ctx, &cache_service.SetRequest{
+			Namespace:  notFoundNamespace,
+			Key:        shortCode,
+			Value:      "1",
+			TtlSeconds: int32(s.live().negativeTTL / time.Second),
+		})
+		if err != nil {
+			logf(ctx, "Warning: failed to cache not-found entry: %v", err)
+		}
+
+		// Created while the sentinel was being written
+		if s.urls.Contains(shortCode) {
+			s.forgetMissing(bg, shortCode)
+		}
+	})
+}
+
+// forgetMissing drops any not-found entry for shortCode so a newly created
+// link isn't shadowed.
+func (s *urlServer) forgetMissing(ctx context.Context, shortCode string) {
+	if s.live().negativeTTL == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	delete(s.missing, shortCode)
+	s.mu.Unlock()
+
+	ctx, cancel := s.cacheCtx(ctx)
+	defer cancel()
+
+	if _, err := s.cacheClient.Delete(ctx, &cache_service.DeleteRequest{Namespace: notFoundNamespace, Key: shortCode}); err != nil {
+		logf(ctx, "Warning: failed to invalidate not-found entry for %s: %v", shortCode, err)
+	}
+}
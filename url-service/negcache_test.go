@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// waitForCacheEntry waits until the cache holds key, or doesn't as present says.
+func waitForCacheEntry(t *testing.T, cache *fakeCache, key string, present bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := cache.entry(key); ok == present {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("cache entry %s present %v after 5s, want %v", key, !present, present)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNegativeCacheSkipsStorage(t *testing.T) {
+	s, storage, cache := newTestServer(t, nil)
+	var lookups atomic.Int32
+	storage.afterGet = func(string) { lookups.Add(1) }
+	ctx := context.Background()
+
+	lookup := func(step string) {
+		t.Helper()
+		if _, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "ghost"}); status.Code(err) != codes.NotFound {
+			t.Fatalf("%s: got %v, want NotFound", step, err)
+		}
+	}
+	lookup("first lookup")
+	waitForCacheEntry(t, cache, "notfound:ghost", true)
+	if ttl := cache.ttl("notfound:ghost"); ttl != int32(defaultNegativeCacheTTL/time.Second) {
+		t.Errorf("sentinel TTL %ds, want %v", ttl, defaultNegativeCacheTTL)
+	}
+
+	// Memory answers first, then the shared sentinel once memory forgot
+	lookup("memory")
+	s.mu.Lock()
+	delete(s.missing, "ghost")
+	s.mu.Unlock()
+	lookup("cache")
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("%d storage lookups, want 1", n)
+	}
+}
+
+func TestNegativeCacheDisabled(t *testing.T) {
+	s, storage, cache := newTestServer(t, map[string]string{"NEGATIVE_CACHE_TTL": "0s"})
+	var lookups atomic.Int32
+	storage.afterGet = func(string) { lookups.Add(1) }
+	for i := 0; i < 3; i++ {
+		s.GetOriginalURL(context.Background(), &url_service.GetOriginalRequest{ShortCode: "ghost"})
+	}
+	if n := lookups.Load(); n != 3 {
+		t.Errorf("%d storage lookups, want 3", n)
+	}
+	if _, ok := cache.entry("notfound:ghost"); ok {
+		t.Error("sentinel cached with negative caching disabled")
+	}
+}
+
+func TestCreateAfterNegativeCache(t *testing.T) {
+	s, _, cache := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+	ctx := context.Background()
+	if _, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "fresh"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("GetOriginalURL before creating: got %v, want NotFound", err)
+	}
+	waitForCacheEntry(t, cache, "notfound:fresh", true)
+
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "fresh"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	if _, ok := cache.entry("notfound:fresh"); ok {
+		t.Error("sentinel still cached after the code was created")
+	}
+	if resp, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "fresh"}); err != nil || resp.OriginalUrl != "https://example.com" {
+		t.Errorf("GetOriginalURL after creating = %v, %v, want https://example.com", resp, err)
+	}
+
+	// An update clears a sentinel another replica wrote too
+	cache.set("notfound:fresh", "1")
+	if _, err := s.UpdateURL(ctx, &url_service.UpdateURLRequest{ShortCode: "fresh", OriginalUrl: "https://example.com/new"}); err != nil {
+		t.Fatalf("UpdateURL: %v", err)
+	}
+	if _, ok := cache.entry("notfound:fresh"); ok {
+		t.Error("sentinel still cached after the update")
+	}
+}
+
+func TestCreateDuringNegativeLookup(t *testing.T) {
+	s, storage, cache := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+	ctx := context.Background()
+
+	// The code is created after storage found it missing but before the
+	// lookup records that
+	var created atomic.Bool
+	storage.afterGet = func(shortCode string) {
+		if created.CompareAndSwap(false, true) {
+			if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: shortCode}); err != nil {
+				t.Errorf("ShortenURL: %v", err)
+			}
+		}
+	}
+	if _, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "racy"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("racing GetOriginalURL: got %v, want NotFound", err)
+	}
+	s.tasks.Close(context.Background())
+
+	if _, ok := cache.entry("notfound:racy"); ok {
+		t.Error("sentinel cached for a code created during the lookup")
+	}
+	if resp, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "racy"}); err != nil || resp.OriginalUrl != "https://example.com" {
+		t.Errorf("GetOriginalURL after the race = %v, %v, want https://example.com", resp, err)
+	}
+}
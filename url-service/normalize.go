@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// normalizeURL rewrites an already validated URL into a canonical form so
+// equivalent URLs are stored and deduplicated as one:
+//   - scheme and host are lowercased, IDN hosts are converted to punycode
+//   - default ports (80 for http, 443 for https) are dropped
+//   - trailing slashes are removed from the path
+//   - percent-encoded unreserved characters are decoded and the remaining
+//     escapes are uppercased
+//   - query parameters are sorted by key, keeping the order of repeated keys
+//
+// The fragment is kept as is.
+func normalizeURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	host := strings.ToLower(u.Hostname())
+	if net.ParseIP(host) == nil {
+		host, err = idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", fmt.Errorf("invalid host %q: %v", u.Hostname(), err)
+		}
+	}
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Host = host
+
+	escapedPath := strings.TrimRight(normalizePercentEncoding(u.EscapedPath()), "/")
+	path, err := url.PathUnescape(escapedPath)
+	if err != nil {
+		return "", err
+	}
+	u.Path = path
+	u.RawPath = escapedPath
+
+	if u.RawQuery != "" {
+		u.RawQuery = sortQuery(u.RawQuery)
+	}
+
+	return u.String(), nil
+}
+
+// normalizePercentEncoding decodes percent-escapes of unreserved characters
+// (RFC 3986 section 2.3) and uppercases the hex digits of all other escapes.
+func normalizePercentEncoding(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			c := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(c) {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte('%')
+				b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+			}
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// sortQuery orders query parameters by key with a stable sort so repeated
+// keys keep their relative order. Parameters are otherwise left untouched.
+func sortQuery(rawQuery string) string {
+	params := strings.Split(rawQuery, "&")
+	sort.SliceStable(params, func(i, j int) bool {
+		return queryKey(params[i]) < queryKey(params[j])
+	})
+	for i, p := range params {
+		params[i] = normalizePercentEncoding(p)
+	}
+	return strings.Join(params, "&")
+}
+
+func queryKey(param string) string {
+	key, _, _ := strings.Cut(param, "=")
+	return key
+}
+
+func isUnreserved(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultListPageSize = 50
+	maxListPageSize     = 100
+)
+
+// checkOwner rejects changes to shortCode by anyone but the user who created
+// it. Without authentication there is no caller to check, so any change is
+// allowed, as before ownership existed.
+func (s *urlServer) checkOwner(ctx context.Context, shortCode string) error {
+	user := userID(ctx)
+	if user == "" {
+		return nil
+	}
+
+	// Owners never change, so a remembered owner is as good as storage's
+	owner := ""
+	if entry, ok := s.urls.Get(shortCode); ok {
+		owner = entry.userID
+	}
+	if owner == "" {
+		storageCtx, cancel := s.storageCtx(ctx)
+		defer cancel()
+		resp, err := s.storageClient.GetURL(storageCtx, &storage_service.GetURLRequest{ShortCode: shortCode, ForcePrimary: true})
+		if status.Code(err) == codes.NotFound {
+			return status.Error(codes.NotFound, "URL not found")
+		} else if err != nil {
+			logf(ctx, "Storage owner lookup failed for %s: %v", shortCode, err)
+			return status.Error(codes.Unavailable, "storage unavailable")
+		}
+		owner = resp.UserId
+	}
+
+	if owner != user {
+		return status.Error(codes.PermissionDenied, "URL is owned by another user")
+	}
+	return nil
+}
+
+// checkAdmin rejects callers who aren't in ADMIN_USERS. It fails closed:
+// without authentication there is no caller to check, so nobody is let
+// through.
+func (s *urlServer) checkAdmin(ctx context.Context) error {
+	user := userID(ctx)
+	if user == "" {
+		return status.Error(codes.Unauthenticated, "admin access requires an API key")
+	}
+	if !s.admins[user] {
+		return status.Error(codes.PermissionDenied, "admin access required")
+	}
+	return nil
+}
+
+// checkQuota rejects n new links if they would take the caller past
+// maxURLsPerUser active ones. Links still waiting to be persisted aren't
+// counted.
+func (s *urlServer) checkQuota(ctx context.Context, n int) error {
+	user := userID(ctx)
+	if user == "" || s.maxURLsPerUser == 0 {
+		return nil
+	}
+
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	resp, err := s.storageClient.CountURLs(storageCtx, &storage_service.CountURLsRequest{UserId: user, TenantId: tenantID(ctx)})
+	if err != nil {
+		logf(ctx, "Failed to count URLs of %s: %v", user, err)
+		return status.Error(codes.Unavailable, "failed to check link quota")
+	}
+	if resp.Active+int64(n) > int64(s.maxURLsPerUser) {
+		return status.Errorf(codes.ResourceExhausted, "quota of %d active links reached", s.maxURLsPerUser)
+	}
+	return nil
+}
+
+// listedUser returns the user whose links a listing is for: the caller,
+// or without authentication the user asked for.
+func listedUser(ctx context.Context, requested string) (string, error) {
+	user := userID(ctx)
+	switch {
+	case user == "":
+		user = requested
+	case requested != "" && requested != user:
+		return "", status.Error(codes.PermissionDenied, "cannot list another user's URLs")
+	}
+	if user == "" {
+		return "", status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	return user, nil
+}
+
+// ListURLs returns a page of the caller's links, newest first, optionally
+// only those with all of the given tags.
+func (s *urlServer) ListURLs(ctx context.Context, req *url_service.ListURLsRequest) (*url_service.ListURLsResponse, error) {
+	logf(ctx, "ListURLs request for user: %s", req.UserId)
+
+	user, err := listedUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := req.PageSize
+	if pageSize < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	}
+	if pageSize == 0 {
+		pageSize = defaultListPageSize
+	}
+	pageSize = min(pageSize, maxListPageSize)
+
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	resp, err := s.storageClient.ListURLs(storageCtx, &storage_service.ListURLsRequest{
+		UserId:    user,
+		PageSize:  pageSize,
+		PageToken: req.PageToken,
+		Tags:      tags,
+		TenantId:  tenantID(ctx),
+	})
+	if status.Code(err) == codes.InvalidArgument {
+		return nil, err
+	} else if err != nil {
+		logf(ctx, "Failed to list URLs of %s: %v", user, err)
+		return nil, status.Error(codes.Unavailable, "failed to list URLs")
+	}
+
+	urls := make([]*url_service.URLSummary, 0, len(resp.Urls))
+	for _, u := range resp.Urls {
+		urls = append(urls, &url_service.URLSummary{
+			ShortCode:   u.ShortCode,
+			OriginalUrl: u.OriginalUrl,
+			// Include clicks not yet flushed to storage
+			ClickCount:     u.ClickCount + s.clicks.Pending(u.ShortCode),
+			CreatedAt:      u.CreatedAt,
+			ExpiresAt:      u.ExpiresAt,
+			Disabled:       u.Disabled,
+			Tags:           u.Tags,
+			LastAccessedAt: lastAccessedAt(u.LastAccessedAt, s.clicks.PendingTallies(u.ShortCode)),
+			Title:          u.Title,
+			Description:    u.Description,
+			ImageUrl:       u.ImageUrl,
+		})
+	}
+	return &url_service.ListURLsResponse{
+		Urls:          urls,
+		NextPageToken: resp.NextPageToken,
+	}, nil
+}
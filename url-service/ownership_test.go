@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestQuotaEnforced(t *testing.T) {
+	s, _, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true", "MAX_URLS_PER_USER": "2"})
+	alice := withKey(context.Background(), "alice-key", "alice")
+	bob := withKey(context.Background(), "bob-key", "bob")
+
+	shorten := func(ctx context.Context, alias string) error {
+		_, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com/" + alias, CustomAlias: alias})
+		return err
+	}
+	for _, alias := range []string{"alice1", "alice2"} {
+		if err := shorten(alice, alias); err != nil {
+			t.Fatalf("ShortenURL(%s) within the quota: %v", alias, err)
+		}
+	}
+	if err := shorten(alice, "alice3"); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("ShortenURL past the quota: got %v, want ResourceExhausted", err)
+	}
+	_, err := s.BatchShorten(alice, &url_service.BatchShortenRequest{Items: []*url_service.ShortenRequest{{OriginalUrl: "https://example.com/batch"}}})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("BatchShorten past the quota: got %v, want ResourceExhausted", err)
+	}
+
+	// Quotas are per user, and deleting a link frees its slot
+	if err := shorten(bob, "bob1"); err != nil {
+		t.Errorf("ShortenURL for another user: %v", err)
+	}
+	if _, err := s.DeleteURL(alice, &url_service.DeleteURLRequest{ShortCode: "alice1"}); err != nil {
+		t.Fatalf("DeleteURL: %v", err)
+	}
+	if err := shorten(alice, "alice3"); err != nil {
+		t.Errorf("ShortenURL after deleting a link: %v", err)
+	}
+}
+
+func TestOwnershipChecks(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+	alice := withKey(context.Background(), "alice-key", "alice")
+	bob := withKey(context.Background(), "bob-key", "bob")
+	if _, err := s.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "owned"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	if req, _ := storage.url("owned"); req.UserId != "alice" {
+		t.Fatalf("owned saved for user %q, want alice", req.UserId)
+	}
+
+	if _, err := s.UpdateURL(bob, &url_service.UpdateURLRequest{ShortCode: "owned", OriginalUrl: "https://bob.example"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("UpdateURL by another user: got %v, want PermissionDenied", err)
+	}
+	if _, err := s.DeleteURL(bob, &url_service.DeleteURLRequest{ShortCode: "owned"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("DeleteURL by another user: got %v, want PermissionDenied", err)
+	}
+	if _, err := s.ListURLs(bob, &url_service.ListURLsRequest{UserId: "alice"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("ListURLs of another user: got %v, want PermissionDenied", err)
+	}
+	if _, err := s.ListURLs(alice, &url_service.ListURLsRequest{PageSize: -1}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListURLs with a negative page size: got %v, want InvalidArgument", err)
+	}
+
+	if _, err := s.UpdateURL(alice, &url_service.UpdateURLRequest{ShortCode: "owned", OriginalUrl: "https://example.com/new"}); err != nil {
+		t.Errorf("UpdateURL by the owner: %v", err)
+	}
+	if _, err := s.DeleteURL(alice, &url_service.DeleteURLRequest{ShortCode: "owned"}); err != nil {
+		t.Errorf("DeleteURL by the owner: %v", err)
+	}
+}
+
+func TestCheckAdmin(t *testing.T) {
+	s, _, _ := newTestServer(t, map[string]string{"ADMIN_USERS": "root, ops"})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want codes.Code
+	}{
+		{"anonymous", context.Background(), codes.Unauthenticated},
+		{"not an admin", withKey(context.Background(), "alice-key", "alice"), codes.PermissionDenied},
+		{"admin", withKey(context.Background(), "root-key", "root"), codes.OK},
+		{"admin after a space", withKey(context.Background(), "ops-key", "ops"), codes.OK},
+	}
+	for _, tt := range tests {
+		if err := s.checkAdmin(tt.ctx); status.Code(err) != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// Without ADMIN_USERS nobody is an admin
+	none, _, _ := newTestServer(t, nil)
+	if err := none.checkAdmin(context.Background()); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("anonymous without admins: got %v, want Unauthenticated", err)
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPersistMaxAttempts = 10
+	defaultPersistBaseDelay   = time.Second
+	maxPersistDelay           = 5 * time.Minute
+	persistWALFile            = "pending-saves.jsonl"
+)
+
+// pendingSave is a URL that has been handed out but not yet written to storage.
+type pendingSave struct {
+	ShortCode     string        `json:"short_code"`
+	OriginalURL   string        `json:"original_url"`
+	ExpiresAt     string        `json:"expires_at,omitempty"`
+	APIKeyID      string        `json:"api_key_id,omitempty"`
+	UserID        string        `json:"user_id,omitempty"`
+	NotBefore     string        `json:"not_before,omitempty"`
+	ComingSoonURL string        `json:"coming_soon_url,omitempty"`
+	FallbackURL   string        `json:"fallback_url,omitempty"`
+	Variants      *variantSet   `json:"variants,omitempty"`
+	Rules         redirectRules `json:"rules,omitempty"`
+	QueryTemplate string        `json:"query_template,omitempty"`
+	Tags          []string      `json:"tags,omitempty"`
+	Attempts      int           `json:"attempts"`
+	NextAttempt   time.Time     `json:"next_attempt"`
+}
+
+// urlPersister writes new URLs to storage, retrying failed writes with
+// exponential backoff. When a WAL directory is configured the pending set is
+// mirrored to a JSONL file and replayed at startup so a restart doesn't lose
+// short codes that were already returned to users.
+type urlPersister struct {
+	storageClient storage_service.StorageServiceClient
+	timeout       time.Duration
+	maxAttempts   int
+	baseDelay     time.Duration
+	walPath       string
+
+	// lost is called with the code of a save another URL took first; it
+	// must not call back into the persister
+	lost func(shortCode string)
+
+	mu      sync.Mutex
+	pending map[string]*pendingSave
+}
+
+func newURLPersister(storageClient storage_service.StorageServiceClient, timeout time.Duration, maxAttempts int, baseDelay time.Duration, walDir string) (*urlPersister, error) {
+	p := &urlPersister{
+		storageClient: storageClient,
+		timeout:       timeout,
+		maxAttempts:   maxAttempts,
+		baseDelay:     baseDelay,
+		pending:       make(map[string]*pendingSave),
+	}
+
+	if walDir != "" {
+		if err := os.MkdirAll(walDir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create WAL directory: %v", err)
+		}
+		p.walPath = filepath.Join(walDir, persistWALFile)
+		if err := p.replay(); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// Save writes a URL to storage, queueing it for retry on failure.
+func (p *urlPersister) Save(ctx context.Context, save *pendingSave) {
+	err := p.write(ctx, save)
+	if status.Code(err) == codes.AlreadyExists {
+		p.lose(save.ShortCode, err)
+		return
+	}
+	if err != nil {
+		log.Printf("Warning: failed to persist URL %s to storage, queued for retry: %v", save.ShortCode, err)
+		p.mu.Lock()
+		p.schedule(save)
+		p.pending[save.ShortCode] = save
+		p.syncWAL()
+		p.mu.Unlock()
+		return
+	}
+
+	log.Printf("URL persisted to storage: %s", save.ShortCode)
+}
+
+// Update points a pending save at new destinations so a later retry
+// doesn't overwrite an update that was already written.
+func (p *urlPersister) Update(shortCode, originalURL string, routes linkRoutes) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if save, ok := p.pending[shortCode]; ok {
+		save.OriginalURL = originalURL
+		save.Variants = routes.variants
+		save.Rules = routes.rules
+		save.QueryTemplate = routes.queryTemplate
+		p.syncWAL()
+	}
+}
+
+// UpdateTags replaces the tags of a pending save, like Update.
+func (p *urlPersister) UpdateTags(shortCode string, tags []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if save, ok := p.pending[shortCode]; ok {
+		save.Tags = tags
+		p.syncWAL()
+	}
+}
+
+// Forget drops a pending save, e.g. because the URL was deleted.
+func (p *urlPersister) Forget(shortCode string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.pending[shortCode]; ok {
+		delete(p.pending, shortCode)
+		p.syncWAL()
+	}
+}
+
+// IsPending reports whether shortCode is waiting for a storage retry.
+func (p *urlPersister) IsPending(shortCode string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.pending[shortCode]
+	return ok
+}
+
+// Pending returns the number of URLs not yet persisted.
+func (p *urlPersister) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.pending)
+}
+
+// Run retries pending saves until ctx is cancelled.
+func (p *urlPersister) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.retry(ctx, false)
+		}
+	}
+}
+
+// Flush makes one more attempt at every pending save regardless of backoff.
+// Saves that still fail stay in the WAL for the next start.
+func (p *urlPersister) Flush(ctx context.Context) {
+	p.retry(ctx, true)
+}
+
+// retry attempts pending saves whose backoff has elapsed, or all of them if
+// all is set.
+func (p *urlPersister) retry(ctx context.Context, all bool) {
+	now := time.Now()
+
+	p.mu.Lock()
+	var due []pendingSave
+	for _, save := range p.pending {
+		if all || !now.Before(save.NextAttempt) {
+			due = append(due, *save)
+		}
+	}
+	p.mu.Unlock()
+
+	for i := range due {
+		save := &due[i]
+		err := p.write(ctx, save)
+
+		p.mu.Lock()
+		current, ok := p.pending[save.ShortCode]
+		if !ok {
+			// Forgotten while the write was in flight
+			p.mu.Unlock()
+			continue
+		}
+		switch {
+		case status.Code(err) == codes.AlreadyExists:
+			delete(p.pending, save.ShortCode)
+			p.lose(save.ShortCode, err)
+		case err == nil && current.OriginalURL == save.OriginalURL:
+			log.Printf("URL persisted to storage after %d retries: %s", current.Attempts+1, save.ShortCode)
+			delete(p.pending, save.ShortCode)
+		case err == nil:
+			// Updated while the write was in flight; write the new destination next
+			current.NextAttempt = time.Time{}
+		default:
+			p.schedule(current)
+			if current.Attempts >= p.maxAttempts {
+				log.Printf("Error: giving up persisting URL %s after %d attempts: %v", save.ShortCode, current.Attempts, err)
+				delete(p.pending, save.ShortCode)
+			} else {
+				log.Printf("Warning: retry %d/%d persisting URL %s failed: %v", current.Attempts, p.maxAttempts, save.ShortCode, err)
+			}
+		}
+		p.syncWAL()
+		p.mu.Unlock()
+	}
+
+	if n := p.Pending(); n > 0 {
+		log.Printf("URLs pending persistence: %d", n)
+	}
+}
+
+// lose gives up on a save whose code another URL took in storage first.
+func (p *urlPersister) lose(shortCode string, err error) {
+	log.Printf("Error: short code %s was taken before its URL was persisted: %v", shortCode, err)
+	if p.lost != nil {
+		p.lost(shortCode)
+	}
+}
+
+func (p *urlPersister) write(ctx context.Context, save *pendingSave) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	// The code was free when it was handed out, so it replaces any deleted
+	// URL that used to hold it, but not a live one saved in the meantime
+	tenant, _ := splitTenantKey(save.ShortCode)
+	req := &storage_service.SaveURLRequest{
+		ShortCode:      save.ShortCode,
+		OriginalUrl:    save.OriginalURL,
+		ExpiresAt:      save.ExpiresAt,
+		ApiKeyId:       save.APIKeyID,
+		UserId:         save.UserID,
+		Resurrect:      true,
+		NotBefore:      save.NotBefore,
+		ComingSoonUrl:  save.ComingSoonURL,
+		FallbackUrl:    save.FallbackURL,
+		Variants:       save.Variants.storage(),
+		StickyVariants: save.Variants != nil && save.Variants.Sticky,
+		Rules:          save.Rules.storage(),
+		QueryTemplate:  save.QueryTemplate,
+		Tags:           save.Tags,
+		SetTags:        true,
+		TenantId:       tenant,
+		CreateOnly:     true,
+	}
+	_, err := p.storageClient.SaveURL(ctx, req)
+	if status.Code(err) == codes.AlreadyExists && savedAlready(ctx, p.storageClient, req) {
+		return nil
+	}
+	return err
+}
+
+// savedAlready reports whether the live URL holding the code of a create
+// storage turned down is the one being created: an earlier attempt whose
+// response was lost wrote it, or an update already did.
+func savedAlready(ctx context.Context, storageClient storage_service.StorageServiceClient, req *storage_service.SaveURLRequest) bool {
+	resp, err := storageClient.GetURL(ctx, &storage_service.GetURLRequest{ShortCode: req.ShortCode, IncludeExpired: true, ForcePrimary: true})
+	if err != nil || !resp.Found {
+		return false
+	}
+	return resp.OriginalUrl == req.OriginalUrl && resp.UserId == req.UserId
+}
+
+// schedule records a failed attempt and sets the next retry time.
+// Caller must hold p.mu.
+func (p *urlPersister) schedule(save *pendingSave) {
+	save.Attempts++
+	delay := p.baseDelay << (save.Attempts - 1)
+	if delay <= 0 || delay > maxPersistDelay {
+		delay = maxPersistDelay
+	}
+	save.NextAttempt = time.Now().Add(delay)
+}
+
+// syncWAL rewrites the WAL file with the current pending set.
+// Caller must hold p.mu.
+func (p *urlPersister) syncWAL() {
+	if p.walPath == "" {
+		return
+	}
+
+	codes := make([]string, 0, len(p.pending))
+	for code := range p.pending {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	tmpPath := p.walPath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		log.Printf("Warning: failed to write WAL: %v", err)
+		return
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, code := range codes {
+		if err = enc.Encode(p.pending[code]); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, p.walPath)
+	}
+	if err != nil {
+		log.Printf("Warning: failed to write WAL: %v", err)
+	}
+}
+
+// replay loads pending saves left over from a previous run.
+func (p *urlPersister) replay() error {
+	f, err := os.Open(p.walPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open WAL: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var save pendingSave
+		if err := json.Unmarshal(scanner.Bytes(), &save); err != nil {
+			log.Printf("Warning: skipping corrupt WAL entry: %v", err)
+			continue
+		}
+		save.NextAttempt = time.Time{}
+		p.pending[save.ShortCode] = &save
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read WAL: %v", err)
+	}
+
+	if len(p.pending) > 0 {
+		log.Printf("Replaying %d unpersisted URLs from %s", len(p.pending), p.walPath)
+	}
+	return nil
+}
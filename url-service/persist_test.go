@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestPersister returns a persister writing to storage, keeping its WAL
+// in walDir if set.
+func newTestPersister(t *testing.T, storage *fakeStorage, maxAttempts int, walDir string) *urlPersister {
+	t.Helper()
+	conn := dialBufconn(t, func(srv *grpc.Server) { storage_service.RegisterStorageServiceServer(srv, storage) })
+	p, err := newURLPersister(storage_service.NewStorageServiceClient(conn), time.Second, maxAttempts, time.Millisecond, walDir)
+	if err != nil {
+		t.Fatalf("newURLPersister: %v", err)
+	}
+	return p
+}
+
+// walCodes returns the short codes in the WAL in dir.
+func walCodes(t *testing.T, dir string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, persistWALFile))
+	if err != nil {
+		t.Fatalf("read WAL: %v", err)
+	}
+	var codes []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if _, code, ok := strings.Cut(line, `"short_code":"`); ok {
+			codes = append(codes, code[:strings.IndexByte(code, '"')])
+		}
+	}
+	return codes
+}
+
+// isPending reports whether p holds shortCode for retry.
+func isPending(p *urlPersister, shortCode string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.pending[shortCode]
+	return ok
+}
+
+// retryUntil retries p's due saves until it has no more than pending left.
+func retryUntil(t *testing.T, p *urlPersister, pending int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Pending() > pending {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d URLs still pending after 5s, want %d", p.Pending(), pending)
+		}
+		time.Sleep(5 * time.Millisecond)
+		p.retry(context.Background(), false)
+	}
+}
+
+func TestPersisterRetriesUntilSaved(t *testing.T) {
+	storage := newFakeStorage()
+	unavailable := status.Error(codes.Unavailable, "storage down")
+	storage.saveErrs = []error{unavailable, unavailable, unavailable}
+	dir := t.TempDir()
+	p := newTestPersister(t, storage, 10, dir)
+
+	p.Save(context.Background(), &pendingSave{ShortCode: "abc123", OriginalURL: "https://example.com"})
+	if !isPending(p, "abc123") || p.Pending() != 1 {
+		t.Fatalf("after a failed save: pending %v, %d URLs, want abc123 alone", isPending(p, "abc123"), p.Pending())
+	}
+	if codes := walCodes(t, dir); len(codes) != 1 || codes[0] != "abc123" {
+		t.Errorf("WAL holds %v, want [abc123]", codes)
+	}
+
+	// Two more failures, then the retry goes through
+	retryUntil(t, p, 0)
+	if u, ok := storage.url("abc123"); !ok || u.OriginalUrl != "https://example.com" {
+		t.Errorf("storage holds %v, want https://example.com", u)
+	}
+	if codes := walCodes(t, dir); len(codes) != 0 {
+		t.Errorf("WAL holds %v after the save, want nothing", codes)
+	}
+}
+
+func TestPersisterGivesUp(t *testing.T) {
+	storage := newFakeStorage()
+	for i := 0; i < 5; i++ {
+		storage.saveErrs = append(storage.saveErrs, status.Error(codes.Unavailable, "storage down"))
+	}
+	p := newTestPersister(t, storage, 3, "")
+
+	p.Save(context.Background(), &pendingSave{ShortCode: "abc123", OriginalURL: "https://example.com"})
+	retryUntil(t, p, 0)
+
+	// The first save and two retries failed, leaving two errors unused
+	if _, ok := storage.url("abc123"); ok {
+		t.Error("abc123 was saved after the persister gave up")
+	}
+	if len(storage.saveErrs) != 2 {
+		t.Errorf("%d saves attempted, want 3", 5-len(storage.saveErrs))
+	}
+}
+
+func TestPersisterReplaysWAL(t *testing.T) {
+	dir := t.TempDir()
+	down := newFakeStorage()
+	for i := 0; i < 4; i++ {
+		down.saveErrs = append(down.saveErrs, status.Error(codes.Unavailable, "storage down"))
+	}
+	before := newTestPersister(t, down, 10, dir)
+	before.Save(context.Background(), &pendingSave{ShortCode: "abc123", OriginalURL: "https://example.com/a", UserID: "alice"})
+	before.Save(context.Background(), &pendingSave{ShortCode: "def456", OriginalURL: "https://example.com/d", ExpiresAt: "2030-01-02T03:04:05Z"})
+
+	// A restart reads the pending saves back and writes them on the next retry
+	storage := newFakeStorage()
+	after := newTestPersister(t, storage, 10, dir)
+	if after.Pending() != 2 || !isPending(after, "abc123") || !isPending(after, "def456") {
+		t.Fatalf("replayed %d URLs, want abc123 and def456", after.Pending())
+	}
+	after.retry(context.Background(), false)
+
+	if u, ok := storage.url("abc123"); !ok || u.OriginalUrl != "https://example.com/a" || u.UserId != "alice" {
+		t.Errorf("storage holds %v for abc123, want https://example.com/a owned by alice", u)
+	}
+	if u, ok := storage.url("def456"); !ok || u.ExpiresAt != "2030-01-02T03:04:05Z" {
+		t.Errorf("storage holds %v for def456, want it expiring", u)
+	}
+	if after.Pending() != 0 || len(walCodes(t, dir)) != 0 {
+		t.Errorf("%d URLs still pending and WAL holds %v, want nothing", after.Pending(), walCodes(t, dir))
+	}
+}
+
+func TestPersisterSkipsCorruptWALEntries(t *testing.T) {
+	dir := t.TempDir()
+	wal := `{"short_code":"abc123","original_url":"https://example.com","attempts":2}` + "\nnot json\n"
+	if err := os.WriteFile(filepath.Join(dir, persistWALFile), []byte(wal), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := newTestPersister(t, newFakeStorage(), 10, dir)
+	if p.Pending() != 1 || !isPending(p, "abc123") {
+		t.Errorf("replayed %d URLs, want abc123 alone", p.Pending())
+	}
+}
+
+func TestPersisterBackoff(t *testing.T) {
+	p := &urlPersister{baseDelay: time.Second}
+	save := &pendingSave{}
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		p.schedule(save)
+		if delay := time.Until(save.NextAttempt); delay > want || delay < want-time.Second/2 {
+			t.Errorf("attempt %d retries in %v, want %v", save.Attempts, delay, want)
+		}
+	}
+
+	// Delays stop growing at the cap, even once the shift overflows
+	for i := 0; i < 70; i++ {
+		p.schedule(save)
+		if delay := time.Until(save.NextAttempt); delay > maxPersistDelay {
+			t.Fatalf("attempt %d retries in %v, over the %v cap", save.Attempts, delay, maxPersistDelay)
+		}
+	}
+}
+
+func TestShortenURLQueuesFailedSave(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	storage.mu.Lock()
+	storage.saveErrs = []error{status.Error(codes.Internal, "disk full")}
+	storage.mu.Unlock()
+
+	ctx := context.Background()
+	resp, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "queued"})
+	if err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	s.tasks.Close(context.Background())
+
+	// The code still resolves while it waits for the retry
+	if n := s.persister.Pending(); n != 1 || !isPending(s.persister, resp.ShortCode) {
+		t.Fatalf("%d URLs pending, want %s", n, resp.ShortCode)
+	}
+	got, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: resp.ShortCode})
+	if err != nil || got.OriginalUrl != "https://example.com" {
+		t.Errorf("GetOriginalURL = %v, %v, want https://example.com", got, err)
+	}
+
+	retryUntil(t, s.persister, 0)
+	if _, ok := storage.url(resp.ShortCode); !ok {
+		t.Errorf("after the retry: stored %v, %d pending, want stored and none pending", ok, s.persister.Pending())
+	}
+}
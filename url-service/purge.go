@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	cache_service "github.com/syedalijabir/protos/cache-service"
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PurgeURL takes a link down for good: its row is removed from storage,
+// skipping the soft delete, then its cache entries and this replica's
+// in-memory copy. Every layer is tried and checked whatever happened to the
+// others, and reported on its own, so an operator can tell which failed and
+// retry. The layers can't be changed atomically; going from storage outwards
+// means a lookup racing the purge can at worst find a copy about to go.
+func (s *urlServer) PurgeURL(ctx context.Context, req *url_service.PurgeURLRequest) (*url_service.PurgeURLResponse, error) {
+	logf(ctx, "PurgeURL request for: %s", req.ShortCode)
+
+	if req.ShortCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "short code is required")
+	}
+	if err := s.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	// The purge carries on if the caller gives up partway
+	ctx = detach(ctx)
+	resp := &url_service.PurgeURLResponse{
+		ShortCode: req.ShortCode,
+		Storage:   purgeResult(s.purgeStorage(ctx, req.ShortCode)),
+		Cache:     purgeResult(s.purgeCache(ctx, req.ShortCode)),
+		Memory:    purgeResult(s.purgeMemory(req.ShortCode)),
+	}
+	resp.Complete = resp.Storage.Ok && resp.Cache.Ok && resp.Memory.Ok
+
+	logf(ctx, "URL %s purged, storage: %t, cache: %t, memory: %t", req.ShortCode, resp.Storage.Ok, resp.Cache.Ok, resp.Memory.Ok)
+	return resp, nil
+}
+
+func (s *urlServer) purgeStorage(ctx context.Context, shortCode string) error {
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	resp, err := s.storageClient.PurgeURL(storageCtx, &storage_service.PurgeURLRequest{ShortCode: shortCode})
+	if err != nil {
+		logf(ctx, "Failed to purge URL from storage: %v", err)
+		return err
+	}
+	if resp.Purged {
+		s.publishDeleted(shortCode)
+	}
+	return nil
+}
+
+// purgeCache deletes the code's cache entries and checks the one lookups
+// read is gone.
+func (s *urlServer) purgeCache(ctx context.Context, shortCode string) error {
+	if err := s.invalidateCache(ctx, shortCode); err != nil {
+		return err
+	}
+
+	cacheCtx, cancel := s.cacheCtx(ctx)
+	defer cancel()
+	resp, err := s.cacheClient.Exists(cacheCtx, &cache_service.ExistsRequest{Namespace: urlNamespace, Key: shortCode})
+	if err != nil {
+		return fmt.Errorf("failed to verify cache delete: %w", err)
+	}
+	if resp.Exists {
+		return fmt.Errorf("cache key %s:%s still exists after delete", urlNamespace, shortCode)
+	}
+	return nil
+}
+
+// purgeMemory drops the in-memory copy and keeps the code from being served
+// by this replica again while stale cache entries could remain elsewhere.
+func (s *urlServer) purgeMemory(shortCode string) error {
+	s.urls.Remove(shortCode)
+	s.persister.Forget(shortCode)
+	s.markDeleted(shortCode)
+	if s.urls.Contains(shortCode) {
+		return fmt.Errorf("%s is still held in memory", shortCode)
+	}
+	return nil
+}
+
+func purgeResult(err error) *url_service.PurgeLayerResult {
+	if err != nil {
+		return &url_service.PurgeLayerResult{Error: err.Error()}
+	}
+	return &url_service.PurgeLayerResult{Ok: true}
+}
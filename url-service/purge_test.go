@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (f *fakeStorage) PurgeURL(ctx context.Context, req *storage_service.PurgeURLRequest) (*storage_service.PurgeURLResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, live := f.urls[req.ShortCode]
+	_, deleted := f.deleted[req.ShortCode]
+	delete(f.urls, req.ShortCode)
+	delete(f.deleted, req.ShortCode)
+	ok := live || deleted
+	return &storage_service.PurgeURLResponse{Purged: ok}, nil
+}
+
+func TestPurgeURLPartial(t *testing.T) {
+	// The breaker would open on the failing deletes and fail the retry too
+	s, storage, cache := newTestServer(t, map[string]string{"ADMIN_USERS": "root", "CACHE_TTL": "24h", "BREAKER_FAILURE_THRESHOLD": "100"})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "takedown", OriginalUrl: "https://example.com"})
+	cache.set("url:takedown", "https://example.com")
+	ctx := withKey(context.Background(), "root-key", "root")
+	lookup := &url_service.GetOriginalRequest{ShortCode: "takedown"}
+	if got, err := s.GetOriginalURL(ctx, lookup); err != nil || got.OriginalUrl != "https://example.com" {
+		t.Fatalf("GetOriginalURL = %v, %v", got, err)
+	}
+
+	cache.mu.Lock()
+	cache.deleteErr = errors.New("cache unreachable")
+	cache.mu.Unlock()
+	resp, err := s.PurgeURL(ctx, &url_service.PurgeURLRequest{ShortCode: "takedown"})
+	if err != nil {
+		t.Fatalf("PurgeURL: %v", err)
+	}
+	if resp.Complete || !resp.Storage.Ok || resp.Cache.Ok || resp.Cache.Error == "" || !resp.Memory.Ok {
+		t.Errorf("PurgeURL = %v, want storage and memory purged, cache failed", resp)
+	}
+	if _, ok := storage.url("takedown"); ok {
+		t.Error("storage still holds the purged URL")
+	}
+	// The entry left in the cache isn't served by this replica
+	if _, ok := cache.entry("url:takedown"); !ok {
+		t.Fatal("cache entry gone despite the failing delete")
+	}
+	if got, err := s.GetOriginalURL(ctx, lookup); status.Code(err) != codes.NotFound {
+		t.Errorf("GetOriginalURL after a partial purge = %v, %v, want NotFound", got, err)
+	}
+
+	// A retry finishes the job
+	cache.mu.Lock()
+	cache.deleteErr = nil
+	cache.mu.Unlock()
+	resp, err = s.PurgeURL(ctx, &url_service.PurgeURLRequest{ShortCode: "takedown"})
+	if err != nil || !resp.Complete || !resp.Storage.Ok || !resp.Cache.Ok || !resp.Memory.Ok {
+		t.Errorf("retried PurgeURL = %v, %v, want complete", resp, err)
+	}
+	if _, ok := cache.entry("url:takedown"); ok {
+		t.Error("cache still holds the purged URL after the retry")
+	}
+}
+
+func TestPurgeURLAdminOnly(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"ADMIN_USERS": "root"})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "mine", OriginalUrl: "https://example.com", UserId: "alice"})
+	ctx := context.Background()
+
+	// Not even the owner may purge
+	if _, err := s.PurgeURL(withKey(ctx, "alice-key", "alice"), &url_service.PurgeURLRequest{ShortCode: "mine"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("PurgeURL by the owner: got %v, want PermissionDenied", err)
+	}
+	if _, ok := storage.url("mine"); !ok {
+		t.Error("a refused purge removed the URL")
+	}
+	if _, err := s.PurgeURL(withKey(ctx, "root-key", "root"), &url_service.PurgeURLRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("PurgeURL without a code: got %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultShortenRateLimit = 5
+	defaultShortenRateBurst = 20
+	defaultLookupRateBurst  = defaultMaxBatchSize
+	defaultBatchRateLimit   = 50
+	defaultBatchRateBurst   = defaultMaxBatchSize
+	defaultRateLimitIdleTTL = 10 * time.Minute
+
+	// retryAfterHeader tells a throttled caller how many seconds to wait.
+	retryAfterHeader = "retry-after"
+)
+
+// rateLimiter keeps a token bucket per caller. Buckets unused for idleTTL
+// are evicted; by then they have refilled, so dropping them changes nothing
+// but memory. A zero limit lets everything through.
+type rateLimiter struct {
+	idleTTL time.Duration
+
+	mu        sync.Mutex
+	limit     rate.Limit
+	burst     int
+	buckets   map[string]*callerBucket
+	lastSweep time.Time
+}
+
+type callerBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newRateLimiter(perSecond float64, burst int, idleTTL time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:     rate.Limit(perSecond),
+		burst:     burst,
+		idleTTL:   idleTTL,
+		buckets:   make(map[string]*callerBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes n tokens from key's bucket. When they aren't available it
+// returns false and how long until they will be.
+func (l *rateLimiter) Allow(key string, n int) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	if l.limit == 0 {
+		l.mu.Unlock()
+		return true, 0
+	}
+	if now.Sub(l.lastSweep) >= l.idleTTL {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &callerBucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+	l.mu.Unlock()
+
+	r := b.limiter.ReserveN(now, n)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// Limits returns the rate and burst of the buckets.
+func (l *rateLimiter) Limits() (rate.Limit, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit, l.burst
+}
+
+// SetLimits changes the rate and burst of every bucket, keeping the tokens
+// callers have left.
+func (l *rateLimiter) SetLimits(perSecond float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit, l.burst = rate.Limit(perSecond), burst
+	for _, b := range l.buckets {
+		b.limiter.SetLimit(l.limit)
+		b.limiter.SetBurst(l.burst)
+	}
+}
+
+// sweep evicts idle buckets. Caller must hold l.mu.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= l.idleTTL {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// rateLimitInterceptor throttles the methods in limits, keyed by API key ID
+// or, for unauthenticated calls, the caller's IP. Methods without a limiter,
+// or whose limiter has a zero limit, are exempt. It must run after
+// authInterceptor.
+func rateLimitInterceptor(limits map[string]*rateLimiter, trustForwardedFor bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		limiter, ok := limits[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		limit, burst := limiter.Limits()
+		if limit == 0 {
+			return handler(ctx, req)
+		}
+		cost := requestCost(req)
+		if cost > burst {
+			return nil, status.Errorf(codes.InvalidArgument, "batch of %d exceeds the rate limit burst of %d", cost, burst)
+		}
+
+		key := callerKey(ctx, trustForwardedFor)
+		if allowed, retryAfter := limiter.Allow(key, cost); !allowed {
+			seconds := int64(math.Ceil(retryAfter.Seconds()))
+			if err := grpc.SetTrailer(ctx, metadata.Pairs(retryAfterHeader, strconv.FormatInt(seconds, 10))); err != nil {
+				logf(ctx, "Warning: failed to set retry-after trailer: %v", err)
+			}
+			logf(ctx, "Rate limited %s for %s", info.FullMethod, key)
+			return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry in %ds", seconds)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// requestCost is the number of tokens req takes: one per link for batches.
+func requestCost(req interface{}) int {
+	switch r := req.(type) {
+	case *url_service.BatchShortenRequest:
+		return max(len(r.Items), 1)
+	case *url_service.BatchGetOriginalRequest:
+		return max(len(r.ShortCodes), 1)
+	}
+	return 1
+}
+
+// callerKey identifies who a request is charged to.
+func callerKey(ctx context.Context, trustForwardedFor bool) string {
+	if id := apiKeyID(ctx); id != "" {
+		return "key:" + id
+	}
+	if ip := callerIP(ctx, trustForwardedFor); ip != "" {
+		return "ip:" + ip
+	}
+	return "ip:unknown"
+}
+
+// callerIP returns the address a request came from, or "" if unknown.
+// X-Forwarded-For is only honoured when the service sits behind a trusted
+// proxy such as the gateway; otherwise callers could claim any address.
+func callerIP(ctx context.Context, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if values := md.Get("x-forwarded-for"); len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// rateLimits are the limiters of the throttled methods. A zero rate exempts
+// the methods of a limiter; it is kept so a reload can limit them again.
+type rateLimits struct {
+	shorten *rateLimiter
+	batch   *rateLimiter
+	lookup  *rateLimiter
+}
+
+func newRateLimits(cfg Config) *rateLimits {
+	return &rateLimits{
+		shorten: newRateLimiter(cfg.ShortenRateLimit, cfg.ShortenRateBurst, cfg.RateLimitIdleTTL),
+		batch:   newRateLimiter(cfg.BatchRateLimit, cfg.BatchRateBurst, cfg.RateLimitIdleTTL),
+		lookup:  newRateLimiter(cfg.LookupRateLimit, cfg.LookupRateBurst, cfg.RateLimitIdleTTL),
+	}
+}
+
+// byMethod maps the throttled methods to their limiters.
+func (l *rateLimits) byMethod() map[string]*rateLimiter {
+	// Batches of links get a budget of their own, whose burst fits a whole
+	// batch. Abuse reports, which anyone can send, share single creation's.
+	return map[string]*rateLimiter{
+		url_service.URLService_ShortenURL_FullMethodName:       l.shorten,
+		url_service.URLService_BatchShorten_FullMethodName:     l.batch,
+		url_service.URLService_ReportURL_FullMethodName:        l.shorten,
+		url_service.URLService_GetOriginalURL_FullMethodName:   l.lookup,
+		url_service.URLService_BatchGetOriginal_FullMethodName: l.lookup,
+	}
+}
+
+// Set applies the limits of cfg.
+func (l *rateLimits) Set(cfg Config) {
+	l.shorten.SetLimits(cfg.ShortenRateLimit, cfg.ShortenRateBurst)
+	l.batch.SetLimits(cfg.BatchRateLimit, cfg.BatchRateBurst)
+	l.lookup.SetLimits(cfg.LookupRateLimit, cfg.LookupRateBurst)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimiterConcurrent(t *testing.T) {
+	// Slow enough that no token comes back during the test
+	const burst = 20
+	l := newRateLimiter(0.001, burst, time.Minute)
+
+	var allowed, denied atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, retryAfter := l.Allow("ip:203.0.113.7", 1); ok {
+				allowed.Add(1)
+			} else if retryAfter > 0 {
+				denied.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed.Load() != burst || denied.Load() != 200-burst {
+		t.Errorf("allowed %d and denied %d of 200, want %d and %d", allowed.Load(), denied.Load(), burst, 200-burst)
+	}
+	// Another caller has a bucket of their own
+	if ok, _ := l.Allow("ip:198.51.100.1", 1); !ok {
+		t.Error("another caller was throttled")
+	}
+}
+
+func TestRateLimitInterceptorForwardedFor(t *testing.T) {
+	const burst = 5
+	limits := map[string]*rateLimiter{
+		url_service.URLService_ShortenURL_FullMethodName: newRateLimiter(0.001, burst, time.Minute),
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: url_service.URLService_ShortenURL_FullMethodName}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	caller := &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 4321}}
+
+	for _, trust := range []bool{false, true} {
+		limits[info.FullMethod] = newRateLimiter(0.001, burst, time.Minute)
+		interceptor := rateLimitInterceptor(limits, trust)
+
+		// One caller claiming a new address every time, concurrently
+		var allowed atomic.Int64
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ctx := peer.NewContext(context.Background(), caller)
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-forwarded-for", net.IPv4(198, 51, 100, byte(i)).String()))
+				_, err := interceptor(ctx, &url_service.ShortenRequest{}, info, handler)
+				if err == nil {
+					allowed.Add(1)
+				} else if status.Code(err) != codes.ResourceExhausted {
+					t.Errorf("got %v, want ResourceExhausted", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		// Only a trusted proxy's forwarded addresses are callers of their own
+		want := int64(burst)
+		if trust {
+			want = 50
+		}
+		if allowed.Load() != want {
+			t.Errorf("trusting X-Forwarded-For %v: allowed %d of 50, want %d", trust, allowed.Load(), want)
+		}
+	}
+}
+
+func TestRateLimitInterceptorFullBatch(t *testing.T) {
+	cfg, err := loadConfig(nil, testEnv(map[string]string{
+		"STORAGE_SERVICE_ADDR": "localhost:50052",
+		"CACHE_SERVICE_ADDR":   "localhost:50053",
+		"INSECURE_DEV_MODE":    "true",
+	}))
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	interceptor := rateLimitInterceptor(newRateLimits(cfg).byMethod(), false)
+	info := &grpc.UnaryServerInfo{FullMethod: url_service.URLService_BatchShorten_FullMethodName}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 4321}})
+
+	// With the defaults, a batch of the largest size is let through once
+	batch := &url_service.BatchShortenRequest{Items: make([]*url_service.ShortenRequest, cfg.MaxBatchSize)}
+	if _, err := interceptor(ctx, batch, info, handler); err != nil {
+		t.Fatalf("full batch: %v", err)
+	}
+	if _, err := interceptor(ctx, batch, info, handler); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second full batch: got %v, want ResourceExhausted", err)
+	}
+
+	// and doesn't use up single creation's budget
+	info.FullMethod = url_service.URLService_ShortenURL_FullMethodName
+	if _, err := interceptor(ctx, &url_service.ShortenRequest{}, info, handler); err != nil {
+		t.Errorf("ShortenURL after a batch: %v", err)
+	}
+}
+
+func TestBatchRateBurstValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		ok   bool
+	}{
+		{"defaults", nil, true},
+		{"batch burst below batch size", map[string]string{"BATCH_RATE_BURST": "100"}, false},
+		{"smaller batches", map[string]string{"BATCH_RATE_BURST": "100", "MAX_BATCH_SIZE": "100"}, true},
+		{"unlimited batches", map[string]string{"BATCH_RATE_LIMIT": "0", "BATCH_RATE_BURST": "1"}, true},
+		{"lookup limit with default burst", map[string]string{"LOOKUP_RATE_LIMIT": "100"}, true},
+		{"lookup burst below batch size", map[string]string{"LOOKUP_RATE_LIMIT": "100", "LOOKUP_RATE_BURST": "200"}, false},
+		{"unlimited lookups", map[string]string{"LOOKUP_RATE_BURST": "200"}, true},
+	}
+	for _, tt := range tests {
+		env := map[string]string{
+			"STORAGE_SERVICE_ADDR": "localhost:50052",
+			"CACHE_SERVICE_ADDR":   "localhost:50053",
+			"INSECURE_DEV_MODE":    "true",
+		}
+		for k, v := range tt.env {
+			env[k] = v
+		}
+		_, err := loadConfig(nil, testEnv(env))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: loadConfig returned %v", tt.name, err)
+		}
+	}
+}
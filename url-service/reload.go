@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Log levels. warn keeps only the request logs that are warnings or
+// failures.
+const (
+	logLevelInfo = "info"
+	logLevelWarn = "warn"
+)
+
+// Settings can also come from CONFIG_FILE, a JSON object of the same names
+// as the environment variables, which take precedence over the environment:
+//
+//	{"CACHE_TTL": "5m", "SHORTEN_RATE_LIMIT": 10, "DOMAIN_POLICY": "blocklist"}
+//
+// On SIGHUP, or when the file changes with CONFIG_WATCH_INTERVAL set, the
+// config is loaded again and the fields in reloadableFields are applied
+// while running, without losing what memory holds. Changes to the others
+// are logged as needing a restart. A config that doesn't load or validate
+// is rejected as a whole and the running one kept.
+
+// reloadableFields are the Config fields a reload applies.
+var reloadableFields = map[string]bool{
+	"CacheTTL":            true,
+	"NegativeCacheTTL":    true,
+	"ShortenRateLimit":    true,
+	"ShortenRateBurst":    true,
+	"BatchRateLimit":      true,
+	"BatchRateBurst":      true,
+	"LookupRateLimit":     true,
+	"LookupRateBurst":     true,
+	"ReservedAliases":     true,
+	"ReservedAliasesFile": true,
+	"DomainPolicy":        true,
+	"DomainRulesRefresh":  true,
+	"LogLevel":            true,
+}
+
+// liveConfig is the part of the config handlers read on every call that a
+// reload can change. It is replaced as a whole, so a call sees either the
+// old values or the new ones.
+type liveConfig struct {
+	cacheTTLSeconds    int32
+	negativeTTL        time.Duration
+	domainRulesRefresh time.Duration
+}
+
+func newLiveConfig(cfg Config) *liveConfig {
+	return &liveConfig{
+		cacheTTLSeconds:    int32(cfg.CacheTTL / time.Second),
+		negativeTTL:        cfg.NegativeCacheTTL,
+		domainRulesRefresh: cfg.DomainRulesRefresh,
+	}
+}
+
+// live returns the current reloadable settings.
+func (s *urlServer) live() *liveConfig {
+	return s.settings.Load()
+}
+
+// readConfigFile reads the settings of a config file as strings, like the
+// environment's.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string:
+			values[key] = v
+		case float64:
+			values[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			values[key] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("invalid config file %s: %s must be a string, number or boolean", path, key)
+		}
+	}
+	return values, nil
+}
+
+// quietLogs drops the request logs below warnings, with LOG_LEVEL=warn.
+var quietLogs atomic.Bool
+
+func setLogLevel(level string) {
+	quietLogs.Store(level == logLevelWarn)
+}
+
+// isWarning reports whether a log line is a warning or a failure, by the
+// way they are worded throughout.
+func isWarning(format string) bool {
+	return strings.HasPrefix(format, "Warning") || strings.HasPrefix(format, "Failed")
+}
+
+// reloadConfig loads the config with load and applies its reloadable fields
+// over current, returning the config now in effect.
+func (s *urlServer) reloadConfig(ctx context.Context, current Config, load func() (Config, error)) (Config, error) {
+	cfg, err := load()
+	if err != nil {
+		return current, err
+	}
+	reserved, err := loadReservedAliases(cfg.ReservedAliases, cfg.ReservedAliasesFile)
+	if err != nil {
+		return current, err
+	}
+
+	// Only the reloadable fields move, so changes waiting for a restart are
+	// reported again by the next reload
+	next := current
+	var applied, pending []string
+	have, want, out := reflect.ValueOf(current), reflect.ValueOf(cfg), reflect.ValueOf(&next).Elem()
+	for i := 0; i < have.NumField(); i++ {
+		name := have.Type().Field(i).Name
+		if reflect.DeepEqual(have.Field(i).Interface(), want.Field(i).Interface()) {
+			continue
+		}
+		if !reloadableFields[name] {
+			pending = append(pending, name)
+			continue
+		}
+		out.Field(i).Set(want.Field(i))
+		applied = append(applied, name)
+	}
+
+	s.settings.Store(newLiveConfig(next))
+	s.rateLimits.Set(next)
+	s.aliases.Set(reserved)
+	s.domains.SetPolicy(next.DomainPolicy)
+	setLogLevel(next.LogLevel)
+	// The blocked domains are read again too, the reason for many reloads
+	if err := s.refreshDomainRules(ctx); err != nil {
+		log.Printf("Warning: failed to refresh blocked domains on reload: %v", err)
+	}
+
+	if len(applied) == 0 {
+		log.Printf("Config reloaded, nothing changed")
+	} else {
+		log.Printf("Config reloaded, applied %s", strings.Join(applied, ", "))
+	}
+	if len(pending) > 0 {
+		log.Printf("Warning: config changes to %s need a restart", strings.Join(pending, ", "))
+	}
+	return next, nil
+}
+
+// runConfigReloads reloads the config each time reload fires, and when the
+// config file changes if cfg asks for it to be watched, until ctx is done.
+func (s *urlServer) runConfigReloads(ctx context.Context, cfg Config, reload <-chan os.Signal, load func() (Config, error)) {
+	var watch <-chan time.Time
+	var modTime time.Time
+	if cfg.ConfigFile != "" && cfg.ConfigWatchInterval > 0 {
+		ticker := time.NewTicker(cfg.ConfigWatchInterval)
+		defer ticker.Stop()
+		watch = ticker.C
+		modTime = configModTime(cfg.ConfigFile)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reload:
+			log.Printf("Reloading config on SIGHUP")
+		case <-watch:
+			// A file being replaced can be missing for a moment
+			t := configModTime(cfg.ConfigFile)
+			if t.IsZero() || t.Equal(modTime) {
+				continue
+			}
+			modTime = t
+			log.Printf("Reloading config, %s changed", cfg.ConfigFile)
+		}
+
+		next, err := s.reloadConfig(ctx, cfg, load)
+		if err != nil {
+			log.Printf("Warning: config not reloaded, keeping the running one: %v", err)
+			continue
+		}
+		cfg = next
+	}
+}
+
+func configModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// writeConfigFile replaces the config file at path with settings.
+func writeConfigFile(t *testing.T, path, settings string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(settings), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfigFile(t, path, `{"CACHE_TTL": "5m", "SHORTEN_RATE_LIMIT": 2.5, "DEDUPLICATE_URLS": true}`)
+	// The file wins over the environment, which fills in the rest
+	cfg, err := loadConfig(nil, testEnv(map[string]string{"CONFIG_FILE": path, "CACHE_TTL": "1h", "SHORT_CODE_LENGTH": "9"}))
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if cfg.CacheTTL != 5*time.Minute || cfg.ShortenRateLimit != 2.5 || !cfg.DedupURLs || cfg.ShortCodeLength != 9 {
+		t.Errorf("loaded TTL %v, rate %v, dedup %v, code length %d, want 5m, 2.5, true and 9",
+			cfg.CacheTTL, cfg.ShortenRateLimit, cfg.DedupURLs, cfg.ShortCodeLength)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		settings string
+	}{
+		{"not JSON", `CACHE_TTL=5m`},
+		{"nested value", `{"CACHE_TTL": {"seconds": 300}}`},
+		{"invalid value", `{"CACHE_TTL": "soon"}`},
+	} {
+		writeConfigFile(t, path, tt.settings)
+		if _, err := loadConfig(nil, testEnv(map[string]string{"CONFIG_FILE": path})); err == nil {
+			t.Errorf("%s: loadConfig succeeded", tt.name)
+		}
+	}
+	if _, err := loadConfig(nil, testEnv(map[string]string{"CONFIG_FILE": filepath.Join(t.TempDir(), "missing.json")})); err == nil {
+		t.Error("loadConfig of a missing config file succeeded")
+	}
+}
+
+func TestConfigReloadOnSIGHUP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfigFile(t, path, `{"CACHE_TTL": "1h", "SHORTEN_RATE_LIMIT": 0, "LOG_LEVEL": "info"}`)
+	env := map[string]string{"CONFIG_FILE": path, "URL_SYNC_PERSIST": "true"}
+	s, _, cache := newTestServer(t, env)
+	t.Cleanup(func() { setLogLevel(logLevelInfo) })
+	conn := dialBufconn(t, func(srv *grpc.Server) { url_service.RegisterURLServiceServer(srv, s) },
+		grpc.UnaryInterceptor(rateLimitInterceptor(s.rateLimits.byMethod(), false)))
+	client := url_service.NewURLServiceClient(conn)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The server was built with fake service addresses, but only what
+	// changes between loads matters to a reload
+	load := func() (Config, error) { return loadConfig(nil, testEnv(env)) }
+	current, err := load()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := client.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com"}); err != nil {
+			t.Fatalf("ShortenURL %d without a rate limit: %v", i, err)
+		}
+	}
+	if _, err := client.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "inflight"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+
+	// Lookups keep running through the reload, none may fail
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := client.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "inflight"}); err != nil {
+					t.Errorf("GetOriginalURL during the reload: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	reloads := make(chan os.Signal, 1)
+	go s.runConfigReloads(ctx, current, reloads, load)
+	writeConfigFile(t, path, `{"CACHE_TTL": "2m", "SHORTEN_RATE_LIMIT": 0.01, "SHORTEN_RATE_BURST": 2,
+		"RESERVED_ALIASES": "promo", "LOG_LEVEL": "warn", "GRPC_PORT": "6000"}`)
+	reloads <- syscall.SIGHUP
+	waitFor(t, "the reload", func() bool { return s.live().cacheTTLSeconds == 120 })
+	close(stop)
+	wg.Wait()
+
+	if _, err := client.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "promo"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ShortenURL of a newly reserved alias: got %v, want InvalidArgument", err)
+	}
+	if _, err := client.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com", CustomAlias: "fresh1"}); err != nil {
+		t.Fatalf("ShortenURL within the new burst: %v", err)
+	}
+	if _, err := client.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com"}); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("ShortenURL past the new burst: got %v, want ResourceExhausted", err)
+	}
+	waitForCacheEntry(t, cache, "url:fresh1", true)
+	if ttl := cache.ttl("url:fresh1"); ttl != 120 {
+		t.Errorf("link cached for %ds after the reload, want 120", ttl)
+	}
+	if !quietLogs.Load() {
+		t.Error("LOG_LEVEL=warn not applied")
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfigFile(t, path, `{"NEGATIVE_CACHE_TTL": "30s", "GRPC_PORT": "50051"}`)
+	env := map[string]string{"CONFIG_FILE": path}
+	s, _, _ := newTestServer(t, env)
+	load := func() (Config, error) { return loadConfig(nil, testEnv(env)) }
+	current, err := load()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	ctx := context.Background()
+
+	// Changes needing a restart aren't applied, the others are
+	writeConfigFile(t, path, `{"NEGATIVE_CACHE_TTL": "45s", "GRPC_PORT": "6000"}`)
+	next, err := s.reloadConfig(ctx, current, load)
+	if err != nil {
+		t.Fatalf("reloadConfig: %v", err)
+	}
+	if next.NegativeCacheTTL != 45*time.Second || next.GRPCPort != "50051" {
+		t.Errorf("reloaded negative TTL %v and port %s, want 45s and the running 50051", next.NegativeCacheTTL, next.GRPCPort)
+	}
+	if got := s.live().negativeTTL; got != 45*time.Second {
+		t.Errorf("negative TTL in effect %v, want 45s", got)
+	}
+
+	// A config that doesn't validate is rejected as a whole
+	for _, tt := range []struct {
+		name     string
+		settings string
+	}{
+		{"invalid value", `{"NEGATIVE_CACHE_TTL": "10s", "CACHE_TTL": "100ms"}`},
+		{"missing reserved aliases file", `{"NEGATIVE_CACHE_TTL": "10s", "RESERVED_ALIASES_FILE": "/nonexistent/aliases"}`},
+		{"not JSON", `{`},
+	} {
+		writeConfigFile(t, path, tt.settings)
+		kept, err := s.reloadConfig(ctx, next, load)
+		if err == nil {
+			t.Errorf("%s: reloadConfig succeeded", tt.name)
+		}
+		if kept.NegativeCacheTTL != 45*time.Second || s.live().negativeTTL != 45*time.Second {
+			t.Errorf("%s: negative TTL %v, in effect %v, want the running 45s", tt.name, kept.NegativeCacheTTL, s.live().negativeTTL)
+		}
+	}
+}
+
+func TestConfigReloadOnFileChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfigFile(t, path, `{"CACHE_TTL": "1h", "CONFIG_WATCH_INTERVAL": "1s"}`)
+	env := map[string]string{"CONFIG_FILE": path}
+	s, _, _ := newTestServer(t, env)
+	load := func() (Config, error) { return loadConfig(nil, testEnv(env)) }
+	current, err := load()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.runConfigReloads(ctx, current, nil, load)
+
+	writeConfigFile(t, path, `{"CACHE_TTL": "3m", "CONFIG_WATCH_INTERVAL": "1s"}`)
+	// The watcher may not have noted the old modification time yet, and a
+	// coarse clock may not move it, so it moves on every poll
+	later := time.Now()
+	waitFor(t, "the reload", func() bool {
+		later = later.Add(time.Second)
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+		return s.live().cacheTTLSeconds == 180
+	})
+}
+
+func TestLogLevel(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		setLogLevel(logLevelInfo)
+	})
+	tests := []struct {
+		level  string
+		format string
+		logged bool
+	}{
+		{logLevelInfo, "Shortened %s", true},
+		{logLevelWarn, "Shortened %s", false},
+		{logLevelWarn, "Warning: failed to cache %s", true},
+		{logLevelWarn, "Failed to save %s", true},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		setLogLevel(tt.level)
+		logf(context.Background(), tt.format, "abc123")
+		if logged := strings.Contains(buf.String(), "abc123"); logged != tt.logged {
+			t.Errorf("%q at %s: logged %v, want %v", tt.format, tt.level, logged, tt.logged)
+		}
+	}
+}
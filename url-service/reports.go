@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultReportDisableThreshold = 5
+
+	maxReportReasonLength  = 1000
+	maxReportContactLength = 254
+
+	// reportsActor is recorded in the history of links disabled over reports
+	reportsActor = "abuse-reports"
+)
+
+// ReportURL records an abuse report on a link from anyone who came across
+// it. Once REPORT_DISABLE_THRESHOLD distinct reporters have open reports on
+// a link it is disabled, as with SetURLStatus.
+func (s *urlServer) ReportURL(ctx context.Context, req *url_service.ReportURLRequest) (*url_service.ReportURLResponse, error) {
+	logf(ctx, "ReportURL request for: %s", req.ShortCode)
+
+	reason := strings.TrimSpace(req.Reason)
+	contact := strings.TrimSpace(req.ReporterContact)
+	switch {
+	case req.ShortCode == "":
+		return nil, status.Error(codes.InvalidArgument, "short code is required")
+	case reason == "":
+		return nil, status.Error(codes.InvalidArgument, "reason is required")
+	case len(reason) > maxReportReasonLength:
+		return nil, status.Errorf(codes.InvalidArgument, "reason exceeds maximum length of %d characters", maxReportReasonLength)
+	case len(contact) > maxReportContactLength:
+		return nil, status.Errorf(codes.InvalidArgument, "reporter contact exceeds maximum length of %d characters", maxReportContactLength)
+	}
+
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	storageCtx = withActor(storageCtx, reportsActor)
+	resp, err := s.storageClient.ReportURL(storageCtx, &storage_service.ReportURLRequest{
+		ShortCode:        req.ShortCode,
+		Reason:           reason,
+		ReporterContact:  contact,
+		Reporter:         s.reporterID(ctx, contact),
+		DisableThreshold: s.reportThreshold,
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil, status.Error(codes.NotFound, "URL not found")
+	} else if err != nil {
+		logf(ctx, "Failed to store report: %v", err)
+		return nil, status.Error(codes.Unavailable, "failed to store report")
+	}
+
+	if resp.Disabled {
+		s.urls.Remove(req.ShortCode)
+		s.invalidateCache(detach(ctx), req.ShortCode)
+		logf(ctx, "URL %s disabled after reports from %d reporters", req.ShortCode, resp.Reporters)
+	}
+	return &url_service.ReportURLResponse{
+		ReportId:  resp.ReportId,
+		Duplicate: resp.Duplicate,
+	}, nil
+}
+
+// reporterID identifies who made a report by their address, which is harder
+// to vary than a contact, falling back to the contact when the address is
+// unknown. Only a hash is stored.
+func (s *urlServer) reporterID(ctx context.Context, contact string) string {
+	id := "ip:" + callerIP(ctx, s.trustForwardedFor)
+	if id == "ip:" {
+		id = "contact:" + strings.ToLower(contact)
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+// ListReports returns a page of abuse reports, newest first, to admins.
+func (s *urlServer) ListReports(ctx context.Context, req *url_service.ListReportsRequest) (*url_service.ListReportsResponse, error) {
+	logf(ctx, "ListReports request for status %q, code %q", req.Status, req.ShortCode)
+
+	if err := s.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	pageSize := req.PageSize
+	if pageSize < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	}
+	if pageSize == 0 {
+		pageSize = defaultListPageSize
+	}
+	pageSize = min(pageSize, maxListPageSize)
+
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	resp, err := s.storageClient.ListReports(storageCtx, &storage_service.ListReportsRequest{
+		Status:    req.Status,
+		ShortCode: req.ShortCode,
+		PageSize:  pageSize,
+		PageToken: req.PageToken,
+		TenantId:  tenantID(ctx),
+	})
+	if status.Code(err) == codes.InvalidArgument {
+		return nil, err
+	} else if err != nil {
+		logf(ctx, "Failed to list reports: %v", err)
+		return nil, status.Error(codes.Unavailable, "failed to list reports")
+	}
+
+	out := &url_service.ListReportsResponse{NextPageToken: resp.NextPageToken}
+	for _, r := range resp.Reports {
+		out.Reports = append(out.Reports, &url_service.AbuseReport{
+			Id:              r.Id,
+			ShortCode:       r.ShortCode,
+			Reason:          r.Reason,
+			ReporterContact: r.ReporterContact,
+			Status:          r.Status,
+			CreatedAt:       r.CreatedAt,
+		})
+	}
+	return out, nil
+}
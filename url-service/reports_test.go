@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func (f *fakeStorage) ReportURL(ctx context.Context, req *storage_service.ReportURLRequest) (*storage_service.ReportURLResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.urls[req.ShortCode]; !ok {
+		return nil, status.Errorf(codes.NotFound, "URL not found: %s", req.ShortCode)
+	}
+	reporters := map[string]bool{req.Reporter: true}
+	for _, r := range f.reports {
+		if r.ShortCode != req.ShortCode {
+			continue
+		}
+		if r.Reporter == req.Reporter {
+			return &storage_service.ReportURLResponse{Duplicate: true}, nil
+		}
+		reporters[r.Reporter] = true
+	}
+	f.reports = append(f.reports, req)
+	resp := &storage_service.ReportURLResponse{ReportId: int64(len(f.reports)), Reporters: int32(len(reporters))}
+	if req.DisableThreshold > 0 && resp.Reporters >= req.DisableThreshold && !f.disabled[req.ShortCode] {
+		f.disabled[req.ShortCode] = true
+		resp.Disabled = true
+	}
+	return resp, nil
+}
+
+func (f *fakeStorage) ListReports(ctx context.Context, req *storage_service.ListReportsRequest) (*storage_service.ListReportsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := &storage_service.ListReportsResponse{}
+	for i, r := range f.reports {
+		resp.Reports = append(resp.Reports, &storage_service.AbuseReport{Id: int64(i + 1), ShortCode: r.ShortCode, Reason: r.Reason, Status: "open"})
+	}
+	return resp, nil
+}
+
+// fromIP returns a context of a call from ip.
+func fromIP(ip string) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 4321}})
+}
+
+func TestReportURLThresholdDisables(t *testing.T) {
+	s, storage, cache := newTestServer(t, map[string]string{"REPORT_DISABLE_THRESHOLD": "3"})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "spam", OriginalUrl: "https://example.com"})
+	cache.set("url:spam", "https://example.com")
+	if got, err := s.GetOriginalURL(context.Background(), &url_service.GetOriginalRequest{ShortCode: "spam"}); err != nil || got.Disabled {
+		t.Fatalf("GetOriginalURL = %v, %v", got, err)
+	}
+
+	reports := []struct {
+		ip       string
+		contact  string
+		wantDupe bool
+	}{
+		{"198.51.100.1", "a@example.com", false},
+		// The same address again, under another contact, the same day
+		{"198.51.100.1", "b@example.com", true},
+		{"198.51.100.2", "a@example.com", false},
+		{"198.51.100.2", "", true},
+	}
+	for i, r := range reports {
+		resp, err := s.ReportURL(fromIP(r.ip), &url_service.ReportURLRequest{ShortCode: "spam", Reason: "phishing", ReporterContact: r.contact})
+		if err != nil || resp.Duplicate != r.wantDupe || !r.wantDupe && resp.ReportId == 0 {
+			t.Fatalf("report %d = %v, %v, want duplicate %v", i, resp, err, r.wantDupe)
+		}
+	}
+	// Two distinct reporters are below the threshold
+	if got, err := s.GetOriginalURL(context.Background(), &url_service.GetOriginalRequest{ShortCode: "spam"}); err != nil || got.Disabled {
+		t.Fatalf("GetOriginalURL below the threshold = %v, %v", got, err)
+	}
+
+	if _, err := s.ReportURL(fromIP("198.51.100.3"), &url_service.ReportURLRequest{ShortCode: "spam", Reason: "phishing"}); err != nil {
+		t.Fatalf("third report: %v", err)
+	}
+	if _, ok := cache.entry("url:spam"); ok {
+		t.Error("cache still holds a link disabled over reports")
+	}
+	if got, err := s.GetOriginalURL(context.Background(), &url_service.GetOriginalRequest{ShortCode: "spam"}); err != nil || !got.Disabled {
+		t.Errorf("GetOriginalURL at the threshold = %v, %v, want disabled", got, err)
+	}
+
+	// Only a hash of the reporter reaches storage
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	for _, r := range storage.reports {
+		if len(r.Reporter) != 64 || strings.Contains(r.Reporter, "198.51.100") {
+			t.Errorf("stored reporter %q, want a SHA-256 hash", r.Reporter)
+		}
+		if r.DisableThreshold != 3 {
+			t.Errorf("report sent threshold %d, want 3", r.DisableThreshold)
+		}
+	}
+}
+
+func TestReportURLValidation(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "spam", OriginalUrl: "https://example.com"})
+	ctx := fromIP("198.51.100.1")
+
+	tests := []struct {
+		name string
+		req  *url_service.ReportURLRequest
+		want codes.Code
+	}{
+		{"unknown code", &url_service.ReportURLRequest{ShortCode: "ghost", Reason: "spam"}, codes.NotFound},
+		{"no code", &url_service.ReportURLRequest{Reason: "spam"}, codes.InvalidArgument},
+		{"blank reason", &url_service.ReportURLRequest{ShortCode: "spam", Reason: "  "}, codes.InvalidArgument},
+		{"long reason", &url_service.ReportURLRequest{ShortCode: "spam", Reason: strings.Repeat("x", maxReportReasonLength+1)}, codes.InvalidArgument},
+		{"long contact", &url_service.ReportURLRequest{ShortCode: "spam", Reason: "spam", ReporterContact: strings.Repeat("x", maxReportContactLength+1)}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		if _, err := s.ReportURL(ctx, tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestListReportsAdminOnly(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"ADMIN_USERS": "root"})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "spam", OriginalUrl: "https://example.com"})
+	if _, err := s.ReportURL(fromIP("198.51.100.1"), &url_service.ReportURLRequest{ShortCode: "spam", Reason: "spam"}); err != nil {
+		t.Fatalf("ReportURL: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := s.ListReports(withKey(ctx, "alice-key", "alice"), &url_service.ListReportsRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("ListReports by a user: got %v, want PermissionDenied", err)
+	}
+	resp, err := s.ListReports(withKey(ctx, "root-key", "root"), &url_service.ListReportsRequest{})
+	if err != nil || len(resp.Reports) != 1 || resp.Reports[0].ShortCode != "spam" {
+		t.Errorf("ListReports by an admin = %v, %v, want the report", resp, err)
+	}
+	if _, err := s.ListReports(withKey(ctx, "root-key", "root"), &url_service.ListReportsRequest{PageSize: -1}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListReports with a negative page size: got %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	cache_service "github.com/syedalijabir/protos/cache-service"
+	storage_service "github.com/syedalijabir/protos/storage-service"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Reputation providers, chosen with REPUTATION_PROVIDER.
+const (
+	reputationNone         = ""
+	reputationSafeBrowsing = "safebrowsing"
+)
+
+const (
+	defaultSafeBrowsingURL    = "https://safebrowsing.googleapis.com/v4/threatMatches:find"
+	defaultReputationTimeout  = 2 * time.Second
+	defaultReputationCacheTTL = 30 * time.Minute
+	defaultReputationRescan   = 24 * time.Hour
+
+	// Safe Browsing takes at most 500 URLs per lookup
+	maxReputationBatch = 500
+
+	// reputationActor is recorded in the history of links the rescan disables
+	reputationActor = "reputation-rescan"
+)
+
+// urlReputation looks URLs up in a threat feed.
+type urlReputation interface {
+	// Lookup returns the threat type of each listed URL. Clean URLs are
+	// left out.
+	Lookup(ctx context.Context, urls []string) (map[string]string, error)
+}
+
+// noopReputation is the default provider and finds nothing.
+type noopReputation struct{}
+
+func (noopReputation) Lookup(ctx context.Context, urls []string) (map[string]string, error) {
+	return nil, nil
+}
+
+// safeBrowsing uses the Safe Browsing Lookup API (v4).
+type safeBrowsing struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+type safeBrowsingEntry struct {
+	URL string `json:"url"`
+}
+
+type safeBrowsingRequest struct {
+	Client struct {
+		ClientID      string `json:"clientId"`
+		ClientVersion string `json:"clientVersion"`
+	} `json:"client"`
+	ThreatInfo struct {
+		ThreatTypes      []string            `json:"threatTypes"`
+		PlatformTypes    []string            `json:"platformTypes"`
+		ThreatEntryTypes []string            `json:"threatEntryTypes"`
+		ThreatEntries    []safeBrowsingEntry `json:"threatEntries"`
+	} `json:"threatInfo"`
+}
+
+type safeBrowsingResponse struct {
+	Matches []struct {
+		ThreatType string            `json:"threatType"`
+		Threat     safeBrowsingEntry `json:"threat"`
+	} `json:"matches"`
+}
+
+func (sb *safeBrowsing) Lookup(ctx context.Context, urls []string) (map[string]string, error) {
+	var body safeBrowsingRequest
+	body.Client.ClientID = "url-shortener"
+	body.Client.ClientVersion = "1.0"
+	body.ThreatInfo.ThreatTypes = []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"}
+	body.ThreatInfo.PlatformTypes = []string{"ANY_PLATFORM"}
+	body.ThreatInfo.ThreatEntryTypes = []string{"URL"}
+	for _, u := range urls {
+		body.ThreatInfo.ThreatEntries = append(body.ThreatInfo.ThreatEntries, safeBrowsingEntry{URL: u})
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sb.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// A header rather than the key query parameter keeps the key out of
+	// logged request errors
+	req.Header.Set("X-Goog-Api-Key", sb.apiKey)
+	resp, err := sb.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("safe browsing returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var result safeBrowsingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid safe browsing response: %v", err)
+	}
+	threats := make(map[string]string, len(result.Matches))
+	for _, m := range result.Matches {
+		threats[m.Threat.URL] = m.ThreatType
+	}
+	return threats, nil
+}
+
+type reputationVerdict struct {
+	threat  string // "" for clean
+	expires time.Time
+}
+
+// reputationChecker screens destinations with a provider, remembering
+// verdicts for REPUTATION_CACHE_TTL to stay within the provider's quota.
+type reputationChecker struct {
+	provider   urlReputation
+	enabled    bool
+	timeout    time.Duration
+	failClosed bool
+	ttl        time.Duration
+	outcomes   *prometheus.CounterVec
+
+	mu       sync.Mutex
+	verdicts map[string]reputationVerdict
+}
+
+func newReputationChecker(cfg Config, outcomes *prometheus.CounterVec) *reputationChecker {
+	c := &reputationChecker{
+		provider:   noopReputation{},
+		timeout:    cfg.ReputationTimeout,
+		failClosed: cfg.ReputationFailClosed,
+		ttl:        cfg.ReputationCacheTTL,
+		outcomes:   outcomes,
+		verdicts:   make(map[string]reputationVerdict),
+	}
+	if cfg.ReputationProvider == reputationSafeBrowsing {
+		c.provider = &safeBrowsing{
+			endpoint: cfg.SafeBrowsingURL,
+			apiKey:   cfg.SafeBrowsingAPIKey,
+			client:   &http.Client{},
+		}
+		c.enabled = true
+	}
+	return c
+}
+
+// Lookup returns the threat type of each listed URL, from the remembered
+// verdicts where it can and from the provider, within the timeout, for
+// the rest.
+func (c *reputationChecker) Lookup(ctx context.Context, urls []string) (map[string]string, error) {
+	threats := make(map[string]string)
+	var unknown []string
+	now := time.Now()
+	c.mu.Lock()
+	for _, u := range urls {
+		v, ok := c.verdicts[u]
+		switch {
+		case ok && now.Before(v.expires):
+			if v.threat != "" {
+				threats[u] = v.threat
+			}
+		case u != "":
+			unknown = append(unknown, u)
+		}
+	}
+	c.mu.Unlock()
+	if len(unknown) == 0 {
+		return threats, nil
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	found := make(map[string]string)
+	for start := 0; start < len(unknown); start += maxReputationBatch {
+		batch, err := c.provider.Lookup(lookupCtx, unknown[start:min(start+maxReputationBatch, len(unknown))])
+		if err != nil {
+			return nil, err
+		}
+		for u, threat := range batch {
+			found[u] = threat
+		}
+	}
+
+	expires := time.Now().Add(c.ttl)
+	c.mu.Lock()
+	for u, v := range c.verdicts {
+		if now.After(v.expires) {
+			delete(c.verdicts, u)
+		}
+	}
+	for _, u := range unknown {
+		c.verdicts[u] = reputationVerdict{threat: found[u], expires: expires}
+		if found[u] != "" {
+			threats[u] = found[u]
+		}
+	}
+	c.mu.Unlock()
+	return threats, nil
+}
+
+// Screen returns an error for each of urls that may not be shortened:
+// PermissionDenied for known threats and, when the provider fails and
+// REPUTATION_FAIL_CLOSED is set, Unavailable for all of them. Otherwise a
+// failed lookup lets them through.
+func (c *reputationChecker) Screen(ctx context.Context, urls []string) map[string]error {
+	if !c.enabled {
+		return nil
+	}
+
+	threats, err := c.Lookup(ctx, urls)
+	if err != nil {
+		c.outcomes.WithLabelValues("error").Inc()
+		if !c.failClosed {
+			logf(ctx, "Warning: reputation lookup failed, allowing destinations: %v", err)
+			return nil
+		}
+		logf(ctx, "Reputation lookup failed, rejecting destinations: %v", err)
+		errs := make(map[string]error, len(urls))
+		for _, u := range urls {
+			if u != "" {
+				errs[u] = status.Error(codes.Unavailable, "destination reputation could not be checked")
+			}
+		}
+		return errs
+	}
+
+	errs := make(map[string]error)
+	for _, u := range urls {
+		if threat := threats[u]; threat != "" {
+			c.outcomes.WithLabelValues("malicious").Inc()
+			errs[u] = status.Errorf(codes.PermissionDenied, "destination %s is flagged as %s", u, threat)
+		} else if u != "" {
+			c.outcomes.WithLabelValues("clean").Inc()
+		}
+	}
+	return errs
+}
+
+// screenDestinations starts screening urls in the background, so ShortenURL
+// can pick a code meanwhile, and returns a function waiting for the first
+// error among them.
+func (s *urlServer) screenDestinations(ctx context.Context, urls ...string) func() error {
+	if !s.reputation.enabled {
+		return func() error { return nil }
+	}
+
+	done := make(chan map[string]error, 1)
+	go func() {
+		done <- s.reputation.Screen(ctx, urls)
+	}()
+	return func() error {
+		errs := <-done
+		for _, u := range urls {
+			if err := errs[u]; err != nil {
+				logf(ctx, "Rejected URL: %v", err)
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// runReputationRescan checks every stored link against the provider every
+// interval, disabling those whose destination has turned malicious since.
+// One replica per interval does the scan, claimed through the cache.
+func (s *urlServer) runReputationRescan(ctx context.Context, interval time.Duration) {
+	if !s.reputation.enabled || interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cacheCtx, cancel := s.cacheCtx(ctx)
+		claim, err := s.cacheClient.SetIfAbsent(cacheCtx, &cache_service.SetRequest{
+			Namespace:  reputationNamespace,
+			Key:        "rescan",
+			Value:      "1",
+			TtlSeconds: int32(interval / time.Second * 9 / 10),
+		})
+		cancel()
+		if err != nil {
+			log.Printf("Warning: failed to claim the reputation rescan: %v", err)
+			continue
+		}
+		if !claim.Stored {
+			continue
+		}
+
+		scanned, disabled, err := s.rescanReputation(ctx)
+		if err != nil {
+			log.Printf("Warning: reputation rescan stopped after %d links: %v", scanned, err)
+			continue
+		}
+		log.Printf("Reputation rescan checked %d links, disabled %d", scanned, disabled)
+	}
+}
+
+// rescanReputation runs one rescan over storage's export of every link.
+func (s *urlServer) rescanReputation(ctx context.Context) (scanned, disabled int, err error) {
+	ctx = withActor(ctx, reputationActor)
+	stream, err := s.storageClient.ExportURLs(ctx, &storage_service.ExportURLsRequest{BatchSize: maxReputationBatch})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for {
+		page, err := stream.Recv()
+		if err == io.EOF {
+			return scanned, disabled, nil
+		}
+		if err != nil {
+			return scanned, disabled, err
+		}
+
+		byURL := make(map[string][]string)
+		var urls []string
+		for _, u := range page.Urls {
+			if u.DeletedAt != "" || isExpired(parseOptionalTime(u.ExpiresAt)) {
+				continue
+			}
+			if byURL[u.OriginalUrl] == nil {
+				urls = append(urls, u.OriginalUrl)
+			}
+			byURL[u.OriginalUrl] = append(byURL[u.OriginalUrl], u.ShortCode)
+		}
+		threats, err := s.reputation.Lookup(ctx, urls)
+		if err != nil {
+			return scanned, disabled, err
+		}
+		scanned += len(page.Urls)
+
+		for originalURL, threat := range threats {
+			for _, code := range byURL[originalURL] {
+				if err := s.disableFlagged(ctx, code, threat); err != nil {
+					log.Printf("Warning: failed to disable %s flagged as %s: %v", code, threat, err)
+					continue
+				}
+				disabled++
+			}
+		}
+	}
+}
+
+// disableFlagged disables a link whose destination the provider flagged,
+// like SetURLStatus does for its owner.
+func (s *urlServer) disableFlagged(ctx context.Context, shortCode, threat string) error {
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	if _, err := s.storageClient.SetURLStatus(storageCtx, &storage_service.SetURLStatusRequest{ShortCode: shortCode}); err != nil {
+		return err
+	}
+
+	s.urls.Remove(shortCode)
+	s.invalidateCache(ctx, shortCode)
+	s.reputation.outcomes.WithLabelValues("disabled").Inc()
+	log.Printf("Disabled %s, its destination is flagged as %s", shortCode, threat)
+	return nil
+}
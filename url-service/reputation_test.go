@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ExportURLs sends every URL, or every URL of a user, deleted ones
+// included, in one page.
+func (f *fakeStorage) ExportURLs(req *storage_service.ExportURLsRequest, stream grpc.ServerStreamingServer[storage_service.ExportURLsResponse]) error {
+	f.mu.Lock()
+	page := &storage_service.ExportURLsResponse{}
+	for _, urls := range []map[string]*storage_service.SaveURLRequest{f.urls, f.deleted} {
+		for code, u := range urls {
+			if req.UserId != "" && u.UserId != req.UserId {
+				continue
+			}
+			exported := &storage_service.ExportedURL{ShortCode: code, OriginalUrl: u.OriginalUrl, ExpiresAt: u.ExpiresAt, UserId: u.UserId,
+				CreatedAt: fakeCreatedAt, ClickCount: f.clickCounts[code]}
+			if _, live := f.urls[code]; !live {
+				exported.DeletedAt = fakeCreatedAt
+			}
+			page.Urls = append(page.Urls, exported)
+		}
+	}
+	f.mu.Unlock()
+	sort.Slice(page.Urls, func(i, j int) bool { return page.Urls[i].ShortCode < page.Urls[j].ShortCode })
+	return stream.Send(page)
+}
+
+// fakeSafeBrowsing answers Safe Browsing lookups, flagging the URLs in
+// threats, after waiting delay.
+type fakeSafeBrowsing struct {
+	mu      sync.Mutex
+	threats map[string]string
+	delay   time.Duration
+	lookups [][]string
+	keys    []string
+}
+
+func (f *fakeSafeBrowsing) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req safeBrowsingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	delay := f.delay
+	var matches []map[string]any
+	var urls []string
+	for _, entry := range req.ThreatInfo.ThreatEntries {
+		urls = append(urls, entry.URL)
+		if threat := f.threats[entry.URL]; threat != "" {
+			matches = append(matches, map[string]any{"threatType": threat, "threat": map[string]string{"url": entry.URL}})
+		}
+	}
+	f.lookups = append(f.lookups, urls)
+	f.keys = append(f.keys, r.Header.Get("X-Goog-Api-Key")+r.URL.Query().Get("key"))
+	f.mu.Unlock()
+
+	select {
+	case <-time.After(delay):
+	case <-r.Context().Done():
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"matches": matches})
+}
+
+// lookupCount returns how many lookups reached the fake.
+func (f *fakeSafeBrowsing) lookupCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.lookups)
+}
+
+// newReputationServer returns a test server screening destinations with a
+// fake Safe Browsing API.
+func newReputationServer(t *testing.T, sb *fakeSafeBrowsing, env map[string]string) (*urlServer, *fakeStorage, *fakeCache) {
+	t.Helper()
+	api := httptest.NewServer(sb)
+	t.Cleanup(api.Close)
+	all := map[string]string{
+		"URL_SYNC_PERSIST":      "true",
+		"REPUTATION_PROVIDER":   reputationSafeBrowsing,
+		"SAFE_BROWSING_API_KEY": "sb-key",
+		"SAFE_BROWSING_URL":     api.URL,
+	}
+	for k, v := range env {
+		all[k] = v
+	}
+	return newTestServer(t, all)
+}
+
+func TestShortenURLReputation(t *testing.T) {
+	sb := &fakeSafeBrowsing{threats: map[string]string{"https://malware.example/": "MALWARE"}}
+	s, _, _ := newReputationServer(t, sb, nil)
+	ctx := context.Background()
+
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://malware.example/"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("ShortenURL of a flagged URL: got %v, want PermissionDenied", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://clean.example/"}); err != nil {
+			t.Fatalf("ShortenURL of a clean URL: %v", err)
+		}
+	}
+	// Verdicts are remembered, flagged ones too
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://malware.example/"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("second ShortenURL of a flagged URL: got %v, want PermissionDenied", err)
+	}
+	if got := sb.lookupCount(); got != 2 {
+		t.Errorf("%d lookups, want 2, one per destination", got)
+	}
+	sb.mu.Lock()
+	keys := sb.keys
+	sb.mu.Unlock()
+	for _, key := range keys {
+		if key != "sb-key" {
+			t.Errorf("lookup sent key %q, want sb-key in the header only", key)
+		}
+	}
+
+	outcomes := gathered(t, s.metrics.registry, "url_service_reputation_total")
+	if outcomes["outcome=malicious"] != 2 || outcomes["outcome=clean"] != 3 {
+		t.Errorf("reputation outcomes %v, want 2 malicious and 3 clean", outcomes)
+	}
+}
+
+func TestReputationCacheExpires(t *testing.T) {
+	sb := &fakeSafeBrowsing{}
+	s, _, _ := newReputationServer(t, sb, map[string]string{"REPUTATION_CACHE_TTL": "50ms"})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.reputation.Lookup(ctx, []string{"https://example.com/"}); err != nil {
+			t.Fatalf("Lookup: %v", err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := s.reputation.Lookup(ctx, []string{"https://example.com/"}); err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if got := sb.lookupCount(); got != 2 {
+		t.Errorf("%d lookups, want 2, the second after the verdict expired", got)
+	}
+}
+
+func TestReputationTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		failClosed string
+		want       codes.Code
+	}{
+		{"fail open", "false", codes.OK},
+		{"fail closed", "true", codes.Unavailable},
+	}
+	for _, tt := range tests {
+		sb := &fakeSafeBrowsing{delay: time.Minute}
+		s, _, _ := newReputationServer(t, sb, map[string]string{"REPUTATION_TIMEOUT": "50ms", "REPUTATION_FAIL_CLOSED": tt.failClosed})
+
+		start := time.Now()
+		_, err := s.ShortenURL(context.Background(), &url_service.ShortenRequest{OriginalUrl: "https://slow.example/"})
+		if status.Code(err) != tt.want {
+			t.Errorf("%s: ShortenURL = %v, want %v", tt.name, err, tt.want)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%s: ShortenURL took %v with a 50ms reputation timeout", tt.name, elapsed)
+		}
+		if got := gathered(t, s.metrics.registry, "url_service_reputation_total")["outcome=error"]; got != 1 {
+			t.Errorf("%s: %v reputation errors, want 1", tt.name, got)
+		}
+	}
+}
+
+func TestReputationRescan(t *testing.T) {
+	sb := &fakeSafeBrowsing{}
+	s, storage, cache := newReputationServer(t, sb, nil)
+	ctx := context.Background()
+	for code, u := range map[string]string{"bad1": "https://turned.example/", "bad2": "https://turned.example/", "fine": "https://fine.example/"} {
+		if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: u, CustomAlias: code}); err != nil {
+			t.Fatalf("ShortenURL %s: %v", code, err)
+		}
+	}
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "expired", OriginalUrl: "https://turned.example/", ExpiresAt: time.Now().Add(-time.Hour).Format(time.RFC3339)})
+	cache.set("url:bad1", "https://turned.example/")
+
+	// The destination turns malicious once its clean verdict has expired
+	sb.mu.Lock()
+	sb.threats = map[string]string{"https://turned.example/": "SOCIAL_ENGINEERING"}
+	sb.mu.Unlock()
+	s.reputation.mu.Lock()
+	clear(s.reputation.verdicts)
+	s.reputation.mu.Unlock()
+
+	scanned, disabled, err := s.rescanReputation(ctx)
+	if err != nil || scanned != 4 || disabled != 2 {
+		t.Fatalf("rescanReputation = %d, %d, %v, want 4 scanned and 2 disabled", scanned, disabled, err)
+	}
+	storage.mu.Lock()
+	flagged := map[string]bool{"bad1": storage.disabled["bad1"], "bad2": storage.disabled["bad2"], "fine": storage.disabled["fine"], "expired": storage.disabled["expired"]}
+	storage.mu.Unlock()
+	if !flagged["bad1"] || !flagged["bad2"] || flagged["fine"] || flagged["expired"] {
+		t.Errorf("disabled %v, want bad1 and bad2 only", flagged)
+	}
+	if _, ok := cache.entry("url:bad1"); ok {
+		t.Error("cache still holds a disabled link")
+	}
+	if got, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "bad1"}); err != nil || !got.Disabled {
+		t.Errorf("GetOriginalURL of a flagged link = %v, %v, want disabled", got, err)
+	}
+	if got := gathered(t, s.metrics.registry, "url_service_reputation_total")["outcome=disabled"]; got != 2 {
+		t.Errorf("%v links counted disabled, want 2", got)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// requestIDHeader carries the ID that ties together log lines from every
+// service involved in a single user action.
+const requestIDHeader = "x-request-id"
+
+type requestIDKey struct{}
+
+// requestIDInterceptor takes the caller's request ID, or generates one, and
+// stores it in the context for logging and forwarding. The ID is also
+// returned in the response trailer so gateways can surface it.
+func requestIDInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var id string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(requestIDHeader); len(values) > 0 {
+				id = values[0]
+			}
+		}
+		if id == "" {
+			id = uuid.NewString()
+		}
+
+		ctx = context.WithValue(ctx, requestIDKey{}, id)
+		if err := grpc.SetTrailer(ctx, metadata.Pairs(requestIDHeader, id)); err != nil {
+			logf(ctx, "Warning: failed to set request ID trailer: %v", err)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// requestID returns the request ID stored in ctx, if any.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// logf logs like log.Printf, prefixed with the request ID from ctx. With
+// LOG_LEVEL=warn only warnings and failures are logged.
+func logf(ctx context.Context, format string, args ...interface{}) {
+	if quietLogs.Load() && !isWarning(format) {
+		return
+	}
+	if id := requestID(ctx); id != "" {
+		log.Printf("[%s] %s", id, fmt.Sprintf(format, args...))
+		return
+	}
+	log.Printf(format, args...)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// captureLogs collects log output until the test ends.
+func captureLogs(t *testing.T) *syncBuffer {
+	t.Helper()
+	var buf syncBuffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of log.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRequestIDPropagates(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+	conn := dialBufconn(t, func(srv *grpc.Server) { url_service.RegisterURLServiceServer(srv, s) }, grpc.UnaryInterceptor(requestIDInterceptor()))
+	client := url_service.NewURLServiceClient(conn)
+	logs := captureLogs(t)
+
+	tests := []struct {
+		name, sent string
+	}{
+		{"preset", "req-31"},
+		{"generated", ""},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.sent != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, requestIDHeader, tt.sent)
+		}
+		var trailer metadata.MD
+		resp, err := client.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com/" + tt.name}, grpc.Trailer(&trailer))
+		if err != nil {
+			t.Fatalf("%s: ShortenURL: %v", tt.name, err)
+		}
+
+		// The trailer returns the ID, the caller's or a generated UUID
+		ids := trailer.Get(requestIDHeader)
+		if len(ids) != 1 {
+			t.Fatalf("%s: trailer request IDs %v, want one", tt.name, ids)
+		}
+		id := ids[0]
+		if tt.sent != "" && id != tt.sent {
+			t.Errorf("%s: trailer request ID %s, want %s", tt.name, id, tt.sent)
+		}
+		if _, err := uuid.Parse(id); tt.sent == "" && err != nil {
+			t.Errorf("%s: generated request ID %s is not a UUID", tt.name, id)
+		}
+
+		// Storage receives it, and the service's log lines carry it
+		if got := storage.savedWith(resp.ShortCode).Get(requestIDHeader); len(got) != 1 || got[0] != id {
+			t.Errorf("%s: storage received request ID %v, want %s", tt.name, got, id)
+		}
+		if !strings.Contains(logs.String(), "["+id+"] ShortenURL request for: https://example.com/"+tt.name) {
+			t.Errorf("%s: no log line tagged [%s]", tt.name, id)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const maxQueryTemplateLength = 1024
+
+var templatePlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// linkRoutes is how a link picks its destination on each lookup, beyond
+// the original URL: rules tried first, then variants, with the query
+// template added to whichever destination wins. The zero value is a plain
+// link.
+type linkRoutes struct {
+	variants      *variantSet   // nil unless a split link
+	rules         redirectRules // tried before originalURL or variants
+	queryTemplate string        // e.g. utm_source=shortener&utm_campaign={code}
+}
+
+// routesFromStorage returns the routes storage returned for a link.
+func routesFromStorage(resp *storage_service.GetURLResponse) linkRoutes {
+	return linkRoutes{
+		variants:      variantsFromStorage(resp.Variants, resp.StickyVariants),
+		rules:         rulesFromStorage(resp.Rules),
+		queryTemplate: resp.QueryTemplate,
+	}
+}
+
+func (r linkRoutes) plain() bool {
+	return r.variants == nil && len(r.rules) == 0 && r.queryTemplate == ""
+}
+
+// URLs returns the destinations besides a plain link's original URL, for
+// screening.
+func (r linkRoutes) URLs() []string {
+	var urls []string
+	if r.variants != nil {
+		urls = r.variants.URLs()
+	}
+	return append(urls, r.rules.URLs()...)
+}
+
+// newLinkRoutes validates the destinations a request sets: original_url or
+// variants, whose first then stands in for the original URL, plus rules
+// and a query template. It returns the original URL as stored.
+func (s *urlServer) newLinkRoutes(originalURL string, variants []*url_service.Variant, sticky bool, rules []*url_service.RedirectRule, queryTemplate string) (string, linkRoutes, error) {
+	var routes linkRoutes
+	var err error
+	if routes.variants, err = s.newVariantSet(variants, sticky); err != nil {
+		return "", routes, err
+	}
+	if routes.variants != nil {
+		if originalURL != "" {
+			return "", routes, status.Error(codes.InvalidArgument, "set either original_url or variants, not both")
+		}
+		originalURL = routes.variants.Primary()
+	} else {
+		if err := s.validator.Validate(originalURL); err != nil {
+			return "", routes, err
+		}
+		if originalURL, err = s.canonicalURL(originalURL); err != nil {
+			return "", routes, err
+		}
+	}
+	if routes.rules, err = s.newRedirectRules(rules); err != nil {
+		return "", routes, err
+	}
+	if routes.queryTemplate, err = newQueryTemplate(queryTemplate); err != nil {
+		return "", routes, err
+	}
+	return originalURL, routes, nil
+}
+
+// newQueryTemplate validates a query template: query parameters whose
+// values may hold the placeholders {code} and {variant}.
+func newQueryTemplate(template string) (string, error) {
+	template = strings.TrimLeft(strings.TrimSpace(template), "?&")
+	if template == "" {
+		return "", nil
+	}
+	if len(template) > maxQueryTemplateLength {
+		return "", status.Errorf(codes.InvalidArgument, "query_template exceeds %d characters", maxQueryTemplateLength)
+	}
+	for _, placeholder := range templatePlaceholder.FindAllString(template, -1) {
+		if placeholder != "{code}" && placeholder != "{variant}" {
+			return "", status.Errorf(codes.InvalidArgument, "unknown placeholder %s in query_template, want {code} or {variant}", placeholder)
+		}
+	}
+	for _, param := range strings.Split(template, "&") {
+		key, _, _ := strings.Cut(param, "=")
+		if key == "" || strings.ContainsAny(key, "{}#") {
+			return "", status.Errorf(codes.InvalidArgument, "invalid parameter %q in query_template", param)
+		}
+		if _, err := url.ParseQuery(templatePlaceholder.ReplaceAllString(param, "x")); err != nil || strings.Contains(param, "#") {
+			return "", status.Errorf(codes.InvalidArgument, "invalid parameter %q in query_template", param)
+		}
+	}
+	return template, nil
+}
+
+// applyQueryTemplate adds the parameters of template to destination, with
+// the placeholders filled in. Parameters the destination already has keep
+// their value, and any fragment stays at the end.
+func applyQueryTemplate(destination, template, shortCode, variant string) string {
+	if template == "" {
+		return destination
+	}
+	base, fragment, hasFragment := strings.Cut(destination, "#")
+	_, query, hasQuery := strings.Cut(base, "?")
+	existing, _ := url.ParseQuery(query)
+
+	fill := strings.NewReplacer("{code}", url.QueryEscape(shortCode), "{variant}", url.QueryEscape(variant))
+	var added []string
+	for _, param := range strings.Split(template, "&") {
+		rawKey, _, _ := strings.Cut(param, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil || existing.Has(key) {
+			continue
+		}
+		existing.Add(key, "") // Repeats in the template are dropped too
+		added = append(added, fill.Replace(param))
+	}
+	if len(added) == 0 {
+		return destination
+	}
+
+	switch {
+	case !hasQuery:
+		base += "?"
+	case query != "" && !strings.HasSuffix(query, "&"):
+		base += "&"
+	}
+	base += strings.Join(added, "&")
+	if hasFragment {
+		base += "#" + fragment
+	}
+	return base
+}
+
+// cachedLink is the JSON the cache holds for links with routes, so they are
+// cached as a unit with their destination.
+type cachedLink struct {
+	URL           string        `json:"url"`
+	Sticky        bool          `json:"sticky,omitempty"`
+	Variants      []variant     `json:"variants,omitempty"`
+	Rules         redirectRules `json:"rules,omitempty"`
+	QueryTemplate string        `json:"query_template,omitempty"`
+}
+
+// cacheValue is what the cache holds for a link: its destination, or for
+// links with routes a cachedLink, which no URL starts like.
+func cacheValue(originalURL string, routes linkRoutes) string {
+	if routes.plain() {
+		return originalURL
+	}
+	link := cachedLink{URL: originalURL, Rules: routes.rules, QueryTemplate: routes.queryTemplate}
+	if routes.variants != nil {
+		link.Sticky, link.Variants = routes.variants.Sticky, routes.variants.Variants
+	}
+	data, err := json.Marshal(link)
+	if err != nil {
+		panic(fmt.Sprintf("encoding cached link: %v", err)) // Only strings and numbers
+	}
+	return string(data)
+}
+
+// parseCacheValue reverses cacheValue. A value that doesn't parse is served
+// as a miss, by returning an empty destination.
+func parseCacheValue(value string) (string, linkRoutes) {
+	if !strings.HasPrefix(value, "{") {
+		return value, linkRoutes{}
+	}
+	var link cachedLink
+	if err := json.Unmarshal([]byte(value), &link); err != nil || link.URL == "" {
+		return "", linkRoutes{}
+	}
+	routes := linkRoutes{rules: link.Rules, queryTemplate: link.QueryTemplate}
+	if len(link.Variants) > 0 {
+		routes.variants = &variantSet{Sticky: link.Sticky, Variants: link.Variants}
+	}
+	return link.URL, routes
+}
+
+// linkTarget is where a lookup of a link goes, with the variant and rule
+// it got there by, if any.
+type linkTarget struct {
+	URL, Variant, Rule string
+}
+
+func (t linkTarget) response() *url_service.GetOriginalResponse {
+	return &url_service.GetOriginalResponse{OriginalUrl: t.URL, Found: true, Variant: t.Variant, Rule: t.Rule, Resolution: resolutionDestination}
+}
+
+// destination picks where a lookup of a link goes. The first rule matching
+// the visitor's device and country wins; without a match the link goes to
+// its own destination, picking a variant for split links. Sticky picks
+// hash the short code with the visitor ID the caller sent, or else the
+// caller's IP and user agent. The query template is added last.
+func (s *urlServer) destination(ctx context.Context, req *url_service.GetOriginalRequest, originalURL string, routes linkRoutes) linkTarget {
+	target := s.pickDestination(ctx, req, originalURL, routes)
+	_, shortCode := splitTenantKey(req.ShortCode)
+	target.URL = applyQueryTemplate(target.URL, routes.queryTemplate, shortCode, target.Variant)
+	return target
+}
+
+func (s *urlServer) pickDestination(ctx context.Context, req *url_service.GetOriginalRequest, originalURL string, routes linkRoutes) linkTarget {
+	var target linkTarget
+	if len(routes.rules) > 0 {
+		rule, number, ok := routes.rules.Match(classifyDevice(req.UserAgent), s.requestCountry(ctx, req))
+		if ok {
+			return linkTarget{URL: rule.URL, Rule: number}
+		}
+		target.Rule = ruleDefault
+	}
+	if routes.variants == nil {
+		target.URL = originalURL
+		return target
+	}
+
+	var visitorKey string
+	if routes.variants.Sticky {
+		visitor := req.VisitorId
+		if visitor == "" {
+			visitor = callerIP(ctx, s.trustForwardedFor) + "\x00" + req.UserAgent
+		}
+		visitorKey = req.ShortCode + "\x00" + visitor
+	}
+	v := routes.variants.Pick(visitorKey)
+	target.URL, target.Variant = v.URL, v.Name
+	return target
+}
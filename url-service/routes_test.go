@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestApplyQueryTemplate(t *testing.T) {
+	const utm = "utm_source=shortener&utm_campaign={code}"
+	tests := []struct {
+		name, destination, template, variant string
+		want                                 string
+	}{
+		{"no template", "https://example.com/a?x=1", "", "", "https://example.com/a?x=1"},
+		{"no query", "https://example.com/a", utm, "", "https://example.com/a?utm_source=shortener&utm_campaign=promo"},
+		{"existing query", "https://example.com/a?id=7", utm, "", "https://example.com/a?id=7&utm_source=shortener&utm_campaign=promo"},
+		{"empty query", "https://example.com/a?", utm, "", "https://example.com/a?utm_source=shortener&utm_campaign=promo"},
+		{"query ending in &", "https://example.com/a?id=7&", utm, "", "https://example.com/a?id=7&utm_source=shortener&utm_campaign=promo"},
+		{"fragment kept last", "https://example.com/a?id=7#top", utm, "", "https://example.com/a?id=7&utm_source=shortener&utm_campaign=promo#top"},
+		{"fragment without query", "https://example.com/a#top", utm, "", "https://example.com/a?utm_source=shortener&utm_campaign=promo#top"},
+		{"conflicting key keeps the destination's", "https://example.com/a?utm_source=newsletter", utm, "", "https://example.com/a?utm_source=newsletter&utm_campaign=promo"},
+		{"every key already set", "https://example.com/a?utm_campaign=x&utm_source=y", utm, "", "https://example.com/a?utm_campaign=x&utm_source=y"},
+		{"escaped key in destination", "https://example.com/a?utm%5Fsource=x", utm, "", "https://example.com/a?utm%5Fsource=x&utm_campaign=promo"},
+		{"repeated key in template", "https://example.com/a", "ref=a&ref=b", "", "https://example.com/a?ref=a"},
+		{"variant placeholder", "https://example.com/b", "utm_content={variant}", "new page", "https://example.com/b?utm_content=new+page"},
+		{"bare parameter", "https://example.com/a", "beta", "", "https://example.com/a?beta"},
+	}
+	for _, tt := range tests {
+		if got := applyQueryTemplate(tt.destination, tt.template, "promo", tt.variant); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewQueryTemplate(t *testing.T) {
+	valid := []struct{ template, want string }{
+		{"", ""},
+		{"  ", ""},
+		{"?utm_source=shortener", "utm_source=shortener"},
+		{"&utm_campaign={code}&utm_content={variant}", "utm_campaign={code}&utm_content={variant}"},
+	}
+	for _, tt := range valid {
+		if got, err := newQueryTemplate(tt.template); err != nil || got != tt.want {
+			t.Errorf("newQueryTemplate(%q) = %q, %v, want %q", tt.template, got, err, tt.want)
+		}
+	}
+
+	invalid := []string{
+		"utm_source={source}",
+		"{code}=x",
+		"=x",
+		"utm_source=a#b",
+		"utm_source=%zz",
+		"a=" + strings.Repeat("x", maxQueryTemplateLength),
+	}
+	for _, template := range invalid {
+		if _, err := newQueryTemplate(template); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("newQueryTemplate(%.40q): got %v, want InvalidArgument", template, err)
+		}
+	}
+}
+
+func TestQueryTemplateLookup(t *testing.T) {
+	s, storage, cache := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true", "DEDUPLICATE_URLS": "true"})
+	ctx := context.Background()
+	const destination = "https://example.com/sale?ref=home#deals"
+	resp, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: destination, CustomAlias: "sale", QueryTemplate: "utm_source=shortener&utm_campaign={code}&ref=link"})
+	if err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	// The stored URL stays clean
+	if u, _ := storage.url("sale"); u.OriginalUrl != destination || u.QueryTemplate != "utm_source=shortener&utm_campaign={code}&ref=link" {
+		t.Errorf("stored %s with template %q", u.OriginalUrl, u.QueryTemplate)
+	}
+	if resp.OriginalUrl != destination {
+		t.Errorf("ShortenURL returned %s, want the clean destination", resp.OriginalUrl)
+	}
+
+	const want = "https://example.com/sale?ref=home&utm_source=shortener&utm_campaign=sale#deals"
+	// lookup checks the redirect carries the template
+	lookup := func(layer string) {
+		resp, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "sale"})
+		if err != nil || resp.OriginalUrl != want {
+			t.Errorf("%s: GetOriginalURL = %v, %v, want %s", layer, resp.GetOriginalUrl(), err, want)
+		}
+	}
+	lookup("memory")
+	waitForCacheEntry(t, cache, "url:sale", true)
+	s.urls.Remove("sale")
+	lookup("cache")
+	cache.expire("url:sale")
+	s.urls.Remove("sale")
+	lookup("storage")
+
+	// Deduplication doesn't hand out the templated link for the plain URL
+	plain, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: destination})
+	if err != nil {
+		t.Fatalf("ShortenURL plain: %v", err)
+	}
+	if plain.ShortCode == "sale" {
+		t.Fatal("plain link reused the templated one")
+	}
+	again, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: destination})
+	if err != nil || again.ShortCode != plain.ShortCode {
+		t.Errorf("second plain link = %v, %v, want %s reused", again.GetShortCode(), err, plain.ShortCode)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const maxRedirectRules = 10
+
+// Devices a redirect rule can match. ios and android match the platform,
+// the others the form factor on any platform.
+const (
+	deviceIOS     = "ios"
+	deviceAndroid = "android"
+	deviceMobile  = "mobile"
+	deviceTablet  = "tablet"
+	deviceDesktop = "desktop"
+)
+
+// ruleDefault is recorded for clicks on links with rules that none of the
+// rules matched.
+const ruleDefault = "default"
+
+var countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// redirectRule sends the visitors it matches to its own destination, such
+// as an app store for one platform. Empty matchers match anyone.
+type redirectRule struct {
+	Device    string   `json:"device,omitempty"`
+	Countries []string `json:"countries,omitempty"`
+	URL       string   `json:"url"`
+}
+
+// redirectRules are tried in order on each lookup, the first match
+// winning. Visitors no rule matches go to the link's own destination.
+type redirectRules []redirectRule
+
+// newRedirectRules validates and canonicalizes the rules of a request.
+func (s *urlServer) newRedirectRules(rules []*url_service.RedirectRule) (redirectRules, error) {
+	if len(rules) > maxRedirectRules {
+		return nil, status.Errorf(codes.InvalidArgument, "a link can have at most %d rules", maxRedirectRules)
+	}
+
+	var out redirectRules
+	for i, r := range rules {
+		rule := redirectRule{Device: strings.ToLower(strings.TrimSpace(r.Device))}
+		switch rule.Device {
+		case "", deviceIOS, deviceAndroid, deviceMobile, deviceTablet, deviceDesktop:
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "rule %d: unknown device %q, want ios, android, mobile, tablet or desktop", i+1, r.Device)
+		}
+		for _, country := range r.Countries {
+			country = strings.ToUpper(strings.TrimSpace(country))
+			if !countryPattern.MatchString(country) {
+				return nil, status.Errorf(codes.InvalidArgument, "rule %d: invalid country %q, want an ISO 3166-1 alpha-2 code", i+1, country)
+			}
+			rule.Countries = append(rule.Countries, country)
+		}
+		if rule.Device == "" && len(rule.Countries) == 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "rule %d matches every visitor, set a device or countries", i+1)
+		}
+		if r.Url == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "rule %d needs a url", i+1)
+		}
+		if err := s.validator.Validate(r.Url); err != nil {
+			return nil, status.Errorf(status.Code(err), "rule %d: %s", i+1, status.Convert(err).Message())
+		}
+		destination, err := s.canonicalURL(r.Url)
+		if err != nil {
+			return nil, err
+		}
+		rule.URL = destination
+		out = append(out, rule)
+	}
+	return out, nil
+}
+
+func rulesFromStorage(rules []*storage_service.RedirectRule) redirectRules {
+	if len(rules) == 0 {
+		return nil
+	}
+	out := make(redirectRules, len(rules))
+	for i, r := range rules {
+		out[i] = redirectRule{Device: r.Device, Countries: r.Countries, URL: r.Url}
+	}
+	return out
+}
+
+// URLs returns the destinations, for screening.
+func (rs redirectRules) URLs() []string {
+	urls := make([]string, len(rs))
+	for i, r := range rs {
+		urls[i] = r.URL
+	}
+	return urls
+}
+
+func (rs redirectRules) storage() []*storage_service.RedirectRule {
+	if len(rs) == 0 {
+		return nil
+	}
+	out := make([]*storage_service.RedirectRule, len(rs))
+	for i, r := range rs {
+		out[i] = &storage_service.RedirectRule{Device: r.Device, Countries: r.Countries, Url: r.URL}
+	}
+	return out
+}
+
+func (rs redirectRules) proto() []*url_service.RedirectRule {
+	if len(rs) == 0 {
+		return nil
+	}
+	out := make([]*url_service.RedirectRule, len(rs))
+	for i, r := range rs {
+		out[i] = &url_service.RedirectRule{Device: r.Device, Countries: r.Countries, Url: r.URL}
+	}
+	return out
+}
+
+// Match returns the first rule matching a visitor and its number from 1,
+// or false if none does. A visitor whose device or country is unknown only
+// matches rules that don't ask for it.
+func (rs redirectRules) Match(device visitorDevice, country string) (redirectRule, string, bool) {
+	for i, r := range rs {
+		if r.Device != "" && !device.is(r.Device) {
+			continue
+		}
+		if len(r.Countries) > 0 && !containsCountry(r.Countries, country) {
+			continue
+		}
+		return r, strconv.Itoa(i + 1), true
+	}
+	return redirectRule{}, "", false
+}
+
+func containsCountry(countries []string, country string) bool {
+	if country == "" {
+		return false
+	}
+	for _, c := range countries {
+		if strings.EqualFold(c, country) {
+			return true
+		}
+	}
+	return false
+}
+
+// visitorDevice is what a user agent tells of the visitor's device: its
+// platform, ios or android if either, and its form factor.
+type visitorDevice struct {
+	platform, form string
+}
+
+func (d visitorDevice) is(device string) bool {
+	return device == d.platform || device == d.form
+}
+
+// classifyDevice reads a visitor's device from their user agent. An empty
+// or unrecognized user agent matches no device.
+func classifyDevice(userAgent string) visitorDevice {
+	var d visitorDevice
+	switch {
+	case strings.Contains(userAgent, "iPhone") || strings.Contains(userAgent, "iPad") || strings.Contains(userAgent, "iPod"):
+		d.platform = deviceIOS
+	case strings.Contains(userAgent, "Android"):
+		d.platform = deviceAndroid
+	}
+
+	switch {
+	case strings.Contains(userAgent, "iPad") || strings.Contains(strings.ToLower(userAgent), "tablet") ||
+		(d.platform == deviceAndroid && !strings.Contains(userAgent, "Mobile")):
+		d.form = deviceTablet
+	case d.platform != "" || strings.Contains(userAgent, "Mobile"):
+		d.form = deviceMobile
+	case strings.Contains(userAgent, "Windows") || strings.Contains(userAgent, "Macintosh") ||
+		strings.Contains(userAgent, "X11") || strings.Contains(userAgent, "CrOS"):
+		d.form = deviceDesktop
+	}
+	return d
+}
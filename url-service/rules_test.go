@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	iPhoneUA        = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"
+	iPadUA          = "Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"
+	androidPhoneUA  = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36"
+	androidTabletUA = "Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 Chrome/120.0 Safari/537.36"
+	windowsUA       = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36"
+	macUA           = "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/605.1.15 Version/17.0 Safari/605.1.15"
+)
+
+func TestClassifyDevice(t *testing.T) {
+	tests := []struct {
+		name, userAgent, platform, form string
+	}{
+		{"iPhone", iPhoneUA, deviceIOS, deviceMobile},
+		{"iPad", iPadUA, deviceIOS, deviceTablet},
+		{"Android phone", androidPhoneUA, deviceAndroid, deviceMobile},
+		{"Android tablet", androidTabletUA, deviceAndroid, deviceTablet},
+		{"Windows", windowsUA, "", deviceDesktop},
+		{"Mac", macUA, "", deviceDesktop},
+		{"command line", "curl/8.4.0", "", ""},
+		{"none", "", "", ""},
+	}
+	for _, tt := range tests {
+		if got := classifyDevice(tt.userAgent); got.platform != tt.platform || got.form != tt.form {
+			t.Errorf("%s: got %+v, want platform %q and form %q", tt.name, got, tt.platform, tt.form)
+		}
+	}
+}
+
+func TestRedirectRulesMatch(t *testing.T) {
+	rules := redirectRules{
+		{Device: deviceIOS, URL: "https://apps.apple.com/app"},
+		{Device: deviceAndroid, URL: "https://play.google.com/app"},
+		{Device: deviceMobile, Countries: []string{"DE", "AT"}, URL: "https://example.de/mobile"},
+		{Countries: []string{"DE"}, URL: "https://example.de"},
+	}
+	tests := []struct {
+		name, userAgent, country string
+		want                     string // rule number, "" for none
+	}{
+		{"iOS before country", iPhoneUA, "DE", "1"},
+		{"Android", androidPhoneUA, "", "2"},
+		{"Android tablet is still Android", androidTabletUA, "US", "2"},
+		{"device and country", "Mozilla/5.0 (Mobile; rv:120.0) Gecko/120.0 Firefox/120.0", "AT", "3"},
+		{"country only", windowsUA, "DE", "4"},
+		{"country in lower case", windowsUA, "de", "4"},
+		{"no match falls through", windowsUA, "US", ""},
+		{"missing GeoIP data", windowsUA, "", ""},
+		{"missing GeoIP data and user agent", "", "", ""},
+	}
+	for _, tt := range tests {
+		rule, number, ok := rules.Match(classifyDevice(tt.userAgent), tt.country)
+		if ok != (tt.want != "") || number != tt.want {
+			t.Errorf("%s: matched rule %q (%v), want %q", tt.name, number, ok, tt.want)
+			continue
+		}
+		if n, _ := strconv.Atoi(number); ok && rule.URL != rules[n-1].URL {
+			t.Errorf("%s: rule %s sends to %s", tt.name, number, rule.URL)
+		}
+	}
+}
+
+func TestNewRedirectRules(t *testing.T) {
+	s, _, _ := newTestServer(t, nil)
+	rules, err := s.newRedirectRules([]*url_service.RedirectRule{
+		{Device: " iOS ", Url: "https://apps.apple.com/app"},
+		{Countries: []string{"de", " at"}, Url: "https://example.de"},
+	})
+	if err != nil {
+		t.Fatalf("newRedirectRules: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Device != deviceIOS || strings.Join(rules[1].Countries, ",") != "DE,AT" {
+		t.Errorf("newRedirectRules = %+v, want the device and countries normalized", rules)
+	}
+
+	many := make([]*url_service.RedirectRule, maxRedirectRules+1)
+	for i := range many {
+		many[i] = &url_service.RedirectRule{Device: deviceIOS, Url: "https://example.com"}
+	}
+	tests := []struct {
+		name string
+		rule *url_service.RedirectRule
+	}{
+		{"unknown device", &url_service.RedirectRule{Device: "watch", Url: "https://example.com"}},
+		{"invalid country", &url_service.RedirectRule{Countries: []string{"Germany"}, Url: "https://example.com"}},
+		{"matches everyone", &url_service.RedirectRule{Url: "https://example.com"}},
+		{"empty destination", &url_service.RedirectRule{Device: deviceIOS}},
+		{"unsafe destination", &url_service.RedirectRule{Device: deviceIOS, Url: "javascript:alert(1)"}},
+	}
+	for _, tt := range tests {
+		if _, err := s.newRedirectRules([]*url_service.RedirectRule{tt.rule}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: got %v, want InvalidArgument", tt.name, err)
+		}
+	}
+	if _, err := s.newRedirectRules(many); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("%d rules: got %v, want InvalidArgument", len(many), err)
+	}
+}
+
+func TestRuleRedirectLookup(t *testing.T) {
+	s, storage, cache := newTestServer(t, map[string]string{
+		"URL_SYNC_PERSIST":    "true",
+		"GEOIP_DB_PATH":       writeTestGeoIPDB(t, testCountries),
+		"TRUST_FORWARDED_FOR": "true",
+	})
+	if _, err := s.ShortenURL(context.Background(), &url_service.ShortenRequest{
+		OriginalUrl: "https://example.com/app",
+		CustomAlias: "app",
+		Rules: []*url_service.RedirectRule{
+			{Device: deviceIOS, Url: "https://apps.apple.com/app"},
+			{Device: deviceAndroid, Url: "https://play.google.com/app"},
+			{Countries: []string{"DE"}, Url: "https://example.de/app"},
+		},
+	}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	if u, _ := storage.url("app"); len(u.Rules) != 3 {
+		t.Fatalf("stored rules %v, want 3", u.Rules)
+	}
+
+	feed, err := s.feed.Subscribe("", "app")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer s.feed.Unsubscribe(feed)
+	tests := []struct {
+		name, userAgent, ip string
+		wantURL, wantRule   string
+	}{
+		{"iPhone in Germany", iPhoneUA, "2.125.170.1", "https://apps.apple.com/app", "1"},
+		{"Android", androidPhoneUA, "216.160.83.56", "https://play.google.com/app", "2"},
+		{"desktop in Germany", windowsUA, "2.125.170.1", "https://example.de/app", "3"},
+		{"desktop elsewhere", windowsUA, "216.160.83.56", "https://example.com/app", ruleDefault},
+		{"no GeoIP data", windowsUA, "10.0.0.1", "https://example.com/app", ruleDefault},
+	}
+	// lookup checks each visitor's destination and the rule its click records
+	lookup := func(layer string) {
+		for _, tt := range tests {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-forwarded-for", tt.ip))
+			resp, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "app", UserAgent: tt.userAgent})
+			if err != nil {
+				t.Fatalf("%s, %s: GetOriginalURL: %v", layer, tt.name, err)
+			}
+			if resp.OriginalUrl != tt.wantURL || resp.Rule != tt.wantRule {
+				t.Errorf("%s, %s: sent to %s by rule %q, want %s by %q", layer, tt.name, resp.OriginalUrl, resp.Rule, tt.wantURL, tt.wantRule)
+			}
+			if click := <-feed.events; click.Rule != tt.wantRule {
+				t.Errorf("%s, %s: click records rule %q, want %q", layer, tt.name, click.Rule, tt.wantRule)
+			}
+		}
+	}
+	lookup("memory")
+
+	// The rules are cached with the link, as one entry
+	waitForCacheEntry(t, cache, "url:app", true)
+	if value, _ := cache.entry("url:app"); !strings.Contains(value, "play.google.com") {
+		t.Errorf("cached %q, want the rules", value)
+	}
+	s.urls.Remove("app")
+	lookup("cache")
+
+	cache.expire("url:app")
+	s.urls.Remove("app")
+	lookup("storage")
+}
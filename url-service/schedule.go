@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// activationSkew lets a link resolve this much before its not_before, so a
+// replica whose clock runs a little behind doesn't turn away the first
+// visitors of a campaign.
+const activationSkew = 5 * time.Second
+
+// isActive reports whether a link that starts at notBefore resolves now.
+func isActive(notBefore time.Time) bool {
+	return notBefore.IsZero() || !time.Now().Add(activationSkew).Before(notBefore)
+}
+
+// linkWindow returns when a new link starts and stops working. not_after
+// ends the link like ttl_seconds does, the earlier of the two winning.
+func linkWindow(req *url_service.ShortenRequest, expiresAt time.Time) (notBefore, end time.Time, err error) {
+	end = expiresAt
+	if req.NotBefore != "" {
+		notBefore, err = time.Parse(time.RFC3339, req.NotBefore)
+		if err != nil {
+			return time.Time{}, time.Time{}, status.Errorf(codes.InvalidArgument, "invalid not_before: %v", err)
+		}
+	}
+	if req.NotAfter != "" {
+		notAfter, err := time.Parse(time.RFC3339, req.NotAfter)
+		if err != nil {
+			return time.Time{}, time.Time{}, status.Errorf(codes.InvalidArgument, "invalid not_after: %v", err)
+		}
+		if !notAfter.After(time.Now()) {
+			return time.Time{}, time.Time{}, status.Error(codes.InvalidArgument, "not_after must be in the future")
+		}
+		if end.IsZero() || notAfter.Before(end) {
+			end = notAfter
+		}
+	}
+	if !notBefore.IsZero() && !end.IsZero() && !notBefore.Before(end) {
+		return time.Time{}, time.Time{}, status.Error(codes.InvalidArgument, "not_before must be before the link expires")
+	}
+	return notBefore, end, nil
+}
+
+// validateFallbacks checks the destinations a new link uses outside its
+// window. A coming soon page needs the not_before it stands in for; any
+// link can have a fallback, since any link can be disabled or deleted.
+func (s *urlServer) validateFallbacks(req *url_service.ShortenRequest, notBefore time.Time) error {
+	if req.FallbackUrl != "" {
+		if err := s.validator.Validate(req.FallbackUrl); err != nil {
+			return err
+		}
+	}
+	if req.ComingSoonUrl != "" {
+		if notBefore.IsZero() {
+			return status.Error(codes.InvalidArgument, "coming_soon_url requires not_before")
+		}
+		if err := s.validator.Validate(req.ComingSoonUrl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notYetActive answers a lookup of a link before its window opens, with
+// its coming soon page if it has one. Such lookups don't count as clicks.
+func notYetActive(ctx context.Context, shortCode string, entry urlEntry) (*url_service.GetOriginalResponse, error) {
+	logf(ctx, "URL %s is not active until %s", shortCode, entry.notBefore.Format(time.RFC3339))
+	if entry.comingSoonURL != "" {
+		return &url_service.GetOriginalResponse{OriginalUrl: entry.comingSoonURL, Found: true, Resolution: resolutionComingSoon}, nil
+	}
+	return nil, status.Error(codes.NotFound, "URL not found")
+}
+
+// expiredResponse answers a lookup of an expired link, redirecting to its
+// fallback if it has one.
+func (s *urlServer) expiredResponse(ctx context.Context, shortCode string, entry urlEntry) *url_service.GetOriginalResponse {
+	if resp := s.fallbackResponse(ctx, shortCode, entry.fallbackURL, resolutionExpired); resp != nil {
+		return resp
+	}
+	return &url_service.GetOriginalResponse{Expired: true, Resolution: resolutionExpired}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsActive(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		notBefore time.Time
+		want      bool
+	}{
+		{"no window", time.Time{}, true},
+		{"started", now.Add(-time.Second), true},
+		{"within the skew", now.Add(activationSkew - time.Second), true},
+		{"past the skew", now.Add(activationSkew + time.Second), false},
+		{"much later", now.Add(time.Hour), false},
+	}
+	for _, tt := range tests {
+		if got := isActive(tt.notBefore); got != tt.want {
+			t.Errorf("%s: isActive = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLinkWindow(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	format := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+	ttl := now.Add(2 * time.Hour)
+	tests := []struct {
+		name          string
+		req           *url_service.ShortenRequest
+		expiresAt     time.Time
+		wantNotBefore time.Time
+		wantEnd       time.Time
+		wantErr       bool
+	}{
+		{"no window", &url_service.ShortenRequest{}, time.Time{}, time.Time{}, time.Time{}, false},
+		{"not_before only", &url_service.ShortenRequest{NotBefore: format(time.Hour)}, time.Time{}, now.Add(time.Hour), time.Time{}, false},
+		{"not_before in the past", &url_service.ShortenRequest{NotBefore: format(-time.Hour)}, time.Time{}, now.Add(-time.Hour), time.Time{}, false},
+		{"not_after before the TTL", &url_service.ShortenRequest{NotAfter: format(time.Hour)}, ttl, time.Time{}, now.Add(time.Hour), false},
+		{"TTL before not_after", &url_service.ShortenRequest{NotAfter: format(3 * time.Hour)}, ttl, time.Time{}, ttl, false},
+		{"full window", &url_service.ShortenRequest{NotBefore: format(time.Hour), NotAfter: format(2 * time.Hour)}, time.Time{}, now.Add(time.Hour), now.Add(2 * time.Hour), false},
+		{"empty window", &url_service.ShortenRequest{NotBefore: format(time.Hour), NotAfter: format(time.Hour)}, time.Time{}, time.Time{}, time.Time{}, true},
+		{"not_before after the TTL", &url_service.ShortenRequest{NotBefore: format(3 * time.Hour)}, ttl, time.Time{}, time.Time{}, true},
+		{"not_after in the past", &url_service.ShortenRequest{NotAfter: format(-time.Second)}, time.Time{}, time.Time{}, time.Time{}, true},
+		{"malformed not_before", &url_service.ShortenRequest{NotBefore: "tomorrow"}, time.Time{}, time.Time{}, time.Time{}, true},
+		{"malformed not_after", &url_service.ShortenRequest{NotAfter: "2030-01-01"}, time.Time{}, time.Time{}, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		notBefore, end, err := linkWindow(tt.req, tt.expiresAt)
+		if tt.wantErr {
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("%s: got %v, want InvalidArgument", tt.name, err)
+			}
+			continue
+		}
+		if err != nil || !notBefore.Equal(tt.wantNotBefore) || !end.Equal(tt.wantEnd) {
+			t.Errorf("%s: linkWindow = %v, %v, %v, want %v, %v", tt.name, notBefore, end, err, tt.wantNotBefore, tt.wantEnd)
+		}
+	}
+}
+
+func TestScheduledLinkBeforeWindow(t *testing.T) {
+	s, storage, cache := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+	ctx := context.Background()
+	notBefore := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	resp, err := s.ShortenURL(ctx, &url_service.ShortenRequest{
+		OriginalUrl:   "https://example.com/launch",
+		CustomAlias:   "launch",
+		NotBefore:     notBefore.Format(time.RFC3339),
+		NotAfter:      notBefore.Add(time.Hour).Format(time.RFC3339),
+		ComingSoonUrl: "https://example.com/soon",
+	})
+	if err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	if u, _ := storage.url("launch"); u.NotBefore != notBefore.Format(time.RFC3339) || u.ComingSoonUrl != "https://example.com/soon" {
+		t.Errorf("storage holds not_before %q and coming soon %q", u.NotBefore, u.ComingSoonUrl)
+	}
+
+	// From memory, then from storage once memory forgets it
+	for _, source := range []string{"memory", "storage"} {
+		got, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: resp.ShortCode})
+		if err != nil || got.OriginalUrl != "https://example.com/soon" || got.Resolution != resolutionComingSoon {
+			t.Errorf("%s: GetOriginalURL = %v, %v, want the coming soon page", source, got, err)
+		}
+		s.urls.Remove("launch")
+	}
+
+	// Neither creating nor looking up a link that isn't live yet caches it
+	s.tasks.Close(ctx)
+	if _, ok := cache.entry("url:launch"); ok {
+		t.Error("cached a link before its window")
+	}
+	if stats, err := s.GetURLStats(ctx, &url_service.StatsRequest{ShortCode: "launch"}); err != nil || stats.ClickCount != 0 {
+		t.Errorf("GetURLStats = %v, %v, want no clicks before the window", stats, err)
+	}
+
+	// Without a coming soon page it doesn't exist yet
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "secret", OriginalUrl: "https://example.com", NotBefore: notBefore.Format(time.RFC3339)})
+	if _, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "secret"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetOriginalURL before the window = %v, want NotFound", err)
+	}
+}
+
+func TestScheduledLinkClockSkew(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	ctx := context.Background()
+
+	// A replica a few seconds behind already serves the campaign
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "soon", OriginalUrl: "https://example.com", NotBefore: time.Now().Add(activationSkew - 2*time.Second).Format(time.RFC3339)})
+	if got, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "soon"}); err != nil || got.OriginalUrl != "https://example.com" {
+		t.Errorf("GetOriginalURL within the skew = %v, %v, want the destination", got, err)
+	}
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "later", OriginalUrl: "https://example.com", NotBefore: time.Now().Add(activationSkew + 5*time.Second).Format(time.RFC3339)})
+	if _, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "later"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetOriginalURL past the skew = %v, want NotFound", err)
+	}
+}
+
+func TestScheduledLinkAfterWindow(t *testing.T) {
+	s, storage, cache := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+	ctx := context.Background()
+
+	// The cache entry never outlives not_after
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{
+		OriginalUrl: "https://example.com/sale",
+		CustomAlias: "sale",
+		NotAfter:    time.Now().Add(90 * time.Second).Format(time.RFC3339),
+	}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	s.tasks.Close(ctx)
+	if ttl := cache.ttl("url:sale"); ttl <= 0 || ttl > 90 {
+		t.Errorf("cache TTL %d, want at most the 90 seconds left", ttl)
+	}
+
+	// Past not_after it is expired, or goes to its fallback
+	ended := time.Now().Add(-time.Second).Format(time.RFC3339)
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "ended", OriginalUrl: "https://example.com", ExpiresAt: ended})
+	if got, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "ended"}); err != nil || !got.Expired || got.OriginalUrl != "" {
+		t.Errorf("GetOriginalURL after the window = %v, %v, want expired", got, err)
+	}
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "moved", OriginalUrl: "https://example.com", ExpiresAt: ended, FallbackUrl: "https://example.com/next"})
+	if got, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "moved"}); err != nil || got.OriginalUrl != "https://example.com/next" || got.Resolution != resolutionExpired {
+		t.Errorf("GetOriginalURL after the window = %v, %v, want the fallback", got, err)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// What ShortenURL does with a destination that is one of our own short
+// links, set with SELF_LINK_POLICY.
+const (
+	selfLinkReject  = "reject"  // turn it down
+	selfLinkResolve = "resolve" // shorten the link's destination instead
+)
+
+const (
+	defaultLinkChainMaxHops = 3
+	defaultLinkChainTimeout = 2 * time.Second
+)
+
+// defaultShorteners are the hosts of public shorteners whose redirects
+// ShortenURL follows to find links leading back to ours. KNOWN_SHORTENERS
+// adds to them.
+var defaultShorteners = []string{
+	"bit.ly", "bitly.com", "buff.ly", "cutt.ly", "goo.gl", "is.gd", "lnkd.in", "ow.ly",
+	"rb.gy", "rebrand.ly", "shorturl.at", "t.co", "t.ly", "tiny.cc", "tinyurl.com",
+}
+
+// selfBase is a base URL short links are served on.
+type selfBase struct {
+	tenant string
+	host   string
+	prefix string // the base URL's path, ending in '/'
+}
+
+// selfLinks recognizes URLs that are short links of this shortener, so a
+// link can't redirect to another, or to itself, and start a loop. A URL is
+// a short link when it is on a tenant's base URL, or at the root of one of
+// SHORTENER_DOMAINS, and its path below that is a single segment that could
+// be a code and isn't reserved, whether or not a link holds it yet: the
+// gateway serves every such path as a code. Anything else on those hosts is
+// a deep link into the site, which is fine to shorten.
+//
+// A destination on a known public shortener is followed, one redirect at
+// a time and for at most LINK_CHAIN_MAX_HOPS redirects, to find short links
+// of ours it leads to as well. Only redirects to other shorteners are
+// followed, and only to public addresses.
+type selfLinks struct {
+	policy     string
+	bases      []selfBase
+	domains    []string // SHORTENER_DOMAINS
+	aliases    *aliasValidator
+	shorteners []string
+	maxHops    int
+	timeout    time.Duration
+	client     *http.Client
+
+	// allow decides which resolved addresses redirects may be followed on
+	allow func(netip.Addr) bool
+}
+
+// newSelfLinks returns the short link recognizer for the tenants' base
+// URLs and cfg. aliases may be nil, when only validating config.
+func newSelfLinks(cfg Config, baseURLs map[string]string, aliases *aliasValidator) *selfLinks {
+	l := &selfLinks{
+		policy:  cfg.SelfLinkPolicy,
+		aliases: aliases,
+		maxHops: cfg.LinkChainMaxHops,
+		timeout: cfg.LinkChainTimeout,
+		allow:   publicAddr,
+	}
+	for tenant, baseURL := range baseURLs {
+		// Base URLs are validated at startup
+		u, err := url.Parse(baseURL)
+		if baseURL == "" || err != nil {
+			continue
+		}
+		l.bases = append(l.bases, selfBase{
+			tenant: tenant,
+			host:   normalizeHost(u.Hostname()),
+			prefix: strings.TrimSuffix(u.Path, "/") + "/",
+		})
+	}
+	sort.Slice(l.bases, func(i, j int) bool { return l.bases[i].tenant < l.bases[j].tenant })
+	l.domains = hostList(cfg.ShortenerDomains)
+	l.shorteners = hostList(append(append([]string{}, defaultShorteners...), cfg.KnownShorteners...))
+
+	dialer := &net.Dialer{
+		Timeout: cfg.LinkChainTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return checkDialAddr(l.allow, address)
+		},
+	}
+	l.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:             nil,
+			DialContext:       dialer.DialContext,
+			DisableKeepAlives: true,
+		},
+		// Each redirect is looked at before it is followed
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return l
+}
+
+// hostList lowercases hosts and drops empty ones.
+func hostList(hosts []string) []string {
+	list := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		if h = normalizeHost(h); h != "" {
+			list = append(list, h)
+		}
+	}
+	return list
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// matchesDomain reports whether host is one of domains or a subdomain of one.
+func matchesDomain(host string, domains []string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// shortLink returns the key of the short link u is, if it is one, looking
+// first at the base URLs, preferring tenant's, and then at the root of
+// SHORTENER_DOMAINS, where codes are taken to be tenant's.
+func (l *selfLinks) shortLink(u *url.URL, tenant string) (string, bool) {
+	host := normalizeHost(u.Hostname())
+	key, found := "", false
+	for _, b := range l.bases {
+		if b.host != host || !strings.HasPrefix(u.Path, b.prefix) {
+			continue
+		}
+		// Below a base URL other paths are deep links, though they may be
+		// codes of another base URL with a longer path
+		shortCode, ok := l.code(strings.TrimPrefix(u.Path, b.prefix))
+		if !ok {
+			continue
+		}
+		if !found || b.tenant == tenant {
+			key, found = tenantKey(b.tenant, shortCode), true
+		}
+	}
+	if found {
+		return key, true
+	}
+	if matchesDomain(host, l.domains) {
+		if shortCode, ok := l.code(strings.TrimPrefix(u.Path, "/")); ok {
+			return tenantKey(tenant, shortCode), true
+		}
+	}
+	return "", false
+}
+
+// code returns the short code segment is, if it could be one. The gateway
+// redirects a code with a trailing slash like the code; anything with '+',
+// as previews have, isn't a code.
+func (l *selfLinks) code(segment string) (string, bool) {
+	segment = strings.TrimSuffix(segment, "/")
+	if segment == "" || len(segment) > maxAliasLength {
+		return "", false
+	}
+	for _, r := range segment {
+		if !isAliasChar(r) {
+			return "", false
+		}
+	}
+	if l.aliases != nil && l.aliases.IsReserved(segment) {
+		return "", false
+	}
+	return segment, true
+}
+
+// nextHop returns where a shortener redirects u to, empty if it doesn't.
+func (l *selfLinks) nextHop(ctx context.Context, u *url.URL) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; url-shortener link checker)")
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	location := resp.Header.Get("Location")
+	if resp.StatusCode < 300 || resp.StatusCode > 399 || location == "" {
+		return "", nil
+	}
+	next, err := u.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect %q: %v", location, err)
+	}
+	return next.String(), nil
+}
+
+// resolveLinkChain returns the URL to shorten for rawURL, which is rawURL
+// itself unless it leads to one of our short links. Such a URL is turned
+// down, or with SELF_LINK_POLICY=resolve replaced by the link's
+// destination; one leading back to newKey, the link being created or
+// updated, or to a code no link holds is always turned down. With follow,
+// redirects of known shorteners are followed too; if they can't be,
+// within LINK_CHAIN_TIMEOUT, the URL is let through.
+func (s *urlServer) resolveLinkChain(ctx context.Context, rawURL, newKey string, follow bool) (string, error) {
+	l := s.selfLinks
+	if follow {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+
+	result, current := rawURL, rawURL
+	seen := make(map[string]bool)
+	for hops := 0; ; {
+		if seen[current] {
+			return "", status.Error(codes.InvalidArgument, "original URL leads to a redirect loop")
+		}
+		seen[current] = true
+		u, err := url.Parse(current)
+		if err != nil || !u.IsAbs() {
+			// Validation turns rawURL down, a redirect is the shortener's
+			// business
+			return result, nil
+		}
+
+		if key, ok := l.shortLink(u, tenantID(ctx)); ok {
+			_, shortCode := splitTenantKey(key)
+			if key == newKey {
+				return "", status.Errorf(codes.InvalidArgument, "original URL leads back to %s itself", shortCode)
+			}
+			if l.policy != selfLinkResolve {
+				return "", status.Errorf(codes.InvalidArgument, "original URL leads to short link %s, shorten its destination instead", shortCode)
+			}
+			destination, expired, err := s.shortCodeHolder(ctx, key)
+			if err != nil {
+				logf(ctx, "Failed to look up own short link %s: %v", shortCode, err)
+				return "", status.Error(codes.Unavailable, "unable to check the original URL, please retry")
+			}
+			if destination == "" || expired {
+				return "", status.Errorf(codes.InvalidArgument, "original URL leads to short link %s, which doesn't exist", shortCode)
+			}
+			logf(ctx, "Original URL %s leads to short link %s, shortening its destination %s", rawURL, shortCode, destination)
+			result, current = destination, destination
+			continue
+		}
+
+		if !follow || hops >= l.maxHops || !matchesDomain(normalizeHost(u.Hostname()), l.shorteners) {
+			return result, nil
+		}
+		next, err := l.nextHop(ctx, u)
+		if err != nil {
+			logf(ctx, "Warning: not following %s for redirect loops: %v", current, err)
+			return result, nil
+		}
+		if next == "" {
+			return result, nil
+		}
+		hops++
+		current = next
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newSelfLinkServer returns a server on https://sho.rt, holding the link
+// "existing" to https://example.com/dest, that follows redirects of
+// shorteners on loopback, and alice's context.
+func newSelfLinkServer(t *testing.T, env map[string]string) (*urlServer, *fakeStorage, context.Context) {
+	t.Helper()
+	base := map[string]string{"URL_SYNC_PERSIST": "true", "BASE_URL": "https://sho.rt", "KNOWN_SHORTENERS": "127.0.0.1"}
+	for k, v := range env {
+		base[k] = v
+	}
+	s, storage, _ := newTestServer(t, base)
+	s.selfLinks.allow = func(netip.Addr) bool { return true }
+	alice := withKey(context.Background(), "alice-key", "alice")
+	if _, err := s.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: "https://example.com/dest", CustomAlias: "existing"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	return s, storage, alice
+}
+
+// serveRedirects serves a shortener redirecting each path of redirects to
+// its location, a path on the server itself when it starts with '/'.
+func serveRedirects(t *testing.T, redirects map[string]string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		location, ok := redirects[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, location, http.StatusMovedPermanently)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// checkRejected fails the test unless err is InvalidArgument mentioning
+// about.
+func checkRejected(t *testing.T, what string, err error, about string) {
+	t.Helper()
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(status.Convert(err).Message(), about) {
+		t.Errorf("%s: got %v, want InvalidArgument about %s", what, err, about)
+	}
+}
+
+func TestSelfLinkRecognition(t *testing.T) {
+	s, _, _ := newTestServer(t, map[string]string{"BASE_URL": "https://sho.rt/go", "SHORTENER_DOMAINS": "short.example", "RESERVED_ALIASES": "promo"})
+	tests := []struct {
+		url  string
+		code string // empty if not a short link
+	}{
+		{"https://sho.rt/go/abc123", "abc123"},
+		{"https://SHO.RT./go/abc123/", "abc123"},
+		{"http://sho.rt/go/abc123?utm_source=x", "abc123"},
+		{"https://short.example/abc", "abc"},
+		{"https://go.short.example/abc", "abc"},
+		// Deep links into the site
+		{"https://sho.rt/go/", ""},
+		{"https://sho.rt/abc123", ""},
+		{"https://sho.rt/go/blog/post", ""},
+		{"https://sho.rt/go/abc123+", ""},
+		{"https://sho.rt/go/promo", ""},
+		{"https://short.example/blog/post", ""},
+		{"https://notshort.example/abc", ""},
+		{"https://example.com/go/abc123", ""},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatalf("parse %s: %v", tt.url, err)
+		}
+		key, ok := s.selfLinks.shortLink(u, "")
+		if ok != (tt.code != "") || key != tt.code {
+			t.Errorf("shortLink(%s) = %q, %v, want %q", tt.url, key, ok, tt.code)
+		}
+	}
+}
+
+func TestSelfLinkRejected(t *testing.T) {
+	s, storage, alice := newSelfLinkServer(t, nil)
+
+	_, err := s.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: "https://sho.rt/existing"})
+	checkRejected(t, "ShortenURL of a short link", err, "shorten its destination")
+	_, err = s.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: "https://sho.rt/selfie", CustomAlias: "selfie"})
+	checkRejected(t, "ShortenURL of the link itself", err, "itself")
+	_, err = s.UpdateURL(alice, &url_service.UpdateURLRequest{ShortCode: "existing", OriginalUrl: "https://sho.rt/existing"})
+	checkRejected(t, "UpdateURL to the link itself", err, "itself")
+	batch, err := s.BatchShorten(alice, &url_service.BatchShortenRequest{Items: []*url_service.ShortenRequest{{OriginalUrl: "https://sho.rt/existing"}}})
+	if err != nil || len(batch.Results) != 1 || codes.Code(batch.Results[0].Code) != codes.InvalidArgument {
+		t.Errorf("BatchShorten of a short link = %v, %v, want it InvalidArgument", batch, err)
+	}
+
+	// Deep links on our domain aren't short links
+	for _, deep := range []string{"https://sho.rt/blog/post", "https://sho.rt/existing+", "https://sho.rt/"} {
+		resp, err := s.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: deep})
+		if err != nil {
+			t.Errorf("ShortenURL(%s): %v", deep, err)
+			continue
+		}
+		if u, _ := storage.url(resp.ShortCode); u.OriginalUrl != deep {
+			t.Errorf("%s stored as %s", deep, u.OriginalUrl)
+		}
+	}
+}
+
+func TestSelfLinkResolved(t *testing.T) {
+	s, storage, alice := newSelfLinkServer(t, map[string]string{"SELF_LINK_POLICY": "resolve"})
+
+	// The link's destination is shortened instead
+	resp, err := s.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: "https://sho.rt/existing"})
+	if err != nil {
+		t.Fatalf("ShortenURL of a short link: %v", err)
+	}
+	if u, _ := storage.url(resp.ShortCode); u.OriginalUrl != "https://example.com/dest" {
+		t.Errorf("short link shortened to %s, want its destination", u.OriginalUrl)
+	}
+	batch, err := s.BatchShorten(alice, &url_service.BatchShortenRequest{Items: []*url_service.ShortenRequest{{OriginalUrl: "https://sho.rt/existing", CustomAlias: "batched"}}})
+	if err != nil || len(batch.Results) != 1 || batch.Results[0].Code != 0 {
+		t.Fatalf("BatchShorten of a short link = %v, %v", batch, err)
+	}
+	if u, _ := storage.url("batched"); u.OriginalUrl != "https://example.com/dest" {
+		t.Errorf("batched short link shortened to %s, want its destination", u.OriginalUrl)
+	}
+
+	// Still never to itself, or to a code no link holds
+	_, err = s.UpdateURL(alice, &url_service.UpdateURLRequest{ShortCode: "existing", OriginalUrl: "https://sho.rt/existing"})
+	checkRejected(t, "UpdateURL to the link itself", err, "itself")
+	_, err = s.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: "https://sho.rt/nosuch"})
+	checkRejected(t, "ShortenURL of a missing short link", err, "doesn't exist")
+
+	// Storage down, the destination can't be looked up
+	s.urls.Remove("existing")
+	storage.mu.Lock()
+	storage.getErr = status.Error(codes.Unavailable, "database is down")
+	storage.mu.Unlock()
+	if _, err := s.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: "https://sho.rt/existing"}); status.Code(err) != codes.Unavailable {
+		t.Errorf("ShortenURL with storage down: got %v, want Unavailable", err)
+	}
+}
+
+func TestLinkChainLoops(t *testing.T) {
+	s, storage, alice := newSelfLinkServer(t, map[string]string{"LINK_CHAIN_MAX_HOPS": "3"})
+	shortener := serveRedirects(t, map[string]string{
+		"/back":  "https://sho.rt/existing",
+		"/twice": "/back",
+		"/loop1": "/loop2",
+		"/loop2": "/loop1",
+		"/away":  "https://example.com/elsewhere",
+		"/1":     "/2",
+		"/2":     "/3",
+		"/3":     "/4",
+		"/4":     "https://sho.rt/existing",
+	})
+
+	tests := []struct {
+		name  string
+		path  string
+		about string // In the message of a rejection, empty if let through
+	}{
+		{"back to us", "/back", "short link existing"},
+		{"back to us in two hops", "/twice", "short link existing"},
+		{"around in a circle", "/loop1", "redirect loop"},
+		{"elsewhere", "/away", ""},
+		{"not redirecting", "/page", ""},
+		// Further than LINK_CHAIN_MAX_HOPS isn't followed
+		{"too many hops", "/1", ""},
+	}
+	for _, tt := range tests {
+		resp, err := s.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: shortener + tt.path})
+		if tt.about != "" {
+			checkRejected(t, tt.name, err, tt.about)
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ShortenURL: %v", tt.name, err)
+			continue
+		}
+		// The URL is kept as given, not where it redirects
+		if u, _ := storage.url(resp.ShortCode); u.OriginalUrl != shortener+tt.path {
+			t.Errorf("%s: stored %s", tt.name, u.OriginalUrl)
+		}
+	}
+
+	// Batches don't follow other shorteners
+	batch, err := s.BatchShorten(alice, &url_service.BatchShortenRequest{Items: []*url_service.ShortenRequest{{OriginalUrl: shortener + "/back"}}})
+	if err != nil || len(batch.Results) != 1 || batch.Results[0].Code != 0 {
+		t.Errorf("BatchShorten of a shortener link = %v, %v, want it shortened", batch, err)
+	}
+
+	// With SELF_LINK_POLICY=resolve the chain ends at the link's destination
+	resolving, storage, alice := newSelfLinkServer(t, map[string]string{"SELF_LINK_POLICY": "resolve"})
+	resp, err := resolving.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: shortener + "/twice"})
+	if err != nil {
+		t.Fatalf("ShortenURL resolving a chain: %v", err)
+	}
+	if u, _ := storage.url(resp.ShortCode); u.OriginalUrl != "https://example.com/dest" {
+		t.Errorf("chain to a short link shortened to %s, want its destination", u.OriginalUrl)
+	}
+
+	// A shortener that can't be reached, or only on an internal address,
+	// lets the URL through
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	if _, err := s.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: down.URL + "/gone"}); err != nil {
+		t.Errorf("ShortenURL through an unreachable shortener: %v, want it let through", err)
+	}
+	s.selfLinks.allow = publicAddr
+	if _, err := s.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: shortener + "/back"}); err != nil {
+		t.Errorf("ShortenURL through a shortener on loopback: %v, want it let through", err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Values of CODE_STRATEGY.
+const (
+	codeStrategyRandom   = "random"
+	codeStrategySequence = "sequence"
+	codeStrategyPool     = "pool"
+	codeStrategyHash     = "hash"
+)
+
+const defaultIDBlockSize = 1000
+
+// idRange is a block of IDs reserved in storage, [start, end).
+type idRange struct {
+	start, end int64
+}
+
+// idAllocator hands out IDs from blocks reserved with AllocateIDRange.
+// Storage never gives two callers overlapping blocks, so IDs are unique
+// across url-service replicas without any further checks. The next block
+// is fetched in the background once a quarter of the current one is left,
+// so a steady stream of requests never waits for storage. IDs left in a
+// block when the process stops are never used.
+type idAllocator struct {
+	allocate  func(ctx context.Context, count int64) (idRange, error)
+	blockSize int64
+
+	mu       sync.Mutex
+	current  idRange
+	spare    *idRange // The prefetched next block
+	fetching bool
+}
+
+func newIDAllocator(blockSize int64, allocate func(ctx context.Context, count int64) (idRange, error)) *idAllocator {
+	return &idAllocator{allocate: allocate, blockSize: blockSize}
+}
+
+// allocateIDs reserves count IDs in storage.
+func (s *urlServer) allocateIDs(ctx context.Context, count int64) (idRange, error) {
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	resp, err := s.storageClient.AllocateIDRange(storageCtx, &storage_service.AllocateIDRangeRequest{Count: count})
+	if err != nil {
+		return idRange{}, err
+	}
+	if resp.End-resp.Start != count {
+		return idRange{}, fmt.Errorf("storage returned %d IDs, want %d", resp.End-resp.Start, count)
+	}
+	return idRange{start: resp.Start, end: resp.End}, nil
+}
+
+// Next returns an ID no other call, here or on another replica, returns.
+// It only waits for storage when the current block ran out before the next
+// one arrived.
+func (a *idAllocator) Next(ctx context.Context) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.current.start == a.current.end {
+		if a.spare != nil {
+			a.current, a.spare = *a.spare, nil
+		} else {
+			// Holding the lock makes concurrent callers wait for this
+			// block instead of each reserving their own
+			block, err := a.allocate(ctx, a.blockSize)
+			if err != nil {
+				return 0, err
+			}
+			a.current = block
+		}
+	}
+
+	id := a.current.start
+	a.current.start++
+
+	if a.current.end-a.current.start <= a.blockSize/4 && a.spare == nil && !a.fetching {
+		a.fetching = true
+		go a.prefetch(detach(ctx))
+	}
+	return id, nil
+}
+
+// prefetch reserves the block after the current one.
+func (a *idAllocator) prefetch(ctx context.Context) {
+	block, err := a.allocate(ctx, a.blockSize)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.fetching = false
+	if err != nil {
+		// Next fetches the block itself when the current one runs out
+		log.Printf("Warning: failed to prefetch ID block: %v", err)
+		return
+	}
+	a.spare = &block
+}
+
+// firstSequenceID is where storage's short_codes range starts.
+const firstSequenceID = 56800235584 // 62^6
+
+// sequenceCode maps id to a code of length+1 characters of alphabet, the
+// first never being alphabet[0], so sequence codes never collide with
+// random codes of the configured length. They only grow a character once
+// every such code is used up. With the default alphabet and length the
+// first ID encodes as "baaaaaa", just like the ID itself in base 62.
+func sequenceCode(id int64, alphabet string, length int) string {
+	// This is offset + base^length in alphabet, built without computing
+	// base^length, which overflows for long codes
+	offset := uint64(id - firstSequenceID)
+	digits := encodeID(offset, alphabet)
+	if len(digits) <= length {
+		return alphabet[1:2] + strings.Repeat(alphabet[:1], length-len(digits)) + digits
+	}
+	base := uint64(len(alphabet))
+	limit := uint64(1)
+	for range length {
+		limit *= base
+	}
+	return encodeID(offset+limit, alphabet)
+}
+
+// encodeID writes n in alphabet, most significant digit first.
+func encodeID(n uint64, alphabet string) string {
+	if n == 0 {
+		return alphabet[:1]
+	}
+	base := uint64(len(alphabet))
+	var b [64]byte
+	i := len(b)
+	for ; n > 0; n /= base {
+		i--
+		b[i] = alphabet[n%base]
+	}
+	return string(b[i:])
+}
+
+// nextSequenceCode encodes the next allocated ID, skipping the rare code
+// that is a reserved word or already known here, such as a custom alias,
+// and returns its key in ctx's tenant. claimed holds the keys taken by
+// earlier items of a batch.
+func (s *urlServer) nextSequenceCode(ctx context.Context, claimed map[string]bool) (string, error) {
+	for attempt := 1; attempt <= maxShortCodeAttempts; attempt++ {
+		id, err := s.ids.Next(ctx)
+		if err != nil {
+			logf(ctx, "Failed to allocate short code ID: %v", err)
+			return "", status.Error(codes.Unavailable, "unable to allocate a short code, please retry")
+		}
+		shortCode := sequenceCode(id, s.codeAlphabet, s.codeLength)
+		key := tenantKey(tenantID(ctx), shortCode)
+		if s.aliases.Validate(shortCode) != nil || claimed[key] || s.urls.Contains(key) {
+			logf(ctx, "Skipping sequence code %s (attempt %d/%d)", shortCode, attempt, maxShortCodeAttempts)
+			continue
+		}
+		return key, nil
+	}
+	return "", status.Error(codes.ResourceExhausted, "could not generate a unique short code")
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+)
+
+func (f *fakeStorage) AllocateIDRange(ctx context.Context, req *storage_service.AllocateIDRangeRequest) (*storage_service.AllocateIDRangeResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.nextID == 0 {
+		f.nextID = firstSequenceID
+	}
+	start := f.nextID
+	f.nextID += req.Count
+	return &storage_service.AllocateIDRangeResponse{Start: start, End: f.nextID}, nil
+}
+
+// blockStore reserves consecutive blocks like storage's AllocateIDRange,
+// failing while err is set.
+type blockStore struct {
+	mu     sync.Mutex
+	next   int64
+	blocks int
+	err    error
+}
+
+func (b *blockStore) allocate(ctx context.Context, count int64) (idRange, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return idRange{}, b.err
+	}
+	b.blocks++
+	start := b.next
+	b.next += count
+	return idRange{start: start, end: b.next}, nil
+}
+
+// allocated returns how many blocks were reserved.
+func (b *blockStore) allocated() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.blocks
+}
+
+// decodeID reverses encodeID.
+func decodeID(code, alphabet string) uint64 {
+	var n uint64
+	for _, c := range code {
+		n = n*uint64(len(alphabet)) + uint64(strings.IndexRune(alphabet, c))
+	}
+	return n
+}
+
+func TestIDAllocatorExhaustion(t *testing.T) {
+	store := &blockStore{}
+	a := newIDAllocator(8, store.allocate)
+	ctx := context.Background()
+
+	// IDs run on across blocks, the next one prefetched before it is needed
+	for want := int64(0); want < 20; want++ {
+		id, err := a.Next(ctx)
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if id != want {
+			t.Fatalf("Next = %d, want %d", id, want)
+		}
+	}
+	// Three blocks, and the fourth prefetched as the third ran low
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		a.mu.Lock()
+		prefetched := a.spare != nil
+		a.mu.Unlock()
+		if prefetched {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("next block not prefetched after 5s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := store.allocated(); n != 4 {
+		t.Errorf("%d blocks reserved for 20 IDs, want 4", n)
+	}
+
+	// Once the block and the prefetched one are used up, failures surface
+	store.mu.Lock()
+	store.err = errors.New("storage down")
+	store.mu.Unlock()
+	var err error
+	for i := 0; i < 16 && err == nil; i++ {
+		_, err = a.Next(ctx)
+	}
+	if err == nil {
+		t.Error("Next kept returning IDs with storage down")
+	}
+}
+
+func TestIDAllocatorConcurrentReplicas(t *testing.T) {
+	// Replicas share storage but each has its own allocator
+	store := &blockStore{}
+	var mu sync.Mutex
+	seen := make(map[int64]bool)
+	var wg sync.WaitGroup
+	for replica := 0; replica < 4; replica++ {
+		a := newIDAllocator(16, store.allocate)
+		for worker := 0; worker < 8; worker++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					id, err := a.Next(context.Background())
+					if err != nil {
+						t.Errorf("Next: %v", err)
+						return
+					}
+					mu.Lock()
+					if seen[id] {
+						t.Errorf("ID %d handed out twice", id)
+					}
+					seen[id] = true
+					mu.Unlock()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	if len(seen) != 3200 {
+		t.Errorf("%d distinct IDs, want 3200", len(seen))
+	}
+}
+
+func TestSequenceCodeRoundTrip(t *testing.T) {
+	if got := sequenceCode(firstSequenceID, shortCodeCharset, defaultShortCodeLength); got != "baaaaaa" {
+		t.Errorf("first sequence code %q, want baaaaaa", got)
+	}
+
+	tests := []struct {
+		name     string
+		alphabet string
+		length   int
+	}{
+		{"default", shortCodeCharset, 6},
+		{"human-safe", alphabetPresets["human-safe"], 6},
+		{"short", shortCodeCharset, 4},
+		{"long", shortCodeCharset, 12},
+		{"digits", "0123456789", 5},
+	}
+	for _, tt := range tests {
+		base := uint64(len(tt.alphabet))
+		limit := uint64(1)
+		for range tt.length {
+			limit *= base
+		}
+		seen := make(map[string]bool)
+		for _, offset := range []int64{0, 1, 61, 62, 1000, 123456789, int64(limit) - 1, int64(limit), int64(limit) * 3} {
+			id := firstSequenceID + offset
+			code := sequenceCode(id, tt.alphabet, tt.length)
+			if len(code) < tt.length+1 || code[0] == tt.alphabet[0] {
+				t.Errorf("%s: code %q for ID %d, want at least %d characters not starting with %c", tt.name, code, id, tt.length+1, tt.alphabet[0])
+			}
+			if got := int64(decodeID(code, tt.alphabet)-limit) + firstSequenceID; got != id {
+				t.Errorf("%s: code %q decodes to %d, want %d", tt.name, code, got, id)
+			}
+			if seen[code] {
+				t.Errorf("%s: code %q repeated", tt.name, code)
+			}
+			seen[code] = true
+		}
+	}
+}
+
+func TestShortenURLSequenceStrategy(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true", "CODE_STRATEGY": "sequence", "ID_BLOCK_SIZE": "4"})
+	ctx := withKey(context.Background(), "alice-key", "alice")
+
+	var codes []string
+	for i := 0; i < 6; i++ {
+		resp, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com"})
+		if err != nil {
+			t.Fatalf("ShortenURL: %v", err)
+		}
+		codes = append(codes, resp.ShortCode)
+	}
+	want := []string{"baaaaaa", "baaaaab", "baaaaac", "baaaaad", "baaaaae", "baaaaaf"}
+	if strings.Join(codes, " ") != strings.Join(want, " ") {
+		t.Errorf("codes %v, want %v", codes, want)
+	}
+	if _, ok := storage.url("baaaaaf"); !ok {
+		t.Error("baaaaaf not saved")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+)
+
+const defaultShutdownTimeout = 15 * time.Second
+
+// shutdown stops advertising the service, drains in-flight RPCs, then flushes
+// background work in dependency order: queued saves before click deltas, so
+// clicks aren't flushed for codes storage hasn't seen yet.
+func shutdown(server *grpc.Server, webServer, httpServer *http.Server, healthServer *health.Server, urlServer *urlServer, stopBackground func(), timeout time.Duration) {
+	log.Printf("Shutting down, draining for up to %s", timeout)
+	deadline := time.Now().Add(timeout)
+
+	// Flip health first so load balancers stop routing new requests here
+	healthServer.Shutdown()
+
+	// Click feed streams would otherwise hold up the drain until the timeout
+	urlServer.feed.Close()
+
+	// gRPC-Web calls run on the HTTP server, which GracefulStop doesn't wait for
+	if webServer != nil {
+		webCtx, webCancel := context.WithDeadline(context.Background(), deadline)
+		if err := webServer.Shutdown(webCtx); err != nil {
+			log.Printf("Warning: failed to drain gRPC-Web calls: %v", err)
+		}
+		webCancel()
+	}
+
+	gracefulStop(server, time.Until(deadline))
+
+	httpCtx, httpCancel := context.WithTimeout(context.Background(), time.Second)
+	if err := httpServer.Shutdown(httpCtx); err != nil {
+		log.Printf("Warning: failed to stop health endpoints: %v", err)
+	}
+	httpCancel()
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	if err := urlServer.tasks.Close(ctx); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	urlServer.persister.Flush(ctx)
+
+	stopBackground()
+	urlServer.clicks.Wait()
+	urlServer.events.Wait()
+
+	if err := urlServer.Close(); err != nil {
+		log.Printf("Warning: failed to close client connections: %v", err)
+	}
+	log.Printf("URL Service stopped")
+}
+
+// gracefulStop waits for in-flight RPCs to finish, forcing the server to stop
+// once timeout elapses.
+func gracefulStop(server *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Warning: drain timed out after %s, forcing stop", timeout)
+		server.Stop()
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "slow", OriginalUrl: "https://example.com/slow"})
+
+	// Lookups of slow hang in storage until released
+	started, release := make(chan struct{}), make(chan struct{})
+	storage.afterGet = func(shortCode string) {
+		if shortCode == "slow" {
+			close(started)
+			<-release
+		}
+	}
+
+	var server *grpc.Server
+	healthServer := health.NewServer()
+	conn := dialBufconn(t, func(srv *grpc.Server) {
+		server = srv
+		url_service.RegisterURLServiceServer(srv, s)
+		grpc_health_v1.RegisterHealthServer(srv, healthServer)
+	})
+	client := url_service.NewURLServiceClient(conn)
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go s.clicks.Run(background)
+
+	// A save waiting for a retry
+	storage.mu.Lock()
+	storage.saveErrs = []error{status.Error(codes.Internal, "disk full")}
+	storage.mu.Unlock()
+	ctx := context.Background()
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com/queued", CustomAlias: "queued"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); !isPending(s.persister, "queued"); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the failed save was never queued")
+		}
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := client.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "slow"})
+		result <- err
+	}()
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		shutdown(server, nil, newHealthHTTPServer("0", s, false), healthServer, s, stopBackground, 5*time.Second)
+		close(done)
+	}()
+
+	// Health flips while the in-flight call still holds up the drain
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		resp, err := healthServer.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		if err == nil && resp.Status == grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("health still %v, %v after 5s", resp, err)
+		}
+	}
+	select {
+	case err := <-result:
+		t.Fatalf("in-flight call returned %v before it was released", err)
+	case <-done:
+		t.Fatal("shutdown finished with a call in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The call completes, then the queued save and its click are flushed
+	close(release)
+	if err := <-result; err != nil {
+		t.Errorf("in-flight GetOriginalURL: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown still running after 5s")
+	}
+	if u, ok := storage.url("queued"); !ok || u.OriginalUrl != "https://example.com/queued" {
+		t.Errorf("storage holds %v for queued, want https://example.com/queued", u)
+	}
+	if n := storage.clicks("slow"); n != 1 {
+		t.Errorf("storage counted %d clicks on slow, want 1", n)
+	}
+}
+
+func TestGracefulStopForcesAfterTimeout(t *testing.T) {
+	started := make(chan struct{})
+	var server *grpc.Server
+	s, storage, _ := newTestServer(t, nil)
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "stuck", OriginalUrl: "https://example.com"})
+	storage.afterGet = func(string) {
+		close(started)
+		time.Sleep(time.Second)
+	}
+	conn := dialBufconn(t, func(srv *grpc.Server) {
+		server = srv
+		url_service.RegisterURLServiceServer(srv, s)
+	})
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := url_service.NewURLServiceClient(conn).GetOriginalURL(context.Background(), &url_service.GetOriginalRequest{ShortCode: "stuck"})
+		result <- err
+	}()
+	<-started
+
+	// The stuck call is cut off instead of holding up the stop
+	start := time.Now()
+	gracefulStop(server, 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("gracefulStop took %v with a 50ms timeout", elapsed)
+	}
+	if err := <-result; status.Code(err) != codes.Unavailable {
+		t.Errorf("stuck call: got %v, want Unavailable", err)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// blockStorage makes the storage calls hook installs count themselves and
+// wait until release is closed. started is closed by the first call.
+func blockStorage(install func(func(string))) (calls *atomic.Int32, started, release chan struct{}) {
+	calls = new(atomic.Int32)
+	started, release = make(chan struct{}), make(chan struct{})
+	install(func(string) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+	})
+	return calls, started, release
+}
+
+func TestConcurrentMissesShareLookup(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "hot", OriginalUrl: "https://example.com"})
+	lookups, started, release := blockStorage(func(hook func(string)) { storage.afterGet = hook })
+
+	const callers = 50
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	var resolved atomic.Int32
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "hot"})
+			if err != nil {
+				t.Errorf("GetOriginalURL: %v", err)
+				return
+			}
+			if resp.OriginalUrl == "https://example.com" {
+				resolved.Add(1)
+			}
+		}()
+	}
+
+	// Let every caller reach the lookup in flight before it returns
+	<-started
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("%d storage lookups for %d concurrent misses, want 1", n, callers)
+	}
+	if n := resolved.Load(); n != callers {
+		t.Errorf("%d callers resolved the code, want %d", n, callers)
+	}
+}
+
+func TestSharedLookupHonoursCallerDeadline(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "slow", OriginalUrl: "https://example.com"})
+	lookups, started, release := blockStorage(func(hook func(string)) { storage.afterGet = hook })
+
+	patient := make(chan error, 1)
+	go func() {
+		_, err := s.GetOriginalURL(context.Background(), &url_service.GetOriginalRequest{ShortCode: "slow"})
+		patient <- err
+	}()
+	<-started
+
+	// A caller with a short deadline gives up alone
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "slow"}); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("impatient caller: got %v, want DeadlineExceeded", err)
+	}
+
+	close(release)
+	if err := <-patient; err != nil {
+		t.Errorf("patient caller: %v", err)
+	}
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("%d storage lookups, want 1", n)
+	}
+}
+
+func TestConcurrentWarmsCollapse(t *testing.T) {
+	s, storage, cache := newTestServer(t, map[string]string{"CACHE_TTL": "1h"})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "warm", OriginalUrl: "https://example.com"})
+	// Without a cached count every warm reads the count from storage
+	stats, started, release := blockStorage(func(hook func(string)) { storage.afterStats = hook })
+
+	const warms = 20
+	var wg sync.WaitGroup
+	for i := 0; i < warms; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.warmCache(context.Background(), "warm", "https://example.com", time.Time{})
+		}()
+	}
+	<-started
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := stats.Load(); n != 1 {
+		t.Errorf("%d storage stats reads for %d concurrent warms, want 1", n, warms)
+	}
+	if value, ok := cache.entry("url:warm"); !ok || value != "https://example.com" {
+		t.Errorf("cache holds %q, %v for warm, want https://example.com", value, ok)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+)
+
+const (
+	defaultMemoryStaleAfter   = 5 * time.Minute
+	defaultMemoryMaxStaleness = 24 * time.Hour
+)
+
+// Memory entries are fresh until MEMORY_STALE_AFTER after storage, the
+// cache or this replica last confirmed them. A stale entry isn't served by
+// itself: the lookup goes on to storage, which refreshes it. Only when
+// storage can't be reached is it served anyway, for up to
+// MEMORY_MAX_STALENESS, while it is revalidated in the background. Links
+// only ever seen in the cache are remembered as stale entries right away,
+// for outages and nothing else.
+
+// fresh reports whether memory can answer for the entry without asking
+// storage.
+func (e urlEntry) fresh(now time.Time) bool {
+	return e.staleAt.IsZero() || now.Before(e.staleAt)
+}
+
+// rememberCached keeps the last known mapping of a code found in the cache,
+// unless memory knows more about it.
+func (s *urlServer) rememberCached(shortCode, originalURL string, routes linkRoutes) {
+	if s.maxStaleness == 0 {
+		return
+	}
+	now := time.Now()
+	s.urls.AddIfAbsent(shortCode, urlEntry{
+		originalURL: originalURL,
+		routes:      routes,
+		refreshedAt: now,
+		staleAt:     now,
+	})
+}
+
+// serveStale answers a lookup with a stale memory entry because storage
+// failed with cause, and starts revalidating it. It returns cause when the
+// entry is too old or is a limited link, whose clicks only storage can
+// count.
+func (s *urlServer) serveStale(ctx context.Context, req *url_service.GetOriginalRequest, entry urlEntry, cause error) (*url_service.GetOriginalResponse, error) {
+	age := time.Since(entry.refreshedAt)
+	if s.maxStaleness == 0 || age > s.maxStaleness || entry.maxClicks > 0 {
+		return nil, cause
+	}
+
+	logf(ctx, "Storage unavailable, serving %s from memory as of %s ago: %v", req.ShortCode, age.Round(time.Second), cause)
+	s.metrics.lookup("stale")
+	s.revalidate(ctx, req.ShortCode)
+
+	if entry.disabled {
+		return s.disabledResponse(ctx, req.ShortCode, entry), nil
+	}
+	if !isActive(entry.notBefore) {
+		return notYetActive(ctx, req.ShortCode, entry)
+	}
+	target := s.destination(ctx, req, entry.originalURL, entry.routes)
+	if !req.SkipStats {
+		s.recordClick(ctx, req, target)
+	}
+	return target.response(), nil
+}
+
+// revalidate reloads a stale entry from storage in the background. Once
+// storage answers, loadFromStorage refreshes memory and lookups find the
+// entry fresh again; until then every stale answer tries once more.
+func (s *urlServer) revalidate(ctx context.Context, shortCode string) {
+	bg := detach(ctx)
+	s.tasks.Submit("revalidate "+shortCode, func() {
+		s.flights.Do("revalidate:"+shortCode, func() (interface{}, error) {
+			if _, _, err := s.loadFromStorage(bg, shortCode); err == nil {
+				logf(bg, "Revalidated %s", shortCode)
+			}
+			return nil, nil
+		})
+	})
+}
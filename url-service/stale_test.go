@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// outage takes the cache and storage down together, or brings them back.
+func outage(storage *fakeStorage, cache *fakeCache, down bool) {
+	storage.mu.Lock()
+	storage.getErr = nil
+	if down {
+		storage.getErr = status.Error(codes.Unavailable, "database is down")
+	}
+	storage.mu.Unlock()
+	cache.mu.Lock()
+	cache.getDelay = 0
+	if down {
+		cache.getDelay = 5 * time.Second
+	}
+	cache.mu.Unlock()
+}
+
+// ageEntry makes shortCode's memory entry stale, last confirmed age ago.
+func ageEntry(t *testing.T, s *urlServer, shortCode string, age time.Duration) {
+	t.Helper()
+	ok := s.urls.Update(shortCode, func(entry *urlEntry) {
+		entry.refreshedAt = time.Now().Add(-age)
+		entry.staleAt = entry.refreshedAt
+	})
+	if !ok {
+		t.Fatalf("%s not in memory", shortCode)
+	}
+}
+
+func TestStaleServing(t *testing.T) {
+	env := map[string]string{"URL_SYNC_PERSIST": "true", "MEMORY_MAX_STALENESS": "1h", "BREAKER_FAILURE_THRESHOLD": "100"}
+	s, storage, cache := newTestServer(t, env)
+	ctx := context.Background()
+	for _, req := range []*url_service.ShortenRequest{
+		{OriginalUrl: "https://example.com/stale", CustomAlias: "stale"},
+		{OriginalUrl: "https://example.com/ancient", CustomAlias: "ancient"},
+		{OriginalUrl: "https://example.com/limited", CustomAlias: "limited", MaxClicks: 10},
+	} {
+		if _, err := s.ShortenURL(ctx, req); err != nil {
+			t.Fatalf("ShortenURL(%s): %v", req.CustomAlias, err)
+		}
+	}
+	ageEntry(t, s, "stale", 10*time.Minute)
+	ageEntry(t, s, "ancient", 2*time.Hour)
+	ageEntry(t, s, "limited", 10*time.Minute)
+
+	outage(storage, cache, true)
+	resp, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "stale"})
+	if err != nil || resp.OriginalUrl != "https://example.com/stale" {
+		t.Errorf("GetOriginalURL during the outage = %v, %v, want the stale mapping", resp, err)
+	}
+	if got := gathered(t, s.metrics.registry, "url_service_lookups_total")["source=stale"]; got != 1 {
+		t.Errorf("stale lookups %v, want 1", got)
+	}
+
+	// Past the bound, and for links whose clicks only storage counts, the
+	// outage shows
+	for _, code := range []string{"ancient", "limited"} {
+		if _, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: code}); err == nil {
+			t.Errorf("GetOriginalURL(%s) during the outage served stale", code)
+		}
+	}
+
+	// Another replica moved the link meanwhile; once storage is back the
+	// background revalidation picks that up
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "stale", OriginalUrl: "https://example.com/moved"})
+	outage(storage, cache, false)
+	s.revalidate(ctx, "stale")
+	waitFor(t, "revalidation", func() bool {
+		entry, ok := s.urls.Get("stale")
+		return ok && entry.fresh(time.Now()) && entry.originalURL == "https://example.com/moved"
+	})
+	cache.expire("url:stale")
+	if resp, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "stale"}); err != nil || resp.OriginalUrl != "https://example.com/moved" {
+		t.Errorf("GetOriginalURL after recovery = %v, %v, want the revalidated mapping", resp, err)
+	}
+}
+
+func TestStaleEntryRevalidatedByLookup(t *testing.T) {
+	s, storage, cache := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+	ctx := context.Background()
+	if _, err := s.ShortenURL(ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com/a", CustomAlias: "aging"}); err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "aging", OriginalUrl: "https://example.com/b"})
+	waitForCacheEntry(t, cache, "url:aging", true)
+	cache.expire("url:aging")
+
+	// A fresh entry answers by itself
+	if resp, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "aging"}); err != nil || resp.OriginalUrl != "https://example.com/a" {
+		t.Errorf("GetOriginalURL while fresh = %v, %v, want memory's mapping", resp, err)
+	}
+
+	// A stale one sends the lookup on to storage, which refreshes it
+	ageEntry(t, s, "aging", 10*time.Minute)
+	waitForCacheEntry(t, cache, "url:aging", true)
+	cache.expire("url:aging")
+	if resp, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "aging"}); err != nil || resp.OriginalUrl != "https://example.com/b" {
+		t.Errorf("GetOriginalURL while stale = %v, %v, want storage's mapping", resp, err)
+	}
+	if entry, ok := s.urls.Get("aging"); !ok || !entry.fresh(time.Now()) || entry.originalURL != "https://example.com/b" {
+		t.Errorf("memory holds %+v after the lookup, want storage's mapping, fresh", entry)
+	}
+}
+
+func TestStaleServingFromCachedLink(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		wantStale bool
+	}{
+		{"remembered", map[string]string{"MEMORY_MAX_STALENESS": "1h", "BREAKER_FAILURE_THRESHOLD": "100"}, true},
+		{"stale serving off", map[string]string{"MEMORY_MAX_STALENESS": "0s", "BREAKER_FAILURE_THRESHOLD": "100"}, false},
+	}
+	for _, tt := range tests {
+		s, storage, cache := newTestServer(t, tt.env)
+		ctx := context.Background()
+		// Only ever seen in the cache, filled by another replica
+		storage.put(&storage_service.SaveURLRequest{ShortCode: "elsewhere", OriginalUrl: "https://example.com"})
+		cache.set("url:elsewhere", "https://example.com")
+		if resp, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "elsewhere"}); err != nil || resp.OriginalUrl != "https://example.com" {
+			t.Fatalf("%s: GetOriginalURL from the cache = %v, %v", tt.name, resp, err)
+		}
+
+		outage(storage, cache, true)
+		resp, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "elsewhere"})
+		if served := err == nil && resp.OriginalUrl == "https://example.com"; served != tt.wantStale {
+			t.Errorf("%s: GetOriginalURL during the outage = %v, %v, want stale serving %v", tt.name, resp, err, tt.wantStale)
+		}
+		if !tt.wantStale && status.Code(err) == codes.NotFound {
+			t.Errorf("%s: outage reported as NotFound", tt.name)
+		}
+	}
+}
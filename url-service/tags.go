@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	maxTagsPerLink = 10
+	maxTagLength   = 32
+)
+
+// normalizeTags trims and lowercases tags, so "Launch " and "launch" are
+// the same tag, and returns them sorted without duplicates. The limit on
+// their number applies after duplicates are dropped.
+func normalizeTags(tags []string) ([]string, error) {
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			return nil, status.Error(codes.InvalidArgument, "tags must not be empty")
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, status.Errorf(codes.InvalidArgument, "tag %q is longer than %d characters", tag, maxTagLength)
+		}
+		if strings.IndexFunc(tag, unicode.IsControl) >= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "tag %q contains a control character", tag)
+		}
+		out = append(out, tag)
+	}
+	slices.Sort(out)
+	out = slices.Compact(out)
+	if len(out) > maxTagsPerLink {
+		return nil, status.Errorf(codes.InvalidArgument, "a link can have at most %d tags", maxTagsPerLink)
+	}
+	return out, nil
+}
+
+// ListTags returns the tags of the caller's links, most used first.
+func (s *urlServer) ListTags(ctx context.Context, req *url_service.ListTagsRequest) (*url_service.ListTagsResponse, error) {
+	logf(ctx, "ListTags request for user: %s", req.UserId)
+
+	user, err := listedUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	resp, err := s.storageClient.ListTags(storageCtx, &storage_service.ListTagsRequest{UserId: user, TenantId: tenantID(ctx)})
+	if err != nil {
+		logf(ctx, "Failed to list tags of %s: %v", user, err)
+		return nil, status.Error(codes.Unavailable, "failed to list tags")
+	}
+
+	tags := make([]*url_service.TagCount, len(resp.Tags))
+	for i, t := range resp.Tags {
+		tags[i] = &url_service.TagCount{Tag: t.Tag, Urls: t.Urls}
+	}
+	return &url_service.ListTagsResponse{Tags: tags}, nil
+}
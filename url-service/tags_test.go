@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ListURLs returns the user's links with all the tags, by code, in one page.
+func (f *fakeStorage) ListURLs(ctx context.Context, req *storage_service.ListURLsRequest) (*storage_service.ListURLsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := &storage_service.ListURLsResponse{}
+	for code, u := range f.urls {
+		if u.UserId != req.UserId || slices.ContainsFunc(req.Tags, func(tag string) bool { return !slices.Contains(u.Tags, tag) }) {
+			continue
+		}
+		resp.Urls = append(resp.Urls, &storage_service.URLSummary{ShortCode: code, OriginalUrl: u.OriginalUrl, Tags: u.Tags, LastAccessedAt: formatOptionalTime(f.lastAccessed[code])})
+	}
+	sort.Slice(resp.Urls, func(i, j int) bool { return resp.Urls[i].ShortCode < resp.Urls[j].ShortCode })
+	return resp, nil
+}
+
+func (f *fakeStorage) ListTags(ctx context.Context, req *storage_service.ListTagsRequest) (*storage_service.ListTagsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	counts := map[string]int64{}
+	for _, u := range f.urls {
+		if u.UserId == req.UserId {
+			for _, tag := range u.Tags {
+				counts[tag]++
+			}
+		}
+	}
+	resp := &storage_service.ListTagsResponse{}
+	for tag, n := range counts {
+		resp.Tags = append(resp.Tags, &storage_service.TagCount{Tag: tag, Urls: n})
+	}
+	sort.Slice(resp.Tags, func(i, j int) bool {
+		a, b := resp.Tags[i], resp.Tags[j]
+		return a.Urls > b.Urls || a.Urls == b.Urls && a.Tag < b.Tag
+	})
+	return resp, nil
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		want []string
+	}{
+		{"none", nil, nil},
+		{"trimmed and lowercased", []string{"  Launch ", "Q3"}, []string{"launch", "q3"}},
+		{"collisions", []string{"launch", "Launch", " LAUNCH", "q3"}, []string{"launch", "q3"}},
+		{"sorted", []string{"zebra", "apple", "mango"}, []string{"apple", "mango", "zebra"}},
+		{"longest", []string{strings.Repeat("é", maxTagLength)}, []string{strings.Repeat("é", maxTagLength)}},
+		// Duplicates don't count against the limit
+		{"eleven collapsing to ten", append(strings.Split("a b c d e f g h i j", " "), "A"), strings.Split("a b c d e f g h i j", " ")},
+	}
+	for _, tt := range tests {
+		got, err := normalizeTags(tt.tags)
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("%s: normalizeTags(%q) = %q, %v, want %q", tt.name, tt.tags, got, err, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		name string
+		tags []string
+	}{
+		{"empty", []string{"launch", ""}},
+		{"blank", []string{"  "}},
+		{"too long", []string{strings.Repeat("x", maxTagLength+1)}},
+		{"control character", []string{"a\tb"}},
+		{"too many", strings.Split("a b c d e f g h i j k", " ")},
+	} {
+		if _, err := normalizeTags(tt.tags); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: got %v, want InvalidArgument", tt.name, err)
+		}
+	}
+}
+
+func TestLinkTags(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true", "DEDUPLICATE_URLS": "true"})
+	alice := withKey(context.Background(), "alice-key", "alice")
+	bob := withKey(context.Background(), "bob-key", "bob")
+	shorten := func(alias, destination string, tags ...string) *url_service.ShortenResponse {
+		t.Helper()
+		resp, err := s.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: destination, CustomAlias: alias, Tags: tags})
+		if err != nil {
+			t.Fatalf("ShortenURL(%s): %v", alias, err)
+		}
+		return resp
+	}
+
+	// Tags are stored and returned normalized
+	resp := shorten("tag1", "https://example.com/1", "Launch", "q3 ", "launch")
+	if !slices.Equal(resp.Tags, []string{"launch", "q3"}) {
+		t.Errorf("ShortenURL returned tags %q, want launch and q3", resp.Tags)
+	}
+	if u, _ := storage.url("tag1"); !slices.Equal(u.Tags, []string{"launch", "q3"}) || !u.SetTags {
+		t.Errorf("stored tags %q, want launch and q3", u.Tags)
+	}
+	shorten("tag2", "https://example.com/2", "LAUNCH")
+	shorten("tag3", "https://example.com/3", "q3")
+	if _, err := s.ShortenURL(bob, &url_service.ShortenRequest{OriginalUrl: "https://example.com/bob", CustomAlias: "bob1", Tags: []string{"launch", "q3"}}); err != nil {
+		t.Fatalf("ShortenURL for bob: %v", err)
+	}
+	if _, err := s.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: "https://example.com/bad", Tags: []string{""}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ShortenURL with an empty tag: got %v, want InvalidArgument", err)
+	}
+
+	// A tagged link is never reused for the same destination
+	again, err := s.ShortenURL(alice, &url_service.ShortenRequest{OriginalUrl: "https://example.com/2", Tags: []string{"other"}})
+	if err != nil || again.ShortCode == "tag2" {
+		t.Errorf("ShortenURL of a tagged destination = %v, %v, want a new link", again, err)
+	}
+	if _, err := s.DeleteURL(alice, &url_service.DeleteURLRequest{ShortCode: again.ShortCode}); err != nil {
+		t.Fatalf("DeleteURL: %v", err)
+	}
+
+	// listed returns alice's links with all the tags
+	listed := func(tags ...string) string {
+		t.Helper()
+		resp, err := s.ListURLs(alice, &url_service.ListURLsRequest{Tags: tags})
+		if err != nil {
+			t.Fatalf("ListURLs by %q: %v", tags, err)
+		}
+		var got []string
+		for _, u := range resp.Urls {
+			got = append(got, fmt.Sprintf("%s%q", u.ShortCode, u.Tags))
+		}
+		return strings.Join(got, " ")
+	}
+	tests := []struct {
+		name string
+		tags []string
+		want string
+	}{
+		{"every link", nil, `tag1["launch" "q3"] tag2["launch"] tag3["q3"]`},
+		{"one tag", []string{"launch"}, `tag1["launch" "q3"] tag2["launch"]`},
+		{"all of two tags", []string{"Q3", " launch"}, `tag1["launch" "q3"]`},
+		{"unused tag", []string{"launch", "nope"}, ``},
+	}
+	for _, tt := range tests {
+		if got := listed(tt.tags...); got != tt.want {
+			t.Errorf("%s: ListURLs = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if _, err := s.ListURLs(alice, &url_service.ListURLsRequest{Tags: []string{strings.Repeat("x", maxTagLength+1)}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListURLs by a tag too long: got %v, want InvalidArgument", err)
+	}
+
+	// Tags change only with set_tags
+	update := func(req *url_service.UpdateURLRequest) error {
+		req.ShortCode = "tag1"
+		if req.OriginalUrl == "" {
+			req.OriginalUrl = "https://example.com/1"
+		}
+		_, err := s.UpdateURL(alice, req)
+		return err
+	}
+	if err := update(&url_service.UpdateURLRequest{Tags: []string{"archive"}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("UpdateURL with tags but not set_tags: got %v, want InvalidArgument", err)
+	}
+	if err := update(&url_service.UpdateURLRequest{OriginalUrl: "https://example.com/moved"}); err != nil {
+		t.Fatalf("UpdateURL: %v", err)
+	}
+	if got := listed("q3"); got != `tag1["launch" "q3"] tag3["q3"]` {
+		t.Errorf("after updating the destination ListURLs by q3 = %s, want the tags kept", got)
+	}
+	if err := update(&url_service.UpdateURLRequest{Tags: []string{"Archive"}, SetTags: true}); err != nil {
+		t.Fatalf("UpdateURL with set_tags: %v", err)
+	}
+	if got := listed("archive"); got != `tag1["archive"]` {
+		t.Errorf("after retagging ListURLs by archive = %s, want tag1", got)
+	}
+	if err := update(&url_service.UpdateURLRequest{SetTags: true}); err != nil {
+		t.Fatalf("UpdateURL clearing tags: %v", err)
+	}
+	if got := listed(); got != `tag1[] tag2["launch"] tag3["q3"]` {
+		t.Errorf("after clearing tags ListURLs = %s, want tag1 untagged", got)
+	}
+
+	tags, err := s.ListTags(alice, &url_service.ListTagsRequest{})
+	if err != nil {
+		t.Fatalf("ListTags: %v", err)
+	}
+	var counts []string
+	for _, tag := range tags.Tags {
+		counts = append(counts, fmt.Sprintf("%s:%d", tag.Tag, tag.Urls))
+	}
+	if want := []string{"launch:1", "q3:1"}; !slices.Equal(counts, want) {
+		t.Errorf("ListTags = %v, want %v", counts, want)
+	}
+	if _, err := s.ListTags(bob, &url_service.ListTagsRequest{UserId: "alice"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("ListTags of another user: got %v, want PermissionDenied", err)
+	}
+	if _, err := s.ListTags(context.Background(), &url_service.ListTagsRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListTags without a user: got %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultAsyncWorkers   = 16
+	defaultAsyncQueueSize = 1024
+)
+
+// Overflow policies for a full task queue:
+//   - block: Submit waits for a free slot, applying backpressure to the caller.
+//   - drop:  Submit discards the task and counts it in Dropped.
+const (
+	overflowBlock = "block"
+	overflowDrop  = "drop"
+)
+
+// taskQueue runs fire-and-forget work (persistence, cache writes) on a fixed
+// number of workers so traffic spikes can't spawn unbounded goroutines.
+type taskQueue struct {
+	tasks   chan func()
+	policy  string
+	dropped atomic.Int64
+	wg      sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newTaskQueue(workers, size int, policy string) (*taskQueue, error) {
+	if workers <= 0 {
+		return nil, fmt.Errorf("invalid worker count: %d", workers)
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("invalid queue size: %d", size)
+	}
+	if policy != overflowBlock && policy != overflowDrop {
+		return nil, fmt.Errorf("invalid overflow policy: %q", policy)
+	}
+
+	q := &taskQueue{
+		tasks:  make(chan func(), size),
+		policy: policy,
+	}
+	q.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.worker()
+	}
+	return q, nil
+}
+
+func (q *taskQueue) worker() {
+	defer q.wg.Done()
+	for task := range q.tasks {
+		task()
+	}
+}
+
+// Submit queues task for execution. It returns false if the task was
+// dropped because the queue is full (drop policy) or already closed.
+func (q *taskQueue) Submit(name string, task func()) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		log.Printf("Warning: task queue closed, dropping %s", name)
+		q.dropped.Add(1)
+		return false
+	}
+
+	if q.policy == overflowBlock {
+		q.tasks <- task
+		return true
+	}
+
+	select {
+	case q.tasks <- task:
+		return true
+	default:
+		dropped := q.dropped.Add(1)
+		log.Printf("Warning: task queue full, dropping %s (%d dropped total)", name, dropped)
+		return false
+	}
+}
+
+// Depth returns the number of queued tasks not yet picked up by a worker.
+func (q *taskQueue) Depth() int {
+	return len(q.tasks)
+}
+
+// Dropped returns the number of tasks discarded so far.
+func (q *taskQueue) Dropped() int64 {
+	return q.dropped.Load()
+}
+
+// Close stops accepting tasks and waits for queued ones to finish, giving up
+// when ctx is done.
+func (q *taskQueue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.tasks)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("task queue drain: %w (%d tasks left)", ctx.Err(), len(q.tasks))
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTaskQueueRunsTasks(t *testing.T) {
+	q, err := newTaskQueue(4, 10, overflowBlock)
+	if err != nil {
+		t.Fatalf("newTaskQueue: %v", err)
+	}
+	var ran atomic.Int64
+	for i := 0; i < 100; i++ {
+		if !q.Submit("count", func() { ran.Add(1) }) {
+			t.Fatal("Submit with the block policy returned false")
+		}
+	}
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if ran.Load() != 100 {
+		t.Errorf("%d tasks ran, want 100", ran.Load())
+	}
+	if q.Submit("late", func() { ran.Add(1) }) || q.Dropped() != 1 {
+		t.Errorf("Submit after Close accepted the task or didn't count it, %d dropped", q.Dropped())
+	}
+}
+
+// stall fills q's only worker with a task that runs until release is
+// closed.
+func stall(t *testing.T, q *taskQueue) (release chan struct{}) {
+	t.Helper()
+	release = make(chan struct{})
+	started := make(chan struct{})
+	q.Submit("stall", func() {
+		close(started)
+		<-release
+	})
+	<-started
+	return release
+}
+
+func TestTaskQueueDropPolicy(t *testing.T) {
+	q, _ := newTaskQueue(1, 2, overflowDrop)
+	release := stall(t, q)
+
+	accepted := []bool{q.Submit("a", func() {}), q.Submit("b", func() {}), q.Submit("c", func() {})}
+	if !accepted[0] || !accepted[1] || accepted[2] {
+		t.Errorf("Submit to a queue of 2 = %v, want the third dropped", accepted)
+	}
+	if q.Dropped() != 1 || q.Depth() != 2 {
+		t.Errorf("Dropped = %d and Depth = %d, want 1 and 2", q.Dropped(), q.Depth())
+	}
+	close(release)
+	q.Close(context.Background())
+}
+
+func TestTaskQueueBlockPolicy(t *testing.T) {
+	q, _ := newTaskQueue(1, 1, overflowBlock)
+	release := stall(t, q)
+	q.Submit("queued", func() {})
+
+	submitted := make(chan struct{})
+	go func() {
+		q.Submit("blocked", func() {})
+		close(submitted)
+	}()
+	select {
+	case <-submitted:
+		t.Fatal("Submit to a full queue returned with the block policy")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-submitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Submit still blocked 5s after the queue had room")
+	}
+	if q.Dropped() != 0 {
+		t.Errorf("%d tasks dropped with the block policy", q.Dropped())
+	}
+	q.Close(context.Background())
+}
+
+func TestTaskQueueCloseDrains(t *testing.T) {
+	q, _ := newTaskQueue(1, 10, overflowBlock)
+	release := stall(t, q)
+	var ran atomic.Int64
+	for i := 0; i < 5; i++ {
+		q.Submit("queued", func() { ran.Add(1) })
+	}
+
+	// A stuck task makes Close give up at its deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := q.Close(ctx); err == nil {
+		t.Error("Close returned nil with a task stuck")
+	}
+
+	// Queued tasks still run once the worker is free
+	close(release)
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if ran.Load() != 5 {
+		t.Errorf("%d queued tasks ran, want 5", ran.Load())
+	}
+}
+
+func TestNewTaskQueueInvalid(t *testing.T) {
+	tests := []struct {
+		workers, size int
+		policy        string
+	}{
+		{0, 10, overflowBlock},
+		{4, -1, overflowBlock},
+		{4, 10, "wait"},
+	}
+	for _, tt := range tests {
+		if _, err := newTaskQueue(tt.workers, tt.size, tt.policy); err == nil {
+			t.Errorf("newTaskQueue(%d, %d, %q) accepted", tt.workers, tt.size, tt.policy)
+		}
+	}
+}
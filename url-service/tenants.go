@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// tenantHeader names the tenant of calls that don't carry a tenant_id, set
+// by the gateway from the Host the request came in on.
+const tenantHeader = "x-tenant-id"
+
+// tenantPattern is what a tenant ID can be. It leaves out ':', which joins
+// it to the codes of the tenant.
+var tenantPattern = regexp.MustCompile(`^[a-z0-9-]{1,20}$`)
+
+// Each tenant has its own short codes: the same code can be a different
+// link in each. Internally a tenant's code is keyed as "<tenant>:<code>",
+// which is what the cache and memory hold and what storage is called with,
+// so everything keyed by code is per tenant without knowing about tenants.
+// Storage splits the key into the tenant_id and short_code of its rows.
+// The default tenant, "", keeps bare codes, as before tenants existed.
+// Codes are qualified by tenantInterceptor as requests come in and stripped
+// again on the way out.
+
+// tenantKey returns the key of code in tenant.
+func tenantKey(tenant, code string) string {
+	if tenant == "" {
+		return code
+	}
+	return tenant + ":" + code
+}
+
+// splitTenantKey returns the tenant and the code of key.
+func splitTenantKey(key string) (tenant, code string) {
+	if tenant, code, ok := strings.Cut(key, ":"); ok {
+		return tenant, code
+	}
+	return "", key
+}
+
+// tenantSet holds the tenants from the comma separated TENANTS list of "id"
+// or "id=base_url" entries, mapped to the base URL their short links are
+// built on. The default tenant uses BASE_URL.
+type tenantSet struct {
+	baseURLs map[string]string
+}
+
+func parseTenants(list, defaultBaseURL string) (*tenantSet, error) {
+	t := &tenantSet{baseURLs: map[string]string{"": defaultBaseURL}}
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, baseURL, _ := strings.Cut(entry, "=")
+		if !tenantPattern.MatchString(id) {
+			return nil, fmt.Errorf("tenant %q must be 1 to 20 lowercase letters, digits or '-'", id)
+		}
+		if _, dup := t.baseURLs[id]; dup {
+			return nil, fmt.Errorf("duplicate tenant %q", id)
+		}
+		if baseURL != "" {
+			if err := validateBaseURL(baseURL); err != nil {
+				return nil, fmt.Errorf("tenant %q: %v", id, err)
+			}
+		}
+		t.baseURLs[id] = baseURL
+	}
+	return t, nil
+}
+
+// Known reports whether tenant is configured.
+func (t *tenantSet) Known(tenant string) bool {
+	_, ok := t.baseURLs[tenant]
+	return ok
+}
+
+type tenantCtxKey struct{}
+
+// tenantID returns the tenant of the call ctx belongs to.
+func tenantID(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantCtxKey{}).(string)
+	return tenant
+}
+
+// resolve picks the tenant of a call: the one its API key is bound to, else
+// the tenant_id of the request, else the gateway's header, else the default
+// tenant. A key bound to a tenant can't be used for another.
+func (t *tenantSet) resolve(ctx context.Context, requested string) (string, error) {
+	if bound := apiKeyTenant(ctx); bound != "" {
+		if requested != "" && requested != bound {
+			return "", status.Errorf(codes.PermissionDenied, "API key is not valid for tenant %q", requested)
+		}
+		return bound, nil
+	}
+	if requested == "" {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(tenantHeader); len(values) > 0 {
+				requested = values[0]
+			}
+		}
+	}
+	if !t.Known(requested) {
+		return "", status.Errorf(codes.InvalidArgument, "unknown tenant %q", requested)
+	}
+	return requested, nil
+}
+
+// tenantRequest is a request that can name its tenant.
+type tenantRequest interface {
+	GetTenantId() string
+}
+
+// enter resolves the tenant of a call and qualifies the codes of its
+// request.
+func (t *tenantSet) enter(ctx context.Context, req interface{}) (context.Context, error) {
+	var requested string
+	if r, ok := req.(tenantRequest); ok {
+		requested = r.GetTenantId()
+	}
+	tenant, err := t.resolve(ctx, requested)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := req.(proto.Message); ok {
+		// A ':' would reach into another tenant's codes
+		var invalid bool
+		rewriteShortCodes(m.ProtoReflect(), func(code string) string {
+			invalid = invalid || strings.Contains(code, ":")
+			return tenantKey(tenant, code)
+		})
+		if invalid {
+			return nil, status.Error(codes.InvalidArgument, "short code must not contain ':'")
+		}
+	}
+	return context.WithValue(ctx, tenantCtxKey{}, tenant), nil
+}
+
+// leave strips the tenant from the codes of a response. Responses can share
+// messages with what the server keeps, so a copy is rewritten.
+func leave(ctx context.Context, resp interface{}) interface{} {
+	m, ok := resp.(proto.Message)
+	if !ok || tenantID(ctx) == "" {
+		return resp
+	}
+	m = proto.Clone(m)
+	rewriteShortCodes(m.ProtoReflect(), func(key string) string {
+		_, code := splitTenantKey(key)
+		return code
+	})
+	return m
+}
+
+// rewriteShortCodes replaces the short_code and short_codes fields of m and
+// of the messages in it with what rewrite returns for them.
+func rewriteShortCodes(m protoreflect.Message, rewrite func(string) string) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.Name() == "short_code" && fd.Kind() == protoreflect.StringKind && !fd.IsList():
+			if code := v.String(); code != "" {
+				m.Set(fd, protoreflect.ValueOfString(rewrite(code)))
+			}
+		case fd.Name() == "short_codes" && fd.Kind() == protoreflect.StringKind && fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				list.Set(i, protoreflect.ValueOfString(rewrite(list.Get(i).String())))
+			}
+		case fd.Kind() == protoreflect.MessageKind && fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				rewriteShortCodes(list.Get(i).Message(), rewrite)
+			}
+		case fd.Kind() == protoreflect.MessageKind && !fd.IsMap():
+			rewriteShortCodes(v.Message(), rewrite)
+		}
+		return true
+	})
+}
+
+// tenantInterceptor runs each call in its tenant. It comes after
+// authentication, which tells the tenant the API key is bound to.
+func tenantInterceptor(t *tenantSet) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := t.enter(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return leave(ctx, resp), nil
+	}
+}
+
+// tenantStreamInterceptor is tenantInterceptor for streams, whose request
+// is only read by the handler.
+func tenantStreamInterceptor(t *tenantSet) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &tenantStream{ServerStream: ss, tenants: t, ctx: ss.Context()})
+	}
+}
+
+type tenantStream struct {
+	grpc.ServerStream
+	tenants *tenantSet
+	ctx     context.Context
+}
+
+func (s *tenantStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *tenantStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	ctx, err := s.tenants.enter(s.ServerStream.Context(), m)
+	if err != nil {
+		return err
+	}
+	s.ctx = ctx
+	return nil
+}
+
+func (s *tenantStream) SendMsg(m interface{}) error {
+	return s.ServerStream.SendMsg(leave(s.ctx, m))
+}
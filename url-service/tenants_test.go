@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// callInTenant runs a call through tenantInterceptor, as the server does.
+func callInTenant[Req, Resp any](ctx context.Context, s *urlServer, req Req, method func(context.Context, Req) (Resp, error)) (Resp, error) {
+	resp, err := tenantInterceptor(s.tenants)(ctx, req, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return method(ctx, req.(Req))
+	})
+	if err != nil {
+		var zero Resp
+		return zero, err
+	}
+	return resp.(Resp), nil
+}
+
+func TestTenantsShareCodesWithoutCrosstalk(t *testing.T) {
+	s, storage, cache := newTestServer(t, map[string]string{
+		"URL_SYNC_PERSIST": "true",
+		"BASE_URL":         "https://sho.rt",
+		"TENANTS":          "acme=https://acme.example/go,globex=https://globex.example",
+	})
+	links := []struct {
+		tenant, destination, shortURL string
+	}{
+		{"", "https://default.example", "https://sho.rt/promo"},
+		{"acme", "https://shop.acme.test/sale", "https://acme.example/go/promo"},
+		{"globex", "https://shop.globex.test/sale", "https://globex.example/promo"},
+	}
+
+	for _, l := range links {
+		ctx := withKey(context.Background(), "key-"+l.tenant, "user")
+		resp, err := callInTenant(ctx, s, &url_service.ShortenRequest{TenantId: l.tenant, OriginalUrl: l.destination, CustomAlias: "promo"}, s.ShortenURL)
+		if err != nil {
+			t.Fatalf("tenant %q: ShortenURL: %v", l.tenant, err)
+		}
+		if resp.ShortCode != "promo" || resp.ShortUrl != l.shortURL {
+			t.Errorf("tenant %q: ShortenURL = %s %s, want promo %s", l.tenant, resp.ShortCode, resp.ShortUrl, l.shortURL)
+		}
+	}
+
+	for _, l := range links {
+		if u, ok := storage.url(tenantKey(l.tenant, "promo")); !ok || u.OriginalUrl != l.destination {
+			t.Errorf("tenant %q: storage holds %v, want %s", l.tenant, u, l.destination)
+		}
+		// Through the gateway's header, and again once it is cached
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tenantHeader, l.tenant))
+		for i := 0; i < 2; i++ {
+			resp, err := callInTenant(ctx, s, &url_service.GetOriginalRequest{ShortCode: "promo"}, s.GetOriginalURL)
+			if err != nil || resp.OriginalUrl != l.destination {
+				t.Errorf("tenant %q: GetOriginalURL = %v, %v, want %s", l.tenant, resp, err, l.destination)
+			}
+		}
+	}
+	cache.mu.Lock()
+	for _, l := range links {
+		if key := "url:" + tenantKey(l.tenant, "promo"); cache.entries[key] != l.destination {
+			t.Errorf("cache has %q under %s, want %s", cache.entries[key], key, l.destination)
+		}
+	}
+	cache.mu.Unlock()
+
+	// A code only one tenant has is missing from the others
+	storage.put(&storage_service.SaveURLRequest{ShortCode: tenantKey("acme", "only"), OriginalUrl: "https://acme.example/only"})
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tenantHeader, "globex"))
+	if _, err := callInTenant(ctx, s, &url_service.GetOriginalRequest{ShortCode: "only"}, s.GetOriginalURL); status.Code(err) != codes.NotFound {
+		t.Errorf("globex GetOriginalURL of acme's code: %v, want NotFound", err)
+	}
+}
+
+func TestTenantResolve(t *testing.T) {
+	tenants, err := parseTenants("acme,globex", "https://sho.rt")
+	if err != nil {
+		t.Fatalf("parseTenants: %v", err)
+	}
+	bound := context.WithValue(context.Background(), apiKeyCtxKey{}, apiKey{id: "k", user: "u", tenant: "acme"})
+	header := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tenantHeader, "globex"))
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		requested string
+		want      string
+		code      codes.Code
+	}{
+		{"default", context.Background(), "", "", codes.OK},
+		{"requested", context.Background(), "globex", "globex", codes.OK},
+		{"header", header, "", "globex", codes.OK},
+		{"request over header", header, "acme", "acme", codes.OK},
+		{"bound key", bound, "", "acme", codes.OK},
+		{"bound key, same tenant", bound, "acme", "acme", codes.OK},
+		{"bound key, other tenant", bound, "globex", "", codes.PermissionDenied},
+		{"unknown", context.Background(), "initech", "", codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		got, err := tenants.resolve(tt.ctx, tt.requested)
+		if status.Code(err) != tt.code || got != tt.want {
+			t.Errorf("%s: resolve = %q, %v, want %q, %s", tt.name, got, err, tt.want, tt.code)
+		}
+	}
+}
+
+func TestTenantRewritesShortCodes(t *testing.T) {
+	tenants, _ := parseTenants("acme", "https://sho.rt")
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tenantHeader, "acme"))
+
+	getReq := &url_service.GetOriginalRequest{ShortCode: "promo"}
+	ctx, err := tenants.enter(ctx, getReq)
+	if err != nil || getReq.ShortCode != "acme:promo" || tenantID(ctx) != "acme" {
+		t.Fatalf("enter = %q in tenant %q, %v, want acme:promo in acme", getReq.ShortCode, tenantID(ctx), err)
+	}
+
+	// The way out strips the tenant from a copy, nested messages included
+	resp := &url_service.BatchShortenResponse{Results: []*url_service.BatchShortenResult{
+		{Url: &url_service.ShortenResponse{ShortCode: "acme:a"}},
+		{Code: int32(codes.InvalidArgument)},
+		{Url: &url_service.ShortenResponse{ShortCode: "acme:b"}},
+	}}
+	out := leave(ctx, resp).(*url_service.BatchShortenResponse)
+	if out.Results[0].Url.ShortCode != "a" || out.Results[2].Url.ShortCode != "b" || resp.Results[0].Url.ShortCode != "acme:a" {
+		t.Errorf("leave = %v from %v", out, resp)
+	}
+
+	if _, err := tenants.enter(ctx, &url_service.GetOriginalRequest{ShortCode: "globex:promo"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("enter with a ':' in the code: %v, want InvalidArgument", err)
+	}
+}
+
+func TestParseTenants(t *testing.T) {
+	for _, list := range []string{"Acme", "acme,acme", "a:b", "acme=ftp://acme.example", "this-name-is-far-too-long"} {
+		if _, err := parseTenants(list, "https://sho.rt"); err == nil {
+			t.Errorf("parseTenants(%q) accepted", list)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultTopURLs = 50
+	maxTopURLs     = 1000
+)
+
+// GetTopURLs returns the most clicked URLs of the caller's tenant, all time
+// or since a given time.
+// Counts are as flushed to storage, so the ranking is consistent.
+func (s *urlServer) GetTopURLs(ctx context.Context, req *url_service.GetTopURLsRequest) (*url_service.GetTopURLsResponse, error) {
+	logf(ctx, "GetTopURLs request for %d URLs since %q", req.Limit, req.Since)
+
+	limit := req.Limit
+	if limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+	if limit == 0 {
+		limit = defaultTopURLs
+	}
+	limit = min(limit, maxTopURLs)
+
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	resp, err := s.storageClient.GetTopURLs(storageCtx, &storage_service.GetTopURLsRequest{
+		Limit:    limit,
+		OrderBy:  "clicks",
+		Since:    req.Since,
+		TenantId: tenantID(ctx),
+	})
+	if status.Code(err) == codes.InvalidArgument {
+		return nil, err
+	} else if err != nil {
+		logf(ctx, "Failed to get top URLs: %v", err)
+		return nil, status.Error(codes.Unavailable, "failed to get top URLs")
+	}
+
+	urls := make([]*url_service.URLSummary, 0, len(resp.Urls))
+	for _, u := range resp.Urls {
+		urls = append(urls, &url_service.URLSummary{
+			ShortCode:   u.ShortCode,
+			OriginalUrl: u.OriginalUrl,
+			ClickCount:  u.ClickCount,
+			CreatedAt:   u.CreatedAt,
+			ExpiresAt:   u.ExpiresAt,
+		})
+	}
+	return &url_service.GetTopURLsResponse{Urls: urls}, nil
+}
+
+// GetGlobalStats returns totals across every URL of the caller's tenant.
+func (s *urlServer) GetGlobalStats(ctx context.Context, req *url_service.GetGlobalStatsRequest) (*url_service.GetGlobalStatsResponse, error) {
+	storageCtx, cancel := s.storageCtx(ctx)
+	defer cancel()
+	resp, err := s.storageClient.GetGlobalStats(storageCtx, &storage_service.GetGlobalStatsRequest{TenantId: tenantID(ctx)})
+	if err != nil {
+		logf(ctx, "Failed to get global stats: %v", err)
+		return nil, status.Error(codes.Unavailable, "failed to get global stats")
+	}
+
+	return &url_service.GetGlobalStatsResponse{
+		TotalUrls:      resp.TotalUrls,
+		TotalClicks:    resp.TotalClicks,
+		CreatedLastDay: resp.CreatedLastDay,
+		ActiveUrls:     resp.ActiveUrls,
+	}, nil
+}
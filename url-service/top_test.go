@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGetTopURLs(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	storage.topURLs = []*storage_service.URLSummary{
+		{ShortCode: "first", OriginalUrl: "https://example.com/1", ClickCount: 9, CreatedAt: fakeCreatedAt},
+		{ShortCode: "second", OriginalUrl: "https://example.com/2", ClickCount: 4, CreatedAt: fakeCreatedAt},
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		limit     int32
+		wantLimit int32
+	}{
+		{"default", 0, defaultTopURLs},
+		{"given", 5, 5},
+		{"capped", 5000, maxTopURLs},
+	}
+	for _, tt := range tests {
+		resp, err := s.GetTopURLs(ctx, &url_service.GetTopURLsRequest{Limit: tt.limit, Since: "2024-01-01T00:00:00Z"})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(resp.Urls) != 2 || resp.Urls[0].ShortCode != "first" || resp.Urls[0].ClickCount != 9 {
+			t.Errorf("%s: got %v, want first then second", tt.name, resp.Urls)
+		}
+		storage.mu.Lock()
+		req := storage.topReqs[len(storage.topReqs)-1]
+		storage.mu.Unlock()
+		if req.Limit != tt.wantLimit || req.OrderBy != "clicks" || req.Since != "2024-01-01T00:00:00Z" {
+			t.Errorf("%s: storage asked for %v, want %d by clicks since 2024-01-01", tt.name, req, tt.wantLimit)
+		}
+	}
+
+	if _, err := s.GetTopURLs(ctx, &url_service.GetTopURLsRequest{Limit: -1}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("negative limit: got %v, want InvalidArgument", err)
+	}
+}
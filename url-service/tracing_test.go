@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+)
+
+var (
+	spansOnce sync.Once
+	spans     *tracetest.SpanRecorder
+)
+
+// recordSpans installs a tracer provider recording every span for the rest
+// of the test binary. It is installed once: tracers and handlers created
+// after that keep the provider they were created with.
+func recordSpans() *tracetest.SpanRecorder {
+	spansOnce.Do(func() {
+		spans = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	return spans
+}
+
+func TestAsyncWorkJoinsRequestTrace(t *testing.T) {
+	spans := recordSpans()
+	s, storage, _ := newTestServer(t, nil)
+	conn := dialBufconn(t, func(srv *grpc.Server) { url_service.RegisterURLServiceServer(srv, s) }, grpc.StatsHandler(serverTracing()))
+	before := len(spans.Ended())
+
+	resp, err := url_service.NewURLServiceClient(conn).ShortenURL(context.Background(), &url_service.ShortenRequest{OriginalUrl: "https://example.com"})
+	if err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	s.tasks.Close(context.Background())
+
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range spans.Ended()[before:] {
+		byName[span.Name()] = span
+	}
+	shorten, ok := byName[url_service.URLService_ServiceDesc.ServiceName+"/ShortenURL"]
+	if !ok {
+		t.Fatalf("no ShortenURL span among %v", byName)
+	}
+
+	// The save and the cache write run after the RPC returned, as its children
+	for _, name := range []string{"storage.StorageService/SaveURL", "cache.CacheService/MSet"} {
+		span, ok := byName[name]
+		if !ok {
+			t.Errorf("no %s span", name)
+			continue
+		}
+		if span.Parent().SpanID() != shorten.SpanContext().SpanID() || span.SpanContext().TraceID() != shorten.SpanContext().TraceID() {
+			t.Errorf("%s has parent %s, want the ShortenURL span %s", name, span.Parent().SpanID(), shorten.SpanContext().SpanID())
+		}
+	}
+
+	// Storage is handed the trace context
+	traceparent := storage.savedWith(resp.ShortCode).Get("traceparent")
+	if len(traceparent) != 1 || !strings.Contains(traceparent[0], shorten.SpanContext().TraceID().String()) {
+		t.Errorf("save carried traceparent %v, want trace %s", traceparent, shorten.SpanContext().TraceID())
+	}
+}
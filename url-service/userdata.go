@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// userDataPurgeConcurrency bounds the cache deletes DeleteUserData runs at
+// once, a user can have many links.
+const userDataPurgeConcurrency = 16
+
+// DeleteUserData erases a user's data for a data protection request: storage
+// soft deletes their links and clears the visitor details of the clicks on
+// them, auditing the request, then every link's cache entries and this
+// replica's copy are dropped. It can be repeated until cache_failed is 0,
+// storage only changes what is left to change.
+func (s *urlServer) DeleteUserData(ctx context.Context, req *url_service.DeleteUserDataRequest) (*url_service.DeleteUserDataResponse, error) {
+	logf(ctx, "DeleteUserData request for user: %s", req.UserId)
+
+	if err := s.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	// The deletion carries on if the caller gives up partway
+	ctx = detach(ctx)
+	storageCtx, cancel := s.storageCtx(ctx)
+	deleted, err := s.storageClient.DeleteUserData(storageCtx, &storage_service.DeleteUserDataRequest{
+		UserId:   req.UserId,
+		TenantId: tenantID(ctx),
+	})
+	cancel()
+	if err != nil {
+		logf(ctx, "Failed to delete user data from storage: %v", err)
+		return nil, status.Error(codes.Unavailable, "failed to delete user data")
+	}
+	for _, shortCode := range deleted.DeletedShortCodes {
+		s.publishDeleted(shortCode)
+	}
+
+	var purged, failed atomic.Int64
+	var g errgroup.Group
+	g.SetLimit(userDataPurgeConcurrency)
+	for _, shortCode := range deleted.ShortCodes {
+		g.Go(func() error {
+			err := s.invalidateCache(ctx, shortCode)
+			if memErr := s.purgeMemory(shortCode); err == nil {
+				err = memErr
+			}
+			if err != nil {
+				logf(ctx, "Failed to purge %s: %v", shortCode, err)
+				failed.Add(1)
+			} else {
+				purged.Add(1)
+			}
+			return nil
+		})
+	}
+	g.Wait()
+
+	resp := &url_service.DeleteUserDataResponse{
+		UserId:         req.UserId,
+		DeletedUrls:    deleted.DeletedUrls,
+		ScrubbedClicks: deleted.ScrubbedClicks,
+		CachePurged:    purged.Load(),
+		CacheFailed:    failed.Load(),
+	}
+	logf(ctx, "Data of user %s deleted: %d links deleted, %d clicks scrubbed, cache purged for %d, failed for %d",
+		req.UserId, resp.DeletedUrls, resp.ScrubbedClicks, resp.CachePurged, resp.CacheFailed)
+	return resp, nil
+}
+
+// userDataLink is a link in an ExportUserData chunk.
+type userDataLink struct {
+	ShortCode   string `json:"short_code"`
+	OriginalURL string `json:"original_url"`
+	CreatedAt   string `json:"created_at"`
+	ExpiresAt   string `json:"expires_at,omitempty"`
+	DeletedAt   string `json:"deleted_at,omitempty"`
+	ClickCount  int64  `json:"click_count"`
+}
+
+// userDataPage is the chunk of each page of links.
+type userDataPage struct {
+	Type string         `json:"type"` // urls
+	URLs []userDataLink `json:"urls"`
+}
+
+// userDataSummary is the last chunk of an export.
+type userDataSummary struct {
+	Type        string `json:"type"` // summary
+	UserID      string `json:"user_id"`
+	TenantID    string `json:"tenant_id,omitempty"`
+	URLs        int64  `json:"urls"`
+	DeletedURLs int64  `json:"deleted_urls"`
+	Clicks      int64  `json:"clicks"`
+	ExportedAt  string `json:"exported_at"`
+}
+
+// ExportUserData streams a user's links, deleted ones included, as they are
+// read from storage, then their totals, each as a JSON chunk. It only reads,
+// so an interrupted export is simply started again.
+func (s *urlServer) ExportUserData(req *url_service.ExportUserDataRequest, stream url_service.URLService_ExportUserDataServer) error {
+	ctx := stream.Context()
+	logf(ctx, "ExportUserData request for user: %s", req.UserId)
+
+	if err := s.checkAdmin(ctx); err != nil {
+		return err
+	}
+	if req.UserId == "" {
+		return status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	export, err := s.storageClient.ExportURLs(outgoingContext(ctx), &storage_service.ExportURLsRequest{
+		UserId:   req.UserId,
+		TenantId: tenantID(ctx),
+	})
+	if err != nil {
+		logf(ctx, "Failed to export user data from storage: %v", err)
+		return status.Error(codes.Unavailable, "failed to export user data")
+	}
+
+	summary := userDataSummary{Type: "summary", UserID: req.UserId, TenantID: tenantID(ctx)}
+	for {
+		page, err := export.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			logf(ctx, "User data export stopped after %d links: %v", summary.URLs, err)
+			if ctx.Err() != nil {
+				return status.FromContextError(ctx.Err()).Err()
+			}
+			return status.Error(codes.Unavailable, "failed to export user data")
+		}
+
+		chunk := userDataPage{Type: "urls", URLs: make([]userDataLink, len(page.Urls))}
+		for i, u := range page.Urls {
+			_, shortCode := splitTenantKey(u.ShortCode)
+			chunk.URLs[i] = userDataLink{
+				ShortCode:   shortCode,
+				OriginalURL: u.OriginalUrl,
+				CreatedAt:   u.CreatedAt,
+				ExpiresAt:   u.ExpiresAt,
+				DeletedAt:   u.DeletedAt,
+				ClickCount:  u.ClickCount,
+			}
+			summary.URLs++
+			summary.Clicks += u.ClickCount
+			if u.DeletedAt != "" {
+				summary.DeletedURLs++
+			}
+		}
+		if err := sendUserData(stream, chunk); err != nil {
+			return err
+		}
+	}
+
+	summary.ExportedAt = time.Now().UTC().Format(time.RFC3339)
+	logf(ctx, "Exported %d links of user %s", summary.URLs, req.UserId)
+	return sendUserData(stream, summary)
+}
+
+func sendUserData(stream url_service.URLService_ExportUserDataServer, chunk interface{}) error {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to encode user data: %v", err)
+	}
+	return stream.Send(&url_service.ExportUserDataChunk{Data: data})
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"sort"
+	"testing"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DeleteUserData soft deletes the user's URLs, returning every code the
+// user ever had. The fake records no clicks to scrub.
+func (f *fakeStorage) DeleteUserData(ctx context.Context, req *storage_service.DeleteUserDataRequest) (*storage_service.DeleteUserDataResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := &storage_service.DeleteUserDataResponse{}
+	for code, u := range f.urls {
+		if u.UserId == req.UserId {
+			f.deleted[code] = u
+			delete(f.urls, code)
+			resp.DeletedShortCodes = append(resp.DeletedShortCodes, code)
+		}
+	}
+	for code, u := range f.deleted {
+		if u.UserId == req.UserId {
+			resp.ShortCodes = append(resp.ShortCodes, code)
+		}
+	}
+	sort.Strings(resp.ShortCodes)
+	resp.DeletedUrls = int64(len(resp.DeletedShortCodes))
+	return resp, nil
+}
+
+func TestDeleteUserData(t *testing.T) {
+	// The breaker would open on the failing deletes
+	s, storage, cache := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true", "ADMIN_USERS": "root", "BREAKER_FAILURE_THRESHOLD": "100"})
+	alice := withKey(context.Background(), "alice-key", "alice")
+	bob := withKey(context.Background(), "bob-key", "bob")
+	root := withKey(context.Background(), "root-key", "root")
+	for _, link := range []struct {
+		ctx   context.Context
+		alias string
+	}{{alice, "alice1"}, {alice, "alice2"}, {bob, "bob1"}} {
+		if _, err := s.ShortenURL(link.ctx, &url_service.ShortenRequest{OriginalUrl: "https://example.com/" + link.alias, CustomAlias: link.alias}); err != nil {
+			t.Fatalf("ShortenURL(%s): %v", link.alias, err)
+		}
+		waitForCacheEntry(t, cache, "url:"+link.alias, true)
+	}
+
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		req  *url_service.DeleteUserDataRequest
+		want codes.Code
+	}{
+		{"anonymous", context.Background(), &url_service.DeleteUserDataRequest{UserId: "alice"}, codes.Unauthenticated},
+		{"not an admin", alice, &url_service.DeleteUserDataRequest{UserId: "alice"}, codes.PermissionDenied},
+		{"no user", root, &url_service.DeleteUserDataRequest{}, codes.InvalidArgument},
+	} {
+		if _, err := s.DeleteUserData(tt.ctx, tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	resp, err := s.DeleteUserData(root, &url_service.DeleteUserDataRequest{UserId: "alice"})
+	if err != nil {
+		t.Fatalf("DeleteUserData: %v", err)
+	}
+	if resp.UserId != "alice" || resp.DeletedUrls != 2 || resp.CachePurged != 2 || resp.CacheFailed != 0 {
+		t.Errorf("DeleteUserData = %v, want 2 links deleted and purged", resp)
+	}
+
+	// Only alice's links are gone, from every layer
+	for _, tt := range []struct {
+		code string
+		gone bool
+	}{{"alice1", true}, {"alice2", true}, {"bob1", false}} {
+		_, cached := cache.entry("url:" + tt.code)
+		_, inMemory := s.urls.Get(tt.code)
+		_, stored := storage.url(tt.code)
+		if cached == tt.gone || inMemory == tt.gone || stored == tt.gone {
+			t.Errorf("%s: cached %v, in memory %v, stored %v, want gone %v", tt.code, cached, inMemory, stored, tt.gone)
+		}
+		_, err := s.GetOriginalURL(context.Background(), &url_service.GetOriginalRequest{ShortCode: tt.code})
+		if gone := status.Code(err) == codes.NotFound; gone != tt.gone {
+			t.Errorf("%s: GetOriginalURL got %v, want gone %v", tt.code, err, tt.gone)
+		}
+	}
+
+	// A repeat deletes nothing more but purges again, until the cache
+	// takes it
+	cache.mu.Lock()
+	cache.deleteErr = errors.New("cache unreachable")
+	cache.mu.Unlock()
+	again, err := s.DeleteUserData(root, &url_service.DeleteUserDataRequest{UserId: "alice"})
+	if err != nil || again.DeletedUrls != 0 || again.CachePurged != 0 || again.CacheFailed != 2 {
+		t.Errorf("DeleteUserData with the cache failing = %v, %v, want nothing deleted and 2 failed", again, err)
+	}
+	cache.mu.Lock()
+	cache.deleteErr = nil
+	cache.mu.Unlock()
+	if again, err := s.DeleteUserData(root, &url_service.DeleteUserDataRequest{UserId: "alice"}); err != nil || again.DeletedUrls != 0 || again.CachePurged != 2 {
+		t.Errorf("retried DeleteUserData = %v, %v, want nothing deleted and 2 purged", again, err)
+	}
+}
+
+func TestExportUserData(t *testing.T) {
+	s, storage, _ := newTestServer(t, map[string]string{"ADMIN_USERS": "root"})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "alice1", OriginalUrl: "https://example.com/1", UserId: "alice"})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "alice2", OriginalUrl: "https://example.com/2", UserId: "alice"})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "bob1", OriginalUrl: "https://example.com/b", UserId: "bob"})
+	storage.mu.Lock()
+	storage.clickCounts["alice1"], storage.clickCounts["alice2"], storage.clickCounts["bob1"] = 3, 4, 100
+	storage.deleted["alice2"] = storage.urls["alice2"]
+	delete(storage.urls, "alice2")
+	storage.mu.Unlock()
+
+	keys, err := loadAPIKeys("alice:a-key,root:r-key", "", "")
+	if err != nil {
+		t.Fatalf("loadAPIKeys: %v", err)
+	}
+	conn := dialBufconn(t, func(srv *grpc.Server) { url_service.RegisterURLServiceServer(srv, s) },
+		grpc.StreamInterceptor(authStreamInterceptor(keys, false)))
+	client := url_service.NewURLServiceClient(conn)
+	// export reads a whole export as key, one JSON chunk per message
+	export := func(key, user string) ([]json.RawMessage, error) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), apiKeyHeader, key)
+		stream, err := client.ExportUserData(ctx, &url_service.ExportUserDataRequest{UserId: user})
+		if err != nil {
+			return nil, err
+		}
+		var chunks []json.RawMessage
+		for {
+			chunk, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return chunks, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			chunks = append(chunks, chunk.Data)
+		}
+	}
+
+	// Without API keys there is no caller to trust with the export
+	anonymous := url_service.NewURLServiceClient(dialBufconn(t, func(srv *grpc.Server) { url_service.RegisterURLServiceServer(srv, s) }))
+	stream, err := anonymous.ExportUserData(context.Background(), &url_service.ExportUserDataRequest{UserId: "alice"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("export by an anonymous caller: got %v, want Unauthenticated", err)
+	}
+	if _, err := export("a-key", "alice"); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("export by a user who isn't an admin: got %v, want PermissionDenied", err)
+	}
+	if _, err := export("r-key", ""); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("export without a user: got %v, want InvalidArgument", err)
+	}
+
+	chunks, err := export("r-key", "alice")
+	if err != nil {
+		t.Fatalf("ExportUserData: %v", err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want a page and the summary", len(chunks))
+	}
+	var page userDataPage
+	if err := json.Unmarshal(chunks[0], &page); err != nil {
+		t.Fatalf("page chunk %s: %v", chunks[0], err)
+	}
+	var exported []string
+	for _, u := range page.URLs {
+		exported = append(exported, u.ShortCode)
+	}
+	if page.Type != "urls" || !slices.Equal(exported, []string{"alice1", "alice2"}) || page.URLs[1].DeletedAt == "" || page.URLs[0].ClickCount != 3 {
+		t.Errorf("page chunk %s, want alice1 and the deleted alice2", chunks[0])
+	}
+	var summary userDataSummary
+	if err := json.Unmarshal(chunks[1], &summary); err != nil {
+		t.Fatalf("summary chunk %s: %v", chunks[1], err)
+	}
+	if summary.Type != "summary" || summary.UserID != "alice" || summary.URLs != 2 || summary.DeletedURLs != 1 || summary.Clicks != 7 || summary.ExportedAt == "" {
+		t.Errorf("summary chunk %s, want 2 links, 1 deleted, 7 clicks", chunks[1])
+	}
+
+	// Exporting only reads, so it can be repeated
+	if again, err := export("r-key", "alice"); err != nil || len(again) != 2 || string(again[0]) != string(chunks[0]) {
+		t.Errorf("repeated export = %s, %v, want the same links", again, err)
+	}
+}
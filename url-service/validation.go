@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultMaxURLLength = 2048
+
+// urlValidator checks original URLs before they are shortened.
+type urlValidator struct {
+	maxLength int
+	self      *selfLinks
+	domains   *domainRules
+}
+
+func newURLValidator(maxLength int, self *selfLinks, rules *domainRules) *urlValidator {
+	return &urlValidator{
+		maxLength: maxLength,
+		self:      self,
+		domains:   rules,
+	}
+}
+
+// Validate returns an InvalidArgument status naming the rule the URL broke,
+// PermissionDenied if the blocked domains rule it out, or nil if the URL
+// can be shortened.
+func (v *urlValidator) Validate(rawURL string) error {
+	if rawURL == "" {
+		return status.Error(codes.InvalidArgument, "original URL is required")
+	}
+	if len(rawURL) > v.maxLength {
+		return status.Errorf(codes.InvalidArgument, "original URL exceeds maximum length of %d characters", v.maxLength)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "original URL is malformed: %v", err)
+	}
+	if !u.IsAbs() {
+		return status.Error(codes.InvalidArgument, "original URL must be absolute")
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return status.Errorf(codes.InvalidArgument, "original URL scheme %q is not allowed, use http or https", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return status.Error(codes.InvalidArgument, "original URL must include a host")
+	}
+
+	// Deep links into our own site are fine
+	if _, ok := v.self.shortLink(u, ""); ok {
+		return status.Error(codes.InvalidArgument, "original URL must not point at a short link of the shortener itself")
+	}
+
+	if v.domains != nil {
+		return v.domains.Check(u)
+	}
+	return nil
+}
+
+const (
+	minAliasLength = 3
+	maxAliasLength = 32
+)
+
+// defaultReservedAliases are short codes that would shadow HTTP routes or
+// are otherwise confusing as links.
+var defaultReservedAliases = []string{
+	"api", "health", "healthz", "readyz", "metrics", "stats", "admin", "shorten", "debug",
+}
+
+// aliasValidator checks custom aliases and generated codes against the
+// allowed charset, length limits, and the reserved-word list.
+type aliasValidator struct {
+	reserved atomic.Pointer[map[string]struct{}]
+}
+
+func newAliasValidator(reserved []string) *aliasValidator {
+	v := &aliasValidator{}
+	v.Set(reserved)
+	return v
+}
+
+// Set replaces the reserved-word list.
+func (v *aliasValidator) Set(reserved []string) {
+	words := make(map[string]struct{}, len(reserved))
+	for _, r := range reserved {
+		r = strings.ToLower(strings.TrimSpace(r))
+		if r != "" {
+			words[r] = struct{}{}
+		}
+	}
+	v.reserved.Store(&words)
+}
+
+// loadReservedAliases combines the defaults with the comma separated
+// RESERVED_ALIASES list and the newline separated RESERVED_ALIASES_FILE.
+func loadReservedAliases(list, path string) ([]string, error) {
+	reserved := append([]string{}, defaultReservedAliases...)
+	reserved = append(reserved, strings.Split(list, ",")...)
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read reserved aliases file: %v", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			reserved = append(reserved, line)
+		}
+	}
+
+	return reserved, nil
+}
+
+// Validate returns an InvalidArgument status naming the rule the alias
+// broke, or nil if the alias is acceptable.
+func (v *aliasValidator) Validate(alias string) error {
+	if len(alias) < minAliasLength || len(alias) > maxAliasLength {
+		return status.Errorf(codes.InvalidArgument, "alias must be between %d and %d characters", minAliasLength, maxAliasLength)
+	}
+	for _, r := range alias {
+		if !isAliasChar(r) {
+			return status.Errorf(codes.InvalidArgument, "alias contains invalid character %q, allowed are letters, digits, '_' and '-'", r)
+		}
+	}
+	if v.IsReserved(alias) {
+		return status.Errorf(codes.InvalidArgument, "alias %q is reserved", alias)
+	}
+	return nil
+}
+
+// IsReserved reports whether the alias is on the reserved-word list.
+func (v *aliasValidator) IsReserved(alias string) bool {
+	_, reserved := (*v.reserved.Load())[strings.ToLower(alias)]
+	return reserved
+}
+
+// isAliasChar reports whether r may appear in an alias. '+' in particular
+// must not: the gateway serves previews at /{code}+.
+func isAliasChar(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '-'
+}
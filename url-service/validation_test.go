@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestURLValidator(t *testing.T) {
+	s, _, _ := newTestServer(t, map[string]string{"BASE_URL": "https://sho.rt", "MAX_URL_LENGTH": "60"})
+	tests := []struct {
+		name, url string
+		ok        bool
+		rule      string // In the message of a rejection
+	}{
+		{"http", "http://example.com", true, ""},
+		{"https with path and query", "https://example.com/a/b?c=d#e", true, ""},
+		{"upper case scheme", "HTTPS://EXAMPLE.COM/", true, ""},
+		{"port", "https://example.com:8443/x", true, ""},
+		{"empty", "", false, "required"},
+		{"ftp", "ftp://example.com/file", false, "scheme"},
+		{"data", "data:text/html,<script>alert(1)</script>", false, "scheme"},
+		{"mailto", "mailto:someone@example.com", false, "scheme"},
+		{"javascript", "javascript:alert(1)", false, "scheme"},
+		{"relative path", "/just/a/path", false, "absolute"},
+		{"no scheme", "example.com/path", false, "absolute"},
+		{"missing host", "http:///path", false, "host"},
+		{"port without host", "https://:443/", false, "host"},
+		{"malformed", "http://exa mple.com/%zz", false, "malformed"},
+		{"too long", "https://example.com/" + strings.Repeat("a", 41), false, "length"},
+		{"own short link", "https://sho.rt/abc123", false, "short link"},
+	}
+	for _, tt := range tests {
+		err := s.validator.Validate(tt.url)
+		if tt.ok {
+			if err != nil {
+				t.Errorf("%s: Validate(%q) = %v, want ok", tt.name, tt.url, err)
+			}
+			continue
+		}
+		if status.Code(err) != codes.InvalidArgument || !strings.Contains(status.Convert(err).Message(), tt.rule) {
+			t.Errorf("%s: Validate(%q) = %v, want InvalidArgument about %s", tt.name, tt.url, err, tt.rule)
+		}
+	}
+}
+
+func TestAliasValidator(t *testing.T) {
+	reserved, err := loadReservedAliases("promo, Launch", "")
+	if err != nil {
+		t.Fatalf("loadReservedAliases: %v", err)
+	}
+	v := newAliasValidator(reserved)
+	tests := []struct {
+		alias string
+		ok    bool
+		rule  string
+	}{
+		{"abc", true, ""},
+		{"My_Alias-2024", true, ""},
+		{strings.Repeat("a", maxAliasLength), true, ""},
+		{"ab", false, "between"},
+		{strings.Repeat("a", maxAliasLength+1), false, "between"},
+		{strings.Repeat("a", 500), false, "between"},
+		{"../etc", false, "invalid character"},
+		{"with space", false, "invalid character"},
+		{"code+", false, "invalid character"},
+		{"héllo", false, "invalid character"},
+		{"go🚀", false, "invalid character"},
+		{"health", false, "reserved"},
+		{"METRICS", false, "reserved"},
+		{"api", false, "reserved"},
+		{"promo", false, "reserved"},
+		{"launch", false, "reserved"},
+	}
+	for _, tt := range tests {
+		err := v.Validate(tt.alias)
+		if tt.ok {
+			if err != nil {
+				t.Errorf("Validate(%q) = %v, want ok", tt.alias, err)
+			}
+			continue
+		}
+		if status.Code(err) != codes.InvalidArgument || !strings.Contains(status.Convert(err).Message(), tt.rule) {
+			t.Errorf("Validate(%q) = %v, want InvalidArgument about %s", tt.alias, err, tt.rule)
+		}
+	}
+}
+
+func TestLoadReservedAliasesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reserved.txt")
+	if err := os.WriteFile(path, []byte("# routes\nlogin\n\n  signup  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reserved, err := loadReservedAliases("", path)
+	if err != nil {
+		t.Fatalf("loadReservedAliases: %v", err)
+	}
+	v := newAliasValidator(reserved)
+	for alias, want := range map[string]bool{"login": true, "signup": true, "health": true, "# routes": false, "other": false} {
+		if got := v.IsReserved(alias); got != want {
+			t.Errorf("IsReserved(%q) = %v, want %v", alias, got, want)
+		}
+	}
+
+	if _, err := loadReservedAliases("", filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("loadReservedAliases accepted a missing file")
+	}
+}
+
+func TestGeneratedCodesSkipReserved(t *testing.T) {
+	// With a one-letter alphabet the only code there is is reserved
+	s, _, _ := newTestServer(t, map[string]string{"RESERVED_ALIASES": "aaaa"})
+	s.codeAlphabet, s.codeLength = "a", 4
+	if code, err := s.generateUniqueShortCode(context.Background(), codeStrategyRandom); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("generateUniqueShortCode = %q, %v, want ResourceExhausted", code, err)
+	}
+
+	s.aliases.Set(nil)
+	if code, err := s.generateUniqueShortCode(context.Background(), codeStrategyRandom); err != nil || code != "aaaa" {
+		t.Errorf("generateUniqueShortCode = %q, %v, want aaaa", code, err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/rand/v2"
+	"regexp"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	minVariants      = 2
+	maxVariants      = 10
+	maxVariantWeight = 1000000
+)
+
+var variantNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// variant is one weighted destination of a split link.
+type variant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int32  `json:"weight"`
+}
+
+// variantSet is what a split link resolves to: each lookup picks one of
+// its variants in proportion to the weights. Sticky sets pick by a hash of
+// the visitor instead of at random, so a visitor keeps getting the same
+// variant for as long as the set doesn't change. The cache holds the whole
+// set as JSON, so every lookup picks afresh whichever layer answers it.
+type variantSet struct {
+	Sticky   bool      `json:"sticky,omitempty"`
+	Variants []variant `json:"variants"`
+}
+
+// newVariantSet validates and canonicalizes the variants of a request. It
+// returns nil for a plain link.
+func (s *urlServer) newVariantSet(variants []*url_service.Variant, sticky bool) (*variantSet, error) {
+	if len(variants) == 0 {
+		if sticky {
+			return nil, status.Error(codes.InvalidArgument, "sticky_variants needs variants")
+		}
+		return nil, nil
+	}
+	if len(variants) < minVariants || len(variants) > maxVariants {
+		return nil, status.Errorf(codes.InvalidArgument, "a split link needs between %d and %d variants", minVariants, maxVariants)
+	}
+
+	set := &variantSet{Sticky: sticky}
+	names := make(map[string]bool, len(variants))
+	for i, v := range variants {
+		name := v.Name
+		if name == "" {
+			name = string(rune('a' + i))
+		}
+		if !variantNamePattern.MatchString(name) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid variant name %q: use up to 32 letters, digits, '_' or '-'", name)
+		}
+		if names[name] {
+			return nil, status.Errorf(codes.InvalidArgument, "duplicate variant name %q", name)
+		}
+		names[name] = true
+		if v.Weight <= 0 || v.Weight > maxVariantWeight {
+			return nil, status.Errorf(codes.InvalidArgument, "weight of variant %q must be between 1 and %d", name, maxVariantWeight)
+		}
+		if err := s.validator.Validate(v.Url); err != nil {
+			return nil, status.Errorf(status.Code(err), "variant %q: %s", name, status.Convert(err).Message())
+		}
+		destination, err := s.canonicalURL(v.Url)
+		if err != nil {
+			return nil, err
+		}
+		set.Variants = append(set.Variants, variant{Name: name, URL: destination, Weight: v.Weight})
+	}
+	return set, nil
+}
+
+// variantsFromStorage returns the variant set storage returned for a link,
+// or nil if it isn't split.
+func variantsFromStorage(variants []*storage_service.Variant, sticky bool) *variantSet {
+	if len(variants) == 0 {
+		return nil
+	}
+	set := &variantSet{Sticky: sticky, Variants: make([]variant, len(variants))}
+	for i, v := range variants {
+		set.Variants[i] = variant{Name: v.Name, URL: v.Url, Weight: v.Weight}
+	}
+	return set
+}
+
+// Primary returns the destination stored as the link's original_url.
+func (vs *variantSet) Primary() string {
+	return vs.Variants[0].URL
+}
+
+// URLs returns the destinations, for screening.
+func (vs *variantSet) URLs() []string {
+	urls := make([]string, len(vs.Variants))
+	for i, v := range vs.Variants {
+		urls[i] = v.URL
+	}
+	return urls
+}
+
+func (vs *variantSet) storage() []*storage_service.Variant {
+	if vs == nil {
+		return nil
+	}
+	out := make([]*storage_service.Variant, len(vs.Variants))
+	for i, v := range vs.Variants {
+		out[i] = &storage_service.Variant{Name: v.Name, Url: v.URL, Weight: v.Weight}
+	}
+	return out
+}
+
+func (vs *variantSet) proto() []*url_service.Variant {
+	if vs == nil {
+		return nil
+	}
+	out := make([]*url_service.Variant, len(vs.Variants))
+	for i, v := range vs.Variants {
+		out[i] = &url_service.Variant{Name: v.Name, Url: v.URL, Weight: v.Weight}
+	}
+	return out
+}
+
+// Pick chooses a variant in proportion to the weights: at random, or for
+// sticky sets by visitorKey, when there is one.
+func (vs *variantSet) Pick(visitorKey string) variant {
+	var total int64
+	for _, v := range vs.Variants {
+		total += int64(v.Weight)
+	}
+
+	var n int64
+	if vs.Sticky && visitorKey != "" {
+		sum := sha256.Sum256([]byte(visitorKey))
+		n = int64(binary.BigEndian.Uint64(sum[:8]) % uint64(total))
+	} else {
+		n = rand.Int64N(total)
+	}
+	for _, v := range vs.Variants {
+		if n < int64(v.Weight) {
+			return v
+		}
+		n -= int64(v.Weight)
+	}
+	return vs.Variants[len(vs.Variants)-1]
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	url_service "github.com/syedalijabir/protos/url-service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestVariantSetPickDistribution(t *testing.T) {
+	const samples = 20000
+	tests := []struct {
+		name   string
+		sticky bool
+		key    func(i int) string
+	}{
+		{"random", false, func(int) string { return "" }},
+		{"sticky", true, func(i int) string { return "visitor" + strconv.Itoa(i) }},
+	}
+	for _, tt := range tests {
+		set := &variantSet{Sticky: tt.sticky, Variants: []variant{
+			{Name: "a", URL: "https://example.com/a", Weight: 1},
+			{Name: "b", URL: "https://example.com/b", Weight: 3},
+			{Name: "c", URL: "https://example.com/c", Weight: 6},
+		}}
+		counts := map[string]int{}
+		for i := 0; i < samples; i++ {
+			counts[set.Pick(tt.key(i)).Name]++
+		}
+		for _, v := range set.Variants {
+			share := float64(counts[v.Name]) / samples
+			if want := float64(v.Weight) / 10; math.Abs(share-want) > 0.02 {
+				t.Errorf("%s: variant %s got %.3f of the picks, want %.1f±0.02", tt.name, v.Name, share, want)
+			}
+		}
+	}
+}
+
+func TestVariantSetStickyPick(t *testing.T) {
+	set := &variantSet{Sticky: true, Variants: []variant{{Name: "a", Weight: 1}, {Name: "b", Weight: 1}}}
+	for i := 0; i < 100; i++ {
+		key := "visitor" + strconv.Itoa(i)
+		first := set.Pick(key)
+		for j := 0; j < 10; j++ {
+			if got := set.Pick(key); got.Name != first.Name {
+				t.Fatalf("%s flipped from %s to %s", key, first.Name, got.Name)
+			}
+		}
+	}
+}
+
+func TestNewVariantSet(t *testing.T) {
+	s, _, _ := newTestServer(t, nil)
+	variants := func(weights ...int32) []*url_service.Variant {
+		var out []*url_service.Variant
+		for i, w := range weights {
+			out = append(out, &url_service.Variant{Url: "https://example.com/" + strconv.Itoa(i), Weight: w})
+		}
+		return out
+	}
+
+	set, err := s.newVariantSet(variants(1, 2), true)
+	if err != nil {
+		t.Fatalf("newVariantSet: %v", err)
+	}
+	if !set.Sticky || set.Variants[0].Name != "a" || set.Variants[1].Name != "b" || set.Primary() != "https://example.com/0" {
+		t.Errorf("newVariantSet = %+v, want sticky variants named a and b", set)
+	}
+	if set, err := s.newVariantSet(nil, false); set != nil || err != nil {
+		t.Errorf("newVariantSet without variants = %v, %v, want a plain link", set, err)
+	}
+
+	tests := []struct {
+		name     string
+		variants []*url_service.Variant
+		sticky   bool
+	}{
+		{"sticky without variants", nil, true},
+		{"one variant", variants(1), false},
+		{"eleven variants", variants(1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1), false},
+		{"zero weight", variants(1, 0), false},
+		{"weight too large", variants(1, maxVariantWeight+1), false},
+		{"duplicate name", []*url_service.Variant{{Name: "x", Url: "https://example.com/a", Weight: 1}, {Name: "x", Url: "https://example.com/b", Weight: 1}}, false},
+		{"bad name", []*url_service.Variant{{Name: "a b", Url: "https://example.com/a", Weight: 1}, {Url: "https://example.com/b", Weight: 1}}, false},
+		{"bad URL", []*url_service.Variant{{Url: "javascript:alert(1)", Weight: 1}, {Url: "https://example.com/b", Weight: 1}}, false},
+	}
+	for _, tt := range tests {
+		if _, err := s.newVariantSet(tt.variants, tt.sticky); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: got %v, want InvalidArgument", tt.name, err)
+		}
+	}
+}
+
+func TestSplitLinkStickyAcrossLayers(t *testing.T) {
+	s, storage, cache := newTestServer(t, map[string]string{"URL_SYNC_PERSIST": "true"})
+	ctx := context.Background()
+	resp, err := s.ShortenURL(ctx, &url_service.ShortenRequest{
+		CustomAlias: "split",
+		Variants: []*url_service.Variant{
+			{Name: "control", Url: "https://example.com/a", Weight: 1},
+			{Name: "new", Url: "https://example.com/b", Weight: 1},
+		},
+		StickyVariants: true,
+	})
+	if err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	if resp.OriginalUrl != "https://example.com/a" || len(resp.Variants) != 2 {
+		t.Fatalf("ShortenURL = %v, want the first variant as original_url and both variants", resp)
+	}
+	if u, _ := storage.url("split"); len(u.Variants) != 2 || !u.StickyVariants {
+		t.Fatalf("stored %v, want two sticky variants", u)
+	}
+
+	feed, err := s.feed.Subscribe("", "split")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer s.feed.Unsubscribe(feed)
+	urls := map[string]string{"control": "https://example.com/a", "new": "https://example.com/b"}
+	// lookup resolves the link for every visitor, by visitor
+	lookup := func() map[string]string {
+		got := map[string]string{}
+		for i := 0; i < 50; i++ {
+			visitor := "visitor" + strconv.Itoa(i)
+			resp, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "split", VisitorId: visitor})
+			if err != nil {
+				t.Fatalf("GetOriginalURL for %s: %v", visitor, err)
+			}
+			if urls[resp.Variant] != resp.OriginalUrl {
+				t.Fatalf("%s sent to %s as variant %q", visitor, resp.OriginalUrl, resp.Variant)
+			}
+			// The click is counted against the variant served
+			if click := <-feed.events; click.Variant != resp.Variant {
+				t.Fatalf("%s: click on variant %q, served %q", visitor, click.Variant, resp.Variant)
+			}
+			got[visitor] = resp.Variant
+		}
+		return got
+	}
+
+	fromMemory := lookup()
+	seen := map[string]bool{}
+	for _, v := range fromMemory {
+		seen[v] = true
+	}
+	if !seen["control"] || !seen["new"] {
+		t.Errorf("50 visitors all got %v", seen)
+	}
+
+	// The cache holds the whole set, so visitors stay put when it answers
+	waitForCacheEntry(t, cache, "url:split", true)
+	if value, _ := cache.entry("url:split"); !strings.Contains(value, `"variants"`) {
+		t.Errorf("cached %q, want the variant set", value)
+	}
+	var storageLookups atomic.Int32
+	storage.afterGet = func(string) { storageLookups.Add(1) }
+	s.urls.Remove("split")
+	fromCache := lookup()
+
+	// And so does storage
+	cache.expire("url:split")
+	s.urls.Remove("split")
+	fromStorage := lookup()
+
+	for visitor, want := range fromMemory {
+		if fromCache[visitor] != want || fromStorage[visitor] != want {
+			t.Errorf("%s got %s from memory, %s from cache and %s from storage", visitor, want, fromCache[visitor], fromStorage[visitor])
+		}
+	}
+	if n := storageLookups.Load(); n != 1 {
+		t.Errorf("storage looked up %d times, want once, after the cache entry expired", n)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	cache_service "github.com/syedalijabir/protos/cache-service"
+)
+
+const (
+	defaultUniqueClickWindow = 24 * time.Hour
+
+	// saltTTL keeps a day's salt until every replica has moved on to the
+	// next day, after which the day's hashes can't be recomputed
+	saltTTL = 48 * time.Hour
+)
+
+// visitorTracker decides whether a click comes from a visitor not seen
+// within the window. Visitors are identified by a hash of the short code,
+// their IP and user agent and the day, salted with a random value that
+// replicas share through the cache and that changes every day, so the
+// hashes can't be linked across days or traced back to an IP. The hashes
+// live in the cache for the window, which therefore ends at midnight UTC
+// at the latest.
+type visitorTracker struct {
+	cacheClient       cache_service.CacheServiceClient
+	window            time.Duration
+	trustForwardedFor bool
+
+	mu      sync.Mutex
+	saltDay string
+	salt    string
+}
+
+func newVisitorTracker(cacheClient cache_service.CacheServiceClient, window time.Duration, trustForwardedFor bool) *visitorTracker {
+	return &visitorTracker{cacheClient: cacheClient, window: window, trustForwardedFor: trustForwardedFor}
+}
+
+// IsNew records the visit of ip and userAgent to shortCode and reports
+// whether it is the first within the window. Replicas racing on the same
+// visitor agree, since only one of them can store the hash.
+func (v *visitorTracker) IsNew(ctx context.Context, shortCode, ip, userAgent string) (bool, error) {
+	day := time.Now().UTC().Format(time.DateOnly)
+	salt, err := v.dailySalt(ctx, day)
+	if err != nil {
+		return false, err
+	}
+
+	h := sha256.New()
+	for _, part := range []string{salt, shortCode, ip, userAgent, day} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	resp, err := v.cacheClient.SetIfAbsent(ctx, &cache_service.SetRequest{
+		Namespace:  visitorNamespace,
+		Key:        hex.EncodeToString(h.Sum(nil)),
+		Value:      "1",
+		TtlSeconds: int32(v.window / time.Second),
+	})
+	if err != nil {
+		return false, err
+	}
+	return resp.Stored, nil
+}
+
+// dailySalt returns the salt of day. The first replica to need it picks it
+// and the others read theirs from the cache.
+func (v *visitorTracker) dailySalt(ctx context.Context, day string) (string, error) {
+	v.mu.Lock()
+	if v.saltDay == day {
+		defer v.mu.Unlock()
+		return v.salt, nil
+	}
+	v.mu.Unlock()
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	salt := hex.EncodeToString(b)
+
+	key := "salt:" + day
+	resp, err := v.cacheClient.SetIfAbsent(ctx, &cache_service.SetRequest{
+		Namespace:  visitorNamespace,
+		Key:        key,
+		Value:      salt,
+		TtlSeconds: int32(saltTTL / time.Second),
+	})
+	if err != nil {
+		return "", err
+	}
+	if !resp.Stored {
+		existing, err := v.cacheClient.Get(ctx, &cache_service.GetRequest{Namespace: visitorNamespace, Key: key})
+		if err != nil {
+			return "", err
+		}
+		if !existing.Found {
+			return "", fmt.Errorf("salt of %s vanished from the cache", day)
+		}
+		salt = existing.Value
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.saltDay, v.salt = day, salt
+	return salt, nil
+}
+
+// trackVisitor counts the click in the background as unique if its
+// visitor is new within the window. A failed check counts it as a repeat
+// visit rather than inflating the unique count.
+func (s *urlServer) trackVisitor(ctx context.Context, shortCode, userAgent string) {
+	if s.visitors == nil {
+		return
+	}
+	ip := callerIP(ctx, s.visitors.trustForwardedFor)
+	bg := detach(ctx)
+	s.tasks.Submit("track visitor "+shortCode, func() {
+		ctx, cancel := s.cacheCtx(bg)
+		defer cancel()
+
+		isNew, err := s.visitors.IsNew(ctx, shortCode, ip, userAgent)
+		if err != nil {
+			logf(ctx, "Warning: failed to check visitor of %s: %v", shortCode, err)
+			return
+		}
+		if isNew {
+			s.clicks.AddUnique(shortCode)
+		}
+	})
+}
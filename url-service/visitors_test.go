@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+	url_service "github.com/syedalijabir/protos/url-service"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestUniqueVisitors(t *testing.T) {
+	s, storage, cache := newTestServer(t, map[string]string{"TRUST_FORWARDED_FOR": "true", "UNIQUE_CLICK_WINDOW": "1h"})
+	storage.put(&storage_service.SaveURLRequest{ShortCode: "abc", OriginalUrl: "https://example.com"})
+
+	visits := []struct {
+		ip, userAgent string
+	}{
+		{"198.51.100.1", "Mozilla/5.0 Firefox"},
+		{"198.51.100.1", "Mozilla/5.0 Firefox"}, // a refresh
+		{"198.51.100.1", "Mozilla/5.0 Firefox"},
+		{"198.51.100.2", "Mozilla/5.0 Firefox"}, // same browser elsewhere
+		{"198.51.100.1", "Mozilla/5.0 Safari"},  // another browser behind the same IP
+	}
+	for _, v := range visits {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-forwarded-for", v.ip))
+		if _, err := s.GetOriginalURL(ctx, &url_service.GetOriginalRequest{ShortCode: "abc", UserAgent: v.userAgent}); err != nil {
+			t.Fatalf("GetOriginalURL: %v", err)
+		}
+	}
+	s.tasks.Close(context.Background())
+
+	stats, err := s.GetURLStats(context.Background(), &url_service.StatsRequest{ShortCode: "abc"})
+	if err != nil {
+		t.Fatalf("GetURLStats: %v", err)
+	}
+	if stats.ClickCount != 5 || stats.UniqueClicks != 3 {
+		t.Errorf("GetURLStats = %d clicks and %d unique, want 5 and 3", stats.ClickCount, stats.UniqueClicks)
+	}
+
+	// Hashes live for the window and the salt for two days, and neither
+	// holds the IP
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	var hashes int
+	for key, ttl := range cache.ttls {
+		if !strings.HasPrefix(key, visitorNamespace+":") {
+			continue
+		}
+		if strings.Contains(key, "198.51.100") || strings.Contains(cache.entries[key], "198.51.100") {
+			t.Errorf("cache entry %s keeps the IP", key)
+		}
+		switch {
+		case strings.HasPrefix(key, visitorNamespace+":salt:"):
+			if want := visitorNamespace + ":salt:" + time.Now().UTC().Format(time.DateOnly); key != want || ttl != int32(saltTTL/time.Second) {
+				t.Errorf("salt %s with TTL %ds, want %s with %v", key, ttl, want, saltTTL)
+			}
+		default:
+			hashes++
+			if ttl != 3600 {
+				t.Errorf("visitor hash %s with TTL %ds, want 3600", key, ttl)
+			}
+		}
+	}
+	if hashes != 3 {
+		t.Errorf("%d visitor hashes cached, want 3", hashes)
+	}
+}
+
+func TestVisitorTrackerSharesSalt(t *testing.T) {
+	s, _, cache := newTestServer(t, nil)
+	ctx := context.Background()
+
+	// Replicas pick up the salt the first one stored, so they agree on
+	// who is new
+	first := newVisitorTracker(s.cacheClient, time.Hour, false)
+	second := newVisitorTracker(s.cacheClient, time.Hour, false)
+	if isNew, err := first.IsNew(ctx, "abc", "198.51.100.1", "curl"); err != nil || !isNew {
+		t.Fatalf("first visit = %v, %v, want new", isNew, err)
+	}
+	if isNew, err := second.IsNew(ctx, "abc", "198.51.100.1", "curl"); err != nil || isNew {
+		t.Errorf("same visitor on another replica = %v, %v, want a repeat", isNew, err)
+	}
+	if isNew, err := second.IsNew(ctx, "other", "198.51.100.1", "curl"); err != nil || !isNew {
+		t.Errorf("same visitor on another code = %v, %v, want new", isNew, err)
+	}
+
+	// Once the window ends the visitor is new again
+	cache.mu.Lock()
+	for key := range cache.entries {
+		if strings.HasPrefix(key, visitorNamespace+":") && !strings.HasPrefix(key, visitorNamespace+":salt:") {
+			delete(cache.entries, key)
+		}
+	}
+	cache.mu.Unlock()
+	if isNew, err := first.IsNew(ctx, "abc", "198.51.100.1", "curl"); err != nil || !isNew {
+		t.Errorf("visit after the window = %v, %v, want new", isNew, err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	cache_service "github.com/syedalijabir/protos/cache-service"
+	storage_service "github.com/syedalijabir/protos/storage-service"
+)
+
+const (
+	defaultWarmupURLs    = 1000
+	defaultWarmupTimeout = 5 * time.Second
+
+	// warmupCacheBatch keeps each MSet well under cache-service's batch limit.
+	warmupCacheBatch = 500
+)
+
+// warmUp loads the most popular URLs from storage into memory and the cache
+// before the service starts taking traffic, so the first lookup of each
+// doesn't go to Postgres. It gives up when budget runs out; failures only
+// mean starting cold.
+func (s *urlServer) warmUp(ctx context.Context, limit int, orderBy string, budget time.Duration) {
+	if limit <= 0 {
+		return
+	}
+	// More than the LRU holds would just evict each other
+	limit = min(limit, s.urls.maxEntries)
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+
+	resp, err := s.storageClient.GetTopURLs(ctx, &storage_service.GetTopURLsRequest{
+		Limit:   int32(limit),
+		OrderBy: orderBy,
+	})
+	if err != nil {
+		log.Printf("Warning: warm-up skipped, failed to load top URLs: %v", err)
+		return
+	}
+
+	entries := make([]*cache_service.SetRequest, 0, 2*len(resp.Urls))
+	warmed := 0
+	for _, summary := range resp.Urls {
+		expiresAt := parseOptionalTime(summary.ExpiresAt)
+		ttl := s.cacheTTL(expiresAt)
+		if ttl <= 0 {
+			continue
+		}
+		// Summaries don't carry variants or rules, so those links are left
+		// to load on their first lookup
+		if summary.Split || summary.Conditional {
+			continue
+		}
+		routes := linkRoutes{queryTemplate: summary.QueryTemplate}
+		createdAt, err := time.Parse(time.RFC3339, summary.CreatedAt)
+		if err != nil {
+			createdAt = time.Now()
+		}
+		// Entries already in memory are at least as fresh
+		if !s.urls.AddIfAbsent(summary.ShortCode, urlEntry{
+			originalURL: summary.OriginalUrl,
+			createdAt:   createdAt,
+			expiresAt:   expiresAt,
+			clickCount:  summary.ClickCount,
+			maxClicks:   summary.MaxClicks,
+			notBefore:   parseOptionalTime(summary.NotBefore),
+			disabled:    summary.Disabled,
+			routes:      routes,
+		}) {
+			continue
+		}
+		warmed++
+		// Limited links are never cached, every click has to reach
+		// storage, and neither are disabled links or those before their
+		// window opens
+		if summary.MaxClicks > 0 || summary.Disabled || !isActive(parseOptionalTime(summary.NotBefore)) {
+			continue
+		}
+
+		entries = append(entries, &cache_service.SetRequest{
+			Namespace:  urlNamespace,
+			Key:        summary.ShortCode,
+			Value:      cacheValue(summary.OriginalUrl, routes),
+			TtlSeconds: ttl,
+		}, &cache_service.SetRequest{
+			Namespace:  countNamespace,
+			Key:        summary.ShortCode,
+			Value:      strconv.FormatInt(summary.ClickCount, 10),
+			TtlSeconds: s.live().cacheTTLSeconds,
+		})
+	}
+
+	cached := 0
+	for len(entries) > 0 {
+		batch := entries[:min(len(entries), warmupCacheBatch)]
+		entries = entries[len(batch):]
+		if _, err := s.cacheClient.MSet(ctx, &cache_service.MSetRequest{Entries: batch}); err != nil {
+			log.Printf("Warning: warm-up stopped filling the cache: %v", err)
+			break
+		}
+		cached += len(batch)
+	}
+
+	log.Printf("Warm-up loaded %d URLs into memory and %d cache entries in %s", warmed, cached, time.Since(start).Round(time.Millisecond))
+}
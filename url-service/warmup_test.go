@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	storage_service "github.com/syedalijabir/protos/storage-service"
+)
+
+func (f *fakeStorage) GetTopURLs(ctx context.Context, req *storage_service.GetTopURLsRequest) (*storage_service.GetTopURLsResponse, error) {
+	select {
+	case <-time.After(f.topDelay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.topReqs = append(f.topReqs, req)
+	urls := f.topURLs
+	if len(urls) > int(req.Limit) {
+		urls = urls[:req.Limit]
+	}
+	return &storage_service.GetTopURLsResponse{Urls: urls}, nil
+}
+
+func TestWarmUpPopulatesMemoryAndCache(t *testing.T) {
+	s, storage, cache := newTestServer(t, nil)
+	for i := 0; i < 500; i++ {
+		storage.topURLs = append(storage.topURLs, &storage_service.URLSummary{
+			ShortCode:   fmt.Sprintf("hot%03d", i),
+			OriginalUrl: fmt.Sprintf("https://example.com/%d", i),
+			ClickCount:  int64(1000 - i),
+			CreatedAt:   fakeCreatedAt,
+		})
+	}
+	// Limited and disabled links go to memory but never the cache
+	storage.topURLs[1].MaxClicks = 10
+	storage.topURLs[2].Disabled = true
+
+	s.warmUp(context.Background(), 1000, "clicks", 5*time.Second)
+
+	for i, summary := range storage.topURLs {
+		entry, ok := s.urls.Get(summary.ShortCode)
+		if !ok || entry.originalURL != summary.OriginalUrl || entry.clickCount != summary.ClickCount {
+			t.Fatalf("memory holds %+v, %v for %s, want %s", entry, ok, summary.ShortCode, summary.OriginalUrl)
+		}
+		value, cached := cache.entry("url:" + summary.ShortCode)
+		if wantCached := i != 1 && i != 2; cached != wantCached || cached && value != summary.OriginalUrl {
+			t.Fatalf("cache holds %q, %v for %s, want cached %v", value, cached, summary.ShortCode, wantCached)
+		}
+		if cached {
+			if count, _ := cache.entry("count:" + summary.ShortCode); count != strconv.FormatInt(summary.ClickCount, 10) {
+				t.Fatalf("cached count %q for %s, want %d", count, summary.ShortCode, summary.ClickCount)
+			}
+		}
+	}
+}
+
+func TestWarmUpBudget(t *testing.T) {
+	s, storage, _ := newTestServer(t, nil)
+	storage.topURLs = []*storage_service.URLSummary{{ShortCode: "late", OriginalUrl: "https://example.com", CreatedAt: fakeCreatedAt}}
+	storage.topDelay = time.Minute
+
+	// Startup goes on once the budget is spent, cold
+	start := time.Now()
+	s.warmUp(context.Background(), 1000, "clicks", 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("warm-up took %v with a 50ms budget", elapsed)
+	}
+	if s.urls.Contains("late") {
+		t.Error("warm-up loaded a URL after its budget ran out")
+	}
+
+	// Disabled, it doesn't ask storage at all
+	start = time.Now()
+	s.warmUp(context.Background(), 0, "clicks", time.Minute)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("disabled warm-up took %v", elapsed)
+	}
+}